@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"io"
 	"time"
+	"unicode/utf8"
 )
 
 const (
@@ -37,6 +38,9 @@ type Writer struct {
 	Config
 	TimestampNano int64
 	writer        io.Writer
+
+	// 上次输出末尾不完整的 UTF-8 字符，与下一次输出合并后写入
+	pending []byte
 }
 
 func (w *Writer) WriteHeader() error {
@@ -69,8 +73,34 @@ func (w *Writer) WriteRow(p []byte) error {
 	return w.WriteStdout(ts, p)
 }
 
+/*
+WriteStdout 写入一条 [timestamp, "o", data] 事件。asciicast 的 data 是 JSON 字符串，
+多字节字符被拆分到两次输出时，直接序列化会被替换成 U+FFFD，回放时显示乱码，
+所以末尾不完整的字符留到下一次输出时再写入。
+*/
+
 func (w *Writer) WriteStdout(ts float64, data []byte) error {
-	row := []interface{}{ts, "o", string(data)}
+	return w.writeEvent(ts, "o", data)
+}
+
+// WriteInput 写入一条 [timestamp, "i", data] 事件，记录用户的输入
+func (w *Writer) WriteInput(ts float64, data []byte) error {
+	return w.writeEvent(ts, "i", data)
+}
+
+func (w *Writer) writeEvent(ts float64, eventType string, data []byte) error {
+	if len(w.pending) > 0 {
+		data = append(w.pending, data...)
+		w.pending = nil
+	}
+	data, rest := splitIncompleteRune(data)
+	if len(rest) > 0 {
+		w.pending = append([]byte(nil), rest...)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	row := []interface{}{ts, eventType, string(data)}
 	raw, err := json.Marshal(row)
 	if err != nil {
 		return err
@@ -83,6 +113,20 @@ func (w *Writer) WriteStdout(ts float64, data []byte) error {
 	return err
 }
 
+// splitIncompleteRune 拆分出末尾不完整的 UTF-8 字符，非法的编码不做处理
+func splitIncompleteRune(p []byte) ([]byte, []byte) {
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if !utf8.RuneStart(p[i]) {
+			continue
+		}
+		if !utf8.FullRune(p[i:]) {
+			return p[:i], p[i:]
+		}
+		break
+	}
+	return p, nil
+}
+
 type Header struct {
 	Version   int    `json:"version"`
 	Width     int    `json:"width"`
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const defaultLimiterMaxEntries = 10000
+
+/*
+IPAuthLimiter 按来源 IP 统计认证失败次数，窗口期内失败次数达到阈值后锁定该 IP 一段时间。
+记录的 IP 数量有上限，超出后淘汰最久未更新的记录，避免大量不同 IP 的请求耗尽内存。
+*/
+
+type IPAuthLimiter struct {
+	threshold  int
+	window     time.Duration
+	lockout    time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // 队首为最近更新的记录
+
+	now func() time.Time
+}
+
+type ipAuthRecord struct {
+	ip          string
+	failures    int
+	windowStart time.Time
+	lockedUntil time.Time
+}
+
+// NewIPAuthLimiter threshold 小于等于 0 时不限制
+func NewIPAuthLimiter(threshold int, window, lockout time.Duration, maxEntries int) *IPAuthLimiter {
+	if maxEntries <= 0 {
+		maxEntries = defaultLimiterMaxEntries
+	}
+	return &IPAuthLimiter{
+		threshold:  threshold,
+		window:     window,
+		lockout:    lockout,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+		now:        time.Now,
+	}
+}
+
+func (l *IPAuthLimiter) Enabled() bool {
+	return l != nil && l.threshold > 0
+}
+
+// IsLocked 返回该 IP 是否处于锁定状态以及剩余的锁定时间
+func (l *IPAuthLimiter) IsLocked(ip string) (bool, time.Duration) {
+	if !l.Enabled() {
+		return false, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	elem, ok := l.entries[ip]
+	if !ok {
+		return false, 0
+	}
+	record := elem.Value.(*ipAuthRecord)
+	if remain := record.lockedUntil.Sub(l.now()); remain > 0 {
+		// 仍在尝试连接的锁定 IP 保持在队首，不会被新 IP 的记录挤出
+		l.lru.MoveToFront(elem)
+		return true, remain
+	}
+	return false, 0
+}
+
+// Failed 记录一次认证失败，本次失败导致锁定时返回 true
+func (l *IPAuthLimiter) Failed(ip string) bool {
+	if !l.Enabled() {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	var record *ipAuthRecord
+	if elem, ok := l.entries[ip]; ok {
+		record = elem.Value.(*ipAuthRecord)
+		l.lru.MoveToFront(elem)
+	} else {
+		record = &ipAuthRecord{ip: ip}
+		l.entries[ip] = l.lru.PushFront(record)
+		l.evict()
+	}
+	if record.lockedUntil.After(now) {
+		return false
+	}
+	if record.failures == 0 || now.Sub(record.windowStart) > l.window {
+		// 开始新的统计窗口
+		record.failures = 0
+		record.windowStart = now
+	}
+	record.failures++
+	if record.failures < l.threshold {
+		return false
+	}
+	record.failures = 0
+	record.lockedUntil = now.Add(l.lockout)
+	return true
+}
+
+// Reset 认证成功后清除该 IP 的失败记录
+func (l *IPAuthLimiter) Reset(ip string) {
+	if !l.Enabled() {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if elem, ok := l.entries[ip]; ok {
+		l.lru.Remove(elem)
+		delete(l.entries, ip)
+	}
+}
+
+func (l *IPAuthLimiter) evict() {
+	for l.lru.Len() > l.maxEntries {
+		oldest := l.lru.Back()
+		l.lru.Remove(oldest)
+		delete(l.entries, oldest.Value.(*ipAuthRecord).ip)
+	}
+}
+
+func (l *IPAuthLimiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lru.Len()
+}
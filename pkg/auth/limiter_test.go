@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestLimiter(clock *fakeClock, maxEntries int) *IPAuthLimiter {
+	limiter := NewIPAuthLimiter(3, time.Minute, 10*time.Minute, maxEntries)
+	limiter.now = clock.Now
+	return limiter
+}
+
+func TestIPAuthLimiterLockout(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	limiter := newTestLimiter(clock, 0)
+	ip := "192.168.1.10"
+
+	for i := 1; i < 3; i++ {
+		if limiter.Failed(ip) {
+			t.Fatalf("Failed() locked after %d failures, want 3", i)
+		}
+	}
+	if locked, _ := limiter.IsLocked(ip); locked {
+		t.Fatal("IsLocked() = true before reaching the threshold")
+	}
+	if !limiter.Failed(ip) {
+		t.Fatal("Failed() should lock the IP at the threshold")
+	}
+	locked, remain := limiter.IsLocked(ip)
+	if !locked || remain != 10*time.Minute {
+		t.Fatalf("IsLocked() = %v, %s, want true, 10m0s", locked, remain)
+	}
+	if locked, _ = limiter.IsLocked("192.168.1.11"); locked {
+		t.Error("IsLocked() other IP = true, want false")
+	}
+	// 锁定期间的失败不延长锁定时间
+	clock.Add(5 * time.Minute)
+	limiter.Failed(ip)
+	if _, remain = limiter.IsLocked(ip); remain != 5*time.Minute {
+		t.Errorf("IsLocked() remain = %s, want 5m0s", remain)
+	}
+
+	clock.Add(5*time.Minute + time.Second)
+	if locked, _ = limiter.IsLocked(ip); locked {
+		t.Fatal("IsLocked() = true after the lockout expired")
+	}
+	// 锁定结束后重新计数
+	if limiter.Failed(ip) {
+		t.Error("Failed() locked on the first failure after the lockout expired")
+	}
+}
+
+func TestIPAuthLimiterWindowAndReset(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	limiter := newTestLimiter(clock, 0)
+	ip := "10.0.0.1"
+
+	limiter.Failed(ip)
+	limiter.Failed(ip)
+	// 超出统计窗口后之前的失败不再计数
+	clock.Add(time.Minute + time.Second)
+	if limiter.Failed(ip) {
+		t.Fatal("Failed() counted failures outside the window")
+	}
+	limiter.Failed(ip)
+	limiter.Reset(ip)
+	if limiter.Failed(ip) || limiter.Failed(ip) {
+		t.Fatal("Failed() counted failures before Reset()")
+	}
+	if !limiter.Failed(ip) {
+		t.Error("Failed() should lock the IP after 3 failures following Reset()")
+	}
+	limiter.Reset(ip)
+	if locked, _ := limiter.IsLocked(ip); locked {
+		t.Error("IsLocked() = true after Reset()")
+	}
+}
+
+func TestIPAuthLimiterBounded(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	limiter := newTestLimiter(clock, 100)
+	locked := "172.16.0.1"
+	for i := 0; i < 3; i++ {
+		limiter.Failed(locked)
+	}
+	for i := 0; i < 1000; i++ {
+		limiter.Failed(fmt.Sprintf("10.1.%d.%d", i/256, i%256))
+		if i%50 == 0 {
+			// 锁定的 IP 持续重试连接
+			limiter.IsLocked(locked)
+		}
+	}
+	if n := limiter.Len(); n != 100 {
+		t.Errorf("Len() = %d, want 100", n)
+	}
+	if ok, _ := limiter.IsLocked(locked); !ok {
+		t.Error("IsLocked() = false, the retrying locked IP should not be evicted")
+	}
+}
+
+func TestIPAuthLimiterDisabled(t *testing.T) {
+	var limiter *IPAuthLimiter
+	if limiter.Failed("10.0.0.1") {
+		t.Error("nil limiter Failed() = true")
+	}
+	limiter = NewIPAuthLimiter(0, time.Minute, time.Minute, 0)
+	for i := 0; i < 10; i++ {
+		limiter.Failed("10.0.0.1")
+	}
+	if ok, _ := limiter.IsLocked("10.0.0.1"); ok {
+		t.Error("disabled limiter IsLocked() = true")
+	}
+}
@@ -1,28 +1,48 @@
 package auth
 
 import (
+	"crypto/subtle"
 	"net"
 	"strings"
+	"time"
 
 	"github.com/gliderlabs/ssh"
 	gossh "golang.org/x/crypto/ssh"
 
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/i18n"
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/service"
 	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/metrics"
 )
 
 type SSHAuthFunc func(ctx ssh.Context, password, publicKey string) (res ssh.AuthResult)
 
+// keyboardInteractiveDisabled 关闭 keyboard-interactive 认证后无法进行 MFA 和登录复核
+var keyboardInteractiveDisabled bool
+
+// InitSSHAuthMethods 设置 SSH 服务是否开启 keyboard-interactive 认证
+func InitSSHAuthMethods(keyboardInteractive bool) {
+	keyboardInteractiveDisabled = !keyboardInteractive
+}
+
 func SSHPasswordAndPublicKeyAuth(jmsService *service.JMService) SSHAuthFunc {
 	return func(ctx ssh.Context, password, publicKey string) (res ssh.AuthResult) {
 		remoteAddr, _, _ := net.SplitHostPort(ctx.RemoteAddr().String())
 		username := ctx.User()
+		if sshAuthLocked(ctx, remoteAddr) {
+			return ssh.AuthFailed
+		}
+		if strings.HasPrefix(username, oneTimeTokenPrefix) {
+			return oneTimeTokenAuth(jmsService, ctx, remoteAddr)
+		}
 		if req, ok := parseDirectLoginReq(jmsService, ctx); ok {
 			if req.IsToken() && req.Authenticate(password) {
 				ctx.SetValue(ContextKeyUser, &req.ConnectToken.User)
+				sshAuthLimiterReset(remoteAddr)
 				logger.Infof("SSH conn[%s] %s for %s from %s", ctx.SessionID(),
-					actionAccepted, username, remoteAddr)
+					actionAccepted, RedactUsername(username), remoteAddr)
 				return ssh.AuthSuccessful
 			}
 			username = req.User()
@@ -53,6 +73,15 @@ func SSHPasswordAndPublicKeyAuth(jmsService *service.JMService) SSHAuthFunc {
 			service.UserClientPublicKey(publicKey))
 		logger.Infof("SSH conn[%s] authenticating user %s %s", ctx.SessionID(), username, authMethod)
 		user, authStatus := userAuthClient.Authenticate(ctx)
+		ctx.SetValue(ContextKeyCoreUnreachable, authStatus == authCoreUnreachable)
+		ctx.SetValue(ContextKeyPasswordExpired, authStatus == authPasswordExpired)
+		if keyboardInteractiveDisabled && (authStatus == authMFARequired || authStatus == authConfirmRequired) {
+			// 没有 keyboard-interactive 无法完成第二步认证，直接拒绝，不计入认证失败的次数
+			logger.Warnf("SSH conn[%s] %s %s for %s from %s: MFA or login confirmation required, "+
+				"but keyboard-interactive auth is disabled", ctx.SessionID(), actionFailed, authMethod, username, remoteAddr)
+			metrics.AuthFailed("ssh")
+			return ssh.AuthFailed
+		}
 		switch authStatus {
 		case authMFARequired:
 			action = actionPartialAccepted
@@ -61,12 +90,26 @@ func SSHPasswordAndPublicKeyAuth(jmsService *service.JMService) SSHAuthFunc {
 		case authSuccess:
 			res = ssh.AuthSuccessful
 			ctx.SetValue(ContextKeyUser, &user)
+			sshAuthLimiterReset(remoteAddr)
 		case authConfirmRequired:
 			action = actionPartialAccepted
 			res = ssh.AuthPartiallySuccessful
 			ctx.SetValue(ContextKeyAuthStatus, authConfirmRequired)
+		case authCoreUnreachable:
+			// core 无法访问不是用户的错误，不计入认证失败的次数
+			action = actionFailed
+			metrics.AuthFailed("ssh")
+		case authPasswordExpired:
+			// 密码正确但已过期，由 keyboard-interactive 提示用户修改密码，不计入认证失败的次数
+			action = actionFailed
+			metrics.AuthFailed("ssh")
 		default:
 			action = actionFailed
+			metrics.AuthFailed("ssh")
+			// 客户端会依次尝试多个公钥，只统计密码认证的失败
+			if password != "" {
+				sshAuthLimiterFailed(ctx, remoteAddr)
+			}
 		}
 		logger.Infof("SSH conn[%s] %s %s for %s from %s", ctx.SessionID(),
 			action, authMethod, username, remoteAddr)
@@ -74,7 +117,123 @@ func SSHPasswordAndPublicKeyAuth(jmsService *service.JMService) SSHAuthFunc {
 	}
 }
 
+/*
+oneTimeTokenAuth 用户名即一次性令牌，格式为 token-{id}.{secret}，secret 为连接令牌的 value，
+校验通过后直接连接令牌对应的资产，不再需要密码或公钥。
+secret 校验通过后令牌先在本地占用，保证并发的连接只有一个能继续；然后在 core 使令牌失效，
+失效成功才算认证通过，失败时令牌仍然可能有效，不放行也不再允许本节点使用。
+*/
+func oneTimeTokenAuth(jmsService *service.JMService, ctx ssh.Context, remoteAddr string) ssh.AuthResult {
+	username := RedactUsername(ctx.User())
+	if _, ok := ctx.Value(ContextKeyAuthFailedReason).(string); ok {
+		// 同一连接中会尝试多个认证方式，令牌校验失败后不再请求 core
+		return ssh.AuthFailed
+	}
+	authFailed := func(reason string) ssh.AuthResult {
+		ctx.SetValue(ContextKeyAuthFailedReason, reason)
+		metrics.AuthFailed("ssh")
+		sshAuthLimiterFailed(ctx, remoteAddr)
+		logger.Infof("SSH conn[%s] %s token for %s from %s: %s", ctx.SessionID(),
+			actionFailed, username, remoteAddr, reason)
+		return ssh.AuthFailed
+	}
+	tokenId, secret, ok := parseOneTimeToken(ctx.User())
+	if !ok {
+		return authFailed("invalid token")
+	}
+	connectToken, err := jmsService.GetConnectTokenInfo(tokenId)
+	if reason := checkOneTimeToken(&connectToken, err); reason != "" {
+		if err != nil {
+			logger.Errorf("SSH conn[%s] check one-time token %s failed: %s", ctx.SessionID(), username, err)
+		}
+		return authFailed(reason)
+	}
+	if connectToken.Value == "" || subtle.ConstantTimeCompare([]byte(connectToken.Value), []byte(secret)) != 1 {
+		// secret 不匹配时令牌没有被使用，不占用也不失效，避免只知道 id 的人使其不可用
+		return authFailed("invalid token")
+	}
+	if !oneTimeTokens.Claim(tokenId, time.Now().Add(oneTimeTokenClaimTTL)) {
+		return authFailed("token already used")
+	}
+	if err = jmsService.ExpireConnectToken(tokenId); err != nil {
+		logger.Errorf("SSH conn[%s] expire one-time token %s failed: %s", ctx.SessionID(), username, err)
+		return authFailed("expire token failed")
+	}
+	req := DirectLoginAssetReq{ConnectToken: &connectToken, Protocol: connectToken.Protocol}
+	ctx.SetValue(ContextKeyDirectLoginFormat, &req)
+	ctx.SetValue(ContextKeyUser, &connectToken.User)
+	sshAuthLimiterReset(remoteAddr)
+	logger.Infof("SSH conn[%s] %s token for %s(%s) from %s", ctx.SessionID(),
+		actionAccepted, username, connectToken.User.String(), remoteAddr)
+	return ssh.AuthSuccessful
+}
+
+// parseOneTimeToken 从 token-{id}.{secret} 格式的用户名中解析令牌 id 和 secret
+func parseOneTimeToken(username string) (tokenId, secret string, ok bool) {
+	value := strings.TrimPrefix(username, oneTimeTokenPrefix)
+	tokenId, secret, ok = strings.Cut(value, oneTimeTokenSecretSep)
+	return tokenId, secret, ok && tokenId != "" && secret != ""
+}
+
+// oneTimeTokenClaimTTL 本地记录已使用令牌的时长，超过后由 core 的失效状态拒绝
+const oneTimeTokenClaimTTL = 24 * time.Hour
+
+var oneTimeTokens = newTokenClaims()
+
+// checkOneTimeToken 返回令牌不可用的原因，可用时返回空字符串
+func checkOneTimeToken(token *model.ConnectToken, err error) string {
+	switch {
+	case err != nil:
+		return "invalid token"
+	case token.Code != "" || token.Detail != "":
+		if token.Detail != "" {
+			return token.Detail
+		}
+		return token.Code
+	case token.Id == "" || token.User.ID == "":
+		return "invalid token"
+	case token.ExpireAt > 0 && token.ExpireAt.IsExpired(time.Now()):
+		return "token expired"
+	}
+	return ""
+}
+
+// passwordExpiredMessage 配置了 PASSWORD_CHANGE_URL 时附带修改密码的地址
+func passwordExpiredMessage() string {
+	msg := i18n.T("Your password has expired, please change it and try again")
+	if url := config.GetConf().PasswordChangeURL; url != "" {
+		msg += ": " + url
+	}
+	return msg
+}
+
+// RedactUsername 隐藏用户名中的令牌，避免写入日志
+func RedactUsername(username string) string {
+	for _, prefix := range []string{oneTimeTokenPrefix, tokenPrefix} {
+		if strings.HasPrefix(username, prefix) {
+			return prefix + "******"
+		}
+	}
+	return username
+}
+
 func SSHKeyboardInteractiveAuth(ctx ssh.Context, challenger gossh.KeyboardInteractiveChallenge) (res ssh.AuthResult) {
+	if reason, ok := ctx.Value(ContextKeyAuthFailedReason).(string); ok {
+		// 通过 keyboard-interactive 的提示信息告知客户端令牌认证失败的原因
+		_, _ = challenger(RedactUsername(ctx.User()), "Authentication failed: "+reason, nil, nil)
+		return ssh.AuthFailed
+	}
+	if unreachable, ok := ctx.Value(ContextKeyCoreUnreachable).(bool); ok && unreachable {
+		// 通过 keyboard-interactive 的提示信息告知客户端 core 无法访问，而不是密码错误
+		msg := i18n.T("JumpServer core is unreachable, please try again later")
+		_, _ = challenger(RedactUsername(ctx.User()), msg, nil, nil)
+		return ssh.AuthFailed
+	}
+	if expired, ok := ctx.Value(ContextKeyPasswordExpired).(bool); ok && expired {
+		// 明确告知密码已过期，而不是密码错误
+		_, _ = challenger(RedactUsername(ctx.User()), passwordExpiredMessage(), nil, nil)
+		return ssh.AuthFailed
+	}
 	if value, ok := ctx.Value(ContextKeyAuthFailed).(*bool); ok && *value {
 		return ssh.AuthFailed
 	}
@@ -98,9 +257,16 @@ func SSHKeyboardInteractiveAuth(ctx ssh.Context, challenger gossh.KeyboardIntera
 	case authMFARequired:
 		checkAuth = client.CheckMFAAuth
 	}
+	remoteAddr, _, _ := net.SplitHostPort(ctx.RemoteAddr().String())
+	if sshAuthLocked(ctx, remoteAddr) {
+		return
+	}
 	if checkAuth != nil && checkAuth(ctx, challenger) {
 		res = ssh.AuthSuccessful
+		sshAuthLimiterReset(remoteAddr)
+		return
 	}
+	sshAuthLimiterFailed(ctx, remoteAddr)
 	return
 }
 
@@ -112,6 +278,12 @@ const (
 
 	ContextKeyAuthFailed = "CONTEXT_AUTH_FAILED"
 
+	ContextKeyAuthFailedReason = "CONTEXT_AUTH_FAILED_REASON"
+
+	ContextKeyCoreUnreachable = "CONTEXT_CORE_UNREACHABLE"
+
+	ContextKeyPasswordExpired = "CONTEXT_PASSWORD_EXPIRED"
+
 	ContextKeyDirectLoginFormat = "CONTEXT_DIRECT_LOGIN_FORMAT"
 )
 
@@ -147,6 +319,12 @@ const (
 
 	*/
 	tokenPrefix = "JMS-"
+
+	/*
+		格式为: token-{id}.{secret}，一次性的连接令牌，secret 为令牌的 value，用户名即凭证
+	*/
+	oneTimeTokenPrefix    = "token-"
+	oneTimeTokenSecretSep = "."
 )
 
 const (
@@ -223,7 +401,7 @@ func parseJMSTokenLoginReq(jmsService *service.JMService, ctx ssh.Context) (*Dir
 				Protocol: connectToken.Protocol}
 			return &req, true
 		} else {
-			logger.Errorf("Check user token %s failed: %s", ctx.User(), err)
+			logger.Errorf("Check user token %s failed: %s", RedactUsername(ctx.User()), err)
 		}
 	}
 	return nil, false
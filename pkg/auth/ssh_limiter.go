@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"net"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+// sshAuthLimiter SSH 服务认证失败的限制，未初始化时不限制
+var sshAuthLimiter *IPAuthLimiter
+
+func InitSSHAuthLimiter(threshold int, window, lockout time.Duration, maxEntries int) {
+	sshAuthLimiter = NewIPAuthLimiter(threshold, window, lockout, maxEntries)
+	if sshAuthLimiter.Enabled() {
+		logger.Infof("SSH auth limiter enabled: %d failures in %s lock the source IP for %s",
+			threshold, window, lockout)
+	}
+}
+
+// SSHConnCallback 拒绝处于锁定状态的 IP 建立新的连接
+func SSHConnCallback(ctx ssh.Context, conn net.Conn) net.Conn {
+	remoteAddr, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	if locked, remain := sshAuthLimiter.IsLocked(remoteAddr); locked {
+		logger.Warnf("SSH conn from %s refused: locked for too many auth failures, %s remaining",
+			remoteAddr, remain.Round(time.Second))
+		return nil
+	}
+	return conn
+}
+
+func sshAuthLimiterFailed(ctx ssh.Context, remoteAddr string) {
+	if sshAuthLimiter.Failed(remoteAddr) {
+		logger.Warnf("SSH conn[%s] source IP %s locked for too many auth failures",
+			ctx.SessionID(), remoteAddr)
+	}
+}
+
+func sshAuthLimiterReset(remoteAddr string) {
+	sshAuthLimiter.Reset(remoteAddr)
+}
+
+func sshAuthLocked(ctx ssh.Context, remoteAddr string) bool {
+	locked, _ := sshAuthLimiter.IsLocked(remoteAddr)
+	if locked {
+		logger.Infof("SSH conn[%s] reject auth from locked IP %s", ctx.SessionID(), remoteAddr)
+	}
+	return locked
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gliderlabs/ssh"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+type authTestContext struct {
+	ssh.Context
+	user   string
+	values map[interface{}]interface{}
+}
+
+func (c *authTestContext) User() string { return c.user }
+
+func (c *authTestContext) SessionID() string { return "test" }
+
+func (c *authTestContext) Value(key interface{}) interface{} { return c.values[key] }
+
+func (c *authTestContext) SetValue(key, value interface{}) { c.values[key] = value }
+
+func TestOneTimeTokenAuth(t *testing.T) {
+	var expireFailed, expired atomic.Int32
+	jms := newTestJMService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/expire/") {
+			if expireFailed.Load() == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(`{}`))
+				return
+			}
+			expired.Add(1)
+			_, _ = w.Write([]byte(`{}`))
+			return
+		}
+		var data map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&data)
+		_ = json.NewEncoder(w).Encode(model.ConnectToken{Id: data["id"], Value: "secret-" + data["id"], User: model.User{ID: "user-1"}})
+	}))
+	auth := func(token string) ssh.AuthResult {
+		ctx := &authTestContext{user: oneTimeTokenPrefix + token, values: map[interface{}]interface{}{}}
+		return oneTimeTokenAuth(jms, ctx, "127.0.0.1")
+	}
+
+	if auth("token-1") != ssh.AuthFailed {
+		t.Error("token without secret should fail")
+	}
+	if auth("token-1.wrong") != ssh.AuthFailed {
+		t.Error("token with wrong secret should fail")
+	}
+	if expired.Load() != 0 {
+		t.Error("token should not be expired by a wrong secret")
+	}
+	if auth("token-1.secret-token-1") != ssh.AuthSuccessful {
+		t.Error("first use of token should succeed")
+	}
+	if auth("token-1.secret-token-1") != ssh.AuthFailed {
+		t.Error("reuse of token should fail")
+	}
+	if expired.Load() != 1 {
+		t.Errorf("token expired %d times, want 1", expired.Load())
+	}
+	expireFailed.Store(1)
+	if auth("token-2.secret-token-2") != ssh.AuthFailed {
+		t.Error("token should not be accepted when expire failed")
+	}
+}
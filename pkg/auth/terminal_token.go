@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/service"
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+var (
+	ErrTerminalTokenInvalid = errors.New("terminal token invalid")
+	ErrTerminalTokenExpired = errors.New("terminal token expired")
+	ErrTerminalTokenUsed    = errors.New("terminal token already used")
+)
+
+// TerminalTokenProtocolPrefix 浏览器无法设置 websocket 的请求头，令牌放在 Sec-WebSocket-Protocol 中，格式为 jms-token.{token}
+const TerminalTokenProtocolPrefix = "jms-token."
+
+/*
+TerminalTokenClaims websocket 终端使用的连接令牌，由 Web 控制台使用 WS_TERMINAL_SECRET 签名，
+格式为 base64url(JSON 内容).base64url(HMAC-SHA256 签名)，jti 为令牌的唯一 ID，每个令牌只能使用一次
+*/
+type TerminalTokenClaims struct {
+	ID        string `json:"jti"`
+	UserID    string `json:"user_id"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+func signTerminalPayload(secret string, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SignTerminalToken 生成 websocket 终端的连接令牌
+func SignTerminalToken(secret string, claims TerminalTokenClaims) (string, error) {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + signTerminalPayload(secret, payload), nil
+}
+
+// ParseTerminalToken 校验签名和有效期，返回令牌中的内容
+func ParseTerminalToken(secret, token string, now time.Time) (*TerminalTokenClaims, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || secret == "" {
+		return nil, ErrTerminalTokenInvalid
+	}
+	expected := signTerminalPayload(secret, payload)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return nil, ErrTerminalTokenInvalid
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrTerminalTokenInvalid, err)
+	}
+	var claims TerminalTokenClaims
+	if err = json.Unmarshal(data, &claims); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrTerminalTokenInvalid, err)
+	}
+	if claims.ID == "" || claims.UserID == "" {
+		return nil, ErrTerminalTokenInvalid
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, ErrTerminalTokenExpired
+	}
+	return &claims, nil
+}
+
+var usedTerminalTokens = newTokenClaims()
+
+// terminalTokenFromRequest 令牌只从 Authorization 请求头或 Sec-WebSocket-Protocol 中读取，避免出现在访问日志的 URL 中
+func terminalTokenFromRequest(r *http.Request) string {
+	if token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); token != "" {
+		return token
+	}
+	for _, value := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(value, ",") {
+			if token := strings.TrimSpace(protocol); strings.HasPrefix(token, TerminalTokenProtocolPrefix) {
+				return strings.TrimPrefix(token, TerminalTokenProtocolPrefix)
+			}
+		}
+	}
+	return ""
+}
+
+/*
+CheckTerminalOrigin 浏览器发起的连接 Origin 需要与请求的 Host 相同或者在 WS_TERMINAL_ALLOWED_ORIGINS 中，
+防止其他站点的页面使用用户浏览器中的令牌连接，没有 Origin 的非浏览器客户端不校验
+*/
+func CheckTerminalOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range config.GetConf().WsTerminalAllowedOrigins {
+		if strings.EqualFold(strings.TrimRight(strings.TrimSpace(allowed), "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// HTTPMiddleTerminalTokenAuth 校验连接请求的 Origin 和终端令牌(Authorization: Bearer 请求头或 Sec-WebSocket-Protocol)
+func HTTPMiddleTerminalTokenAuth(jmsService *service.JMService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		secret := config.GetConf().WsTerminalSecret
+		if secret == "" {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		if !CheckTerminalOrigin(ctx.Request) {
+			logger.Errorf("Reject terminal token request from %s: origin %s not allowed",
+				ctx.ClientIP(), ctx.GetHeader("Origin"))
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		now := time.Now()
+		claims, err := ParseTerminalToken(secret, terminalTokenFromRequest(ctx.Request), now)
+		if err == nil && !usedTerminalTokens.Claim(claims.ID, time.Unix(claims.ExpiresAt, 0)) {
+			err = ErrTerminalTokenUsed
+		}
+		if err != nil {
+			logger.Errorf("Check terminal token from %s failed: %s", ctx.ClientIP(), err)
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		user, err := jmsService.GetUserById(claims.UserID)
+		if err != nil || user == nil || !user.IsActive {
+			logger.Errorf("Get terminal token user %s failed: %v", claims.UserID, err)
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		ctx.Set(ContextKeyUser, user)
+	}
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestParseTerminalToken(t *testing.T) {
+	now := time.Now()
+	token, err := SignTerminalToken("secret", TerminalTokenClaims{ID: "jti-1", UserID: "user-1", ExpiresAt: now.Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ParseTerminalToken("secret", token, now)
+	if err != nil || claims.UserID != "user-1" {
+		t.Fatalf("ParseTerminalToken() = %+v, %v, want user-1", claims, err)
+	}
+	if _, err = ParseTerminalToken("other", token, now); !errors.Is(err, ErrTerminalTokenInvalid) {
+		t.Errorf("ParseTerminalToken() wrong secret err = %v, want invalid", err)
+	}
+	if _, err = ParseTerminalToken("secret", token+"x", now); !errors.Is(err, ErrTerminalTokenInvalid) {
+		t.Errorf("ParseTerminalToken() tampered err = %v, want invalid", err)
+	}
+	if _, err = ParseTerminalToken("secret", token, now.Add(2*time.Minute)); !errors.Is(err, ErrTerminalTokenExpired) {
+		t.Errorf("ParseTerminalToken() expired err = %v, want expired", err)
+	}
+	if _, err = ParseTerminalToken("", token, now); !errors.Is(err, ErrTerminalTokenInvalid) {
+		t.Errorf("ParseTerminalToken() empty secret err = %v, want invalid", err)
+	}
+}
+
+func TestHTTPMiddleTerminalTokenAuth(t *testing.T) {
+	jms := newTestJMService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(model.User{ID: "user-1", Username: "alice", IsActive: true})
+	}))
+	conf := config.GetConf()
+	conf.WsTerminalSecret = "secret"
+	conf.WsTerminalAllowedOrigins = []string{"https://console.example.com/"}
+	setTestConfig(t, &conf)
+
+	gin.SetMode(gin.TestMode)
+	eng := gin.New()
+	eng.GET("/", HTTPMiddleTerminalTokenAuth(jms), func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	sign := func(id string) string {
+		token, err := SignTerminalToken("secret", TerminalTokenClaims{ID: id, UserID: "user-1",
+			ExpiresAt: time.Now().Add(time.Minute).Unix()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	do := func(target string, header http.Header) int {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Host = "koko.example.com"
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		eng.ServeHTTP(w, req)
+		return w.Code
+	}
+	token := sign("jti-1")
+	tests := []struct {
+		name   string
+		target string
+		header http.Header
+		want   int
+	}{
+		{name: "query token", target: "/?token=" + sign("jti-2"), want: http.StatusUnauthorized},
+		{name: "protocol", target: "/", header: http.Header{
+			"Sec-Websocket-Protocol": {"JMS-KOKO, " + TerminalTokenProtocolPrefix + token},
+			"Origin":                 {"https://koko.example.com"}}, want: http.StatusOK},
+		{name: "reused", target: "/", header: http.Header{"Authorization": {"Bearer " + token}},
+			want: http.StatusUnauthorized},
+		{name: "allowed origin", target: "/", header: http.Header{"Authorization": {"Bearer " + sign("jti-3")},
+			"Origin": {"https://console.example.com"}}, want: http.StatusOK},
+		{name: "other origin", target: "/", header: http.Header{"Authorization": {"Bearer " + sign("jti-4")},
+			"Origin": {"https://evil.example.com"}}, want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if got := do(tt.target, tt.header); got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenClaims 记录本节点已经使用过的一次性令牌，过期后删除
+type tokenClaims struct {
+	mu     sync.Mutex
+	claims map[string]time.Time
+}
+
+func newTokenClaims() *tokenClaims {
+	return &tokenClaims{claims: make(map[string]time.Time)}
+}
+
+// Claim 令牌没有被使用过时记录到 until 并返回 true
+func (c *tokenClaims) Claim(id string, until time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for claimedId, expireAt := range c.claims {
+		if now.After(expireAt) {
+			delete(c.claims, claimedId)
+		}
+	}
+	if _, ok := c.claims[id]; ok {
+		return false
+	}
+	c.claims[id] = until
+	return true
+}
+
+func (c *tokenClaims) Release(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.claims, id)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/gliderlabs/ssh"
 	gossh "golang.org/x/crypto/ssh"
 
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/httplib"
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/service"
 	"github.com/jumpserver/koko/pkg/logger"
@@ -38,6 +39,9 @@ func (u *UserAuthClient) Authenticate(ctx context.Context) (user model.User, aut
 	resp, err := u.UserClient.GetAPIToken()
 	if err != nil {
 		logger.Errorf("User %s Authenticate err: %s", u.Opts.Username, err)
+		if httplib.IsUnreachable(err) {
+			authStatus = authCoreUnreachable
+		}
 		return
 	}
 	if resp.Err != "" {
@@ -45,6 +49,9 @@ func (u *UserAuthClient) Authenticate(ctx context.Context) (user model.User, aut
 		case ErrLoginConfirmWait:
 			logger.Infof("User %s login need confirmation", u.Opts.Username)
 			authStatus = authConfirmRequired
+		case ErrPasswordExpired:
+			logger.Infof("User %s password expired", u.Opts.Username)
+			authStatus = authPasswordExpired
 		case ErrMFARequired:
 			u.mfaTypes = nil
 			for _, choiceType := range resp.Data.Choices {
@@ -235,6 +242,7 @@ const (
 	ErrLoginConfirmRequired = "login_confirm_required"
 	ErrMFARequired          = "mfa_required"
 	ErrPasswordFailed       = "password_failed"
+	ErrPasswordExpired      = "password_expired"
 )
 
 func (u *UserAuthClient) CheckConfirm(ctx context.Context) (user model.User, authStatus StatusAuth) {
@@ -288,4 +296,8 @@ const (
 	authFailed
 	authMFARequired
 	authConfirmRequired
+	// authCoreUnreachable core 无法访问，无法判断认证结果
+	authCoreUnreachable
+	// authPasswordExpired 密码已过期，修改密码后才能登录
+	authPasswordExpired
 )
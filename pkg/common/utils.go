@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/netip"
 	"os"
+	"strings"
 	"time"
 	"unsafe"
 )
@@ -26,6 +27,11 @@ func EnsureDirExist(name string) error {
 }
 
 func GzipCompressFile(srcPath, dstPath string) error {
+	return GzipCompressFileLevel(srcPath, dstPath, gzip.DefaultCompression)
+}
+
+// GzipCompressFileLevel 按 level 流式压缩文件，不会把整个文件读入内存
+func GzipCompressFileLevel(srcPath, dstPath string, level int) error {
 	sf, err := os.Open(srcPath)
 	if err != nil {
 		return err
@@ -40,7 +46,10 @@ func GzipCompressFile(srcPath, dstPath string) error {
 		return err
 	}
 	defer df.Close()
-	writer := gzip.NewWriter(df)
+	writer, err := gzip.NewWriterLevel(df, level)
+	if err != nil {
+		return err
+	}
 	writer.Name = sfInfo.Name()
 	writer.ModTime = time.Now().UTC()
 	_, err = io.Copy(writer, sf)
@@ -92,3 +101,34 @@ func CompareIP(ipA, ipB string) bool {
 	}
 	return addrA.Less(addrB)
 }
+
+// TrimHostBrackets 去除地址两端的空白和 IPv6 地址的方括号，如 "[::1]" 返回 "::1"，
+// 资产和网关的地址可能保存为带方括号的形式，拼接端口前需要去除，否则 net.JoinHostPort 会重复添加
+func TrimHostBrackets(host string) string {
+	host = strings.TrimSpace(host)
+	if len(host) > 1 && host[0] == '[' && host[len(host)-1] == ']' {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
+
+// DisplayHost 显示用的地址，IPv6 地址使用缩写形式并加上方括号，避免与端口或者用户名混淆
+func DisplayHost(host string) string {
+	host = TrimHostBrackets(host)
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !addr.Is6() {
+		return host
+	}
+	return "[" + addr.String() + "]"
+}
+
+// HostEqual 比较两个地址，IP 地址按解析后的值比较，"[::1]" 与 "0:0::1" 相同，主机名不区分大小写
+func HostEqual(a, b string) bool {
+	a, b = TrimHostBrackets(a), TrimHostBrackets(b)
+	addrA, errA := netip.ParseAddr(a)
+	addrB, errB := netip.ParseAddr(b)
+	if errA == nil && errB == nil {
+		return addrA == addrB
+	}
+	return strings.EqualFold(a, b)
+}
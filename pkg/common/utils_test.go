@@ -0,0 +1,56 @@
+package common
+
+import (
+	"net"
+	"testing"
+)
+
+func TestTrimHostBrackets(t *testing.T) {
+	tests := []struct {
+		address string
+		host    string
+		dial    string
+		display string
+	}{
+		{"192.168.1.2", "192.168.1.2", "192.168.1.2:22", "192.168.1.2"},
+		{" 192.168.1.2 ", "192.168.1.2", "192.168.1.2:22", "192.168.1.2"},
+		{"::1", "::1", "[::1]:22", "[::1]"},
+		{"[::1]", "::1", "[::1]:22", "[::1]"},
+		{"2001:0db8:0000::0001", "2001:0db8:0000::0001", "[2001:0db8:0000::0001]:22", "[2001:db8::1]"},
+		{"[fe80::1%eth0]", "fe80::1%eth0", "[fe80::1%eth0]:22", "[fe80::1%eth0]"},
+		{"db.example.com", "db.example.com", "db.example.com:22", "db.example.com"},
+		{"[", "[", "[:22", "["},
+	}
+	for _, tt := range tests {
+		host := TrimHostBrackets(tt.address)
+		if host != tt.host {
+			t.Errorf("TrimHostBrackets(%q) = %q, want %q", tt.address, host, tt.host)
+		}
+		if dial := net.JoinHostPort(host, "22"); dial != tt.dial {
+			t.Errorf("dial address of %q = %q, want %q", tt.address, dial, tt.dial)
+		}
+		if display := DisplayHost(tt.address); display != tt.display {
+			t.Errorf("DisplayHost(%q) = %q, want %q", tt.address, display, tt.display)
+		}
+	}
+}
+
+func TestHostEqual(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"192.168.1.2", "192.168.1.2", true},
+		{"192.168.1.2", "192.168.1.20", false},
+		{"[::1]", "::1", true},
+		{"2001:db8::1", "2001:DB8:0::0001", true},
+		{"2001:db8::1", "2001:db8::2", false},
+		{"Web.Example.com", "web.example.com", true},
+		{"web", "web2", false},
+	}
+	for _, tt := range tests {
+		if got := HostEqual(tt.a, tt.b); got != tt.want {
+			t.Errorf("HostEqual(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -27,13 +27,39 @@ type Config struct {
 	HTTPPort       string `mapstructure:"HTTPD_PORT"`
 	SSHTimeout     int    `mapstructure:"SSH_TIMEOUT"`
 
-	LogLevel string `mapstructure:"LOG_LEVEL"`
+	CoreAPITimeout      int `mapstructure:"CORE_API_TIMEOUT"`
+	CoreAPIRetry        int `mapstructure:"CORE_API_RETRY"`
+	CoreAPIRetryBackoff int `mapstructure:"CORE_API_RETRY_BACKOFF"`
+
+	SSHHostKeyFiles []string `mapstructure:"SSH_HOST_KEY_FILES"`
+
+	SSHAllowedSubsystems []string `mapstructure:"SSH_ALLOWED_SUBSYSTEMS"`
+
+	SSHAuthBanner     string `mapstructure:"SSH_AUTH_BANNER"`
+	SSHAuthBannerFile string `mapstructure:"SSH_AUTH_BANNER_FILE"`
+
+	SSHAuthMethods []string `mapstructure:"SSH_AUTH_METHODS"`
+
+	SSHAlgorithmProfile string   `mapstructure:"SSH_ALGORITHM_PROFILE"`
+	SSHCiphers          []string `mapstructure:"SSH_CIPHERS"`
+	SSHKeyExchanges     []string `mapstructure:"SSH_KEY_EXCHANGES"`
+	SSHMACs             []string `mapstructure:"SSH_MACS"`
+
+	SSHAuthFailureLimit  int `mapstructure:"SSH_AUTH_FAILURE_LIMIT"`
+	SSHAuthFailureWindow int `mapstructure:"SSH_AUTH_FAILURE_WINDOW"`
+	SSHAuthLockoutTime   int `mapstructure:"SSH_AUTH_LOCKOUT_TIME"`
+	SSHAuthLimiterMaxIPs int `mapstructure:"SSH_AUTH_LIMITER_MAX_IPS"`
+
+	LogLevel  string `mapstructure:"LOG_LEVEL"`
+	LogFormat string `mapstructure:"LOG_FORMAT"`
 
 	Comment             string `mapstructure:"COMMENT"`
 	LanguageCode        string `mapstructure:"LANGUAGE_CODE"`
 	UploadFailedReplay  bool   `mapstructure:"UPLOAD_FAILED_REPLAY_ON_START"`
 	UploadFailedFTPFile bool   `mapstructure:"UPLOAD_FAILED_FTP_FILE_ON_START"`
 	AssetLoadPolicy     string `mapstructure:"ASSET_LOAD_POLICY"` // all
+	AssetMenuCacheSize  int    `mapstructure:"ASSET_MENU_CACHE_SIZE"`
+	AssetMenuCacheTTL   int    `mapstructure:"ASSET_MENU_CACHE_TTL"`
 	ZipMaxSize          string `mapstructure:"ZIP_MAX_SIZE"`
 	ZipTmpPath          string `mapstructure:"ZIP_TMP_PATH"`
 	ClientAliveInterval int    `mapstructure:"CLIENT_ALIVE_INTERVAL"`
@@ -57,6 +83,139 @@ type Config struct {
 
 	HiddenFields []string `mapstructure:"HIDDEN_FIELDS"`
 
+	BannerTemplatePath string `mapstructure:"BANNER_TEMPLATE_PATH"`
+	BannerANSIPolicy   string `mapstructure:"BANNER_ANSI_POLICY"`
+	BannerMaxLength    int    `mapstructure:"BANNER_MAX_LENGTH"`
+
+	RecentSessionSize int `mapstructure:"RECENT_SESSION_SIZE"`
+
+	SessionWebhookURL string `mapstructure:"SESSION_WEBHOOK_URL"`
+
+	ZmodemPolicy string `mapstructure:"ZMODEM_POLICY"`
+
+	CommandFilterPreview bool `mapstructure:"COMMAND_FILTER_PREVIEW"`
+	CommandFilterNotice  bool `mapstructure:"COMMAND_FILTER_NOTICE"`
+
+	CommandFilterFile     string `mapstructure:"COMMAND_FILTER_FILE"`
+	CommandFilterFileMode string `mapstructure:"COMMAND_FILTER_FILE_MODE"`
+
+	ShutdownGracePeriod int `mapstructure:"SHUTDOWN_GRACE_PERIOD"`
+
+	DynamicHostZones []string `mapstructure:"DYNAMIC_HOST_ZONES"`
+
+	FirstLoginNoticePath string `mapstructure:"FIRST_LOGIN_NOTICE_PATH"`
+
+	UserSessionLimits []string `mapstructure:"USER_SESSION_LIMITS"`
+
+	AssetSessionQueue     bool `mapstructure:"ASSET_SESSION_QUEUE"`
+	AssetSessionQueueWait int  `mapstructure:"ASSET_SESSION_QUEUE_WAIT"`
+
+	SessionObserve bool `mapstructure:"SESSION_OBSERVE"`
+
+	PastePolicy string `mapstructure:"PASTE_POLICY"`
+
+	WsTerminalSecret         string   `mapstructure:"WS_TERMINAL_SECRET"`
+	WsTerminalAllowedOrigins []string `mapstructure:"WS_TERMINAL_ALLOWED_ORIGINS"`
+
+	ColorTheme      string   `mapstructure:"COLOR_THEME"`
+	ColorThemeRoles []string `mapstructure:"COLOR_THEME_ROLES"`
+
+	ReplayForceAssetRules  []string `mapstructure:"REPLAY_FORCE_ASSET_RULES"`
+	ReplayExemptAssetRules []string `mapstructure:"REPLAY_EXEMPT_ASSET_RULES"`
+
+	KeystrokeAuditAssetRules []string `mapstructure:"KEYSTROKE_AUDIT_ASSET_RULES"`
+
+	LoginUserTemplate string `mapstructure:"LOGIN_USER_TEMPLATE"`
+
+	DBTLSVerify   string `mapstructure:"DB_TLS_VERIFY"`
+	DBTLSCABundle string `mapstructure:"DB_TLS_CA_BUNDLE"`
+
+	SSHConnectHost      string `mapstructure:"SSH_CONNECT_HOST"`
+	SSHConnectProxyJump string `mapstructure:"SSH_CONNECT_PROXY_JUMP"`
+
+	DisabledMenuItems []string `mapstructure:"DISABLED_MENU_ITEMS"`
+
+	InputRateLimit int `mapstructure:"INPUT_RATE_LIMIT"`
+
+	EscapeSequence string `mapstructure:"ESCAPE_SEQUENCE"`
+
+	ScrollbackKey   string `mapstructure:"SCROLLBACK_KEY"`
+	ScrollbackLines int    `mapstructure:"SCROLLBACK_LINES"`
+
+	PasswordExpireNoticeDays int    `mapstructure:"PASSWORD_EXPIRE_NOTICE_DAYS"`
+	PasswordChangeURL        string `mapstructure:"PASSWORD_CHANGE_URL"`
+
+	SessionNotePrompt bool `mapstructure:"SESSION_NOTE_PROMPT"`
+
+	SessionTagEnvs      []string `mapstructure:"SESSION_TAG_ENVS"`
+	SessionTagMaxLength int      `mapstructure:"SESSION_TAG_MAX_LENGTH"`
+
+	EnableSessionMFA       bool     `mapstructure:"ENABLE_SESSION_MFA"`
+	SessionMFAPolicy       string   `mapstructure:"SESSION_MFA_POLICY"`
+	SessionMFAExemptUsers  []string `mapstructure:"SESSION_MFA_EXEMPT_USERS"`
+	SessionMFAMaxAttempts  int      `mapstructure:"SESSION_MFA_MAX_ATTEMPTS"`
+	SessionMFAFailureLimit int      `mapstructure:"SESSION_MFA_FAILURE_LIMIT"`
+	SessionMFALockoutTime  int      `mapstructure:"SESSION_MFA_LOCKOUT_TIME"`
+
+	BannerLineEnding string `mapstructure:"BANNER_LINE_ENDING"`
+
+	SessionDetachGrace    int `mapstructure:"SESSION_DETACH_GRACE"`
+	SessionScrollbackSize int `mapstructure:"SESSION_SCROLLBACK_SIZE"`
+
+	OutboundBindAddresses []string `mapstructure:"OUTBOUND_BIND_ADDRESSES"`
+
+	SyslogHost        string `mapstructure:"SYSLOG_HOST"`
+	SyslogPort        int    `mapstructure:"SYSLOG_PORT"`
+	SyslogTLS         bool   `mapstructure:"SYSLOG_TLS"`
+	SyslogTLSCAFile   string `mapstructure:"SYSLOG_TLS_CA_FILE"`
+	SyslogTLSInsecure bool   `mapstructure:"SYSLOG_TLS_INSECURE"`
+
+	ConfirmAssetRules []string `mapstructure:"CONFIRM_ASSET_RULES"`
+
+	ApprovalAssetRules  []string `mapstructure:"APPROVAL_ASSET_RULES"`
+	ApprovalWaitTimeout int      `mapstructure:"APPROVAL_WAIT_TIMEOUT"`
+
+	HealthListenAddr  string `mapstructure:"HEALTH_LISTEN_ADDR"`
+	HealthTLSCertFile string `mapstructure:"HEALTH_TLS_CERT_FILE"`
+	HealthTLSKeyFile  string `mapstructure:"HEALTH_TLS_KEY_FILE"`
+
+	MenuKeyBindings []string `mapstructure:"MENU_KEY_BINDINGS"`
+
+	LanguageSwitchKey string `mapstructure:"LANGUAGE_SWITCH_KEY"`
+
+	TitleSequencePolicy string `mapstructure:"TITLE_SEQUENCE_POLICY"`
+
+	BellPolicy         string `mapstructure:"BELL_POLICY"`
+	BellAuditThreshold int    `mapstructure:"BELL_AUDIT_THRESHOLD"`
+
+	ConnectInfo bool `mapstructure:"CONNECT_INFO"`
+
+	ClientAgentAssetRules []string `mapstructure:"CLIENT_AGENT_ASSET_RULES"`
+
+	SessionMaxDuration      int `mapstructure:"SESSION_MAX_DURATION"`
+	SessionMaxDurationGrace int `mapstructure:"SESSION_MAX_DURATION_GRACE"`
+
+	AccessDeniedURLTemplate string `mapstructure:"ACCESS_DENIED_URL_TEMPLATE"`
+	AccessDeniedShowAsset   bool   `mapstructure:"ACCESS_DENIED_SHOW_ASSET"`
+
+	LatencyDisplay       bool `mapstructure:"LATENCY_DISPLAY"`
+	LatencyWarnThreshold int  `mapstructure:"LATENCY_WARN_THRESHOLD"`
+	LatencyCheckInterval int  `mapstructure:"LATENCY_CHECK_INTERVAL"`
+
+	MetricsListenAddr string `mapstructure:"METRICS_LISTEN_ADDR"`
+	MetricsPath       string `mapstructure:"METRICS_PATH"`
+
+	ReplayMaxOutputSize     string `mapstructure:"REPLAY_MAX_OUTPUT_SIZE"`
+	ReplayOutputLimitPolicy string `mapstructure:"REPLAY_OUTPUT_LIMIT_POLICY"`
+	ReplayCompressLevel     int    `mapstructure:"REPLAY_COMPRESS_LEVEL"`
+
+	ReplayMaskPatterns []string `mapstructure:"REPLAY_MASK_PATTERNS"`
+
+	GRPCListenAddr string `mapstructure:"GRPC_LISTEN_ADDR"`
+	GRPCAuthToken  string `mapstructure:"GRPC_AUTH_TOKEN"`
+
+	RDPGatewayAddr string `mapstructure:"RDP_GATEWAY_ADDR"`
+
 	RootPath          string
 	DataFolderPath    string
 	LogDirPath        string
@@ -74,6 +233,8 @@ func (c *Config) EnsureConfigValid() {
 }
 
 func GetConf() Config {
+	confLock.RLock()
+	defer confLock.RUnlock()
 	if GlobalConfig == nil {
 		return getDefaultConfig()
 	}
@@ -87,6 +248,7 @@ func Setup(configPath string) {
 	loadConfigFromEnv(&conf)
 	loadConfigFromFile(configPath, &conf)
 	conf.EnsureConfigValid()
+	setConfigPath(configPath)
 	GlobalConfig = &conf
 	log.Printf("%+v\n", GlobalConfig)
 }
@@ -119,6 +281,7 @@ func getDefaultConfig() Config {
 		HTTPPort:          "5000",
 		AccessKeyFilePath: accessKeyFilePath,
 		LogLevel:          "INFO",
+		LogFormat:         "text",
 		RootPath:          rootPath,
 		DataFolderPath:    dataFolderPath,
 		LogDirPath:        LogDirPath,
@@ -133,10 +296,12 @@ func getDefaultConfig() Config {
 		ShowHiddenFile:      false,
 		ReuseConnection:     true,
 		AssetLoadPolicy:     "",
+		AssetMenuCacheSize:  10000,
+		AssetMenuCacheTTL:   60,
 		ZipMaxSize:          "1024M",
 		ZipTmpPath:          "/tmp",
 		ClientAliveInterval: 30,
-		RetryAliveCountMax:  3,
+		RetryAliveCountMax:  0,
 		ShareRoomType:       "local",
 		RedisHost:           "127.0.0.1",
 		RedisPort:           "6379",
@@ -144,6 +309,65 @@ func getDefaultConfig() Config {
 
 		EnableLocalPortForward: false,
 		EnableVscodeSupport:    false,
+
+		RecentSessionSize: 10,
+		ZmodemPolicy:      "allow",
+		MetricsPath:       "/metrics",
+
+		ReplayOutputLimitPolicy: "stop",
+		ReplayCompressLevel:     -1,
+
+		SSHAuthFailureLimit:  10,
+		SSHAuthFailureWindow: 600,
+		SSHAuthLockoutTime:   1800,
+		SSHAuthLimiterMaxIPs: 10000,
+		SSHAllowedSubsystems: []string{"sftp"},
+
+		ShutdownGracePeriod: 60,
+		PastePolicy:         "off",
+		ColorTheme:          "default",
+		InputRateLimit:      1024 * 1024,
+		EscapeSequence:      "~.",
+		ScrollbackKey:       "^]s",
+		ScrollbackLines:     1000,
+
+		PasswordExpireNoticeDays: 7,
+		ApprovalWaitTimeout:      600,
+
+		SessionTagMaxLength: 64,
+
+		SessionMFAPolicy:       "off",
+		SessionMFAMaxAttempts:  3,
+		SessionMFAFailureLimit: 5,
+		SessionMFALockoutTime:  900,
+
+		BannerLineEnding: "crlf",
+		BannerANSIPolicy: "color",
+		BannerMaxLength:  8192,
+
+		SessionScrollbackSize: 64 * 1024,
+
+		TitleSequencePolicy: "pass",
+
+		SessionMaxDurationGrace: 60,
+
+		AssetSessionQueueWait: 300,
+
+		LatencyWarnThreshold: 300,
+
+		CoreAPITimeout:      30,
+		CoreAPIRetry:        2,
+		CoreAPIRetryBackoff: 500,
+
+		BellPolicy: "pass",
+
+		SSHAuthMethods: []string{"publickey", "password", "keyboard-interactive"},
+
+		SSHAlgorithmProfile: "secure",
+
+		CommandFilterFileMode: "merge",
+		CommandFilterNotice:   true,
+		DBTLSVerify:           "asset",
 	}
 
 }
@@ -188,22 +412,28 @@ func loadConfigFromEnv(conf *Config) {
 }
 
 func loadConfigFromFile(path string, conf *Config) {
-	var err error
-	if have(path) {
-		fileViper := viper.New()
-		fileViper.SetConfigFile(path)
-		if err = fileViper.ReadInConfig(); err == nil {
-			if err = fileViper.Unmarshal(conf); err == nil {
-				log.Printf("Load config from %s success\n", path)
-				return
-			}
-		}
-	}
-	if err != nil {
+	if err := readConfigFile(path, conf); err != nil {
 		log.Fatalf("Load config from %s failed: %s\n", path, err)
 	}
 }
 
+// readConfigFile 配置文件不存在时不修改 conf
+func readConfigFile(path string, conf *Config) error {
+	if !have(path) {
+		return nil
+	}
+	fileViper := viper.New()
+	fileViper.SetConfigFile(path)
+	if err := fileViper.ReadInConfig(); err != nil {
+		return err
+	}
+	if err := fileViper.Unmarshal(conf); err != nil {
+		return err
+	}
+	log.Printf("Load config from %s success\n", path)
+	return nil
+}
+
 const (
 	prefixName = "[KoKo]-"
 
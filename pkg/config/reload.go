@@ -0,0 +1,192 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+/*
+hotReloadKeys 可以在运行中重新加载的配置，修改后对新的连接和会话生效，已建立的会话不受影响。
+监听地址、端口、主机密钥、core 地址、Redis、日志、gRPC 等在启动时初始化的配置需要重启后生效。
+*/
+
+var hotReloadKeys = map[string]bool{
+	"SSH_TIMEOUT":                 true,
+	"LANGUAGE_CODE":               true,
+	"ASSET_LOAD_POLICY":           true,
+	"ZIP_MAX_SIZE":                true,
+	"ZIP_TMP_PATH":                true,
+	"CLIENT_ALIVE_INTERVAL":       true,
+	"RETRY_ALIVE_COUNT_MAX":       true,
+	"SFTP_SHOW_HIDDEN_FILE":       true,
+	"REUSE_CONNECTION":            true,
+	"ENABLE_LOCAL_PORT_FORWARD":   true,
+	"ENABLE_VSCODE_SUPPORT":       true,
+	"HIDDEN_FIELDS":               true,
+	"BANNER_TEMPLATE_PATH":        true,
+	"BANNER_ANSI_POLICY":          true,
+	"BANNER_MAX_LENGTH":           true,
+	"RECENT_SESSION_SIZE":         true,
+	"SESSION_WEBHOOK_URL":         true,
+	"ZMODEM_POLICY":               true,
+	"COMMAND_FILTER_PREVIEW":      true,
+	"SHUTDOWN_GRACE_PERIOD":       true,
+	"DYNAMIC_HOST_ZONES":          true,
+	"FIRST_LOGIN_NOTICE_PATH":     true,
+	"USER_SESSION_LIMITS":         true,
+	"PASTE_POLICY":                true,
+	"REPLAY_MAX_OUTPUT_SIZE":      true,
+	"REPLAY_OUTPUT_LIMIT_POLICY":  true,
+	"WS_TERMINAL_SECRET":          true,
+	"WS_TERMINAL_ALLOWED_ORIGINS": true,
+	"COLOR_THEME":                 true,
+	"COLOR_THEME_ROLES":           true,
+	"REPLAY_FORCE_ASSET_RULES":    true,
+	"REPLAY_EXEMPT_ASSET_RULES":   true,
+	"SSH_CONNECT_HOST":            true,
+	"SSH_CONNECT_PROXY_JUMP":      true,
+	"DISABLED_MENU_ITEMS":         true,
+	"INPUT_RATE_LIMIT":            true,
+	"ESCAPE_SEQUENCE":             true,
+	"SCROLLBACK_KEY":              true,
+	"SCROLLBACK_LINES":            true,
+	"PASSWORD_EXPIRE_NOTICE_DAYS": true,
+	"PASSWORD_CHANGE_URL":         true,
+	"SESSION_NOTE_PROMPT":         true,
+	"SESSION_TAG_ENVS":            true,
+	"SESSION_TAG_MAX_LENGTH":      true,
+	"SESSION_MFA_POLICY":          true,
+	"SESSION_MFA_EXEMPT_USERS":    true,
+	"SESSION_MFA_MAX_ATTEMPTS":    true,
+	"BANNER_LINE_ENDING":          true,
+	"SESSION_DETACH_GRACE":        true,
+	"SESSION_SCROLLBACK_SIZE":     true,
+	"OUTBOUND_BIND_ADDRESSES":     true,
+	"CONFIRM_ASSET_RULES":         true,
+	"APPROVAL_ASSET_RULES":        true,
+	"APPROVAL_WAIT_TIMEOUT":       true,
+	"MENU_KEY_BINDINGS":           true,
+	"TITLE_SEQUENCE_POLICY":       true,
+	"CLIENT_AGENT_ASSET_RULES":    true,
+	"SESSION_MAX_DURATION":        true,
+	"SESSION_MAX_DURATION_GRACE":  true,
+	"ACCESS_DENIED_URL_TEMPLATE":  true,
+	"ACCESS_DENIED_SHOW_ASSET":    true,
+	"LATENCY_DISPLAY":             true,
+	"LATENCY_WARN_THRESHOLD":      true,
+	"LATENCY_CHECK_INTERVAL":      true,
+	"REPLAY_COMPRESS_LEVEL":       true,
+	"LANGUAGE_SWITCH_KEY":         true,
+	"SSH_ALLOWED_SUBSYSTEMS":      true,
+	"SSH_AUTH_BANNER":             true,
+	"SSH_AUTH_BANNER_FILE":        true,
+	"REPLAY_MASK_PATTERNS":        true,
+	"ASSET_SESSION_QUEUE":         true,
+	"ASSET_SESSION_QUEUE_WAIT":    true,
+	"SESSION_OBSERVE":             true,
+	"BELL_POLICY":                 true,
+	"BELL_AUDIT_THRESHOLD":        true,
+	"CONNECT_INFO":                true,
+	"COMMAND_FILTER_FILE":         true,
+	"COMMAND_FILTER_FILE_MODE":    true,
+	"KEYSTROKE_AUDIT_ASSET_RULES": true,
+	"LOGIN_USER_TEMPLATE":         true,
+	"COMMAND_FILTER_NOTICE":       true,
+	"ASSET_MENU_CACHE_SIZE":       true,
+	"ASSET_MENU_CACHE_TTL":        true,
+	"DB_TLS_VERIFY":               true,
+	"DB_TLS_CA_BUNDLE":            true,
+}
+
+var (
+	configPath string
+
+	confLock sync.RWMutex
+
+	reloadLock  sync.Mutex
+	reloadHooks []func(conf Config)
+)
+
+func setConfigPath(path string) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	configPath = path
+}
+
+// RegisterReloadHook 注册配置重新加载后的回调，如重新加载语言包
+func RegisterReloadHook(hook func(conf Config)) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	reloadHooks = append(reloadHooks, hook)
+}
+
+type ReloadResult struct {
+	// 已经生效的配置
+	Reloaded []string
+	// 有修改但需要重启才能生效的配置
+	RequireRestart []string
+}
+
+func (r ReloadResult) String() string {
+	reloaded, restart := "none", "none"
+	if len(r.Reloaded) > 0 {
+		reloaded = strings.Join(r.Reloaded, ",")
+	}
+	if len(r.RequireRestart) > 0 {
+		restart = strings.Join(r.RequireRestart, ",")
+	}
+	return fmt.Sprintf("reloaded: %s; require restart: %s", reloaded, restart)
+}
+
+/*
+Reload 重新读取启动时的配置文件和环境变量，只应用可以热加载的配置。
+配置文件解析失败时返回错误，不会应用任何修改。
+*/
+
+func Reload() (ReloadResult, error) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	current := GetConf()
+	conf := getDefaultConfig()
+	// 未配置 NAME 时使用启动时生成的名称
+	conf.Name = current.Name
+	loadConfigFromEnv(&conf)
+	if err := readConfigFile(configPath, &conf); err != nil {
+		return ReloadResult{}, err
+	}
+	conf.EnsureConfigValid()
+	result := mergeHotReload(&current, &conf)
+	confLock.Lock()
+	GlobalConfig = &current
+	confLock.Unlock()
+	for _, hook := range reloadHooks {
+		hook(current)
+	}
+	return result, nil
+}
+
+// mergeHotReload 将 src 中修改了的可热加载配置复制到 dst
+func mergeHotReload(dst, src *Config) ReloadResult {
+	var result ReloadResult
+	dstValue := reflect.ValueOf(dst).Elem()
+	srcValue := reflect.ValueOf(src).Elem()
+	confType := dstValue.Type()
+	for i := 0; i < confType.NumField(); i++ {
+		key := confType.Field(i).Tag.Get("mapstructure")
+		if key == "" {
+			continue
+		}
+		if reflect.DeepEqual(dstValue.Field(i).Interface(), srcValue.Field(i).Interface()) {
+			continue
+		}
+		if !hotReloadKeys[key] {
+			result.RequireRestart = append(result.RequireRestart, key)
+			continue
+		}
+		dstValue.Field(i).Set(srcValue.Field(i))
+		result.Reloaded = append(result.Reloaded, key)
+	}
+	return result
+}
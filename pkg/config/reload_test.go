@@ -0,0 +1,50 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte("NAME: koko-test\nSSHD_PORT: 2222\nPASTE_POLICY: off\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	prevConf := GlobalConfig
+	Setup(path)
+	t.Cleanup(func() { GlobalConfig = prevConf })
+
+	var hookConf Config
+	RegisterReloadHook(func(conf Config) { hookConf = conf })
+	if err := os.WriteFile(path, []byte("NAME: koko-test\nSSHD_PORT: 2223\nPASTE_POLICY: confirm\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Reloaded, []string{"PASTE_POLICY"}) ||
+		!reflect.DeepEqual(result.RequireRestart, []string{"SSHD_PORT"}) {
+		t.Errorf("Reload() = %s, want PASTE_POLICY reloaded and SSHD_PORT require restart", result)
+	}
+	conf := GetConf()
+	if conf.PastePolicy != "confirm" || conf.SSHPort != "2222" || conf.Name != "koko-test" {
+		t.Errorf("config after reload = %s %s %s, want confirm 2222 koko-test", conf.PastePolicy, conf.SSHPort, conf.Name)
+	}
+	if hookConf.PastePolicy != "confirm" {
+		t.Error("reload hook not called with the new config")
+	}
+
+	// 解析失败时不应用任何修改
+	if err = os.WriteFile(path, []byte("PASTE_POLICY: warn\nSSH_TIMEOUT: [\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Reload(); err == nil {
+		t.Fatal("Reload() invalid config error = nil")
+	}
+	if GetConf().PastePolicy != "confirm" {
+		t.Error("invalid config partially applied")
+	}
+}
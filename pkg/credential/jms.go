@@ -0,0 +1,51 @@
+package credential
+
+import (
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/service"
+)
+
+// JMSResolver 默认的 Resolver，使用 core 中保存的账号凭证
+type JMSResolver struct {
+	jmsService *service.JMService
+}
+
+func NewJMSResolver(jmsService *service.JMService) *JMSResolver {
+	return &JMSResolver{jmsService: jmsService}
+}
+
+func (r *JMSResolver) Name() string {
+	return "jumpserver"
+}
+
+func (r *JMSResolver) Resolve(req *Request) (*Credential, error) {
+	account := req.Account
+	if account.Secret != "" {
+		// 连接令牌中已经包含了 core 返回的凭证
+		return &Credential{
+			SecretType:  account.SecretType.Value,
+			Secret:      account.Secret,
+			Certificate: account.Certificate,
+		}, nil
+	}
+	// 手动输入、同名账号等没有保存凭证的账号，连接时由用户输入
+	switch account.Name {
+	case model.InputUser, model.DynamicUser:
+		return nil, ErrNotResolved
+	}
+	if account.ID == "" || account.IsAnonymous() {
+		return nil, ErrNotResolved
+	}
+	detail, err := r.jmsService.GetAccountSecretById(account.ID)
+	if err != nil {
+		return nil, err
+	}
+	if detail.Secret == "" {
+		return nil, ErrNotResolved
+	}
+	return &Credential{
+		SecretType:  detail.SecretType.Value,
+		Secret:      detail.Secret,
+		Certificate: account.Certificate,
+	}, nil
+}
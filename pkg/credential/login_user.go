@@ -0,0 +1,112 @@
+package credential
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+/*
+LOGIN_USER_TEMPLATE 账号的用户名为 @TEMPLATE 时，使用模板(text/template)根据连接的用户生成登录资产的用户名，
+如 "svc-{{ .Username | lower }}"。模板中可以使用用户的 ID、Name、Username、Email、Role 和连接的 OrgID、OrgName，
+值为空的属性按不存在处理，模板引用不存在的属性、渲染结果为空或者包含空白和 @ 时连接失败。
+会话中记录的账号是渲染后的用户名，账号的凭证仍然使用 @TEMPLATE 账号保存的凭证。
+*/
+
+const TemplateUser = "@TEMPLATE"
+
+var ErrLoginUserTemplate = errors.New("login user template")
+
+var loginUserFuncs = template.FuncMap{
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"replace":    strings.ReplaceAll,
+	"trimSuffix": strings.TrimSuffix,
+	"trimPrefix": strings.TrimPrefix,
+	// 如 {{ .Email | before "@" }} 返回邮箱的用户名部分
+	"before": func(sep, s string) string {
+		before, _, _ := strings.Cut(s, sep)
+		return before
+	},
+}
+
+func parseLoginUserTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("LOGIN_USER_TEMPLATE").Funcs(loginUserFuncs).
+		Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid LOGIN_USER_TEMPLATE %q: %s", ErrLoginUserTemplate, text, err)
+	}
+	return tmpl, nil
+}
+
+// ValidateLoginUserTemplate 检查 LOGIN_USER_TEMPLATE 的语法，为空时不检查
+func ValidateLoginUserTemplate(text string) error {
+	if text == "" {
+		return nil
+	}
+	_, err := parseLoginUserTemplate(text)
+	return err
+}
+
+func loginUserTemplateData(token *model.ConnectToken) map[string]string {
+	data := make(map[string]string)
+	for key, value := range map[string]string{
+		"ID":       token.User.ID,
+		"Name":     token.User.Name,
+		"Username": token.User.Username,
+		"Email":    token.User.Email,
+		"Role":     token.User.Role,
+		"OrgID":    token.OrgId,
+		"OrgName":  token.OrgName,
+	} {
+		if value != "" {
+			data[key] = value
+		}
+	}
+	return data
+}
+
+func renderLoginUser(text string, token *model.ConnectToken) (string, error) {
+	if text == "" {
+		return "", fmt.Errorf("%w: account username is %s but LOGIN_USER_TEMPLATE is not configured",
+			ErrLoginUserTemplate, TemplateUser)
+	}
+	tmpl, err := parseLoginUserTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	if err = tmpl.Execute(&buf, loginUserTemplateData(token)); err != nil {
+		return "", fmt.Errorf("%w: render for user %s failed: %s", ErrLoginUserTemplate, token.User.String(), err)
+	}
+	username := buf.String()
+	if username == "" || strings.ContainsAny(username, " \t\r\n@") {
+		return "", fmt.Errorf("%w: invalid login user %q rendered for user %s",
+			ErrLoginUserTemplate, username, token.User.String())
+	}
+	return username, nil
+}
+
+// ResolveLoginUser 账号(或者切换用户的账号)的用户名为 @TEMPLATE 时替换为模板渲染的用户名
+func ResolveLoginUser(token *model.ConnectToken) error {
+	text := config.GetConf().LoginUserTemplate
+	accounts := []*model.BaseAccount{&token.Account.BaseAccount}
+	if token.Account.SuFrom != nil {
+		accounts = append(accounts, token.Account.SuFrom)
+	}
+	for _, account := range accounts {
+		if account.Username != TemplateUser {
+			continue
+		}
+		username, err := renderLoginUser(text, token)
+		if err != nil {
+			return err
+		}
+		account.Username = username
+	}
+	return nil
+}
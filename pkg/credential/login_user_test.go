@@ -0,0 +1,60 @@
+package credential
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestResolveLoginUser(t *testing.T) {
+	newToken := func() *model.ConnectToken {
+		return &model.ConnectToken{
+			User:    model.User{ID: "u1", Name: "Alice", Username: "Alice", Email: "alice@example.com"},
+			Account: model.Account{BaseAccount: model.BaseAccount{Name: "svc", Username: TemplateUser}},
+			OrgName: "Default",
+		}
+	}
+	conf := config.GetConf()
+	prevConf := config.GlobalConfig
+	config.GlobalConfig = &conf
+	t.Cleanup(func() { config.GlobalConfig = prevConf })
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{`svc-{{ .Username | lower }}`, "svc-alice"},
+		{`{{ .Email | before "@" }}-{{ .OrgName | lower }}`, "alice-default"},
+	}
+	for _, tt := range tests {
+		conf.LoginUserTemplate = tt.template
+		token := newToken()
+		if err := ResolveLoginUser(token); err != nil {
+			t.Fatalf("ResolveLoginUser(%s) failed: %s", tt.template, err)
+		}
+		if token.Account.Username != tt.want || token.Account.String() != "svc("+tt.want+")" {
+			t.Errorf("ResolveLoginUser(%s) = %s, want %s", tt.template, token.Account.Username, tt.want)
+		}
+	}
+
+	// 模板错误、属性不存在或者为空、渲染结果不合法时连接失败
+	for _, text := range []string{"", `svc-{{ .Username`, `svc-{{ .Team }}`, `svc-{{ .Role }}`, `{{ .Name }} x`} {
+		conf.LoginUserTemplate = text
+		if err := ResolveLoginUser(newToken()); !errors.Is(err, ErrLoginUserTemplate) {
+			t.Errorf("ResolveLoginUser(%q) = %v, want login user template error", text, err)
+		}
+	}
+
+	// 普通账号不使用模板
+	conf.LoginUserTemplate = `svc-{{ .Team }}`
+	token := newToken()
+	token.Account.Username = "root"
+	if err := ResolveLoginUser(token); err != nil || token.Account.Username != "root" {
+		t.Errorf("ResolveLoginUser(root) = %s, %v", token.Account.Username, err)
+	}
+	if err := ValidateLoginUserTemplate(`svc-{{ .Username`); err == nil {
+		t.Error("ValidateLoginUserTemplate() want error for invalid template")
+	}
+}
@@ -0,0 +1,102 @@
+package credential
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+/*
+CredentialResolver 获取连接资产使用的账号凭证。
+连接资产前按注册顺序依次调用，返回 ErrNotResolved 表示不处理该账号，交给下一个 Resolver；
+默认的 JMSResolver 最后调用，使用 core 中保存的凭证。
+新增凭证后端(如 Vault)只需实现该接口并调用 Register 注册，不需要修改连接逻辑。
+*/
+
+type CredentialResolver interface {
+	Name() string
+	Resolve(req *Request) (*Credential, error)
+}
+
+type Request struct {
+	User     *model.User
+	Asset    *model.Asset
+	Account  *model.Account
+	Protocol string
+}
+
+// Credential 账号的认证信息，SecretType 为 password 或者 ssh_key
+type Credential struct {
+	SecretType  string
+	Secret      string
+	Certificate string
+}
+
+var ErrNotResolved = errors.New("credential not resolved")
+
+var (
+	mu               sync.RWMutex
+	resolvers        []CredentialResolver
+	fallbackResolver CredentialResolver
+)
+
+// Register 注册自定义的 Resolver，优先于默认的 Resolver 调用
+func Register(r CredentialResolver) {
+	mu.Lock()
+	defer mu.Unlock()
+	resolvers = append(resolvers, r)
+}
+
+// SetDefault 设置默认的 Resolver，所有注册的 Resolver 都不处理时使用
+func SetDefault(r CredentialResolver) {
+	mu.Lock()
+	defer mu.Unlock()
+	fallbackResolver = r
+}
+
+func Resolve(req *Request) (*Credential, error) {
+	mu.RLock()
+	chain := make([]CredentialResolver, 0, len(resolvers)+1)
+	chain = append(chain, resolvers...)
+	if fallbackResolver != nil {
+		chain = append(chain, fallbackResolver)
+	}
+	mu.RUnlock()
+	for i := range chain {
+		cred, err := chain[i].Resolve(req)
+		if errors.Is(err, ErrNotResolved) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("resolver %s: %w", chain[i].Name(), err)
+		}
+		return cred, nil
+	}
+	return nil, ErrNotResolved
+}
+
+// ResolveConnectToken 使用 Resolver 获取的凭证替换连接令牌中的账号凭证，
+// 没有 Resolver 处理时保留令牌中原有的凭证
+func ResolveConnectToken(token *model.ConnectToken) error {
+	req := Request{
+		User:     &token.User,
+		Asset:    &token.Asset,
+		Account:  &token.Account,
+		Protocol: token.Protocol,
+	}
+	cred, err := Resolve(&req)
+	if errors.Is(err, ErrNotResolved) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	token.Account.Secret = cred.Secret
+	token.Account.Certificate = cred.Certificate
+	if cred.SecretType != "" && cred.SecretType != token.Account.SecretType.Value {
+		token.Account.SecretType = model.LabelValue{Value: cred.SecretType, Label: cred.SecretType}
+	}
+	return nil
+}
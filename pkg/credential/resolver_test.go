@@ -0,0 +1,97 @@
+package credential
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+// fakeVaultResolver 模拟保存在外部系统中的凭证，只处理指定资产的账号
+type fakeVaultResolver struct {
+	assetID string
+	err     error
+	calls   int
+}
+
+func (r *fakeVaultResolver) Name() string {
+	return "fake-vault"
+}
+
+func (r *fakeVaultResolver) Resolve(req *Request) (*Credential, error) {
+	r.calls++
+	if req.Asset.ID != r.assetID {
+		return nil, ErrNotResolved
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &Credential{SecretType: "ssh_key", Secret: "vault-key-for-" + req.User.Username}, nil
+}
+
+func setResolvers(t *testing.T, fallback CredentialResolver, rs ...CredentialResolver) {
+	mu.Lock()
+	resolvers, fallbackResolver = rs, fallback
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		resolvers, fallbackResolver = nil, nil
+		mu.Unlock()
+	})
+}
+
+func newTestToken(assetID string) *model.ConnectToken {
+	token := &model.ConnectToken{
+		User:  model.User{Username: "alice"},
+		Asset: model.Asset{ID: assetID},
+	}
+	token.Account.Username = "root"
+	token.Account.Secret = "core-password"
+	token.Account.SecretType = model.LabelValue{Label: "Password", Value: "password"}
+	return token
+}
+
+func TestResolveConnectToken(t *testing.T) {
+	vault := &fakeVaultResolver{assetID: "asset-vault"}
+	setResolvers(t, NewJMSResolver(nil), vault)
+
+	token := newTestToken("asset-vault")
+	if err := ResolveConnectToken(token); err != nil {
+		t.Fatalf("ResolveConnectToken() error = %s", err)
+	}
+	if token.Account.Secret != "vault-key-for-alice" || !token.Account.IsSSHKey() {
+		t.Errorf("account = %+v, want credential from fake vault", token.Account)
+	}
+
+	// 其他资产使用默认的 Resolver，保留 core 返回的凭证
+	token = newTestToken("asset-core")
+	if err := ResolveConnectToken(token); err != nil {
+		t.Fatalf("ResolveConnectToken() error = %s", err)
+	}
+	if token.Account.Secret != "core-password" || token.Account.SecretType.Label != "Password" {
+		t.Errorf("account = %+v, want credential from core", token.Account)
+	}
+	if vault.calls != 2 {
+		t.Errorf("fake vault resolver calls = %d, want 2", vault.calls)
+	}
+}
+
+func TestResolveConnectTokenError(t *testing.T) {
+	vaultErr := errors.New("vault sealed")
+	setResolvers(t, NewJMSResolver(nil), &fakeVaultResolver{assetID: "asset-vault", err: vaultErr})
+	token := newTestToken("asset-vault")
+	if err := ResolveConnectToken(token); !errors.Is(err, vaultErr) {
+		t.Fatalf("ResolveConnectToken() error = %v, want %v", err, vaultErr)
+	}
+	if token.Account.Secret != "core-password" {
+		t.Errorf("account secret = %q, want unchanged on error", token.Account.Secret)
+	}
+
+	// 没有注册任何 Resolver 时不修改令牌
+	setResolvers(t, nil)
+	token = newTestToken("asset-vault")
+	token.Account.Secret = ""
+	if err := ResolveConnectToken(token); err != nil || token.Account.Secret != "" {
+		t.Errorf("ResolveConnectToken() = %v, secret %q, want no change", err, token.Account.Secret)
+	}
+}
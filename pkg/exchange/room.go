@@ -139,12 +139,17 @@ func (r *Room) run() {
 }
 
 func (r *Room) Subscribe(conn *Conn) {
-	r.subscriber <- conn
-
+	select {
+	case <-r.done:
+	case r.subscriber <- conn:
+	}
 }
 
 func (r *Room) UnSubscribe(conn *Conn) {
-	r.unSubscriber <- conn
+	select {
+	case <-r.done:
+	case r.unSubscriber <- conn:
+	}
 }
 
 func (r *Room) Broadcast(msg *RoomMessage) {
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/common"
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+/*
+ACCESS_DENIED_URL_TEMPLATE 搜索不到授权资产时提示用户申请权限的地址。
+默认只显示通用的提示，不查询用户没有权限的资产；ACCESS_DENIED_SHOW_ASSET 为 true 时在所有资产中查找
+名称或地址与关键字完全相同的资产，存在时提示用户没有该资产的权限，申请地址中可以使用资产的 ID 和名称。
+*/
+
+// findUnauthorizedAsset 查找名称或地址与 key 完全相同的资产，查询失败时当作不存在
+func (u *UserSelectHandler) findUnauthorizedAsset(key string) (model.Asset, bool) {
+	assets, err := u.h.jmsService.SearchAssets(key)
+	if err != nil {
+		logger.Errorf("User %s search all assets %s failed: %s", u.user.Name, key, err)
+		return model.Asset{}, false
+	}
+	for i := range assets {
+		if strings.EqualFold(assets[i].Name, key) || common.HostEqual(assets[i].Address, key) {
+			return assets[i], true
+		}
+	}
+	return model.Asset{}, false
+}
+
+// accessRequestURL 替换申请地址中的变量，asset 为 nil 时资产相关的变量替换为空
+func accessRequestURL(tpl, keyword, username string, asset *model.Asset) string {
+	var assetID, assetName string
+	if asset != nil {
+		assetID, assetName = asset.ID, asset.Name
+	}
+	return strings.NewReplacer(
+		"{keyword}", url.QueryEscape(keyword),
+		"{user}", url.QueryEscape(username),
+		"{asset_id}", url.QueryEscape(assetID),
+		"{asset}", url.QueryEscape(assetName),
+	).Replace(tpl)
+}
+
+// displayAccessDenied 搜索不到授权资产时提示申请权限的地址
+func (u *UserSelectHandler) displayAccessDenied(key string) {
+	conf := config.GetConf()
+	key = strings.TrimSpace(key)
+	if conf.AccessDeniedURLTemplate == "" || key == "" {
+		return
+	}
+	switch u.currentType {
+	case TypeAsset, TypeHost, TypeWindows, TypeDatabase, TypeK8s, TypeAll:
+	default:
+		return
+	}
+	lang := i18n.NewLang(u.h.i18nLang)
+	var (
+		asset model.Asset
+		found bool
+	)
+	if conf.AccessDeniedShowAsset && !strings.ContainsAny(key, " \t") {
+		asset, found = u.findUnauthorizedAsset(key)
+	}
+	var msg string
+	if found {
+		logger.Infof("User %s has no permission to asset %s", u.user.Name, asset.String())
+		requestURL := accessRequestURL(conf.AccessDeniedURLTemplate, key, u.user.Username, &asset)
+		msg = fmt.Sprintf(lang.T("You don't have permission to access asset %s, request access here: %s"),
+			asset.Name, requestURL)
+	} else {
+		requestURL := accessRequestURL(conf.AccessDeniedURLTemplate, key, u.user.Username, nil)
+		msg = fmt.Sprintf(lang.T("No authorized asset matches %s, if you need access, request it here: %s"),
+			key, requestURL)
+	}
+	utils.IgnoreErrWriteString(u.h.term, utils.WrapperWarn(msg))
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestAccessRequestURL(t *testing.T) {
+	tpl := "https://jms.example.com/apply?search={keyword}&user={user}&asset={asset}&id={asset_id}"
+	got := accessRequestURL(tpl, "db 01", "alice", nil)
+	want := "https://jms.example.com/apply?search=db+01&user=alice&asset=&id="
+	if got != want {
+		t.Errorf("accessRequestURL() without asset = %q, want %q", got, want)
+	}
+	asset := model.Asset{ID: "a1", Name: "db&01"}
+	got = accessRequestURL(tpl, "db&01", "alice", &asset)
+	want = "https://jms.example.com/apply?search=db%2601&user=alice&asset=db%2601&id=a1"
+	if got != want {
+		t.Errorf("accessRequestURL() with asset = %q, want %q", got, want)
+	}
+}
+
+func TestFindUnauthorizedAsset(t *testing.T) {
+	jms := newTestJMService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		assets := []model.Asset{
+			{ID: "a1", Name: "payments-db-01", Address: "10.0.0.11"},
+			{ID: "a2", Name: "payments-db", Address: "10.0.0.1"},
+		}
+		_ = json.NewEncoder(w).Encode(assets)
+	}))
+	u := &UserSelectHandler{
+		user: &model.User{Name: "alice"},
+		h:    &InteractiveHandler{jmsService: jms},
+	}
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"Payments-DB", "a2"},
+		{"10.0.0.11", "a1"},
+		{"payments", ""},
+		{"10.0.0", ""},
+	}
+	for _, tt := range tests {
+		asset, ok := u.findUnauthorizedAsset(tt.key)
+		if ok != (tt.want != "") || asset.ID != tt.want {
+			t.Errorf("findUnauthorizedAsset(%q) = %q, %v, want %q", tt.key, asset.ID, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+// lastAccount 返回本次连接中资产最后选择的账号别名
+func (u *UserSelectHandler) lastAccount(asset *model.Asset) string {
+	if asset == nil || asset.ID == "" {
+		return ""
+	}
+	return u.lastAccounts[asset.ID]
+}
+
+func (u *UserSelectHandler) setLastAccount(asset *model.Asset, account *model.PermAccount) {
+	if asset == nil || asset.ID == "" {
+		return
+	}
+	if u.lastAccounts == nil {
+		u.lastAccounts = make(map[string]string)
+	}
+	u.lastAccounts[asset.ID] = account.Alias
+}
+
+// rememberAccount 记录用户在多个账号中选择的账号，返回该账号
+func (h *InteractiveHandler) rememberAccount(asset *model.Asset, account model.PermAccount) model.PermAccount {
+	logger.Infof("User %s select account %s of asset %s", h.user.Name, account.String(), asset.String())
+	if h.selectHandler != nil {
+		h.selectHandler.setLastAccount(asset, &account)
+	}
+	return account
+}
+
+func lastAccountIndex(accounts []model.PermAccount, alias string) int {
+	if alias == "" {
+		return -1
+	}
+	for i := range accounts {
+		if accounts[i].Alias == alias {
+			return i
+		}
+	}
+	return -1
+}
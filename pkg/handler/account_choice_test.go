@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"golang.org/x/term"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestChooseAccountRememberLast(t *testing.T) {
+	accounts := []model.PermAccount{
+		{Alias: "root", Name: "root", Username: "root"},
+		{Alias: "app", Name: "app", Username: "app"},
+		{Alias: "readonly", Name: "readonly", Username: "readonly"},
+	}
+	user := &model.User{ID: "user-1", Name: "alice", Username: "alice"}
+	h := &InteractiveHandler{sess: &WrapperSession{Sess: &bannerTestSession{}}, user: user, i18nLang: "en"}
+	h.selectHandler = &UserSelectHandler{user: user, h: h}
+	asset := &model.Asset{ID: "a1", Name: "web-01", Address: "10.0.0.1"}
+	choose := func(input string, asset *model.Asset, accounts []model.PermAccount) (model.PermAccount, bool, string) {
+		var output strings.Builder
+		h.term = term.NewTerminal(struct {
+			io.Reader
+			io.Writer
+		}{strings.NewReader(input), &output}, "Opt> ")
+		account, ok := h.chooseAccount(asset, accounts)
+		return account, ok, output.String()
+	}
+
+	// 只有一个账号时直接使用，不记录
+	if account, ok, out := choose("", asset, accounts[:1]); !ok || account.Alias != "root" || out != "" {
+		t.Fatalf("chooseAccount() with single account = %v %v, output %q", account.Alias, ok, out)
+	}
+	// 第一次选择时回车不选择账号，账号按名称排序，2 是 readonly
+	account, ok, out := choose("\r2\r", asset, accounts)
+	if !ok || account.Alias != "readonly" {
+		t.Fatalf("chooseAccount() = %v %v, want readonly", account.Alias, ok)
+	}
+	if strings.Contains(out, "last selected account") {
+		t.Errorf("first choice output %q should not show the last account", out)
+	}
+	// 再次连接同一资产时回车使用上次的账号
+	account, ok, out = choose("\r", asset, accounts)
+	if !ok || account.Alias != "readonly" {
+		t.Fatalf("chooseAccount() again = %v %v, want the last account readonly", account.Alias, ok)
+	}
+	if !strings.Contains(out, "Press Enter to use the last selected account readonly(readonly)") {
+		t.Errorf("output %q missing the last account tip", out)
+	}
+	// 其他资产和已经不再授权的账号不使用记录
+	if _, ok, _ = choose("\r\r\r", &model.Asset{ID: "a2"}, accounts); ok {
+		t.Error("chooseAccount() for another asset should not use the last account")
+	}
+	permitted := []model.PermAccount{{Alias: "root", Name: "root"}, {Alias: "app", Name: "app"}}
+	if _, ok, _ = choose("\r\r\r", asset, permitted); ok {
+		t.Error("chooseAccount() should not use the last account that is no longer permitted")
+	}
+}
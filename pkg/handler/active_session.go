@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/common"
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/session"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+// displayActiveSessions 管理员查看当前 koko 节点上的活跃会话
+func (h *InteractiveHandler) displayActiveSessions() {
+	lang := i18n.NewLang(h.i18nLang)
+	h.activeSessions = session.GetAliveSessionList()
+	if len(h.activeSessions) == 0 {
+		utils.IgnoreErrWriteString(h.term, utils.WrapperString(lang.T("No active sessions"), utils.Red))
+		utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+		return
+	}
+	idLabel := lang.T("ID")
+	userLabel := lang.T("User")
+	assetLabel := lang.T("Asset")
+	durationLabel := lang.T("Duration")
+	idFieldSize := len(idLabel)
+	userFieldSize := len(userLabel)
+	assetFieldSize := len(assetLabel)
+	durationFieldSize := len(durationLabel)
+	now := time.Now()
+	data := make([]map[string]string, 0, len(h.activeSessions))
+	for i := range h.activeSessions {
+		item := h.activeSessions[i]
+		idNumber := strconv.Itoa(i + 1)
+		duration := now.Sub(item.DateStart.Time).Truncate(time.Second).String()
+		row := map[string]string{
+			"ID":       idNumber,
+			"User":     item.User,
+			"Asset":    item.Asset,
+			"Duration": duration,
+		}
+		data = append(data, row)
+		if idFieldSize < len(idNumber) {
+			idFieldSize = len(idNumber)
+		}
+		if userFieldSize < len(item.User) {
+			userFieldSize = len(item.User)
+		}
+		if assetFieldSize < len(item.Asset) {
+			assetFieldSize = len(item.Asset)
+		}
+		if durationFieldSize < len(duration) {
+			durationFieldSize = len(duration)
+		}
+	}
+	if userFieldSize > maxFieldSize {
+		userFieldSize = maxFieldSize
+	}
+	if assetFieldSize > maxFieldSize {
+		assetFieldSize = maxFieldSize
+	}
+	w, _ := h.GetPtySize()
+	caption := fmt.Sprintf(lang.T("Total Count: %d"), len(h.activeSessions))
+	table := common.WrapperTable{
+		Fields: []string{"ID", "User", "Asset", "Duration"},
+		Labels: []string{idLabel, userLabel, assetLabel, durationLabel},
+		FieldsSize: map[string][3]int{
+			"ID":       {idFieldSize, 0, 0},
+			"User":     {userFieldSize, 0, 0},
+			"Asset":    {assetFieldSize, 0, 0},
+			"Duration": {durationFieldSize, 0, 0},
+		},
+		Data:        data,
+		TotalSize:   w,
+		Caption:     utils.WrapperString(caption, utils.Green),
+		TruncPolicy: common.TruncMiddle,
+	}
+	table.Initial()
+	killTip := lang.T("Enter a+ID to terminate the session, such as: a1")
+	_, _ = h.term.Write([]byte(utils.CharClear))
+	_, _ = h.term.Write([]byte(table.Display()))
+	utils.IgnoreErrWriteString(h.term, utils.WrapperString(killTip, utils.Green))
+	utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+	if sessionObserveEnabled(h) {
+		observeTip := lang.T("Enter o+ID to observe the session read-only, such as: o1")
+		utils.IgnoreErrWriteString(h.term, utils.WrapperString(observeTip, utils.Green))
+		utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+	}
+}
+
+// killActiveSession 终止上次列出的第 index 个会话，并记录会话生命周期日志
+func (h *InteractiveHandler) killActiveSession(index int) bool {
+	if index <= 0 || index > len(h.activeSessions) {
+		return false
+	}
+	lang := i18n.NewLang(h.i18nLang)
+	sid := h.activeSessions[index-1].ID
+	sess, ok := session.GetSessionById(sid)
+	if !ok {
+		msg := fmt.Sprintf(lang.T("Session %s has already ended"), sid)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(msg))
+		return true
+	}
+	task := model.TerminalTask{
+		Name:   model.TaskKillSession,
+		Kwargs: model.TaskKwargs{TerminatedBy: h.user.Username},
+	}
+	if err := sess.HandleTask(&task); err != nil {
+		logger.Errorf("User %s terminate session %s failed: %s", h.user.Name, sid, err)
+		msg := fmt.Sprintf(lang.T("Terminate session %s failed"), sid)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(msg))
+		return true
+	}
+	logger.Infof("User %s terminate session %s from menu", h.user.Name, sid)
+	logObj := model.SessionLifecycleLog{
+		Reason: "Terminated by admin from koko menu",
+		User:   h.user.String(),
+	}
+	if err := h.jmsService.RecordSessionLifecycleLog(sid, model.AdminTerminate, logObj); err != nil {
+		logger.Errorf("Record session %s lifecycle log failed: %s", sid, err)
+	}
+	msg := fmt.Sprintf(lang.T("Session %s terminated"), sid)
+	utils.IgnoreErrWriteString(h.term, utils.WrapperString(msg, utils.Green))
+	utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+	return true
+}
+
+// broadcastMessage 向当前节点所有的交互会话广播消息
+func (h *InteractiveHandler) broadcastMessage(text string) {
+	lang := i18n.NewLang(h.i18nLang)
+	task := model.TerminalTask{
+		Name:   model.TaskBroadcastMessage,
+		Args:   text,
+		Kwargs: model.TaskKwargs{CreatedByUser: h.user.Username},
+	}
+	var (
+		count int32
+		wg    sync.WaitGroup
+	)
+	sessions := session.GetAliveSessionList()
+	// 每个会话最多等待 1 秒，同时发送避免会话多时菜单长时间没有响应
+	for i := range sessions {
+		wg.Add(1)
+		go func(sess *session.Session) {
+			defer wg.Done()
+			// sftp、端口转发等非交互会话不支持广播
+			if err := sess.HandleTask(&task); err != nil {
+				logger.Debugf("Session %s skip broadcast message: %s", sess.ID, err)
+				return
+			}
+			atomic.AddInt32(&count, 1)
+		}(sessions[i])
+	}
+	wg.Wait()
+	logger.Infof("User %s broadcast message to %d sessions", h.user.Name, count)
+	msg := fmt.Sprintf(lang.T("Message broadcast to %d sessions"), count)
+	utils.IgnoreErrWriteString(h.term, utils.WrapperString(msg, utils.Green))
+	utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+}
+
+// reloadConfig 重新加载本节点可以热加载的配置，已建立的会话不受影响
+func (h *InteractiveHandler) reloadConfig() {
+	lang := i18n.NewLang(h.i18nLang)
+	result, err := config.Reload()
+	if err != nil {
+		logger.Errorf("User %s reload config failed, keep current config: %s", h.user.Name, err)
+		msg := fmt.Sprintf(lang.T("Reload config failed, keep current config: %s"), err)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(msg))
+		return
+	}
+	logger.Infof("User %s reload config success, %s", h.user.Name, result)
+	if len(result.Reloaded) == 0 && len(result.RequireRestart) == 0 {
+		utils.IgnoreErrWriteString(h.term, utils.WrapperString(lang.T("No config changed"), utils.Green))
+		utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+		return
+	}
+	if len(result.Reloaded) > 0 {
+		msg := fmt.Sprintf(lang.T("Reloaded config: %s"), strings.Join(result.Reloaded, ", "))
+		utils.IgnoreErrWriteString(h.term, utils.WrapperString(msg, utils.Green))
+		utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+	}
+	if len(result.RequireRestart) > 0 {
+		msg := fmt.Sprintf(lang.T("Config changes that require restart: %s"), strings.Join(result.RequireRestart, ", "))
+		utils.IgnoreErrWriteString(h.term, utils.WrapperString(msg, utils.Red))
+		utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+	}
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/common"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/session"
+)
+
+func TestBroadcastMessageConcurrent(t *testing.T) {
+	var sessions []*session.Session
+	for i := 0; i < 5; i++ {
+		sid := "broadcast-" + strconv.Itoa(i)
+		handle := func(task *model.TerminalTask) error {
+			// 模拟还没有开始转发的会话，等待超时
+			time.Sleep(300 * time.Millisecond)
+			return nil
+		}
+		if i == 0 {
+			handle = func(task *model.TerminalTask) error { return errors.New("sftp session") }
+		}
+		sess := session.NewSession(&model.Session{ID: sid, DateStart: common.NewNowUTCTime()}, handle)
+		session.AddSession(sess)
+		sessions = append(sessions, sess)
+	}
+	defer func() {
+		for i := range sessions {
+			session.RemoveSession(sessions[i])
+		}
+	}()
+	var output strings.Builder
+	rw := struct {
+		io.Reader
+		io.Writer
+	}{strings.NewReader(""), &output}
+	h := &InteractiveHandler{user: &model.User{Username: "admin"}, term: term.NewTerminal(rw, "Opt> "), i18nLang: "en"}
+	start := time.Now()
+	h.broadcastMessage("maintenance at 22:00")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("broadcastMessage() took %s, want sessions notified concurrently", elapsed)
+	}
+	if !strings.Contains(output.String(), "4 sessions") {
+		t.Errorf("broadcastMessage() output %q, want 4 sessions", output.String())
+	}
+}
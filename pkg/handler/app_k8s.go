@@ -44,10 +44,14 @@ func (u *UserSelectHandler) displayResult(searchHeader string, Labels, fields []
 	table.Initial()
 	loginTip := lang.T("Enter ID number directly login, multiple search use // + field, such as: //16")
 	pageActionTip := lang.T("Page up: b	Page down: n")
-	actionTip := fmt.Sprintf("%s %s", loginTip, pageActionTip)
+	jumpPageTip := lang.T("Jump to page: j+page number, such as: j2")
+	pageTip := fmt.Sprintf(lang.T("Page %d/%d"), currentPage, totalPage)
+	pageControlTip := fmt.Sprintf("%s  %s	%s", pageTip, pageActionTip, jumpPageTip)
 	_, _ = vt.Write([]byte(utils.CharClear))
 	_, _ = vt.Write([]byte(table.Display()))
-	utils.IgnoreErrWriteString(vt, utils.WrapperString(actionTip, utils.Green))
+	utils.IgnoreErrWriteString(vt, utils.WrapperString(loginTip, utils.Green))
+	utils.IgnoreErrWriteString(vt, utils.CharNewLine)
+	utils.IgnoreErrWriteString(vt, utils.WrapperString(pageControlTip, utils.Green))
 	utils.IgnoreErrWriteString(vt, utils.CharNewLine)
 	utils.IgnoreErrWriteString(vt, utils.WrapperString(searchHeader, utils.Green))
 	utils.IgnoreErrWriteString(vt, utils.CharNewLine)
@@ -6,7 +6,9 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/jumpserver/koko/pkg/common"
 	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/httplib"
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/service"
 	"github.com/jumpserver/koko/pkg/logger"
@@ -16,13 +18,26 @@ import (
 )
 
 func (u *UserSelectHandler) retrieveRemoteAsset(reqParam model.PaginationParam) []model.Asset {
-	res, err := u.h.jmsService.GetUserPermsAssets(u.user.ID, reqParam)
+	res, err := u.fetchAssetPage(assetPageKey("assets", "", reqParam), func() (model.PaginationResponse, error) {
+		return u.h.jmsService.GetUserPermsAssets(u.user.ID, reqParam)
+	})
 	if err != nil {
 		logger.Errorf("Get user perm assets failed: %s", err.Error())
+		u.warnCoreUnreachable(err)
 	}
 	return u.updateRemotePageData(reqParam, res)
 }
 
+// warnCoreUnreachable 重试后 core 仍然无法访问时提示用户稍后重试，而不是只显示没有资产
+func (u *UserSelectHandler) warnCoreUnreachable(err error) {
+	if !httplib.IsUnreachable(err) {
+		return
+	}
+	lang := i18n.NewLang(u.h.i18nLang)
+	msg := lang.T("JumpServer core is unreachable, please try again later")
+	utils.IgnoreErrWriteString(u.h.term, utils.WrapperWarn(msg))
+}
+
 func (u *UserSelectHandler) searchLocalAsset(searches ...string) []model.Asset {
 	allFields := []string{"name", "address", "platform", "comment"}
 	fields := make(map[string]struct{}, len(allFields))
@@ -57,6 +72,7 @@ func (u *UserSelectHandler) displayAssets(searchHeader string) {
 	platformLabel := lang.T("Platform")
 	orgLabel := lang.T("Organization")
 	commentLabel := lang.T("Comment")
+	typeLabel := lang.T("Type")
 	idFieldSize := len(idLabel)
 	nameFieldSize := len(nameLabel)
 	addressFieldSize := len(addressLabel)
@@ -64,6 +80,7 @@ func (u *UserSelectHandler) displayAssets(searchHeader string) {
 	platformFieldSize := len(platformLabel)
 	organizationFieldSize := len(orgLabel)
 	commentFieldSize := len(commentLabel)
+	typeFieldSize := len(typeLabel)
 	data := make([]map[string]string, len(currentResult))
 	for i := range currentResult {
 		item := &u.currentResult[i]
@@ -71,11 +88,13 @@ func (u *UserSelectHandler) displayAssets(searchHeader string) {
 		idNumber := strconv.Itoa(i + 1)
 		row["ID"] = idNumber
 		row["Name"] = strings.ReplaceAll(item.Name, " ", "_") // 多个空格可能会导致换行，所以全部替换成下划线
-		row["Address"] = item.Address
+		address := common.DisplayHost(item.Address)
+		row["Address"] = address
 		row["Protocols"] = strings.Join(item.SupportProtocols(), "|")
 		row["Platform"] = item.Platform.Name
 		row["Organization"] = item.OrgName
 		row["Comment"] = joinMultiLineString(item.Comment)
+		row["Type"] = resourceTypeLabel(lang, assetResourceType(item))
 		data[i] = row
 		if idFieldSize < len(idNumber) {
 			idFieldSize = len(idNumber)
@@ -83,8 +102,11 @@ func (u *UserSelectHandler) displayAssets(searchHeader string) {
 		if len(item.Name) > nameFieldSize {
 			nameFieldSize = len(item.Name)
 		}
-		if len(item.Address) > addressFieldSize {
-			addressFieldSize = len(item.Address)
+		if len(address) > addressFieldSize {
+			addressFieldSize = len(address)
+		}
+		if len(row["Type"]) > typeFieldSize {
+			typeFieldSize = len(row["Type"])
 		}
 	}
 	if nameFieldSize > maxFieldSize {
@@ -102,11 +124,17 @@ func (u *UserSelectHandler) displayAssets(searchHeader string) {
 		"Platform":     {0, platformFieldSize, 0},
 		"Organization": {0, organizationFieldSize, 0},
 		"Comment":      {0, commentFieldSize, 0},
+		"Type":         {typeFieldSize, 0, 0},
 	}
 	allLabels := []string{idLabel, nameLabel, addressLabel, protocolsLabel,
 		platformLabel, orgLabel, commentLabel}
 	allFields := []string{"ID", "Name", "Address", "Protocols",
 		"Platform", "Organization", "Comment"}
+	if u.currentType == TypeAll {
+		// 统一搜索的结果在 ID 之后显示资源类型
+		allLabels = append([]string{idLabel, typeLabel}, allLabels[1:]...)
+		allFields = append([]string{"ID", "Type"}, allFields[1:]...)
+	}
 	labels := make([]string, 0, len(allLabels))
 	fields := make([]string, 0, len(allFields))
 	for i := range allFields {
@@ -142,7 +170,10 @@ func (u *UserSelectHandler) proxyAsset(asset model.Asset) {
 		return false
 	}
 	protocols := asset.FilterProtocols(filterFunc)
-	protocol, ok := u.h.chooseAssetProtocol(protocols)
+	protocol, ok := u.reconnectProtocol(protocols)
+	if !ok {
+		protocol, ok = u.h.chooseAssetProtocol(protocols)
+	}
 	if !ok {
 		logger.Info("Not select protocol")
 		return
@@ -163,12 +194,27 @@ func (u *UserSelectHandler) proxyAsset(asset model.Asset) {
 		return
 	}
 	supportAccounts := u.filterValidAccount(accounts)
-	selectedAccount, ok := u.h.chooseAccount(supportAccounts)
+	selectedAccount, ok := u.reconnectAccount(supportAccounts)
+	if !ok {
+		selectedAccount, ok = u.h.chooseAccount(&asset, supportAccounts)
+	}
 	if !ok {
 		logger.Info("Not select account")
 		return
 	}
 	u.selectedAccount = &selectedAccount
+	macro, ok := u.h.chooseMacro(asset, protocol)
+	if !ok {
+		logger.Info("Not select macro")
+		return
+	}
+	var container *proxy.ContainerInfo
+	if protocol == srvconn.ProtocolK8s {
+		if container, ok = u.h.chooseK8sPod(); !ok {
+			logger.Info("Not select k8s pod")
+			return
+		}
+	}
 	req := service.SuperConnectTokenReq{
 		UserId:        u.user.ID,
 		AssetId:       asset.ID,
@@ -176,68 +222,90 @@ func (u *UserSelectHandler) proxyAsset(asset model.Asset) {
 		Protocol:      protocol,
 		ConnectMethod: "ssh",
 	}
-	tokenInfo, err := u.h.jmsService.CreateSuperConnectToken(&req)
+	tokenInfo, ok := u.createConnectToken(&req)
+	if !ok {
+		return
+	}
+
+	connectToken, err := u.h.jmsService.GetConnectTokenInfo(tokenInfo.ID)
+	if err != nil {
+		logger.Errorf("connect token err: %s", err)
+		utils.IgnoreErrWriteString(u.h.term, lang.T("get connect token err"))
+		return
+	}
+	proxyOpts := make([]proxy.ConnectionOption, 0, 10)
+	proxyOpts = append(proxyOpts, proxy.ConnectTokenAuthInfo(&connectToken))
+	proxyOpts = append(proxyOpts, proxy.ConnectI18nLang(i18nLang))
+	// 连接成功后才记录到最近连接
+	proxyOpts = append(proxyOpts, proxy.ConnectOnSuccess(func() {
+		u.recordRecentSession(asset, protocol)
+	}))
+	if macro != nil {
+		proxyOpts = append(proxyOpts, proxy.ConnectMacro(macro))
+	}
+	if container != nil {
+		proxyOpts = append(proxyOpts, proxy.ConnectContainer(container))
+	}
+	proxyOpts = u.h.commandSummaryOptions(proxyOpts)
+	proxyOpts = u.h.bellPolicyOptions(proxyOpts)
+	srv, err := proxy.NewServer(u.h.sess, u.h.jmsService, proxyOpts...)
+	if err != nil {
+		logger.Errorf("create proxy server err: %s", err)
+		return
+	}
+	srv.Proxy()
+}
+
+func (u *UserSelectHandler) isHiddenField(field string) bool {
+	fieldName := strings.ToLower(field)
+	if isBuiltinFields(fieldName) {
+		return false
+	}
+	_, ok := u.hiddenFields[fieldName]
+	return ok
+}
+
+// createConnectToken 创建连接令牌，处理 ACL 拒绝和登录复核
+func (u *UserSelectHandler) createConnectToken(req *service.SuperConnectTokenReq) (model.ConnectTokenInfo, bool) {
+	lang := i18n.NewLang(u.h.i18nLang)
+	tokenInfo, err := u.h.jmsService.CreateSuperConnectToken(req)
 	if err != nil {
 		if tokenInfo.Code == "" {
 			logger.Errorf("Create connect token and auth info failed: %s", err)
 			utils.IgnoreErrWriteString(u.h.term, lang.T("Core API failed"))
-			return
+			return tokenInfo, false
 		}
 		switch tokenInfo.Code {
 		case model.ACLReject:
 			logger.Errorf("Create connect token and auth info failed: %s", tokenInfo.Detail)
 			utils.IgnoreErrWriteString(u.h.term, lang.T("ACL reject"))
 			utils.IgnoreErrWriteString(u.h.term, utils.CharNewLine)
-			return
+			return tokenInfo, false
 		case model.ACLReview:
 			reviewHandler := LoginReviewHandler{
 				readWriter: u.h.sess,
 				i18nLang:   u.h.i18nLang,
 				user:       u.user,
 				jmsService: u.h.jmsService,
-				req:        &req,
+				req:        req,
 			}
 			ok2, err2 := reviewHandler.WaitReview(u.h.sess.Context())
 			if err2 != nil {
 				logger.Errorf("Wait login review failed: %s", err)
 				utils.IgnoreErrWriteString(u.h.term, lang.T("Core API failed"))
-				return
+				return tokenInfo, false
 			}
 			if !ok2 {
 				logger.Error("Wait login review failed")
-				return
+				return tokenInfo, false
 			}
 			tokenInfo = reviewHandler.tokenInfo
 		default:
 			logger.Errorf("Create connect token and auth info failed: %s %s", tokenInfo.Code, tokenInfo.Detail)
-			return
+			return tokenInfo, false
 		}
 	}
-
-	connectToken, err := u.h.jmsService.GetConnectTokenInfo(tokenInfo.ID)
-	if err != nil {
-		logger.Errorf("connect token err: %s", err)
-		utils.IgnoreErrWriteString(u.h.term, lang.T("get connect token err"))
-		return
-	}
-	proxyOpts := make([]proxy.ConnectionOption, 0, 10)
-	proxyOpts = append(proxyOpts, proxy.ConnectTokenAuthInfo(&connectToken))
-	proxyOpts = append(proxyOpts, proxy.ConnectI18nLang(i18nLang))
-	srv, err := proxy.NewServer(u.h.sess, u.h.jmsService, proxyOpts...)
-	if err != nil {
-		logger.Errorf("create proxy server err: %s", err)
-		return
-	}
-	srv.Proxy()
-}
-
-func (u *UserSelectHandler) isHiddenField(field string) bool {
-	fieldName := strings.ToLower(field)
-	if isBuiltinFields(fieldName) {
-		return false
-	}
-	_, ok := u.hiddenFields[fieldName]
-	return ok
+	return tokenInfo, true
 }
 
 func (u *UserSelectHandler) filterValidAccount(accounts []model.PermAccount) []model.PermAccount {
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+// loadFavoriteAssets 获取用户在 Web 端收藏的资产，终端只展示不修改
+func (u *UserSelectHandler) loadFavoriteAssets() {
+	assets, err := u.h.jmsService.GetFavoriteAssets(u.user.ID)
+	if err != nil {
+		logger.Errorf("Get user %s favorite assets failed: %s", u.user.Name, err)
+	}
+	// 接口失败或者无收藏时，使用空列表
+	u.favoriteAssets = make([]model.Asset, 0, len(assets))
+	u.favoriteAssets = append(u.favoriteAssets, assets...)
+}
+
+func (u *UserSelectHandler) searchFavoriteAsset(searches ...string) []model.Asset {
+	allFields := []string{"name", "address", "platform", "comment"}
+	fields := make(map[string]struct{}, len(allFields))
+	for i := range allFields {
+		if u.isHiddenField(allFields[i]) {
+			continue
+		}
+		fields[allFields[i]] = struct{}{}
+	}
+	items := make([]model.Asset, 0, len(u.favoriteAssets))
+	for i := range u.favoriteAssets {
+		data := assetSearchFieldsMap(&u.favoriteAssets[i])
+		if containKeysInMapItemFields(data, fields, searches...) {
+			items = append(items, u.favoriteAssets[i])
+		}
+	}
+	return items
+}
+
+func (u *UserSelectHandler) displayFavoriteAssetResult(searchHeader string) {
+	lang := i18n.NewLang(u.h.i18nLang)
+	if len(u.currentResult) == 0 {
+		noFavorites := lang.T("No favorite assets, you can add favorites on the web")
+		u.displayNoResultMsg(searchHeader, noFavorites)
+		return
+	}
+	u.displayAssets(searchHeader)
+}
@@ -9,9 +9,13 @@ import (
 )
 
 func (u *UserSelectHandler) retrieveRemoteNodeAsset(reqParam model.PaginationParam) []model.Asset {
-	res, err := u.h.jmsService.GetUserNodeAssets(u.user.ID, u.selectedNode.ID, reqParam)
+	key := assetPageKey("node", u.selectedNode.ID, reqParam)
+	res, err := u.fetchAssetPage(key, func() (model.PaginationResponse, error) {
+		return u.h.jmsService.GetUserNodeAssets(u.user.ID, u.selectedNode.ID, reqParam)
+	})
 	if err != nil {
 		logger.Errorf("Get user %s node assets failed %s", u.user.Name, err)
+		u.warnCoreUnreachable(err)
 	}
 	return u.updateRemotePageData(reqParam, res)
 }
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+/*
+资产菜单按页从 core 获取资产(搜索也由 core 完成)，翻页和返回列表时重复请求同一页，
+assetPageCache 在会话内缓存最近获取的分页结果：
+ASSET_MENU_CACHE_SIZE 限制缓存的资产数量，超出时淘汰最久未使用的页; ASSET_MENU_CACHE_TTL 为缓存的有效时间(秒)。
+ASSET_LOAD_POLICY 为 all 时，用户的资产数量超过 ASSET_MENU_CACHE_SIZE 也改为分页加载，避免全部加载到内存。
+菜单中输入 r 刷新时清空缓存。
+*/
+
+type assetPageEntry struct {
+	key     string
+	res     model.PaginationResponse
+	expired time.Time
+}
+
+type assetPageCache struct {
+	mu      sync.Mutex
+	items   map[string]*list.Element
+	order   *list.List
+	size    int
+	maxSize int
+	ttl     time.Duration
+}
+
+func newAssetPageCache(maxSize int, ttl time.Duration) *assetPageCache {
+	return &assetPageCache{
+		items:   make(map[string]*list.Element),
+		order:   list.New(),
+		maxSize: maxSize,
+		ttl:     ttl,
+	}
+}
+
+func (c *assetPageCache) enabled() bool {
+	return c != nil && c.maxSize > 0 && c.ttl > 0
+}
+
+func (c *assetPageCache) Get(key string) (model.PaginationResponse, bool) {
+	if !c.enabled() {
+		return model.PaginationResponse{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return model.PaginationResponse{}, false
+	}
+	entry := elem.Value.(*assetPageEntry)
+	if time.Now().After(entry.expired) {
+		c.remove(elem)
+		return model.PaginationResponse{}, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.res, true
+}
+
+func (c *assetPageCache) Set(key string, res model.PaginationResponse) {
+	if !c.enabled() || pageCost(res) > c.maxSize {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+	entry := &assetPageEntry{key: key, res: res, expired: time.Now().Add(c.ttl)}
+	c.items[key] = c.order.PushFront(entry)
+	c.size += pageCost(res)
+	for c.size > c.maxSize {
+		c.remove(c.order.Back())
+	}
+}
+
+// Clear 清空缓存，刷新资产时使用
+func (c *assetPageCache) Clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+	c.size = 0
+}
+
+func (c *assetPageCache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(*assetPageEntry)
+	delete(c.items, entry.key)
+	c.size -= pageCost(entry.res)
+}
+
+// pageCost 按资产数量计算缓存大小，空页也占用一个位置
+func pageCost(res model.PaginationResponse) int {
+	if len(res.Data) == 0 {
+		return 1
+	}
+	return len(res.Data)
+}
+
+func newMenuAssetPageCache() *assetPageCache {
+	conf := config.GetConf()
+	return newAssetPageCache(conf.AssetMenuCacheSize, time.Duration(conf.AssetMenuCacheTTL)*time.Second)
+}
+
+func assetPageKey(kind, nodeID string, reqParam model.PaginationParam) string {
+	return fmt.Sprintf("%s|%s|%+v", kind, nodeID, reqParam)
+}
+
+// fetchAssetPage 优先使用缓存的分页结果，获取失败的结果不缓存
+func (u *UserSelectHandler) fetchAssetPage(key string, fetch func() (model.PaginationResponse, error)) (model.PaginationResponse, error) {
+	if res, ok := u.pageCache.Get(key); ok {
+		return res, nil
+	}
+	res, err := fetch()
+	if err == nil {
+		u.pageCache.Set(key, res)
+	}
+	return res, err
+}
+
+// exceedMenuCacheSize 资产数量超过 ASSET_MENU_CACHE_SIZE 时不全部加载到内存
+func exceedMenuCacheSize(userName string, total int) bool {
+	maxSize := config.GetConf().AssetMenuCacheSize
+	if maxSize <= 0 || total <= maxSize {
+		return false
+	}
+	logger.Infof("User %s has %d assets, more than ASSET_MENU_CACHE_SIZE %d, load assets by page",
+		userName, total, maxSize)
+	return true
+}
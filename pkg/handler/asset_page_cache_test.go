@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestAssetPageCache(t *testing.T) {
+	page := func(n int) model.PaginationResponse {
+		return model.PaginationResponse{Total: 100, Data: make([]model.Asset, n)}
+	}
+	cache := newAssetPageCache(10, time.Minute)
+	cache.Set("a", page(4))
+	cache.Set("b", page(4))
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("page a not cached")
+	}
+	// 超出大小时淘汰最久未使用的 b
+	cache.Set("c", page(4))
+	if _, ok := cache.Get("b"); ok {
+		t.Error("page b not evicted")
+	}
+	if _, ok := cache.Get("a"); !ok {
+		t.Error("recently used page a evicted")
+	}
+	cache.Set("big", page(11))
+	if _, ok := cache.Get("big"); ok {
+		t.Error("page larger than cache size cached")
+	}
+	cache.Clear()
+	if _, ok := cache.Get("a"); ok || cache.size != 0 {
+		t.Error("cache not cleared")
+	}
+
+	cache = newAssetPageCache(10, time.Millisecond)
+	cache.Set("a", page(1))
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := cache.Get("a"); ok {
+		t.Error("expired page returned")
+	}
+
+	var disabled *assetPageCache
+	disabled.Set("a", page(1))
+	disabled.Clear()
+	if _, ok := disabled.Get("a"); ok {
+		t.Error("nil cache returned page")
+	}
+}
+
+func TestFetchAssetPage(t *testing.T) {
+	u := &UserSelectHandler{pageCache: newAssetPageCache(100, time.Minute)}
+	calls := 0
+	fetch := func() (model.PaginationResponse, error) {
+		calls++
+		if calls == 1 {
+			return model.PaginationResponse{}, errors.New("core unreachable")
+		}
+		return model.PaginationResponse{Total: 1, Data: []model.Asset{{ID: "asset-1"}}}, nil
+	}
+	param := model.PaginationParam{PageSize: 10, Searches: []string{"web"}}
+	key := assetPageKey("assets", "", param)
+	for i := 0; i < 3; i++ {
+		_, _ = u.fetchAssetPage(key, fetch)
+	}
+	if calls != 2 {
+		t.Errorf("fetch calls = %d, want failed result not cached and success cached", calls)
+	}
+	param.Searches = []string{"db"}
+	if assetPageKey("assets", "", param) == key {
+		t.Error("page key ignores search keywords")
+	}
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/service"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+const (
+	protocolRDP = "rdp"
+
+	// 通过 RDP 网关连接时使用的连接方式，与 core 中 mstsc 客户端一致
+	rdpConnectMethod = "mstsc"
+)
+
+func rdpGatewayEnabled(h *InteractiveHandler) bool {
+	return config.GetConf().RDPGatewayAddr != ""
+}
+
+func filterRDPAssets(assets []model.Asset) []model.Asset {
+	ret := make([]model.Asset, 0, len(assets))
+	for i := range assets {
+		if assets[i].IsSupportProtocol(protocolRDP) {
+			ret = append(ret, assets[i])
+		}
+	}
+	return ret
+}
+
+/*
+proxyRDPAsset koko 无法在终端中显示 RDP 画面，选择 Windows 资产后按照权限和 ACL
+创建 rdp 协议的连接令牌，交给已部署的 RDP 网关(如 Razor)处理。用户使用 RDP 客户端
+连接网关，网关使用令牌获取账号密码连接资产，并负责 RDP 会话的录像。
+*/
+
+func (u *UserSelectHandler) proxyRDPAsset(asset model.Asset) {
+	lang := i18n.NewLang(u.h.i18nLang)
+	if !asset.IsSupportProtocol(protocolRDP) {
+		msg := fmt.Sprintf(lang.T("Asset %s does not support RDP protocol"), asset.Name)
+		utils.IgnoreErrWriteString(u.h.term, utils.WrapperWarn(msg))
+		return
+	}
+	accounts, err := u.h.jmsService.GetAccountsByUserIdAndAssetId(u.user.ID, asset.ID)
+	if err != nil {
+		logger.Errorf("Get asset accounts err: %s", err)
+		return
+	}
+	selectedAccount, ok := u.h.chooseAccount(&asset, u.filterValidAccount(accounts))
+	if !ok {
+		logger.Info("Not select account")
+		return
+	}
+	req := service.SuperConnectTokenReq{
+		UserId:        u.user.ID,
+		AssetId:       asset.ID,
+		Account:       selectedAccount.Alias,
+		Protocol:      protocolRDP,
+		ConnectMethod: rdpConnectMethod,
+	}
+	tokenInfo, ok := u.createConnectToken(&req)
+	if !ok {
+		return
+	}
+	logger.Infof("User %s create rdp connect token %s for asset %s",
+		u.user.Name, tokenInfo.ID, asset.String())
+	u.h.displayRDPConnectInfo(asset, tokenInfo)
+}
+
+func (h *InteractiveHandler) displayRDPConnectInfo(asset model.Asset, tokenInfo model.ConnectTokenInfo) {
+	lang := i18n.NewLang(h.i18nLang)
+	// RDP 网关通过用户名中的令牌 ID 获取连接信息，密码为令牌的值
+	username := fmt.Sprintf("%s|%s", h.user.Username, tokenInfo.ID)
+	lines := []string{
+		utils.WrapperString(fmt.Sprintf(lang.T("Connect to %s with an RDP client:"), asset.Name), utils.Green),
+		fmt.Sprintf("  %s: %s", lang.T("Address"), config.GetConf().RDPGatewayAddr),
+		fmt.Sprintf("  %s: %s", lang.T("Username"), username),
+		fmt.Sprintf("  %s: %s", lang.T("Password"), tokenInfo.Value),
+	}
+	if tokenInfo.ExpireTime > 0 {
+		lines = append(lines, fmt.Sprintf(lang.T("The connection information expires in %d seconds"),
+			tokenInfo.ExpireTime))
+	}
+	utils.IgnoreErrWriteString(h.term, strings.Join(lines, utils.CharNewLine)+utils.CharNewLine)
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"bytes"
+	"sort"
+
+	"golang.org/x/text/collate"
+
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+/*
+菜单中的资产和节点按会话语言的排序规则排序，中文按拼音、不区分大小写，名称中的数字按数值比较。
+排序前一次性计算每个名称的排序 key，比较时只比较字节，资产数量较多时也不会反复解析名称。
+排序是稳定的，名称相同时保持原来的顺序。
+*/
+
+type collationSorter struct {
+	keys [][]byte
+	swap func(i, j int)
+}
+
+func (s *collationSorter) Len() int {
+	return len(s.keys)
+}
+
+func (s *collationSorter) Less(i, j int) bool {
+	return bytes.Compare(s.keys[i], s.keys[j]) < 0
+}
+
+func (s *collationSorter) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.swap(i, j)
+}
+
+// sortByCollation 按 name 返回的名称排序 n 个元素，swap 交换原数据中的元素
+func sortByCollation(lang string, n int, name func(i int) string, swap func(i, j int)) {
+	if n < 2 {
+		return
+	}
+	col := i18n.NewLang(lang).Collator()
+	var buf collate.Buffer
+	keys := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		keys[i] = col.KeyFromString(&buf, name(i))
+	}
+	sort.Stable(&collationSorter{keys: keys, swap: swap})
+}
+
+func sortAssetsByName(lang string, assets []model.Asset) {
+	sortByCollation(lang, len(assets), func(i int) string {
+		return assets[i].Name
+	}, func(i, j int) {
+		assets[i], assets[j] = assets[j], assets[i]
+	})
+}
+
+func sortNodeTreesByName(lang string, items model.NodeTreeList) {
+	sortByCollation(lang, len(items), func(i int) string {
+		return items[i].Name
+	}, func(i, j int) {
+		items[i], items[j] = items[j], items[i]
+	})
+}
+
+// sortAssets 资产列表按名称排序时使用会话语言的排序规则，按 IP 排序时保持原来的顺序
+func (u *UserSelectHandler) sortAssets(assets []model.Asset) {
+	if u.h.terminalConf.AssetListSortBy == "ip" {
+		return
+	}
+	sortAssetsByName(u.h.i18nLang, assets)
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestSortAssetsByName(t *testing.T) {
+	names := func(assets []model.Asset) string {
+		items := make([]string, len(assets))
+		for i := range assets {
+			items[i] = assets[i].Name
+		}
+		return strings.Join(items, ",")
+	}
+	tests := []struct {
+		lang  string
+		names []string
+		want  string
+	}{
+		{lang: "en", names: []string{"web10", "Web3", "web2", "db1"}, want: "db1,web2,Web3,web10"},
+		{lang: "zh", names: []string{"上海", "北京", "广州"}, want: "北京,广州,上海"},
+	}
+	for _, tt := range tests {
+		assets := make([]model.Asset, len(tt.names))
+		for i := range tt.names {
+			assets[i] = model.Asset{Name: tt.names[i]}
+		}
+		sortAssetsByName(tt.lang, assets)
+		if got := names(assets); got != tt.want {
+			t.Errorf("sortAssetsByName(%s) = %s, want %s", tt.lang, got, tt.want)
+		}
+	}
+
+	// 名称相同时保持原来的顺序
+	assets := []model.Asset{{ID: "1", Name: "web"}, {ID: "2", Name: "WEB"}, {ID: "3", Name: "app"}}
+	sortAssetsByName("en", assets)
+	if assets[0].ID != "3" || assets[1].ID != "1" || assets[2].ID != "2" {
+		t.Errorf("sortAssetsByName() is not stable: %+v", assets)
+	}
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/auth"
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+/*
+DisplaySSHCommand 显示通过 koko 直接连接当前列表中第 index 个资产的 ssh 命令，不会连接资产。
+命令使用 "用户名@账号@资产ID" 格式的直连用户名，资产 ID 保证唯一匹配；
+输出不带颜色，方便复制到脚本中使用。
+*/
+
+func (u *UserSelectHandler) DisplaySSHCommand(index int) bool {
+	if index <= 0 || index > len(u.currentResult) {
+		return false
+	}
+	lang := i18n.NewLang(u.h.i18nLang)
+	asset := u.currentResult[index-1]
+	if !asset.IsSupportProtocol(model.ProtocolSSH) {
+		msg := fmt.Sprintf(lang.T("Asset %s does not support SSH protocol"), asset.Name)
+		utils.IgnoreErrWriteString(u.h.term, utils.WrapperWarn(msg))
+		return true
+	}
+	accounts, err := u.h.jmsService.GetAccountsByUserIdAndAssetId(u.user.ID, asset.ID)
+	if err != nil {
+		logger.Errorf("Get asset accounts err: %s", err)
+		utils.IgnoreErrWriteString(u.h.term, utils.WrapperWarn(lang.T("Core API failed")))
+		return true
+	}
+	selectedAccount, ok := u.h.chooseAccount(&asset, filterAutoLoginAccounts(accounts))
+	if !ok {
+		logger.Info("Not select account")
+		return true
+	}
+	conf := config.GetConf()
+	username := strings.Join([]string{u.user.Username, selectedAccount.Username, asset.ID},
+		auth.SeparatorATSign)
+	lines := []string{
+		fmt.Sprintf(lang.T("SSH command to connect %s as %s through koko:"), asset.Name, selectedAccount.Username),
+		buildSSHCommand(username, sshConnectHost(conf), conf.SSHPort, ""),
+	}
+	if jump := strings.TrimSpace(conf.SSHConnectProxyJump); jump != "" {
+		lines = append(lines, lang.T("Through the jump host:"),
+			buildSSHCommand(username, sshConnectHost(conf), conf.SSHPort, jump))
+	}
+	utils.IgnoreErrWriteString(u.h.term, strings.Join(lines, utils.CharNewLine)+utils.CharNewLine)
+	return true
+}
+
+// filterAutoLoginAccounts 手动输入和同名账号无法使用直连用户名登录
+func filterAutoLoginAccounts(accounts []model.PermAccount) []model.PermAccount {
+	ret := make([]model.PermAccount, 0, len(accounts))
+	for i := range accounts {
+		switch accounts[i].Username {
+		case model.InputUser, model.DynamicUser, model.ANONUser:
+			continue
+		}
+		ret = append(ret, accounts[i])
+	}
+	return ret
+}
+
+// sshConnectHost 未配置 SSH_CONNECT_HOST 且监听所有地址时使用主机名
+func sshConnectHost(conf config.Config) string {
+	if host := strings.TrimSpace(conf.SSHConnectHost); host != "" {
+		return host
+	}
+	if ip := net.ParseIP(conf.BindHost); conf.BindHost != "" && (ip == nil || !ip.IsUnspecified()) {
+		return conf.BindHost
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		return hostname
+	}
+	return "127.0.0.1"
+}
+
+func buildSSHCommand(username, host, port, jump string) string {
+	args := []string{"ssh"}
+	if jump != "" {
+		args = append(args, "-J", shellQuote(jump))
+	}
+	if port != "" && port != "22" {
+		args = append(args, "-p", port)
+	}
+	args = append(args, "-l", shellQuote(username), shellQuote(host))
+	return strings.Join(args, " ")
+}
+
+func shellQuote(s string) string {
+	needQuote := s == ""
+	for _, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("-_.:/=,+", c):
+		default:
+			needQuote = true
+		}
+	}
+	if !needQuote {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
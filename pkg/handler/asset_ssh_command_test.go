@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/config"
+)
+
+func TestBuildSSHCommand(t *testing.T) {
+	username := "admin@root@9c6e4b1a-3f3e-4a8e-9d6e-2f1c1b7e0a11"
+	tests := []struct {
+		port string
+		jump string
+		want string
+	}{
+		{port: "2222", want: "ssh -p 2222 -l 'admin@root@9c6e4b1a-3f3e-4a8e-9d6e-2f1c1b7e0a11' koko.example.com"},
+		{port: "22", want: "ssh -l 'admin@root@9c6e4b1a-3f3e-4a8e-9d6e-2f1c1b7e0a11' koko.example.com"},
+		{port: "2222", jump: "ops@jump.example.com:22",
+			want: "ssh -J 'ops@jump.example.com:22' -p 2222 -l 'admin@root@9c6e4b1a-3f3e-4a8e-9d6e-2f1c1b7e0a11' koko.example.com"},
+	}
+	for _, tt := range tests {
+		if got := buildSSHCommand(username, "koko.example.com", tt.port, tt.jump); got != tt.want {
+			t.Errorf("buildSSHCommand() = %s, want %s", got, tt.want)
+		}
+	}
+	if got := shellQuote("it's"); got != `'it'\''s'` {
+		t.Errorf("shellQuote() = %s", got)
+	}
+}
+
+func TestSSHConnectHost(t *testing.T) {
+	conf := config.Config{SSHConnectHost: "koko.example.com", BindHost: "0.0.0.0"}
+	if got := sshConnectHost(conf); got != "koko.example.com" {
+		t.Errorf("sshConnectHost() = %s, want koko.example.com", got)
+	}
+	conf = config.Config{BindHost: "10.1.1.1"}
+	if got := sshConnectHost(conf); got != "10.1.1.1" {
+		t.Errorf("sshConnectHost() = %s, want 10.1.1.1", got)
+	}
+	conf = config.Config{BindHost: "::"}
+	if got := sshConnectHost(conf); got == "::" || got == "" {
+		t.Errorf("sshConnectHost() = %q, want the hostname", got)
+	}
+}
@@ -1,13 +1,19 @@
 package handler
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"os"
+	"strings"
 	"text/template"
+	"time"
 
+	"github.com/jumpserver/koko/pkg/config"
 	"github.com/jumpserver/koko/pkg/i18n"
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
 	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/proxy"
 	"github.com/jumpserver/koko/pkg/utils"
 )
 
@@ -18,48 +24,228 @@ type MenuItem struct {
 
 type Menu []MenuItem
 
-type ColorMeta struct {
-	GreenBoldColor string
-	ColorEnd       string
+// menuEntry 菜单注册项，instruct 和 helpText 为翻译前的文本，
+// available 为空表示所有用户可用
+type menuEntry struct {
+	instruct  string
+	helpText  string
+	available func(h *InteractiveHandler) bool
+}
+
+func adminOnly(h *InteractiveHandler) bool {
+	return h.user.IsAdmin()
+}
+
+// menuRegistry 横幅和帮助(?)共用的菜单项，按显示顺序排列
+var menuRegistry = []menuEntry{
+	{instruct: "part IP, Hostname, Comment", helpText: "to search login if unique"},
+	{instruct: "/ + IP, Hostname, Comment", helpText: "to search, such as: /192.168"},
+	{instruct: "// + Regular expression", helpText: "to filter the search result, such as: //^web-(prod|stg)-\\d+"},
+	{instruct: "@ + Platform", helpText: "to filter the assets by platform, such as: /web @linux, enter @ to clear"},
+	{instruct: "comment:, tag: + keyword", helpText: "to search the field only (also name:, ip:), such as: comment:payments"},
+	{instruct: "p", helpText: "display the assets you have permission"},
+	{instruct: "g", helpText: "display the node that you have permission"},
+	{instruct: "h", helpText: "display the hosts that you have permission"},
+	{instruct: "d", helpText: "display the databases that you have permission"},
+	{instruct: "k", helpText: "display the kubernetes that you have permission"},
+	{instruct: "w", helpText: "display the Windows assets that you can connect with RDP", available: rdpGatewayEnabled},
+	{instruct: "u", helpText: "search all types of resources in one list, filter by type such as: web type:db"},
+	{instruct: "f", helpText: "display your favorite assets"},
+	{instruct: "c", helpText: "display your recent sessions"},
+	{instruct: "l", helpText: "reconnect to the last asset of this session"},
+	{instruct: "m", helpText: "display and manage your connection macros"},
+	{instruct: "ssh + ID", helpText: "print the ssh command to connect the asset through koko, such as: ssh1"},
+	{instruct: "history + keyword", helpText: "search your command history, such as: history systemctl"},
+	{instruct: "summary + on/off", helpText: "show a summary of your commands after each session ends"},
+	{instruct: "bell + pass/count/suppress", helpText: "set how the bell characters of the asset output are handled in your sessions"},
+	{instruct: "prompt + template", helpText: "customize your menu prompt with the variables User, Node and Type, enter prompt reset to restore"},
+	{instruct: "whoami", helpText: "display your user, koko node and session ids for support requests"},
+	// 仅管理员可以查看和终止当前节点的活跃会话
+	{instruct: "a", helpText: "display and terminate the active sessions on this node", available: adminOnly},
+	{instruct: "o + ID", helpText: "silently watch the output of an active session listed by a, such as: o1", available: sessionObserveEnabled},
+	{instruct: "broadcast + message", helpText: "notify all users connected to this node", available: adminOnly},
+	{instruct: "reload", helpText: "reload the configuration of this node without restart", available: adminOnly},
+	{instruct: "share + ID", helpText: "share your live session with other users, such as: share1", available: sessionShareEnabled},
+	{instruct: "join + share code", helpText: "join the session shared by other users", available: sessionShareEnabled},
+	{instruct: "attach + ID", helpText: "resume your detached session, such as: attach1", available: sessionDetachEnabled},
+	{instruct: "r", helpText: "refresh your assets and nodes"},
+	{instruct: "s", helpText: "Chinese-English-Japanese-Korean-Russian switch"},
+	{instruct: "?", helpText: "print help"},
+	{instruct: "q", helpText: "exit"},
+}
+
+// buildMenu 根据当前用户的角色、开启的功能和禁用的菜单项生成菜单
+func (h *InteractiveHandler) buildMenu(lang i18n.LanguageCode) Menu {
+	menu := make(Menu, 0, len(menuRegistry))
+	bindings := activeKeyBindings()
+	for i := range menuRegistry {
+		entry := menuRegistry[i]
+		if !h.menuEntryEnabled(entry) {
+			continue
+		}
+		instruct, ok := bindInstruct(bindings, entry.command(), lang.T(entry.instruct))
+		if !ok {
+			continue
+		}
+		menu = append(menu, MenuItem{instruct: instruct, helpText: lang.T(entry.helpText)})
+	}
+	return menu
+}
+
+// resolveTheme 客户端设置了 NO_COLOR 或者 TERM=dumb 时不使用颜色
+func (h *InteractiveHandler) resolveTheme(conf config.Config) *utils.Theme {
+	var (
+		environ []string
+		term    string
+	)
+	if h.sess != nil {
+		environ = h.sess.Environ()
+		term = h.sess.Pty().Term
+	}
+	if _, ok := utils.GetTheme(conf.ColorTheme); !ok {
+		logger.Errorf("Color theme %s not found, use default theme", conf.ColorTheme)
+	}
+	theme := utils.ResolveTheme(conf.ColorTheme, conf.ColorThemeRoles, environ, term)
+	return &theme
+}
+
+func (h *InteractiveHandler) activeTheme() utils.Theme {
+	if h.theme == nil {
+		theme, _ := utils.GetTheme(utils.ThemeDefault)
+		return theme
+	}
+	return *h.theme
+}
+
+// BannerContext 自定义横幅模板可使用的变量
+type BannerContext struct {
+	User        string
+	Time        time.Time
+	HeaderTitle string
+}
+
+func renderBannerTemplate(path string, ctx BannerContext) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := template.New("banner").Parse(string(content))
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, ctx); err != nil {
+		return "", err
+	}
+	// 统一换行符，避免终端显示错位
+	banner := strings.ReplaceAll(buf.String(), "\r\n", "\n")
+	banner = strings.ReplaceAll(banner, "\n", utils.CharNewLine)
+	return sanitizeBannerText(path, banner, true, bannerMaxLength()), nil
+}
+
+const (
+	BannerLineEndingCRLF = "crlf"
+	BannerLineEndingLF   = "lf"
+)
+
+// bannerLineEnding BANNER_LINE_ENDING 为 lf 时只使用 \n，用于会自动补全回车的 Web 终端等客户端，
+// 默认使用 \r\n，PuTTY、OpenSSH 等在 pty 下不转换换行符的客户端也能正确显示
+func bannerLineEnding() string {
+	if strings.EqualFold(strings.TrimSpace(config.GetConf().BannerLineEnding), BannerLineEndingLF) {
+		return "\n"
+	}
+	return utils.CharNewLine
+}
+
+// normalizeLineEnding 将混用的 \r\n 和 \n 统一为 ending
+func normalizeLineEnding(s, ending string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if ending == "\n" {
+		return s
+	}
+	return strings.ReplaceAll(s, "\n", ending)
 }
 
 func (h *InteractiveHandler) displayBanner(sess io.ReadWriter, user string, termConf *model.TerminalConfig) {
-	lang := i18n.NewLang(h.i18nLang)
-	defaultTitle := utils.WrapperTitle(lang.T("Welcome to use JumpServer open source fortress system"))
-	menu := Menu{
-		{instruct: lang.T("part IP, Hostname, Comment"), helpText: lang.T("to search login if unique")},
-		{instruct: lang.T("/ + IP, Hostname, Comment"), helpText: lang.T("to search, such as: /192.168")},
-		{instruct: "p", helpText: lang.T("display the assets you have permission")},
-		{instruct: "g", helpText: lang.T("display the node that you have permission")},
-		{instruct: "h", helpText: lang.T("display the hosts that you have permission")},
-		{instruct: "d", helpText: lang.T("display the databases that you have permission")},
-		{instruct: "k", helpText: lang.T("display the kubernetes that you have permission")},
-		{instruct: "r", helpText: lang.T("refresh your assets and nodes")},
-		{instruct: "s", helpText: lang.T("Chinese-English-Japanese switch")},
-		{instruct: "?", helpText: lang.T("print help")},
-		{instruct: "q", helpText: lang.T("exit")},
+	banner := normalizeLineEnding(h.renderBanner(user, termConf), bannerLineEnding())
+	if _, err := io.WriteString(sess, banner); err != nil {
+		logger.Errorf("Send to client error, %s", err)
 	}
+}
+
+// renderBanner 生成横幅和菜单，换行符由 displayBanner 统一处理
+func (h *InteractiveHandler) renderBanner(user string, termConf *model.TerminalConfig) string {
+	lang := i18n.NewLang(h.i18nLang)
+	theme := h.activeTheme()
+	defaultTitle := theme.WrapTitle(lang.T("Welcome to use JumpServer open source fortress system"))
+	menu := h.buildMenu(lang)
 
 	title := defaultTitle
-	if termConf.HeaderTitle != "" {
-		title = termConf.HeaderTitle
+	headerTitle := sanitizeHeaderTitle(termConf.HeaderTitle)
+	if headerTitle != "" {
+		title = headerTitle
 	}
 
 	prefix := utils.CharClear + utils.CharTab + utils.CharTab
 	suffix := utils.CharNewLine + utils.CharNewLine
-	welcomeMsg := prefix + utils.WrapperTitle(user+",") + "  " + title + suffix
-	_, err := io.WriteString(sess, welcomeMsg)
-	if err != nil {
-		logger.Errorf("Send to client error, %s", err)
-		return
+	welcomeMsg := prefix + theme.WrapTitle(user+",") + "  " + title + suffix
+	if bannerPath := config.GetConf().BannerTemplatePath; bannerPath != "" {
+		ctx := BannerContext{User: user, Time: time.Now().In(h.userTimezone()), HeaderTitle: headerTitle}
+		if banner, err := renderBannerTemplate(bannerPath, ctx); err == nil {
+			welcomeMsg = utils.CharClear + banner + utils.CharNewLine
+		} else {
+			logger.Errorf("Render banner template %s failed, use default banner: %s", bannerPath, err)
+		}
 	}
-	cm := ColorMeta{GreenBoldColor: "\033[1;32m", ColorEnd: "\033[0m"}
+	welcomeMsg += h.lastLoginMessage()
+	welcomeMsg += h.passwordExpiryMessage(time.Now())
+	welcomeMsg += h.permSummaryMessage()
+	if notice := h.popFirstLoginNotice(); notice != "" {
+		welcomeMsg = utils.CharClear + notice + strings.TrimPrefix(welcomeMsg, utils.CharClear)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(welcomeMsg)
+	cm := theme.ColorMeta()
+	termWidth, _ := h.GetPtySize()
+	instructWidth := menuInstructWidth(menu, termWidth)
 	for i, v := range menu {
-		line := fmt.Sprintf(lang.T("\t%d) Enter {{.GreenBoldColor}}%s{{.ColorEnd}} to %s.%s"),
-			i+1, v.instruct, v.helpText, "\r\n")
+		line := fmt.Sprintf(lang.T("\t%2d) Enter {{.InstructionColor}}%s{{.ColorEnd}} to %s.%s"),
+			i+1, padInstruct(v.instruct, instructWidth), theme.WrapHelp(v.helpText), utils.CharNewLine)
 		tmpl := template.Must(template.New("item").Parse(line))
-		if err := tmpl.Execute(sess, cm); err != nil {
+		if err := tmpl.Execute(&buf, cm); err != nil {
 			logger.Error(err)
 		}
 	}
+	if seq := config.GetConf().EscapeSequence; seq != "" {
+		// 资产无响应时可以强制返回菜单
+		buf.WriteString(fmt.Sprintf(lang.T("\tType %s at the start of a line in a session to force-quit and return to the menu."), seq))
+		buf.WriteString(utils.CharNewLine)
+	}
+	if key := config.GetConf().ScrollbackKey; key != "" && proxy.ValidateScrollbackKey(key) == nil {
+		buf.WriteString(fmt.Sprintf(lang.T("\tPress %s in a session to scroll back through the output."), key))
+		buf.WriteString(utils.CharNewLine)
+	}
+	return buf.String()
+}
+
+// menuInstructWidth 计算菜单指令列的对齐宽度。终端宽度未知(为 0)时按最长的指令对齐，
+// 否则对齐宽度不超过终端宽度的一半，超长的指令不再补齐
+func menuInstructWidth(menu Menu, termWidth int) int {
+	width := 0
+	for i := range menu {
+		if w := utils.DisplayWidth(menu[i].instruct); w > width {
+			width = w
+		}
+	}
+	if termWidth > 0 && width > termWidth/2 {
+		width = termWidth / 2
+	}
+	return width
+}
+
+func padInstruct(instruct string, width int) string {
+	if w := utils.DisplayWidth(instruct); w < width {
+		return instruct + strings.Repeat(" ", width-w)
+	}
+	return instruct
 }
@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+/*
+HeaderTitle 和横幅模板(BANNER_TEMPLATE_PATH、FIRST_LOGIN_NOTICE_PATH)的内容直接输出到用户的终端，
+其中的控制字符和转义序列可能导致终端显示错乱，或者修改终端标题、剪贴板等。显示前按 BANNER_ANSI_POLICY 处理：
+none 去除所有转义序列；color(默认) 只保留颜色等文字样式(SGR)；all 保留所有 CSI 序列(光标移动、清屏等)。
+OSC、DCS 等字符串序列和其他控制字符总是去除，超过长度限制时截断，处理后记录警告日志。
+*/
+
+const (
+	BannerANSIPolicyNone  = "none"
+	BannerANSIPolicyColor = "color"
+	BannerANSIPolicyAll   = "all"
+
+	maxHeaderTitleLength   = 256
+	defaultBannerMaxLength = 8192
+)
+
+func normalizeBannerANSIPolicy(policy string) string {
+	switch policy = strings.ToLower(strings.TrimSpace(policy)); policy {
+	case BannerANSIPolicyNone, BannerANSIPolicyAll:
+		return policy
+	}
+	return BannerANSIPolicyColor
+}
+
+// bannerWarned 同样的内容只记录一次警告，横幅在每次显示菜单时都会处理
+var bannerWarned sync.Map
+
+// sanitizeBannerText 处理 source(用于日志)的内容，maxLength 为可见字符的数量上限
+func sanitizeBannerText(source, text string, multiline bool, maxLength int) string {
+	policy := normalizeBannerANSIPolicy(config.GetConf().BannerANSIPolicy)
+	result, stripped, truncated := sanitizeTerminalText(text, policy, multiline, maxLength)
+	if !stripped && !truncated {
+		return result
+	}
+	if _, loaded := bannerWarned.LoadOrStore(source+"\x00"+text, struct{}{}); !loaded {
+		if stripped {
+			logger.Warnf("Banner %s contains control characters not allowed by policy %s, removed", source, policy)
+		}
+		if truncated {
+			logger.Warnf("Banner %s exceeds %d characters, truncated", source, maxLength)
+		}
+	}
+	return result
+}
+
+/*
+sanitizeTerminalText 按策略去除 text 中的控制字符和转义序列，返回处理后的内容、是否去除了内容和是否截断。
+multiline 为 false 时换行替换为空格；保留 \t 和 \r\n，单独的 \r 去除，避免覆盖已经显示的内容。
+*/
+
+func sanitizeTerminalText(text, policy string, multiline bool, maxLength int) (string, bool, bool) {
+	var (
+		sb       strings.Builder
+		stripped bool
+		visible  int
+		styled   bool
+	)
+	for i := 0; i < len(text); {
+		if maxLength > 0 && visible >= maxLength {
+			if styled {
+				// 截断后恢复默认样式，避免影响后续的输出
+				sb.WriteString("\x1b[0m")
+			}
+			return sb.String(), stripped, true
+		}
+		c := text[i]
+		switch {
+		case c == 0x1b:
+			end, keep, sgr := scanBannerEscape(text, i, policy)
+			if keep {
+				sb.WriteString(text[i:end])
+				styled = styled || sgr
+			} else {
+				stripped = true
+			}
+			i = end
+			continue
+		case c == '\n':
+			if multiline {
+				sb.WriteByte(c)
+			} else {
+				sb.WriteByte(' ')
+				stripped = true
+				visible++
+			}
+		case c == '\r':
+			if multiline && i+1 < len(text) && text[i+1] == '\n' {
+				sb.WriteByte(c)
+			} else {
+				stripped = true
+			}
+		case c == '\t':
+			sb.WriteByte(c)
+			visible++
+		case c < 0x20 || c == 0x7f:
+			stripped = true
+		default:
+			r, size := utf8.DecodeRuneInString(text[i:])
+			// 无效的编码和 C1 控制字符(包括 8 位的 CSI)
+			if r == utf8.RuneError && size == 1 || r >= 0x80 && r <= 0x9f {
+				stripped = true
+			} else {
+				sb.WriteString(text[i : i+size])
+				visible++
+			}
+			i += size
+			continue
+		}
+		i++
+	}
+	return sb.String(), stripped, false
+}
+
+// scanBannerEscape 返回 text[i] 开始的转义序列的结束位置、是否保留和是否是 SGR 序列
+func scanBannerEscape(text string, i int, policy string) (int, bool, bool) {
+	if i+1 >= len(text) {
+		return len(text), false, false
+	}
+	switch text[i+1] {
+	case '[':
+		j := i + 2
+		for j < len(text) && text[j] >= 0x20 && text[j] <= 0x3f {
+			j++
+		}
+		if j >= len(text) || text[j] < 0x40 || text[j] > 0x7e {
+			// 不完整或者无效的 CSI 序列
+			return j, false, false
+		}
+		sgr := text[j] == 'm' && strings.Trim(text[i+2:j], "0123456789;") == ""
+		keep := policy == BannerANSIPolicyAll || policy == BannerANSIPolicyColor && sgr
+		return j + 1, keep, sgr
+	case ']', 'P', '_', '^', 'X':
+		// 字符串序列以 BEL 或者 ESC \ 结束
+		for j := i + 2; j < len(text); j++ {
+			if text[j] == 0x07 {
+				return j + 1, false, false
+			}
+			if text[j] == 0x1b && j+1 < len(text) && text[j+1] == '\\' {
+				return j + 2, false, false
+			}
+		}
+		return len(text), false, false
+	}
+	return i + 2, false, false
+}
+
+// bannerMaxLength BANNER_MAX_LENGTH 横幅模板渲染后可见字符的数量上限
+func bannerMaxLength() int {
+	if n := config.GetConf().BannerMaxLength; n > 0 {
+		return n
+	}
+	return defaultBannerMaxLength
+}
+
+func sanitizeHeaderTitle(title string) string {
+	if title == "" {
+		return ""
+	}
+	return sanitizeBannerText("header title", title, false, maxHeaderTitleLength)
+}
+
+// sanitizeWindowTitle 窗口标题中不能包含任何转义序列，否则会提前结束标题序列
+func sanitizeWindowTitle(title string) string {
+	text, _, _ := sanitizeTerminalText(title, BannerANSIPolicyNone, false, maxHeaderTitleLength)
+	return text
+}
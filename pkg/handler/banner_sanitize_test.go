@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"testing"
+)
+
+func TestSanitizeTerminalText(t *testing.T) {
+	tests := []struct {
+		text      string
+		policy    string
+		multiline bool
+		maxLength int
+		want      string
+		stripped  bool
+		truncated bool
+	}{
+		{text: "\x1b[1;32mWelcome\x1b[0m\r\nline2", policy: BannerANSIPolicyColor, multiline: true,
+			want: "\x1b[1;32mWelcome\x1b[0m\r\nline2"},
+		{text: "\x1b[1;32mWelcome\x1b[0m", policy: BannerANSIPolicyNone, want: "Welcome", stripped: true},
+		// 清屏和光标移动只在 all 时保留
+		{text: "\x1b[2J\x1b[Hhi", policy: BannerANSIPolicyColor, want: "hi", stripped: true},
+		{text: "\x1b[2J\x1b[Hhi", policy: BannerANSIPolicyAll, want: "\x1b[2J\x1b[Hhi"},
+		// 修改标题、剪贴板的序列总是去除
+		{text: "a\x1b]2;fake\x07b\x1b]52;c;ZXZpbA==\x1b\\c", policy: BannerANSIPolicyAll, want: "abc", stripped: true},
+		{text: "JumpServer\r\nevil\x07\x08\u009b2J", policy: BannerANSIPolicyColor, want: "JumpServer evil2J", stripped: true},
+		{text: "over\rwrite", policy: BannerANSIPolicyColor, multiline: true, want: "overwrite", stripped: true},
+		{text: "\x1b[31m研发环境堡垒机", policy: BannerANSIPolicyColor, maxLength: 4,
+			want: "\x1b[31m研发环境\x1b[0m", truncated: true},
+		// 不完整的序列
+		{text: "title\x1b[31", policy: BannerANSIPolicyColor, want: "title", stripped: true},
+	}
+	for _, tt := range tests {
+		got, stripped, truncated := sanitizeTerminalText(tt.text, tt.policy, tt.multiline, tt.maxLength)
+		if got != tt.want || stripped != tt.stripped || truncated != tt.truncated {
+			t.Errorf("sanitizeTerminalText(%q, %s) = %q, %v, %v, want %q, %v, %v", tt.text, tt.policy,
+				got, stripped, truncated, tt.want, tt.stripped, tt.truncated)
+		}
+	}
+}
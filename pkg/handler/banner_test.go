@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gliderlabs/ssh"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+type bannerTestSession struct {
+	ssh.Session
+}
+
+func (s *bannerTestSession) Pty() (ssh.Pty, <-chan ssh.Window, bool) {
+	return ssh.Pty{Term: "xterm", Window: ssh.Window{Width: 80, Height: 24}}, nil, true
+}
+
+func TestDisplayBannerLineEnding(t *testing.T) {
+	conf := config.GetConf()
+	for _, item := range DisableableMenuItems() {
+		if item != "p" {
+			conf.DisabledMenuItems = append(conf.DisabledMenuItems, item)
+		}
+	}
+	setTestConfig(t, &conf)
+
+	theme, _ := utils.GetTheme(utils.ThemeNoColor)
+	h := &InteractiveHandler{
+		sess:         &WrapperSession{Sess: &bannerTestSession{}},
+		user:         &model.User{ID: "user-1", Name: "alice", Username: "alice"},
+		terminalConf: &model.TerminalConfig{},
+		i18nLang:     "en",
+		theme:        &theme,
+	}
+	want := "\x1b[H\x1b[2J\t\talice,  Welcome to use JumpServer open source fortress system\r\n\r\n" +
+		"\t 1) Enter part IP, Hostname, Comment to to search login if unique.\r\n" +
+		"\t 2) Enter / + IP, Hostname, Comment  to to search, such as: /192.168.\r\n" +
+		"\t 3) Enter // + Regular expression    to to filter the search result, such as: //^web-(prod|stg)-\\d+.\r\n" +
+		"\t 4) Enter @ + Platform               to to filter the assets by platform, such as: /web @linux, enter @ to clear.\r\n" +
+		"\t 5) Enter comment:, tag: + keyword   to to search the field only (also name:, ip:), such as: comment:payments.\r\n" +
+		"\t 6) Enter p                          to display the assets you have permission.\r\n" +
+		"\t 7) Enter ?                          to print help.\r\n" +
+		"\t 8) Enter q                          to exit.\r\n" +
+		"\tType ~. at the start of a line in a session to force-quit and return to the menu.\r\n" +
+		"\tPress ^]s in a session to scroll back through the output.\r\n"
+	tests := []struct {
+		ending string
+		want   string
+	}{
+		{ending: "", want: want},
+		{ending: BannerLineEndingCRLF, want: want},
+		{ending: "LF", want: strings.ReplaceAll(want, "\r\n", "\n")},
+	}
+	for _, tt := range tests {
+		conf.BannerLineEnding = tt.ending
+		var buf bytes.Buffer
+		h.displayBanner(&buf, h.user.Name, h.terminalConf)
+		if got := buf.String(); got != tt.want {
+			t.Errorf("displayBanner() with line ending %q = %q, want %q", tt.ending, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeLineEnding(t *testing.T) {
+	mixed := "a\r\nb\nc\r\n\r\n"
+	if got := normalizeLineEnding(mixed, "\r\n"); got != "a\r\nb\r\nc\r\n\r\n" {
+		t.Errorf("normalizeLineEnding(crlf) = %q", got)
+	}
+	if got := normalizeLineEnding(mixed, "\n"); got != "a\nb\nc\n\n" {
+		t.Errorf("normalizeLineEnding(lf) = %q", got)
+	}
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/proxy"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+// bellPolicyOptions 用户设置了响铃处理策略时覆盖节点的 BELL_POLICY
+func (h *InteractiveHandler) bellPolicyOptions(opts []proxy.ConnectionOption) []proxy.ConnectionOption {
+	if h.bellPolicy == "" {
+		return opts
+	}
+	return append(opts, proxy.ConnectBellPolicy(h.bellPolicy))
+}
+
+// setBellPolicy 处理 bell pass/count/suppress，保存到用户的偏好设置，没有参数时显示当前的策略
+func (h *InteractiveHandler) setBellPolicy(args string) {
+	lang := i18n.NewLang(h.i18nLang)
+	policy := strings.ToLower(strings.TrimSpace(args))
+	switch policy {
+	case "":
+		current := h.bellPolicy
+		if current == "" {
+			current = config.GetConf().BellPolicy
+		}
+		msg := fmt.Sprintf(lang.T("Bell policy is %s, enter bell pass, count or suppress to change it"),
+			proxy.NormalizeBellPolicy(current))
+		utils.IgnoreErrWriteString(h.term, utils.WrapperString(msg, utils.Green)+utils.CharNewLine)
+		return
+	case proxy.BellPolicyPass, proxy.BellPolicyCount, proxy.BellPolicySuppress:
+	default:
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(lang.T("Usage: bell pass|count|suppress")))
+		return
+	}
+	macros, err := h.loadMacros()
+	if err != nil {
+		logger.Errorf("Get user %s koko preference failed: %s", h.user.Name, err)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(lang.T("Core API failed")))
+		return
+	}
+	// 偏好设置按字段整体更新，需要带上已有的连接宏
+	pref := model.KokoPreference{Macros: macros, BellPolicy: policy}
+	if err = h.jmsService.UpdateUserKokoPreference(h.user.ID, &pref); err != nil {
+		logger.Errorf("Update user %s bell policy preference failed: %s", h.user.Name, err)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(lang.T("Core API failed")))
+		return
+	}
+	h.bellPolicy = policy
+	logger.Infof("User %s set bell policy: %s", h.user.Name, policy)
+	msg := fmt.Sprintf(lang.T("Bell policy set to %s, it takes effect in your next session"), policy)
+	utils.IgnoreErrWriteString(h.term, utils.WrapperString(msg, utils.Green)+utils.CharNewLine)
+}
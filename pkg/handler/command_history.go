@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/common"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+const (
+	historyPrompt = "[History]> "
+
+	// 终端配置为显示全部时，每页显示的历史命令数量
+	defaultHistoryPageSize = 20
+)
+
+/*
+searchCommandHistory 查询当前用户在 core 中的历史命令，只读且只包含该用户自己的命令。
+按页向 core 请求数据，n/b 翻页，其他输入返回菜单。
+*/
+
+func (h *InteractiveHandler) searchCommandHistory(search string) {
+	pageSize := getPageSize(h, h.terminalConf)
+	if pageSize == PAGESIZEALL {
+		pageSize = defaultHistoryPageSize
+	}
+	offset := 0
+	defer h.term.SetPrompt(h.prompt)
+	for {
+		total, ok := h.displayCommandHistoryPage(search, pageSize, offset)
+		if !ok || total <= pageSize {
+			return
+		}
+		h.term.SetPrompt(historyPrompt)
+		line, err := h.term.ReadLine()
+		if err != nil {
+			return
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "n":
+			if offset+pageSize < total {
+				offset += pageSize
+			}
+		case "b":
+			if offset -= pageSize; offset < 0 {
+				offset = 0
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (h *InteractiveHandler) displayCommandHistoryPage(search string, pageSize, offset int) (int, bool) {
+	lang := i18n.NewLang(h.i18nLang)
+	userName := h.user.String()
+	res, err := h.jmsService.SearchUserCommands(userName, search, pageSize, offset)
+	if err != nil {
+		logger.Errorf("User %s search command history failed: %s", h.user.Name, err)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(lang.T("Core API failed")))
+		return 0, false
+	}
+	records := make([]model.CommandRecord, 0, len(res.Data))
+	for i := range res.Data {
+		// 只显示当前用户自己的命令
+		if res.Data[i].User == userName {
+			records = append(records, res.Data[i])
+		}
+	}
+	if len(records) == 0 {
+		utils.IgnoreErrWriteString(h.term, utils.WrapperString(lang.T("No command history"), utils.Red))
+		utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+		return 0, false
+	}
+
+	idLabel := lang.T("ID")
+	dateLabel := lang.T("Date")
+	assetLabel := lang.T("Asset")
+	accountLabel := lang.T("Account")
+	commandLabel := lang.T("Command")
+	idFieldSize := len(idLabel)
+	dateFieldSize := len(dateLabel)
+	assetFieldSize := len(assetLabel)
+	accountFieldSize := len(accountLabel)
+	commandFieldSize := len(commandLabel)
+	data := make([]map[string]string, 0, len(records))
+	for i := range records {
+		item := &records[i]
+		idNumber := strconv.Itoa(offset + i + 1)
+		date := h.formatTime(time.Unix(item.Timestamp, 0))
+		command := joinMultiLineString(item.Input)
+		row := map[string]string{
+			"ID":      idNumber,
+			"Date":    date,
+			"Asset":   item.Asset,
+			"Account": item.Account,
+			"Command": command,
+		}
+		data = append(data, row)
+		if idFieldSize < len(idNumber) {
+			idFieldSize = len(idNumber)
+		}
+		if dateFieldSize < len(date) {
+			dateFieldSize = len(date)
+		}
+		if assetFieldSize < len(item.Asset) {
+			assetFieldSize = len(item.Asset)
+		}
+		if accountFieldSize < len(item.Account) {
+			accountFieldSize = len(item.Account)
+		}
+		if commandFieldSize < len(command) {
+			commandFieldSize = len(command)
+		}
+	}
+	if assetFieldSize > maxFieldSize {
+		assetFieldSize = maxFieldSize
+	}
+	if commandFieldSize > maxFieldSize {
+		commandFieldSize = maxFieldSize
+	}
+	totalPage := (res.Total + pageSize - 1) / pageSize
+	currentPage := offset/pageSize + 1
+	w, _ := h.GetPtySize()
+	caption := fmt.Sprintf(lang.T("Page: %d, Count: %d, Total Page: %d, Total Count: %d"),
+		currentPage, pageSize, totalPage, res.Total)
+	table := common.WrapperTable{
+		Fields: []string{"ID", "Date", "Asset", "Account", "Command"},
+		Labels: []string{idLabel, dateLabel, assetLabel, accountLabel, commandLabel},
+		FieldsSize: map[string][3]int{
+			"ID":      {idFieldSize, 0, 0},
+			"Date":    {dateFieldSize, 0, 0},
+			"Asset":   {assetFieldSize, 0, 0},
+			"Account": {accountFieldSize, 0, 0},
+			"Command": {commandFieldSize, 0, 0},
+		},
+		Data:        data,
+		TotalSize:   w,
+		Caption:     utils.WrapperString(caption, utils.Green),
+		TruncPolicy: common.TruncMiddle,
+	}
+	table.Initial()
+	_, _ = h.term.Write([]byte(utils.CharClear))
+	_, _ = h.term.Write([]byte(highlightKeyword(table.Display(), search)))
+	if res.Total > pageSize {
+		pageActionTip := lang.T("Page up: b	Page down: n")
+		pageTip := fmt.Sprintf(lang.T("Page %d/%d"), currentPage, totalPage)
+		backTip := lang.T("Press other keys to return")
+		tip := fmt.Sprintf("%s  %s	%s", pageTip, pageActionTip, backTip)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperString(tip, utils.Green))
+		utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+	}
+	searchHeader := fmt.Sprintf(lang.T("Search: %s"), search)
+	utils.IgnoreErrWriteString(h.term, utils.WrapperString(searchHeader, utils.Green))
+	utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+	return res.Total, true
+}
+
+// highlightKeyword 表格渲染后再高亮关键字，不影响列宽计算。已带颜色的表头和标题行不处理
+func highlightKeyword(table, keyword string) string {
+	if keyword == "" {
+		return table
+	}
+	lines := strings.Split(table, "\n")
+	for i := range lines {
+		if strings.Contains(lines[i], "\x1b[") {
+			continue
+		}
+		lines[i] = strings.ReplaceAll(lines[i], keyword, utils.WrapperString(keyword, utils.Yellow))
+	}
+	return strings.Join(lines, "\n")
+}
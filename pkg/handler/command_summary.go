@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/proxy"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+// commandSummaryOptions 用户开启了命令摘要时，会话结束后显示执行的命令
+func (h *InteractiveHandler) commandSummaryOptions(opts []proxy.ConnectionOption) []proxy.ConnectionOption {
+	if !h.commandSummary {
+		return opts
+	}
+	return append(opts, proxy.ConnectCommandSummary(h.userTimezone()))
+}
+
+// setCommandSummary 处理 summary on/off，保存到用户的偏好设置，没有参数时显示当前状态
+func (h *InteractiveHandler) setCommandSummary(args string) {
+	lang := i18n.NewLang(h.i18nLang)
+	var enabled bool
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "":
+		msg := lang.T("Command summary is off, enter summary on to show your commands after each session")
+		if h.commandSummary {
+			msg = lang.T("Command summary is on, enter summary off to turn it off")
+		}
+		utils.IgnoreErrWriteString(h.term, utils.WrapperString(msg, utils.Green)+utils.CharNewLine)
+		return
+	case "on":
+		enabled = true
+	case "off":
+		enabled = false
+	default:
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(lang.T("Usage: summary on|off")))
+		return
+	}
+	macros, err := h.loadMacros()
+	if err != nil {
+		logger.Errorf("Get user %s koko preference failed: %s", h.user.Name, err)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(lang.T("Core API failed")))
+		return
+	}
+	// 偏好设置按字段整体更新，需要带上已有的连接宏
+	pref := model.KokoPreference{Macros: macros, CommandSummary: &enabled}
+	if err = h.jmsService.UpdateUserKokoPreference(h.user.ID, &pref); err != nil {
+		logger.Errorf("Update user %s command summary preference failed: %s", h.user.Name, err)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(lang.T("Core API failed")))
+		return
+	}
+	h.commandSummary = enabled
+	logger.Infof("User %s set command summary: %t", h.user.Name, enabled)
+	msg := lang.T("Command summary turned off")
+	if enabled {
+		msg = lang.T("Command summary turned on")
+	}
+	utils.IgnoreErrWriteString(h.term, utils.WrapperString(msg, utils.Green)+utils.CharNewLine)
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+const (
+	keyTab = '\t'
+
+	// 候选项过多时只显示前面的部分
+	maxCompletionCandidates = 60
+)
+
+type completionCandidate struct {
+	text     string
+	helpText string
+}
+
+/*
+completeLine 菜单提示符下按 Tab 补全。空行时列出菜单命令；否则按前缀匹配菜单命令、
+已加载资产的名称和地址(/ 搜索时只匹配资产)。唯一匹配时直接补全，多个匹配时在提示符下方列出候选项，
+并补全到候选项的公共前缀。
+*/
+
+func (h *InteractiveHandler) completeLine(line string, pos int, key rune) (newLine string, newPos int, ok bool) {
+	if key != keyTab {
+		return "", 0, false
+	}
+	prefix, suffix := line[:pos], line[pos:]
+	if strings.TrimSpace(prefix) == "" {
+		h.displayCompletions(line, h.menuCompletions(""))
+		return line, pos, true
+	}
+	marker, word := "", prefix
+	if strings.HasPrefix(word, "/") {
+		marker, word = "/", strings.TrimPrefix(word, "/")
+	}
+	var candidates []completionCandidate
+	if marker == "" {
+		candidates = append(candidates, h.menuCompletions(word)...)
+	}
+	candidates = append(candidates, h.assetCompletions(word)...)
+	switch len(candidates) {
+	case 0:
+		return line, pos, true
+	case 1:
+		completed := marker + candidates[0].text
+		return completed + suffix, len(completed), true
+	}
+	h.displayCompletions(line, candidates)
+	texts := make([]string, 0, len(candidates))
+	for i := range candidates {
+		texts = append(texts, candidates[i].text)
+	}
+	// 大小写不同时公共前缀可能比已输入的内容短，保留用户的输入
+	if common := utils.LongestCommonPrefix(texts); len(common) > len(word) {
+		completed := marker + common
+		return completed + suffix, len(completed), true
+	}
+	return line, pos, true
+}
+
+// menuCompletions 当前用户可用的菜单命令，命令带参数时只补全命令本身
+func (h *InteractiveHandler) menuCompletions(word string) []completionCandidate {
+	lang := i18n.NewLang(h.i18nLang)
+	candidates := make([]completionCandidate, 0, len(menuRegistry))
+	bindings := activeKeyBindings()
+	for i := range menuRegistry {
+		entry := menuRegistry[i]
+		if !h.menuEntryEnabled(entry) {
+			continue
+		}
+		command := entry.command()
+		if command == "" {
+			continue
+		}
+		for _, key := range commandKeys(bindings, command) {
+			if strings.HasPrefix(key, strings.ToLower(word)) {
+				candidates = append(candidates, completionCandidate{text: key, helpText: lang.T(entry.helpText)})
+			}
+		}
+	}
+	return candidates
+}
+
+// assetCompletions 从已加载的资产(全部加载的资产和当前页的结果)中匹配名称和地址
+func (h *InteractiveHandler) assetCompletions(word string) []completionCandidate {
+	if h.selectHandler == nil {
+		return nil
+	}
+	word = strings.ToLower(word)
+	seen := make(map[string]struct{})
+	texts := make([]string, 0, 10)
+	addText := func(text string) {
+		if text == "" || !strings.HasPrefix(strings.ToLower(text), word) {
+			return
+		}
+		if _, ok := seen[text]; ok {
+			return
+		}
+		seen[text] = struct{}{}
+		texts = append(texts, text)
+	}
+	for _, assets := range [][]model.Asset{h.selectHandler.allLocalData, h.selectHandler.currentResult} {
+		for i := range assets {
+			addText(assets[i].Name)
+			addText(assets[i].Address)
+		}
+	}
+	sort.Strings(texts)
+	candidates := make([]completionCandidate, 0, len(texts))
+	for i := range texts {
+		candidates = append(candidates, completionCandidate{text: texts[i]})
+	}
+	return candidates
+}
+
+func (h *InteractiveHandler) displayCompletions(line string, candidates []completionCandidate) {
+	lang := i18n.NewLang(h.i18nLang)
+	var buf strings.Builder
+	buf.WriteString(h.prompt + line + "\n")
+	total := len(candidates)
+	if total > maxCompletionCandidates {
+		candidates = candidates[:maxCompletionCandidates]
+	}
+	var texts []string
+	for i := range candidates {
+		if candidates[i].helpText != "" {
+			buf.WriteString(fmt.Sprintf("  %-10s %s\n", candidates[i].text, candidates[i].helpText))
+			continue
+		}
+		texts = append(texts, candidates[i].text)
+	}
+	if len(texts) > 0 {
+		termWidth, _ := h.GetPtySize()
+		buf.WriteString(utils.Pretty(texts, termWidth) + "\n")
+	}
+	if total > maxCompletionCandidates {
+		msg := fmt.Sprintf(lang.T("%d candidates in total, only the first %d are shown"), total, maxCompletionCandidates)
+		buf.WriteString(utils.WrapperString(msg, utils.Yellow) + "\n")
+	}
+	utils.IgnoreErrWriteString(h.term, buf.String())
+}
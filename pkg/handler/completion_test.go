@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestCompleteLine(t *testing.T) {
+	h := &InteractiveHandler{
+		user:         &model.User{Role: "User"},
+		terminalConf: &model.TerminalConfig{},
+	}
+	h.selectHandler = &UserSelectHandler{
+		h: h,
+		allLocalData: []model.Asset{
+			{Name: "web-prod-01", Address: "192.168.1.10"},
+			{Name: "db-prod-01", Address: "192.168.1.20"},
+		},
+	}
+	tests := []struct {
+		line    string
+		want    string
+		wantPos int
+	}{
+		{line: "web", want: "web-prod-01", wantPos: 11},
+		{line: "/DB", want: "/db-prod-01", wantPos: 11},
+		{line: "192.168.1.2", want: "192.168.1.20", wantPos: 12},
+		{line: "bro", want: "bro", wantPos: 3}, // broadcast 只对管理员可用
+		{line: "xyz", want: "xyz", wantPos: 3},
+	}
+	for _, tt := range tests {
+		got, pos, ok := h.completeLine(tt.line, len(tt.line), keyTab)
+		if !ok || got != tt.want || pos != tt.wantPos {
+			t.Errorf("completeLine(%q) = %q, %d, %v, want %q, %d", tt.line, got, pos, ok, tt.want, tt.wantPos)
+		}
+	}
+	if _, _, ok := h.completeLine("web", 3, 'a'); ok {
+		t.Error("completeLine() should only handle the tab key")
+	}
+	if got := h.menuCompletions("q"); len(got) != 1 || got[0].text != "q" {
+		t.Errorf("menuCompletions(q) = %v, want q", got)
+	}
+}
@@ -33,7 +33,7 @@ asset_target: 			对应资产的ip 或者 id
 
 FormatNORMAL: 使用 account_username 和 asset_ip 的登录方式，即1和2的方式
 
-FormatToken:  使用 JMS-{token} 的方式登陆方式
+FormatToken:  使用 JMS-{token} 或一次性令牌 token-{id}.{secret} 的方式登陆方式
 
 */
 
@@ -173,6 +173,9 @@ func (d *DirectHandler) NewSFTPHandler() *SftpHandler {
 func (d *DirectHandler) Dispatch() {
 	_, winChan, _ := d.sess.Pty()
 	go d.WatchWinSizeChange(winChan)
+	if !d.checkSessionMFA() {
+		return
+	}
 	if d.opts.IsTokenConnection() {
 		d.LoginConnectToken(d.opts.tokenInfo)
 		return
@@ -333,7 +336,7 @@ func (d *DirectHandler) displayAssets(assets []model.Asset) {
 		row := make(map[string]string)
 		row["ID"] = strconv.Itoa(i + 1)
 		row["Hostname"] = assets[i].Name
-		row["Address"] = assets[i].Address
+		row["Address"] = common.DisplayHost(assets[i].Address)
 		row["Comment"] = joinMultiLineString(assets[i].Comment)
 		data[i] = row
 	}
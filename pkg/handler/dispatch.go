@@ -1,28 +1,43 @@
 package handler
 
 import (
-	"io"
 	"strconv"
 	"strings"
 
-	"github.com/jumpserver/koko/pkg/i18n"
-	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
 	"github.com/jumpserver/koko/pkg/logger"
 )
 
 func (h *InteractiveHandler) Dispatch() {
 	defer logger.Infof("Request %s: User %s stop interactive", h.sess.ID(), h.user.Name)
+	if h.mfaRejected {
+		return
+	}
 	var initialed bool
 	checkChan := make(chan bool)
 	go h.checkMaxIdleTime(checkChan)
+	if h.targetAsset != "" {
+		checkChan <- false
+		if h.proxyTargetAsset() {
+			return
+		}
+		initialed = true
+	}
 	for {
+		if h.applyPendingLang() {
+			// 连接资产时切换了语言，返回菜单后使用新的语言
+			h.displayHelp()
+		}
 		checkChan <- true
+		// 只在菜单提示符下补全，选择账号等其他输入不受影响
+		h.term.AutoCompleteCallback = h.completeLine
 		line, err := h.term.ReadLine()
+		h.term.AutoCompleteCallback = nil
 		if err != nil {
 			logger.Debugf("User %s close connect %s", h.user.Name, err)
 			break
 		}
 		checkChan <- false
+		h.applyPendingLang()
 		line = strings.TrimSpace(line)
 		if len(line) == 0 {
 			// 当 只是回车 空字符单独处理
@@ -36,6 +51,11 @@ func (h *InteractiveHandler) Dispatch() {
 			continue
 		}
 		initialed = true
+		line = expandKeyBinding(activeKeyBindings(), line)
+		if command := menuLineCommand(line); menuItemDisabled(command) {
+			h.displayMenuItemDisabled(command)
+			continue
+		}
 		switch len(line) {
 		case 1:
 			switch strings.ToLower(line) {
@@ -58,8 +78,7 @@ func (h *InteractiveHandler) Dispatch() {
 				h.selectHandler.MoveNextPage()
 				continue
 			case "g":
-				h.wg.Wait() // 等待node加载完成
-				h.displayNodeTree(h.nodes)
+				h.displayRootNodeTree()
 				continue
 			case "?":
 				h.displayHelp()
@@ -80,12 +99,109 @@ func (h *InteractiveHandler) Dispatch() {
 				h.selectHandler.SetSelectType(TypeK8s)
 				h.selectHandler.Search("")
 				continue
+			case "u":
+				h.selectHandler.SetSelectType(TypeAll)
+				h.selectHandler.Search("")
+				continue
+			case "f":
+				h.selectHandler.SetSelectType(TypeFavorite)
+				h.selectHandler.Search("")
+				continue
+			case "c":
+				h.selectHandler.SetSelectType(TypeRecentSession)
+				h.selectHandler.Search("")
+				continue
+			case "l":
+				h.selectHandler.reconnectLastAsset()
+				continue
+			case "a":
+				if h.user.IsAdmin() {
+					h.displayActiveSessions()
+					continue
+				}
+			case "m":
+				h.displayMacros()
+				continue
+			case "w":
+				if rdpGatewayEnabled(h) {
+					h.selectHandler.SetSelectType(TypeWindows)
+					h.selectHandler.Search("")
+					continue
+				}
 			}
 		default:
 			switch {
 			case line == "exit", line == "quit":
 				logger.Infof("user %s enter %s to exit", h.user.Name, line)
 				return
+			case line == "history":
+				h.searchCommandHistory("")
+				continue
+			case strings.HasPrefix(line, "history "):
+				h.searchCommandHistory(strings.TrimSpace(strings.TrimPrefix(line, "history ")))
+				continue
+			case strings.HasPrefix(line, "macro add "):
+				if h.addMacro(strings.TrimPrefix(line, "macro add ")) {
+					continue
+				}
+			case strings.HasPrefix(line, "macro del "):
+				if h.deleteMacro(strings.TrimPrefix(line, "macro del ")) {
+					continue
+				}
+			case strings.HasPrefix(line, "macro trust "):
+				if h.trustMacro(strings.TrimPrefix(line, "macro trust "), true) {
+					continue
+				}
+			case strings.HasPrefix(line, "macro untrust "):
+				if h.trustMacro(strings.TrimPrefix(line, "macro untrust "), false) {
+					continue
+				}
+			case line == "share" && sessionShareEnabled(h):
+				h.displayOwnSessions()
+				continue
+			case strings.HasPrefix(line, "share") && sessionShareEnabled(h):
+				if index, writable, ok := parseShareArgs(strings.TrimPrefix(line, "share")); ok {
+					if h.createSessionShare(index, writable) {
+						continue
+					}
+				}
+			case strings.HasPrefix(line, "join ") && sessionShareEnabled(h):
+				if args := strings.Fields(strings.TrimPrefix(line, "join ")); len(args) == 2 {
+					h.joinSessionShare(args[0], args[1])
+					continue
+				}
+			case strings.HasPrefix(line, "broadcast ") && h.user.IsAdmin():
+				if text := strings.TrimSpace(strings.TrimPrefix(line, "broadcast ")); text != "" {
+					h.broadcastMessage(text)
+					continue
+				}
+			case line == "attach" && sessionDetachEnabled(h):
+				h.displayDetachedSessions()
+				continue
+			case strings.HasPrefix(line, "attach") && sessionDetachEnabled(h):
+				if index, ok := parseAttachArgs(strings.TrimPrefix(line, "attach")); ok {
+					if h.attachSession(index) {
+						continue
+					}
+				}
+			case line == "summary":
+				h.setCommandSummary("")
+				continue
+			case strings.HasPrefix(line, "summary "):
+				h.setCommandSummary(strings.TrimPrefix(line, "summary "))
+				continue
+			case line == "bell", strings.HasPrefix(line, "bell "):
+				h.setBellPolicy(strings.TrimPrefix(line, "bell"))
+				continue
+			case line == "prompt", strings.HasPrefix(line, "prompt "):
+				h.handlePromptCommand(strings.TrimPrefix(line, "prompt"))
+				continue
+			case line == "whoami":
+				h.displayWhoami()
+				continue
+			case line == "reload" && h.user.IsAdmin():
+				h.reloadConfig()
+				continue
 			case strings.Index(line, "/") == 0:
 				if strings.Index(line[1:], "/") == 0 {
 					line = strings.TrimSpace(line[2:])
@@ -97,12 +213,38 @@ func (h *InteractiveHandler) Dispatch() {
 				continue
 			case strings.Index(line, "g") == 0:
 				searchWord := strings.TrimSpace(strings.TrimPrefix(line, "g"))
+				if searchWord == ".." {
+					h.moveUpNodeTree()
+					continue
+				}
 				if num, err := strconv.Atoi(searchWord); err == nil {
-					h.wg.Wait() // 等待node加载完成
-					if num > 0 && num <= len(h.nodes) {
-						selectedNode := h.nodes[num-1]
-						h.selectHandler.SetNode(selectedNode)
-						h.selectHandler.Search("")
+					if h.expandNodeTreeItem(num) {
+						continue
+					}
+				}
+			case strings.HasPrefix(line, "ssh"):
+				if num, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "ssh"))); err == nil {
+					if h.selectHandler.DisplaySSHCommand(num) {
+						continue
+					}
+				}
+			case strings.Index(line, "j") == 0:
+				searchWord := strings.TrimSpace(strings.TrimPrefix(line, "j"))
+				if num, err := strconv.Atoi(searchWord); err == nil {
+					h.selectHandler.MoveToPage(num)
+					continue
+				}
+			case strings.Index(line, "o") == 0 && sessionObserveEnabled(h):
+				searchWord := strings.TrimSpace(strings.TrimPrefix(line, "o"))
+				if num, err := strconv.Atoi(searchWord); err == nil {
+					if h.observeActiveSession(num) {
+						continue
+					}
+				}
+			case strings.Index(line, "a") == 0 && h.user.IsAdmin():
+				searchWord := strings.TrimSpace(strings.TrimPrefix(line, "a"))
+				if num, err := strconv.Atoi(searchWord); err == nil {
+					if h.killActiveSession(num) {
 						continue
 					}
 				}
@@ -117,29 +259,26 @@ func (h *InteractiveHandler) checkMaxIdleTime(checkChan <-chan bool) {
 	checkMaxIdleTime(maxIdleMinutes, h.i18nLang, h.user, h.sess.Sess, checkChan)
 }
 
-func (h *InteractiveHandler) ChangeLang() {
-	lang := i18n.NewLang(h.i18nLang)
-	i18nLang := h.i18nLang
-	switch lang {
-	case i18n.EN:
-		i18nLang = i18n.ZH.String()
-	case i18n.ZH:
-		i18nLang = i18n.JA.String()
-	case i18n.JA:
-		i18nLang = i18n.EN.String()
+// proxyTargetAsset 唯一匹配目标资产时直接连接，否则显示横幅和过滤后的资产列表
+func (h *InteractiveHandler) proxyTargetAsset() bool {
+	h.selectHandler.SetSelectType(TypeAsset)
+	if asset, ok := h.selectHandler.searchUnique(h.targetAsset); ok {
+		logger.Infof("Request %s: User %s direct connect target asset %s",
+			h.sess.ID(), h.user.Name, asset.String())
+		h.selectHandler.Proxy(asset)
+		return true
 	}
-	userLangGlobalStore.Store(h.user.ID, i18nLang)
-	h.i18nLang = i18nLang
+	h.displayHelp()
+	h.selectHandler.DisplayCurrentResult()
+	return false
 }
 
-func (h *InteractiveHandler) displayNodeTree(nodes model.NodeList) {
-	lang := i18n.NewLang(h.i18nLang)
-	tree, newNodes := ConstructNodeTree(nodes)
-	h.nodes = newNodes
-	_, _ = io.WriteString(h.term, "\n\r"+lang.T("Node: [ ID.Name(Asset amount) ]"))
-	_, _ = io.WriteString(h.term, tree.String())
-	_, err := io.WriteString(h.term, lang.T("Tips: Enter g+NodeID to display the host under the node, such as g1")+"\n\r")
-	if err != nil {
-		logger.Errorf("displayAssetNodes err: %s", err)
-	}
+func (h *InteractiveHandler) ChangeLang() {
+	h.langMu.Lock()
+	i18nLang := nextLang(h.i18nLang)
+	h.i18nLang = i18nLang
+	h.pendingLang = ""
+	h.langMu.Unlock()
+	userLangGlobalStore.Store(h.user.ID, i18nLang)
+	h.saveLangPreference(i18nLang)
 }
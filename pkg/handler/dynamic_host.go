@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/service"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/proxy"
+	"github.com/jumpserver/koko/pkg/srvconn"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+const dynamicHostResolveTimeout = 5 * time.Second
+
+/*
+动态主机：按需创建的临时主机不在 JumpServer 的资产列表中，用户搜索的 FQDN 属于
+DYNAMIC_HOST_ZONES 中配置的域名后缀时，koko 解析该域名并使用该域绑定的模板资产连接。
+模板资产是 JumpServer 中的普通资产，其账号作为该域的凭证模板，用户需要有模板资产的授权，
+ACL、命令过滤和网关等也使用模板资产的配置。
+*/
+
+type hostZone struct {
+	Suffix          string
+	TemplateAssetID string
+}
+
+// parseHostZones 解析 "域名后缀=模板资产ID" 格式的配置
+func parseHostZones(entries []string) []hostZone {
+	zones := make([]hostZone, 0, len(entries))
+	for _, entry := range entries {
+		suffix, assetID, ok := strings.Cut(entry, "=")
+		suffix = strings.Trim(strings.ToLower(strings.TrimSpace(suffix)), ".")
+		assetID = strings.TrimSpace(assetID)
+		if !ok || suffix == "" || assetID == "" {
+			logger.Errorf("Invalid dynamic host zone config: %s", entry)
+			continue
+		}
+		zones = append(zones, hostZone{Suffix: suffix, TemplateAssetID: assetID})
+	}
+	return zones
+}
+
+// matchHostZone host 为合法的主机名且是某个域的子域名时返回该域
+func matchHostZone(zones []hostZone, host string) (hostZone, bool) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if !isValidHostname(host) {
+		return hostZone{}, false
+	}
+	for i := range zones {
+		if strings.HasSuffix(host, "."+zones[i].Suffix) {
+			return zones[i], true
+		}
+	}
+	return hostZone{}, false
+}
+
+func isValidHostname(host string) bool {
+	if len(host) == 0 || len(host) > 253 || net.ParseIP(host) != nil {
+		return false
+	}
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, label := range labels {
+		if len(label) == 0 || len(label) > 63 {
+			return false
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			switch {
+			case c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-':
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func dynamicHostZones() []hostZone {
+	return parseHostZones(config.GetConf().DynamicHostZones)
+}
+
+// looksLikeFQDN 用于提示用户输入的域名不在允许的域中
+func looksLikeFQDN(key string) bool {
+	return isValidHostname(strings.TrimSuffix(strings.ToLower(key), "."))
+}
+
+func (u *UserSelectHandler) proxyDynamicHost(host string, zone hostZone) {
+	lang := i18n.NewLang(u.h.i18nLang)
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	assets, err := u.h.jmsService.GetUserPermAssetById(u.user.ID, zone.TemplateAssetID)
+	if err != nil || len(assets) != 1 {
+		logger.Errorf("User %s get dynamic host zone %s template asset %s failed: %v",
+			u.user.Name, zone.Suffix, zone.TemplateAssetID, err)
+		msg := fmt.Sprintf(lang.T("You don't have permission to connect hosts in domain %s"), zone.Suffix)
+		utils.IgnoreErrWriteString(u.h.term, utils.WrapperWarn(msg))
+		return
+	}
+	template := assets[0]
+	ctx, cancel := context.WithTimeout(u.h.sess.Context(), dynamicHostResolveTimeout)
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	cancel()
+	if err != nil || len(addrs) == 0 {
+		logger.Errorf("User %s resolve dynamic host %s failed: %v", u.user.Name, host, err)
+		msg := fmt.Sprintf(lang.T("Resolve host %s failed"), host)
+		utils.IgnoreErrWriteString(u.h.term, utils.WrapperWarn(msg))
+		return
+	}
+	protocols := template.FilterProtocols(func(p string) bool {
+		switch strings.ToLower(p) {
+		case srvconn.ProtocolSSH, srvconn.ProtocolTELNET:
+			return true
+		}
+		return false
+	})
+	protocol, ok := u.h.chooseAssetProtocol(protocols)
+	if !ok {
+		logger.Info("Not select protocol")
+		return
+	}
+	accounts, err := u.h.jmsService.GetAccountsByUserIdAndAssetId(u.user.ID, template.ID)
+	if err != nil {
+		logger.Errorf("Get asset accounts err: %s", err)
+		return
+	}
+	selectedAccount, ok := u.h.chooseAccount(&template, u.filterValidAccount(accounts))
+	if !ok {
+		logger.Info("Not select account")
+		return
+	}
+	req := service.SuperConnectTokenReq{
+		UserId:        u.user.ID,
+		AssetId:       template.ID,
+		Account:       selectedAccount.Alias,
+		Protocol:      protocol,
+		ConnectMethod: "ssh",
+	}
+	tokenInfo, ok := u.createConnectToken(&req)
+	if !ok {
+		return
+	}
+	connectToken, err := u.h.jmsService.GetConnectTokenInfo(tokenInfo.ID)
+	if err != nil {
+		logger.Errorf("connect token err: %s", err)
+		utils.IgnoreErrWriteString(u.h.term, lang.T("get connect token err"))
+		return
+	}
+	// 使用解析的地址连接，避免连接时再次解析得到不同的结果
+	connectToken.Asset.Name = host
+	connectToken.Asset.Address = addrs[0]
+	logger.Infof("User %s connect dynamic host %s(%s) with template asset %s",
+		u.user.Name, host, addrs[0], template.String())
+	proxyOpts := make([]proxy.ConnectionOption, 0, 4)
+	proxyOpts = append(proxyOpts, proxy.ConnectTokenAuthInfo(&connectToken))
+	proxyOpts = append(proxyOpts, proxy.ConnectI18nLang(u.h.i18nLang))
+	proxyOpts = append(proxyOpts, proxy.ConnectDynamicHost())
+	proxyOpts = u.h.commandSummaryOptions(proxyOpts)
+	proxyOpts = u.h.bellPolicyOptions(proxyOpts)
+	srv, err := proxy.NewServer(u.h.sess, u.h.jmsService, proxyOpts...)
+	if err != nil {
+		logger.Errorf("create proxy server err: %s", err)
+		return
+	}
+	srv.Proxy()
+}
@@ -0,0 +1,37 @@
+package handler
+
+import "testing"
+
+func TestMatchHostZone(t *testing.T) {
+	zones := parseHostZones([]string{
+		".Dev.Example.com=template-dev",
+		"invalid",
+		"staging.example.com = template-staging",
+	})
+	if len(zones) != 2 {
+		t.Fatalf("parseHostZones() = %+v, want 2 zones", zones)
+	}
+	tests := []struct {
+		host   string
+		want   string
+		wantOk bool
+	}{
+		{"web-01.dev.example.com", "template-dev", true},
+		{"WEB-01.Dev.Example.com.", "template-dev", true},
+		{"db.eu.staging.example.com", "template-staging", true},
+		{"dev.example.com", "", false},
+		{"web-01.evildev.example.com", "", false},
+		{"web-01.dev.example.com.evil.org", "", false},
+		{"web_01.dev.example.com", "", false},
+		{"-web.dev.example.com", "", false},
+		{"../etc/passwd.dev.example.com", "", false},
+		{"10.0.0.1", "", false},
+	}
+	for _, tt := range tests {
+		zone, ok := matchHostZone(zones, tt.host)
+		if ok != tt.wantOk || zone.TemplateAssetID != tt.want {
+			t.Errorf("matchHostZone(%q) = %q, %v, want %q, %v",
+				tt.host, zone.TemplateAssetID, ok, tt.want, tt.wantOk)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/jumpserver/koko/pkg/common"
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/service"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/proxy"
+)
+
+// execExitUnavailable 与 ssh 客户端一致，无法连接资产时返回 255，便于与命令本身的退出码区分
+const execExitUnavailable = 255
+
+// execAssetCommand 在 [账号@]资产 上执行 exec 请求中剩余的命令，返回命令的退出码
+func (s *Server) execAssetCommand(sess ssh.Session, user *model.User, args []string) int {
+	accountName, assetTarget := parseExecTarget(args[0])
+	command := execCommandLine(sess.RawCommand(), args)
+	stderr := sess.Stderr()
+	// exec 请求无法输入动态码，需要二次认证的用户不能绕过
+	if sessionMFARequired(user) {
+		logger.Warnf("User %s exec command on %s rejected: session MFA required", user.String(), args[0])
+		_, _ = io.WriteString(stderr, "session MFA required, please use interactive session\n")
+		return execExitError
+	}
+	asset, err := s.resolveExecAsset(user, assetTarget)
+	if err != nil {
+		logger.Errorf("User %s exec command on %s failed: %s", user.String(), args[0], err)
+		_, _ = io.WriteString(stderr, err.Error()+"\n")
+		return execExitError
+	}
+	accounts, err := s.jmsService.GetAccountsByUserIdAndAssetId(user.ID, asset.ID)
+	if err != nil {
+		logger.Errorf("User %s exec command get asset %s accounts failed: %s", user.String(), asset.String(), err)
+		_, _ = io.WriteString(stderr, fmt.Sprintf("get accounts failed: %s\n", err))
+		return execExitError
+	}
+	account, err := selectExecAccount(accounts, accountName)
+	if err != nil {
+		logger.Errorf("User %s exec command on %s failed: %s", user.String(), asset.String(), err)
+		_, _ = io.WriteString(stderr, err.Error()+"\n")
+		return execExitError
+	}
+	req := &service.SuperConnectTokenReq{
+		UserId:        user.ID,
+		AssetId:       asset.ID,
+		Account:       account.Alias,
+		Protocol:      model.ProtocolSSH,
+		ConnectMethod: model.ProtocolSSH,
+	}
+	tokenInfo, err := s.jmsService.CreateSuperConnectToken(req)
+	if err != nil {
+		msg := err.Error()
+		if tokenInfo.Detail != "" {
+			msg = tokenInfo.Detail
+		}
+		logger.Errorf("User %s exec command create connect token failed: %s", user.String(), msg)
+		_, _ = io.WriteString(stderr, msg+"\n")
+		return execExitError
+	}
+	connectToken, err := s.jmsService.GetConnectTokenInfo(tokenInfo.ID)
+	if err != nil {
+		logger.Errorf("User %s exec command get connect token failed: %s", user.String(), err)
+		_, _ = io.WriteString(stderr, err.Error()+"\n")
+		return execExitError
+	}
+	// 与交互会话一致检查连接权限，需要确认或者审批的资产无法在 exec 请求中处理
+	if !connectToken.Actions.EnableConnect() {
+		logger.Warnf("User %s exec command on %s rejected: no connect permission", user.String(), asset.String())
+		_, _ = io.WriteString(stderr, fmt.Sprintf("you don't have permission to connect %s\n", asset.Name))
+		return execExitError
+	}
+	if rule, ok := proxy.InteractiveOnlyRule(&connectToken.Asset); ok {
+		logger.Warnf("User %s exec command on %s rejected: asset matches the %s",
+			user.String(), asset.String(), rule)
+		_, _ = io.WriteString(stderr, fmt.Sprintf("asset %s matches the %s, please use interactive session\n",
+			asset.Name, rule))
+		return execExitError
+	}
+	sshClient, err := s.newTokenSSHClient(&connectToken)
+	if err != nil {
+		_, _ = io.WriteString(stderr, fmt.Sprintf("connect asset %s failed: %s\n", asset.Name, err))
+		return execExitUnavailable
+	}
+	defer sshClient.Close()
+	logger.Infof("User %s exec command on asset %s account %s: %s",
+		user.String(), asset.String(), account.String(), command)
+	return s.proxyAssetCommand(sess, sshClient, &connectToken, command)
+}
+
+// parseExecTarget 解析 [账号@]资产，账号中可能包含 @，以最后一个 @ 分隔
+func parseExecTarget(target string) (account, asset string) {
+	index := strings.LastIndex(target, "@")
+	if index < 0 {
+		return "", target
+	}
+	return target[:index], target[index+1:]
+}
+
+// execCommandLine 返回目标之后的原始命令，保留用户输入的引号
+func execCommandLine(rawCommand string, args []string) string {
+	rawCommand = strings.TrimSpace(rawCommand)
+	if strings.HasPrefix(rawCommand, args[0]) {
+		return strings.TrimSpace(rawCommand[len(args[0]):])
+	}
+	return strings.Join(args[1:], " ")
+}
+
+// resolveExecAsset 按 ID、名称或者地址查找唯一的支持 ssh 协议的授权资产
+func (s *Server) resolveExecAsset(user *model.User, target string) (model.Asset, error) {
+	var (
+		assets []model.Asset
+		err    error
+	)
+	if common.ValidUUIDString(target) {
+		assets, err = s.jmsService.GetUserPermAssetById(user.ID, target)
+	} else {
+		var res model.PaginationResponse
+		res, err = s.jmsService.GetUserPermsAssets(user.ID, model.PaginationParam{
+			Searches:  []string{target},
+			IsActive:  true,
+			Protocols: []string{model.ProtocolSSH},
+		})
+		assets = matchExecAssets(res.Data, target)
+	}
+	if err != nil {
+		return model.Asset{}, fmt.Errorf("get asset %s failed: %w", target, err)
+	}
+	matched := make([]model.Asset, 0, len(assets))
+	for i := range assets {
+		if assets[i].IsSupportProtocol(model.ProtocolSSH) {
+			matched = append(matched, assets[i])
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return model.Asset{}, fmt.Errorf("no ssh asset found for %s", target)
+	case 1:
+		return matched[0], nil
+	default:
+		return model.Asset{}, fmt.Errorf("multiple assets found for %s, please use the asset id", target)
+	}
+}
+
+// matchExecAssets 搜索是模糊匹配，只保留名称或者地址与 target 一致的资产
+func matchExecAssets(assets []model.Asset, target string) []model.Asset {
+	matched := make([]model.Asset, 0, len(assets))
+	for i := range assets {
+		if assets[i].Name == target || common.HostEqual(assets[i].Address, target) {
+			matched = append(matched, assets[i])
+		}
+	}
+	return matched
+}
+
+// selectExecAccount 选择执行命令的账号，没有指定账号时资产只能有一个自动登录的账号
+func selectExecAccount(accounts []model.PermAccount, username string) (model.PermAccount, error) {
+	var matched []model.PermAccount
+	if username != "" {
+		matched = GetMatchedAccounts(accounts, username)
+	} else {
+		for i := range accounts {
+			switch accounts[i].Username {
+			case "@INPUT", "@USER":
+			default:
+				matched = append(matched, accounts[i])
+			}
+		}
+	}
+	if len(matched) != 1 {
+		if username == "" {
+			return model.PermAccount{}, errors.New("must be unique auto login account, please specify the account")
+		}
+		return model.PermAccount{}, fmt.Errorf("must be unique account for %s", username)
+	}
+	switch matched[0].Username {
+	case "@INPUT", "@USER":
+		return model.PermAccount{}, fmt.Errorf("must be auto login account for %s", username)
+	}
+	return matched[0], nil
+}
+
+/*
+matchExecCommandACL 返回命令命中的第一个规则和执行的动作，没有命中时规则为 nil。
+与交互会话一致，过滤预览模式下拒绝、复核规则按告警处理，并记录会话生命周期日志。
+*/
+
+func (s *Server) matchExecCommandACL(sid string, tokeInfo *model.ConnectToken, rawStr string) (*model.CommandACL, model.CommandAction) {
+	acls := proxy.CommandFilterACLs(tokeInfo.CommandFilterACLs)
+	for i := range acls {
+		acl := &acls[i]
+		_, action, _ := acl.Match(rawStr)
+		switch action {
+		case model.ActionUnknown:
+			continue
+		case model.ActionReject, model.ActionReview:
+			if !config.GetConf().CommandFilterPreview {
+				return acl, action
+			}
+		default:
+			return acl, action
+		}
+		logger.Infof("Session %s: command filter preview, command %s would be %s by rule %s",
+			sid, rawStr, action, acl.Name)
+		logObj := model.SessionLifecycleLog{
+			Reason: fmt.Sprintf("command `%s` would be %s by rule %s", rawStr, action, acl.Name),
+			User:   tokeInfo.User.String(),
+		}
+		if err := s.jmsService.RecordSessionLifecycleLog(sid, model.CommandFilterPreview, logObj); err != nil {
+			logger.Errorf("Session %s: record command filter preview log failed: %s", sid, err)
+		}
+		return acl, model.ActionWarning
+	}
+	return nil, model.ActionUnknown
+}
+
+// commandOutput 保留命令记录中的输出，最多 maxSize 字节，stdout 和 stderr 会同时写入
+type commandOutput struct {
+	mu      sync.Mutex
+	buf     strings.Builder
+	maxSize int
+}
+
+func (o *commandOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if remain := o.maxSize - o.buf.Len(); remain > 0 {
+		if len(p) > remain {
+			o.buf.Write(p[:remain])
+		} else {
+			o.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func (o *commandOutput) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
+
+// execExitCode 返回资产上命令的退出码，没有退出码(连接断开、被信号中断)时返回 1
+func execExitCode(err error) int {
+	if err == nil {
+		return execExitOK
+	}
+	var exitErr *gossh.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitStatus() > 0 {
+		return exitErr.ExitStatus()
+	}
+	return execExitError
+}
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/service"
+)
+
+func TestExecAssetTarget(t *testing.T) {
+	account, asset := parseExecTarget("root@web01")
+	if account != "root" || asset != "web01" {
+		t.Errorf("parseExecTarget(root@web01) = %q, %q", account, asset)
+	}
+	account, asset = parseExecTarget("web01")
+	if account != "" || asset != "web01" {
+		t.Errorf("parseExecTarget(web01) = %q, %q", account, asset)
+	}
+	args := []string{"web01", "systemctl", "status", "nginx"}
+	if cmd := execCommandLine(`web01 'systemctl status nginx'`, args); cmd != `'systemctl status nginx'` {
+		t.Errorf("execCommandLine() = %q, want the raw command", cmd)
+	}
+
+	assets := []model.Asset{{ID: "1", Name: "web01", Address: "10.0.0.1"},
+		{ID: "2", Name: "web011", Address: "10.0.0.11"}, {ID: "3", Name: "db", Address: "2001:db8::1"}}
+	for target, want := range map[string]string{"web01": "1", "10.0.0.11": "2", "[2001:db8::1]": "3"} {
+		matched := matchExecAssets(assets, target)
+		if len(matched) != 1 || matched[0].ID != want {
+			t.Errorf("matchExecAssets(%s) = %+v, want asset %s", target, matched, want)
+		}
+	}
+
+	accounts := []model.PermAccount{{Username: "root", Alias: "root"}, {Username: "@INPUT", Alias: "@INPUT"}}
+	if got, err := selectExecAccount(accounts, ""); err != nil || got.Username != "root" {
+		t.Errorf("selectExecAccount() = %+v, %v, want the auto login account", got, err)
+	}
+	if _, err := selectExecAccount(accounts, "@INPUT"); err == nil {
+		t.Error("selectExecAccount(@INPUT) want error")
+	}
+	accounts = append(accounts, model.PermAccount{Username: "web", Alias: "web"})
+	if _, err := selectExecAccount(accounts, ""); err == nil {
+		t.Error("selectExecAccount() with multiple accounts want error")
+	}
+	if got, err := selectExecAccount(accounts, "web"); err != nil || got.Username != "web" {
+		t.Errorf("selectExecAccount(web) = %+v, %v", got, err)
+	}
+
+	if code := execExitCode(nil); code != execExitOK {
+		t.Errorf("execExitCode(nil) = %d", code)
+	}
+	if code := execExitCode(errors.New("closed")); code != execExitError {
+		t.Errorf("execExitCode(closed) = %d", code)
+	}
+}
+
+func TestMatchExecCommandACL(t *testing.T) {
+	var lifecycle string
+	jms := newTestJMService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "lifecycle_log") {
+			lifecycle = r.URL.Path
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("{}"))
+	}))
+	srv := &Server{jmsService: jms}
+	token := &model.ConnectToken{CommandFilterACLs: []model.CommandACL{{
+		ID: "acl-1", Name: "deny-rm", Action: model.ActionReject,
+		CommandGroups: []model.CommandFilterItem{{RePattern: `\brm\b`}},
+	}}}
+
+	if acl, action := srv.matchExecCommandACL("sid", token, "uptime"); acl != nil || action != model.ActionUnknown {
+		t.Errorf("uptime matched %v %s, want not matched", acl, action)
+	}
+	acl, action := srv.matchExecCommandACL("sid", token, "rm -rf /tmp/a")
+	if acl == nil || acl.ID != "acl-1" || action != model.ActionReject {
+		t.Errorf("rm matched %v %s, want rejected by acl-1", acl, action)
+	}
+
+	conf := config.GetConf()
+	conf.CommandFilterPreview = true
+	setTestConfig(t, &conf)
+	acl, action = srv.matchExecCommandACL("sid", token, "rm -rf /tmp/a")
+	if acl == nil || action != model.ActionWarning {
+		t.Errorf("preview rm matched %v %s, want warning", acl, action)
+	}
+	if lifecycle == "" {
+		t.Error("preview mode not record lifecycle log")
+	}
+}
+
+func TestExecAssetCommandInteractiveOnly(t *testing.T) {
+	conf := config.GetConf()
+	conf.ApprovalAssetRules = []string{"tier:core-db"}
+	setTestConfig(t, &conf)
+
+	const assetID = "4c3c5b4e-6b0a-4a4e-9a43-1d0c1f3c2e11"
+	asset := model.Asset{ID: assetID, Name: "crown-db", Address: "10.0.0.5",
+		Protocols: []model.Protocol{{Name: model.ProtocolSSH, Port: 22}}}
+	var actions model.Actions
+	jms := newTestJMService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/accounts/"):
+			_ = json.NewEncoder(w).Encode([]model.PermAccount{{Name: "root", Username: "root", Alias: "root"}})
+		case strings.HasPrefix(r.URL.Path, "/api/v1/perms/users/"):
+			_ = json.NewEncoder(w).Encode([]model.Asset{asset})
+		case r.URL.Path == service.SuperConnectTokenInfoURL:
+			_, _ = w.Write([]byte(`{"id": "token-1"}`))
+		default:
+			_ = json.NewEncoder(w).Encode(model.ConnectToken{Id: "token-1", Asset: asset, Actions: actions})
+		}
+	}))
+	srv := &Server{jmsService: jms}
+	user := &model.User{ID: "user-1", Name: "alice", Username: "alice"}
+	run := func() (int, string) {
+		sess := &execTestSession{command: []string{assetID, "uptime"}}
+		code := srv.execAssetCommand(sess, user, sess.command)
+		return code, sess.stderr.String()
+	}
+
+	// 没有连接权限
+	if code, stderr := run(); code != execExitError || !strings.Contains(stderr, "permission") {
+		t.Errorf("exec without connect action = %d %q, want permission denied", code, stderr)
+	}
+	// 需要审批的资产
+	actions = model.Actions{{Value: model.ActionConnect}}
+	asset.Labels = []model.Label{{Name: "tier", Value: "core-db"}}
+	if code, stderr := run(); code != execExitError || !strings.Contains(stderr, "approval rule tier:core-db") {
+		t.Errorf("exec on approval asset = %d %q, want rejected", code, stderr)
+	}
+	// 需要确认的资产
+	conf.ApprovalAssetRules = nil
+	conf.ConfirmAssetRules = []string{"tier:core-db"}
+	if code, stderr := run(); code != execExitError || !strings.Contains(stderr, "confirmation rule tier:core-db") {
+		t.Errorf("exec on confirmation asset = %d %q, want rejected", code, stderr)
+	}
+}
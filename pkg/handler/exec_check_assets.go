@@ -0,0 +1,286 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+
+	"github.com/jumpserver/koko/pkg/common"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/service"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/srvconn"
+)
+
+/*
+check-assets 命令检查 koko 能否连接资产，用于变更窗口前的预检，如 ssh koko check-assets --node <节点 ID> --auth。
+只有管理员可以使用，资产可以是 ID、名称或者地址，也可以检查节点下的所有资产。
+默认只检查 TCP 连接，--auth 时再使用资产唯一的自动登录账号认证 SSH 资产，认证成功后立即断开。
+不创建会话、不录像，只记录一条管理员的审计日志；所有资产都通过时退出码为 0。
+有网关的资产 koko 无法直接连接，不检查 TCP 连接，使用 --auth 时通过网关认证。
+*/
+
+const (
+	checkResultPass = "pass"
+	checkResultFail = "fail"
+	checkResultSkip = "skip"
+
+	defaultCheckConcurrency = 10
+	maxCheckConcurrency     = 100
+	defaultCheckTimeout     = 5
+)
+
+type assetCheckResult struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Address   string `json:"address"`
+	Protocol  string `json:"protocol"`
+	Port      int    `json:"port"`
+	TCP       string `json:"tcp"`
+	Auth      string `json:"auth"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+func (r *assetCheckResult) passed() bool {
+	return r.TCP != checkResultFail && r.Auth != checkResultFail
+}
+
+func (s *Server) execCheckAssets(sess ssh.Session, user *model.User, args []string) int {
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	nodeID := flags.String("node", "", "check all assets of the node")
+	auth := flags.Bool("auth", false, "authenticate ssh assets")
+	concurrency := flags.Int("concurrency", defaultCheckConcurrency, "max concurrent checks")
+	timeout := flags.Int("timeout", defaultCheckTimeout, "timeout of each asset in seconds")
+	if err := flags.Parse(args[1:]); err != nil || (flags.NArg() == 0 && *nodeID == "") ||
+		*concurrency <= 0 || *concurrency > maxCheckConcurrency || *timeout <= 0 {
+		_, _ = io.WriteString(sess.Stderr(), execUsage)
+		return execExitUsage
+	}
+	stderr := sess.Stderr()
+	if !user.IsAdmin() {
+		logger.Warnf("User %s exec %s rejected: not admin", user.String(), args[0])
+		_, _ = io.WriteString(stderr, "permission denied, only admin can check assets\n")
+		return execExitError
+	}
+	if sessionMFARequired(user) {
+		logger.Warnf("User %s exec %s rejected: session MFA required", user.String(), args[0])
+		_, _ = io.WriteString(stderr, "session MFA required, please use interactive session\n")
+		return execExitError
+	}
+	assets, err := s.resolveCheckAssets(user, *nodeID, flags.Args())
+	if err != nil {
+		logger.Errorf("User %s exec %s failed: %s", user.String(), args[0], err)
+		_, _ = io.WriteString(stderr, err.Error()+"\n")
+		return execExitError
+	}
+	checker := func(asset *model.Asset) assetCheckResult {
+		return s.checkAsset(user, asset, *auth, time.Duration(*timeout)*time.Second)
+	}
+	results := runAssetChecks(assets, *concurrency, checker)
+	failed := 0
+	for i := range results {
+		if !results[i].passed() {
+			failed++
+		}
+	}
+	// 管理员审计日志
+	logger.Infof("Admin %s ran check-assets (node %q, targets %v, auth %v) on %d assets: %d passed, %d failed",
+		user.String(), *nodeID, flags.Args(), *auth, len(results), len(results)-failed, failed)
+	if err = json.NewEncoder(sess).Encode(results); err != nil {
+		logger.Errorf("User %s exec %s write result failed: %s", user.String(), args[0], err)
+		return execExitError
+	}
+	if failed > 0 {
+		return execExitError
+	}
+	return execExitOK
+}
+
+// resolveCheckAssets 返回节点下的资产和指定的资产，按 ID 去重
+func (s *Server) resolveCheckAssets(user *model.User, nodeID string, targets []string) ([]model.Asset, error) {
+	var assets []model.Asset
+	if nodeID != "" {
+		res, err := s.jmsService.GetUserNodeAssets(user.ID, nodeID, model.PaginationParam{IsActive: true})
+		if err != nil {
+			return nil, fmt.Errorf("get assets of node %s failed: %w", nodeID, err)
+		}
+		assets = append(assets, res.Data...)
+	}
+	for _, target := range targets {
+		var (
+			matched []model.Asset
+			err     error
+		)
+		if common.ValidUUIDString(target) {
+			matched, err = s.jmsService.GetUserPermAssetById(user.ID, target)
+		} else {
+			var res model.PaginationResponse
+			res, err = s.jmsService.GetUserPermsAssets(user.ID, model.PaginationParam{
+				Searches: []string{target},
+				IsActive: true,
+			})
+			matched = matchExecAssets(res.Data, target)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("get asset %s failed: %w", target, err)
+		}
+		if len(matched) == 0 {
+			return nil, fmt.Errorf("no asset found for %s", target)
+		}
+		assets = append(assets, matched...)
+	}
+	seen := make(map[string]bool, len(assets))
+	unique := assets[:0]
+	for i := range assets {
+		if seen[assets[i].ID] {
+			continue
+		}
+		seen[assets[i].ID] = true
+		unique = append(unique, assets[i])
+	}
+	if len(unique) == 0 {
+		return nil, errors.New("no asset to check")
+	}
+	return unique, nil
+}
+
+// runAssetChecks 最多 concurrency 个资产同时检查，结果与资产的顺序一致
+func runAssetChecks(assets []model.Asset, concurrency int, check func(*model.Asset) assetCheckResult) []assetCheckResult {
+	results := make([]assetCheckResult, len(assets))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range assets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i] = check(&assets[i])
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+// checkProtocol 优先检查 ssh，其他资产检查第一个协议
+func checkProtocol(asset *model.Asset) (string, int) {
+	if asset.IsSupportProtocol(model.ProtocolSSH) {
+		return model.ProtocolSSH, asset.ProtocolPort(model.ProtocolSSH)
+	}
+	if len(asset.Protocols) > 0 {
+		return asset.Protocols[0].Name, asset.Protocols[0].Port
+	}
+	return "", 0
+}
+
+func (s *Server) checkAsset(user *model.User, asset *model.Asset, auth bool, timeout time.Duration) assetCheckResult {
+	protocol, port := checkProtocol(asset)
+	result := assetCheckResult{
+		ID: asset.ID, Name: asset.Name, Address: asset.Address, Protocol: protocol, Port: port,
+		TCP: checkResultSkip, Auth: checkResultSkip,
+	}
+	if protocol == "" || port <= 0 {
+		result.TCP = checkResultFail
+		result.Error = "no protocol port"
+		return result
+	}
+	start := time.Now()
+	if asset.Domain == nil || asset.Domain.ID == "" {
+		conn, err := srvconn.DialTCP(net.JoinHostPort(asset.Address, strconv.Itoa(port)), timeout)
+		if err != nil {
+			result.TCP = checkResultFail
+			result.Error = err.Error()
+			result.LatencyMs = time.Since(start).Milliseconds()
+			return result
+		}
+		_ = conn.Close()
+		result.TCP = checkResultPass
+	}
+	if auth && protocol == model.ProtocolSSH {
+		// TCP 连接和认证共用每个资产的超时时间
+		if err := s.probeSSHAuth(user, asset, timeout-time.Since(start)); err != nil {
+			result.Auth = checkResultFail
+			result.Error = err.Error()
+		} else {
+			result.Auth = checkResultPass
+		}
+	}
+	result.LatencyMs = time.Since(start).Milliseconds()
+	return result
+}
+
+/*
+probeSSHAuth 使用自动登录账号认证后立即断开，不打开会话。
+超时后取消未完成的请求和连接，创建的连接令牌在结束时失效。
+*/
+func (s *Server) probeSSHAuth(user *model.User, asset *model.Asset, timeout time.Duration) error {
+	if timeout <= 0 {
+		return errors.New("auth timeout")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		accounts, err := s.jmsService.GetAccountsByUserIdAndAssetId(user.ID, asset.ID)
+		if err != nil {
+			done <- fmt.Errorf("get accounts failed: %w", err)
+			return
+		}
+		account, err := selectExecAccount(accounts, "")
+		if err != nil {
+			done <- err
+			return
+		}
+		if err = ctx.Err(); err != nil {
+			done <- err
+			return
+		}
+		tokenInfo, err := s.jmsService.CreateSuperConnectToken(&service.SuperConnectTokenReq{
+			UserId:        user.ID,
+			AssetId:       asset.ID,
+			Account:       account.Alias,
+			Protocol:      model.ProtocolSSH,
+			ConnectMethod: model.ProtocolSSH,
+		})
+		if err != nil {
+			done <- fmt.Errorf("create connect token failed: %w", err)
+			return
+		}
+		defer func() {
+			if err2 := s.jmsService.ExpireConnectToken(tokenInfo.ID); err2 != nil {
+				logger.Errorf("Expire check connect token %s failed: %s", tokenInfo.ID, err2)
+			}
+		}()
+		connectToken, err := s.jmsService.GetConnectTokenInfo(tokenInfo.ID)
+		if err != nil {
+			done <- fmt.Errorf("get connect token failed: %w", err)
+			return
+		}
+		sshClient, err := s.newTokenSSHClient(&connectToken, srvconn.SSHClientContext(ctx))
+		if err != nil {
+			done <- err
+			return
+		}
+		_ = sshClient.Close()
+		done <- nil
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("auth timeout after %s", timeout)
+	}
+}
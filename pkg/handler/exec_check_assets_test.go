@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestExecCheckAssets(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	openPort := ln.Addr().(*net.TCPAddr).Port
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	_ = closed.Close()
+
+	jms := newTestJMService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode([]model.Asset{
+			{ID: "asset-1", Name: "open", Address: "127.0.0.1",
+				Protocols: []model.Protocol{{Name: "ssh", Port: openPort}}},
+			{ID: "asset-2", Name: "closed", Address: "127.0.0.1",
+				Protocols: []model.Protocol{{Name: "mysql", Port: closedPort}}},
+			{ID: "asset-3", Name: "gateway", Address: "10.0.0.3", Domain: &model.BaseDomain{ID: "domain-1"},
+				Protocols: []model.Protocol{{Name: "ssh", Port: 22}}},
+		})
+	}))
+	srv := &Server{jmsService: jms}
+	admin := &model.User{ID: "admin-1", Name: "admin", Username: "admin", Role: model.RoleAdmin}
+
+	sess := &execTestSession{command: []string{"check-assets", "--timeout", "2", "open", "gateway", "open"}}
+	if code := srv.runExecCommand(sess, admin); code != execExitOK {
+		t.Fatalf("check-assets exit = %d, stderr %q, output %s", code, sess.stderr.String(), sess.stdout.String())
+	}
+	var results []assetCheckResult
+	if err = json.Unmarshal(sess.stdout.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].TCP != checkResultPass || results[0].Auth != checkResultSkip ||
+		results[1].TCP != checkResultSkip {
+		t.Errorf("check-assets results = %+v, want open passed and gateway skipped", results)
+	}
+
+	sess = &execTestSession{command: []string{"check-assets", "open", "closed"}}
+	if code := srv.runExecCommand(sess, admin); code != execExitError {
+		t.Errorf("check-assets with closed port exit = %d, want %d", code, execExitError)
+	}
+	results = nil
+	if err = json.Unmarshal(sess.stdout.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[1].TCP != checkResultFail || results[1].Protocol != "mysql" {
+		t.Errorf("check-assets results = %+v, want closed failed", results)
+	}
+
+	sess = &execTestSession{command: []string{"check-assets", "open"}}
+	if code := srv.runExecCommand(sess, &model.User{ID: "user-1", Name: "alice"}); code != execExitError {
+		t.Errorf("check-assets by normal user exit = %d, want %d", code, execExitError)
+	}
+	for _, command := range [][]string{{"check-assets"}, {"check-assets", "--concurrency", "0", "open"}} {
+		sess = &execTestSession{command: command}
+		if code := srv.runExecCommand(sess, admin); code != execExitUsage {
+			t.Errorf("%v exit = %d, want usage", command, code)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gliderlabs/ssh"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/srvconn"
+)
+
+/*
+exec 命令：直接执行命令(如 ssh koko list-assets --format json)时，
+返回机器可读的结果后退出，不进入交互菜单，供自动化脚本使用。
+第一个参数不是内置命令时按 [账号@]资产 解析，在资产上执行后面的命令(如 ssh koko web01 'systemctl status nginx')，
+资产可以是 ID、名称或者地址，没有指定账号时使用资产唯一的自动登录账号。
+*/
+
+const (
+	execExitOK    = 0
+	execExitError = 1
+	execExitUsage = 2
+)
+
+const execUsage = `Usage: list-assets [--format json]
+       download-replay [--raw] <session-id>
+       last-output [session-id]
+       check-assets [--node <node-id>] [--auth] [--concurrency 10] [--timeout 5] [asset...]
+       [account@]asset command...
+
+Commands:
+  list-assets    list the permitted assets of current user
+  download-replay
+                 write the replay of your session to stdout, e.g. > session.cast
+  last-output    write the output of the last command in your session as plain text
+  check-assets   (admin) check the connectivity of assets and print a json report
+  [account@]asset command...
+                 run the command on the asset (id, name or address) and return its exit status
+`
+
+type execAsset struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	IP        string   `json:"ip,omitempty"`
+	Protocols []string `json:"protocols"`
+	Platform  string   `json:"platform"`
+}
+
+var execSubcommands = map[string]bool{
+	"list-assets":     true,
+	"download-replay": true,
+	"last-output":     true,
+	"check-assets":    true,
+}
+
+/*
+isExecRequest 判断是否按 exec 命令处理。没有申请 pty 时总是执行命令；
+申请了 pty(ssh -t koko web01) 时只有内置命令和 "资产 命令..." 按 exec 处理，
+单个参数作为目标资产进入交互菜单连接。
+*/
+
+func isExecRequest(args []string, isPty bool) bool {
+	if len(args) == 0 {
+		return false
+	}
+	if !isPty {
+		return true
+	}
+	return execSubcommands[args[0]] || len(args) >= 2
+}
+
+// runExecCommand 执行 exec 请求的命令，返回退出码
+func (s *Server) runExecCommand(sess ssh.Session, user *model.User) int {
+	args := sess.Command()
+	if len(args) > 0 {
+		switch args[0] {
+		case "list-assets":
+			return s.execListAssets(sess, user, args)
+		case "download-replay":
+			return s.execDownloadReplay(sess, user, args)
+		case "last-output":
+			return s.execLastOutput(sess, user, args)
+		case "check-assets":
+			return s.execCheckAssets(sess, user, args)
+		}
+	}
+	if len(args) < 2 {
+		logger.Infof("User %s exec unknown command: %s", user.String(), sess.RawCommand())
+		_, _ = io.WriteString(sess.Stderr(), execUsage)
+		return execExitUsage
+	}
+	return s.execAssetCommand(sess, user, args)
+}
+
+func (s *Server) execListAssets(sess ssh.Session, user *model.User, args []string) int {
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	format := flags.String("format", "json", "output format")
+	if err := flags.Parse(args[1:]); err != nil || flags.NArg() > 0 || *format != "json" {
+		_, _ = io.WriteString(sess.Stderr(), execUsage)
+		return execExitUsage
+	}
+	// exec 请求无法输入动态码，需要二次认证的用户不能绕过
+	if sessionMFARequired(user) {
+		logger.Warnf("User %s exec %s rejected: session MFA required", user.String(), args[0])
+		_, _ = io.WriteString(sess.Stderr(), "session MFA required, please use interactive session\n")
+		return execExitError
+	}
+	assets, err := s.listPermAssets(user)
+	if err != nil {
+		logger.Errorf("User %s exec %s get perm assets failed: %s", user.String(), args[0], err)
+		_, _ = io.WriteString(sess.Stderr(), fmt.Sprintf("get assets failed: %s\n", err))
+		return execExitError
+	}
+	if err = json.NewEncoder(sess).Encode(assets); err != nil {
+		logger.Errorf("User %s exec %s write result failed: %s", user.String(), args[0], err)
+		return execExitError
+	}
+	logger.Infof("User %s exec %s returned %d assets", user.String(), args[0], len(assets))
+	return execExitOK
+}
+
+// listPermAssets 与 p 菜单使用相同的过滤条件：激活的、koko 支持协议的授权资产
+func (s *Server) listPermAssets(user *model.User) ([]execAsset, error) {
+	reqParam := model.PaginationParam{
+		Order:     "name",
+		IsActive:  true,
+		Protocols: srvconn.SupportedProtocols(),
+	}
+	res, err := s.jmsService.GetUserPermsAssets(user.ID, reqParam)
+	if err != nil {
+		return nil, err
+	}
+	hideAddress := false
+	for _, field := range config.GetConf().HiddenFields {
+		if strings.EqualFold(strings.TrimSpace(field), "address") {
+			hideAddress = true
+		}
+	}
+	assets := make([]execAsset, 0, len(res.Data))
+	for i := range res.Data {
+		asset := &res.Data[i]
+		protocols := make([]string, 0, len(asset.Protocols))
+		for j := range asset.Protocols {
+			protocols = append(protocols, asset.Protocols[j].Name)
+		}
+		item := execAsset{
+			ID:        asset.ID,
+			Name:      asset.Name,
+			Protocols: protocols,
+			Platform:  asset.Platform.Name,
+		}
+		if !hideAddress {
+			item.IP = asset.Address
+		}
+		assets = append(assets, item)
+	}
+	return assets, nil
+}
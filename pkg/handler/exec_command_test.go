@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gliderlabs/ssh"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+type execTestSession struct {
+	ssh.Session
+	command []string
+	stdout  bytes.Buffer
+	stderr  bytes.Buffer
+}
+
+func (s *execTestSession) Command() []string { return s.command }
+
+func (s *execTestSession) RawCommand() string { return strings.Join(s.command, " ") }
+
+func (s *execTestSession) Write(p []byte) (int, error) { return s.stdout.Write(p) }
+
+func (s *execTestSession) Stderr() io.ReadWriter { return &s.stderr }
+
+func TestRunExecCommand(t *testing.T) {
+	var query string
+	jms := newTestJMService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode([]model.Asset{{
+			ID: "asset-1", Name: "web", Address: "10.0.0.1",
+			Protocols: []model.Protocol{{Name: "ssh", Port: 22}, {Name: "sftp", Port: 22}},
+			Platform:  model.BasePlatform{Name: "Linux"},
+		}})
+	}))
+	srv := &Server{jmsService: jms}
+	user := &model.User{ID: "user-1", Name: "alice", Username: "alice"}
+
+	sess := &execTestSession{command: []string{"list-assets", "--format", "json"}}
+	if code := srv.runExecCommand(sess, user); code != execExitOK {
+		t.Fatalf("list-assets exit = %d, stderr %q", code, sess.stderr.String())
+	}
+	if !strings.Contains(query, "is_active=true") || !strings.Contains(query, "protocols=") {
+		t.Errorf("perm assets query = %q, want active assets of supported protocols", query)
+	}
+	var assets []execAsset
+	if err := json.Unmarshal(sess.stdout.Bytes(), &assets); err != nil {
+		t.Fatalf("invalid json output %q: %s", sess.stdout.String(), err)
+	}
+	want := execAsset{ID: "asset-1", Name: "web", IP: "10.0.0.1", Protocols: []string{"ssh", "sftp"}, Platform: "Linux"}
+	if len(assets) != 1 || assets[0].ID != want.ID || assets[0].IP != want.IP ||
+		strings.Join(assets[0].Protocols, ",") != "ssh,sftp" || assets[0].Platform != want.Platform {
+		t.Errorf("list-assets output = %+v, want %+v", assets, want)
+	}
+
+	for _, command := range [][]string{{"list-users"}, {"list-assets", "--format", "yaml"}, {"list-assets", "extra"}} {
+		sess = &execTestSession{command: command}
+		if code := srv.runExecCommand(sess, user); code != execExitUsage {
+			t.Errorf("%v exit = %d, want %d", command, code, execExitUsage)
+		}
+		if !strings.Contains(sess.stderr.String(), "Usage:") || sess.stdout.Len() != 0 {
+			t.Errorf("%v output = %q, stderr %q, want usage", command, sess.stdout.String(), sess.stderr.String())
+		}
+	}
+}
+
+func TestIsExecRequest(t *testing.T) {
+	tests := []struct {
+		args  []string
+		isPty bool
+		want  bool
+	}{
+		{args: nil, want: false},
+		{args: []string{"web-prod-01"}, want: true},
+		{args: []string{"web-prod-01"}, isPty: true, want: false},
+		{args: []string{"list-assets"}, isPty: true, want: true},
+		{args: []string{"web-prod-01", "top"}, isPty: true, want: true},
+	}
+	for _, tt := range tests {
+		if got := isExecRequest(tt.args, tt.isPty); got != tt.want {
+			t.Errorf("isExecRequest(%q, pty %v) = %v, want %v", tt.args, tt.isPty, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/gliderlabs/ssh"
+
+	"github.com/jumpserver/koko/pkg/common"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/proxy"
+)
+
+/*
+last-output 命令输出用户最近一条命令的输出(去除终端控制符的纯文本)，如 ssh koko last-output > output.txt。
+默认使用用户最近有输出的会话，也可以指定会话 ID，只能获取自己在当前 koko 上的会话。
+*/
+
+func (s *Server) execLastOutput(sess ssh.Session, user *model.User, args []string) int {
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args[1:]); err != nil || flags.NArg() > 1 ||
+		(flags.NArg() == 1 && !common.ValidUUIDString(flags.Arg(0))) {
+		_, _ = io.WriteString(sess.Stderr(), execUsage)
+		return execExitUsage
+	}
+	stderr := sess.Stderr()
+	if sessionMFARequired(user) {
+		logger.Warnf("User %s exec %s rejected: session MFA required", user.String(), args[0])
+		_, _ = io.WriteString(stderr, "session MFA required, please use interactive session\n")
+		return execExitError
+	}
+	sid := flags.Arg(0)
+	output, err := proxy.LastCommandOutput(user.ID, sid)
+	if err != nil {
+		if errors.Is(err, proxy.ErrLastOutputNotFound) && sid != "" {
+			err = fmt.Errorf("session %s not found", sid)
+		}
+		logger.Infof("User %s exec %s failed: %s", user.String(), args[0], err)
+		_, _ = io.WriteString(stderr, err.Error()+"\n")
+		return execExitError
+	}
+	if output != "" {
+		output += "\n"
+	}
+	if _, err = io.WriteString(sess, output); err != nil {
+		logger.Errorf("User %s exec %s write result failed: %s", user.String(), args[0], err)
+		return execExitError
+	}
+	logger.Infof("User %s exec %s returned %d bytes", user.String(), args[0], len(output))
+	return execExitOK
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"bufio"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/gliderlabs/ssh"
+
+	"github.com/jumpserver/koko/pkg/common"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+/*
+download-replay 命令下载会话录像，如 ssh koko download-replay <会话 ID> > session.cast。
+用户只能下载自己的会话录像，管理员和审计员可以下载所有会话的录像。
+gzip 压缩的录像解压后输出，可以直接使用 asciinema 播放，--raw 输出 core 中保存的原始文件。
+下载和解压都是流式的，录像不会全部读入内存。
+*/
+
+func (s *Server) execDownloadReplay(sess ssh.Session, user *model.User, args []string) int {
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	raw := flags.Bool("raw", false, "output the original replay file")
+	if err := flags.Parse(args[1:]); err != nil || flags.NArg() != 1 || !common.ValidUUIDString(flags.Arg(0)) {
+		_, _ = io.WriteString(sess.Stderr(), execUsage)
+		return execExitUsage
+	}
+	sid := flags.Arg(0)
+	stderr := sess.Stderr()
+	if sessionMFARequired(user) {
+		logger.Warnf("User %s exec %s rejected: session MFA required", user.String(), args[0])
+		_, _ = io.WriteString(stderr, "session MFA required, please use interactive session\n")
+		return execExitError
+	}
+	termSession, err := s.jmsService.GetSessionById(sid)
+	if err != nil {
+		logger.Errorf("User %s download replay get session %s failed: %s", user.String(), sid, err)
+		_, _ = io.WriteString(stderr, fmt.Sprintf("session %s not found\n", sid))
+		return execExitError
+	}
+	if !canDownloadReplay(user, &termSession) {
+		logger.Warnf("User %s download replay of session %s rejected: not the session owner", user.String(), sid)
+		_, _ = io.WriteString(stderr, fmt.Sprintf("permission denied for the replay of session %s\n", sid))
+		return execExitError
+	}
+	info, err := s.jmsService.GetSessionReplayInfo(sid)
+	if err != nil || info.Src == "" {
+		logger.Errorf("User %s download replay of session %s failed: %v", user.String(), sid, err)
+		_, _ = io.WriteString(stderr, fmt.Sprintf("session %s has no replay\n", sid))
+		return execExitError
+	}
+	body, err := s.jmsService.DownloadSessionReplay(sess.Context(), info.Src)
+	if err != nil {
+		logger.Errorf("User %s download replay of session %s failed: %s", user.String(), sid, err)
+		_, _ = io.WriteString(stderr, fmt.Sprintf("download replay failed: %s\n", err))
+		return execExitError
+	}
+	defer body.Close()
+	var reader io.Reader = body
+	if !*raw {
+		if reader, err = playableReplayReader(body); err != nil {
+			logger.Errorf("User %s download replay of session %s failed: %s", user.String(), sid, err)
+			_, _ = io.WriteString(stderr, fmt.Sprintf("invalid replay file: %s\n", err))
+			return execExitError
+		}
+	}
+	written, err := io.Copy(sess, reader)
+	if err != nil {
+		logger.Errorf("User %s download replay of session %s failed after %d bytes: %s",
+			user.String(), sid, written, err)
+		_, _ = io.WriteString(stderr, fmt.Sprintf("download replay failed: %s\n", err))
+		return execExitError
+	}
+	logger.Infof("User %s downloaded replay of session %s, %d bytes", user.String(), sid, written)
+	return execExitOK
+}
+
+func canDownloadReplay(user *model.User, termSession *model.Session) bool {
+	return termSession.UserID == user.ID || user.IsAdmin() || user.IsAuditor()
+}
+
+// playableReplayReader gzip 压缩的录像返回解压的 reader，其他格式原样返回
+func playableReplayReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
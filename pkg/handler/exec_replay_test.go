@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+type replayTestSession struct {
+	execTestSession
+}
+
+func (s *replayTestSession) Context() context.Context { return context.Background() }
+
+func TestExecDownloadReplay(t *testing.T) {
+	const sid = "6f1d2a9c-3b4e-4f5a-8c7d-9e0f1a2b3c4d"
+	cast := `{"version": 2, "width": 80, "height": 24}` + "\n" + `[0.1, "o", "hello"]` + "\n"
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write([]byte(cast))
+	_ = zw.Close()
+	jms := newTestJMService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/terminal/sessions/" + sid + "/":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id": "` + sid + `", "user_id": "owner"}`))
+		case "/api/v1/terminal/sessions/" + sid + "/replay/":
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(model.ReplayInfo{Type: "asciicast", Src: "/media/replay/" + sid + ".cast.gz"})
+		case "/media/replay/" + sid + ".cast.gz":
+			_, _ = w.Write(gz.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	srv := &Server{jmsService: jms}
+
+	tests := []struct {
+		user    model.User
+		command []string
+		code    int
+		output  string
+	}{
+		{user: model.User{ID: "owner"}, command: []string{"download-replay", sid}, code: execExitOK, output: cast},
+		{user: model.User{ID: "owner"}, command: []string{"download-replay", "--raw", sid}, code: execExitOK, output: gz.String()},
+		{user: model.User{ID: "auditor", Role: model.RoleAuditor}, command: []string{"download-replay", sid}, code: execExitOK, output: cast},
+		{user: model.User{ID: "other"}, command: []string{"download-replay", sid}, code: execExitError},
+		{user: model.User{ID: "owner"}, command: []string{"download-replay", "not-a-session"}, code: execExitUsage},
+	}
+	for _, tt := range tests {
+		sess := &replayTestSession{execTestSession{command: tt.command}}
+		if code := srv.runExecCommand(sess, &tt.user); code != tt.code {
+			t.Errorf("user %s %v exit = %d, want %d, stderr %q", tt.user.ID, tt.command, code, tt.code, sess.stderr.String())
+		}
+		if got := sess.stdout.String(); got != tt.output {
+			t.Errorf("user %s %v output = %q, want %q", tt.user.ID, tt.command, got, tt.output)
+		}
+		if tt.code == execExitError && !strings.Contains(sess.stderr.String(), "permission denied") {
+			t.Errorf("user %s stderr = %q, want permission denied", tt.user.ID, sess.stderr.String())
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+/*
+首次登录提示：用户第一次登录 koko 时，在横幅前显示 FIRST_LOGIN_NOTICE_PATH 模板渲染的内容，
+显示后在 core 的用户偏好设置中标记为已读，之后不再显示。获取偏好设置失败时不显示。
+存在 <文件名>.<语言><扩展名> 的模板时(如 notice.en_US.tmpl)优先使用对应语言的模板。
+*/
+
+func localizedTemplatePath(path string, lang i18n.LanguageCode) string {
+	ext := filepath.Ext(path)
+	localized := strings.TrimSuffix(path, ext) + "." + lang.String() + ext
+	if _, err := os.Stat(localized); err == nil {
+		return localized
+	}
+	return path
+}
+
+func (h *InteractiveHandler) loadFirstLoginNotice(pref model.KokoPreference) {
+	path := config.GetConf().FirstLoginNoticePath
+	if path == "" || pref.FirstLoginNoticeSeen {
+		return
+	}
+	lang := i18n.NewLang(h.i18nLang)
+	ctx := BannerContext{User: h.user.Name, Time: time.Now().In(h.userTimezone()),
+		HeaderTitle: sanitizeHeaderTitle(h.terminalConf.HeaderTitle)}
+	notice, err := renderBannerTemplate(localizedTemplatePath(path, lang), ctx)
+	if err != nil {
+		logger.Errorf("Render first login notice %s failed: %s", path, err)
+		return
+	}
+	h.firstLoginNotice = notice
+}
+
+// popFirstLoginNotice 返回待显示的首次登录提示，并标记为已读
+func (h *InteractiveHandler) popFirstLoginNotice() string {
+	notice := h.firstLoginNotice
+	if notice == "" {
+		return ""
+	}
+	h.firstLoginNotice = ""
+	// 偏好设置按字段整体更新，需要带上已有的连接宏
+	pref := model.KokoPreference{Macros: h.macros, FirstLoginNoticeSeen: true}
+	go func() {
+		if err := h.jmsService.UpdateUserKokoPreference(h.user.ID, &pref); err != nil {
+			logger.Errorf("Mark user %s first login notice seen failed: %s", h.user.Name, err)
+		}
+	}()
+	return notice + utils.CharNewLine
+}
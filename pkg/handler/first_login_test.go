@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestFirstLoginNotice(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notice.tmpl")
+	if err := os.WriteFile(path, []byte("欢迎 {{.User}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notice.en_US.tmpl"), []byte("Welcome {{.User}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf := config.GetConf()
+	conf.FirstLoginNoticePath = path
+	setTestConfig(t, &conf)
+
+	pref := model.KokoPreference{Macros: []model.ConnectMacro{{Name: "deploy"}}}
+	updated := make(chan model.KokoPreference, 1)
+	var unreachable atomic.Bool
+	jms := newTestJMService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unreachable.Load() {
+			panic(http.ErrAbortHandler)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPatch {
+			var data model.KokoPreference
+			_ = json.NewDecoder(r.Body).Decode(&data)
+			updated <- data
+			pref = data
+		}
+		_ = json.NewEncoder(w).Encode(pref)
+	}))
+	newHandler := func() *InteractiveHandler {
+		return &InteractiveHandler{
+			user:         &model.User{ID: "user-1", Name: "alice"},
+			jmsService:   jms,
+			terminalConf: &model.TerminalConfig{},
+			i18nLang:     "en",
+		}
+	}
+
+	h := newHandler()
+	h.loadPreference(context.Background())
+	notice := h.popFirstLoginNotice()
+	if !strings.Contains(notice, "Welcome alice") {
+		t.Fatalf("first login notice = %q, want localized template", notice)
+	}
+	select {
+	case data := <-updated:
+		if !data.FirstLoginNoticeSeen || len(data.Macros) != 1 {
+			t.Errorf("updated preference = %+v, want seen and macros kept", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("first login notice not marked seen")
+	}
+	if notice = h.popFirstLoginNotice(); notice != "" {
+		t.Errorf("popFirstLoginNotice() again = %q, want empty", notice)
+	}
+
+	h = newHandler()
+	h.loadPreference(context.Background())
+	if h.firstLoginNotice != "" {
+		t.Errorf("first login notice = %q after seen, want empty", h.firstLoginNotice)
+	}
+
+	// core 不可用时跳过提示
+	unreachable.Store(true)
+	h = newHandler()
+	h.loadPreference(context.Background())
+	if h.firstLoginNotice != "" {
+		t.Errorf("first login notice = %q with core unreachable, want empty", h.firstLoginNotice)
+	}
+}
@@ -1,16 +1,17 @@
 package handler
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	"github.com/gliderlabs/ssh"
-	"github.com/xlab/treeprint"
 	"golang.org/x/term"
 
 	"github.com/jumpserver/koko/pkg/common"
@@ -19,6 +20,7 @@ import (
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/service"
 	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/session"
 	"github.com/jumpserver/koko/pkg/utils"
 )
 
@@ -32,6 +34,8 @@ func NewInteractiveHandler(sess ssh.Session, user *model.User, jmsService *servi
 		term:         vt,
 		jmsService:   jmsService,
 		terminalConf: &termConfig,
+		targetAsset:  strings.TrimSpace(sess.RawCommand()),
+		loginTime:    time.Now(),
 	}
 	handler.Initial()
 	return handler
@@ -85,7 +89,8 @@ type InteractiveHandler struct {
 
 	selectHandler *UserSelectHandler
 
-	nodes model.NodeList
+	// g 菜单的资产树导航状态
+	nodeTree nodeTreeView
 
 	assetLoadPolicy string
 
@@ -96,16 +101,101 @@ type InteractiveHandler struct {
 	terminalConf *model.TerminalConfig
 
 	i18nLang string
+
+	// ssh user@koko -t target 方式传入的资产搜索关键字
+	targetAsset string
+
+	// 管理员最近一次查看的活跃会话列表
+	activeSessions []*session.Session
+
+	// 最近一次列出的当前用户自己的会话，用于分享
+	ownSessions []*session.Session
+
+	// 当前的提示符，Tab 补全列出候选项时重新显示
+	prompt string
+
+	// 用户的连接宏，首次使用时从 core 加载
+	macros       []model.ConnectMacro
+	macrosLoaded bool
+
+	// 待显示的首次登录提示
+	firstLoginNotice string
+
+	// 面向用户显示时间使用的时区
+	timezone *time.Location
+
+	// 会话结束后显示命令摘要，来自用户的偏好设置
+	commandSummary bool
+
+	// 用户设置的响铃处理策略，为空时使用节点的配置
+	bellPolicy string
+
+	// 用户自定义的菜单提示符模板，为空时使用默认提示符
+	promptText     string
+	promptTemplate *template.Template
+	// 当前菜单提示符的类型，如 Opt、Host
+	promptKind string
+
+	// 本次登录的时间
+	loginTime time.Time
+
+	// 用户上一次的登录记录，没有记录时为空
+	lastLogin *model.LoginLog
+
+	// 用户有权限的资源数量，获取失败时为空
+	permSummary *permSummary
+
+	// 用户密码的过期时间，没有设置过期或获取失败时为零值
+	passwordExpiredAt time.Time
+
+	// 横幅和菜单使用的颜色主题
+	theme *utils.Theme
+
+	// 会话二次认证没有通过，不显示菜单直接断开
+	mfaRejected bool
+
+	// 快捷键切换的语言在读取输入的 goroutine 中记录，由 Dispatch 应用
+	langMu      sync.Mutex
+	pendingLang string
+}
+
+/*
+loadLoginInfo 并发加载登录时展示的偏好设置、上次登录、授权统计和密码过期信息，
+共用同一个等待时间，core 响应慢时最多阻塞 preferenceLoadTimeout。
+*/
+func (h *InteractiveHandler) loadLoginInfo() {
+	ctx, cancel := context.WithTimeout(context.Background(), preferenceLoadTimeout)
+	defer cancel()
+	loaders := []func(context.Context){h.loadPreference, h.loadLastLogin, h.loadPermSummary, h.loadPasswordExpiry}
+	var wg sync.WaitGroup
+	for i := range loaders {
+		wg.Add(1)
+		go func(load func(context.Context)) {
+			defer wg.Done()
+			load(ctx)
+		}(loaders[i])
+	}
+	wg.Wait()
 }
 
 func (h *InteractiveHandler) Initial() {
 	conf := config.GetConf()
-	if conf.ClientAliveInterval > 0 {
-		go h.keepSessionAlive(time.Duration(conf.ClientAliveInterval) * time.Second)
+	if interval, maxMissCount := clientKeepAlive(&conf, h.terminalConf); interval > 0 {
+		go h.keepSessionAlive(interval, maxMissCount)
 	}
 	h.assetLoadPolicy = strings.ToLower(conf.AssetLoadPolicy)
 	h.i18nLang = getUserDefaultLangCode(h.user)
-	h.displayHelp()
+	h.theme = h.resolveTheme(conf)
+	h.loadLoginInfo()
+	h.installLangSwitchKey()
+	if h.mfaRejected = !h.checkSessionMFA(); h.mfaRejected {
+		return
+	}
+	// 指定了目标资产时，仅在匹配不唯一的情况下才显示横幅
+	if h.targetAsset == "" {
+		h.displayHelp()
+		h.displayDetachedSessionTip()
+	}
 	hiddenFields := make(map[string]struct{})
 	for i := range conf.HiddenFields {
 		name := strings.TrimSpace(strings.ToLower(conf.HiddenFields[i]))
@@ -117,17 +207,22 @@ func (h *InteractiveHandler) Initial() {
 		pageInfo: &pageInfo{},
 
 		hiddenFields: hiddenFields,
+		pageCache:    newMenuAssetPageCache(),
 	}
 	switch h.assetLoadPolicy {
 	case "all":
+		// 资产过多时改为分页加载
+		res, err := h.jmsService.GetUserPermsAssets(h.user.ID, model.PaginationParam{PageSize: 1})
+		if err == nil && exceedMenuCacheSize(h.user.String(), res.Total) {
+			h.assetLoadPolicy = ""
+			return
+		}
 		allAssets, err := h.jmsService.GetAllUserPermsAssets(h.user.ID)
 		if err != nil {
 			logger.Errorf("Get all user perms assets failed: %s", err)
 		}
 		h.selectHandler.SetAllLocalData(allAssets)
 	}
-	h.firstLoadData()
-
 }
 
 func (h *InteractiveHandler) GetPtySize() (int, int) {
@@ -136,16 +231,13 @@ func (h *InteractiveHandler) GetPtySize() (int, int) {
 	return pty.Window.Width, pty.Window.Height
 }
 
-func (h *InteractiveHandler) firstLoadData() {
-	h.wg.Add(1)
-	go func() {
-		defer h.wg.Done()
-		h.loadUserNodes()
-	}()
+func (h *InteractiveHandler) setPrompt(prompt string) {
+	h.prompt = prompt
+	h.term.SetPrompt(prompt)
 }
 
 func (h *InteractiveHandler) displayHelp() {
-	h.term.SetPrompt("Opt> ")
+	h.useMenuPrompt("Opt")
 	h.displayBanner(h.sess, h.user.Name, h.terminalConf)
 }
 
@@ -166,26 +258,72 @@ func (h *InteractiveHandler) WatchWinSizeChange(winChan <-chan ssh.Window) {
 	}
 }
 
-func (h *InteractiveHandler) keepSessionAlive(keepAliveTime time.Duration) {
+/*
+clientKeepAlive 返回向用户发送 keepalive 的间隔和最多无回复的次数，与资产一侧相同使用终端配置的
+TERMINAL_KEEPALIVE_INTERVAL、TERMINAL_KEEPALIVE_COUNT_MAX，core 没有下发时使用 CLIENT_ALIVE_INTERVAL、RETRY_ALIVE_COUNT_MAX
+*/
+func clientKeepAlive(conf *config.Config, termConf *model.TerminalConfig) (time.Duration, int) {
+	interval, maxMissCount := conf.ClientAliveInterval, conf.RetryAliveCountMax
+	if termConf != nil && termConf.KeepAliveInterval > 0 {
+		interval = termConf.KeepAliveInterval
+	}
+	if termConf != nil && termConf.KeepAliveCountMax > 0 {
+		maxMissCount = termConf.KeepAliveCountMax
+	}
+	return time.Duration(interval) * time.Second, maxMissCount
+}
+
+// keepSessionAlive 定时向用户发送 keepalive，连续 maxMissCount 次没有回复则关闭会话
+func (h *InteractiveHandler) keepSessionAlive(keepAliveTime time.Duration, maxMissCount int) {
 	t := time.NewTicker(keepAliveTime)
 	defer t.Stop()
+	var (
+		waiting   bool
+		missCount int
+	)
+	replyChan := make(chan error, 1)
 	for {
 		select {
 		case <-h.sess.Sess.Context().Done():
 			return
-		case <-t.C:
-			_, err := h.sess.Sess.SendRequest("keepalive@openssh.com", true, nil)
+		case err := <-replyChan:
+			waiting = false
 			if err != nil {
+				missCount++
 				logger.Errorf("Request %s: Send user %s keepalive packet failed: %s",
 					h.sess.Uuid, h.user.Name, err)
-				continue
+				break
 			}
+			missCount = 0
 			logger.Debugf("Request %s: Send user %s keepalive packet success", h.sess.Uuid, h.user.Name)
+		case <-t.C:
+			if waiting {
+				missCount++
+				logger.Errorf("Request %s: User %s keepalive packet no reply %d times",
+					h.sess.Uuid, h.user.Name, missCount)
+				break
+			}
+			waiting = true
+			go func() {
+				_, err := h.sess.Sess.SendRequest("keepalive@openssh.com", true, nil)
+				replyChan <- err
+			}()
+		}
+		if maxMissCount > 0 && missCount >= maxMissCount {
+			logger.Errorf("Request %s: User %s keepalive miss %d times, close session",
+				h.sess.Uuid, h.user.Name, missCount)
+			_ = h.sess.Sess.Close()
+			return
 		}
 	}
 }
 
-func (h *InteractiveHandler) chooseAccount(permAccounts []model.PermAccount) (model.PermAccount, bool) {
+/*
+chooseAccount 资产有多个授权账号时列出账号供用户选择，只有一个账号时直接使用。
+本次连接中记住每个资产最后选择的账号，再次连接时直接回车使用该账号。
+*/
+
+func (h *InteractiveHandler) chooseAccount(asset *model.Asset, permAccounts []model.PermAccount) (model.PermAccount, bool) {
 	lang := i18n.NewLang(h.i18nLang)
 	length := len(permAccounts)
 	switch length {
@@ -199,6 +337,11 @@ func (h *InteractiveHandler) chooseAccount(permAccounts []model.PermAccount) (mo
 	}
 	displayAccounts := model.PermAccountList(permAccounts)
 	sort.Sort(displayAccounts)
+	userHandler := h.selectHandler
+	lastIndex := -1
+	if userHandler != nil {
+		lastIndex = lastAccountIndex(displayAccounts, userHandler.lastAccount(asset))
+	}
 
 	idLabel := lang.T("ID")
 	nameLabel := lang.T("Name")
@@ -229,15 +372,23 @@ func (h *InteractiveHandler) chooseAccount(permAccounts []model.PermAccount) (mo
 		TruncPolicy: common.TruncMiddle,
 	}
 	table.Initial()
-	userHandler := h.selectHandler
 
-	h.term.SetPrompt("ID> ")
-	selectTip := fmt.Sprintf(lang.T("Tips: Enter asset[%s] account ID"), userHandler.selectedAsset.String())
+	h.setPrompt("ID> ")
+	selectTip := fmt.Sprintf(lang.T("Tips: Enter asset[%s] account ID"), asset.String())
+	var lastTip string
+	if lastIndex >= 0 {
+		lastTip = fmt.Sprintf(lang.T("Press Enter to use the last selected account %s"),
+			displayAccounts[lastIndex].String())
+	}
 	backTip := lang.T("Back: B/b")
 	for i := 0; i < 3; i++ {
 		utils.IgnoreErrWriteString(h.term, table.Display())
 		utils.IgnoreErrWriteString(h.term, utils.WrapperString(selectTip, utils.Green))
 		utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+		if lastTip != "" {
+			utils.IgnoreErrWriteString(h.term, utils.WrapperString(lastTip, utils.Green))
+			utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+		}
 		utils.IgnoreErrWriteString(h.term, utils.WrapperString(backTip, utils.Green))
 		utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
 		line, err := h.term.ReadLine()
@@ -251,11 +402,14 @@ func (h *InteractiveHandler) chooseAccount(permAccounts []model.PermAccount) (mo
 			logger.Info("select account cancel")
 			return model.PermAccount{}, false
 		case "":
-			continue
+			if lastIndex < 0 {
+				continue
+			}
+			return h.rememberAccount(asset, displayAccounts[lastIndex]), true
 		}
 		if num, err2 := strconv.Atoi(line); err2 == nil {
 			if num > 0 && num <= len(displayAccounts) {
-				return displayAccounts[num-1], true
+				return h.rememberAccount(asset, displayAccounts[num-1]), true
 			}
 		}
 	}
@@ -306,7 +460,7 @@ func (h *InteractiveHandler) chooseAssetProtocol(protocols []string) (string, bo
 	}
 	table.Initial()
 
-	h.term.SetPrompt("ID> ")
+	h.setPrompt("ID> ")
 	selectTip := lang.T("Tips: Enter protocol ID")
 	backTip := lang.T("Back: B/b")
 	for i := 0; i < 3; i++ {
@@ -351,17 +505,21 @@ func (h *InteractiveHandler) refreshAssetsAndNodesData() {
 			return
 		}
 		if h.assetLoadPolicy == "all" {
+			if exceedMenuCacheSize(h.user.String(), len(allAssets)) {
+				h.assetLoadPolicy = ""
+				h.selectHandler.SetAllLocalData(nil)
+				return
+			}
 			h.selectHandler.SetAllLocalData(allAssets)
 		}
 	}()
 	go func() {
 		defer h.wg.Done()
-		nodes, err := h.jmsService.RefreshUserNodes(h.user.ID)
-		if err != nil {
+		// 重建用户的授权节点树，已展开的节点在下次进入时重新加载
+		if _, err := h.jmsService.RefreshUserNodes(h.user.ID); err != nil {
 			logger.Errorf("Refresh user nodes error: %s", err)
 			return
 		}
-		h.nodes = nodes
 		tConfig, err := h.jmsService.GetTerminalConfig()
 		if err != nil {
 			logger.Errorf("Refresh user terminal config error: %s", err)
@@ -370,6 +528,12 @@ func (h *InteractiveHandler) refreshAssetsAndNodesData() {
 		h.terminalConf = &tConfig
 	}()
 	h.wg.Wait()
+	h.nodeTree.reset()
+	h.selectHandler.pageCache.Clear()
+	h.selectHandler.clearFilterCache()
+	ctx, cancel := context.WithTimeout(context.Background(), preferenceLoadTimeout)
+	h.loadPermSummary(ctx)
+	cancel()
 	lang := i18n.NewLang(h.i18nLang)
 	_, err := io.WriteString(h.term, lang.T("Refresh done")+"\n\r")
 	if err != nil {
@@ -377,15 +541,6 @@ func (h *InteractiveHandler) refreshAssetsAndNodesData() {
 	}
 }
 
-func (h *InteractiveHandler) loadUserNodes() {
-	nodes, err := h.jmsService.GetUserNodes(h.user.ID)
-	if err != nil {
-		logger.Errorf("Get user nodes error: %s", err)
-		return
-	}
-	h.nodes = nodes
-}
-
 func getPageSize(h *InteractiveHandler, termConf *model.TerminalConfig) int {
 	var (
 		pageSize  int
@@ -403,6 +558,10 @@ func getPageSize(h *InteractiveHandler, termConf *model.TerminalConfig) int {
 	default:
 		if value, err := strconv.Atoi(AssetListPageSize); err == nil {
 			pageSize = value
+			// 终端高度不足以显示一页时，按终端高度截断
+			if maxSize := height - minHeight; maxSize > 0 && pageSize > maxSize {
+				pageSize = maxSize
+			}
 		} else {
 			pageSize = height - minHeight
 		}
@@ -412,76 +571,3 @@ func getPageSize(h *InteractiveHandler, termConf *model.TerminalConfig) int {
 	}
 	return pageSize
 }
-
-func ConstructNodeTree(assetNodes []model.Node) (treeprint.Tree, []model.Node) {
-	model.SortNodesByKey(assetNodes)
-	rootTree := treeprint.New()
-	newNodes := make([]model.Node, 0, len(assetNodes))
-	newNodes = constructDisplayTree(rootTree, convertToDisplayTrees(assetNodes), newNodes)
-	return rootTree, newNodes
-}
-
-func constructDisplayTree(tree treeprint.Tree, rootNodes []*displayTree, newNodes []model.Node) []model.Node {
-	for i := 0; i < len(rootNodes); i++ {
-		subTree := tree.AddBranch(fmt.Sprintf("%d.%s(%s)",
-			len(newNodes)+1, rootNodes[i].node.Name,
-			strconv.Itoa(rootNodes[i].node.AssetsAmount)))
-		newNodes = append(newNodes, rootNodes[i].node)
-		if len(rootNodes[i].subTrees) > 0 {
-			sort.Sort(nodeTrees(rootNodes[i].subTrees))
-			newNodes = constructDisplayTree(subTree, rootNodes[i].subTrees, newNodes)
-		}
-	}
-	return newNodes
-}
-
-func convertToDisplayTrees(assetNodes []model.Node) []*displayTree {
-	var rootNodeTrees []*displayTree
-	nodeTreeMap := make(map[string]*displayTree)
-	for i := 0; i < len(assetNodes); i++ {
-		currentTree := displayTree{
-			Key:  assetNodes[i].Key,
-			node: assetNodes[i],
-		}
-		r := strings.LastIndex(assetNodes[i].Key, ":")
-		if r < 0 {
-			rootNodeTrees = append(rootNodeTrees, &currentTree)
-			nodeTreeMap[assetNodes[i].Key] = &currentTree
-			continue
-		}
-		nodeTreeMap[assetNodes[i].Key] = &currentTree
-		parentKey := assetNodes[i].Key[:r]
-
-		parentTree, ok := nodeTreeMap[parentKey]
-		if !ok {
-			rootNodeTrees = append(rootNodeTrees, &currentTree)
-			continue
-		}
-		parentTree.AddSubNode(&currentTree)
-	}
-	return rootNodeTrees
-}
-
-type displayTree struct {
-	Key      string
-	node     model.Node
-	subTrees []*displayTree
-}
-
-func (t *displayTree) AddSubNode(sub *displayTree) {
-	t.subTrees = append(t.subTrees, sub)
-}
-
-type nodeTrees []*displayTree
-
-func (l nodeTrees) Len() int {
-	return len(l)
-}
-
-func (l nodeTrees) Swap(i, j int) {
-	l[i], l[j] = l[j], l[i]
-}
-
-func (l nodeTrees) Less(i, j int) bool {
-	return l[i].node.Name < l[j].node.Name
-}
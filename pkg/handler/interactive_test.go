@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestClientKeepAlive(t *testing.T) {
+	conf := config.Config{ClientAliveInterval: 30, RetryAliveCountMax: 3}
+	tests := []struct {
+		termConf *model.TerminalConfig
+		interval time.Duration
+		count    int
+	}{
+		{termConf: nil, interval: 30 * time.Second, count: 3},
+		{termConf: &model.TerminalConfig{}, interval: 30 * time.Second, count: 3},
+		{termConf: &model.TerminalConfig{KeepAliveInterval: 15, KeepAliveCountMax: 5}, interval: 15 * time.Second, count: 5},
+	}
+	for _, tt := range tests {
+		interval, count := clientKeepAlive(&conf, tt.termConf)
+		if interval != tt.interval || count != tt.count {
+			t.Errorf("clientKeepAlive(%+v) = %s, %d, want %s, %d", tt.termConf, interval, count, tt.interval, tt.count)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/proxy"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+var (
+	// pod、namespace 和容器的名称为 DNS 标签或子域名
+	k8sNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9.-]*[a-z0-9])?$`)
+
+	shellPattern = regexp.MustCompile(`^[A-Za-z0-9_./-]+$`)
+)
+
+/*
+chooseK8sPod 连接 kubernetes 资产时可以输入 namespace/pod[/container] 直接进入 pod 的容器，
+直接回车使用 kubectl 命令行。未指定容器时在连接时获取 pod 的容器，只有一个容器时自动选择。
+shell 为空时依次尝试 bash、sh，指定的 shell 不存在时同样回退并提示用户。
+*/
+
+func (h *InteractiveHandler) chooseK8sPod() (*proxy.ContainerInfo, bool) {
+	lang := i18n.NewLang(h.i18nLang)
+	podTip := lang.T("Tips: Enter namespace/pod[/container] to exec into the pod, press Enter to use kubectl")
+	backTip := lang.T("Back: B/b")
+	h.setPrompt("Pod> ")
+	var info *proxy.ContainerInfo
+	for info == nil {
+		utils.IgnoreErrWriteString(h.term, utils.WrapperString(podTip, utils.Green))
+		utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperString(backTip, utils.Green))
+		utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+		line, err := h.term.ReadLine()
+		if err != nil {
+			logger.Errorf("select k8s pod err: %s", err)
+			return nil, false
+		}
+		line = strings.TrimSpace(line)
+		switch strings.ToLower(line) {
+		case "q", "b", "quit", "exit", "back":
+			logger.Info("select k8s pod cancel")
+			return nil, false
+		case "":
+			return nil, true
+		}
+		target, ok := parseK8sPodTarget(line)
+		if !ok {
+			utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(lang.T("Invalid pod, format: namespace/pod[/container]")))
+			continue
+		}
+		info = &target
+	}
+	shellTip := lang.T("Tips: Enter the shell to exec, press Enter to use bash and fallback to sh")
+	h.setPrompt("Shell> ")
+	for {
+		utils.IgnoreErrWriteString(h.term, utils.WrapperString(shellTip, utils.Green))
+		utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+		line, err := h.term.ReadLine()
+		if err != nil {
+			logger.Errorf("select k8s pod shell err: %s", err)
+			return nil, false
+		}
+		shell := strings.TrimSpace(line)
+		if shell == "" || shellPattern.MatchString(shell) {
+			info.Shell = shell
+			return info, true
+		}
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(lang.T("Invalid shell")))
+	}
+}
+
+// parseK8sPodTarget 解析 namespace/pod[/container]
+func parseK8sPodTarget(target string) (proxy.ContainerInfo, bool) {
+	parts := strings.Split(target, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return proxy.ContainerInfo{}, false
+	}
+	for i := range parts {
+		if !k8sNamePattern.MatchString(parts[i]) {
+			return proxy.ContainerInfo{}, false
+		}
+	}
+	info := proxy.ContainerInfo{Namespace: parts[0], PodName: parts[1]}
+	if len(parts) == 3 {
+		info.Container = parts[2]
+	}
+	return info, true
+}
@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/proxy"
+)
+
+func TestParseK8sPodTarget(t *testing.T) {
+	tests := []struct {
+		target string
+		want   proxy.ContainerInfo
+		ok     bool
+	}{
+		{target: "prod/web-0", want: proxy.ContainerInfo{Namespace: "prod", PodName: "web-0"}, ok: true},
+		{target: "prod/web-0/app", want: proxy.ContainerInfo{Namespace: "prod", PodName: "web-0", Container: "app"}, ok: true},
+		{target: "web-0"},
+		{target: "prod//app"},
+		{target: "prod/web-0/app/extra"},
+		{target: "prod/web 0"},
+	}
+	for _, tt := range tests {
+		got, ok := parseK8sPodTarget(tt.target)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseK8sPodTarget(%q) = %+v, %v, want %+v, %v", tt.target, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/config"
+)
+
+/*
+MENU_KEY_BINDINGS 自定义菜单快捷键，格式为 "快捷键=菜单命令"，如 "l=p" 使用 l 显示资产列表，
+"hist=history" 后可以输入 hist systemctl。快捷键与已有的菜单命令相同时覆盖该命令，
+被覆盖的命令只能通过其他快捷键使用。帮助(?)、退出(q)、翻页(b、n)、序号、/ 和 @ 开头的搜索为保留输入，不能绑定。
+没有配置时菜单命令保持不变。
+*/
+
+type keyBinding struct {
+	key     string
+	command string
+}
+
+var reservedMenuKeys = []string{"?", "q", "b", "n"}
+
+func isReservedMenuKey(key string) bool {
+	if containsString(reservedMenuKeys, key) {
+		return true
+	}
+	if strings.HasPrefix(key, "/") || strings.HasPrefix(key, "@") {
+		return true
+	}
+	_, err := strconv.Atoi(key)
+	return err == nil
+}
+
+// menuCommands 所有菜单命令，包括不能禁用的 ? 和 q
+func menuCommands() []string {
+	commands := make([]string, 0, len(menuRegistry))
+	for i := range menuRegistry {
+		if command := menuRegistry[i].command(); command != "" {
+			commands = append(commands, command)
+		}
+	}
+	return commands
+}
+
+func parseKeyBindings(entries []string) ([]keyBinding, error) {
+	commands := menuCommands()
+	bindings := make([]keyBinding, 0, len(entries))
+	for _, entry := range entries {
+		key, command, ok := strings.Cut(entry, "=")
+		key = normalizeMenuItem(key)
+		command = normalizeMenuItem(command)
+		switch {
+		case !ok || key == "" || command == "" || strings.ContainsAny(key, " \t"):
+			return nil, fmt.Errorf("invalid key binding %q in MENU_KEY_BINDINGS, format: key=command", entry)
+		case isReservedMenuKey(key):
+			return nil, fmt.Errorf("key %s in MENU_KEY_BINDINGS is reserved", key)
+		case !containsString(commands, command):
+			return nil, fmt.Errorf("unknown menu command %s in MENU_KEY_BINDINGS, available: %s",
+				command, strings.Join(commands, ", "))
+		case key == command:
+			return nil, fmt.Errorf("key %s in MENU_KEY_BINDINGS is bound to itself", key)
+		}
+		for i := range bindings {
+			if bindings[i].key == key {
+				return nil, fmt.Errorf("key %s in MENU_KEY_BINDINGS is bound more than once", key)
+			}
+		}
+		bindings = append(bindings, keyBinding{key: key, command: command})
+	}
+	return bindings, nil
+}
+
+// ValidateKeyBindings 检查 MENU_KEY_BINDINGS，避免与保留输入冲突的配置被忽略
+func ValidateKeyBindings(entries []string) error {
+	_, err := parseKeyBindings(entries)
+	return err
+}
+
+// activeKeyBindings 配置错误时不使用任何自定义快捷键，错误在启动和重新加载配置时提示
+func activeKeyBindings() []keyBinding {
+	bindings, err := parseKeyBindings(config.GetConf().MenuKeyBindings)
+	if err != nil {
+		return nil
+	}
+	return bindings
+}
+
+// expandKeyBinding 将快捷键替换为对应的菜单命令，后面的参数保持不变
+func expandKeyBinding(bindings []keyBinding, line string) string {
+	for i := range bindings {
+		key := bindings[i].key
+		if strings.EqualFold(line, key) {
+			return bindings[i].command
+		}
+		if len(line) > len(key) && strings.EqualFold(line[:len(key)], key) && line[len(key)] == ' ' {
+			return bindings[i].command + line[len(key):]
+		}
+	}
+	return line
+}
+
+// commandKeys 可以使用菜单命令的输入，命令被其他快捷键覆盖时不包括命令本身
+func commandKeys(bindings []keyBinding, command string) []string {
+	keys := make([]string, 0, 2)
+	overridden := false
+	for i := range bindings {
+		if bindings[i].key == command {
+			overridden = true
+		}
+	}
+	if !overridden {
+		keys = append(keys, command)
+	}
+	for i := range bindings {
+		if bindings[i].command == command {
+			keys = append(keys, bindings[i].key)
+		}
+	}
+	return keys
+}
+
+// bindInstruct 菜单中显示命令的快捷键，如 "p, l"、"history, hist + keyword"，没有可用的输入时返回 false
+func bindInstruct(bindings []keyBinding, command, instruct string) (string, bool) {
+	if command == "" || len(bindings) == 0 {
+		return instruct, true
+	}
+	keys := commandKeys(bindings, command)
+	switch {
+	case len(keys) == 0:
+		return "", false
+	case len(keys) == 1 && keys[0] == command:
+		return instruct, true
+	case strings.HasPrefix(instruct, command):
+		return strings.Join(keys, ", ") + instruct[len(command):], true
+	}
+	return strings.Join(keys, ", ") + " / " + instruct, true
+}
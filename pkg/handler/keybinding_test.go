@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestParseKeyBindings(t *testing.T) {
+	for _, entries := range [][]string{{"q=p"}, {"n=p"}, {"1=p"}, {"/x=p"}, {"l=list"}, {"l"}, {"l=p", "L=h"}, {"p=p"}} {
+		if err := ValidateKeyBindings(entries); err == nil {
+			t.Errorf("ValidateKeyBindings(%v) err = nil, want conflict error", entries)
+		}
+	}
+	bindings, err := parseKeyBindings([]string{"l=p", " Hist = history ", "a=f"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		line string
+		want string
+	}{
+		{line: "l", want: "p"},
+		{line: "L", want: "p"},
+		{line: "hist systemctl", want: "history systemctl"},
+		{line: "historyx", want: "historyx"},
+		{line: "a", want: "f"},
+		{line: "a1", want: "a1"},
+		{line: "web-01", want: "web-01"},
+	}
+	for _, tt := range tests {
+		if got := expandKeyBinding(bindings, tt.line); got != tt.want {
+			t.Errorf("expandKeyBinding(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestBuildMenuWithKeyBindings(t *testing.T) {
+	conf := config.GetConf()
+	conf.MenuKeyBindings = []string{"l=p", "hist=history", "a=f"}
+	setTestConfig(t, &conf)
+
+	h := &InteractiveHandler{user: &model.User{Role: "Admin"}, terminalConf: &model.TerminalConfig{}}
+	instructs := make(map[string]bool)
+	for _, item := range h.buildMenu(i18n.EN) {
+		instructs[item.instruct] = true
+	}
+	for _, want := range []string{"p, l", "history, hist + keyword", "f, a", "h"} {
+		if !instructs[want] {
+			t.Errorf("buildMenu() missing %q in %v", want, instructs)
+		}
+	}
+	// a 被覆盖后不再显示活跃会话的菜单项
+	if instructs["a"] {
+		t.Error("buildMenu() contains overridden command a")
+	}
+	if got := h.menuCompletions("hi"); len(got) != 2 || got[0].text != "history" || got[1].text != "hist" {
+		t.Errorf("menuCompletions(hi) = %+v, want history and hist", got)
+	}
+}
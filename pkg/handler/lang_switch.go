@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+/*
+LANGUAGE_SWITCH_KEY 在任何时候(菜单、选择账号或者连接资产时)切换语言的快捷键，如 "^]l" 表示 Ctrl+] 后按 l。
+快捷键在读取用户输入时拦截，不会发送给资产。切换后立即提示，菜单和提示在下次显示时使用新的语言，
+已经连接的资产会话在结束前保持原来的语言。选择的语言保存到用户的偏好设置。
+*/
+
+// langSwitchOrder 切换语言的顺序，与菜单中的 s 相同
+var langSwitchOrder = []i18n.LanguageCode{i18n.EN, i18n.ZH, i18n.JA, i18n.KO, i18n.RU}
+
+func nextLang(code string) string {
+	current := i18n.NewLang(code)
+	for i := range langSwitchOrder {
+		if langSwitchOrder[i] == current {
+			return langSwitchOrder[(i+1)%len(langSwitchOrder)].String()
+		}
+	}
+	return i18n.EN.String()
+}
+
+// langDisplayName 切换后提示中显示的语言名称
+var langDisplayName = map[i18n.LanguageCode]string{
+	i18n.EN: "English",
+	i18n.ZH: "简体中文",
+	i18n.JA: "日本語",
+	i18n.KO: "한국어",
+	i18n.RU: "Русский",
+}
+
+// ValidateLanguageSwitchKey 检查 LANGUAGE_SWITCH_KEY，单个可见字符会拦截正常的输入
+func ValidateLanguageSwitchKey(value string) error {
+	if value == "" {
+		return nil
+	}
+	seq, err := utils.ParseKeySequence(value)
+	if err != nil {
+		return fmt.Errorf("LANGUAGE_SWITCH_KEY: %w", err)
+	}
+	if len(seq) == 1 && seq[0] >= 0x20 && seq[0] < 0x7f {
+		return fmt.Errorf("LANGUAGE_SWITCH_KEY %q is a printable character", value)
+	}
+	return nil
+}
+
+func languageSwitchKey() []byte {
+	value := config.GetConf().LanguageSwitchKey
+	if ValidateLanguageSwitchKey(value) != nil {
+		return nil
+	}
+	seq, _ := utils.ParseKeySequence(value)
+	return seq
+}
+
+// installLangSwitchKey 在会话的输入中拦截切换语言的快捷键
+func (h *InteractiveHandler) installLangSwitchKey() {
+	seq := languageSwitchKey()
+	if len(seq) == 0 || h.sess == nil {
+		return
+	}
+	h.sess.SetKeyHook(seq, h.onLangSwitchKey)
+}
+
+// onLangSwitchKey 在读取输入的 goroutine 中调用，只记录待切换的语言，由 Dispatch 应用
+func (h *InteractiveHandler) onLangSwitchKey() {
+	h.langMu.Lock()
+	current := h.pendingLang
+	if current == "" {
+		current = h.i18nLang
+	}
+	lang := nextLang(current)
+	h.pendingLang = lang
+	h.langMu.Unlock()
+	code := i18n.NewLang(lang)
+	logger.Infof("User %s switch language to %s by hotkey", h.user.Name, lang)
+	msg := fmt.Sprintf(code.T("Language switched to %s"), langDisplayName[code])
+	_, _ = h.sess.Write([]byte(utils.CharNewLine + utils.WrapperString(msg, utils.Green) + utils.CharNewLine))
+}
+
+// applyPendingLang 应用快捷键切换的语言，返回是否切换
+func (h *InteractiveHandler) applyPendingLang() bool {
+	h.langMu.Lock()
+	lang := h.pendingLang
+	h.pendingLang = ""
+	if lang != "" {
+		h.i18nLang = lang
+	}
+	h.langMu.Unlock()
+	if lang == "" {
+		return false
+	}
+	userLangGlobalStore.Store(h.user.ID, lang)
+	h.saveLangPreference(lang)
+	return true
+}
+
+// saveLangPreference 保存用户选择的语言，失败时只在本节点生效
+func (h *InteractiveHandler) saveLangPreference(lang string) {
+	macros, err := h.loadMacros()
+	if err != nil {
+		logger.Errorf("Get user %s koko preference failed, language not saved: %s", h.user.Name, err)
+		return
+	}
+	// 偏好设置按字段整体更新，需要带上已有的连接宏
+	pref := model.KokoPreference{Macros: macros, Language: lang}
+	if err = h.jmsService.UpdateUserKokoPreference(h.user.ID, &pref); err != nil {
+		logger.Errorf("Update user %s language preference failed: %s", h.user.Name, err)
+	}
+}
+
+// preferredLang 偏好设置中的语言，无法识别时返回空
+func preferredLang(pref model.KokoPreference) string {
+	lang := strings.TrimSpace(pref.Language)
+	if lang == "" {
+		return ""
+	}
+	for i := range langSwitchOrder {
+		if strings.EqualFold(langSwitchOrder[i].String(), lang) {
+			return langSwitchOrder[i].String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestValidateLanguageSwitchKey(t *testing.T) {
+	if err := ValidateLanguageSwitchKey("l"); err == nil {
+		t.Error("ValidateLanguageSwitchKey(\"l\") = nil, want error for printable key")
+	}
+	if err := ValidateLanguageSwitchKey("^]l"); err != nil {
+		t.Errorf("ValidateLanguageSwitchKey(\"^]l\") = %s", err)
+	}
+}
+
+func TestNextLang(t *testing.T) {
+	lang := "en"
+	want := []string{"zh_CN", "ja_JP", "ko_KR", "ru_RU", "en_US"}
+	for _, w := range want {
+		if lang = nextLang(lang); lang != w {
+			t.Fatalf("nextLang() = %s, want %s", lang, w)
+		}
+	}
+}
+
+func TestPreferredLang(t *testing.T) {
+	for value, want := range map[string]string{"ja_jp": "ja_JP", "": "", "fr_FR": ""} {
+		if got := preferredLang(model.KokoPreference{Language: value}); got != want {
+			t.Errorf("preferredLang(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
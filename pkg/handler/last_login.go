@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/common"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+// loadLastLogin 获取用户上一次的登录记录后再记录本次登录，获取失败或超时不影响登录
+func (h *InteractiveHandler) loadLastLogin(ctx context.Context) {
+	done := make(chan *model.LoginLog, 1)
+	go func() {
+		record, err := h.jmsService.GetUserLastLogin(h.user.Username)
+		if err != nil {
+			logger.Errorf("Get user %s last login failed: %s", h.user.Name, err)
+		}
+		done <- record
+	}()
+	select {
+	case h.lastLogin = <-done:
+	case <-ctx.Done():
+		logger.Errorf("Get user %s last login timeout", h.user.Name)
+	}
+	current := model.LoginLog{
+		Username: h.user.Username,
+		IP:       h.remoteIP(),
+		Type:     model.LoginTypeTerminal,
+		Status:   true,
+		Datetime: common.NewNowUTCTime(),
+	}
+	go func() {
+		if err := h.jmsService.CreateLoginLog(current); err != nil {
+			logger.Errorf("Record user %s login failed: %s", h.user.Name, err)
+		}
+	}()
+}
+
+func (h *InteractiveHandler) remoteIP() string {
+	if h.sess == nil {
+		return ""
+	}
+	return h.sess.RemoteAddr()
+}
+
+// lastLoginMessage 类似 sshd 的 Last login 提示，没有上一次的登录记录时返回空
+func (h *InteractiveHandler) lastLoginMessage() string {
+	if h.lastLogin == nil || h.lastLogin.Datetime.IsZero() {
+		return ""
+	}
+	lang := i18n.NewLang(h.i18nLang)
+	msg := fmt.Sprintf(lang.T("Last login: %s from %s"),
+		h.formatTime(h.lastLogin.Datetime.Time), h.lastLogin.IP)
+	return utils.CharTab + h.activeTheme().WrapTitle(msg) + utils.CharNewLine + utils.CharNewLine
+}
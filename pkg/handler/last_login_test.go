@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestLastLogin(t *testing.T) {
+	var logs []model.LoginLog
+	created := make(chan model.LoginLog, 2)
+	jms := newTestJMService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			var data model.LoginLog
+			_ = json.NewDecoder(r.Body).Decode(&data)
+			logs = append([]model.LoginLog{data}, logs...)
+			created <- data
+			_ = json.NewEncoder(w).Encode(data)
+			return
+		}
+		resp := model.LoginLogList{Total: len(logs), Data: logs}
+		if len(logs) > 1 {
+			resp.Data = logs[:1]
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	newHandler := func() *InteractiveHandler {
+		return &InteractiveHandler{
+			user:       &model.User{ID: "user-1", Name: "alice", Username: "alice"},
+			jmsService: jms,
+			i18nLang:   "en",
+		}
+	}
+
+	h := newHandler()
+	h.loadLastLogin(context.Background())
+	if msg := h.lastLoginMessage(); msg != "" {
+		t.Errorf("lastLoginMessage() without record = %q, want empty", msg)
+	}
+	select {
+	case data := <-created:
+		if data.Username != "alice" || !data.Status || data.Type != model.LoginTypeTerminal {
+			t.Errorf("created login log = %+v, want alice terminal login", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("current login not recorded")
+	}
+
+	logs[0].IP = "10.0.0.8"
+	h = newHandler()
+	h.loadLastLogin(context.Background())
+	<-created
+	if msg := h.lastLoginMessage(); !strings.Contains(msg, "Last login:") || !strings.Contains(msg, "from 10.0.0.8") {
+		t.Errorf("lastLoginMessage() = %q, want previous login", msg)
+	}
+}
@@ -0,0 +1,352 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/common"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/proxy"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+const (
+	macroDelayPrefix = ":delay "
+	macroWaitPrefix  = ":wait "
+)
+
+// 仅交互式 shell 类协议支持连接宏
+var macroProtocols = map[string]bool{
+	"ssh":    true,
+	"telnet": true,
+}
+
+/*
+连接宏保存在 core 的用户偏好设置中(category=koko)，首次使用时加载。
+定义步骤时每行一个: 普通文本为命令，":delay 2s" 为等待固定时长，
+":wait <正则>" 为等待资产输出匹配(如 shell 提示符)后再继续。
+*/
+
+func (h *InteractiveHandler) loadMacros() ([]model.ConnectMacro, error) {
+	if h.macrosLoaded {
+		return h.macros, nil
+	}
+	pref, err := h.jmsService.GetUserKokoPreference(h.user.ID)
+	if err != nil {
+		return nil, err
+	}
+	h.macros = pref.Macros
+	h.macrosLoaded = true
+	return h.macros, nil
+}
+
+func (h *InteractiveHandler) saveMacros(macros []model.ConnectMacro) bool {
+	pref := model.KokoPreference{Macros: macros}
+	if err := h.jmsService.UpdateUserKokoPreference(h.user.ID, &pref); err != nil {
+		logger.Errorf("Update user %s koko preference failed: %s", h.user.Name, err)
+		lang := i18n.NewLang(h.i18nLang)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(lang.T("Core API failed")))
+		return false
+	}
+	h.macros = macros
+	return true
+}
+
+func parseMacroStep(line string) (model.MacroStep, error) {
+	var step model.MacroStep
+	switch {
+	case strings.HasPrefix(line, macroDelayPrefix):
+		step = model.MacroStep{Type: model.MacroStepDelay,
+			Value: strings.TrimSpace(strings.TrimPrefix(line, macroDelayPrefix))}
+	case strings.HasPrefix(line, macroWaitPrefix):
+		step = model.MacroStep{Type: model.MacroStepWait,
+			Value: strings.TrimSpace(strings.TrimPrefix(line, macroWaitPrefix))}
+	default:
+		step = model.MacroStep{Type: model.MacroStepCommand, Value: line}
+	}
+	return step, proxy.ValidateMacroStep(step)
+}
+
+func formatMacroStep(step model.MacroStep) string {
+	switch step.Type {
+	case model.MacroStepDelay:
+		return macroDelayPrefix + step.Value
+	case model.MacroStepWait:
+		return macroWaitPrefix + step.Value
+	default:
+		return step.Value
+	}
+}
+
+func (h *InteractiveHandler) displayMacroTable(macros []model.ConnectMacro) {
+	lang := i18n.NewLang(h.i18nLang)
+	labels := []string{lang.T("ID"), lang.T("Name"), lang.T("Asset"), lang.T("Trusted"), lang.T("Steps")}
+	fields := []string{"ID", "Name", "Asset", "Trusted", "Steps"}
+	data := make([]map[string]string, len(macros))
+	for i := range macros {
+		asset := macros[i].Asset
+		if macros[i].AssetID == "" {
+			asset = lang.T("All assets")
+		}
+		steps := make([]string, 0, len(macros[i].Steps))
+		for j := range macros[i].Steps {
+			steps = append(steps, formatMacroStep(macros[i].Steps[j]))
+		}
+		trusted := lang.T("No")
+		if macros[i].Trusted {
+			trusted = lang.T("Yes")
+		}
+		data[i] = map[string]string{
+			"ID":      strconv.Itoa(i + 1),
+			"Name":    macros[i].Name,
+			"Asset":   asset,
+			"Trusted": trusted,
+			"Steps":   strings.Join(steps, "; "),
+		}
+	}
+	w, _ := h.GetPtySize()
+	table := common.WrapperTable{
+		Fields: fields,
+		Labels: labels,
+		FieldsSize: map[string][3]int{
+			"ID":      {0, 0, 5},
+			"Name":    {0, 8, 0},
+			"Asset":   {0, 8, 0},
+			"Trusted": {0, 0, 8},
+			"Steps":   {0, 10, 0},
+		},
+		Data:        data,
+		TotalSize:   w,
+		TruncPolicy: common.TruncSuffix,
+	}
+	table.Initial()
+	utils.IgnoreErrWriteString(h.term, table.Display())
+}
+
+func (h *InteractiveHandler) displayMacros() {
+	lang := i18n.NewLang(h.i18nLang)
+	macros, err := h.loadMacros()
+	if err != nil {
+		logger.Errorf("Get user %s koko preference failed: %s", h.user.Name, err)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(lang.T("Core API failed")))
+		return
+	}
+	utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+	if len(macros) == 0 {
+		utils.IgnoreErrWriteString(h.term, lang.T("No macros"))
+		utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+	} else {
+		h.displayMacroTable(macros)
+	}
+	tips := lang.T("Tips: Enter macro add NAME to add a macro for all assets, macro add NAME ID to add a macro for the asset in the list, macro del ID to delete")
+	utils.IgnoreErrWriteString(h.term, utils.WrapperString(tips, utils.Green))
+	utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+	trustTips := lang.T("Tips: Enter macro trust ID to run the macro without confirmation, macro untrust ID to confirm before running")
+	utils.IgnoreErrWriteString(h.term, utils.WrapperString(trustTips, utils.Green))
+	utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+}
+
+// addMacro 参数为 "名称 [资产列表中的序号]"，之后逐行读取步骤，空行结束
+func (h *InteractiveHandler) addMacro(args string) bool {
+	fields := strings.Fields(args)
+	if len(fields) == 0 || len(fields) > 2 {
+		return false
+	}
+	lang := i18n.NewLang(h.i18nLang)
+	macros, err := h.loadMacros()
+	if err != nil {
+		logger.Errorf("Get user %s koko preference failed: %s", h.user.Name, err)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(lang.T("Core API failed")))
+		return true
+	}
+	macro := model.ConnectMacro{Name: fields[0]}
+	if len(fields) == 2 {
+		index, err2 := strconv.Atoi(fields[1])
+		currentResult := h.selectHandler.currentResult
+		if err2 != nil || index <= 0 || index > len(currentResult) {
+			return false
+		}
+		macro.AssetID = currentResult[index-1].ID
+		macro.Asset = currentResult[index-1].String()
+	}
+	tips := lang.T("Enter one step per line: a command, :delay DURATION such as :delay 2s, or :wait REGEX to wait for the output such as :wait \\$ $; enter an empty line to finish")
+	utils.IgnoreErrWriteString(h.term, utils.WrapperString(tips, utils.Green))
+	utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+	defer h.setPrompt(h.prompt)
+	h.setPrompt("Step> ")
+	for {
+		line, err2 := h.term.ReadLine()
+		if err2 != nil {
+			logger.Errorf("Read macro step err: %s", err2)
+			return true
+		}
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		step, err2 := parseMacroStep(line)
+		if err2 != nil {
+			msg := fmt.Sprintf(lang.T("Invalid step: %s"), err2)
+			utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(msg))
+			continue
+		}
+		macro.Steps = append(macro.Steps, step)
+	}
+	if len(macro.Steps) == 0 {
+		return true
+	}
+	newMacros := make([]model.ConnectMacro, 0, len(macros)+1)
+	newMacros = append(newMacros, macros...)
+	if h.saveMacros(append(newMacros, macro)) {
+		msg := fmt.Sprintf(lang.T("Macro %s saved"), macro.Name)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperString(msg, utils.Green))
+		utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+	}
+	return true
+}
+
+func (h *InteractiveHandler) deleteMacro(arg string) bool {
+	index, err := strconv.Atoi(strings.TrimSpace(arg))
+	if err != nil {
+		return false
+	}
+	lang := i18n.NewLang(h.i18nLang)
+	macros, err := h.loadMacros()
+	if err != nil {
+		logger.Errorf("Get user %s koko preference failed: %s", h.user.Name, err)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(lang.T("Core API failed")))
+		return true
+	}
+	if index <= 0 || index > len(macros) {
+		return false
+	}
+	name := macros[index-1].Name
+	newMacros := make([]model.ConnectMacro, 0, len(macros)-1)
+	newMacros = append(newMacros, macros[:index-1]...)
+	newMacros = append(newMacros, macros[index:]...)
+	if h.saveMacros(newMacros) {
+		msg := fmt.Sprintf(lang.T("Macro %s deleted"), name)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperString(msg, utils.Green))
+		utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+	}
+	return true
+}
+
+// trustMacro 设置宏是否可信，可信的宏执行前不需要确认
+func (h *InteractiveHandler) trustMacro(arg string, trusted bool) bool {
+	index, err := strconv.Atoi(strings.TrimSpace(arg))
+	if err != nil {
+		return false
+	}
+	lang := i18n.NewLang(h.i18nLang)
+	macros, err := h.loadMacros()
+	if err != nil {
+		logger.Errorf("Get user %s koko preference failed: %s", h.user.Name, err)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(lang.T("Core API failed")))
+		return true
+	}
+	if index <= 0 || index > len(macros) {
+		return false
+	}
+	newMacros := make([]model.ConnectMacro, len(macros))
+	copy(newMacros, macros)
+	newMacros[index-1].Trusted = trusted
+	if h.saveMacros(newMacros) {
+		logger.Infof("User %s set macro %s trusted %v", h.user.String(), newMacros[index-1].Name, trusted)
+		msg := fmt.Sprintf(lang.T("Macro %s saved"), newMacros[index-1].Name)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperString(msg, utils.Green))
+		utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+	}
+	return true
+}
+
+/*
+confirmMacro 执行不可信的宏之前以纯文本显示将要发送的全部步骤，用户确认后才执行，
+避免过期或者被篡改的宏执行意外的命令。
+*/
+
+func (h *InteractiveHandler) confirmMacro(macro *model.ConnectMacro) bool {
+	if macro.Trusted {
+		return true
+	}
+	lang := i18n.NewLang(h.i18nLang)
+	var b strings.Builder
+	b.WriteString(utils.CharNewLine)
+	b.WriteString(fmt.Sprintf(lang.T("Macro %s will send the following steps:"), macro.Name))
+	b.WriteString(utils.CharNewLine)
+	for i := range macro.Steps {
+		b.WriteString(fmt.Sprintf("  %d. %s%s", i+1, formatMacroStep(macro.Steps[i]), utils.CharNewLine))
+	}
+	utils.IgnoreErrWriteString(h.term, b.String())
+	defer h.setPrompt(h.prompt)
+	h.setPrompt(lang.T("Run the macro? [y/N]") + " ")
+	line, err := h.term.ReadLine()
+	if err != nil {
+		logger.Errorf("Read macro confirm err: %s", err)
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		logger.Infof("User %s confirm to run macro %s", h.user.String(), macro.Name)
+		return true
+	}
+	logger.Infof("User %s decline to run macro %s", h.user.String(), macro.Name)
+	utils.IgnoreErrWriteString(h.term, lang.T("Macro skipped")+utils.CharNewLine)
+	return false
+}
+
+// chooseMacro 连接资产前选择登录后执行的连接宏，没有可用的宏时直接返回
+func (h *InteractiveHandler) chooseMacro(asset model.Asset, protocol string) (*model.ConnectMacro, bool) {
+	if !macroProtocols[protocol] {
+		return nil, true
+	}
+	macros, err := h.loadMacros()
+	if err != nil {
+		// 获取失败不影响连接
+		logger.Errorf("Get user %s koko preference failed: %s", h.user.Name, err)
+		return nil, true
+	}
+	available := make([]model.ConnectMacro, 0, len(macros))
+	for i := range macros {
+		if macros[i].IsAvailable(asset.ID) {
+			available = append(available, macros[i])
+		}
+	}
+	if len(available) == 0 {
+		return nil, true
+	}
+	lang := i18n.NewLang(h.i18nLang)
+	h.displayMacroTable(available)
+	selectTip := lang.T("Tips: Enter the macro ID to run after login, press Enter to skip")
+	backTip := lang.T("Back: B/b")
+	h.setPrompt("ID> ")
+	for i := 0; i < 3; i++ {
+		utils.IgnoreErrWriteString(h.term, utils.WrapperString(selectTip, utils.Green))
+		utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperString(backTip, utils.Green))
+		utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+		line, err2 := h.term.ReadLine()
+		if err2 != nil {
+			logger.Errorf("select macro err: %s", err2)
+			return nil, false
+		}
+		line = strings.TrimSpace(line)
+		switch strings.ToLower(line) {
+		case "q", "b", "quit", "exit", "back":
+			logger.Info("select macro cancel")
+			return nil, false
+		case "":
+			return nil, true
+		}
+		if num, err3 := strconv.Atoi(line); err3 == nil && num > 0 && num <= len(available) {
+			if !h.confirmMacro(&available[num-1]) {
+				// 用户拒绝时不执行宏，正常连接资产
+				return nil, true
+			}
+			return &available[num-1], true
+		}
+	}
+	return nil, true
+}
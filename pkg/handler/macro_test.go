@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"golang.org/x/term"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestConfirmMacro(t *testing.T) {
+	macro := model.ConnectMacro{Name: "deploy", Steps: []model.MacroStep{
+		{Type: model.MacroStepWait, Value: `\$ $`},
+		{Type: model.MacroStepCommand, Value: "cd /opt/app && ./deploy.sh"},
+	}}
+	tests := []struct {
+		input   string
+		trusted bool
+		want    bool
+	}{
+		{input: "y\r", want: true},
+		{input: "\r", want: false},
+		{input: "no\r", want: false},
+		{trusted: true, want: true},
+	}
+	for _, tt := range tests {
+		var output strings.Builder
+		rw := struct {
+			io.Reader
+			io.Writer
+		}{strings.NewReader(tt.input), &output}
+		h := &InteractiveHandler{user: &model.User{Username: "alice"}, term: term.NewTerminal(rw, "Opt> "), i18nLang: "en"}
+		m := macro
+		m.Trusted = tt.trusted
+		if got := h.confirmMacro(&m); got != tt.want {
+			t.Errorf("confirmMacro(input %q, trusted %v) = %v, want %v", tt.input, tt.trusted, got, tt.want)
+		}
+		if tt.trusted {
+			if output.Len() != 0 {
+				t.Errorf("trusted macro displayed preview %q", output.String())
+			}
+			continue
+		}
+		if !strings.Contains(output.String(), "2. cd /opt/app && ./deploy.sh") ||
+			!strings.Contains(output.String(), `1. :wait \$ $`) {
+			t.Errorf("macro preview = %q, want all steps", output.String())
+		}
+	}
+}
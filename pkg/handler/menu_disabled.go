@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+/*
+DISABLED_MENU_ITEMS 全局禁用的菜单项，使用菜单命令(如 d、k、ssh、history)配置。
+禁用的菜单项不在横幅和帮助中显示，不参与 Tab 补全，输入对应的命令时提示已禁用。
+搜索、帮助(?)和退出(q)不能禁用。
+*/
+
+// command 菜单项对应的命令，搜索类的菜单项返回空
+func (e menuEntry) command() string {
+	command := e.instruct
+	if index := strings.Index(command, " + "); index > 0 {
+		command = command[:index]
+	}
+	if strings.Contains(command, " ") || strings.HasPrefix(command, "/") ||
+		strings.HasPrefix(command, "@") {
+		return ""
+	}
+	return command
+}
+
+func (e menuEntry) disableable() bool {
+	switch command := e.command(); command {
+	case "", "?", "q":
+		return false
+	}
+	return true
+}
+
+// DisableableMenuItems 可以通过 DISABLED_MENU_ITEMS 禁用的菜单命令
+func DisableableMenuItems() []string {
+	items := make([]string, 0, len(menuRegistry))
+	for i := range menuRegistry {
+		if menuRegistry[i].disableable() {
+			items = append(items, menuRegistry[i].command())
+		}
+	}
+	return items
+}
+
+// ValidateDisabledMenuItems 检查禁用的菜单项，避免拼写错误的配置被忽略
+func ValidateDisabledMenuItems(items []string) error {
+	available := DisableableMenuItems()
+	var unknown []string
+	for _, item := range items {
+		if !containsString(available, normalizeMenuItem(item)) {
+			unknown = append(unknown, item)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown menu items %s in DISABLED_MENU_ITEMS, available: %s",
+			strings.Join(unknown, ", "), strings.Join(available, ", "))
+	}
+	return nil
+}
+
+func normalizeMenuItem(item string) string {
+	return strings.ToLower(strings.TrimSpace(item))
+}
+
+func containsString(items []string, s string) bool {
+	for i := range items {
+		if items[i] == s {
+			return true
+		}
+	}
+	return false
+}
+
+func menuItemDisabled(command string) bool {
+	if command == "" || !containsString(DisableableMenuItems(), command) {
+		return false
+	}
+	for _, item := range config.GetConf().DisabledMenuItems {
+		if normalizeMenuItem(item) == command {
+			return true
+		}
+	}
+	return false
+}
+
+// menuEntryEnabled 菜单项对当前用户可用且没有被禁用
+func (h *InteractiveHandler) menuEntryEnabled(entry menuEntry) bool {
+	if entry.available != nil && !entry.available(h) {
+		return false
+	}
+	return !entry.disableable() || !menuItemDisabled(entry.command())
+}
+
+// menuLineCommand 返回输入对应的菜单命令，与 Dispatch 的匹配规则保持一致，其他输入作为搜索处理
+func menuLineCommand(line string) string {
+	if len(line) == 1 {
+		return strings.ToLower(line)
+	}
+	numberArg := func(prefix string) bool {
+		_, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, prefix)))
+		return err == nil
+	}
+	switch {
+	case line == "history", strings.HasPrefix(line, "history "):
+		return "history"
+	case strings.HasPrefix(line, "macro add "), strings.HasPrefix(line, "macro del "),
+		strings.HasPrefix(line, "macro trust "), strings.HasPrefix(line, "macro untrust "):
+		return "m"
+	case line == "share":
+		return "share"
+	case strings.HasPrefix(line, "share"):
+		if _, _, ok := parseShareArgs(strings.TrimPrefix(line, "share")); ok {
+			return "share"
+		}
+	case strings.HasPrefix(line, "join "):
+		return "join"
+	case line == "attach":
+		return "attach"
+	case strings.HasPrefix(line, "attach"):
+		if _, ok := parseAttachArgs(strings.TrimPrefix(line, "attach")); ok {
+			return "attach"
+		}
+	case strings.HasPrefix(line, "broadcast "):
+		return "broadcast"
+	case line == "reload":
+		return "reload"
+	case strings.HasPrefix(line, "ssh") && numberArg("ssh"):
+		return "ssh"
+	case strings.Index(line, "g") == 0:
+		if strings.TrimSpace(strings.TrimPrefix(line, "g")) == ".." || numberArg("g") {
+			return "g"
+		}
+	case strings.Index(line, "f") == 0 && numberArg("f"):
+		return "f"
+	case strings.Index(line, "a") == 0 && numberArg("a"):
+		return "a"
+	}
+	return ""
+}
+
+func (h *InteractiveHandler) displayMenuItemDisabled(command string) {
+	lang := i18n.NewLang(h.i18nLang)
+	msg := fmt.Sprintf(lang.T("Menu item %s has been disabled by the administrator"), command)
+	utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(msg))
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestValidateDisabledMenuItems(t *testing.T) {
+	if err := ValidateDisabledMenuItems([]string{"d", " K ", "ssh", "history"}); err != nil {
+		t.Errorf("ValidateDisabledMenuItems() err = %v", err)
+	}
+	err := ValidateDisabledMenuItems([]string{"d", "kube", "q"})
+	if err == nil || !strings.Contains(err.Error(), "kube, q") {
+		t.Errorf("ValidateDisabledMenuItems() err = %v, want unknown kube and q", err)
+	}
+}
+
+func TestDisabledMenuItems(t *testing.T) {
+	conf := config.GetConf()
+	conf.DisabledMenuItems = []string{"d", "K", "ssh"}
+	setTestConfig(t, &conf)
+
+	h := &InteractiveHandler{user: &model.User{Role: "User"}, terminalConf: &model.TerminalConfig{}}
+	for _, item := range h.buildMenu(i18n.EN) {
+		switch item.instruct {
+		case "d", "k", "ssh + ID":
+			t.Errorf("buildMenu() contains disabled item %s", item.instruct)
+		}
+	}
+	if got := h.menuCompletions("ss"); len(got) != 0 {
+		t.Errorf("menuCompletions(ss) = %v, want none", got)
+	}
+	tests := []struct {
+		line     string
+		disabled bool
+	}{
+		{line: "d", disabled: true},
+		{line: "K", disabled: true},
+		{line: "ssh 3", disabled: true},
+		{line: "sshd-server", disabled: false},
+		{line: "h", disabled: false},
+		{line: "db-prod", disabled: false},
+	}
+	for _, tt := range tests {
+		if got := menuItemDisabled(menuLineCommand(tt.line)); got != tt.disabled {
+			t.Errorf("menuItemDisabled(%q) = %v, want %v", tt.line, got, tt.disabled)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+/*
+用户自定义的菜单提示符保存在 koko 偏好设置中，使用 text/template 渲染，如 "{{.Node}} [{{.Type}}]>"。
+菜单输入会去掉首尾的空格，渲染结果不以空格结尾时自动补一个空格。模板错误时使用默认的提示符并记录警告。只替换菜单的提示符，选择账号、协议等提示符不受影响。
+*/
+
+// maxPromptLength 提示符模板的最大长度，过长的提示符会占满输入行
+const maxPromptLength = 64
+
+// PromptContext 自定义提示符模板可使用的变量
+type PromptContext struct {
+	// 用户名称和用户名
+	User     string
+	Username string
+	// koko 节点的名称
+	Node string
+	// 当前列表的类型，如 Host、DB、K8S、RDP，菜单中为 Opt
+	Type string
+}
+
+func parsePromptTemplate(text string) (*template.Template, error) {
+	if len(text) > maxPromptLength {
+		return nil, fmt.Errorf("prompt is longer than %d characters", maxPromptLength)
+	}
+	if strings.IndexFunc(text, unicode.IsControl) >= 0 {
+		return nil, errors.New("prompt contains control characters")
+	}
+	tmpl, err := template.New("prompt").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	// 提前执行一次，引用不存在的变量时在设置时报错
+	if _, err = renderPrompt(tmpl, PromptContext{}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// renderPrompt 去掉渲染结果中的控制字符，避免变量中的换行破坏输入行
+func renderPrompt(tmpl *template.Template, ctx PromptContext) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, ctx); err != nil {
+		return "", err
+	}
+	prompt := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, buf.String())
+	return prompt, nil
+}
+
+func defaultMenuPrompt(kind string) string {
+	if kind == "Opt" {
+		return "Opt> "
+	}
+	return "[" + kind + "]> "
+}
+
+// menuPrompt 返回菜单的提示符，没有自定义或者渲染失败时使用默认提示符
+func (h *InteractiveHandler) menuPrompt(kind string) string {
+	if h.promptTemplate == nil {
+		return defaultMenuPrompt(kind)
+	}
+	ctx := PromptContext{
+		User:     h.user.Name,
+		Username: h.user.Username,
+		Node:     config.GetConf().Name,
+		Type:     kind,
+	}
+	prompt, err := renderPrompt(h.promptTemplate, ctx)
+	if err != nil || strings.TrimSpace(prompt) == "" {
+		logger.Warnf("User %s render custom prompt failed, use default: %v", h.user.Name, err)
+		return defaultMenuPrompt(kind)
+	}
+	if last := prompt[len(prompt)-1]; last != ' ' {
+		prompt += " "
+	}
+	return prompt
+}
+
+// useMenuPrompt 切换到指定类型的菜单提示符
+func (h *InteractiveHandler) useMenuPrompt(kind string) {
+	h.promptKind = kind
+	h.setPrompt(h.menuPrompt(kind))
+}
+
+// loadPromptPreference 加载偏好设置中的提示符，模板错误时使用默认提示符
+func (h *InteractiveHandler) loadPromptPreference(pref model.KokoPreference) {
+	if pref.Prompt == nil || *pref.Prompt == "" {
+		return
+	}
+	tmpl, err := parsePromptTemplate(*pref.Prompt)
+	if err != nil {
+		logger.Warnf("User %s custom prompt %q is invalid, use default: %s", h.user.Name, *pref.Prompt, err)
+		return
+	}
+	h.promptText = *pref.Prompt
+	h.promptTemplate = tmpl
+}
+
+// handlePromptCommand 处理 prompt 命令，没有参数时显示当前的提示符，reset 恢复默认
+func (h *InteractiveHandler) handlePromptCommand(args string) {
+	lang := i18n.NewLang(h.i18nLang)
+	text := strings.TrimPrefix(args, " ")
+	switch {
+	case strings.TrimSpace(text) == "":
+		msg := lang.T("Your menu prompt is the default, enter prompt + template to customize it")
+		if h.promptText != "" {
+			msg = fmt.Sprintf(lang.T("Your menu prompt is %q, enter prompt reset to restore the default"), h.promptText)
+		}
+		utils.IgnoreErrWriteString(h.term, utils.WrapperString(msg, utils.Green)+utils.CharNewLine)
+		return
+	case strings.TrimSpace(text) == "reset":
+		text = ""
+	}
+	var tmpl *template.Template
+	if text != "" {
+		var err error
+		if tmpl, err = parsePromptTemplate(text); err != nil {
+			logger.Infof("User %s set invalid prompt %q: %s", h.user.Name, text, err)
+			msg := fmt.Sprintf(lang.T("Invalid prompt template: %s"), err)
+			utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(msg))
+			return
+		}
+	}
+	macros, err := h.loadMacros()
+	if err != nil {
+		logger.Errorf("Get user %s koko preference failed: %s", h.user.Name, err)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(lang.T("Core API failed")))
+		return
+	}
+	// 偏好设置按字段整体更新，需要带上已有的连接宏；空字符串表示恢复默认
+	pref := model.KokoPreference{Macros: macros, Prompt: &text}
+	if err = h.jmsService.UpdateUserKokoPreference(h.user.ID, &pref); err != nil {
+		logger.Errorf("Update user %s prompt preference failed: %s", h.user.Name, err)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(lang.T("Core API failed")))
+		return
+	}
+	h.promptText = text
+	h.promptTemplate = tmpl
+	logger.Infof("User %s set menu prompt: %q", h.user.Name, text)
+	if h.promptKind != "" {
+		h.useMenuPrompt(h.promptKind)
+	}
+	msg := lang.T("Menu prompt restored to the default")
+	if text != "" {
+		msg = lang.T("Menu prompt updated")
+	}
+	utils.IgnoreErrWriteString(h.term, utils.WrapperString(msg, utils.Green)+utils.CharNewLine)
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"golang.org/x/term"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestParsePromptTemplate(t *testing.T) {
+	for _, text := range []string{"{{.Node", "{{.Missing}}>", "a\nb", strings.Repeat("x", maxPromptLength+1)} {
+		if _, err := parsePromptTemplate(text); err == nil {
+			t.Errorf("parsePromptTemplate(%q) = nil error", text)
+		}
+	}
+	if _, err := parsePromptTemplate("{{.Username}}@{{.Node}} [{{.Type}}]>"); err != nil {
+		t.Errorf("parsePromptTemplate() = %s", err)
+	}
+}
+
+func TestMenuPrompt(t *testing.T) {
+	conf := config.GetConf()
+	conf.Name = "koko-bj\r\n"
+	setTestConfig(t, &conf)
+
+	rw := struct {
+		io.Reader
+		io.Writer
+	}{strings.NewReader(""), io.Discard}
+	h := &InteractiveHandler{user: &model.User{Name: "Alice", Username: "alice"}, term: term.NewTerminal(rw, "")}
+	if got := h.menuPrompt("Host"); got != "[Host]> " {
+		t.Errorf("default menuPrompt(Host) = %q", got)
+	}
+
+	invalid := "{{.Node"
+	h.loadPromptPreference(model.KokoPreference{Prompt: &invalid})
+	if h.promptTemplate != nil || h.menuPrompt("Opt") != "Opt> " {
+		t.Errorf("invalid prompt preference not ignored, prompt = %q", h.menuPrompt("Opt"))
+	}
+
+	custom := "{{.Username}}@{{.Node}} [{{.Type}}]>"
+	h.loadPromptPreference(model.KokoPreference{Prompt: &custom})
+	h.useMenuPrompt("DB")
+	if want := "alice@koko-bj [DB]> "; h.prompt != want {
+		t.Errorf("custom prompt = %q, want %q", h.prompt, want)
+	}
+}
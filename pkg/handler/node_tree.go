@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xlab/treeprint"
+
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+/*
+nodeTreeView 资产树导航的状态。
+子节点和资产在节点展开时才向 core 请求，并按节点 key 缓存，避免一次加载整棵资产树。
+*/
+
+type nodeTreeView struct {
+	path     []model.NodeTree              // 根节点到当前节点的路径，用于显示面包屑
+	items    []model.NodeTree              // 当前层级显示的节点和资产，序号从 1 开始
+	children map[string]model.NodeTreeList // 已加载的子节点和资产，key 为节点 key
+}
+
+func (v *nodeTreeView) currentKey() string {
+	if len(v.path) == 0 {
+		return ""
+	}
+	return v.path[len(v.path)-1].Meta.Data.Key
+}
+
+func (v *nodeTreeView) reset() {
+	v.path = nil
+	v.items = nil
+	v.children = nil
+}
+
+func (h *InteractiveHandler) loadNodeTreeChildren(nodeKey string) (model.NodeTreeList, error) {
+	if items, ok := h.nodeTree.children[nodeKey]; ok {
+		return items, nil
+	}
+	nodeTrees, err := h.jmsService.GetNodeTreeByUserAndNodeKey(h.user.ID, nodeKey)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(model.NodeTreeList, 0, len(nodeTrees))
+	assets := make(model.NodeTreeList, 0, len(nodeTrees))
+	for i := range nodeTrees {
+		item := nodeTrees[i]
+		if nodeKey == "" && item.Pid != "" {
+			// 根路径下节点的 pid 是空字符
+			continue
+		}
+		switch item.Meta.Type {
+		case model.TreeTypeNode:
+			nodes = append(nodes, item)
+		case model.TreeTypeAsset:
+			if item.ChkDisabled {
+				// 资产被禁用，不显示
+				continue
+			}
+			assets = append(assets, item)
+		}
+	}
+	// 节点在前，资产在后，分别按名称排序
+	sortNodeTreesByName(h.i18nLang, nodes)
+	sortNodeTreesByName(h.i18nLang, assets)
+	items := append(nodes, assets...)
+	if h.nodeTree.children == nil {
+		h.nodeTree.children = make(map[string]model.NodeTreeList)
+	}
+	h.nodeTree.children[nodeKey] = items
+	return items, nil
+}
+
+// displayRootNodeTree 回到根节点并显示
+func (h *InteractiveHandler) displayRootNodeTree() {
+	h.nodeTree.path = nil
+	h.displayCurrentNodeTree()
+}
+
+// expandNodeTreeItem 选择当前层级第 num 个条目，节点则展开，资产则直接连接
+func (h *InteractiveHandler) expandNodeTreeItem(num int) bool {
+	if num <= 0 || num > len(h.nodeTree.items) {
+		return false
+	}
+	item := h.nodeTree.items[num-1]
+	switch item.Meta.Type {
+	case model.TreeTypeNode:
+		h.nodeTree.path = append(h.nodeTree.path, item)
+		h.displayCurrentNodeTree()
+	case model.TreeTypeAsset:
+		assets, err := h.jmsService.GetUserPermAssetById(h.user.ID, item.ID)
+		if err != nil || len(assets) != 1 {
+			logger.Errorf("Get user %s perm asset %s failed: %v", h.user.Name, item.ID, err)
+			lang := i18n.NewLang(h.i18nLang)
+			msg := fmt.Sprintf(lang.T("Asset %s not found"), item.Name)
+			utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(msg))
+			return true
+		}
+		h.selectHandler.Proxy(assets[0])
+	}
+	return true
+}
+
+// moveUpNodeTree 返回上一级节点
+func (h *InteractiveHandler) moveUpNodeTree() {
+	if n := len(h.nodeTree.path); n > 0 {
+		h.nodeTree.path = h.nodeTree.path[:n-1]
+	}
+	h.displayCurrentNodeTree()
+}
+
+func (h *InteractiveHandler) displayCurrentNodeTree() {
+	lang := i18n.NewLang(h.i18nLang)
+	items, err := h.loadNodeTreeChildren(h.nodeTree.currentKey())
+	if err != nil {
+		logger.Errorf("Get user %s node tree failed: %s", h.user.Name, err)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(lang.T("Load node tree failed")))
+		return
+	}
+	h.nodeTree.items = items
+
+	breadcrumbs := make([]string, 0, len(h.nodeTree.path))
+	for i := range h.nodeTree.path {
+		breadcrumbs = append(breadcrumbs, h.nodeTree.path[i].Meta.Data.Value)
+	}
+	tree := treeprint.New()
+	for i := range items {
+		// 节点名称中已包含资产数量，如 Default (10)
+		label := fmt.Sprintf("%d.%s", i+1, items[i].Name)
+		if items[i].Meta.Type == model.TreeTypeNode {
+			tree.AddBranch(label)
+			continue
+		}
+		tree.AddNode(label)
+	}
+	header := fmt.Sprintf(lang.T("Node: %s"), "/"+strings.Join(breadcrumbs, "/"))
+	utils.IgnoreErrWriteString(h.term, utils.CharNewLine+utils.WrapperString(header, utils.Green))
+	if len(items) == 0 {
+		utils.IgnoreErrWriteString(h.term, utils.CharNewLine+lang.T("No nodes or assets"))
+		utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+	} else {
+		utils.IgnoreErrWriteString(h.term, tree.String())
+	}
+	tips := lang.T("Tips: Enter g+ID to expand the node or connect the asset, such as g1; enter g.. to go up a level")
+	utils.IgnoreErrWriteString(h.term, tips+utils.CharNewLine)
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+// loadPasswordExpiry 获取用户密码的过期时间，获取失败或超时不影响登录
+func (h *InteractiveHandler) loadPasswordExpiry(ctx context.Context) {
+	if config.GetConf().PasswordExpireNoticeDays <= 0 {
+		return
+	}
+	done := make(chan time.Time, 1)
+	go func() {
+		var expiredAt time.Time
+		res, err := h.jmsService.GetUserPasswordExpiry(h.user.ID)
+		if err != nil {
+			logger.Errorf("Get user %s password expiry failed: %s", h.user.Name, err)
+		} else if res.DatePasswordExpired != nil {
+			expiredAt = res.DatePasswordExpired.Time
+		}
+		done <- expiredAt
+	}()
+	select {
+	case h.passwordExpiredAt = <-done:
+	case <-ctx.Done():
+		logger.Errorf("Get user %s password expiry timeout", h.user.Name)
+	}
+}
+
+/*
+passwordExpiryMessage 密码在 PASSWORD_EXPIRE_NOTICE_DAYS 天内过期，或者已过期但 core 仍允许登录时，提示用户修改密码。
+配置了 PASSWORD_CHANGE_URL 时附带修改密码的地址。
+*/
+
+func (h *InteractiveHandler) passwordExpiryMessage(now time.Time) string {
+	noticeDays := config.GetConf().PasswordExpireNoticeDays
+	if h.passwordExpiredAt.IsZero() || noticeDays <= 0 {
+		return ""
+	}
+	lang := i18n.NewLang(h.i18nLang)
+	remain := h.passwordExpiredAt.Sub(now)
+	var msg string
+	switch days := int((remain + 24*time.Hour - 1) / (24 * time.Hour)); {
+	case remain <= 0:
+		msg = utils.WrapperString(lang.T("Your password has expired, please change it as soon as possible"), utils.Red)
+	case days <= noticeDays:
+		msg = fmt.Sprintf(lang.TN("Your password will expire in %d day (%s), please change it in time",
+			"Your password will expire in %d days (%s), please change it in time", days),
+			days, h.formatTime(h.passwordExpiredAt))
+		msg = utils.WrapperString(msg, utils.Yellow)
+	default:
+		return ""
+	}
+	msg = utils.CharTab + msg + utils.CharNewLine
+	if url := config.GetConf().PasswordChangeURL; url != "" {
+		msg += utils.CharTab + fmt.Sprintf(lang.T("Change password: %s"), url) + utils.CharNewLine
+	}
+	return msg + utils.CharNewLine
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/config"
+)
+
+func TestPasswordExpiryMessage(t *testing.T) {
+	conf := config.GetConf()
+	conf.PasswordChangeURL = "https://jumpserver.example.com/password"
+	setTestConfig(t, &conf)
+
+	now := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		expiredAt time.Time
+		want      string
+	}{
+		{expiredAt: time.Time{}, want: ""},
+		{expiredAt: now.Add(30 * 24 * time.Hour), want: ""},
+		{expiredAt: now.Add(36 * time.Hour), want: "Your password will expire in 2 days"},
+		{expiredAt: now.Add(time.Hour), want: "Your password will expire in 1 day ("},
+		{expiredAt: now.Add(-time.Hour), want: "Your password has expired"},
+	}
+	for _, tt := range tests {
+		h := &InteractiveHandler{i18nLang: "en", passwordExpiredAt: tt.expiredAt}
+		got := h.passwordExpiryMessage(now)
+		if tt.want == "" {
+			if got != "" {
+				t.Errorf("passwordExpiryMessage(%s) = %q, want empty", tt.expiredAt, got)
+			}
+			continue
+		}
+		if !strings.Contains(got, tt.want) || !strings.Contains(got, conf.PasswordChangeURL) {
+			t.Errorf("passwordExpiryMessage(%s) = %q, want %q with change url", tt.expiredAt, got, tt.want)
+		}
+	}
+	conf.PasswordExpireNoticeDays = 0
+	h := &InteractiveHandler{i18nLang: "en", passwordExpiredAt: now.Add(time.Hour)}
+	if got := h.passwordExpiryMessage(now); got != "" {
+		t.Errorf("passwordExpiryMessage() with notice disabled = %q", got)
+	}
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/srvconn"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+// permSummary 用户有权限的资产、节点、数据库、k8s 集群的数量，显示在横幅中
+type permSummary struct {
+	Assets    int
+	Nodes     int
+	Databases int
+	Clusters  int
+}
+
+/*
+loadPermSummary 并发请求各类资源的数量，每个请求只返回一条数据，不加载全部资产。
+资产数量获取失败或超时时不显示，其他数量获取失败时按 0 处理不显示，不影响登录。
+*/
+
+func (h *InteractiveHandler) loadPermSummary(ctx context.Context) {
+	done := make(chan *permSummary, 1)
+	go func() {
+		var summary permSummary
+		tasks := []struct {
+			name  string
+			value *int
+			fetch func() (int, error)
+		}{
+			{name: "assets", value: &summary.Assets, fetch: func() (int, error) {
+				return h.jmsService.GetUserPermsAssetCount(h.user.ID, model.PaginationParam{
+					IsActive: true, Protocols: srvconn.SupportedProtocols()})
+			}},
+			{name: "nodes", value: &summary.Nodes, fetch: func() (int, error) {
+				return h.jmsService.GetUserNodeCount(h.user.ID)
+			}},
+			{name: "databases", value: &summary.Databases, fetch: func() (int, error) {
+				return h.jmsService.GetUserPermsAssetCount(h.user.ID, model.PaginationParam{
+					IsActive: true, Category: "database", Protocols: srvconn.SupportedDBProtocols()})
+			}},
+			{name: "clusters", value: &summary.Clusters, fetch: func() (int, error) {
+				return h.jmsService.GetUserPermsAssetCount(h.user.ID, model.PaginationParam{
+					IsActive: true, Type: "k8s"})
+			}},
+		}
+		errs := make([]error, len(tasks))
+		var wg sync.WaitGroup
+		for i := range tasks {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				n, err := tasks[i].fetch()
+				if err != nil {
+					logger.Errorf("Get user %s perms %s count failed: %s", h.user.Name, tasks[i].name, err)
+					errs[i] = err
+					return
+				}
+				*tasks[i].value = n
+			}(i)
+		}
+		wg.Wait()
+		if errs[0] != nil {
+			done <- nil
+			return
+		}
+		done <- &summary
+	}()
+	select {
+	case h.permSummary = <-done:
+	case <-ctx.Done():
+		logger.Errorf("Get user %s perms count timeout", h.user.Name)
+	}
+}
+
+// permSummaryMessage 如 You have access to 128 assets across 12 nodes, 4 databases, 2 clusters.
+// 数量为 0 的分类不显示，没有资产时返回空
+func (h *InteractiveHandler) permSummaryMessage() string {
+	s := h.permSummary
+	if s == nil || s.Assets <= 0 {
+		return ""
+	}
+	lang := i18n.NewLang(h.i18nLang)
+	var parts []string
+	if s.Nodes > 0 {
+		parts = append(parts, fmt.Sprintf(lang.TN("%d node", "%d nodes", s.Nodes), s.Nodes))
+	}
+	if s.Databases > 0 {
+		parts = append(parts, fmt.Sprintf(lang.TN("%d database", "%d databases", s.Databases), s.Databases))
+	}
+	if s.Clusters > 0 {
+		parts = append(parts, fmt.Sprintf(lang.TN("%d cluster", "%d clusters", s.Clusters), s.Clusters))
+	}
+	var msg string
+	if len(parts) == 0 {
+		msg = fmt.Sprintf(lang.TN("You have access to %d asset.", "You have access to %d assets.", s.Assets), s.Assets)
+	} else {
+		msg = fmt.Sprintf(lang.TN("You have access to %d asset across %s.", "You have access to %d assets across %s.", s.Assets),
+			s.Assets, strings.Join(parts, lang.T(", ")))
+	}
+	return utils.CharTab + h.activeTheme().WrapTitle(msg) + utils.CharNewLine + utils.CharNewLine
+}
@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+func TestPermSummaryMessage(t *testing.T) {
+	theme, _ := utils.GetTheme(utils.ThemeNoColor)
+	tests := []struct {
+		summary *permSummary
+		want    string
+	}{
+		{summary: nil, want: ""},
+		{summary: &permSummary{Nodes: 3}, want: ""},
+		{summary: &permSummary{Assets: 1}, want: "You have access to 1 asset."},
+		{summary: &permSummary{Assets: 128, Nodes: 12, Databases: 4, Clusters: 2},
+			want: "You have access to 128 assets across 12 nodes, 4 databases, 2 clusters."},
+		{summary: &permSummary{Assets: 5, Nodes: 1, Clusters: 1},
+			want: "You have access to 5 assets across 1 node, 1 cluster."},
+	}
+	for _, tt := range tests {
+		h := &InteractiveHandler{i18nLang: "en", theme: &theme, permSummary: tt.summary}
+		want := tt.want
+		if want != "" {
+			want = "\t" + want + "\r\n\r\n"
+		}
+		if got := h.permSummaryMessage(); got != want {
+			t.Errorf("permSummaryMessage(%+v) = %q, want %q", tt.summary, got, want)
+		}
+	}
+}
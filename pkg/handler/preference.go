@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+// 登录时获取用户偏好设置的等待时间，超时后使用默认设置，避免阻塞登录
+const preferenceLoadTimeout = 3 * time.Second
+
+var errPreferenceTimeout = errors.New("get koko preference timeout")
+
+func (h *InteractiveHandler) fetchKokoPreference(ctx context.Context) (model.KokoPreference, error) {
+	type result struct {
+		pref model.KokoPreference
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		pref, err := h.jmsService.GetUserKokoPreference(h.user.ID)
+		done <- result{pref: pref, err: err}
+	}()
+	select {
+	case ret := <-done:
+		return ret.pref, ret.err
+	case <-ctx.Done():
+		return model.KokoPreference{}, errPreferenceTimeout
+	}
+}
+
+// loadPreference 登录时加载用户的 koko 偏好设置，获取失败时使用默认设置
+func (h *InteractiveHandler) loadPreference(ctx context.Context) {
+	pref, err := h.fetchKokoPreference(ctx)
+	if err != nil {
+		logger.Errorf("Get user %s koko preference failed, use default: %s", h.user.Name, err)
+		h.timezone = i18n.LoadTimezone(h.clientTimezone())
+		return
+	}
+	h.macros = pref.Macros
+	h.macrosLoaded = true
+	h.commandSummary = pref.CommandSummary != nil && *pref.CommandSummary
+	h.bellPolicy = pref.BellPolicy
+	if lang := preferredLang(pref); lang != "" {
+		h.i18nLang = lang
+	}
+	name := pref.Timezone
+	if name == "" {
+		name = h.clientTimezone()
+	}
+	h.timezone = i18n.LoadTimezone(name)
+	h.loadFirstLoginNotice(pref)
+	h.loadPromptPreference(pref)
+}
+
+// clientTimezone 客户端通过 env 请求传入的 TZ
+func (h *InteractiveHandler) clientTimezone() string {
+	if h.sess == nil {
+		return ""
+	}
+	for _, item := range h.sess.Environ() {
+		if name, value, ok := strings.Cut(item, "="); ok && name == "TZ" {
+			return value
+		}
+	}
+	return ""
+}
+
+func (h *InteractiveHandler) userTimezone() *time.Location {
+	if h.timezone == nil {
+		return time.UTC
+	}
+	return h.timezone
+}
+
+// formatTime 面向用户显示的时间，使用用户的时区和语言格式
+func (h *InteractiveHandler) formatTime(t time.Time) string {
+	return i18n.NewLang(h.i18nLang).FormatTime(t, h.userTimezone())
+}
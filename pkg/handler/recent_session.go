@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/common"
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+const defaultRecentSessionSize = 10
+
+var (
+	// 全局缓存用户在当前 koko 进程中最近的连接记录，key 为用户名
+	userRecentSessionStore = recentSessionStore{data: make(map[string]*recentSessionRing)}
+)
+
+type recentSession struct {
+	Asset       model.Asset
+	Protocol    string
+	Account     string
+	DateCreated time.Time
+}
+
+// recentSessionRing 固定容量的环形缓冲区，写满后覆盖最早的记录
+type recentSessionRing struct {
+	items []recentSession
+	next  int
+	full  bool
+}
+
+func newRecentSessionRing(size int) *recentSessionRing {
+	return &recentSessionRing{items: make([]recentSession, size)}
+}
+
+func (r *recentSessionRing) Add(item recentSession) {
+	r.items[r.next] = item
+	r.next = (r.next + 1) % len(r.items)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// List 按照时间倒序返回记录
+func (r *recentSessionRing) List() []recentSession {
+	count := r.next
+	if r.full {
+		count = len(r.items)
+	}
+	result := make([]recentSession, 0, count)
+	for i := 1; i <= count; i++ {
+		index := (r.next - i + len(r.items)) % len(r.items)
+		result = append(result, r.items[index])
+	}
+	return result
+}
+
+type recentSessionStore struct {
+	sync.Mutex
+	data map[string]*recentSessionRing
+}
+
+func (s *recentSessionStore) Add(username string, item recentSession) {
+	s.Lock()
+	defer s.Unlock()
+	ring, ok := s.data[username]
+	if !ok {
+		ring = newRecentSessionRing(getRecentSessionSize())
+		s.data[username] = ring
+	}
+	ring.Add(item)
+}
+
+func (s *recentSessionStore) List(username string) []recentSession {
+	s.Lock()
+	defer s.Unlock()
+	if ring, ok := s.data[username]; ok {
+		return ring.List()
+	}
+	return nil
+}
+
+func getRecentSessionSize() int {
+	if size := config.GetConf().RecentSessionSize; size > 0 {
+		return size
+	}
+	return defaultRecentSessionSize
+}
+
+func (u *UserSelectHandler) recordRecentSession(asset model.Asset, protocol string) {
+	item := recentSession{
+		Asset:       asset,
+		Protocol:    protocol,
+		DateCreated: time.Now(),
+	}
+	if u.selectedAccount != nil {
+		item.Account = u.selectedAccount.Alias
+	}
+	userRecentSessionStore.Add(u.user.Username, item)
+	u.lastSession = &item
+}
+
+func (u *UserSelectHandler) searchRecentSession(searches ...string) []model.Asset {
+	allFields := []string{"name", "address", "platform", "comment"}
+	fields := make(map[string]struct{}, len(allFields))
+	for i := range allFields {
+		if u.isHiddenField(allFields[i]) {
+			continue
+		}
+		fields[allFields[i]] = struct{}{}
+	}
+	sessions := userRecentSessionStore.List(u.user.Username)
+	u.recentSessions = make([]recentSession, 0, len(sessions))
+	items := make([]model.Asset, 0, len(sessions))
+	for i := range sessions {
+		data := assetSearchFieldsMap(&sessions[i].Asset)
+		if containKeysInMapItemFields(data, fields, searches...) {
+			u.recentSessions = append(u.recentSessions, sessions[i])
+			items = append(items, sessions[i].Asset)
+		}
+	}
+	return items
+}
+
+func (u *UserSelectHandler) displayRecentSessionResult(searchHeader string) {
+	lang := i18n.NewLang(u.h.i18nLang)
+	if len(u.currentResult) == 0 {
+		noSessions := lang.T("No recent sessions")
+		u.displayNoResultMsg(searchHeader, noSessions)
+		return
+	}
+	idLabel := lang.T("ID")
+	timeLabel := lang.T("Date")
+	nameLabel := lang.T("Name")
+	addressLabel := lang.T("Address")
+	protocolLabel := lang.T("Protocol")
+	idFieldSize := len(idLabel)
+	timeFieldSize := len(timeLabel)
+	nameFieldSize := len(nameLabel)
+	addressFieldSize := len(addressLabel)
+	protocolFieldSize := len(protocolLabel)
+	// 当前页在全部记录中的起始位置
+	start := u.CurrentOffSet() - len(u.currentResult)
+	data := make([]map[string]string, 0, len(u.currentResult))
+	for i := range u.currentResult {
+		if start+i < 0 || start+i >= len(u.recentSessions) {
+			break
+		}
+		item := &u.recentSessions[start+i]
+		idNumber := strconv.Itoa(i + 1)
+		dateCreated := u.h.formatTime(item.DateCreated)
+		address := common.DisplayHost(item.Asset.Address)
+		row := map[string]string{
+			"ID":       idNumber,
+			"Date":     dateCreated,
+			"Name":     strings.ReplaceAll(item.Asset.Name, " ", "_"),
+			"Address":  address,
+			"Protocol": item.Protocol,
+		}
+		data = append(data, row)
+		if idFieldSize < len(idNumber) {
+			idFieldSize = len(idNumber)
+		}
+		if timeFieldSize < len(dateCreated) {
+			timeFieldSize = len(dateCreated)
+		}
+		if len(item.Asset.Name) > nameFieldSize {
+			nameFieldSize = len(item.Asset.Name)
+		}
+		if len(address) > addressFieldSize {
+			addressFieldSize = len(address)
+		}
+	}
+	if nameFieldSize > maxFieldSize {
+		nameFieldSize = maxFieldSize
+	}
+	if addressFieldSize > maxFieldSize {
+		addressFieldSize = maxFieldSize
+	}
+	allFieldsSize := map[string][3]int{
+		"ID":       {idFieldSize, 0, 0},
+		"Date":     {timeFieldSize, 0, 0},
+		"Name":     {nameFieldSize, 0, 0},
+		"Address":  {addressFieldSize, 0, 0},
+		"Protocol": {0, protocolFieldSize, 0},
+	}
+	allLabels := []string{idLabel, timeLabel, nameLabel, addressLabel, protocolLabel}
+	allFields := []string{"ID", "Date", "Name", "Address", "Protocol"}
+	labels := make([]string, 0, len(allLabels))
+	fields := make([]string, 0, len(allFields))
+	fieldsSize := make(map[string][3]int, len(allFields))
+	for i := range allFields {
+		if u.isHiddenField(allFields[i]) {
+			continue
+		}
+		labels = append(labels, allLabels[i])
+		fields = append(fields, allFields[i])
+		fieldsSize[allFields[i]] = allFieldsSize[allFields[i]]
+	}
+	u.displayResult(searchHeader, labels, fields, fieldsSize, data)
+}
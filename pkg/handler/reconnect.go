@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+// reconnectLastAsset 重新连接本次连接中最近连接的资产。
+// 连接前重新查询授权，权限被回收时不再连接；上次的协议和账号仍然可用时不再询问。
+func (u *UserSelectHandler) reconnectLastAsset() {
+	lang := i18n.NewLang(u.h.i18nLang)
+	last := u.lastSession
+	if last == nil {
+		utils.IgnoreErrWriteString(u.h.term, utils.WrapperWarn(lang.T("No recent asset in this session")))
+		return
+	}
+	assets, err := u.h.jmsService.GetUserPermAssetById(u.user.ID, last.Asset.ID)
+	if err != nil {
+		logger.Errorf("Get user %s perm asset %s failed: %s", u.user.Name, last.Asset.ID, err)
+		msg := fmt.Sprintf(lang.T("Asset %s not found"), last.Asset.Name)
+		utils.IgnoreErrWriteString(u.h.term, utils.WrapperWarn(msg))
+		return
+	}
+	if len(assets) != 1 {
+		logger.Infof("User %s has no permission to reconnect asset %s", u.user.Name, last.Asset.String())
+		msg := fmt.Sprintf(lang.T("You no longer have permission to access asset %s"), last.Asset.Name)
+		utils.IgnoreErrWriteString(u.h.term, utils.WrapperWarn(msg))
+		return
+	}
+	logger.Infof("User %s reconnect asset %s", u.user.Name, last.Asset.String())
+	u.reconnecting = last
+	defer func() { u.reconnecting = nil }()
+	u.proxyAsset(assets[0])
+}
+
+// reconnectProtocol 重新连接时使用上次的协议
+func (u *UserSelectHandler) reconnectProtocol(protocols []string) (string, bool) {
+	if u.reconnecting == nil {
+		return "", false
+	}
+	for i := range protocols {
+		if protocols[i] == u.reconnecting.Protocol {
+			return protocols[i], true
+		}
+	}
+	return "", false
+}
+
+// reconnectAccount 重新连接时使用上次的账号，账号不再授权时重新选择
+func (u *UserSelectHandler) reconnectAccount(accounts []model.PermAccount) (model.PermAccount, bool) {
+	if u.reconnecting == nil || u.reconnecting.Account == "" {
+		return model.PermAccount{}, false
+	}
+	for i := range accounts {
+		if accounts[i].Alias == u.reconnecting.Account {
+			return accounts[i], true
+		}
+	}
+	return model.PermAccount{}, false
+}
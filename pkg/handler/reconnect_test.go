@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang.org/x/term"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestReconnectLastAsset(t *testing.T) {
+	jms := newTestJMService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		// 授权已被回收
+		_ = json.NewEncoder(w).Encode([]model.Asset{})
+	}))
+	var output strings.Builder
+	rw := struct {
+		io.Reader
+		io.Writer
+	}{strings.NewReader(""), &output}
+	user := &model.User{ID: "user-1", Name: "alice", Username: "alice"}
+	u := &UserSelectHandler{
+		user: user,
+		h:    &InteractiveHandler{user: user, term: term.NewTerminal(rw, "Opt> "), jmsService: jms, i18nLang: "en"},
+	}
+
+	u.reconnectLastAsset()
+	if !strings.Contains(output.String(), "No recent asset in this session") {
+		t.Fatalf("output without last session = %q", output.String())
+	}
+
+	output.Reset()
+	u.selectedAccount = &model.PermAccount{Alias: "root"}
+	u.recordRecentSession(model.Asset{ID: "a1", Name: "web-01"}, "ssh")
+	u.reconnectLastAsset()
+	if !strings.Contains(output.String(), "You no longer have permission to access asset web-01") {
+		t.Fatalf("output after permission revoked = %q", output.String())
+	}
+	if u.reconnecting != nil {
+		t.Fatal("reconnecting not cleared")
+	}
+}
+
+func TestReconnectProtocolAndAccount(t *testing.T) {
+	u := &UserSelectHandler{}
+	accounts := []model.PermAccount{{Alias: "admin"}, {Alias: "root"}}
+	if _, ok := u.reconnectProtocol([]string{"ssh"}); ok {
+		t.Fatal("reconnectProtocol() without reconnecting = true")
+	}
+	u.reconnecting = &recentSession{Protocol: "sftp", Account: "root"}
+	if protocol, ok := u.reconnectProtocol([]string{"ssh", "sftp"}); !ok || protocol != "sftp" {
+		t.Errorf("reconnectProtocol() = %q, %v, want sftp", protocol, ok)
+	}
+	if _, ok := u.reconnectProtocol([]string{"ssh"}); ok {
+		t.Error("reconnectProtocol() with removed protocol = true")
+	}
+	if account, ok := u.reconnectAccount(accounts); !ok || account.Alias != "root" {
+		t.Errorf("reconnectAccount() = %q, %v, want root", account.Alias, ok)
+	}
+	if _, ok := u.reconnectAccount(accounts[:1]); ok {
+		t.Error("reconnectAccount() with removed account = true")
+	}
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/srvconn"
+)
+
+/*
+u 在一个列表中搜索所有类型的授权资源：主机、数据库、Kubernetes，开启 RDP 网关时包括 Windows 资产，
+结果中显示资源的类型，选择后按照资源类型使用对应的协议连接。
+搜索中的 type:db 按类型过滤，可用的类型为 host、db、k8s、windows，与其他搜索条件同时生效。
+*/
+
+const (
+	resourceHost     = "host"
+	resourceDatabase = "db"
+	resourceK8s      = "k8s"
+	resourceWindows  = "windows"
+)
+
+var resourceTypeAliases = map[string]string{
+	"host":       resourceHost,
+	"h":          resourceHost,
+	"db":         resourceDatabase,
+	"d":          resourceDatabase,
+	"database":   resourceDatabase,
+	"k8s":        resourceK8s,
+	"k":          resourceK8s,
+	"kubernetes": resourceK8s,
+	"windows":    resourceWindows,
+	"w":          resourceWindows,
+	"rdp":        resourceWindows,
+}
+
+// assetResourceType 按照资产支持的协议判断资源的类型，只支持 rdp 的资产为 Windows 资产
+func assetResourceType(asset *model.Asset) string {
+	if asset.IsSupportProtocol(srvconn.ProtocolK8s) {
+		return resourceK8s
+	}
+	for _, protocol := range srvconn.SupportedDBProtocols() {
+		if asset.IsSupportProtocol(protocol) {
+			return resourceDatabase
+		}
+	}
+	for _, protocol := range srvconn.SupportedHostProtocols() {
+		if asset.IsSupportProtocol(protocol) {
+			return resourceHost
+		}
+	}
+	if asset.IsSupportProtocol(protocolRDP) {
+		return resourceWindows
+	}
+	return resourceHost
+}
+
+func resourceTypeLabel(lang i18n.LanguageCode, resourceType string) string {
+	switch resourceType {
+	case resourceDatabase:
+		return lang.T("Database")
+	case resourceK8s:
+		return lang.T("Kubernetes")
+	case resourceWindows:
+		return lang.T("Windows")
+	default:
+		return lang.T("Host")
+	}
+}
+
+// matchResourceType 资源的类型与过滤条件一致，未知的类型不匹配任何资源
+func matchResourceType(asset *model.Asset, value string) bool {
+	resourceType, ok := resourceTypeAliases[strings.ToLower(value)]
+	return ok && assetResourceType(asset) == resourceType
+}
+
+// resourceSearchProtocols 统一搜索的资源需要支持的协议，开启 RDP 网关时包括 rdp
+func (u *UserSelectHandler) resourceSearchProtocols() []string {
+	protocols := srvconn.SupportedProtocols()
+	if rdpGatewayEnabled(u.h) {
+		protocols = append(protocols, protocolRDP)
+	}
+	return protocols
+}
+
+// filterResourceAssets 本地数据中过滤不能连接的资产
+func (u *UserSelectHandler) filterResourceAssets(assets []model.Asset) []model.Asset {
+	protocols := u.resourceSearchProtocols()
+	ret := make([]model.Asset, 0, len(assets))
+	for i := range assets {
+		for _, protocol := range protocols {
+			if assets[i].IsSupportProtocol(protocol) {
+				ret = append(ret, assets[i])
+				break
+			}
+		}
+	}
+	return ret
+}
+
+func (u *UserSelectHandler) displayResourceResult(searchHeader string) {
+	lang := i18n.NewLang(u.h.i18nLang)
+	if len(u.currentResult) == 0 {
+		u.displayNoResultMsg(searchHeader, lang.T("No resources"))
+		return
+	}
+	u.displayAssets(searchHeader)
+}
+
+// proxyResource Windows 资产交给 RDP 网关，其他资源按照选择的协议连接
+func (u *UserSelectHandler) proxyResource(asset model.Asset) {
+	if assetResourceType(&asset) == resourceWindows {
+		u.proxyRDPAsset(asset)
+		return
+	}
+	u.proxyAsset(asset)
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+/*
+搜索中 "字段:关键字" 形式的条件只匹配指定的字段，如 comment:payments、tag:team-x、type:db，不区分大小写，
+与其他关键字、正则和平台过滤条件同时生效。未知的字段(或隐藏的字段)按普通关键字搜索并提示可用的字段。
+*/
+
+var (
+	fieldSearchPattern = regexp.MustCompile(`^([a-zA-Z_]+):(.+)$`)
+
+	searchFieldAliases = map[string]string{
+		"name":     "name",
+		"hostname": "name",
+		"ip":       "address",
+		"address":  "address",
+		"comment":  "comment",
+		"tag":      "tag",
+		"label":    "tag",
+		"type":     "type",
+	}
+
+	searchFieldNames = []string{"name", "address", "comment", "tag", "type"}
+)
+
+type fieldFilter struct {
+	field string
+	value string
+}
+
+func (f fieldFilter) String() string {
+	return f.field + ":" + f.value
+}
+
+// hasFieldSearch 搜索中包含 "字段:关键字" 形式的条件
+func hasFieldSearch(key string) bool {
+	for _, word := range strings.Fields(key) {
+		if fieldSearchPattern.MatchString(word) {
+			return true
+		}
+	}
+	return false
+}
+
+// searchableFields 可以指定搜索的字段，不包括隐藏的字段
+func (u *UserSelectHandler) searchableFields() []string {
+	fields := make([]string, 0, len(searchFieldNames))
+	for _, field := range searchFieldNames {
+		if !u.isHiddenField(field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// splitFieldFilters 拆分搜索中指定字段的条件，未知字段的条件保留为普通关键字并返回在 unknown 中
+func (u *UserSelectHandler) splitFieldFilters(key string) (rest string, filters []fieldFilter, unknown []string) {
+	if !hasFieldSearch(key) {
+		return key, nil, nil
+	}
+	fields := u.searchableFields()
+	words := strings.Fields(key)
+	keys := make([]string, 0, len(words))
+	for _, word := range words {
+		matches := fieldSearchPattern.FindStringSubmatch(word)
+		if matches == nil {
+			keys = append(keys, word)
+			continue
+		}
+		field, ok := searchFieldAliases[strings.ToLower(matches[1])]
+		if !ok || !containsString(fields, field) {
+			keys = append(keys, word)
+			unknown = append(unknown, matches[1])
+			continue
+		}
+		filters = append(filters, fieldFilter{field: field, value: matches[2]})
+	}
+	return strings.Join(keys, " "), filters, unknown
+}
+
+func (u *UserSelectHandler) warnUnknownSearchFields(unknown []string) {
+	lang := i18n.NewLang(u.h.i18nLang)
+	for _, field := range unknown {
+		msg := fmt.Sprintf(lang.T("Unknown search field %s, searched as a keyword. Available fields: %s"),
+			field, strings.Join(u.searchableFields(), ", "))
+		utils.IgnoreErrWriteString(u.h.term, utils.WrapperWarn(msg))
+	}
+}
+
+// matchFieldFilters 资产需要满足所有指定字段的条件
+func matchFieldFilters(asset *model.Asset, filters []fieldFilter) bool {
+	for _, filter := range filters {
+		value := strings.ToLower(filter.value)
+		matched := false
+		switch filter.field {
+		case "name":
+			matched = strings.Contains(strings.ToLower(asset.Name), value)
+		case "address":
+			matched = strings.Contains(strings.ToLower(asset.Address), value)
+		case "comment":
+			matched = strings.Contains(strings.ToLower(asset.Comment), value)
+		case "tag":
+			for i := range asset.Labels {
+				label := asset.Labels[i].Name + ":" + asset.Labels[i].Value
+				if strings.Contains(strings.ToLower(label), value) {
+					matched = true
+					break
+				}
+			}
+		case "type":
+			matched = matchResourceType(asset, value)
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
@@ -2,10 +2,12 @@ package handler
 
 import (
 	"fmt"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/jumpserver/koko/pkg/common"
 	"github.com/jumpserver/koko/pkg/i18n"
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
 	"github.com/jumpserver/koko/pkg/logger"
@@ -28,6 +30,10 @@ const (
 	TypeK8s
 	TypeDatabase
 	TypeHost
+	TypeFavorite
+	TypeRecentSession
+	TypeWindows
+	TypeAll
 )
 
 type UserSelectHandler struct {
@@ -37,6 +43,12 @@ type UserSelectHandler struct {
 	loadingPolicy dataSource
 	currentType   selectType
 	searchKeys    []string
+	// 通过 // 追加的正则过滤条件
+	searchRegexps []*regexp.Regexp
+	// 通过 @ 指定的平台过滤条件，清除前对所有列表生效
+	platformFilter string
+	// 通过 "字段:关键字" 指定字段的条件，只对当前搜索生效
+	fieldFilters []fieldFilter
 
 	hasPre  bool
 	hasNext bool
@@ -52,6 +64,24 @@ type UserSelectHandler struct {
 	selectedAccount *model.PermAccount
 
 	hiddenFields map[string]struct{}
+
+	// 分页获取的资产缓存
+	pageCache *assetPageCache
+
+	// 过滤时从 core 获取的全部搜索结果，翻页时复用，重新搜索或刷新时清空
+	filterCacheKey    string
+	filterCacheAssets []model.Asset
+
+	favoriteAssets []model.Asset
+
+	recentSessions []recentSession
+
+	// 本次连接中最近连接的资产，重新连接时使用
+	lastSession *recentSession
+	// 正在重新连接的记录，优先使用上次的协议和账号
+	reconnecting *recentSession
+	// 本次连接中每个资产最后选择的账号别名，key 为资产 ID
+	lastAccounts map[string]string
 }
 
 func (u *UserSelectHandler) SetSelectType(s selectType) {
@@ -61,50 +91,31 @@ func (u *UserSelectHandler) SetSelectType(s selectType) {
 		switch u.h.assetLoadPolicy {
 		case "all":
 			u.SetLoadPolicy(loadingFromLocal)
-			u.AutoCompletion()
 		}
-		u.h.term.SetPrompt("[Host]> ")
+		u.h.useMenuPrompt("Host")
 	case TypeNodeAsset, TypeHost:
-		u.h.term.SetPrompt("[Host]> ")
+		u.h.useMenuPrompt("Host")
+	case TypeFavorite:
+		// 收藏的资产一次性获取，本地搜索分页
+		u.SetLoadPolicy(loadingFromLocal)
+		u.loadFavoriteAssets()
+		u.h.useMenuPrompt("Host")
+	case TypeRecentSession:
+		u.SetLoadPolicy(loadingFromLocal)
+		u.h.useMenuPrompt("Host")
 	case TypeK8s:
-		u.h.term.SetPrompt("[K8S]> ")
+		u.h.useMenuPrompt("K8S")
 	case TypeDatabase:
-		u.h.term.SetPrompt("[DB]> ")
-	}
-	u.currentType = s
-}
-
-func (u *UserSelectHandler) AutoCompletion() {
-	assets := u.Retrieve(0, 0, "")
-	suggests := make([]string, 0, len(assets))
-
-	for _, v := range assets {
-		suggests = append(suggests, v.Name)
-	}
-
-	sort.Strings(suggests)
-	u.h.term.AutoCompleteCallback = func(line string, pos int, key rune) (newLine string, newPos int, ok bool) {
-		if key == 9 {
-			termWidth, _ := u.h.GetPtySize()
-			if len(line) >= 1 {
-				sugs := utils.FilterPrefix(suggests, line)
-				if len(sugs) >= 1 {
-					commonPrefix := utils.LongestCommonPrefix(sugs)
-					switch u.currentType {
-					case TypeAsset, TypeNodeAsset:
-						fmt.Fprintf(u.h.term, "%s%s\n%s\n", "[Host]> ", line, utils.Pretty(sugs, termWidth))
-					case TypeK8s:
-						fmt.Fprintf(u.h.term, "%s%s\n%s\n", "[K8S]> ", line, utils.Pretty(sugs, termWidth))
-					case TypeDatabase:
-						fmt.Fprintf(u.h.term, "%s%s\n%s\n", "[DB]> ", line, utils.Pretty(sugs, termWidth))
-					}
-					return commonPrefix, len(commonPrefix), true
-				}
-			}
+		u.h.useMenuPrompt("DB")
+	case TypeWindows:
+		u.h.useMenuPrompt("RDP")
+	case TypeAll:
+		if u.h.assetLoadPolicy == "all" {
+			u.SetLoadPolicy(loadingFromLocal)
 		}
-
-		return newLine, newPos, false
+		u.h.useMenuPrompt("All")
 	}
+	u.currentType = s
 }
 
 func (u *UserSelectHandler) SetNode(node model.Node) {
@@ -116,6 +127,7 @@ func (u *UserSelectHandler) SetAllLocalData(data []model.Asset) {
 	// 使用副本
 	u.allLocalData = make([]model.Asset, len(data))
 	copy(u.allLocalData, data)
+	u.sortAssets(u.allLocalData)
 }
 
 func (u *UserSelectHandler) SetLoadPolicy(policy dataSource) {
@@ -144,48 +156,192 @@ func (u *UserSelectHandler) MovePrePage() {
 	u.DisplayCurrentResult()
 }
 
+// MoveToPage 跳转到指定页，页码超出范围时跳转到首页或末页
+func (u *UserSelectHandler) MoveToPage(page int) {
+	if totalPage := u.TotalPage(); page > totalPage {
+		page = totalPage
+	}
+	if page < 1 {
+		page = 1
+	}
+	newPageSize := getPageSize(u.h, u.h.terminalConf)
+	u.currentResult = u.Retrieve(newPageSize, (page-1)*newPageSize, u.searchKeys...)
+	u.DisplayCurrentResult()
+}
+
+// pagePosition 记录列表的分页位置，连接资产返回后恢复
+type pagePosition struct {
+	currentType    selectType
+	searchKeys     []string
+	searchRegexps  []*regexp.Regexp
+	platformFilter string
+	fieldFilters   []fieldFilter
+	currentResult  []model.Asset
+	pageInfo       pageInfo
+	hasPre         bool
+	hasNext        bool
+}
+
+func (u *UserSelectHandler) savePagePosition() pagePosition {
+	return pagePosition{
+		currentType:    u.currentType,
+		searchKeys:     u.searchKeys,
+		searchRegexps:  u.searchRegexps,
+		platformFilter: u.platformFilter,
+		fieldFilters:   u.fieldFilters,
+		currentResult:  u.currentResult,
+		pageInfo:       *u.pageInfo,
+		hasPre:         u.hasPre,
+		hasNext:        u.hasNext,
+	}
+}
+
+func (u *UserSelectHandler) restorePagePosition(pos pagePosition) {
+	u.currentType = pos.currentType
+	u.searchKeys = pos.searchKeys
+	u.searchRegexps = pos.searchRegexps
+	u.platformFilter = pos.platformFilter
+	u.fieldFilters = pos.fieldFilters
+	u.currentResult = pos.currentResult
+	*u.pageInfo = pos.pageInfo
+	u.hasPre = pos.hasPre
+	u.hasNext = pos.hasNext
+}
+
 func (u *UserSelectHandler) Search(key string) {
+	if rest, platform, ok := splitPlatformFilter(key); ok {
+		u.platformFilter = platform
+		key = rest
+	}
+	key, filters, unknown := u.splitFieldFilters(key)
+	u.fieldFilters = filters
+	u.searchRegexps = nil
+	u.clearFilterCache()
 	newPageSize := getPageSize(u.h, u.h.terminalConf)
 	u.currentResult = u.Retrieve(newPageSize, 0, key)
 	u.searchKeys = []string{key}
 	u.DisplayCurrentResult()
+	u.warnUnknownSearchFields(unknown)
 }
 
+// SearchAgain 在当前搜索结果的基础上使用正则再次过滤，普通关键字按子串匹配的结果不变
 func (u *UserSelectHandler) SearchAgain(key string) {
-	u.searchKeys = append(u.searchKeys, key)
+	re, err := regexp.Compile(key)
+	if err != nil {
+		lang := i18n.NewLang(u.h.i18nLang)
+		msg := fmt.Sprintf(lang.T("Invalid regular expression: %s"), key)
+		utils.IgnoreErrWriteString(u.h.term, utils.WrapperWarn(msg))
+		logger.Debugf("User %s search with invalid regexp %s: %s", u.user.Name, key, err)
+		return
+	}
+	u.searchRegexps = append(u.searchRegexps, re)
 	newPageSize := getPageSize(u.h, u.h.terminalConf)
 	u.currentResult = u.Retrieve(newPageSize, 0, u.searchKeys...)
 	u.DisplayCurrentResult()
 }
 
 func (u *UserSelectHandler) SearchOrProxy(key string) {
+	// 连接结束返回后仍停留在原来的分页位置
+	pos := u.savePagePosition()
+	if _, _, ok := splitPlatformFilter(key); ok || hasFieldSearch(key) {
+		// 设置平台过滤或指定字段时只搜索，不直接连接唯一的结果
+		u.Search(key)
+		return
+	}
 	if indexNum, err := strconv.Atoi(key); err == nil && len(u.currentResult) > 0 {
 		if indexNum > 0 && indexNum <= len(u.currentResult) {
 			u.Proxy(u.currentResult[indexNum-1])
+			u.restorePagePosition(pos)
 			return
 		}
+		if u.isPageIndex(indexNum) {
+			u.displayInvalidIndex(indexNum)
+			return
+		}
+	}
+
+	if ret, ok := u.searchUnique(key); ok {
+		u.Proxy(ret)
+		u.restorePagePosition(pos)
+		return
+	}
+	if len(u.currentResult) == 0 && u.proxyDynamicHostFromKey(key) {
+		u.restorePagePosition(pos)
+		return
+	}
+	u.DisplayCurrentResult()
+	if len(u.currentResult) == 0 {
+		u.displayAccessDenied(key)
+	}
+}
+
+// isPageIndex 不超过每页数量的数字按列表中的 ID 处理，更大的数字(如 IP 的一部分)仍然搜索
+func (u *UserSelectHandler) isPageIndex(num int) bool {
+	maxIndex := u.pageInfo.PageSize()
+	if maxIndex <= 0 {
+		maxIndex = len(u.currentResult)
+	}
+	return num <= maxIndex
+}
+
+// displayInvalidIndex 输入的 ID 不在当前页中时重新显示列表并提示
+func (u *UserSelectHandler) displayInvalidIndex(num int) {
+	lang := i18n.NewLang(u.h.i18nLang)
+	u.DisplayCurrentResult()
+	msg := fmt.Sprintf(lang.T("ID %d is not in the list, please enter an ID from 1 to %d"), num, len(u.currentResult))
+	utils.IgnoreErrWriteString(u.h.term, utils.WrapperWarn(msg))
+	logger.Debugf("User %s enter invalid list ID %d", u.user.Name, num)
+}
+
+// proxyDynamicHostFromKey 搜索不到资产时，key 属于允许的域则连接动态主机
+func (u *UserSelectHandler) proxyDynamicHostFromKey(key string) bool {
+	switch u.currentType {
+	case TypeAsset, TypeHost, TypeAll:
+	default:
+		return false
+	}
+	zones := dynamicHostZones()
+	if len(zones) == 0 {
+		return false
+	}
+	key = strings.TrimSpace(key)
+	zone, ok := matchHostZone(zones, key)
+	if !ok {
+		if looksLikeFQDN(key) {
+			lang := i18n.NewLang(u.h.i18nLang)
+			msg := fmt.Sprintf(lang.T("Host %s is not in the allowed domains"), key)
+			utils.IgnoreErrWriteString(u.h.term, utils.WrapperWarn(msg))
+			logger.Infof("User %s refused to connect dynamic host %s", u.user.Name, key)
+		}
+		return false
 	}
+	u.proxyDynamicHost(key, zone)
+	return true
+}
 
+// searchUnique 搜索 key 并更新当前结果，结果唯一时返回该资产
+func (u *UserSelectHandler) searchUnique(key string) (model.Asset, bool) {
+	u.searchRegexps = nil
+	u.fieldFilters = nil
+	u.clearFilterCache()
 	newPageSize := getPageSize(u.h, u.h.terminalConf)
 	currentResult := u.Retrieve(newPageSize, 0, key)
 	u.currentResult = currentResult
 	u.searchKeys = []string{key}
 	if len(currentResult) == 1 {
-		u.Proxy(currentResult[0])
-		return
+		return currentResult[0], true
 	}
 
 	// 资产类型, 返回结果 ip 或者 hostname 与 key 完全一样则直接登录
 	switch u.currentType {
-	case TypeAsset:
+	case TypeAsset, TypeAll:
 		if strings.TrimSpace(key) != "" {
 			if ret, ok := getUniqueAssetFromKey(key, currentResult); ok {
-				u.Proxy(ret)
-				return
+				return ret, true
 			}
 		}
 	}
-	u.DisplayCurrentResult()
+	return model.Asset{}, false
 }
 
 func (u *UserSelectHandler) HasPrev() bool {
@@ -198,7 +354,18 @@ func (u *UserSelectHandler) HasNext() bool {
 
 func (u *UserSelectHandler) DisplayCurrentResult() {
 	lang := i18n.NewLang(u.h.i18nLang)
-	searchHeader := fmt.Sprintf(lang.T("Search: %s"), strings.Join(u.searchKeys, " "))
+	searchWords := make([]string, 0, len(u.searchKeys)+len(u.fieldFilters)+len(u.searchRegexps))
+	searchWords = append(searchWords, u.searchKeys...)
+	for i := range u.fieldFilters {
+		searchWords = append(searchWords, u.fieldFilters[i].String())
+	}
+	for i := range u.searchRegexps {
+		searchWords = append(searchWords, "//"+u.searchRegexps[i].String())
+	}
+	searchHeader := fmt.Sprintf(lang.T("Search: %s"), strings.Join(searchWords, " "))
+	if u.platformFilter != "" {
+		searchHeader += "  " + fmt.Sprintf(lang.T("Platform: %s (enter @ to clear)"), u.platformFilter)
+	}
 	switch u.currentType {
 	case TypeDatabase:
 		u.displayDatabaseResult(searchHeader)
@@ -208,18 +375,35 @@ func (u *UserSelectHandler) DisplayCurrentResult() {
 		u.displayNodeAssetResult(searchHeader)
 	case TypeAsset:
 		u.displayAssetResult(searchHeader)
-	case TypeHost:
+	case TypeHost, TypeWindows:
 		u.displayAssetResult(searchHeader)
+	case TypeFavorite:
+		u.displayFavoriteAssetResult(searchHeader)
+	case TypeRecentSession:
+		u.displayRecentSessionResult(searchHeader)
+	case TypeAll:
+		u.displayResourceResult(searchHeader)
 	default:
 		logger.Error("Display unknown type")
 	}
 }
 
 func (u *UserSelectHandler) Proxy(target model.Asset) {
+	switch u.currentType {
+	case TypeWindows:
+		u.proxyRDPAsset(target)
+		return
+	case TypeAll:
+		u.proxyResource(target)
+		return
+	}
 	u.proxyAsset(target)
 }
 
 func (u *UserSelectHandler) Retrieve(pageSize, offset int, searches ...string) []model.Asset {
+	if len(u.searchRegexps) > 0 || u.platformFilter != "" || len(u.fieldFilters) > 0 {
+		return u.retrieveWithFilters(pageSize, offset, searches...)
+	}
 	switch u.loadingPolicy {
 	case loadingFromLocal:
 		return u.retrieveFromLocal(pageSize, offset, searches...)
@@ -235,8 +419,74 @@ func (u *UserSelectHandler) retrieveFromLocal(pageSize, offset int, searches ...
 	if offset < 0 {
 		offset = 0
 	}
+	return u.paginateLocalData(u.retrieveLocal(searches...), pageSize, offset)
+}
 
-	searchResult := u.retrieveLocal(searches...)
+// retrieveWithFilters 获取全部搜索结果后使用正则、平台和指定字段的条件过滤，并在本地分页
+func (u *UserSelectHandler) retrieveWithFilters(pageSize, offset int, searches ...string) []model.Asset {
+	if pageSize <= 0 {
+		pageSize = PAGESIZEALL
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	var candidates []model.Asset
+	switch u.loadingPolicy {
+	case loadingFromLocal:
+		candidates = u.retrieveLocal(searches...)
+	default:
+		candidates = u.retrieveRemoteCandidates(searches...)
+	}
+	// 与搜索使用相同的字段
+	allFields := []string{"name", "address", "platform", "org_name", "comment"}
+	fields := make([]string, 0, len(allFields))
+	for i := range allFields {
+		if u.isHiddenField(allFields[i]) {
+			continue
+		}
+		fields = append(fields, allFields[i])
+	}
+	matched := make([]model.Asset, 0, len(candidates))
+	// 最近会话的记录需要与过滤后的资产一一对应
+	recentSessions := make([]recentSession, 0, len(u.recentSessions))
+	for i := range candidates {
+		data := assetSearchFieldsMap(&candidates[i])
+		if matchPlatform(&candidates[i], u.platformFilter) && matchFieldFilters(&candidates[i], u.fieldFilters) &&
+			matchRegexpsInMapItemFields(data, fields, u.searchRegexps) {
+			matched = append(matched, candidates[i])
+			if u.currentType == TypeRecentSession && i < len(u.recentSessions) {
+				recentSessions = append(recentSessions, u.recentSessions[i])
+			}
+		}
+	}
+	if u.currentType == TypeRecentSession {
+		u.recentSessions = recentSessions
+	}
+	return u.paginateLocalData(matched, pageSize, offset)
+}
+
+// retrieveRemoteCandidates 从 core 获取全部搜索结果，同一列表和搜索条件翻页时使用上次的结果
+func (u *UserSelectHandler) retrieveRemoteCandidates(searches ...string) []model.Asset {
+	key := fmt.Sprintf("%d|%s|%s", u.currentType, u.selectedNode.ID, strings.Join(searches, "\x00"))
+	if u.filterCacheAssets != nil && u.filterCacheKey == key {
+		return u.filterCacheAssets
+	}
+	candidates := u.retrieveFromRemote(PAGESIZEALL, 0, searches...)
+	u.sortAssets(candidates)
+	// 获取失败时结果为空，不缓存
+	if len(candidates) > 0 {
+		u.filterCacheKey = key
+		u.filterCacheAssets = candidates
+	}
+	return candidates
+}
+
+func (u *UserSelectHandler) clearFilterCache() {
+	u.filterCacheKey = ""
+	u.filterCacheAssets = nil
+}
+
+func (u *UserSelectHandler) paginateLocalData(searchResult []model.Asset, pageSize, offset int) []model.Asset {
 	var (
 		totalData       []model.Asset
 		total           int
@@ -247,14 +497,14 @@ func (u *UserSelectHandler) retrieveFromLocal(pageSize, offset int, searches ...
 	if offset < len(searchResult) {
 		totalData = searchResult[offset:]
 	}
-	total = len(totalData)
+	total = len(searchResult)
 	currentPageSize = pageSize
 	currentData := totalData
 
 	if currentPageSize < 0 || currentPageSize == PAGESIZEALL {
 		currentPageSize = len(totalData)
 	}
-	if total > currentPageSize {
+	if len(totalData) > currentPageSize {
 		currentData = totalData[:currentPageSize]
 	}
 	currentOffset = offset + len(currentData)
@@ -274,6 +524,14 @@ func (u *UserSelectHandler) retrieveLocal(searches ...string) []model.Asset {
 	switch u.currentType {
 	case TypeAsset, TypeHost, TypeDatabase, TypeK8s:
 		return u.searchLocalAsset(searches...)
+	case TypeWindows:
+		return filterRDPAssets(u.searchLocalAsset(searches...))
+	case TypeAll:
+		return u.filterResourceAssets(u.searchLocalAsset(searches...))
+	case TypeFavorite:
+		return u.searchFavoriteAsset(searches...)
+	case TypeRecentSession:
+		return u.searchRecentSession(searches...)
 	default:
 		// TypeAsset
 		u.SetSelectType(TypeAsset)
@@ -283,16 +541,12 @@ func (u *UserSelectHandler) retrieveLocal(searches ...string) []model.Asset {
 }
 
 func (u *UserSelectHandler) searchLocalFromFields(fields map[string]struct{}, searches ...string) []model.Asset {
+	if u.h.terminalConf.FuzzySearch && !isEmptySearches(searches) {
+		return u.fuzzySearchLocalFromFields(fields, searches...)
+	}
 	items := make([]model.Asset, 0, len(u.allLocalData))
 	for i := range u.allLocalData {
-		assetData := u.allLocalData[i]
-		data := map[string]interface{}{
-			"name":     u.allLocalData[i].Name,
-			"address":  assetData.Address,
-			"org_name": assetData.OrgName,
-			"platform": assetData.Platform.Name,
-			"comment":  assetData.Comment,
-		}
+		data := assetSearchFieldsMap(&u.allLocalData[i])
 		if containKeysInMapItemFields(data, fields, searches...) {
 			items = append(items, u.allLocalData[i])
 		}
@@ -300,6 +554,45 @@ func (u *UserSelectHandler) searchLocalFromFields(fields map[string]struct{}, se
 	return items
 }
 
+// fuzzySearchLocalFromFields 模糊匹配，结果按照匹配得分降序，得分相同则按名称排序
+func (u *UserSelectHandler) fuzzySearchLocalFromFields(fields map[string]struct{}, searches ...string) []model.Asset {
+	items := make([]scoredAsset, 0, len(u.allLocalData))
+	for i := range u.allLocalData {
+		data := assetSearchFieldsMap(&u.allLocalData[i])
+		if score, ok := fuzzyScoreInMapItemFields(data, fields, searches...); ok {
+			items = append(items, scoredAsset{asset: u.allLocalData[i], score: score})
+		}
+	}
+	sortByCollation(u.h.i18nLang, len(items), func(i int) string {
+		return items[i].asset.Name
+	}, func(i, j int) {
+		items[i], items[j] = items[j], items[i]
+	})
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].score > items[j].score
+	})
+	result := make([]model.Asset, len(items))
+	for i := range items {
+		result[i] = items[i].asset
+	}
+	return result
+}
+
+type scoredAsset struct {
+	asset model.Asset
+	score int
+}
+
+func assetSearchFieldsMap(asset *model.Asset) map[string]interface{} {
+	return map[string]interface{}{
+		"name":     asset.Name,
+		"address":  asset.Address,
+		"org_name": asset.OrgName,
+		"platform": asset.Platform.Name,
+		"comment":  asset.Comment,
+	}
+}
+
 func (u *UserSelectHandler) retrieveFromRemote(pageSize, offset int, searches ...string) []model.Asset {
 
 	var order string
@@ -334,6 +627,14 @@ func (u *UserSelectHandler) retrieveFromRemote(pageSize, offset int, searches ..
 		reqParam.Category = "host"
 		reqParam.Protocols = srvconn.SupportedHostProtocols()
 		return u.retrieveRemoteAsset(reqParam)
+	case TypeWindows:
+		reqParam.Category = "host"
+		reqParam.Protocols = []string{protocolRDP}
+		return u.retrieveRemoteAsset(reqParam)
+	case TypeAll:
+		reqParam.Category = ""
+		reqParam.Protocols = u.resourceSearchProtocols()
+		return u.retrieveRemoteAsset(reqParam)
 	default:
 		reqParam.Category = ""
 		reqParam.Protocols = srvconn.SupportedProtocols()
@@ -366,9 +667,55 @@ func (u *UserSelectHandler) updateRemotePageData(reqParam model.PaginationParam,
 	}
 	currentOffset := reqParam.Offset + len(currentData)
 	u.updatePageInfo(currentPageSize, total, currentOffset)
+	// core 已经按名称分页，只能在当前页内按语言的规则排序
+	u.sortAssets(currentData)
 	return currentData
 }
 
+// splitPlatformFilter 拆分搜索中 @ 开头的平台过滤条件，如 "web @linux"，
+// 只输入 @ 表示清除平台过滤，ok 表示搜索中包含平台过滤条件
+func splitPlatformFilter(key string) (rest, platform string, ok bool) {
+	words := strings.Fields(key)
+	keys := make([]string, 0, len(words))
+	for i := range words {
+		if strings.HasPrefix(words[i], "@") {
+			platform = words[i][1:]
+			ok = true
+			continue
+		}
+		keys = append(keys, words[i])
+	}
+	if !ok {
+		return key, "", false
+	}
+	return strings.Join(keys, " "), platform, true
+}
+
+// matchPlatform 资产的平台名称包含过滤条件，不区分大小写
+func matchPlatform(asset *model.Asset, platform string) bool {
+	if platform == "" {
+		return true
+	}
+	return strings.Contains(strings.ToLower(asset.Platform.Name), strings.ToLower(platform))
+}
+
+// matchRegexpsInMapItemFields 每个正则都需要匹配至少一个字段
+func matchRegexpsInMapItemFields(item map[string]interface{}, fields []string, regexps []*regexp.Regexp) bool {
+	for i := range regexps {
+		matched := false
+		for j := range fields {
+			if value, ok := item[fields[j]].(string); ok && regexps[i].MatchString(value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
 func containKeysInMapItemFields(item map[string]interface{},
 	searchFields map[string]struct{}, matchedKeys ...string) bool {
 
@@ -398,6 +745,44 @@ func containKeysInMapItemFields(item map[string]interface{},
 	return false
 }
 
+func isEmptySearches(searches []string) bool {
+	for i := range searches {
+		if strings.TrimSpace(searches[i]) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func fuzzyScoreInMapItemFields(item map[string]interface{},
+	searchFields map[string]struct{}, matchedKeys ...string) (int, bool) {
+	var (
+		bestScore int
+		matched   bool
+	)
+	for key, value := range item {
+		if _, ok := searchFields[key]; !ok {
+			continue
+		}
+		result, ok := value.(string)
+		if !ok {
+			continue
+		}
+		for i := range matchedKeys {
+			if matchedKeys[i] == "" {
+				continue
+			}
+			if score, ok2 := utils.FuzzyMatch(matchedKeys[i], result); ok2 {
+				if !matched || score > bestScore {
+					bestScore = score
+				}
+				matched = true
+			}
+		}
+	}
+	return bestScore, matched
+}
+
 func joinMultiLineString(lines string) string {
 	lines = strings.ReplaceAll(lines, "\r", "\n")
 	lines = strings.ReplaceAll(lines, "\n\n", "\n")
@@ -417,10 +802,7 @@ func getUniqueAssetFromKey(key string, currentResult []model.Asset) (data model.
 	result := make([]int, 0, len(currentResult))
 	for i := range currentResult {
 		asset := currentResult[i]
-		ip := asset.Address
-		hostname := asset.Name
-		switch key {
-		case ip, hostname:
+		if key == asset.Name || common.HostEqual(asset.Address, key) {
 			result = append(result, i)
 		}
 	}
@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestSplitPlatformFilter(t *testing.T) {
+	tests := []struct {
+		key      string
+		rest     string
+		platform string
+		ok       bool
+	}{
+		{key: "web", rest: "web"},
+		{key: "web @linux", rest: "web", platform: "linux", ok: true},
+		{key: "@Windows", platform: "Windows", ok: true},
+		{key: "@", ok: true},
+	}
+	for _, tt := range tests {
+		rest, platform, ok := splitPlatformFilter(tt.key)
+		if rest != tt.rest || platform != tt.platform || ok != tt.ok {
+			t.Errorf("splitPlatformFilter(%q) = %q, %q, %v, want %q, %q, %v",
+				tt.key, rest, platform, ok, tt.rest, tt.platform, tt.ok)
+		}
+	}
+}
+
+func TestRetrieveWithPlatformFilter(t *testing.T) {
+	u := &UserSelectHandler{
+		h:              &InteractiveHandler{terminalConf: &model.TerminalConfig{}},
+		currentType:    TypeAsset,
+		loadingPolicy:  loadingFromLocal,
+		platformFilter: "linux",
+		pageInfo:       &pageInfo{},
+	}
+	u.SetAllLocalData([]model.Asset{
+		{ID: "1", Name: "web-1", Platform: model.BasePlatform{Name: "Linux"}},
+		{ID: "2", Name: "web-2", Platform: model.BasePlatform{Name: "Windows"}},
+		{ID: "3", Name: "db-1", Platform: model.BasePlatform{Name: "Linux"}},
+	})
+	result := u.Retrieve(10, 0, "web")
+	if len(result) != 1 || result[0].ID != "1" {
+		t.Errorf("Retrieve(web @linux) = %+v, want web-1", result)
+	}
+	u.platformFilter = ""
+	if result = u.Retrieve(10, 0, "web"); len(result) != 2 {
+		t.Errorf("Retrieve(web) without filter = %d assets, want 2", len(result))
+	}
+}
+
+func TestRetrieveWithFieldFilters(t *testing.T) {
+	u := &UserSelectHandler{
+		h:             &InteractiveHandler{terminalConf: &model.TerminalConfig{}},
+		currentType:   TypeAsset,
+		loadingPolicy: loadingFromLocal,
+		pageInfo:      &pageInfo{},
+	}
+	u.SetAllLocalData([]model.Asset{
+		{ID: "1", Name: "payments-web", Comment: "owner: web team"},
+		{ID: "2", Name: "web-2", Comment: "owner: Payments team",
+			Labels: []model.Label{{Name: "team", Value: "team-x"}}},
+		{ID: "3", Name: "db-1", Comment: "payments database"},
+	})
+	rest, filters, unknown := u.splitFieldFilters("web comment:payments owner:bob")
+	if rest != "web owner:bob" || len(filters) != 1 || filters[0].String() != "comment:payments" ||
+		len(unknown) != 1 || unknown[0] != "owner" {
+		t.Fatalf("splitFieldFilters() = %q, %v, %v", rest, filters, unknown)
+	}
+	u.fieldFilters = filters
+	if result := u.Retrieve(10, 0, "web"); len(result) != 1 || result[0].ID != "2" {
+		t.Errorf("Retrieve(web comment:payments) = %+v, want web-2", result)
+	}
+	u.fieldFilters = []fieldFilter{{field: "tag", value: "TEAM-X"}}
+	if result := u.Retrieve(10, 0, ""); len(result) != 1 || result[0].ID != "2" {
+		t.Errorf("Retrieve(tag:team-x) = %+v, want web-2", result)
+	}
+	u.hiddenFields = map[string]struct{}{"tag": {}}
+	if _, filters, unknown = u.splitFieldFilters("tag:team-x"); filters != nil || len(unknown) != 1 {
+		t.Errorf("hidden field tag is searchable: %v, %v", filters, unknown)
+	}
+}
+
+func TestRetrieveAllResources(t *testing.T) {
+	u := &UserSelectHandler{
+		h:             &InteractiveHandler{terminalConf: &model.TerminalConfig{}},
+		currentType:   TypeAll,
+		loadingPolicy: loadingFromLocal,
+		pageInfo:      &pageInfo{},
+	}
+	u.SetAllLocalData([]model.Asset{
+		{ID: "1", Name: "web-1", Protocols: []model.Protocol{{Name: "ssh"}}},
+		{ID: "2", Name: "web-db", Protocols: []model.Protocol{{Name: "mysql"}}},
+		{ID: "3", Name: "web-k8s", Protocols: []model.Protocol{{Name: "k8s"}}},
+		{ID: "4", Name: "web-win", Protocols: []model.Protocol{{Name: "rdp"}}},
+		{ID: "5", Name: "web-vnc", Protocols: []model.Protocol{{Name: "vnc"}}},
+	})
+	// 没有开启 RDP 网关时不包括 Windows 资产
+	result := u.Retrieve(10, 0, "web")
+	if len(result) != 3 {
+		t.Fatalf("Retrieve(web) = %+v, want host, database and k8s", result)
+	}
+	want := map[string]string{"1": resourceHost, "2": resourceDatabase, "3": resourceK8s}
+	for i := range result {
+		if got := assetResourceType(&result[i]); got != want[result[i].ID] {
+			t.Errorf("assetResourceType(%s) = %s, want %s", result[i].Name, got, want[result[i].ID])
+		}
+	}
+	_, filters, _ := u.splitFieldFilters("web type:database")
+	u.fieldFilters = filters
+	if result = u.Retrieve(10, 0, "web"); len(result) != 1 || result[0].ID != "2" {
+		t.Errorf("Retrieve(web type:database) = %+v, want web-db", result)
+	}
+	u.fieldFilters = []fieldFilter{{field: "type", value: "unknown"}}
+	if result = u.Retrieve(10, 0, "web"); len(result) != 0 {
+		t.Errorf("Retrieve(web type:unknown) = %+v, want empty", result)
+	}
+}
+
+func TestIsPageIndex(t *testing.T) {
+	u := &UserSelectHandler{pageInfo: &pageInfo{pageSize: 20}, currentResult: make([]model.Asset, 5)}
+	for num, want := range map[int]bool{0: true, 7: true, 20: true, 21: false, 192: false} {
+		if got := u.isPageIndex(num); got != want {
+			t.Errorf("isPageIndex(%d) with page size 20 = %v, want %v", num, got, want)
+		}
+	}
+	// 显示全部结果时按结果的数量
+	u.pageInfo = &pageInfo{pageSize: PAGESIZEALL}
+	if u.isPageIndex(6) || !u.isPageIndex(5) {
+		t.Error("isPageIndex() without page size should use the result count")
+	}
+}
+
+func TestPaginateLocalDataLastPage(t *testing.T) {
+	assets := make([]model.Asset, 25, 30)
+	for i := range assets {
+		assets[i].ID = strconv.Itoa(i + 1)
+	}
+	u := &UserSelectHandler{pageInfo: &pageInfo{}}
+	result := u.paginateLocalData(assets, 10, 20)
+	if len(result) != 5 || result[0].ID != "21" || result[4].ID != "25" {
+		t.Fatalf("paginateLocalData() last page = %d assets, want 21-25", len(result))
+	}
+	if u.currentPage != 3 || u.totalPage != 3 || !u.hasPre || u.hasNext {
+		t.Errorf("page %d/%d pre %v next %v, want 3/3 with previous page only",
+			u.currentPage, u.totalPage, u.hasPre, u.hasNext)
+	}
+	if result = u.paginateLocalData(assets, 10, 10); len(result) != 10 || !u.hasNext {
+		t.Errorf("paginateLocalData() middle page = %d assets, next %v", len(result), u.hasNext)
+	}
+}
+
+func TestRetrieveRemoteWithRegexps(t *testing.T) {
+	var requests int
+	jms := newTestJMService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assets := make([]model.Asset, 0, 15)
+		for i := 1; i <= 15; i++ {
+			assets = append(assets, model.Asset{ID: strconv.Itoa(i), Name: "web-" + strconv.Itoa(i),
+				Platform: model.BasePlatform{Name: "Linux"}, OrgName: "Default"})
+		}
+		assets = append(assets, model.Asset{ID: "16", Name: "web-16", Platform: model.BasePlatform{Name: "Windows"}})
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(assets)
+	}))
+	u := &UserSelectHandler{
+		user:          &model.User{ID: "user-1"},
+		h:             &InteractiveHandler{terminalConf: &model.TerminalConfig{}, jmsService: jms, i18nLang: "en"},
+		currentType:   TypeAsset,
+		loadingPolicy: loadingFromRemote,
+		pageInfo:      &pageInfo{},
+	}
+	// 与搜索相同，正则也匹配平台和组织
+	u.searchRegexps = []*regexp.Regexp{regexp.MustCompile("^linux$|^Linux$")}
+	if result := u.Retrieve(10, 0, "web"); len(result) != 10 {
+		t.Fatalf("Retrieve(web //linux) first page = %d assets, want 10", len(result))
+	}
+	if result := u.Retrieve(10, 10, "web"); len(result) != 5 {
+		t.Errorf("Retrieve(web //linux) second page = %d assets, want 5", len(result))
+	}
+	u.searchRegexps = []*regexp.Regexp{regexp.MustCompile("^Default$")}
+	if result := u.Retrieve(20, 0, "web"); len(result) != 15 {
+		t.Errorf("Retrieve(web //^Default$) = %d assets, want the assets of org Default", len(result))
+	}
+	if requests != 1 {
+		t.Errorf("core requested %d times, want the search result reused across pages", requests)
+	}
+	u.clearFilterCache()
+	u.Retrieve(10, 0, "web")
+	if requests != 2 {
+		t.Errorf("core requested %d times after clearing the cache, want 2", requests)
+	}
+}
@@ -16,8 +16,10 @@ import (
 	"github.com/jumpserver/koko/pkg/auth"
 	"github.com/jumpserver/koko/pkg/common"
 	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/credential"
 	"github.com/jumpserver/koko/pkg/i18n"
 	modelCommon "github.com/jumpserver/koko/pkg/jms-sdk-go/common"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/httplib"
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/service"
 	"github.com/jumpserver/koko/pkg/logger"
@@ -58,6 +60,9 @@ func (s *Server) SFTPHandler(sess ssh.Session) {
 		logger.Errorf("SFTP User not found, exit.")
 		return
 	}
+	if sessionMFARejected(sess.Context(), currentUser, "sftp") {
+		return
+	}
 	addr, _, _ := net.SplitHostPort(sess.RemoteAddr().String())
 	directReq := sess.Context().Value(auth.ContextKeyDirectLoginFormat)
 	var sftpHandler *SftpHandler
@@ -97,8 +102,12 @@ func (s *Server) SFTPHandler(sess ssh.Session) {
 }
 
 func (s *Server) LocalPortForwardingPermission(ctx ssh.Context, dstHost string, dstPort uint32) bool {
-	logger.Debugf("LocalPortForwardingPermission: %s %s %d", ctx.User(), dstHost, dstPort)
-	return config.GlobalConfig.EnableLocalPortForward
+	logger.Debugf("LocalPortForwardingPermission: %s %s %d", auth.RedactUsername(ctx.User()), dstHost, dstPort)
+	if !config.GetConf().EnableLocalPortForward {
+		return false
+	}
+	user, ok := ctx.Value(auth.ContextKeyUser).(*model.User)
+	return ok && !sessionMFARejected(ctx, user, "port forwarding")
 }
 
 func (s *Server) DirectTCPIPChannelHandler(ctx ssh.Context, newChan gossh.NewChannel, destAddr string) {
@@ -111,6 +120,11 @@ func (s *Server) DirectTCPIPChannelHandler(ctx ssh.Context, newChan gossh.NewCha
 		_ = newChan.Reject(gossh.Prohibited, "port forwarding is disabled")
 		return
 	}
+	user, ok := ctx.Value(auth.ContextKeyUser).(*model.User)
+	if !ok || sessionMFARejected(ctx, user, "port forwarding") {
+		_ = newChan.Reject(gossh.Prohibited, "session MFA required")
+		return
+	}
 	vsReq := s.getVSCodeReq(reqId)
 	if vsReq == nil {
 		_ = newChan.Reject(gossh.Prohibited, "port forwarding is disabled")
@@ -145,12 +159,18 @@ func (s *Server) DirectTCPIPChannelHandler(ctx ssh.Context, newChan gossh.NewCha
 func (s *Server) SessionHandler(sess ssh.Session) {
 	user, ok := sess.Context().Value(auth.ContextKeyUser).(*model.User)
 	if !ok || user.ID == "" {
-		logger.Errorf("SSH User %s not found, exit.", sess.User())
+		logger.Errorf("SSH User %s not found, exit.", auth.RedactUsername(sess.User()))
 		utils.IgnoreErrWriteString(sess, "Not auth user.\n")
 		return
 	}
 	termConf := s.GetTerminalConfig()
 	directReq := sess.Context().Value(auth.ContextKeyDirectLoginFormat)
+	// 没有使用直连格式的 exec 请求执行命令后退出，申请了 pty 时单个参数作为目标资产进入交互菜单
+	_, _, isPty := sess.Pty()
+	if _, ok2 := directReq.(*auth.DirectLoginAssetReq); !ok2 && isExecRequest(sess.Command(), isPty) {
+		_ = sess.Exit(s.runExecCommand(sess, user))
+		return
+	}
 	if pty, winChan, isPty := sess.Pty(); isPty {
 		if directRequest, ok3 := directReq.(*auth.DirectLoginAssetReq); ok3 {
 			selectedAssets, err := s.getMatchedAssetsByDirectReq(user, directRequest)
@@ -171,11 +191,17 @@ func (s *Server) SessionHandler(sess ssh.Session) {
 		logger.Infof("User %s request pty %s", sess.User(), pty.Term)
 		go interactiveSrv.WatchWinSizeChange(winChan)
 		interactiveSrv.Dispatch()
-		utils.IgnoreErrWriteWindowTitle(sess, termConf.HeaderTitle)
+		utils.IgnoreErrWriteWindowTitle(sess, sanitizeWindowTitle(termConf.HeaderTitle))
 		return
 	}
 
 	if directRequest, ok3 := directReq.(*auth.DirectLoginAssetReq); ok3 {
+		// 没有 pty 时无法输入动态码
+		if sessionMFARejected(sess.Context(), user, "direct login without pty") {
+			utils.IgnoreErrWriteString(sess.Stderr(), "session MFA required, please request a pty (ssh -t)\n")
+			_ = sess.Exit(execExitError)
+			return
+		}
 		if directRequest.IsToken() {
 			tokenInfo := directRequest.ConnectToken
 			matchedProtocol := tokenInfo.Protocol == model.ProtocolSSH
@@ -232,8 +258,8 @@ func (s *Server) SessionHandler(sess ssh.Session) {
 		default:
 			s.proxyDirectRequest(sess, user, selectedAssets[0], selectAccount)
 		}
+		return
 	}
-
 }
 
 func (s *Server) proxyDirectRequest(sess ssh.Session, user *model.User, asset model.Asset,
@@ -272,18 +298,8 @@ func (s *Server) proxyTokenInfo(sess ssh.Session, tokeInfo *model.ConnectToken)
 		utils.IgnoreErrWriteString(sess, "not found ctx id")
 		return
 	}
-	asset := tokeInfo.Asset
-	account := tokeInfo.Account
-	var gateways []model.Gateway
-	// todo：domain
-	if tokeInfo.Gateway != nil {
-		gateways = []model.Gateway{*tokeInfo.Gateway}
-	}
-
-	sshAuthOpts := buildSSHClientOptions(&asset, &account, gateways)
-	sshClient, err := srvconn.NewSSHClient(sshAuthOpts...)
+	sshClient, err := s.newTokenSSHClient(tokeInfo)
 	if err != nil {
-		logger.Errorf("Get SSH Client failed: %s", err)
 		utils.IgnoreErrWriteString(sess, err.Error())
 		return
 	}
@@ -297,7 +313,7 @@ func (s *Server) proxyTokenInfo(sess ssh.Session, tokeInfo *model.ConnectToken)
 	s.addVSCodeReq(vsReq)
 	defer s.deleteVSCodeReq(vsReq)
 	if len(sess.Command()) != 0 {
-		s.proxyAssetCommand(sess, sshClient, tokeInfo)
+		_ = sess.Exit(s.proxyAssetCommand(sess, sshClient, tokeInfo, sess.RawCommand()))
 		return
 	}
 
@@ -311,42 +327,65 @@ func (s *Server) proxyTokenInfo(sess ssh.Session, tokeInfo *model.ConnectToken)
 	}
 }
 
+// newTokenSSHClient 使用连接令牌中的资产、账号和网关连接资产
+func (s *Server) newTokenSSHClient(tokeInfo *model.ConnectToken, opts ...srvconn.SSHClientOption) (*srvconn.SSHClient, error) {
+	if err := credential.ResolveConnectToken(tokeInfo); err != nil {
+		logger.Errorf("Resolve account %s credential failed: %s", tokeInfo.Account.String(), err)
+		return nil, err
+	}
+	if err := credential.ResolveLoginUser(tokeInfo); err != nil {
+		logger.Errorf("Resolve account %s login user failed: %s", tokeInfo.Account.String(), err)
+		return nil, err
+	}
+	asset := tokeInfo.Asset
+	account := tokeInfo.Account
+	var gateways []model.Gateway
+	// todo：domain
+	if tokeInfo.Gateway != nil {
+		gateways = []model.Gateway{*tokeInfo.Gateway}
+	}
+
+	sshAuthOpts := buildSSHClientOptions(&asset, &account, gateways)
+	if len(tokeInfo.GatewayChain) > 0 {
+		hops := srvconn.NewGatewayChainOptions(tokeInfo.GatewayChain, config.GetConf().SSHTimeout)
+		sshAuthOpts = append(sshAuthOpts, srvconn.SSHClientGatewayChain(hops...))
+	}
+	sshAuthOpts = append(sshAuthOpts, opts...)
+	sshClient, err := srvconn.NewSSHClient(sshAuthOpts...)
+	if err != nil {
+		logger.Errorf("Get SSH Client failed: %s", err)
+		return nil, err
+	}
+	return sshClient, nil
+}
+
+/*
+proxyAssetCommand 在资产上执行命令，stdout 和 stderr 分别写回客户端，返回命令的退出码。
+命令与交互会话一样创建会话、记录命令和输出，命中拒绝、复核规则的命令不执行，记录为拒绝的命令后返回非 0 退出码。
+*/
+
 func (s *Server) proxyAssetCommand(sess ssh.Session, sshClient *srvconn.SSHClient,
-	tokeInfo *model.ConnectToken) {
-	rawStr := sess.RawCommand()
+	tokeInfo *model.ConnectToken, rawStr string) int {
 	if strings.HasPrefix(rawStr, "scp") && !config.GetConf().EnableVscodeSupport {
 		logger.Errorf("Not support scp command: %s", rawStr)
-		utils.IgnoreErrWriteString(sess, "Not support scp command")
-		return
+		utils.IgnoreErrWriteString(sess.Stderr(), "Not support scp command\n")
+		return execExitError
 	} else {
 		// 开启了 vscode 支持，放开使用 scp 命令传输文件
 		// todo: 解析 scp 数据包，获取文件信息
 		logger.Infof("Execute scp command: %s", rawStr)
 	}
-	// todo: 暂且不支持 acl 工单
-	acls := tokeInfo.CommandFilterACLs
-	for i := range acls {
-		acl := acls[i]
-		_, action, _ := acl.Match(rawStr)
-		switch action {
-		case model.ActionReview:
-			msg := "SSH Command not support ACL review ticket"
-			utils.IgnoreErrWriteString(sess, msg)
-			logger.Errorf("SSH Command not support ACL review ticket `%s`", rawStr)
-			return
-		case model.ActionReject:
-			logger.Errorf("ACL reject execute %s ", rawStr)
-			return
-		default:
-		}
-	}
 
 	host, _, _ := net.SplitHostPort(sess.RemoteAddr().String())
 	reqSession := tokeInfo.CreateSession(host, model.LoginFromSSH, model.COMMANDType)
+	if reqSession.Tags = proxy.SessionTags(sess.Environ()); len(reqSession.Tags) > 0 {
+		logger.Infof("Command session tags: %s", proxy.FormatSessionTags(reqSession.Tags))
+	}
 	respSession, err := s.jmsService.CreateSession(reqSession)
 	if err != nil {
 		logger.Errorf("Create command session err: %s", err)
-		return
+		utils.IgnoreErrWriteString(sess.Stderr(), fmt.Sprintf("create session failed: %s\n", err))
+		return execExitError
 	}
 	ctx, cancel := context.WithCancel(sess.Context())
 	defer cancel()
@@ -360,17 +399,61 @@ func (s *Server) proxyAssetCommand(sess ssh.Session, sshClient *srvconn.SSHClien
 	})
 	session.AddSession(traceSession)
 
+	exitReason := model.ExitConnectFailed
 	defer func() {
-		if err2 := s.jmsService.SessionFinished(respSession.ID, modelCommon.NewNowUTCTime()); err2 != nil {
+		reason := sessionExitReason(ctx, sess.Context(), exitReason)
+		if err2 := s.jmsService.SessionFinished(respSession.ID, modelCommon.NewNowUTCTime(), reason); err2 != nil {
 			logger.Errorf("Create tunnel session err: %s", err)
 		}
 		session.RemoveSession(traceSession)
 	}()
 
+	now := time.Now()
+	cmd := model.Command{
+		SessionID:   respSession.ID,
+		OrgID:       respSession.OrgID,
+		Input:       rawStr,
+		User:        respSession.User,
+		Server:      respSession.Asset,
+		Account:     respSession.Account,
+		Timestamp:   now.Unix(),
+		DateCreated: now,
+	}
+	saveCommand := func() {
+		termCfg := s.GetTerminalConfig()
+		cmdStorage := proxy.NewCommandStorage(s.jmsService, &termCfg)
+		if err2 := cmdStorage.BulkSave([]*model.Command{&cmd}); err2 != nil {
+			logger.Errorf("Create command err: %s", err2)
+		}
+	}
+
+	// todo: 暂且不支持 acl 工单，复核的命令与拒绝的命令一样不执行
+	if acl, action := s.matchExecCommandACL(respSession.ID, tokeInfo, rawStr); acl != nil {
+		cmd.CmdFilterAclId = acl.ID
+		switch action {
+		case model.ActionReject, model.ActionReview:
+			logger.Errorf("ACL %s %s execute %s", acl.Name, action, rawStr)
+			cmd.RiskLevel = model.RejectLevel
+			exitReason = model.ExitCommandFinished
+			saveCommand()
+			msg := fmt.Sprintf("Command `%s` is forbidden by rule %s\n", rawStr, acl.Name)
+			if action != model.ActionReject {
+				msg = fmt.Sprintf("Command `%s` need %s by rule %s, not supported in exec mode\n",
+					rawStr, action, acl.Name)
+			}
+			utils.IgnoreErrWriteString(sess.Stderr(), msg)
+			return execExitError
+		case model.ActionWarning:
+			cmd.RiskLevel = model.WarningLevel
+		default:
+		}
+	}
+
 	goSess, err := sshClient.AcquireSession()
 	if err != nil {
 		logger.Errorf("Get SSH session failed: %s", err)
-		return
+		utils.IgnoreErrWriteString(sess.Stderr(), fmt.Sprintf("connect asset failed: %s\n", err))
+		return execExitUnavailable
 	}
 	defer goSess.Close()
 	defer sshClient.ReleaseSession(goSess)
@@ -379,61 +462,35 @@ func (s *Server) proxyAssetCommand(sess ssh.Session, sshClient *srvconn.SSHClien
 		_ = goSess.Close()
 	}()
 
-	goSess.Stdin = sess
-	out, err := goSess.StdoutPipe()
-	if err != nil {
-		logger.Errorf("Get SSH session stdout failed: %s", err)
-		return
-	}
-	errOut, err := goSess.StderrPipe()
-	if err != nil {
-		logger.Errorf("Get SSH session stderr failed: %s", err)
-		return
+	if allowlist := s.GetTerminalConfig().EnvAllowlist; len(allowlist) > 0 {
+		srvconn.SetSessionEnv(goSess, proxy.ForwardEnv(sess.Environ(), allowlist))
 	}
-	reader := io.MultiReader(out, errOut)
-	go func() {
-		now := time.Now()
-		var outResult strings.Builder
-		maxSize := 1024
-		cmd := model.Command{
-			SessionID:   respSession.ID,
-			OrgID:       respSession.OrgID,
-			Input:       rawStr,
-			User:        respSession.User,
-			Server:      respSession.Asset,
-			Account:     respSession.Account,
-			Timestamp:   now.Unix(),
-			DateCreated: now,
+	// 客户端申请了 pty(ssh -t) 时资产上也使用 pty 执行命令
+	if pty, winChan, isPty := sess.Pty(); isPty {
+		modes := gossh.TerminalModes{gossh.ECHO: 1}
+		if err = goSess.RequestPty(pty.Term, pty.Window.Height, pty.Window.Width, modes); err != nil {
+			logger.Errorf("Request SSH session pty failed: %s", err)
 		}
-		buf := make([]byte, 1024)
-		for {
-			n, err := reader.Read(buf)
-			if err != nil {
-				if err != io.EOF {
-					logger.Errorf("Read ssh session output failed: %s", err)
-				} else {
-					logger.Info("Read ssh command session output end")
-				}
-				break
-			}
-			_, _ = sess.Write(buf[:n])
-			maxSize -= n
-			if maxSize >= 0 {
-				_, _ = outResult.Write(buf[:n])
+		go func() {
+			for win := range winChan {
+				_ = goSess.WindowChange(win.Height, win.Width)
 			}
-		}
-		cmd.Output = strings.ReplaceAll(outResult.String(), "\x00", "")
-		termCfg := s.GetTerminalConfig()
-		cmdStorage := proxy.NewCommandStorage(s.jmsService, &termCfg)
-		if err2 := cmdStorage.BulkSave([]*model.Command{&cmd}); err2 != nil {
-			logger.Errorf("Create command err: %s", err2)
-		}
-	}()
+		}()
+	}
+	output := &commandOutput{maxSize: 1024}
+	goSess.Stdin = sess
+	goSess.Stdout = io.MultiWriter(sess, output)
+	goSess.Stderr = io.MultiWriter(sess.Stderr(), output)
+	// Run 返回时输出已经全部写入
 	err = goSess.Run(rawStr)
+	exitReason = model.ExitCommandFinished
 	if err != nil {
 		logger.Errorf("User %s Run command %s failed: %s",
 			tokeInfo.User.String(), rawStr, err)
 	}
+	cmd.Output = proxy.MaskCommandOutput(strings.ReplaceAll(output.String(), "\x00", ""))
+	saveCommand()
+	return execExitCode(err)
 }
 
 func (s *Server) proxyVscodeShell(sess ssh.Session, vsReq *vscodeReq, sshClient *srvconn.SSHClient,
@@ -457,8 +514,10 @@ func (s *Server) proxyVscodeShell(sess ssh.Session, vsReq *vscodeReq, sshClient
 		return fmt.Errorf("ssh proxy not support task: %s", task.Name)
 	})
 	session.AddSession(traceSession)
+	exitReason := model.ExitConnectFailed
 	defer func() {
-		if err2 := s.jmsService.SessionFinished(respSession.ID, modelCommon.NewNowUTCTime()); err2 != nil {
+		reason := sessionExitReason(ctx, sess.Context(), exitReason)
+		if err2 := s.jmsService.SessionFinished(respSession.ID, modelCommon.NewNowUTCTime(), reason); err2 != nil {
 			logger.Errorf("Create tunnel session err: %s", err)
 		}
 		session.RemoveSession(traceSession)
@@ -488,6 +547,7 @@ func (s *Server) proxyVscodeShell(sess ssh.Session, vsReq *vscodeReq, sshClient
 		return err
 	}
 	logger.Infof("User %s start vscode request to %s", vsReq.user, sshClient)
+	exitReason = model.ExitUserDisconnect
 
 	go func() {
 		_, _ = io.Copy(stdin, sess)
@@ -509,6 +569,7 @@ func (s *Server) proxyVscodeShell(sess ssh.Session, vsReq *vscodeReq, sshClient
 			if vsReq.expireInfo.IsExpired(now) {
 				logger.Infof("SSH conn[%s] User %s end vscode request %s as permission has expired",
 					vsReq.reqId, vsReq.user, sshClient)
+				exitReason = model.ExitPermissionExpired
 				return nil
 			}
 			logger.Debugf("SSH conn[%s] user %s vscode request still alive", vsReq.reqId, vsReq.user)
@@ -516,12 +577,23 @@ func (s *Server) proxyVscodeShell(sess ssh.Session, vsReq *vscodeReq, sshClient
 	}
 }
 
+// sessionExitReason ctx 被终断任务取消时为管理员终断，用户连接断开时为用户断开
+func sessionExitReason(ctx, userCtx context.Context, reason model.SessionExitReason) model.SessionExitReason {
+	switch {
+	case userCtx.Err() != nil:
+		return model.ExitUserDisconnect
+	case ctx.Err() != nil:
+		return model.ExitAdminTerminate
+	}
+	return reason
+}
+
 func buildSSHClientOptions(asset *model.Asset, account *model.Account,
 	gateways []model.Gateway) []srvconn.SSHClientOption {
-	timeout := config.GlobalConfig.SSHTimeout
+	timeout := config.GetConf().SSHTimeout
 	sshAuthOpts := make([]srvconn.SSHClientOption, 0, 7)
 	sshAuthOpts = append(sshAuthOpts, srvconn.SSHClientUsername(account.Username))
-	sshAuthOpts = append(sshAuthOpts, srvconn.SSHClientHost(asset.Address))
+	sshAuthOpts = append(sshAuthOpts, srvconn.SSHClientHost(common.TrimHostBrackets(asset.Address)))
 	sshAuthOpts = append(sshAuthOpts, srvconn.SSHClientPort(asset.ProtocolPort(model.ProtocolSSH)))
 	sshAuthOpts = append(sshAuthOpts, srvconn.SSHClientTimeout(timeout))
 	if account.IsSSHKey() {
@@ -530,10 +602,27 @@ func buildSSHClientOptions(asset *model.Asset, account *model.Account,
 		} else {
 			logger.Errorf("Parse account %s private key failed: %s", account.Username, err1)
 		}
+		if account.Certificate != "" {
+			sshAuthOpts = append(sshAuthOpts, srvconn.SSHClientCertificate(account.Certificate))
+		}
 	} else {
 		sshAuthOpts = append(sshAuthOpts, srvconn.SSHClientPassword(account.Secret))
 	}
 
+	for i := range gateways {
+		if gateways[i].IsSocks5() {
+			socks5Arg := srvconn.NewSocks5ProxyOptions(&gateways[i])
+			sshAuthOpts = append(sshAuthOpts, srvconn.SSHClientSocks5Proxy(socks5Arg))
+			return sshAuthOpts
+		}
+	}
+	for i := range gateways {
+		if gateways[i].IsHTTPProxy() {
+			httpArg := srvconn.NewHTTPProxyOptions(&gateways[i])
+			sshAuthOpts = append(sshAuthOpts, srvconn.SSHClientHTTPProxy(httpArg))
+			return sshAuthOpts
+		}
+	}
 	if len(gateways) > 0 {
 		proxyArgs := make([]srvconn.SSHClientOptions, 0, len(gateways))
 		for i := range gateways {
@@ -541,7 +630,7 @@ func buildSSHClientOptions(asset *model.Asset, account *model.Account,
 			loginAccount := gateway.Account
 			port := gateway.Protocols.GetProtocolPort(model.ProtocolSSH)
 			proxyArg := srvconn.SSHClientOptions{
-				Host:     gateway.Address,
+				Host:     common.TrimHostBrackets(gateway.Address),
 				Port:     strconv.Itoa(port),
 				Username: loginAccount.Username,
 				Timeout:  timeout,
@@ -559,6 +648,10 @@ func buildSSHClientOptions(asset *model.Asset, account *model.Account,
 }
 
 func (s *Server) getMatchedAssetsByDirectReq(user *model.User, req *auth.DirectLoginAssetReq) ([]model.Asset, error) {
+	if req.IsToken() {
+		// 令牌已经确定了连接的资产
+		return []model.Asset{req.ConnectToken.Asset}, nil
+	}
 	var getUserPermAssets func() ([]model.Asset, error)
 	if common.ValidUUIDString(req.AssetTarget) {
 		getUserPermAssets = func() ([]model.Asset, error) {
@@ -572,7 +665,11 @@ func (s *Server) getMatchedAssetsByDirectReq(user *model.User, req *auth.DirectL
 	assets, err := getUserPermAssets()
 	if err != nil {
 		logger.Errorf("Get user %s perm asset failed: %s", user.String(), err)
-		return nil, fmt.Errorf("match asset failed: %s", i18n.T("Core API failed"))
+		msg := i18n.T("Core API failed")
+		if httplib.IsUnreachable(err) {
+			msg = i18n.T("JumpServer core is unreachable, please try again later")
+		}
+		return nil, fmt.Errorf("match asset failed: %s", msg)
 	}
 	if len(assets) == 0 {
 		logger.Infof("User %s no perm for asset %s", user.String(), req.AssetTarget)
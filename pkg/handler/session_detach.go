@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/proxy"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+// sessionDetachEnabled 开启了持久会话，客户端断开后可以恢复会话
+func sessionDetachEnabled(h *InteractiveHandler) bool {
+	return config.GetConf().SessionDetachGrace > 0
+}
+
+// displayDetachedSessions 列出当前用户分离的会话，用于恢复
+func (h *InteractiveHandler) displayDetachedSessions() {
+	lang := i18n.NewLang(h.i18nLang)
+	sessions := proxy.ListDetachedSessions(h.user.ID)
+	if len(sessions) == 0 {
+		utils.IgnoreErrWriteString(h.term, utils.WrapperString(lang.T("No detached sessions"), utils.Red))
+		utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+		return
+	}
+	for i, sess := range sessions {
+		line := fmt.Sprintf(lang.T("%d. %s  %s  detached at %s, expire at %s"), i+1, sess.Asset, sess.Account,
+			h.formatTime(sess.DetachedAt), h.formatTime(sess.ExpireAt))
+		utils.IgnoreErrWriteString(h.term, line+utils.CharNewLine)
+	}
+	tips := lang.T("Tips: Enter attach+ID to resume the session, such as attach1")
+	utils.IgnoreErrWriteString(h.term, utils.WrapperString(tips, utils.Green))
+	utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+}
+
+// displayDetachedSessionTip 登录时提示用户有可以恢复的会话
+func (h *InteractiveHandler) displayDetachedSessionTip() {
+	if !sessionDetachEnabled(h) || menuItemDisabled("attach") {
+		return
+	}
+	if count := len(proxy.ListDetachedSessions(h.user.ID)); count > 0 {
+		lang := i18n.NewLang(h.i18nLang)
+		msg := fmt.Sprintf(lang.T("You have %d detached sessions, enter attach to resume"), count)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperString(msg, utils.Yellow)+utils.CharNewLine)
+	}
+}
+
+// parseAttachArgs 解析 attach 后面的会话序号
+func parseAttachArgs(args string) (int, bool) {
+	index, err := strconv.Atoi(strings.TrimSpace(args))
+	if err != nil || index <= 0 {
+		return 0, false
+	}
+	return index, true
+}
+
+// attachSession 恢复第 index 个分离的会话，会话再次分离或结束后返回菜单
+func (h *InteractiveHandler) attachSession(index int) bool {
+	sessions := proxy.ListDetachedSessions(h.user.ID)
+	if index > len(sessions) {
+		return false
+	}
+	lang := i18n.NewLang(h.i18nLang)
+	sess := sessions[index-1]
+	logger.Infof("User %s attach detached session %s", h.user.String(), sess.ID)
+	if err := proxy.AttachSession(h.user.ID, sess.ID, h.sess); err != nil {
+		logger.Errorf("User %s attach session %s failed: %s", h.user.String(), sess.ID, err)
+		msg := fmt.Sprintf(lang.T("Session %s is not available to resume"), sess.ID)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(msg))
+		return true
+	}
+	logger.Infof("User %s leave attached session %s", h.user.String(), sess.ID)
+	return true
+}
@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	"golang.org/x/term"
+
+	"github.com/jumpserver/koko/pkg/auth"
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/service"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+const (
+	SessionMFAPolicyOff   = "off"
+	SessionMFAPolicyAdmin = "admin"
+	SessionMFAPolicyAll   = "all"
+
+	sessionMFAPrompt = "OTP> "
+)
+
+/*
+ENABLE_SESSION_MFA 开启后 SESSION_MFA_POLICY 才生效，校验接口不是 core 内置的接口，默认关闭。
+SESSION_MFA_POLICY 会话内的二次认证：SSH 认证通过后、显示菜单前要求用户输入 OTP 动态码，
+由 core 使用用户绑定的 OTP 密钥校验(接口约定见 service.VerifyUserOTP)。没有绑定 OTP 的用户和豁免的用户跳过。
+每个会话最多输入 SESSION_MFA_MAX_ATTEMPTS 次，同一用户多次失败后在一段时间内锁定。
+直连格式申请了 pty 时同样要求输入；exec、sftp、端口转发和没有 pty 的直连无法输入动态码，直接拒绝。
+*/
+
+// sessionMFALimiter 按用户统计会话二次认证的失败次数，未初始化时不锁定
+var sessionMFALimiter *auth.IPAuthLimiter
+
+func InitSessionMFALimiter(threshold int, lockout time.Duration) {
+	sessionMFALimiter = auth.NewIPAuthLimiter(threshold, lockout, lockout, 0)
+}
+
+// sessionMFARequired 根据策略判断用户是否需要二次认证
+func sessionMFARequired(user *model.User) bool {
+	conf := config.GetConf()
+	if !conf.EnableSessionMFA {
+		return false
+	}
+	switch strings.ToLower(conf.SessionMFAPolicy) {
+	case SessionMFAPolicyAll:
+	case SessionMFAPolicyAdmin:
+		if !user.IsAdmin() {
+			return false
+		}
+	default:
+		return false
+	}
+	for _, name := range conf.SessionMFAExemptUsers {
+		if strings.EqualFold(strings.TrimSpace(name), user.Username) {
+			return false
+		}
+	}
+	// otp_level 为 0 表示用户没有开启 MFA
+	return user.OTPLevel > 0
+}
+
+const ctxKeySessionMFAVerified = "sessionMFAVerified"
+
+// sessionMFAPassed 用户不需要二次认证，或者在同一个 SSH 连接中已经通过了二次认证
+func sessionMFAPassed(ctx context.Context, user *model.User) bool {
+	if !sessionMFARequired(user) {
+		return true
+	}
+	verified, _ := ctx.Value(ctxKeySessionMFAVerified).(bool)
+	return verified
+}
+
+func markSessionMFAVerified(ctx context.Context) {
+	if sshCtx, ok := ctx.(ssh.Context); ok {
+		sshCtx.SetValue(ctxKeySessionMFAVerified, true)
+	}
+}
+
+/*
+sessionMFARejected 无法输入动态码的请求(sftp、端口转发、没有 pty 的直连)在需要二次认证时拒绝，
+同一个 SSH 连接(如 ControlMaster 复用的连接)中已经在交互会话里通过认证的除外。
+*/
+func sessionMFARejected(ctx context.Context, user *model.User, request string) bool {
+	if sessionMFAPassed(ctx, user) {
+		return false
+	}
+	logger.Warnf("User %s %s rejected: session MFA required", user.String(), request)
+	return true
+}
+
+// checkSessionMFA 返回 false 时需要断开连接
+func (h *InteractiveHandler) checkSessionMFA() bool {
+	if !sessionMFARequired(h.user) {
+		return true
+	}
+	if h.sess != nil && sessionMFAPassed(h.sess.Sess.Context(), h.user) {
+		return true
+	}
+	if !verifySessionMFA(h.jmsService, h.term, h.user, h.i18nLang) {
+		return false
+	}
+	if h.sess != nil {
+		markSessionMFAVerified(h.sess.Sess.Context())
+	}
+	return true
+}
+
+// checkSessionMFA 直连格式(user@asset@koko)申请了 pty 时同样在连接资产前要求输入动态码
+func (d *DirectHandler) checkSessionMFA() bool {
+	ctx := d.sess.Context()
+	if sessionMFAPassed(ctx, d.opts.User) {
+		return true
+	}
+	if !verifySessionMFA(d.jmsService, d.term, d.opts.User, d.i18nLang) {
+		return false
+	}
+	markSessionMFAVerified(ctx)
+	return true
+}
+
+// verifySessionMFA 在终端中要求用户输入动态码，返回 false 时需要断开连接
+func verifySessionMFA(jmsService *service.JMService, vt *term.Terminal, user *model.User, langCode string) bool {
+	lang := i18n.NewLang(langCode)
+	if locked, remain := sessionMFALimiter.IsLocked(user.ID); locked {
+		logger.Warnf("User %s session MFA locked, %s remaining", user.String(), remain.Round(time.Second))
+		msg := fmt.Sprintf(lang.T("Too many MFA failures, please try again after %s"), remain.Round(time.Second))
+		utils.IgnoreErrWriteString(vt, utils.WrapperWarn(msg))
+		return false
+	}
+	maxAttempts := config.GetConf().SessionMFAMaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+	tip := lang.T("Please enter the OTP code of your MFA application")
+	utils.IgnoreErrWriteString(vt, utils.WrapperString(tip, utils.Green))
+	utils.IgnoreErrWriteString(vt, utils.CharNewLine)
+	for i := 0; i < maxAttempts; i++ {
+		code, err := vt.ReadPassword(sessionMFAPrompt)
+		if err != nil {
+			logger.Infof("User %s session MFA read code err: %s", user.String(), err)
+			return false
+		}
+		code = strings.TrimSpace(code)
+		if code == "" {
+			utils.IgnoreErrWriteString(vt, utils.WrapperWarn(lang.T("Invalid OTP code")))
+			continue
+		}
+		res, err := jmsService.VerifyUserOTP(user.ID, code)
+		if errors.Is(err, service.ErrOTPVerifyUnsupported) {
+			logger.Errorf("User %s session MFA verify failed, core does not provide %s required by SESSION_MFA_POLICY: %s",
+				user.String(), service.UserOTPVerifyURL, err)
+			utils.IgnoreErrWriteString(vt, utils.WrapperWarn(lang.T("Core API failed")))
+			return false
+		}
+		if err != nil {
+			logger.Errorf("User %s session MFA verify err: %s", user.String(), err)
+			utils.IgnoreErrWriteString(vt, utils.WrapperWarn(lang.T("Core API failed")))
+			return false
+		}
+		if !res.Enrolled {
+			logger.Infof("User %s has no OTP enrolled, skip session MFA", user.String())
+			return true
+		}
+		if res.OK {
+			sessionMFALimiter.Reset(user.ID)
+			logger.Infof("User %s session MFA success", user.String())
+			return true
+		}
+		logger.Warnf("User %s session MFA failed: %s", user.String(), res.Msg)
+		if sessionMFALimiter.Failed(user.ID) {
+			logger.Warnf("User %s session MFA locked for too many failures", user.String())
+			utils.IgnoreErrWriteString(vt, utils.WrapperWarn(lang.T("Too many MFA failures, disconnect")))
+			return false
+		}
+		utils.IgnoreErrWriteString(vt, utils.WrapperWarn(lang.T("Invalid OTP code")))
+	}
+	utils.IgnoreErrWriteString(vt, utils.WrapperWarn(lang.T("Too many MFA failures, disconnect")))
+	return false
+}
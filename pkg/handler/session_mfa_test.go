@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	"golang.org/x/term"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestCheckSessionMFA(t *testing.T) {
+	var verified []string
+	jms := newTestJMService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var data map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&data)
+		verified = append(verified, data["code"])
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(model.OTPVerifyResult{OK: data["code"] == "123456", Enrolled: true})
+	}))
+	conf := config.GetConf()
+	conf.EnableSessionMFA = true
+	conf.SessionMFAPolicy = SessionMFAPolicyAdmin
+	conf.SessionMFAExemptUsers = []string{"bob"}
+	conf.SessionMFAMaxAttempts = 2
+	setTestConfig(t, &conf)
+	InitSessionMFALimiter(3, time.Minute)
+	defer func() { sessionMFALimiter = nil }()
+
+	var output strings.Builder
+	newHandler := func(user *model.User, input string) *InteractiveHandler {
+		rw := struct {
+			io.Reader
+			io.Writer
+		}{strings.NewReader(input), &output}
+		return &InteractiveHandler{user: user, term: term.NewTerminal(rw, "Opt> "),
+			jmsService: jms, i18nLang: "en"}
+	}
+	admin := &model.User{ID: "user-1", Username: "alice", Role: model.RoleAdmin, OTPLevel: 1}
+	conf.EnableSessionMFA = false
+	if sessionMFARequired(admin) {
+		t.Error("session MFA should be ignored when ENABLE_SESSION_MFA is off")
+	}
+	conf.EnableSessionMFA = true
+	tests := []struct {
+		name  string
+		user  *model.User
+		input string
+		want  bool
+		codes []string
+	}{
+		{name: "not admin", user: &model.User{ID: "user-2", Username: "carol", OTPLevel: 1}, want: true},
+		{name: "exempt", user: &model.User{ID: "user-3", Username: "bob", Role: model.RoleAdmin, OTPLevel: 1}, want: true},
+		{name: "not enrolled", user: &model.User{ID: "user-4", Username: "dave", Role: model.RoleAdmin}, want: true},
+		{name: "retry success", user: admin, input: "000000\r123456\r", want: true, codes: []string{"000000", "123456"}},
+		{name: "attempts exhausted", user: admin, input: "000000\r111111\r123456\r", codes: []string{"000000", "111111"}},
+		{name: "locked", user: admin, input: "222222\r123456\r", codes: []string{"222222"}},
+		{name: "locked before prompt", user: admin, input: "123456\r"},
+	}
+	for _, tt := range tests {
+		verified = nil
+		if got := newHandler(tt.user, tt.input).checkSessionMFA(); got != tt.want {
+			t.Errorf("%s: checkSessionMFA() = %v, want %v", tt.name, got, tt.want)
+		}
+		if strings.Join(verified, ",") != strings.Join(tt.codes, ",") {
+			t.Errorf("%s: verified codes %v, want %v", tt.name, verified, tt.codes)
+		}
+	}
+	if strings.Contains(output.String(), "123456") {
+		t.Errorf("OTP code echoed: %q", output.String())
+	}
+}
+
+type mfaTestContext struct {
+	ssh.Context
+	values map[interface{}]interface{}
+}
+
+func (c *mfaTestContext) Value(key interface{}) interface{} { return c.values[key] }
+
+func (c *mfaTestContext) SetValue(key, value interface{}) { c.values[key] = value }
+
+func TestSessionMFARejected(t *testing.T) {
+	conf := config.GetConf()
+	conf.EnableSessionMFA = true
+	conf.SessionMFAPolicy = SessionMFAPolicyAll
+	setTestConfig(t, &conf)
+
+	ctx := &mfaTestContext{values: map[interface{}]interface{}{}}
+	if sessionMFARejected(ctx, &model.User{Username: "carol"}, "sftp") {
+		t.Error("user without OTP should not be rejected")
+	}
+	user := &model.User{ID: "user-1", Username: "alice", OTPLevel: 1}
+	if !sessionMFARejected(ctx, user, "sftp") {
+		t.Error("sftp before session MFA should be rejected")
+	}
+	markSessionMFAVerified(ctx)
+	if sessionMFARejected(ctx, user, "sftp") {
+		t.Error("sftp on a connection that passed session MFA should be allowed")
+	}
+}
+
+func TestVerifySessionMFAUnsupported(t *testing.T) {
+	jms := newTestJMService(t, http.NotFoundHandler())
+	var output strings.Builder
+	rw := struct {
+		io.Reader
+		io.Writer
+	}{strings.NewReader("123456\r123456\r"), &output}
+	user := &model.User{ID: "user-1", Username: "alice", OTPLevel: 1}
+	if verifySessionMFA(jms, term.NewTerminal(rw, "Opt> "), user, "en") {
+		t.Error("verifySessionMFA() = true, want false when core has no OTP verify api")
+	}
+	if strings.Count(output.String(), "OTP> ") != 1 {
+		t.Errorf("missing api should not prompt again: %q", output.String())
+	}
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/exchange"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/session"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+/*
+SESSION_OBSERVE 开启后管理员可以在 a 列出的活跃会话中输入 o+ID 旁观会话，实时查看会话的输出。
+与分享会话不同，旁观不广播加入和离开的事件，会话的用户和其他加入的用户都看不到旁观者，旁观者的输入全部丢弃。
+开始和结束旁观都记录会话生命周期日志，按 Ctrl+] 或者会话结束时退出旁观。
+*/
+
+func sessionObserveEnabled(h *InteractiveHandler) bool {
+	return h.user.IsAdmin() && config.GetConf().SessionObserve
+}
+
+// observeActiveSession 旁观上次列出的第 index 个会话
+func (h *InteractiveHandler) observeActiveSession(index int) bool {
+	if index <= 0 || index > len(h.activeSessions) {
+		return false
+	}
+	lang := i18n.NewLang(h.i18nLang)
+	sid := h.activeSessions[index-1].ID
+	if _, ok := session.GetSessionById(sid); !ok {
+		msg := fmt.Sprintf(lang.T("Session %s has already ended"), sid)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(msg))
+		return true
+	}
+	// sftp、端口转发等会话没有输出可以旁观
+	room := exchange.GetRoom(sid)
+	if room == nil {
+		msg := fmt.Sprintf(lang.T("Session %s can not be observed"), sid)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(msg))
+		return true
+	}
+	tips := fmt.Sprintf(lang.T("Observing session %s read-only, press Ctrl+] to exit"), sid)
+	utils.IgnoreErrWriteString(h.term, utils.WrapperString(tips, utils.Green)+utils.CharNewLine)
+	logger.Infof("User %s start observing session %s", h.user.Name, sid)
+	h.recordObserveLog(sid, model.AdminObserveStart, "Observation started by admin from koko menu")
+
+	start := time.Now()
+	err := observeRoom(room, h.sess, h.sess)
+	duration := time.Since(start).Round(time.Second)
+
+	logger.Infof("User %s stop observing session %s after %s: %v", h.user.Name, sid, duration, err)
+	h.recordObserveLog(sid, model.AdminObserveEnd,
+		fmt.Sprintf("Observation ended by admin from koko menu after %s", duration))
+	utils.IgnoreErrWriteString(h.term, utils.CharNewLine+lang.T("Stopped observing the session")+utils.CharNewLine)
+	return true
+}
+
+func (h *InteractiveHandler) recordObserveLog(sid string, event model.LifecycleEvent, reason string) {
+	logObj := model.SessionLifecycleLog{Reason: reason, User: h.user.String()}
+	if err := h.jmsService.RecordSessionLifecycleLog(sid, event, logObj); err != nil {
+		logger.Errorf("Record session %s lifecycle log failed: %s", sid, err)
+	}
+}
+
+/*
+observeRoom 订阅会话的输出写入 stream，直到 input 中读到 Ctrl+] 或者读取失败。
+会话结束时 room 会关闭 stream，中断 input 的读取。
+*/
+
+func observeRoom(room *exchange.Room, stream exchange.Stream, input io.Reader) error {
+	conn := exchange.WrapperUserCon(stream)
+	room.Subscribe(conn)
+	defer room.UnSubscribe(conn)
+	buf := make([]byte, 1024)
+	for {
+		nr, err := input.Read(buf)
+		if nr > 0 && bytes.IndexByte(buf[:nr], shareExitKey) >= 0 {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/exchange"
+)
+
+type fakeRoomStream struct {
+	mu     sync.Mutex
+	data   []byte
+	events []string
+	output chan struct{}
+	joined chan struct{}
+}
+
+func newFakeRoomStream() *fakeRoomStream {
+	return &fakeRoomStream{output: make(chan struct{}, 10), joined: make(chan struct{}, 1)}
+}
+
+func (f *fakeRoomStream) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	f.data = append(f.data, p...)
+	f.mu.Unlock()
+	f.output <- struct{}{}
+	return len(p), nil
+}
+
+func (f *fakeRoomStream) Close() error { return nil }
+
+func (f *fakeRoomStream) HandleRoomEvent(event string, msg *exchange.RoomMessage) {
+	f.mu.Lock()
+	f.events = append(f.events, event)
+	f.mu.Unlock()
+	if event == exchange.ShareUsers {
+		select {
+		case f.joined <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func TestObserveRoom(t *testing.T) {
+	exchange.Initial()
+	userInput := make(chan *exchange.RoomMessage, 1)
+	room := exchange.CreateRoom("observe-test", userInput)
+	exchange.Register(room)
+	defer exchange.UnRegister(room)
+	owner := newFakeRoomStream()
+	ownerConn := exchange.WrapperUserCon(owner)
+	room.Subscribe(ownerConn)
+	defer room.UnSubscribe(ownerConn)
+	<-owner.joined
+
+	observer := newFakeRoomStream()
+	input, inputWriter := io.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- observeRoom(room, observer, input) }()
+	select {
+	case <-observer.joined:
+	case <-time.After(time.Second):
+		t.Fatal("observer not subscribed")
+	}
+
+	// 旁观者的输入不会发送给会话
+	go func() { _, _ = inputWriter.Write([]byte("whoami\r")) }()
+	room.Broadcast(&exchange.RoomMessage{Event: exchange.DataEvent, Body: []byte("hello")})
+	select {
+	case <-observer.output:
+	case <-time.After(time.Second):
+		t.Fatal("observer got no output")
+	}
+	observer.mu.Lock()
+	if string(observer.data) != "hello" {
+		t.Errorf("observer output = %q, want hello", observer.data)
+	}
+	observer.mu.Unlock()
+
+	if _, err := inputWriter.Write([]byte{shareExitKey}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("observeRoom() = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("observer not exit by Ctrl+]")
+	}
+
+	if len(userInput) != 0 {
+		t.Error("observer input sent to the session")
+	}
+	owner.mu.Lock()
+	defer owner.mu.Unlock()
+	for _, event := range owner.events {
+		if event == exchange.ShareJoin || event == exchange.ShareLeave {
+			t.Errorf("session owner got %s event of the observer", event)
+		}
+	}
+}
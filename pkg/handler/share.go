@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/exchange"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/common"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/session"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+const (
+	// 分享码的有效时间(分钟)
+	shareExpireMinutes = 10
+
+	sharePermReadonly = "readonly"
+	sharePermWritable = "writable"
+
+	// Ctrl+] 退出加入的会话
+	shareExitKey = 0x1d
+)
+
+func sessionShareEnabled(h *InteractiveHandler) bool {
+	return h.terminalConf.EnableSessionShare
+}
+
+// displayOwnSessions 列出当前用户在本节点上正在进行的会话，用于选择分享
+func (h *InteractiveHandler) displayOwnSessions() {
+	lang := i18n.NewLang(h.i18nLang)
+	h.ownSessions = h.ownSessions[:0]
+	for _, sess := range session.GetAliveSessionList() {
+		if sess.UserID == h.user.ID {
+			h.ownSessions = append(h.ownSessions, sess)
+		}
+	}
+	if len(h.ownSessions) == 0 {
+		utils.IgnoreErrWriteString(h.term, utils.WrapperString(lang.T("No active sessions"), utils.Red))
+		utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+		return
+	}
+	for i, sess := range h.ownSessions {
+		line := fmt.Sprintf("%d. %s  %s  %s", i+1, sess.Asset, sess.Account, h.formatTime(sess.DateStart.Time))
+		utils.IgnoreErrWriteString(h.term, line+utils.CharNewLine)
+	}
+	tips := lang.T("Tips: Enter share+ID to share the session read-only, such as share1; enter share+ID+w to allow input, such as share1w")
+	utils.IgnoreErrWriteString(h.term, utils.WrapperString(tips, utils.Green))
+	utils.IgnoreErrWriteString(h.term, utils.CharNewLine)
+}
+
+// parseShareArgs 解析 share 后面的参数，如 1 或者 1w
+func parseShareArgs(args string) (index int, writable bool, ok bool) {
+	args = strings.ToLower(strings.TrimSpace(args))
+	if strings.HasSuffix(args, "w") {
+		writable = true
+		args = strings.TrimSpace(strings.TrimSuffix(args, "w"))
+	}
+	index, err := strconv.Atoi(args)
+	if err != nil {
+		return 0, false, false
+	}
+	return index, writable, true
+}
+
+// createSessionShare 为上次列出的第 index 个会话创建分享码
+func (h *InteractiveHandler) createSessionShare(index int, writable bool) bool {
+	if index <= 0 || index > len(h.ownSessions) {
+		return false
+	}
+	lang := i18n.NewLang(h.i18nLang)
+	sess := h.ownSessions[index-1]
+	if _, ok := session.GetSessionById(sess.ID); !ok {
+		msg := fmt.Sprintf(lang.T("Session %s has already ended"), sess.ID)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(msg))
+		return true
+	}
+	perm := sharePermReadonly
+	if writable {
+		perm = sharePermWritable
+	}
+	req := model.SharingSessionRequest{
+		SessionID:  sess.ID,
+		ExpireTime: shareExpireMinutes,
+		Users:      []string{},
+		ActionPerm: perm,
+	}
+	res, err := h.jmsService.CreateShareRoom(req)
+	if err != nil {
+		logger.Errorf("User %s create share of session %s failed: %s", h.user.Name, sess.ID, err)
+		msg := fmt.Sprintf(lang.T("Share session %s failed"), sess.ID)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(msg))
+		return true
+	}
+	logger.Infof("User %s share session %s with %s permission", h.user.Name, sess.ID, perm)
+	msg := fmt.Sprintf(lang.T("Share code: %s %s, valid for %d minutes"), res.ID, res.Code, shareExpireMinutes)
+	utils.IgnoreErrWriteString(h.term, utils.WrapperString(msg, utils.Green)+utils.CharNewLine)
+	tips := fmt.Sprintf(lang.T("Other users can enter join %s %s in the koko menu to join the session"), res.ID, res.Code)
+	utils.IgnoreErrWriteString(h.term, tips+utils.CharNewLine)
+	return true
+}
+
+/*
+joinSessionShare 使用分享码加入其他用户的会话，输出直接镜像到当前终端；
+只读权限下用户的输入被丢弃，按 Ctrl+] 退出并返回菜单。
+*/
+
+func (h *InteractiveHandler) joinSessionShare(shareID, code string) {
+	lang := i18n.NewLang(h.i18nLang)
+	data := model.SharePostData{
+		ShareId:    shareID,
+		Code:       code,
+		UserId:     h.user.ID,
+		RemoteAddr: h.sess.RemoteAddr(),
+	}
+	record, err := h.jmsService.JoinShareRoom(data)
+	if err != nil || record.Err != nil {
+		logger.Errorf("User %s join share %s failed: %v %v", h.user.Name, shareID, err, record.Err)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(lang.T("Invalid share code or permission denied")))
+		return
+	}
+	defer func() {
+		if err := h.jmsService.FinishShareRoom(record.ID); err != nil {
+			logger.Errorf("User %s finish share record %s failed: %s", h.user.Name, record.ID, err)
+		}
+	}()
+	room := exchange.GetRoom(record.Session.ID)
+	if room == nil {
+		msg := fmt.Sprintf(lang.T("Session %s has already ended"), record.Session.ID)
+		utils.IgnoreErrWriteString(h.term, utils.WrapperWarn(msg))
+		return
+	}
+	writable := record.Writeable()
+	meta := exchange.MetaMessage{
+		UserId:     h.user.ID,
+		User:       h.user.String(),
+		Created:    common.NewNowUTCTime().String(),
+		RemoteAddr: h.sess.RemoteAddr(),
+		TerminalId: h.sess.Uuid,
+		Primary:    false,
+		Writable:   writable,
+	}
+	tips := lang.T("Joined the session read-only, press Ctrl+] to exit")
+	if writable {
+		tips = lang.T("Joined the session, press Ctrl+] to exit")
+	}
+	utils.IgnoreErrWriteString(h.term, utils.WrapperString(tips, utils.Green)+utils.CharNewLine)
+	logger.Infof("User %s join session %s by share %s, writable: %v",
+		h.user.Name, record.Session.ID, shareID, writable)
+
+	conn := exchange.WrapperUserCon(h.sess)
+	room.Subscribe(conn)
+	defer room.UnSubscribe(conn)
+	room.Broadcast(&exchange.RoomMessage{Event: exchange.ShareJoin, Meta: meta})
+	defer room.Broadcast(&exchange.RoomMessage{Event: exchange.ShareLeave, Meta: meta})
+	buf := make([]byte, 1024)
+	for {
+		nr, err := h.sess.Read(buf)
+		if nr > 0 {
+			p := buf[:nr]
+			exitIndex := bytes.IndexByte(p, shareExitKey)
+			if exitIndex >= 0 {
+				p = p[:exitIndex]
+			}
+			if writable && len(p) > 0 {
+				room.Receive(&exchange.RoomMessage{
+					Event: exchange.DataEvent,
+					Body:  append([]byte(nil), p...),
+					Meta:  meta,
+				})
+			}
+			if exitIndex >= 0 {
+				break
+			}
+		}
+		if err != nil {
+			logger.Infof("User %s leave shared session %s: %s", h.user.Name, record.Session.ID, err)
+			break
+		}
+	}
+	utils.IgnoreErrWriteString(h.term, utils.CharNewLine+lang.T("Left the shared session")+utils.CharNewLine)
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/session"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+// whoamiLines 当前连接的信息，用户提交工单时可以根据会话 ID 找到录像
+func (h *InteractiveHandler) whoamiLines(sessions []*session.Session) []string {
+	lang := i18n.NewLang(h.i18nLang)
+	theme := h.activeTheme()
+	field := func(label, value string) string {
+		if value == "" {
+			value = "-"
+		}
+		return utils.CharTab + theme.WrapInstruction(lang.T(label)) + ": " + value
+	}
+	var connectionID string
+	if h.sess != nil {
+		connectionID = h.sess.ID()
+	}
+	var loginTime string
+	if !h.loginTime.IsZero() {
+		loginTime = h.formatTime(h.loginTime)
+	}
+	lines := []string{
+		utils.CharTab + theme.WrapTitle(lang.T("Connection information")),
+		field("User", h.user.String()),
+		field("Koko node", config.GetConf().Name),
+		field("Connection ID", connectionID),
+		field("Login time", loginTime),
+		field("Source IP", h.remoteIP()),
+	}
+	if len(sessions) == 0 {
+		return append(lines, field("Asset sessions", lang.T("none")))
+	}
+	lines = append(lines, utils.CharTab+theme.WrapInstruction(lang.T("Asset sessions"))+":")
+	for _, sess := range sessions {
+		line := fmt.Sprintf("%s  %s  %s  %s", sess.ID, sess.Asset, sess.Account, h.formatTime(sess.DateStart.Time))
+		lines = append(lines, utils.CharTab+utils.CharTab+line)
+	}
+	return lines
+}
+
+// displayWhoami 显示当前用户、节点、连接 ID 以及用户在当前节点上的资产会话
+func (h *InteractiveHandler) displayWhoami() {
+	var sessions []*session.Session
+	for _, sess := range session.GetAliveSessionList() {
+		if sess.UserID == h.user.ID {
+			sessions = append(sessions, sess)
+		}
+	}
+	lines := h.whoamiLines(sessions)
+	utils.IgnoreErrWriteString(h.term, strings.Join(lines, utils.CharNewLine)+utils.CharNewLine)
+}
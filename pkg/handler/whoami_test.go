@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/common"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/session"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+func TestWhoamiLines(t *testing.T) {
+	conf := config.GetConf()
+	conf.Name = "koko-01"
+	setTestConfig(t, &conf)
+
+	theme, _ := utils.GetTheme(utils.ThemeNoColor)
+	h := &InteractiveHandler{
+		user:      &model.User{ID: "u1", Name: "Alice", Username: "alice"},
+		i18nLang:  "en",
+		theme:     &theme,
+		timezone:  time.UTC,
+		loginTime: time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC),
+	}
+	got := strings.Join(h.whoamiLines(nil), "\n")
+	for _, want := range []string{"User: Alice(alice)", "Koko node: koko-01", "Connection ID: -",
+		"Login time: Oct 14, 2026 09:30:00 UTC", "Asset sessions: none"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("whoamiLines() = %q, want %q", got, want)
+		}
+	}
+
+	sessions := []*session.Session{{Session: &model.Session{ID: "sess-1", Asset: "web01",
+		Account: "root", DateStart: common.NewNowUTCTime()}}}
+	lines := h.whoamiLines(sessions)
+	last := lines[len(lines)-1]
+	if !strings.HasSuffix(lines[len(lines)-2], "Asset sessions:") || !strings.Contains(last, "sess-1  web01  root") {
+		t.Errorf("whoamiLines() with sessions = %q", lines)
+	}
+}
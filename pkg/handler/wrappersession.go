@@ -7,12 +7,17 @@ import (
 	"sync"
 
 	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
 
 	"github.com/jumpserver/koko/pkg/common"
 	"github.com/jumpserver/koko/pkg/exchange"
 	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/srvconn"
+	"github.com/jumpserver/koko/pkg/utils"
 )
 
+const agentChannelType = "auth-agent@openssh.com"
+
 type WrapperSession struct {
 	Uuid      string
 	Sess      ssh.Session
@@ -24,6 +29,19 @@ type WrapperSession struct {
 
 	winch      chan ssh.Window
 	currentWin ssh.Window
+	winMux     sync.RWMutex
+
+	// 从输入中拦截的快捷键，只在 readLoop 中使用
+	keyFilter *utils.KeySequenceFilter
+	keyHook   func()
+}
+
+// SetKeyHook 用户输入 seq 时调用 hook，seq 不会被读取
+func (w *WrapperSession) SetKeyHook(seq []byte, hook func()) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	w.keyFilter = utils.NewKeySequenceFilter(seq)
+	w.keyHook = hook
 }
 
 func (w *WrapperSession) initial() {
@@ -40,7 +58,17 @@ func (w *WrapperSession) readLoop() {
 
 		if nr > 0 {
 			w.mux.RLock()
-			_, _ = w.inWriter.Write(buf[:nr])
+			data := buf[:nr]
+			if w.keyFilter != nil {
+				var hits int
+				data, hits = w.keyFilter.Filter(data)
+				for ; hits > 0; hits-- {
+					w.keyHook()
+				}
+			}
+			if len(data) > 0 {
+				_, _ = w.inWriter.Write(data)
+			}
 			w.mux.RUnlock()
 		}
 		if err != nil {
@@ -96,7 +124,15 @@ func (w *WrapperSession) WinCh() (winch <-chan ssh.Window) {
 	return w.winch
 }
 
+// SetWin 记录最新的窗口大小，并通知正在连接的资产。
+// winch 只缓存最新的一次窗口变化，资产连接暂时没有读取时也不会丢失
 func (w *WrapperSession) SetWin(win ssh.Window) {
+	w.winMux.Lock()
+	defer w.winMux.Unlock()
+	select {
+	case <-w.winch:
+	default:
+	}
 	select {
 	case w.winch <- win:
 	default:
@@ -115,8 +151,10 @@ func (w *WrapperSession) RemoteAddr() string {
 
 func (w *WrapperSession) Pty() ssh.Pty {
 	pty, _, _ := w.Sess.Pty()
+	w.winMux.RLock()
 	termWin := w.currentWin
-	if w.currentWin.Width == 0 || w.currentWin.Height == 0 {
+	w.winMux.RUnlock()
+	if termWin.Width == 0 || termWin.Height == 0 {
 		termWin = pty.Window
 	}
 	return ssh.Pty{
@@ -125,6 +163,33 @@ func (w *WrapperSession) Pty() ssh.Pty {
 	}
 }
 
+// Environ 客户端通过 env 请求设置的环境变量
+func (w *WrapperSession) Environ() []string {
+	return w.Sess.Environ()
+}
+
+// AgentForwarded 客户端请求了 agent 转发，如 ssh -A
+func (w *WrapperSession) AgentForwarded() bool {
+	return ssh.AgentRequested(w.Sess)
+}
+
+// OpenAgentChannel 打开一个到客户端 agent 的通道，调用方使用完后关闭
+func (w *WrapperSession) OpenAgentChannel() (io.ReadWriteCloser, error) {
+	if !w.AgentForwarded() {
+		return nil, srvconn.ErrAgentNotForwarded
+	}
+	sshConn, ok := w.Sess.Context().Value(ssh.ContextKeyConn).(gossh.Conn)
+	if !ok {
+		return nil, srvconn.ErrAgentNotForwarded
+	}
+	channel, reqs, err := sshConn.OpenChannel(agentChannelType, nil)
+	if err != nil {
+		return nil, err
+	}
+	go gossh.DiscardRequests(reqs)
+	return channel, nil
+}
+
 func (w *WrapperSession) ID() string {
 	return w.Uuid
 }
@@ -133,7 +198,7 @@ func NewWrapperSession(sess ssh.Session) *WrapperSession {
 	w := &WrapperSession{
 		Sess:  sess,
 		mux:   new(sync.RWMutex),
-		winch: make(chan ssh.Window),
+		winch: make(chan ssh.Window, 1),
 	}
 	w.initial()
 	return w
@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+)
+
+func TestWrapperSessionSetWin(t *testing.T) {
+	sess := &WrapperSession{winch: make(chan ssh.Window, 1)}
+	// 资产连接还没有读取窗口变化时，连续调整窗口大小
+	sess.SetWin(ssh.Window{Width: 100, Height: 30})
+	sess.SetWin(ssh.Window{Width: 120, Height: 40})
+
+	// 只保留最新的窗口大小，资产连接的处理见 proxy.TestBridgeWindowChange
+	select {
+	case win := <-sess.WinCh():
+		if win.Width != 120 || win.Height != 40 {
+			t.Errorf("window change event = %d*%d, want 120*40", win.Width, win.Height)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("window change event lost")
+	}
+	select {
+	case win := <-sess.WinCh():
+		t.Errorf("stale window change event %d*%d", win.Width, win.Height)
+	default:
+	}
+	if sess.currentWin.Width != 120 || sess.currentWin.Height != 40 {
+		t.Errorf("current window = %d*%d, want 120*40", sess.currentWin.Width, sess.currentWin.Height)
+	}
+}
@@ -0,0 +1,128 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+/*
+健康检查单独监听地址，供负载均衡探测:
+  - /healthz 所有检查(如 core 可以访问、SSH 和 HTTP 服务正在监听)都通过时返回 200，否则返回 503 和失败的原因
+  - /readyz 在 /healthz 的基础上，进入优雅停止后返回 503，负载均衡不再转发新的连接
+*/
+
+// Check 返回 nil 表示检查通过
+type Check func() error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+type Server struct {
+	Srv *http.Server
+
+	certFile string
+	keyFile  string
+
+	checks   []namedCheck
+	draining atomic.Bool
+}
+
+// NewServer certFile 和 keyFile 都不为空时使用 TLS
+func NewServer(addr, certFile, keyFile string) *Server {
+	s := &Server{certFile: certFile, keyFile: keyFile}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.healthz)
+	mux.HandleFunc("/readyz", s.readyz)
+	s.Srv = &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	return s
+}
+
+// AddCheck 需要在 Start 之前添加
+func (s *Server) AddCheck(name string, check Check) {
+	s.checks = append(s.checks, namedCheck{name: name, check: check})
+}
+
+// SetDraining 开始优雅停止，之后 /readyz 返回 503
+func (s *Server) SetDraining() {
+	s.draining.Store(true)
+}
+
+func (s *Server) failedChecks() []string {
+	var failed []string
+	for i := range s.checks {
+		if err := s.checks[i].check(); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", s.checks[i].name, err))
+		}
+	}
+	return failed
+}
+
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	writeStatus(w, s.failedChecks())
+}
+
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	if s.draining.Load() {
+		writeStatus(w, []string{"shutting down"})
+		return
+	}
+	writeStatus(w, s.failedChecks())
+}
+
+func writeStatus(w http.ResponseWriter, failed []string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if len(failed) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(strings.Join(failed, "\n") + "\n"))
+		return
+	}
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+func (s *Server) Start() {
+	logger.Infof("Start health check server at %s", s.Srv.Addr)
+	var err error
+	if s.certFile != "" && s.keyFile != "" {
+		err = s.Srv.ListenAndServeTLS(s.certFile, s.keyFile)
+	} else {
+		err = s.Srv.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		logger.Errorf("Health check server exit: %s", err)
+	}
+}
+
+func (s *Server) Stop() {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFunc()
+	_ = s.Srv.Shutdown(ctx)
+}
+
+// CachedCheck 在 ttl 内复用上次的检查结果，避免每次探测都请求 core
+func CachedCheck(check Check, ttl time.Duration) Check {
+	var (
+		mu        sync.Mutex
+		lastErr   error
+		checkedAt time.Time
+	)
+	return func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		if !checkedAt.IsZero() && time.Since(checkedAt) < ttl {
+			return lastErr
+		}
+		lastErr = check()
+		checkedAt = time.Now()
+		return lastErr
+	}
+}
@@ -0,0 +1,49 @@
+package health
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthServer(t *testing.T) {
+	var coreErr error
+	s := NewServer("127.0.0.1:0", "", "")
+	s.AddCheck("core", func() error { return coreErr })
+	s.AddCheck("ssh", func() error { return nil })
+	get := func(path string) (int, string) {
+		w := httptest.NewRecorder()
+		s.Srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code, w.Body.String()
+	}
+	for _, path := range []string{"/healthz", "/readyz"} {
+		if code, body := get(path); code != http.StatusOK || body != "ok\n" {
+			t.Errorf("%s = %d %q, want 200 ok", path, code, body)
+		}
+	}
+	coreErr = errors.New("connection refused")
+	if code, body := get("/healthz"); code != http.StatusServiceUnavailable || !strings.Contains(body, "core: connection refused") {
+		t.Errorf("/healthz with core down = %d %q, want 503 with reason", code, body)
+	}
+	coreErr = nil
+	s.SetDraining()
+	if code, body := get("/readyz"); code != http.StatusServiceUnavailable || body != "shutting down\n" {
+		t.Errorf("/readyz when draining = %d %q, want 503 shutting down", code, body)
+	}
+	if code, _ := get("/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz when draining = %d, want 200", code)
+	}
+}
+
+func TestCachedCheck(t *testing.T) {
+	calls := 0
+	check := CachedCheck(func() error { calls++; return nil }, time.Minute)
+	_ = check()
+	_ = check()
+	if calls != 1 {
+		t.Errorf("check called %d times in ttl, want 1", calls)
+	}
+}
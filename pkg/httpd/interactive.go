@@ -0,0 +1,301 @@
+package httpd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gliderlabs/ssh"
+	gorilla "github.com/gorilla/websocket"
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/jumpserver/koko/pkg/auth"
+	"github.com/jumpserver/koko/pkg/handler"
+	"github.com/jumpserver/koko/pkg/httpd/ws"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+/*
+websocket 交互终端，与 ssh 登录使用相同的菜单和连接处理。
+客户端发送二进制消息作为终端输入，或者发送 JSON 文本消息:
+	{"type": "input", "data": "ls\r"}
+	{"type": "resize", "cols": 120, "rows": 40}
+	{"type": "ping"}  服务端回复 {"type": "pong"}
+服务端以二进制消息发送终端输出，会话结束时关闭连接。
+客户端超过 maxReadTimeout 没有任何消息时断开连接，保持连接需要定时发送 ping。
+*/
+
+const (
+	frameInput  = "input"
+	frameResize = "resize"
+	framePing   = "ping"
+	framePong   = "pong"
+)
+
+type terminalFrame struct {
+	Type string `json:"type"`
+	Data string `json:"data,omitempty"`
+	Cols int    `json:"cols,omitempty"`
+	Rows int    `json:"rows,omitempty"`
+}
+
+// keepalive 等待客户端回复 pong 的时间
+const wsKeepAliveTimeout = 15 * time.Second
+
+// interactiveUpGrader 令牌认证的连接没有 Cookie 的同源保护，需要校验 Origin
+var interactiveUpGrader = gorilla.Upgrader{
+	ReadBufferSize:  defaultBufferSize,
+	WriteBufferSize: defaultBufferSize,
+	Subprotocols:    []string{"JMS-KOKO"},
+	CheckOrigin:     auth.CheckTerminalOrigin,
+}
+
+func (s *Server) ProcessInteractiveWebsocket(ctx *gin.Context) {
+	user := ctx.MustGet(auth.ContextKeyUser).(*model.User)
+	termConf, err := s.apiClient.GetTerminalConfig()
+	if err != nil {
+		logger.Errorf("Get terminal config failed: %s", err)
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+	underWsCon, err := interactiveUpGrader.Upgrade(ctx.Writer, ctx.Request, ctx.Writer.Header())
+	if err != nil {
+		logger.Errorf("Websocket upgrade err: %s", err)
+		return
+	}
+	sess := newWsSession(ws.NewSocket(underWsCon, ctx.Request), ctx, user)
+	underWsCon.SetPingHandler(func(appData string) error {
+		return sess.sock.WritePong([]byte(appData), maxWriteTimeOut)
+	})
+	underWsCon.SetPongHandler(func(string) error {
+		select {
+		case sess.pong <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	defer sess.Close()
+	go sess.readLoop()
+	logger.Infof("User %s start websocket interactive terminal from %s", user.String(), ctx.ClientIP())
+	interactiveSrv := handler.NewInteractiveHandler(sess, user, s.apiClient, termConf)
+	go interactiveSrv.WatchWinSizeChange(sess.winCh)
+	interactiveSrv.Dispatch()
+}
+
+// wsSession 将 websocket 连接适配为 ssh.Session，供交互终端使用
+type wsSession struct {
+	sock   *ws.Socket
+	user   *model.User
+	ip     string
+	query  map[string]string
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	reader *io.PipeReader
+	writer *io.PipeWriter
+
+	winMux sync.Mutex
+	win    ssh.Window
+	winCh  chan ssh.Window
+
+	pong      chan struct{}
+	closeOnce sync.Once
+}
+
+func newWsSession(sock *ws.Socket, ginCtx *gin.Context, user *model.User) *wsSession {
+	ctx, cancel := context.WithCancel(context.Background())
+	reader, writer := io.Pipe()
+	cols, _ := strconv.Atoi(ginCtx.Query("cols"))
+	rows, _ := strconv.Atoi(ginCtx.Query("rows"))
+	if cols <= 0 || rows <= 0 {
+		cols, rows = 80, 24
+	}
+	return &wsSession{
+		sock:   sock,
+		user:   user,
+		ip:     ginCtx.ClientIP(),
+		query:  map[string]string{"target": ginCtx.Query("target"), "tz": ginCtx.Query("tz")},
+		ctx:    ctx,
+		cancel: cancel,
+		reader: reader,
+		writer: writer,
+		win:    ssh.Window{Width: cols, Height: rows},
+		winCh:  make(chan ssh.Window, 1),
+		pong:   make(chan struct{}, 1),
+	}
+}
+
+func (w *wsSession) readLoop() {
+	defer w.Close()
+	for {
+		p, opCode, err := w.sock.ReadData(maxReadTimeout)
+		if err != nil {
+			logger.Debugf("User %s websocket terminal read err: %s", w.user.String(), err)
+			return
+		}
+		switch opCode {
+		case gorilla.BinaryMessage:
+			if _, err = w.writer.Write(p); err != nil {
+				return
+			}
+			continue
+		case gorilla.TextMessage:
+		default:
+			continue
+		}
+		var frame terminalFrame
+		if err = json.Unmarshal(p, &frame); err != nil {
+			logger.Errorf("User %s websocket terminal invalid frame: %s", w.user.String(), p)
+			continue
+		}
+		switch frame.Type {
+		case frameInput:
+			if _, err = w.writer.Write([]byte(frame.Data)); err != nil {
+				return
+			}
+		case frameResize:
+			if frame.Cols > 0 && frame.Rows > 0 {
+				w.setWin(ssh.Window{Width: frame.Cols, Height: frame.Rows})
+			}
+		case framePing:
+			data, _ := json.Marshal(terminalFrame{Type: framePong})
+			if err = w.sock.WriteText(data, maxWriteTimeOut); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (w *wsSession) setWin(win ssh.Window) {
+	w.winMux.Lock()
+	defer w.winMux.Unlock()
+	w.win = win
+	select {
+	case <-w.winCh:
+	default:
+	}
+	w.winCh <- win
+}
+
+func (w *wsSession) Read(p []byte) (int, error) {
+	return w.reader.Read(p)
+}
+
+func (w *wsSession) Write(p []byte) (int, error) {
+	if err := w.sock.WriteBinary(p, maxWriteTimeOut); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *wsSession) Close() error {
+	w.closeOnce.Do(func() {
+		w.cancel()
+		_ = w.writer.Close()
+		_ = w.sock.WriteClose(time.Second)
+		_ = w.sock.Close()
+	})
+	return nil
+}
+
+func (w *wsSession) CloseWrite() error {
+	return nil
+}
+
+// SendRequest 交互终端的 keepalive 使用 websocket 的 ping 检测连接
+func (w *wsSession) SendRequest(name string, wantReply bool, payload []byte) (bool, error) {
+	if err := w.sock.WritePing(nil, wsKeepAliveTimeout); err != nil {
+		return false, err
+	}
+	select {
+	case <-w.pong:
+		return true, nil
+	case <-time.After(wsKeepAliveTimeout):
+		return false, context.DeadlineExceeded
+	case <-w.ctx.Done():
+		return false, w.ctx.Err()
+	}
+}
+
+func (w *wsSession) Stderr() io.ReadWriter {
+	return w
+}
+
+func (w *wsSession) User() string {
+	return w.user.Username
+}
+
+func (w *wsSession) RemoteAddr() net.Addr {
+	return wsAddr(net.JoinHostPort(w.ip, "0"))
+}
+
+func (w *wsSession) LocalAddr() net.Addr {
+	return wsAddr("")
+}
+
+// Environ 连接请求中的 tz 参数作为终端的时区
+func (w *wsSession) Environ() []string {
+	if tz := w.query["tz"]; tz != "" {
+		return []string{"TZ=" + tz}
+	}
+	return nil
+}
+
+func (w *wsSession) Exit(int) error {
+	return w.Close()
+}
+
+func (w *wsSession) Command() []string {
+	if target := w.RawCommand(); target != "" {
+		return []string{target}
+	}
+	return nil
+}
+
+// RawCommand 连接请求中的 target 参数，与 ssh user@koko -t target 一样直接连接匹配的资产
+func (w *wsSession) RawCommand() string {
+	return w.query["target"]
+}
+
+func (w *wsSession) Subsystem() string {
+	return ""
+}
+
+func (w *wsSession) PublicKey() ssh.PublicKey {
+	return nil
+}
+
+func (w *wsSession) Context() context.Context {
+	return w.ctx
+}
+
+func (w *wsSession) Permissions() ssh.Permissions {
+	return ssh.Permissions{Permissions: &gossh.Permissions{}}
+}
+
+func (w *wsSession) Pty() (ssh.Pty, <-chan ssh.Window, bool) {
+	w.winMux.Lock()
+	defer w.winMux.Unlock()
+	return ssh.Pty{Term: "xterm", Window: w.win}, w.winCh, true
+}
+
+func (w *wsSession) Signals(chan<- ssh.Signal) {}
+
+func (w *wsSession) Break(chan<- bool) {}
+
+type wsAddr string
+
+func (a wsAddr) Network() string {
+	return "websocket"
+}
+
+func (a wsAddr) String() string {
+	return string(a)
+}
@@ -50,7 +50,7 @@ func getUIAssetFs() http.FileSystem {
 }
 
 func createRouter(jmsService *service.JMService, webSrv *Server) *gin.Engine {
-	if config.GlobalConfig.LogLevel != "DEBUG" {
+	if config.GetConf().LogLevel != "DEBUG" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	eng := gin.New()
@@ -72,6 +72,10 @@ func createRouter(jmsService *service.JMService, webSrv *Server) *gin.Engine {
 		wsGroup.Group("/elfinder").Use(
 			auth.HTTPMiddleSessionAuth(jmsService)).GET("/", webSrv.ProcessElfinderWebsocket)
 
+		// 使用 Web 控制台签名的令牌认证，进入与 ssh 登录相同的交互菜单
+		wsGroup.Group("/interactive").Use(
+			auth.HTTPMiddleTerminalTokenAuth(jmsService)).GET("/", webSrv.ProcessInteractiveWebsocket)
+
 	}
 
 	connectGroup := kokoGroup.Group("/connect")
@@ -2,10 +2,12 @@ package httpd
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net"
 	"net/http"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/LeeEirc/elfinder"
@@ -45,12 +47,38 @@ type Server struct {
 	broadCaster *broadcaster
 	Srv         *http.Server
 	apiClient   *service.JMService
+
+	listening atomic.Bool
 }
 
 func (s *Server) Start() {
 	go s.broadCaster.Start()
 	logger.Info("Start HTTP Server at ", s.Srv.Addr)
-	log.Print(s.Srv.ListenAndServe())
+	ln, err := net.Listen("tcp", s.Srv.Addr)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	s.listening.Store(true)
+	defer s.listening.Store(false)
+	log.Print(s.Srv.Serve(ln))
+}
+
+// CheckListening 用于健康检查，HTTP 服务正在监听时返回 nil
+func (s *Server) CheckListening() error {
+	if !s.listening.Load() {
+		return errors.New("http server is not listening")
+	}
+	return nil
+}
+
+// StopAccept 关闭监听，不再接受新的连接，已建立的 websocket 连接不受影响
+func (s *Server) StopAccept() {
+	ctx, cancelFunc := context.WithTimeout(context.TODO(), time.Second)
+	defer cancelFunc()
+	if s.Srv != nil {
+		_ = s.Srv.Shutdown(ctx)
+	}
 }
 
 func (s *Server) Stop() {
@@ -0,0 +1,25 @@
+package i18n
+
+import (
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// 排序规则使用的语言，中文按拼音排序
+var collateTags = map[LanguageCode]language.Tag{
+	ZH: language.SimplifiedChinese,
+	JA: language.Japanese,
+	KO: language.Korean,
+	RU: language.Russian,
+	EN: language.AmericanEnglish,
+}
+
+// Collator 返回按语言习惯比较字符串的排序规则，忽略大小写的差异，数字按数值比较(web2 在 web10 之前)。
+// Collator 不能在多个 goroutine 中同时使用
+func (l LanguageCode) Collator() *collate.Collator {
+	tag, ok := collateTags[l]
+	if !ok {
+		tag = collateTags[ZH]
+	}
+	return collate.New(tag, collate.Numeric, collate.IgnoreCase)
+}
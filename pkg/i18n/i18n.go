@@ -16,6 +16,10 @@ func Initial() {
 		gotext.Configure(localePath, "en_US", "koko")
 	} else if strings.HasPrefix(strings.ToLower(cf.LanguageCode), "ja") {
 		gotext.Configure(localePath, "ja_JP", "koko")
+	} else if strings.HasPrefix(strings.ToLower(cf.LanguageCode), "ko") {
+		gotext.Configure(localePath, "ko_KR", "koko")
+	} else if strings.HasPrefix(strings.ToLower(cf.LanguageCode), "ru") {
+		gotext.Configure(localePath, "ru_RU", "koko")
 	} else {
 		gotext.Configure(localePath, "zh_CN", "koko")
 	}
@@ -23,11 +27,15 @@ func Initial() {
 }
 
 func setupLangMap(localePath string) {
-	for _, code := range []LanguageCode{EN, ZH, JA} {
+	locales := make(map[LanguageCode]*gotext.Locale)
+	for _, code := range []LanguageCode{EN, ZH, JA, KO, RU} {
 		enLocal := gotext.NewLocale(localePath, code.String())
 		enLocal.AddDomain("koko")
-		langMap[code] = enLocal
+		locales[code] = enLocal
 	}
+	langLock.Lock()
+	langMap = locales
+	langLock.Unlock()
 }
 
 func NewLang(code string) LanguageCode {
@@ -36,6 +44,10 @@ func NewLang(code string) LanguageCode {
 		return EN
 	} else if strings.Contains(code, "ja") {
 		return JA
+	} else if strings.Contains(code, "ko") {
+		return KO
+	} else if strings.Contains(code, "ru") {
+		return RU
 	}
 	return ZH
 }
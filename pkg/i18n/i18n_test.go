@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestT(t *testing.T) {
@@ -16,3 +17,16 @@ func TestMain(m *testing.M) {
 	code := m.Run()
 	os.Exit(code)
 }
+
+func TestFormatTime(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 16, 30, 0, 0, time.UTC)
+	if got := ZH.FormatTime(ts, LoadTimezone("Asia/Shanghai")); got != "2024-03-02 00:30:00 CST" {
+		t.Errorf("FormatTime() = %q, want %q", got, "2024-03-02 00:30:00 CST")
+	}
+	if loc := LoadTimezone("Invalid/Zone"); loc != time.UTC {
+		t.Errorf("LoadTimezone() invalid = %s, want UTC", loc)
+	}
+	if got := EN.FormatTime(ts, nil); got != "Mar 1, 2024 16:30:00 UTC" {
+		t.Errorf("FormatTime() = %q, want %q", got, "Mar 1, 2024 16:30:00 UTC")
+	}
+}
@@ -1,6 +1,8 @@
 package i18n
 
 import (
+	"sync"
+
 	"github.com/leonelquinteros/gotext"
 )
 
@@ -8,10 +10,14 @@ const (
 	ZH LanguageCode = "zh_CN"
 	EN LanguageCode = "en_US"
 	JA LanguageCode = "ja_JP"
+	KO LanguageCode = "ko_KR"
+	RU LanguageCode = "ru_RU"
 )
 
 var (
 	langMap = make(map[LanguageCode]*gotext.Locale)
+	// 重新加载配置时会替换语言包
+	langLock sync.RWMutex
 )
 
 type LanguageCode string
@@ -21,8 +27,25 @@ func (l LanguageCode) String() string {
 }
 
 func (l LanguageCode) T(s string) string {
-	if lang, ok := langMap[l]; ok {
+	langLock.RLock()
+	lang, ok := langMap[l]
+	langLock.RUnlock()
+	if ok {
 		return lang.Get(s)
 	}
 	return s
 }
+
+// TN 返回 n 对应的复数形式，没有翻译时 n 为 1 返回 singular，否则返回 plural
+func (l LanguageCode) TN(singular, plural string, n int) string {
+	langLock.RLock()
+	lang, ok := langMap[l]
+	langLock.RUnlock()
+	if ok {
+		return lang.GetN(singular, plural, n)
+	}
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
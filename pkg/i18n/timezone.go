@@ -0,0 +1,43 @@
+package i18n
+
+import (
+	"strings"
+	"time"
+
+	// 内置时区数据，运行环境中没有安装 tzdata 时也可以解析时区
+	_ "time/tzdata"
+)
+
+// 面向用户显示的时间格式，按语言习惯排列年月日
+var timeLayouts = map[LanguageCode]string{
+	ZH: "2006-01-02 15:04:05 MST",
+	JA: "2006/01/02 15:04:05 MST",
+	KO: "2006. 01. 02. 15:04:05 MST",
+	RU: "02.01.2006 15:04:05 MST",
+	EN: "Jan 2, 2006 15:04:05 MST",
+}
+
+// LoadTimezone 解析 IANA 时区名称(如 Asia/Shanghai)，为空或者无效时返回 UTC
+func LoadTimezone(name string) *time.Location {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.EqualFold(name, "Local") {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// FormatTime 将时间转换到 loc 时区后按语言习惯格式化，loc 为空时使用 UTC
+func (l LanguageCode) FormatTime(t time.Time, loc *time.Location) string {
+	if loc == nil {
+		loc = time.UTC
+	}
+	layout, ok := timeLayouts[l]
+	if !ok {
+		layout = timeLayouts[ZH]
+	}
+	return t.In(loc).Format(layout)
+}
@@ -3,6 +3,7 @@ package httplib
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -19,7 +20,7 @@ type AuthSign interface {
 	Sign(req *http.Request) error
 }
 
-const miniTimeout = time.Second * 30
+const miniTimeout = time.Second * 5
 
 func NewClient(baseUrl string, timeout time.Duration) (*Client, error) {
 	_, err := url.Parse(baseUrl)
@@ -53,6 +54,7 @@ type Client struct {
 	headers  map[string]string
 	http     *http.Client
 	authSign AuthSign
+	retry    RetryPolicy
 }
 
 func (c *Client) Clone() Client {
@@ -69,6 +71,7 @@ func (c *Client) Clone() Client {
 		cookies: make(map[string]string),
 		headers: make(map[string]string),
 		http:    &con,
+		retry:   c.retry,
 	}
 
 }
@@ -85,6 +88,10 @@ func (c *Client) SetAuthSign(auth AuthSign) {
 	c.authSign = auth
 }
 
+func (c *Client) SetRetryPolicy(policy RetryPolicy) {
+	c.retry = policy
+}
+
 func (c *Client) setReqAuthHeader(r *http.Request) error {
 	if len(c.cookies) != 0 {
 		for k, v := range c.cookies {
@@ -151,7 +158,34 @@ func (c *Client) newRequest(method, reqUrl string, data interface{}, params []ma
 	return req, err
 }
 
+/*
+Do 发送请求，GET 请求在 core 无法访问时按照 RetryPolicy 重试。
+其他方法的请求可能已经被 core 处理(如创建了会话、登录日志等审计记录)，失败时不重试。
+重试后仍然无法访问时返回 UnreachableError。
+*/
+
 func (c *Client) Do(method, reqUrl string, data, res interface{}, params ...map[string]string) (resp *http.Response, err error) {
+	retries := 0
+	if method == http.MethodGet {
+		retries = c.retry.Count
+	}
+	backoff := c.retry.Backoff
+	for attempt := 1; ; attempt++ {
+		resp, err = c.do(method, reqUrl, data, res, params)
+		if !isUnreachable(resp, err) {
+			return resp, err
+		}
+		if attempt > retries {
+			return resp, &UnreachableError{Attempts: attempt, Err: err}
+		}
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+func (c *Client) do(method, reqUrl string, data, res interface{}, params []map[string]string) (resp *http.Response, err error) {
 	req, err := c.newRequest(method, reqUrl, data, params)
 	if err != nil {
 		return
@@ -262,6 +296,43 @@ func (c *Client) PostFileWithFields(reqUrl string, gFile string, fields map[stri
 	return c.handleResp(resp, res)
 }
 
+/*
+GetStream 返回 GET 请求响应的 body，由调用方流式读取并关闭，用于下载录像等较大的文件。
+读取不受 Timeout 的限制，通过 ctx 取消下载；其他主机的地址(如对象存储的临时链接)不发送认证信息。
+*/
+
+func (c *Client) GetStream(ctx context.Context, reqUrl string) (io.ReadCloser, error) {
+	withAuth := true
+	if u, err := url.Parse(reqUrl); err == nil && u.IsAbs() {
+		base, err2 := url.Parse(c.baseUrl)
+		withAuth = err2 == nil && strings.EqualFold(u.Host, base.Host)
+	} else {
+		reqUrl = c.parseUrl(reqUrl, nil)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	if withAuth {
+		if err = c.setReqHeaders(req); err != nil {
+			return nil, err
+		}
+	}
+	// 复用连接池和代理等传输设置，只去掉整个请求的超时
+	client := *c.http
+	client.Timeout = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("GET %s failed, get code: %d, %s", req.URL, resp.StatusCode, body)
+	}
+	return resp.Body, nil
+}
+
 func (c *Client) handleResp(resp *http.Response, res interface{}) (err error) {
 	req := resp.Request
 	// If is buffer return the raw response body
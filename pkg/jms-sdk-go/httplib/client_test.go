@@ -0,0 +1,47 @@
+package httplib
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countTransport struct {
+	count int32
+}
+
+func (t *countTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.count, 1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestClientGetStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("replay"))
+	}))
+	defer srv.Close()
+	client, err := NewClient(srv.URL, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := &countTransport{}
+	client.http.Transport = transport
+	body, err := client.GetStream(context.Background(), "/replay/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	if data, _ := io.ReadAll(body); string(data) != "replay" {
+		t.Errorf("GetStream() body = %q, want replay", data)
+	}
+	if atomic.LoadInt32(&transport.count) != 1 {
+		t.Error("GetStream() should use the client transport")
+	}
+	if client.http.Timeout != client.Timeout {
+		t.Errorf("client timeout changed to %s", client.http.Timeout)
+	}
+}
@@ -0,0 +1,51 @@
+package httplib
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// maxRetryBackoff 重试的最长等待时间
+const maxRetryBackoff = 5 * time.Second
+
+// RetryPolicy GET 请求的重试策略，Count 为 0 时不重试，Backoff 为第一次重试前等待的时间，之后每次加倍
+type RetryPolicy struct {
+	Count   int
+	Backoff time.Duration
+}
+
+// UnreachableError core 连接失败、超时或者返回 502、503、504，重试后仍然失败
+type UnreachableError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *UnreachableError) Error() string {
+	return fmt.Sprintf("core api unreachable after %d attempts: %s", e.Attempts, e.Err)
+}
+
+func (e *UnreachableError) Unwrap() error {
+	return e.Err
+}
+
+// IsUnreachable 请求是否因为 core 无法访问而失败
+func IsUnreachable(err error) bool {
+	var unreachableErr *UnreachableError
+	return errors.As(err, &unreachableErr)
+}
+
+func isUnreachable(resp *http.Response, err error) bool {
+	if err == nil {
+		return false
+	}
+	if resp == nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package httplib
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientRetry(t *testing.T) {
+	var requests int32
+	var failures int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"koko"}`))
+	}))
+	defer srv.Close()
+	client, err := NewClient(srv.URL, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetRetryPolicy(RetryPolicy{Count: 2, Backoff: time.Millisecond})
+
+	tests := []struct {
+		method      string
+		failures    int32
+		requests    int32
+		unreachable bool
+	}{
+		// GET 请求在 core 恢复后成功
+		{http.MethodGet, 2, 3, false},
+		{http.MethodGet, 5, 3, true},
+		// 其他方法的请求不重试
+		{http.MethodPost, 1, 1, true},
+		{http.MethodPost, 0, 1, false},
+	}
+	for _, tt := range tests {
+		atomic.StoreInt32(&requests, 0)
+		atomic.StoreInt32(&failures, tt.failures)
+		var res struct {
+			Name string `json:"name"`
+		}
+		_, err = client.Do(tt.method, "/api/", nil, &res)
+		if got := atomic.LoadInt32(&requests); got != tt.requests {
+			t.Errorf("%s with %d failures sent %d requests, want %d", tt.method, tt.failures, got, tt.requests)
+		}
+		if IsUnreachable(err) != tt.unreachable {
+			t.Errorf("%s with %d failures: IsUnreachable(%v) = %v, want %v",
+				tt.method, tt.failures, err, !tt.unreachable, tt.unreachable)
+		}
+		if !tt.unreachable && (err != nil || res.Name != "koko") {
+			t.Errorf("%s with %d failures = %v, %+v", tt.method, tt.failures, err, res)
+		}
+	}
+}
+
+func TestClientUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	addr := srv.URL
+	srv.Close()
+	client, err := NewClient(addr, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetRetryPolicy(RetryPolicy{Count: 1, Backoff: time.Millisecond})
+	_, err = client.Get("/api/", nil)
+	unreachableErr, ok := err.(*UnreachableError)
+	if !ok {
+		t.Fatalf("Get() error = %v, want UnreachableError", err)
+	}
+	if unreachableErr.Attempts != 2 {
+		t.Errorf("attempts = %d, want 2", unreachableErr.Attempts)
+	}
+	// 404 等 core 的响应不是无法访问
+	srv = httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	client, _ = NewClient(srv.URL, time.Second)
+	if _, err = client.Get("/api/", nil); err == nil || IsUnreachable(err) {
+		t.Errorf("Get() 404 error = %v", err)
+	}
+}
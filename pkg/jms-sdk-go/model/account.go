@@ -11,6 +11,8 @@ type BaseAccount struct {
 	Username   string     `json:"username"`
 	Secret     string     `json:"secret"`
 	SecretType LabelValue `json:"secret_type"`
+	// ssh_key 类型账号可选的 OpenSSH 证书
+	Certificate string `json:"certificate,omitempty"`
 }
 
 func (a *BaseAccount) String() string {
@@ -19,6 +21,9 @@ func (a *BaseAccount) String() string {
 
 func (a *BaseAccount) HashId() string {
 	content := fmt.Sprintf("%s_%s", a.Username, a.Secret)
+	if a.Certificate != "" {
+		content = fmt.Sprintf("%s_%s", content, a.Certificate)
+	}
 	return fmt.Sprintf("%x", md5.Sum([]byte(content)))
 }
 
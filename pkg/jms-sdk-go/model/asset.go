@@ -2,6 +2,7 @@ package model
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -17,6 +18,8 @@ type SpecInfo struct {
 
 	UseSSL           bool `json:"use_ssl"`
 	AllowInvalidCert bool `json:"allow_invalid_cert"`
+	// PostgreSQL 的证书校验模式 disable/require/verify-full，为空时根据 UseSSL 和 AllowInvalidCert 判断
+	SSLMode string `json:"ssl_mode"`
 
 	// web
 	Autofill         string `json:"autofill"`
@@ -42,6 +45,67 @@ type Asset struct {
 	IsActive bool   `json:"is_active"` // 判断资产是否禁用
 
 	Accounts Actions `json:"accounts,omitempty"` // 只有 detail api才会有这个字段
+
+	Labels []Label `json:"labels,omitempty"`
+
+	Nodes []BaseNode `json:"nodes,omitempty"`
+}
+
+type Label struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// AssetMotdLabel 资产上名称为 motd 的标签，连接资产后显示标签的值
+const AssetMotdLabel = "motd"
+
+// Motd 返回资产配置的连接提示，存在多个 motd 标签时按顺序换行拼接
+func (a *Asset) Motd() string {
+	lines := make([]string, 0, 1)
+	for i := range a.Labels {
+		if a.Labels[i].Name == AssetMotdLabel && a.Labels[i].Value != "" {
+			lines = append(lines, a.Labels[i].Value)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// AssetMaxDurationLabel 资产上名称为 max_session_duration 的标签，值为会话的最长分钟数，覆盖全局配置
+const AssetMaxDurationLabel = "max_session_duration"
+
+// MaxSessionDuration 返回资产标签配置的会话最长分钟数，没有配置或者值无效时 ok 为 false
+func (a *Asset) MaxSessionDuration() (minutes int, ok bool) {
+	for i := range a.Labels {
+		if a.Labels[i].Name != AssetMaxDurationLabel {
+			continue
+		}
+		if value, err := strconv.Atoi(strings.TrimSpace(a.Labels[i].Value)); err == nil && value >= 0 {
+			return value, true
+		}
+	}
+	return 0, false
+}
+
+// AssetMaxSessionsLabel 资产上名称为 max_sessions 的标签，值为资产在每个 koko 节点上同时进行的会话数
+const AssetMaxSessionsLabel = "max_sessions"
+
+// MaxSessions 返回资产标签配置的最大会话数，没有配置或者值不是正整数时 ok 为 false
+func (a *Asset) MaxSessions() (limit int, ok bool) {
+	for i := range a.Labels {
+		if a.Labels[i].Name != AssetMaxSessionsLabel {
+			continue
+		}
+		if value, err := strconv.Atoi(strings.TrimSpace(a.Labels[i].Value)); err == nil && value > 0 {
+			return value, true
+		}
+	}
+	return 0, false
+}
+
+type BaseNode struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
 }
 
 type BaseDomain struct {
@@ -109,6 +173,16 @@ type Gateway struct {
 	Account   Account   `json:"account"`
 }
 
+// IsSocks5 网关配置了 socks5 协议时，作为 SOCKS5 代理使用
+func (g Gateway) IsSocks5() bool {
+	return g.Protocols.IsSupportProtocol(ProtocolSocks5)
+}
+
+// IsHTTPProxy 网关配置了 http_proxy 协议时，作为 HTTP CONNECT 代理使用
+func (g Gateway) IsHTTPProxy() bool {
+	return g.Protocols.IsSupportProtocol(ProtocolHTTPProxy)
+}
+
 type Protocols []Protocol
 
 func (p Protocols) GetProtocolPort(protocol string) int {
@@ -141,4 +215,7 @@ const (
 	ProtocolK8S    = "k8s"
 	ProtocolSFTP   = "sftp"
 	ProtocolRedis  = "redis"
+	ProtocolSocks5 = "socks5"
+
+	ProtocolHTTPProxy = "http_proxy"
 )
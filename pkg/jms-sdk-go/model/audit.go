@@ -16,6 +16,9 @@ type FTPLog struct {
 	DateStart  common.UTCTime `json:"date_start"`
 	IsSuccess  bool           `json:"is_success"`
 	Session    string         `json:"session"`
+
+	Size  int64  `json:"size,omitempty"`  // 上传或下载完成后的文件大小
+	Error string `json:"error,omitempty"` // 操作失败的原因
 }
 
 const (
@@ -31,3 +34,20 @@ const (
 	OperateDelete    = "delete"
 	OperateSymlink   = "symlink"
 )
+
+// LoginLog 用户登录日志
+type LoginLog struct {
+	ID       string         `json:"id,omitempty"`
+	Username string         `json:"username"`
+	IP       string         `json:"ip"`
+	Type     string         `json:"type"`
+	Status   bool           `json:"status"`
+	Datetime common.UTCTime `json:"datetime"`
+}
+
+type LoginLogList struct {
+	Total int        `json:"count"`
+	Data  []LoginLog `json:"results"`
+}
+
+const LoginTypeTerminal = "T"
@@ -19,6 +19,22 @@ type Command struct {
 	DateCreated time.Time `json:"@timestamp"`
 }
 
+// CommandRecord core 中保存的命令记录，用于用户查询自己的历史命令
+type CommandRecord struct {
+	ID        string `json:"id"`
+	User      string `json:"user"`
+	Asset     string `json:"asset"`
+	Account   string `json:"account"`
+	Input     string `json:"input"`
+	SessionID string `json:"session"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type CommandRecordList struct {
+	Total int             `json:"count"`
+	Data  []CommandRecord `json:"results"`
+}
+
 const (
 	NormalLevel  = 0
 	WarningLevel = 4
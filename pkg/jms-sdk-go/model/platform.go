@@ -60,6 +60,12 @@ type ProtocolSetting struct {
 	TelnetUsernamePrompt string `json:"username_prompt"`
 	TelnetPasswordPrompt string `json:"password_prompt"`
 	TelnetSuccessPrompt  string `json:"success_prompt"`
+
+	// 会话建立后执行的初始化命令，每行一条
+	InitCommands string `json:"init_commands"`
+
+	// 资产期望的回车序列：lf、cr、crlf，为空时不转换
+	LineEnding string `json:"line_ending"`
 }
 
 type Protocol struct {
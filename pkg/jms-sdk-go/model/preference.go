@@ -0,0 +1,57 @@
+package model
+
+const (
+	MacroStepCommand = "command"
+	MacroStepDelay   = "delay"
+	MacroStepWait    = "wait"
+)
+
+// KokoPreference 用户在 core 中保存的 koko 偏好设置
+type KokoPreference struct {
+	Macros []ConnectMacro `json:"macros"`
+
+	// 未设置时更新偏好设置不会覆盖 core 中已保存的值
+	FirstLoginNoticeSeen bool `json:"first_login_notice_seen,omitempty"`
+
+	// 显示时间使用的时区，如 Asia/Shanghai
+	Timezone string `json:"timezone,omitempty"`
+
+	// 会话结束后显示执行的命令摘要，未设置时不显示
+	CommandSummary *bool `json:"command_summary,omitempty"`
+
+	// 用户选择的语言，如 en_US，未设置时使用节点的默认语言
+	Language string `json:"language,omitempty"`
+
+	// 自定义的菜单提示符模板，空字符串表示恢复默认提示符
+	Prompt *string `json:"prompt,omitempty"`
+
+	// 资产输出中响铃字符的处理策略，未设置时使用节点的配置
+	BellPolicy string `json:"bell_policy,omitempty"`
+}
+
+// ConnectMacro 连接宏，登录资产后依次执行其中的步骤
+type ConnectMacro struct {
+	Name    string      `json:"name"`
+	AssetID string      `json:"asset_id,omitempty"` // 为空表示所有资产可用
+	Asset   string      `json:"asset,omitempty"`    // 资产名称，仅用于显示
+	Steps   []MacroStep `json:"steps"`
+	// Trusted 可信的宏执行前不需要用户确认
+	Trusted bool `json:"trusted,omitempty"`
+}
+
+func (m *ConnectMacro) IsAvailable(assetId string) bool {
+	return m.AssetID == "" || m.AssetID == assetId
+}
+
+/*
+MacroStep 连接宏的单个步骤
+command: Value 为执行的命令
+delay: Value 为等待的时长，如 500ms、2s
+wait: Value 为正则表达式，等待资产的输出匹配(如 shell 提示符)后再继续，Timeout 为超时秒数
+*/
+
+type MacroStep struct {
+	Type    string `json:"type"`
+	Value   string `json:"value"`
+	Timeout int    `json:"timeout,omitempty"`
+}
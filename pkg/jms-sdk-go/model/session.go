@@ -35,6 +35,7 @@ const (
 	TUNNELType  LabelField = "tunnel"
 	COMMANDType LabelField = "command"
 	SFTPType    LabelField = "sftp"
+	DYNAMICType LabelField = "dynamic"
 )
 
 const (
@@ -56,6 +57,89 @@ type Session struct {
 	AssetID    string         `json:"asset_id"`
 	AccountID  string         `json:"account_id"`
 	Type       LabelField     `json:"type"`
+	// Comment 用户连接时填写的备注，如连接原因、工单号
+	Comment string `json:"comment,omitempty"`
+	// Tags 客户端通过环境变量传递的会话标签，如 CI 任务 ID
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+type LifecycleEvent string
+
+const (
+	AdminTerminate LifecycleEvent = "admin_terminate"
+
+	// CommandFilterPreview 过滤预览模式下命令命中了会拦截的规则
+	CommandFilterPreview LifecycleEvent = "command_filter_preview"
+
+	// MultiLinePaste 用户粘贴了多行内容
+	MultiLinePaste LifecycleEvent = "multi_line_paste"
+
+	// ReplayForced 资产命中了强制录像的规则
+	ReplayForced LifecycleEvent = "replay_forced"
+	// ReplayExempted 资产命中了豁免录像的规则
+	ReplayExempted LifecycleEvent = "replay_exempted"
+
+	// SessionDetached 客户端断开，持久会话进入分离状态
+	SessionDetached LifecycleEvent = "session_detached"
+	// SessionReattached 用户恢复了分离的会话
+	SessionReattached LifecycleEvent = "session_reattached"
+
+	// ConnectConfirmed 用户输入资产名称确认连接需要确认的资产
+	ConnectConfirmed LifecycleEvent = "connect_confirmed"
+	// ConnectApproved 需要审批的资产，审批人批准了连接
+	ConnectApproved LifecycleEvent = "connect_approved"
+	// TerminalTitleChange 资产输出中修改终端标题的序列
+	TerminalTitleChange LifecycleEvent = "terminal_title_change"
+	// BellRateExceeded 资产输出响铃字符的频率超过阈值
+	BellRateExceeded LifecycleEvent = "bell_rate_exceeded"
+	// ClientAgentAuth 使用用户转发的 SSH agent 认证资产
+	ClientAgentAuth LifecycleEvent = "client_agent_auth"
+
+	// AdminObserveStart 管理员开始旁观会话
+	AdminObserveStart LifecycleEvent = "admin_observe_start"
+	// AdminObserveEnd 管理员结束旁观会话
+	AdminObserveEnd LifecycleEvent = "admin_observe_end"
+
+	// MacroRun 会话建立后执行用户的连接宏
+	MacroRun LifecycleEvent = "macro_run"
+)
+
+/*
+SessionExitReason 会话结束的原因，结束会话时记录到会话审计中，值作为统计使用，不能修改
+*/
+
+type SessionExitReason string
+
+const (
+	// ExitUserExit 资产端正常结束了会话，如用户在资产上执行 exit
+	ExitUserExit SessionExitReason = "user_exit"
+	// ExitUserDisconnect 用户的客户端断开了连接
+	ExitUserDisconnect SessionExitReason = "user_disconnect"
+	// ExitAssetDisconnect 与资产的连接异常断开，如网络中断、keepalive 无回复
+	ExitAssetDisconnect SessionExitReason = "asset_disconnect"
+	ExitConnectFailed   SessionExitReason = "connect_failed"
+	ExitIdleTimeout     SessionExitReason = "idle_timeout"
+	ExitMaxSessionTime  SessionExitReason = "max_session_time"
+
+	ExitPermissionExpired SessionExitReason = "permission_expired"
+	ExitAdminTerminate    SessionExitReason = "admin_terminate"
+	// ExitNodeShutdown koko 停止服务时关闭了会话
+	ExitNodeShutdown SessionExitReason = "node_shutdown"
+	// ExitNodeInterrupted koko 异常退出，重启后补充结束遗留的会话
+	ExitNodeInterrupted SessionExitReason = "node_interrupted"
+	// ExitCommandFinished ssh 执行命令的会话，命令执行结束
+	ExitCommandFinished SessionExitReason = "command_finished"
+	// ExitDetachExpired 分离的持久会话超过宽限期没有恢复
+	ExitDetachExpired SessionExitReason = "detach_expired"
+	// ExitMaxDurationExceeded 会话超过了 koko 配置的最长时间，与活动无关
+	ExitMaxDurationExceeded SessionExitReason = "max_duration_exceeded"
+	// ExitEscapeSequence 用户在行首输入了退出序列，强制关闭与资产的连接
+	ExitEscapeSequence SessionExitReason = "escape_sequence"
+)
+
+type SessionLifecycleLog struct {
+	Reason string `json:"reason"`
+	User   string `json:"user"`
 }
 
 type ReplayVersion string
@@ -78,6 +162,13 @@ var SuffixMap = map[ReplayVersion]string{
 	Version3: SuffixCastGz,
 }
 
+// ReplayInfo core 返回的会话录像信息，Src 为录像文件的下载地址
+type ReplayInfo struct {
+	Type   string `json:"type"`
+	Src    string `json:"src"`
+	Status string `json:"status"`
+}
+
 func ParseReplayVersion(gzFile string, defaultValue ReplayVersion) ReplayVersion {
 	for version, suffix := range SuffixMap {
 		if strings.HasSuffix(gzFile, suffix) {
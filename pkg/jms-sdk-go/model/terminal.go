@@ -16,6 +16,13 @@ type TerminalConfig struct {
 	HostKey             string                 `json:"TERMINAL_HOST_KEY"`
 	EnableSessionShare  bool                   `json:"SECURITY_SESSION_SHARE"`
 	MaxStoreFTPFileSize int                    `json:"FTP_FILE_MAX_STORE"`
+	FuzzySearch         bool                   `json:"TERMINAL_FUZZY_SEARCH"`
+	IdleTimeout         int                    `json:"TERMINAL_IDLE_TIMEOUT"`
+	KeepAliveInterval   int                    `json:"TERMINAL_KEEPALIVE_INTERVAL"`
+	KeepAliveCountMax   int                    `json:"TERMINAL_KEEPALIVE_COUNT_MAX"`
+	AutoReconnect       bool                   `json:"TERMINAL_AUTO_RECONNECT"`
+	ReconnectMaxRetries int                    `json:"TERMINAL_RECONNECT_MAX_RETRIES"`
+	EnvAllowlist        []string               `json:"TERMINAL_ENV_ALLOWLIST"`
 }
 
 type Terminal struct {
@@ -41,6 +48,14 @@ const (
 
 	TaskLockSession   = "lock_session"
 	TaskUnlockSession = "unlock_session"
+
+	// TaskBroadcastMessage Args 为广播的消息内容
+	TaskBroadcastMessage = "broadcast_message"
+
+	// koko 停止服务时发给本节点会话的任务，不由 core 下发
+	// TaskShutdownNotice Args 为会话关闭前剩余的秒数
+	TaskShutdownNotice  = "shutdown_notice"
+	TaskShutdownSession = "shutdown_session"
 )
 
 type TaskKwargs struct {
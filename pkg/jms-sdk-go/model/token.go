@@ -17,6 +17,9 @@ type ConnectToken struct {
 	OrgName  string     `json:"org_name"`
 	Platform Platform   `json:"platform"`
 
+	// GatewayChain 多级网关，从 koko 到资产依次经过的网关
+	GatewayChain []Gateway `json:"gateway_chain"`
+
 	ConnectOptions ConnectOptions `json:"connect_options"`
 
 	CommandFilterACLs []CommandACL `json:"command_filter_acls"`
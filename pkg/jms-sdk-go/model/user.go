@@ -2,6 +2,9 @@ package model
 
 import (
 	"fmt"
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/common"
 )
 
 type User struct {
@@ -15,16 +18,42 @@ type User struct {
 	OTPLevel int    `json:"otp_level"`
 }
 
+// OTPVerifyResult Enrolled 为 false 表示用户没有绑定 OTP
+type OTPVerifyResult struct {
+	OK       bool   `json:"ok"`
+	Enrolled bool   `json:"enrolled"`
+	Msg      string `json:"msg"`
+}
+
+// PasswordExpiry 用户密码的过期时间，core 没有设置密码过期时为空
+type PasswordExpiry struct {
+	DatePasswordExpired *common.UTCTime `json:"date_password_expired"`
+}
+
 type MiniUser struct {
 	ID       string `json:"id"`
 	Name     string `json:"name"`
 	Username string `json:"username"`
 }
 
+const (
+	RoleAdmin   = "Admin"
+	RoleAuditor = "Auditor"
+)
+
 func (u *User) String() string {
 	return fmt.Sprintf("%s(%s)", u.Name, u.Username)
 }
 
+func (u *User) IsAdmin() bool {
+	return strings.EqualFold(u.Role, RoleAdmin)
+}
+
+// IsAuditor 审计员可以查看所有用户的会话录像
+func (u *User) IsAuditor() bool {
+	return strings.EqualFold(u.Role, RoleAuditor)
+}
+
 type TokenUser struct {
 	UserID         string `json:"user"`
 	UserName       string `json:"username"`
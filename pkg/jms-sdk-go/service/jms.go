@@ -19,7 +19,7 @@ var AccessKeyUnauthorized = errors.New("access key unauthorized")
 var ConnectErr = errors.New("api connect err")
 
 const (
-	minTimeOut = time.Second * 30
+	minTimeOut = time.Second * 5
 
 	orgHeaderKey   = "X-JMS-ORG"
 	orgHeaderValue = "ROOT"
@@ -44,6 +44,7 @@ func NewAuthJMService(opts ...Option) (*JMService, error) {
 		httpClient.SetAuthSign(opt.sign)
 	}
 	httpClient.SetHeader(orgHeaderKey, orgHeaderValue)
+	httpClient.SetRetryPolicy(opt.retry)
 	return &JMService{authClient: httpClient, opt: &opt}, nil
 }
 
@@ -60,6 +61,13 @@ func (s *JMService) GetUserById(userID string) (user *model.User, err error) {
 	return
 }
 
+// GetUserPasswordExpiry 获取用户密码的过期时间
+func (s *JMService) GetUserPasswordExpiry(userID string) (res model.PasswordExpiry, err error) {
+	reqURL := fmt.Sprintf(UserDetailURL, userID)
+	_, err = s.authClient.Get(reqURL, &res)
+	return
+}
+
 func (s *JMService) GetProfile() (user *model.User, err error) {
 	var res *http.Response
 	res, err = s.authClient.Get(UserProfileURL, &user)
@@ -12,6 +12,15 @@ func (s *JMService) GetAssetPlatform(assetId string) (platform model.Platform, e
 	return
 }
 
+// SearchAssets 在所有资产中搜索，不限于用户授权的资产
+func (s *JMService) SearchAssets(search string) (assets []model.Asset, err error) {
+	params := map[string]string{
+		"search": search,
+	}
+	_, err = s.authClient.Get(AssetListURL, &assets, params)
+	return
+}
+
 func (s *JMService) GetDomainGateways(domainId string) (domain model.Domain, err error) {
 	Url := fmt.Sprintf(DomainDetailWithGateways, domainId)
 	_, err = s.authClient.Get(Url, &domain)
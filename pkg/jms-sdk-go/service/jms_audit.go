@@ -1,6 +1,9 @@
 package service
 
 import (
+	"fmt"
+	"strconv"
+
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
 )
 
@@ -9,6 +12,17 @@ func (s *JMService) CreateFileOperationLog(data model.FTPLog) (err error) {
 	return
 }
 
+func (s *JMService) UpdateFileOperationLog(data model.FTPLog) (err error) {
+	payload := map[string]interface{}{
+		"size":       data.Size,
+		"is_success": data.IsSuccess,
+		"error":      data.Error,
+	}
+	url := fmt.Sprintf(FTPLogUpdateURL, data.ID)
+	_, err = s.authClient.Patch(url, payload, nil)
+	return
+}
+
 func (s *JMService) PushSessionCommand(commands []*model.Command) (err error) {
 	_, err = s.authClient.Post(SessionCommandURL, commands, nil)
 	return
@@ -18,4 +32,41 @@ func (s *JMService) PushSessionCommand(commands []*model.Command) (err error) {
 func (s *JMService) NotifyCommand(commands []*model.Command) (err error) {
 	_, err = s.authClient.Post(NotificationCommandURL, commands, nil)
 	return
+}
+
+// SearchUserCommands 分页查询用户的历史命令，按时间倒序
+func (s *JMService) SearchUserCommands(user, search string, limit, offset int) (resp model.CommandRecordList, err error) {
+	params := map[string]string{
+		"user":   user,
+		"search": search,
+		"limit":  strconv.Itoa(limit),
+		"offset": strconv.Itoa(offset),
+		"order":  "-timestamp",
+	}
+	_, err = s.authClient.Get(SessionCommandURL, &resp, params)
+	return
+}
+
+// GetUserLastLogin 获取用户最近一次成功的登录记录，没有记录时返回 nil
+func (s *JMService) GetUserLastLogin(username string) (*model.LoginLog, error) {
+	var resp model.LoginLogList
+	params := map[string]string{
+		"username": username,
+		"status":   "1",
+		"limit":    "1",
+		"offset":   "0",
+		"order":    "-datetime",
+	}
+	if _, err := s.authClient.Get(LoginLogListURL, &resp, params); err != nil {
+		return nil, err
+	}
+	if len(resp.Data) == 0 {
+		return nil, nil
+	}
+	return &resp.Data[0], nil
+}
+
+func (s *JMService) CreateLoginLog(data model.LoginLog) (err error) {
+	_, err = s.authClient.Post(LoginLogListURL, data, nil)
+	return
 }
\ No newline at end of file
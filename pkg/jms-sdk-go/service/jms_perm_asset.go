@@ -35,6 +35,14 @@ func (s *JMService) GetUserPermsAssets(userID string, params model.PaginationPar
 	return s.getPaginationAssets(Url, params)
 }
 
+// GetUserPermsAssetCount 返回用户有权限的资产数量，只请求一条资产
+func (s *JMService) GetUserPermsAssetCount(userID string, params model.PaginationParam) (int, error) {
+	params.PageSize = 1
+	params.Offset = 0
+	res, err := s.GetUserPermsAssets(userID, params)
+	return res.Total, err
+}
+
 func (s *JMService) RefreshUserAllPermsAssets(userId string) ([]model.Asset, error) {
 	var params model.PaginationParam
 	params.Refresh = true
@@ -118,3 +126,9 @@ func (s *JMService) getPaginationAssets(reqUrl string, param model.PaginationPar
 	}
 	return
 }
+
+func (s *JMService) GetFavoriteAssets(userId string) (assets []model.Asset, err error) {
+	reqUrl := fmt.Sprintf(UserPermsFavoriteAssetsURL, userId)
+	_, err = s.authClient.Get(reqUrl, &assets)
+	return
+}
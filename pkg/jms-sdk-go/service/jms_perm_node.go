@@ -17,6 +17,19 @@ func (s *JMService) GetUserNodes(userId string) (nodes model.NodeList, err error
 	return
 }
 
+// GetUserNodeCount 返回用户有权限的节点数量，只请求一个节点
+func (s *JMService) GetUserNodeCount(userId string) (int, error) {
+	var res struct {
+		Total int `json:"count"`
+	}
+	params := map[string]string{
+		"limit": "1",
+	}
+	Url := fmt.Sprintf(UserPermsNodesListURL, userId)
+	_, err := s.authClient.Get(Url, &res, params)
+	return res.Total, err
+}
+
 func (s *JMService) RefreshUserNodes(userId string) (nodes model.NodeList, err error) {
 	params := map[string]string{
 		"rebuild_tree": "1",
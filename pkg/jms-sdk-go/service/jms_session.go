@@ -1,7 +1,10 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"io"
+
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/common"
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
 )
@@ -15,6 +18,18 @@ func (s *JMService) UploadReplay(sid, gZipFile string) error {
 	return s.authClient.PostFileWithFields(Url, gZipFile, fields, &res)
 }
 
+// GetSessionReplayInfo 获取会话录像的类型和下载地址
+func (s *JMService) GetSessionReplayInfo(sid string) (info model.ReplayInfo, err error) {
+	Url := fmt.Sprintf(SessionReplayURL, sid)
+	_, err = s.authClient.Get(Url, &info)
+	return
+}
+
+// DownloadSessionReplay 流式下载录像文件，调用方负责关闭
+func (s *JMService) DownloadSessionReplay(ctx context.Context, src string) (io.ReadCloser, error) {
+	return s.authClient.GetStream(ctx, src)
+}
+
 func (s *JMService) FinishReply(sid string) error {
 	data := map[string]bool{"has_replay": true}
 	return s.sessionPatch(sid, data)
@@ -52,13 +67,14 @@ func (s *JMService) SessionFailed(sid string, err error) error {
 	return s.sessionPatch(sid, data)
 }
 func (s *JMService) SessionDisconnect(sid string) error {
-	return s.SessionFinished(sid, common.NewNowUTCTime())
+	return s.SessionFinished(sid, common.NewNowUTCTime(), model.ExitUserDisconnect)
 }
 
-func (s *JMService) SessionFinished(sid string, time common.UTCTime) error {
+func (s *JMService) SessionFinished(sid string, time common.UTCTime, reason model.SessionExitReason) error {
 	data := map[string]interface{}{
 		"is_finished": true,
 		"date_end":    time,
+		"exit_reason": reason,
 	}
 	return s.sessionPatch(sid, data)
 }
@@ -83,3 +99,14 @@ func (s *JMService) CreateSessionTicketRelation(sid, ticketId string) (err error
 	_, err = s.authClient.Post(TicketSessionURL, data, nil)
 	return
 }
+
+func (s *JMService) RecordSessionLifecycleLog(sid string, event model.LifecycleEvent, logObj model.SessionLifecycleLog) (err error) {
+	data := map[string]interface{}{
+		"event":  event,
+		"reason": logObj.Reason,
+		"user":   logObj.User,
+	}
+	reqURL := fmt.Sprintf(SessionLifecycleLogURL, sid)
+	_, err = s.authClient.Post(reqURL, data, nil)
+	return
+}
@@ -31,6 +31,19 @@ func (s *JMService) CheckIfNeedAssetLoginConfirm(userId, assetId,
 	return
 }
 
+// SubmitAssetLoginReview 为需要审批的资产创建登录工单，reason 为需要审批的原因
+func (s *JMService) SubmitAssetLoginReview(userId, assetId, accountUsername,
+	reason string) (res model.AssetLoginTicketInfo, err error) {
+	data := map[string]string{
+		"user_id":          userId,
+		"asset_id":         assetId,
+		"account_username": accountUsername,
+		"reason":           reason,
+	}
+	_, err = s.authClient.Post(AssetLoginReviewURL, data, &res)
+	return
+}
+
 func (s *JMService) CancelConfirmByRequestInfo(req model.ReqInfo) (err error) {
 	res := make(map[string]interface{})
 	err = s.sendRequestByRequestInfo(req, &res)
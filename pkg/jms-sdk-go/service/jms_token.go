@@ -20,6 +20,13 @@ func (s *JMService) GetConnectTokenInfo(tokenId string) (resp model.ConnectToken
 	return
 }
 
+// ExpireConnectToken 使连接令牌失效，用于一次性令牌
+func (s *JMService) ExpireConnectToken(tokenId string) (err error) {
+	Url := fmt.Sprintf(SuperConnectTokenExpireURL, tokenId)
+	_, err = s.authClient.Patch(Url, nil, nil)
+	return
+}
+
 func (s *JMService) CreateSuperConnectToken(data *SuperConnectTokenReq) (resp model.ConnectTokenInfo, err error) {
 	_, err = s.authClient.Post(SuperConnectTokenInfoURL, data, &resp, data.Params)
 	return
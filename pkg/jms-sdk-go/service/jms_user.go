@@ -1,6 +1,10 @@
 package service
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
 )
 
@@ -12,3 +16,46 @@ func (s *JMService) CheckUserCookie(cookies map[string]string) (user *model.User
 	_, err = client.Get(UserProfileURL, &user)
 	return
 }
+
+const kokoPreferenceCategory = "koko"
+
+func (s *JMService) GetUserKokoPreference(userId string) (pref model.KokoPreference, err error) {
+	params := map[string]string{
+		"category": kokoPreferenceCategory,
+		"user_id":  userId,
+	}
+	_, err = s.authClient.Get(UserPreferenceURL, &pref, params)
+	return
+}
+
+func (s *JMService) UpdateUserKokoPreference(userId string, pref *model.KokoPreference) (err error) {
+	params := map[string]string{
+		"category": kokoPreferenceCategory,
+		"user_id":  userId,
+	}
+	_, err = s.authClient.Patch(UserPreferenceURL, pref, nil, params)
+	return
+}
+
+// ErrOTPVerifyUnsupported core 没有提供会话二次认证使用的 OTP 校验接口
+var ErrOTPVerifyUnsupported = errors.New("otp verify api not supported by core")
+
+/*
+VerifyUserOTP 使用用户绑定的 OTP 密钥校验动态码，供 SESSION_MFA_POLICY 使用。
+JumpServer core 登录流程中的 MFA 接口依赖用户的登录会话，终端无法代替用户调用，
+因此这里使用终端 access key 认证的独立接口，core(或者前置的认证服务)需要按以下约定提供：
+POST /api/v1/authentication/otp/verify/ 请求 {"user_id": "...", "code": "123456"}，
+返回 {"ok": 动态码是否正确, "enrolled": 用户是否绑定了 OTP, "msg": 失败原因}。
+接口不存在(404)时返回 ErrOTPVerifyUnsupported，调用方按认证失败处理。
+*/
+func (s *JMService) VerifyUserOTP(userId, code string) (res model.OTPVerifyResult, err error) {
+	data := map[string]string{
+		"user_id": userId,
+		"code":    code,
+	}
+	resp, err := s.authClient.Post(UserOTPVerifyURL, data, &res)
+	if err != nil && resp != nil && resp.StatusCode == http.StatusNotFound {
+		err = fmt.Errorf("%w: %s", ErrOTPVerifyUnsupported, err)
+	}
+	return
+}
@@ -11,6 +11,7 @@ type option struct {
 	CoreHost string
 	TimeOut  time.Duration
 	sign     httplib.AuthSign
+	retry    httplib.RetryPolicy
 }
 
 type Option func(*option)
@@ -27,6 +28,13 @@ func JMSTimeOut(t time.Duration) Option {
 	}
 }
 
+// JMSRetry core 无法访问时 GET 请求的重试次数和第一次重试前等待的时间
+func JMSRetry(count int, backoff time.Duration) Option {
+	return func(o *option) {
+		o.retry = httplib.RetryPolicy{Count: count, Backoff: backoff}
+	}
+}
+
 func JMSAccessKey(keyID, secretID string) Option {
 	return func(o *option) {
 		o.sign = &httplib.SigAuth{
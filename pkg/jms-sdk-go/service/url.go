@@ -14,6 +14,7 @@ const (
 	UserTokenAuthURL   = "/api/v1/authentication/tokens/"              // 用户登录验证
 	UserConfirmAuthURL = "/api/v1/authentication/login-confirm-ticket/status/"
 	AuthMFASelectURL   = "/api/v1/authentication/mfa/select/" // 选择 MFA
+	UserOTPVerifyURL   = "/api/v1/authentication/otp/verify/" // 校验用户绑定的 OTP, 需要 core 提供, 约定见 VerifyUserOTP
 
 )
 
@@ -28,6 +29,9 @@ const (
 	FTPLogListURL       = "/api/v1/audits/ftp-logs/" // 上传 ftp日志
 	FTPLogUpdateURL     = "/api/v1/audits/ftp-logs/%s/"
 	FTPLogFileURL       = "/api/v1/audits/ftp-logs/%s/upload/"
+	LoginLogListURL     = "/api/v1/audits/login-logs/" // 用户登录日志
+
+	SessionLifecycleLogURL = "/api/v1/terminal/sessions/%s/lifecycle_log/" // 会话生命周期事件
 )
 
 // 授权相关API
@@ -35,13 +39,16 @@ const (
 	UserPermsNodesListURL         = "/api/v1/perms/users/%s/nodes/"
 	UserPermsNodeAssetsListURL    = "/api/v1/perms/users/%s/nodes/%s/assets/"
 	UserPermsNodeTreeWithAssetURL = "/api/v1/perms/users/%s/nodes/children-with-assets/tree/" // 资产树
+	UserPermsFavoriteAssetsURL    = "/api/v1/perms/users/%s/nodes/favorite/assets/"           // 收藏的资产
 )
 
 // 各资源详情相关API
 const (
-	UserListURL      = "/api/v1/users/users/"
-	UserDetailURL    = "/api/v1/users/users/%s/"
-	AssetPlatFormURL = "/api/v1/assets/assets/%s/platform/"
+	UserListURL       = "/api/v1/users/users/"
+	UserDetailURL     = "/api/v1/users/users/%s/"
+	UserPreferenceURL = "/api/v1/users/preference/"
+	AssetListURL      = "/api/v1/assets/assets/"
+	AssetPlatFormURL  = "/api/v1/assets/assets/%s/platform/"
 
 	DomainDetailWithGateways = "/api/v1/assets/domains/%s/?gateway=1"
 )
@@ -70,10 +77,13 @@ const (
 	SuperConnectTokenSecretURL = "/api/v1/authentication/super-connection-token/secret/"
 	SuperConnectTokenInfoURL   = "/api/v1/authentication/super-connection-token/"
 
+	SuperConnectTokenExpireURL = "/api/v1/authentication/super-connection-token/%s/expire/"
+
 	UserPermsAssetAccountsURL = "/api/v1/perms/users/%s/assets/%s/accounts/"
 	AccountSecretURL          = "/api/v1/assets/account-secrets/%s/"
 	UserPermsAssetsURL        = "/api/v1/perms/users/%s/assets/"
 
 	AssetLoginConfirmURL = "/api/v1/acls/login-asset/check/"
+	AssetLoginReviewURL  = "/api/v1/acls/login-asset/review/"
 	AclCommandReviewURL  = "/api/v1/acls/command-filter-acls/command-review/"
 )
@@ -4,14 +4,21 @@ import (
 	"errors"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/credential"
 	"github.com/jumpserver/koko/pkg/exchange"
+	"github.com/jumpserver/koko/pkg/handler"
+	"github.com/jumpserver/koko/pkg/health"
 	"github.com/jumpserver/koko/pkg/httpd"
 	"github.com/jumpserver/koko/pkg/i18n"
 	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/metrics"
+	"github.com/jumpserver/koko/pkg/proxy"
+	"github.com/jumpserver/koko/pkg/rpc"
 	"github.com/jumpserver/koko/pkg/sshd"
 
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
@@ -19,18 +26,54 @@ import (
 )
 
 type Koko struct {
-	webSrv *httpd.Server
-	sshSrv *sshd.Server
+	webSrv     *httpd.Server
+	sshSrv     *sshd.Server
+	metricsSrv *metrics.Server
+	rpcSrv     *rpc.Server
+	healthSrv  *health.Server
 }
 
 func (k *Koko) Start() {
 	go k.webSrv.Start()
 	go k.sshSrv.Start()
+	if k.metricsSrv != nil {
+		go k.metricsSrv.Start()
+	}
+	if k.rpcSrv != nil {
+		go k.rpcSrv.Start()
+	}
+	if k.healthSrv != nil {
+		go k.healthSrv.Start()
+	}
+}
+
+// GracefulStop 停止接受新的连接，等待活跃会话结束后再停止服务
+func (k *Koko) GracefulStop(stop <-chan os.Signal) {
+	if k.healthSrv != nil {
+		// 先让负载均衡摘除本节点
+		k.healthSrv.SetDraining()
+	}
+	grace := time.Duration(config.GetConf().ShutdownGracePeriod) * time.Second
+	if grace > 0 {
+		k.sshSrv.StopAccept()
+		k.webSrv.StopAccept()
+		drainSessions(grace, stop)
+	}
+	k.Stop()
 }
 
 func (k *Koko) Stop() {
+	if k.metricsSrv != nil {
+		k.metricsSrv.Stop()
+	}
+	if k.rpcSrv != nil {
+		k.rpcSrv.Stop()
+	}
 	k.sshSrv.Stop()
 	k.webSrv.Stop()
+	if k.healthSrv != nil {
+		k.healthSrv.Stop()
+	}
 	logger.Info("Quit The KoKo")
 }
 
@@ -39,23 +82,121 @@ func RunForever(confPath string) {
 	bootstrap()
 	gracefulStop := make(chan os.Signal, 1)
 	signal.Notify(gracefulStop, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
+	go reloadOnSignal()
 	jmsService := MustJMService()
+	credential.SetDefault(credential.NewJMSResolver(jmsService))
 	webSrv := httpd.NewServer(jmsService)
 	sshSrv := sshd.NewSSHServer(jmsService)
 	app := &Koko{
 		webSrv: webSrv,
 		sshSrv: sshSrv,
 	}
+	if addr := config.GetConf().MetricsListenAddr; addr != "" {
+		app.metricsSrv = metrics.NewServer(addr, config.GetConf().MetricsPath)
+	}
+	if addr := config.GetConf().HealthListenAddr; addr != "" {
+		app.healthSrv = newHealthServer(addr, jmsService, sshSrv, webSrv)
+	}
+	if addr := config.GetConf().GRPCListenAddr; addr != "" {
+		if token := config.GetConf().GRPCAuthToken; token != "" {
+			app.rpcSrv = rpc.NewServer(addr, token, jmsService)
+		} else {
+			logger.Errorf("GRPC_AUTH_TOKEN is required, gRPC server not started")
+		}
+	}
 	app.Start()
 	runTasks(jmsService)
-	<-gracefulStop
-	app.Stop()
+	sig := <-gracefulStop
+	logger.Infof("Receive signal %s, stop the KoKo", sig)
+	app.GracefulStop(gracefulStop)
 }
 
 func bootstrap() {
 	i18n.Initial()
 	logger.Initial()
 	exchange.Initial()
+	// 重新加载配置时同时重新加载语言包，LANGUAGE_CODE 的修改也一并生效
+	config.RegisterReloadHook(func(config.Config) { i18n.Initial() })
+	if err := handler.ValidateDisabledMenuItems(config.GetConf().DisabledMenuItems); err != nil {
+		logger.Fatal(err)
+	}
+	if err := handler.ValidateKeyBindings(config.GetConf().MenuKeyBindings); err != nil {
+		logger.Fatal(err)
+	}
+	if err := handler.ValidateLanguageSwitchKey(config.GetConf().LanguageSwitchKey); err != nil {
+		logger.Fatal(err)
+	}
+	if err := proxy.ValidateScrollbackKey(config.GetConf().ScrollbackKey); err != nil {
+		logger.Fatal(err)
+	}
+	if err := proxy.ValidateReplayMaskPatterns(config.GetConf().ReplayMaskPatterns); err != nil {
+		logger.Fatal(err)
+	}
+	if err := proxy.LoadCommandFilterFile(config.GetConf()); err != nil {
+		logger.Fatal(err)
+	}
+	if err := credential.ValidateLoginUserTemplate(config.GetConf().LoginUserTemplate); err != nil {
+		logger.Fatal(err)
+	}
+	config.RegisterReloadHook(func(conf config.Config) {
+		if err := handler.ValidateDisabledMenuItems(conf.DisabledMenuItems); err != nil {
+			logger.Error(err)
+		}
+		if err := handler.ValidateKeyBindings(conf.MenuKeyBindings); err != nil {
+			logger.Errorf("%s, custom key bindings are disabled", err)
+		}
+		if err := handler.ValidateLanguageSwitchKey(conf.LanguageSwitchKey); err != nil {
+			logger.Errorf("%s, language switch key is disabled", err)
+		}
+		if err := proxy.ValidateScrollbackKey(conf.ScrollbackKey); err != nil {
+			logger.Errorf("%s, scrollback is disabled", err)
+		}
+		if err := proxy.ValidateReplayMaskPatterns(conf.ReplayMaskPatterns); err != nil {
+			logger.Errorf("%s, output masking is disabled", err)
+		}
+		if err := proxy.LoadCommandFilterFile(conf); err != nil {
+			logger.Errorf("%s, keep the loaded command filter rules", err)
+		}
+		if err := credential.ValidateLoginUserTemplate(conf.LoginUserTemplate); err != nil {
+			logger.Errorf("%s, connections of %s accounts will fail", err, credential.TemplateUser)
+		}
+	})
+	conf := config.GetConf()
+	handler.InitSessionMFALimiter(conf.SessionMFAFailureLimit, time.Duration(conf.SessionMFALockoutTime)*time.Second)
+	if !conf.EnableSessionMFA && !strings.EqualFold(conf.SessionMFAPolicy, handler.SessionMFAPolicyOff) {
+		logger.Warnf("SESSION_MFA_POLICY %s is ignored, set ENABLE_SESSION_MFA to enable it", conf.SessionMFAPolicy)
+	}
+	proxy.InitCommandSyslog(conf)
+}
+
+// 健康检查访问 core 的结果缓存时间
+const healthCoreCheckTTL = 10 * time.Second
+
+func newHealthServer(addr string, jmsService *service.JMService, sshSrv *sshd.Server, webSrv *httpd.Server) *health.Server {
+	conf := config.GetConf()
+	srv := health.NewServer(addr, conf.HealthTLSCertFile, conf.HealthTLSKeyFile)
+	srv.AddCheck("core", health.CachedCheck(func() error {
+		_, err := jmsService.GetTerminalConfig()
+		return err
+	}, healthCoreCheckTTL))
+	srv.AddCheck("ssh", sshSrv.CheckListening)
+	srv.AddCheck("http", webSrv.CheckListening)
+	return srv
+}
+
+// reloadOnSignal 收到 SIGHUP 时重新加载可以热加载的配置，不影响已建立的会话
+func reloadOnSignal() {
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	for range reload {
+		logger.Info("Receive signal SIGHUP, reload config")
+		result, err := config.Reload()
+		if err != nil {
+			logger.Errorf("Reload config failed, keep current config: %s", err)
+			continue
+		}
+		logger.Infof("Reload config success, %s", result)
+	}
 }
 
 func runTasks(jmsService *service.JMService) {
@@ -68,11 +209,20 @@ func runTasks(jmsService *service.JMService) {
 	go keepHeartbeat(jmsService)
 }
 
+// maxCoreAPIRetry 重试次数的上限，避免 core 无法访问时用户长时间等待
+const maxCoreAPIRetry = 5
+
 func MustJMService() *service.JMService {
 	key := MustLoadValidAccessKey()
+	conf := config.GlobalConfig
+	retry := conf.CoreAPIRetry
+	if retry > maxCoreAPIRetry {
+		retry = maxCoreAPIRetry
+	}
 	jmsService, err := service.NewAuthJMService(service.JMSCoreHost(
-		config.GlobalConfig.CoreHost), service.JMSTimeOut(30*time.Second),
+		conf.CoreHost), service.JMSTimeOut(time.Duration(conf.CoreAPITimeout)*time.Second),
 		service.JMSAccessKey(key.ID, key.Secret),
+		service.JMSRetry(retry, time.Duration(conf.CoreAPIRetryBackoff)*time.Millisecond),
 	)
 	if err != nil {
 		logger.Fatal("创建JMS Service 失败 " + err.Error())
@@ -0,0 +1,82 @@
+package koko
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/session"
+)
+
+// 强制关闭会话后，等待会话保存录像和命令记录的时间
+const sessionCloseWaitTime = 10 * time.Second
+
+/*
+drainSessions 通知本节点的活跃会话即将关闭，所有会话结束后提前返回；
+超过 grace 或 stop 再次收到信号(如再次按下 Ctrl+C)时，关闭剩余的会话。
+*/
+
+func drainSessions(grace time.Duration, stop <-chan os.Signal) {
+	events, cancel := session.Subscribe()
+	defer cancel()
+	sessions := session.GetAliveSessionList()
+	if len(sessions) == 0 {
+		return
+	}
+	logger.Infof("Wait %d active sessions to finish in %s", len(sessions), grace)
+	notice := &model.TerminalTask{
+		Name: model.TaskShutdownNotice,
+		Args: strconv.Itoa(int(grace / time.Second)),
+	}
+	for i := range sessions {
+		if err := sessions[i].HandleTask(notice); err != nil {
+			logger.Debugf("Session %s not support shutdown notice: %s", sessions[i].ID, err)
+		}
+	}
+	if waitSessionsDrained(events, time.After(grace), stop) {
+		logger.Info("All sessions finished")
+		return
+	}
+	sessions = session.GetAliveSessionList()
+	logger.Infof("Grace period exceeded, close %d remaining sessions", len(sessions))
+	for i := range sessions {
+		closeSession(sessions[i])
+	}
+	if waitSessionsDrained(events, time.After(sessionCloseWaitTime), stop) {
+		logger.Info("All sessions closed")
+		return
+	}
+	logger.Errorf("%d sessions not closed in time", len(session.GetAliveSessionList()))
+}
+
+// waitSessionsDrained 所有会话都结束时返回 true
+func waitSessionsDrained(events <-chan session.Event, timeout <-chan time.Time, stop <-chan os.Signal) bool {
+	for len(session.GetAliveSessionList()) > 0 {
+		select {
+		case <-events:
+		case <-timeout:
+			return false
+		case sig := <-stop:
+			logger.Infof("Receive signal %s, stop waiting sessions", sig)
+			return false
+		}
+	}
+	return true
+}
+
+func closeSession(sess *session.Session) {
+	task := &model.TerminalTask{Name: model.TaskShutdownSession}
+	if err := sess.HandleTask(task); err == nil {
+		return
+	}
+	// 命令执行等会话只支持终断任务
+	task = &model.TerminalTask{
+		Name:   model.TaskKillSession,
+		Kwargs: model.TaskKwargs{TerminatedBy: "koko"},
+	}
+	if err := sess.HandleTask(task); err != nil {
+		logger.Errorf("Close session %s failed: %s", sess.ID, err)
+	}
+}
@@ -0,0 +1,83 @@
+package koko
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/session"
+)
+
+func TestDrainSessions(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		tasks []string
+	)
+	var finished, stuck *session.Session
+	finished = session.NewSession(&model.Session{ID: "drain-finished"}, func(task *model.TerminalTask) error {
+		mu.Lock()
+		tasks = append(tasks, finished.ID+":"+task.Name)
+		mu.Unlock()
+		if task.Name == model.TaskShutdownNotice {
+			// 用户收到通知后自行退出
+			go session.RemoveSession(finished)
+		}
+		return nil
+	})
+	stuck = session.NewSession(&model.Session{ID: "drain-stuck"}, func(task *model.TerminalTask) error {
+		mu.Lock()
+		tasks = append(tasks, stuck.ID+":"+task.Name)
+		mu.Unlock()
+		if task.Name == model.TaskShutdownSession {
+			go session.RemoveSession(stuck)
+		}
+		return nil
+	})
+	session.AddSession(finished)
+	session.AddSession(stuck)
+
+	start := time.Now()
+	drainSessions(200*time.Millisecond, make(chan os.Signal))
+	if n := len(session.GetAliveSessionList()); n != 0 {
+		t.Fatalf("alive sessions = %d after drain, want 0", n)
+	}
+	if time.Since(start) < 200*time.Millisecond {
+		t.Error("drainSessions() closed sessions before the grace period")
+	}
+	want := map[string]bool{
+		"drain-finished:" + model.TaskShutdownNotice: true,
+		"drain-stuck:" + model.TaskShutdownNotice:    true,
+		"drain-stuck:" + model.TaskShutdownSession:   true,
+	}
+	if len(tasks) != len(want) {
+		t.Fatalf("handled tasks = %q, want %d tasks", tasks, len(want))
+	}
+	for _, task := range tasks {
+		if !want[task] {
+			t.Errorf("unexpected task %s", task)
+		}
+	}
+}
+
+func TestDrainSessionsStopSignal(t *testing.T) {
+	var sess *session.Session
+	sess = session.NewSession(&model.Session{ID: "drain-signal"}, func(task *model.TerminalTask) error {
+		if task.Name == model.TaskShutdownSession {
+			go session.RemoveSession(sess)
+		}
+		return nil
+	})
+	session.AddSession(sess)
+	stop := make(chan os.Signal, 1)
+	stop <- os.Interrupt
+	start := time.Now()
+	drainSessions(time.Minute, stop)
+	if time.Since(start) > 5*time.Second {
+		t.Error("drainSessions() did not stop waiting on the signal")
+	}
+	if n := len(session.GetAliveSessionList()); n != 0 {
+		t.Errorf("alive sessions = %d after drain, want 0", n)
+	}
+}
@@ -28,13 +28,18 @@ func uploadRemainReplay(jmsService *service.JMService) {
 	}
 	replayStorage := proxy.NewReplayStorage(jmsService, &conf)
 	allRemainFiles := make(map[string]RemainReplay)
+	keystrokeFiles := make(map[string]string)
 	_ = filepath.Walk(replayDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil || info.IsDir() {
 			return nil
 		}
+		if sid, ok := proxy.IsKeystrokeAuditFile(info.Name()); ok {
+			keystrokeFiles[path] = sid
+			return nil
+		}
 		if replayInfo, ok := parseReplayFilename(info.Name()); ok {
 			finishedTime := common.NewUTCTime(info.ModTime())
-			if err2 := jmsService.SessionFinished(replayInfo.Id, finishedTime); err2 != nil {
+			if err2 := jmsService.SessionFinished(replayInfo.Id, finishedTime, model.ExitNodeInterrupted); err2 != nil {
 				logger.Error(err2)
 				return nil
 			}
@@ -58,7 +63,7 @@ func uploadRemainReplay(jmsService *service.JMService) {
 				absGzPath = absPath + model.SuffixGz
 			}
 
-			if err = common.CompressToGzipFile(absPath, absGzPath); err != nil {
+			if err = proxy.CompressReplayFile(absPath, absGzPath); err != nil {
 				logger.Error(err)
 				continue
 			}
@@ -78,6 +83,33 @@ func uploadRemainReplay(jmsService *service.JMService) {
 		logger.Infof("Upload remain replay file %s success", absGzPath)
 	}
 	logger.Info("Upload remain replay done")
+	uploadRemainKeystrokeAudit(replayDir, replayStorage, keystrokeFiles)
+}
+
+// uploadRemainKeystrokeAudit 上传遗留的按键审计文件，server 和 null 存储时保留在本地
+func uploadRemainKeystrokeAudit(replayDir string, replayStorage proxy.ReplayStorage, files map[string]string) {
+	if len(files) == 0 || !proxy.CanUploadKeystrokeAudit(replayStorage) {
+		return
+	}
+	for absPath, sid := range files {
+		absGzPath := absPath
+		if !isGzipFile(absPath) {
+			absGzPath = absPath + model.SuffixGz
+			if err := proxy.CompressReplayFile(absPath, absGzPath); err != nil {
+				logger.Error(err)
+				continue
+			}
+			_ = os.Remove(absPath)
+		}
+		target, _ := filepath.Rel(replayDir, absGzPath)
+		logger.Infof("Upload keystroke audit file: %s, type: %s", absGzPath, replayStorage.TypeName())
+		if err := replayStorage.Upload(absGzPath, filepath.ToSlash(target)); err != nil {
+			logger.Errorf("Upload remain keystroke audit file of session %s failed: %s", sid, err)
+			continue
+		}
+		_ = os.Remove(absGzPath)
+	}
+	logger.Info("Upload remain keystroke audit done")
 }
 
 // uploadRemainFTPFile 上传遗留的上传下载文件
@@ -19,12 +19,39 @@ var logLevels = map[string]logrus.Level{
 	"ERROR": logrus.ErrorLevel,
 }
 
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// utcFormatter 日志时间统一使用 UTC，便于和其他组件的日志关联，用户看到的时间使用各自的时区
+type utcFormatter struct {
+	logrus.Formatter
+}
+
+func (f utcFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	entry.Time = entry.Time.UTC()
+	return f.Formatter.Format(entry)
+}
+
 func Initial() {
-	formatter := &Formatter{
+	conf := config.GlobalConfig
+	var formatter logrus.Formatter = &Formatter{
 		LogFormat:       "%time% [%lvl%] %msg%",
 		TimestampFormat: "2006-01-02 15:04:05",
 	}
-	conf := config.GlobalConfig
+	if strings.EqualFold(conf.LogFormat, FormatJSON) {
+		// 每行输出一个 JSON 对象，附带的字段(如 session_id)会一并输出
+		formatter = &logrus.JSONFormatter{
+			TimestampFormat: "2006-01-02 15:04:05",
+			FieldMap: logrus.FieldMap{
+				logrus.FieldKeyTime:  "timestamp",
+				logrus.FieldKeyLevel: "level",
+				logrus.FieldKeyMsg:   "message",
+			},
+		}
+	}
+	formatter = utcFormatter{formatter}
 	level, ok := logLevels[strings.ToUpper(conf.LogLevel)]
 	if !ok {
 		level = logrus.InfoLevel
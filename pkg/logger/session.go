@@ -0,0 +1,57 @@
+package logger
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+type Fields = logrus.Fields
+
+// Entry 附带上下文字段的日志，JSON 格式下字段会作为单独的 key 输出
+type Entry struct {
+	entry *logrus.Entry
+}
+
+func WithFields(fields Fields) *Entry {
+	return &Entry{entry: logger.WithFields(fields)}
+}
+
+// NewSessionLogger 会话级别的日志，自动附带 session_id 和 asset 字段
+func NewSessionLogger(sessionId, asset string) *Entry {
+	return WithFields(Fields{"session_id": sessionId, "asset": asset})
+}
+
+func (e *Entry) WithField(key string, value interface{}) *Entry {
+	return &Entry{entry: e.entry.WithField(key, value)}
+}
+
+func (e *Entry) Debug(args ...interface{}) {
+	e.entry.Debug(args...)
+}
+
+func (e *Entry) Debugf(format string, args ...interface{}) {
+	e.entry.Debugf(format, args...)
+}
+
+func (e *Entry) Info(args ...interface{}) {
+	e.entry.Info(args...)
+}
+
+func (e *Entry) Infof(format string, args ...interface{}) {
+	e.entry.Infof(format, args...)
+}
+
+func (e *Entry) Warn(args ...interface{}) {
+	e.entry.Warn(args...)
+}
+
+func (e *Entry) Warnf(format string, args ...interface{}) {
+	e.entry.Warnf(format, args...)
+}
+
+func (e *Entry) Error(args ...interface{}) {
+	e.entry.Error(args...)
+}
+
+func (e *Entry) Errorf(format string, args ...interface{}) {
+	e.entry.Errorf(format, args...)
+}
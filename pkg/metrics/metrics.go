@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "koko"
+
+var (
+	activeSessions = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_sessions",
+		Help:      "Number of active sessions.",
+	}, []string{"protocol"})
+
+	sessionInputBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "session_input_bytes_total",
+		Help:      "Total bytes sent from users to assets.",
+	}, []string{"protocol"})
+
+	sessionOutputBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "session_output_bytes_total",
+		Help:      "Total bytes sent from assets to users.",
+	}, []string{"protocol"})
+
+	connectLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "asset_connect_duration_seconds",
+		Help:      "Time taken to establish connections to assets.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"protocol"})
+
+	authFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_failures_total",
+		Help:      "Total user authentication failures.",
+	}, []string{"protocol"})
+
+	registry = prometheus.NewRegistry()
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		activeSessions,
+		sessionInputBytes,
+		sessionOutputBytes,
+		connectLatency,
+		authFailures,
+	)
+}
+
+func SessionStarted(protocol string) {
+	activeSessions.WithLabelValues(protocol).Inc()
+}
+
+func SessionEnded(protocol string) {
+	activeSessions.WithLabelValues(protocol).Dec()
+}
+
+func AddInputBytes(protocol string, n int) {
+	sessionInputBytes.WithLabelValues(protocol).Add(float64(n))
+}
+
+func AddOutputBytes(protocol string, n int) {
+	sessionOutputBytes.WithLabelValues(protocol).Add(float64(n))
+}
+
+func ObserveConnectLatency(protocol string, d time.Duration) {
+	connectLatency.WithLabelValues(protocol).Observe(d.Seconds())
+}
+
+func AuthFailed(protocol string) {
+	authFailures.WithLabelValues(protocol).Inc()
+}
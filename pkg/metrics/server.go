@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+type Server struct {
+	Srv *http.Server
+}
+
+// NewServer metrics 单独监听地址，避免与 web 终端的端口一起暴露
+func NewServer(addr, path string) *Server {
+	if path == "" {
+		path = "/metrics"
+	}
+	mux := http.NewServeMux()
+	mux.Handle(path, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	return &Server{Srv: &http.Server{Addr: addr, Handler: mux}}
+}
+
+func (s *Server) Start() {
+	logger.Infof("Start metrics server at %s", s.Srv.Addr)
+	if err := s.Srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Errorf("Metrics server exit: %s", err)
+	}
+}
+
+func (s *Server) Stop() {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFunc()
+	_ = s.Srv.Shutdown(ctx)
+}
@@ -0,0 +1,220 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/session"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+/*
+资产标签 max_sessions 限制资产在当前节点上同时进行的会话数。ASSET_SESSION_QUEUE 开启时，达到上限的用户排队等待，
+会话结束时通知等待的用户按排队顺序连接；等待超过 ASSET_SESSION_QUEUE_WAIT 秒或者用户按 Ctrl-C 时取消等待返回菜单。
+排在前面的用户占用名额，新的连接不会越过排队的用户。
+*/
+
+const defaultAssetQueueWait = 300
+
+var (
+	errAssetQueueTimeout  = errors.New("wait asset session queue timeout")
+	errAssetQueueCanceled = errors.New("wait asset session queue canceled")
+)
+
+// AssetSessionLimitError 资产的会话数量达到了限制
+type AssetSessionLimitError struct {
+	Asset string
+	Limit int
+}
+
+func (e *AssetSessionLimitError) Error() string {
+	return fmt.Sprintf("asset %s reach the session limit %d", e.Asset, e.Limit)
+}
+
+// assetSessionLimit 创建会话时检查的资产限制，waiter 为排队中的用户
+type assetSessionLimit struct {
+	limit  int
+	waiter *assetWaiter
+}
+
+// checkAssetSessionLimit 资产的活跃会话加上排在前面的用户达到限制时返回 AssetSessionLimitError
+func checkAssetSessionLimit(limit assetSessionLimit, sess *session.Session, alive []*session.Session) error {
+	if limit.limit <= 0 {
+		return nil
+	}
+	count := assetQueue.ahead(sess.AssetID, limit.waiter)
+	for _, item := range alive {
+		if item.AssetID == sess.AssetID {
+			count++
+		}
+	}
+	if count >= limit.limit {
+		return &AssetSessionLimitError{Asset: sess.Asset, Limit: limit.limit}
+	}
+	return nil
+}
+
+type assetWaiter struct {
+	// 有会话结束或者排队的用户离开时通知，缓冲为 1 不会阻塞通知方
+	notify chan struct{}
+}
+
+type assetSessionQueue struct {
+	sync.Mutex
+	waiters map[string][]*assetWaiter
+
+	once sync.Once
+}
+
+var assetQueue = &assetSessionQueue{waiters: make(map[string][]*assetWaiter)}
+
+// join 加入资产的队列，第一次使用时订阅会话结束的事件
+func (q *assetSessionQueue) join(assetID string) *assetWaiter {
+	q.once.Do(func() { go q.watchSessionEnded() })
+	w := &assetWaiter{notify: make(chan struct{}, 1)}
+	q.Lock()
+	defer q.Unlock()
+	q.waiters[assetID] = append(q.waiters[assetID], w)
+	return w
+}
+
+func (q *assetSessionQueue) leave(assetID string, w *assetWaiter) {
+	q.Lock()
+	defer q.Unlock()
+	waiters := q.waiters[assetID]
+	for i := range waiters {
+		if waiters[i] == w {
+			waiters = append(waiters[:i:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) == 0 {
+		delete(q.waiters, assetID)
+		return
+	}
+	q.waiters[assetID] = waiters
+	// 后面的用户位置变化，可能可以连接
+	q.notifyLocked(assetID)
+}
+
+// position 返回 w 在队列中的位置，从 1 开始
+func (q *assetSessionQueue) position(assetID string, w *assetWaiter) int {
+	return q.ahead(assetID, w) + 1
+}
+
+// ahead 返回排在 w 前面的用户数量，w 为 nil 时返回队列的长度
+func (q *assetSessionQueue) ahead(assetID string, w *assetWaiter) int {
+	q.Lock()
+	defer q.Unlock()
+	waiters := q.waiters[assetID]
+	for i := range waiters {
+		if waiters[i] == w {
+			return i
+		}
+	}
+	return len(waiters)
+}
+
+func (q *assetSessionQueue) notify(assetID string) {
+	q.Lock()
+	defer q.Unlock()
+	q.notifyLocked(assetID)
+}
+
+func (q *assetSessionQueue) notifyLocked(assetID string) {
+	for _, w := range q.waiters[assetID] {
+		select {
+		case w.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// watchSessionEnded 会话从活跃会话列表移除时通知该资产排队的用户
+func (q *assetSessionQueue) watchSessionEnded() {
+	events, _ := session.Subscribe()
+	for event := range events {
+		if event.Type == session.EventEnded && event.Session.AssetID != "" {
+			q.notify(event.Session.AssetID)
+		}
+	}
+}
+
+func assetQueueWait() time.Duration {
+	seconds := config.GetConf().AssetSessionQueueWait
+	if seconds <= 0 {
+		seconds = defaultAssetQueueWait
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+/*
+waitAssetSessionSlot 排队等待资产的会话名额，成功时会话已经加入活跃会话列表。
+通知可能因为事件订阅的缓冲区已满而丢失，所以每秒也会重新检查一次。
+*/
+
+func (s *Server) waitAssetSessionSlot(traceSession *session.Session, limit int) error {
+	lang := s.connOpts.getLang()
+	asset := &s.connOpts.authInfo.Asset
+	assetID := traceSession.AssetID
+	waiter := assetQueue.join(assetID)
+	defer assetQueue.leave(assetID, waiter)
+	logger.Infof("Conn[%s] user %s wait in the session queue of asset %s",
+		s.UserConn.ID(), s.connOpts.authInfo.User.String(), asset.String())
+
+	// 等待期间读取用户的输入，只处理 Ctrl-C，结束时关闭读取避免占用会话的输入
+	canceled := make(chan struct{})
+	go func() {
+		defer close(canceled)
+		buf := make([]byte, 1024)
+		for {
+			n, err := s.UserConn.Read(buf)
+			if err != nil || bytes.IndexByte(buf[:n], CharCTRLC) >= 0 {
+				return
+			}
+		}
+	}()
+	defer func() { _ = s.UserConn.Close() }()
+
+	start := time.Now()
+	timer := time.NewTimer(assetQueueWait())
+	defer timer.Stop()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	lastPosition := 0
+	for {
+		err := addLimitedSession(traceSession, assetSessionLimit{limit: limit, waiter: waiter})
+		var assetErr *AssetSessionLimitError
+		if !errors.As(err, &assetErr) {
+			utils.IgnoreErrWriteString(s.UserConn, utils.CharNewLine)
+			if err == nil {
+				logger.Infof("Conn[%s] asset %s session slot is available after %s",
+					s.UserConn.ID(), asset.String(), time.Since(start).Round(time.Second))
+			}
+			return err
+		}
+		if position := assetQueue.position(assetID, waiter); position != lastPosition {
+			lastPosition = position
+			msg := fmt.Sprintf(lang.T("Asset %s is busy, you are number %d in the queue, press Ctrl-C to cancel"),
+				asset.Name, position)
+			utils.IgnoreErrWriteString(s.UserConn, "\r\x1b[K"+utils.WrapperString(msg, utils.Yellow))
+		}
+		select {
+		case <-waiter.notify:
+		case <-ticker.C:
+		case <-timer.C:
+			msg := lang.T("Waiting for the asset timed out, please try again later")
+			utils.IgnoreErrWriteString(s.UserConn, utils.CharNewLine+utils.WrapperWarn(msg))
+			return errAssetQueueTimeout
+		case <-canceled:
+			msg := lang.T("Waiting for the asset canceled")
+			utils.IgnoreErrWriteString(s.UserConn, utils.CharNewLine+utils.WrapperWarn(msg))
+			return errAssetQueueCanceled
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/session"
+)
+
+func TestWaitAssetSessionSlot(t *testing.T) {
+	conf := config.GetConf()
+	conf.AssetSessionQueueWait = 5
+	setTestConfig(t, &conf)
+
+	newSession := func(id string) *session.Session {
+		return session.NewSession(&model.Session{ID: id, UserID: "user-" + id, Protocol: "ssh",
+			AssetID: "queue-asset", Asset: "web-01"}, nil)
+	}
+	newServer := func() (*Server, *pipeUserConn) {
+		conn := newPipeUserConn("conn-queue")
+		authInfo := &model.ConnectToken{Asset: model.Asset{ID: "queue-asset", Name: "web-01"}}
+		return &Server{UserConn: conn, connOpts: &ConnectionOptions{authInfo: authInfo, i18nLang: "en"}}, conn
+	}
+	limit := assetSessionLimit{limit: 1}
+	running := newSession("queue-1")
+	if err := addLimitedSession(running, limit); err != nil {
+		t.Fatalf("addLimitedSession() = %s, want nil", err)
+	}
+	defer session.RemoveSession(running)
+	var assetErr *AssetSessionLimitError
+	if err := addLimitedSession(newSession("queue-2"), limit); !errors.As(err, &assetErr) {
+		t.Fatalf("addLimitedSession() over asset limit = %v, want asset limit", err)
+	}
+
+	s, conn := newServer()
+	waiting := newSession("queue-3")
+	result := make(chan error, 1)
+	go func() { result <- s.waitAssetSessionSlot(waiting, 1) }()
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(conn.output(), "number 1 in the queue") {
+		if time.Now().After(deadline) {
+			t.Fatalf("queue position not displayed, output = %q", conn.output())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// 排队的用户占用名额，新的连接不能越过队列
+	if err := addLimitedSession(newSession("queue-4"), limit); !errors.As(err, &assetErr) {
+		t.Fatalf("addLimitedSession() with waiter = %v, want asset limit", err)
+	}
+	session.RemoveSession(running)
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("waitAssetSessionSlot() = %s, want nil", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("waitAssetSessionSlot() not connected after the session ended")
+	}
+	defer session.RemoveSession(waiting)
+	if _, ok := session.GetSessionById(waiting.ID); !ok {
+		t.Fatal("waiting session not added to alive sessions")
+	}
+
+	s, conn = newServer()
+	go func() { _, _ = conn.writer.Write([]byte{CharCTRLC}) }()
+	if err := s.waitAssetSessionSlot(newSession("queue-5"), 1); !errors.Is(err, errAssetQueueCanceled) {
+		t.Fatalf("waitAssetSessionSlot() after Ctrl-C = %v, want canceled", err)
+	}
+	if n := assetQueue.ahead("queue-asset", nil); n != 0 {
+		t.Errorf("queue length after cancel = %d, want 0", n)
+	}
+}
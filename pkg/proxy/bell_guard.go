@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+/*
+BELL_POLICY 资产输出中响铃字符(BEL)的处理策略：pass 原样输出，count 原样输出并统计数量，suppress 去除后统计数量。
+OSC 序列(如修改终端标题)以 BEL 结束，序列中的 BEL 不是响铃，不统计也不去除。
+BELL_AUDIT_THRESHOLD 大于 0 时，一分钟内的响铃超过阈值记录一次会话生命周期日志，提示资产上可能有异常的进程。
+用户可以在菜单中使用 bell 命令设置自己会话的策略，覆盖全局的配置。
+*/
+
+const (
+	BellPolicyPass     = "pass"
+	BellPolicyCount    = "count"
+	BellPolicySuppress = "suppress"
+)
+
+const bellAuditWindow = time.Minute
+
+type bellGuard struct {
+	policy    string
+	threshold int
+
+	// OSC 序列的状态，序列可能分多次输出
+	afterESC bool
+	inOSC    bool
+
+	total       int
+	windowStart time.Time
+	windowCount int
+	// 每个会话只记录一次超过阈值的日志
+	audited bool
+}
+
+// NormalizeBellPolicy 返回有效的策略，未知的策略按 pass 处理
+func NormalizeBellPolicy(policy string) string {
+	switch policy = strings.ToLower(strings.TrimSpace(policy)); policy {
+	case BellPolicyCount, BellPolicySuppress:
+		return policy
+	}
+	return BellPolicyPass
+}
+
+func (g *bellGuard) enabled() bool {
+	return g.policy == BellPolicyCount || g.policy == BellPolicySuppress || g.threshold > 0
+}
+
+// filter 统计输出中的响铃，suppress 时返回去除响铃后的数据
+func (g *bellGuard) filter(b []byte) ([]byte, int) {
+	if !g.afterESC && !g.inOSC && bytes.IndexByte(b, charBEL) < 0 && bytes.IndexByte(b, charESC) < 0 {
+		return b, 0
+	}
+	suppress := g.policy == BellPolicySuppress
+	var out []byte
+	if suppress {
+		out = make([]byte, 0, len(b))
+	}
+	bells := 0
+	for _, c := range b {
+		switch {
+		case c == charESC:
+			// OSC 中的 ESC 结束序列(ESC \ 或者其他控制序列)
+			g.inOSC = false
+			g.afterESC = true
+		case g.afterESC:
+			g.afterESC = false
+			g.inOSC = c == ']'
+		case c == charBEL:
+			if g.inOSC {
+				g.inOSC = false
+				break
+			}
+			bells++
+			if suppress {
+				continue
+			}
+		}
+		if suppress {
+			out = append(out, c)
+		}
+	}
+	if !suppress {
+		return b, bells
+	}
+	return out, bells
+}
+
+// exceeded 累计响铃的数量，一分钟内超过阈值时返回 true，每个会话只返回一次
+func (g *bellGuard) exceeded(bells int, now time.Time) bool {
+	g.total += bells
+	if g.threshold <= 0 || g.audited {
+		return false
+	}
+	if now.Sub(g.windowStart) >= bellAuditWindow {
+		g.windowStart = now
+		g.windowCount = 0
+	}
+	g.windowCount += bells
+	if g.windowCount > g.threshold {
+		g.audited = true
+		return true
+	}
+	return false
+}
+
+// parseBell 按照 BELL_POLICY 统计或者去除资产输出中的响铃
+func (p *Parser) parseBell(b []byte) []byte {
+	guard := &p.bell
+	if !guard.enabled() || p.zmodemParser.IsStartSession() {
+		// 文件传输的数据不处理
+		return b
+	}
+	out, bells := guard.filter(b)
+	if bells > 0 && guard.exceeded(bells, time.Now()) {
+		p.recordBellRateExceeded(guard.windowCount)
+	}
+	return out
+}
+
+func (p *Parser) recordBellRateExceeded(count int) {
+	logger.Warnf("Session %s: asset output %d bell characters in %s, exceed the threshold %d",
+		p.id, count, bellAuditWindow, p.bell.threshold)
+	logObj := model.SessionLifecycleLog{
+		Reason: fmt.Sprintf("asset output %d bell characters in %s, exceed the threshold %d",
+			count, bellAuditWindow, p.bell.threshold),
+		User: p.currentActiveUser.User,
+	}
+	go func() {
+		if err := p.jmsService.RecordSessionLifecycleLog(p.id, model.BellRateExceeded, logObj); err != nil {
+			logger.Errorf("Session %s: record bell rate exceeded log failed: %s", p.id, err)
+		}
+	}()
+}
+
+// logBellCount 会话结束时记录统计的响铃数量
+func (p *Parser) logBellCount() {
+	if p.bell.total > 0 {
+		logger.Infof("Session %s: asset output %d bell characters, policy %s", p.id, p.bell.total, p.bell.policy)
+	}
+}
+
+// bellPolicy 用户设置的策略优先，未设置时使用 BELL_POLICY
+func (s *Server) bellPolicy() string {
+	if s.connOpts.bellPolicy != "" {
+		return NormalizeBellPolicy(s.connOpts.bellPolicy)
+	}
+	return NormalizeBellPolicy(config.GetConf().BellPolicy)
+}
@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBellGuardFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     string
+		chunks     []string
+		wantOutput string
+		wantBells  int
+	}{
+		{"count keeps bell", BellPolicyCount, []string{"a\x07b\x07"}, "a\x07b\x07", 2},
+		{"suppress", BellPolicySuppress, []string{"a\x07\x07b"}, "ab", 2},
+		{"osc terminator", BellPolicySuppress, []string{"\x1b]0;title\x07$ \x07"}, "\x1b]0;title\x07$ ", 1},
+		{"split osc", BellPolicySuppress, []string{"\x1b", "]2;ro", "ot\x07", "\x07"}, "\x1b]2;root\x07", 1},
+		{"st ends osc", BellPolicySuppress, []string{"\x1b]0;t\x1b\\\x07"}, "\x1b]0;t\x1b\\", 1},
+		{"csi is not osc", BellPolicySuppress, []string{"\x1b[1m\x07x"}, "\x1b[1mx", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard := bellGuard{policy: tt.policy}
+			var (
+				output string
+				bells  int
+			)
+			for _, chunk := range tt.chunks {
+				out, n := guard.filter([]byte(chunk))
+				output += string(out)
+				bells += n
+			}
+			if output != tt.wantOutput || bells != tt.wantBells {
+				t.Errorf("filter() = %q, %d bells, want %q, %d", output, bells, tt.wantOutput, tt.wantBells)
+			}
+		})
+	}
+}
+
+func TestBellGuardExceeded(t *testing.T) {
+	guard := bellGuard{policy: BellPolicyCount, threshold: 10}
+	now := time.Now()
+	if guard.exceeded(8, now) {
+		t.Error("8 bells exceed the threshold 10")
+	}
+	// 超过一分钟后重新计数
+	if guard.exceeded(8, now.Add(bellAuditWindow)) {
+		t.Error("bells of the last window are counted")
+	}
+	if !guard.exceeded(3, now.Add(bellAuditWindow+time.Second)) {
+		t.Error("11 bells in a minute not exceed the threshold 10")
+	}
+	if guard.exceeded(100, now.Add(bellAuditWindow+2*time.Second)) {
+		t.Error("exceeded is reported more than once")
+	}
+	if guard.total != 119 {
+		t.Errorf("total = %d, want 119", guard.total)
+	}
+	if NormalizeBellPolicy(" Suppress ") != BellPolicySuppress || NormalizeBellPolicy("beep") != BellPolicyPass {
+		t.Error("NormalizeBellPolicy() returns invalid policy")
+	}
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/srvconn"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+/*
+CLIENT_AGENT_ASSET_RULES 命中规则(格式同 REPLAY_FORCE_ASSET_RULES)的 SSH 资产使用用户转发的 SSH agent(ssh -A)认证，
+agent 中的密钥优先于账号保存的密钥和密码。每次连接资产时单独打开一个到客户端 agent 的通道，认证结束后立即关闭，
+资产和其他会话都不能使用用户的 agent，使用 agent 认证的 SSH 连接也不会被其他会话复用。
+*/
+
+// clientAgentRequired 资产命中了使用客户端 agent 认证的规则
+func (s *Server) clientAgentRequired() bool {
+	if s.connOpts.authInfo.Protocol != srvconn.ProtocolSSH {
+		return false
+	}
+	_, ok := matchAssetRules(&s.connOpts.authInfo.Asset, config.GetConf().ClientAgentAssetRules)
+	return ok
+}
+
+// clientAgentForwarded 资产需要使用客户端 agent 认证，并且用户转发了 agent
+func (s *Server) clientAgentForwarded() bool {
+	if !s.clientAgentRequired() {
+		return false
+	}
+	conn, ok := s.UserConn.(agentConnection)
+	return ok && conn.AgentForwarded()
+}
+
+// openClientAgent 返回 nil 表示不使用 agent 认证，用户没有转发 agent 时提示后使用账号的认证信息
+func (s *Server) openClientAgent() (*srvconn.ClientAgentAuth, io.Closer) {
+	if !s.clientAgentRequired() {
+		return nil, nil
+	}
+	lang := s.connOpts.getLang()
+	asset := s.connOpts.authInfo.Asset
+	var (
+		channel io.ReadWriteCloser
+		err     = srvconn.ErrAgentNotForwarded
+	)
+	if conn, ok := s.UserConn.(agentConnection); ok {
+		channel, err = conn.OpenAgentChannel()
+	}
+	if err != nil {
+		logger.Errorf("Conn[%s] open client ssh agent for asset %s failed: %s", s.UserConn.ID(), asset.String(), err)
+		msg := fmt.Sprintf(lang.T("Asset %s requires SSH agent forwarding, please reconnect with ssh -A"), asset.Name)
+		utils.IgnoreErrWriteString(s.UserConn, utils.WrapperWarn(msg))
+		return nil, nil
+	}
+	logger.Infof("Conn[%s] authenticate asset %s with client ssh agent", s.UserConn.ID(), asset.String())
+	return srvconn.NewClientAgentAuth(channel), channel
+}
+
+// clientAgentCacheKey 使用 agent 认证的连接只属于当前会话
+func (s *Server) clientAgentCacheKey(key string) string {
+	return key + "_agent_" + s.ID
+}
+
+// recordClientAgentAuth 记录资产接受的 agent 密钥，便于审计
+func (s *Server) recordClientAgentAuth(key gossh.PublicKey) {
+	logger.Infof("Session[%s] asset %s accept client agent key %s", s.ID,
+		s.connOpts.authInfo.Asset.String(), gossh.FingerprintSHA256(key))
+	logObj := model.SessionLifecycleLog{
+		Reason: fmt.Sprintf("authenticated with client forwarded ssh agent key %s %s",
+			key.Type(), gossh.FingerprintSHA256(key)),
+		User: s.connOpts.authInfo.User.String(),
+	}
+	go func() {
+		if err := s.jmsService.RecordSessionLifecycleLog(s.ID, model.ClientAgentAuth, logObj); err != nil {
+			logger.Errorf("Session[%s] record client agent auth log failed: %s", s.ID, err)
+		}
+	}()
+}
@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dlclark/regexp2"
+	"sigs.k8s.io/yaml"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+/*
+COMMAND_FILTER_FILE 本地的命令过滤规则文件(YAML 或 JSON)，用于无法依赖 core 下发规则的离线部署。
+COMMAND_FILTER_FILE_MODE 为 merge 时与 core 的规则合并，为 override 时只使用本地规则。
+
+合并后的优先级: 按规则的优先级排序(值越小优先级越高)，优先级相同时按动作排序(reject > warning > accept)，
+动作也相同时本地规则优先。复核和确认需要在 core 中创建工单，本地规则只支持 reject、warning、accept。
+规则在加载时检查并编译正则，SIGHUP 重新加载失败时保留之前的规则。
+*/
+
+const (
+	CommandFilterModeMerge    = "merge"
+	CommandFilterModeOverride = "override"
+)
+
+// 与 core 中命令过滤规则的默认优先级一致
+const defaultLocalFilterPriority = 50
+
+type localCommandFilterRule struct {
+	Name       string   `json:"name"`
+	Action     string   `json:"action"`
+	Priority   *int     `json:"priority"`
+	IgnoreCase bool     `json:"ignore_case"`
+	Patterns   []string `json:"patterns"`
+}
+
+type localCommandFilterFile struct {
+	Rules []localCommandFilterRule `json:"rules"`
+}
+
+var (
+	localFilterLock sync.RWMutex
+	localFilterACLs []model.CommandACL
+)
+
+// parseCommandFilterFile 解析规则文件，规则的正则和动作错误时返回错误
+func parseCommandFilterFile(data []byte) ([]model.CommandACL, error) {
+	var file localCommandFilterFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid command filter file: %w", err)
+	}
+	acls := make([]model.CommandACL, 0, len(file.Rules))
+	for i, rule := range file.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("local-rule-%d", i+1)
+		}
+		action := strings.ToLower(strings.TrimSpace(rule.Action))
+		switch action {
+		case model.ActionReject, model.ActionWarning, model.ActionAccept:
+		default:
+			return nil, fmt.Errorf("command filter rule %s: invalid action %q", name, rule.Action)
+		}
+		if len(rule.Patterns) == 0 {
+			return nil, fmt.Errorf("command filter rule %s: no patterns", name)
+		}
+		opt := regexp2.None
+		if rule.IgnoreCase {
+			opt = regexp2.IgnoreCase
+		}
+		items := make([]model.CommandFilterItem, 0, len(rule.Patterns))
+		for _, pattern := range rule.Patterns {
+			if pattern == "" {
+				return nil, fmt.Errorf("command filter rule %s: empty pattern", name)
+			}
+			if _, err := regexp2.Compile(pattern, opt); err != nil {
+				return nil, fmt.Errorf("command filter rule %s: invalid pattern %q: %w", name, pattern, err)
+			}
+			items = append(items, model.CommandFilterItem{
+				Name: name, Type: "regex", Content: pattern, RePattern: pattern, IgnoreCase: rule.IgnoreCase,
+			})
+		}
+		priority := defaultLocalFilterPriority
+		if rule.Priority != nil {
+			priority = *rule.Priority
+		}
+		// 本地规则在 core 中不存在，ID 为空，命令记录不关联 core 的规则
+		acls = append(acls, model.CommandACL{
+			Action:        model.CommandAction(action),
+			CommandGroups: items,
+			IsActive:      true,
+			Name:          name,
+			Priority:      priority,
+		})
+	}
+	return acls, nil
+}
+
+func readCommandFilterFile(path string) ([]model.CommandACL, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read command filter file failed: %w", err)
+	}
+	acls, err := parseCommandFilterFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return acls, nil
+}
+
+/*
+LoadCommandFilterFile 加载 COMMAND_FILTER_FILE 中的规则，启动时和重新加载配置时调用。
+文件错误时返回错误，不修改已加载的规则；未配置文件时清除本地规则。
+*/
+
+func LoadCommandFilterFile(conf config.Config) error {
+	switch conf.CommandFilterFileMode {
+	case CommandFilterModeMerge, CommandFilterModeOverride:
+	default:
+		return fmt.Errorf("invalid COMMAND_FILTER_FILE_MODE %q, must be merge or override",
+			conf.CommandFilterFileMode)
+	}
+	var acls []model.CommandACL
+	if conf.CommandFilterFile != "" {
+		var err error
+		if acls, err = readCommandFilterFile(conf.CommandFilterFile); err != nil {
+			return err
+		}
+		logger.Infof("Load %d command filter rules from %s, mode %s",
+			len(acls), conf.CommandFilterFile, conf.CommandFilterFileMode)
+	}
+	localFilterLock.Lock()
+	localFilterACLs = acls
+	localFilterLock.Unlock()
+	return nil
+}
+
+// loadedLocalFilterACLs 未配置文件时返回 nil，文件中没有规则时返回空的规则
+func loadedLocalFilterACLs() []model.CommandACL {
+	localFilterLock.RLock()
+	defer localFilterLock.RUnlock()
+	return localFilterACLs
+}
+
+// CommandFilterACLs 返回按优先级排序的会话过滤规则，不修改 core 下发的规则
+func CommandFilterACLs(remote []model.CommandACL) []model.CommandACL {
+	local := loadedLocalFilterACLs()
+	acls := make([]model.CommandACL, 0, len(local)+len(remote))
+	acls = append(acls, local...)
+	if local == nil || config.GetConf().CommandFilterFileMode != CommandFilterModeOverride {
+		acls = append(acls, remote...)
+	}
+	// 本地规则在前，稳定排序保证优先级和动作相同时本地规则优先
+	sort.Stable(model.CommandACLs(acls))
+	return acls
+}
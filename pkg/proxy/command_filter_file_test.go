@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestLoadCommandFilterFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "filters.yml")
+	content := `rules:
+  - name: deny-rm
+    action: reject
+    priority: 50
+    patterns: ['\brm\s+-rf\b']
+  - name: allow-ls
+    action: accept
+    priority: 1
+    patterns: ['^ls\b']
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	conf := config.GetConf()
+	conf.CommandFilterFile = path
+	conf.CommandFilterFileMode = CommandFilterModeMerge
+	setTestConfig(t, &conf)
+	defer func() { localFilterACLs = nil }()
+	if err := LoadCommandFilterFile(conf); err != nil {
+		t.Fatal(err)
+	}
+
+	remote := []model.CommandACL{
+		{ID: "remote-rm", Name: "remote-rm", Action: model.ActionReject, Priority: 50,
+			CommandGroups: []model.CommandFilterItem{{RePattern: `rm`}}},
+		{ID: "remote-warn", Name: "remote-warn", Action: model.ActionWarning, Priority: 10,
+			CommandGroups: []model.CommandFilterItem{{RePattern: `reboot`}}},
+	}
+	acls := CommandFilterACLs(remote)
+	var names []string
+	for i := range acls {
+		names = append(names, acls[i].Name)
+	}
+	want := []string{"allow-ls", "remote-warn", "deny-rm", "remote-rm"}
+	if len(names) != len(want) {
+		t.Fatalf("CommandFilterACLs() = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("CommandFilterACLs() = %v, want %v", names, want)
+		}
+	}
+	if _, action, _ := acls[2].Match("rm -rf /tmp"); action != model.ActionReject {
+		t.Errorf("local rule match rm = %s, want reject", action)
+	}
+	if remote[0].Name != "remote-rm" {
+		t.Error("CommandFilterACLs() modified the remote rules")
+	}
+
+	conf.CommandFilterFileMode = CommandFilterModeOverride
+	config.GlobalConfig = &conf
+	if acls = CommandFilterACLs(remote); len(acls) != 2 || acls[0].Name != "allow-ls" {
+		t.Errorf("override CommandFilterACLs() = %+v, want local rules only", acls)
+	}
+
+	// 规则错误时返回错误，保留已加载的规则
+	for _, bad := range []string{
+		"rules:\n  - name: bad\n    action: reject\n    patterns: ['(rm']\n",
+		"rules:\n  - name: review\n    action: review\n    patterns: ['rm']\n",
+		"rules:\n  - name: empty\n    action: reject\n",
+	} {
+		if err := os.WriteFile(path, []byte(bad), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := LoadCommandFilterFile(conf); err == nil {
+			t.Errorf("LoadCommandFilterFile(%q) want error", bad)
+		}
+	}
+	if len(loadedLocalFilterACLs()) != 2 {
+		t.Error("invalid file replaced the loaded rules")
+	}
+
+	if err := os.WriteFile(path, []byte(`{"rules": [{"name": "json", "action": "warning", "patterns": ["sudo"]}]}`),
+		0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadCommandFilterFile(conf); err != nil {
+		t.Fatal(err)
+	}
+	if acls = loadedLocalFilterACLs(); len(acls) != 1 || acls[0].Priority != defaultLocalFilterPriority {
+		t.Errorf("json rules = %+v, want one rule with default priority", acls)
+	}
+}
@@ -0,0 +1,41 @@
+package proxy
+
+import (
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+/*
+命令被拦截时用户容易误以为是资产的问题，COMMAND_FILTER_NOTICE 开启时，
+会话有会拦截或者告警的过滤规则，连接成功后提示一次本次会话开启了命令过滤。
+预览模式下命令不会被拦截，不提示。
+*/
+
+// commandFilterActive 规则中有非接受动作时认为开启了命令过滤
+func commandFilterActive(acls model.CommandACLs) bool {
+	for i := range acls {
+		if acls[i].Action != model.ActionAccept {
+			return true
+		}
+	}
+	return false
+}
+
+func commandFilterNotice(lang i18n.LanguageCode) string {
+	msg := lang.T("Command filtering is enabled for this session, commands may be blocked by policy")
+	return utils.WrapperString("[*] "+msg, utils.Yellow) + utils.CharNewLine
+}
+
+// displayCommandFilterNotice 会话开启了命令过滤时提示用户
+func (s *Server) displayCommandFilterNotice() {
+	conf := config.GetConf()
+	if !conf.CommandFilterNotice || conf.CommandFilterPreview {
+		return
+	}
+	if !commandFilterActive(CommandFilterACLs(s.connOpts.authInfo.CommandFilterACLs)) {
+		return
+	}
+	utils.IgnoreErrWriteString(s.UserConn, utils.CharNewLine+commandFilterNotice(s.connOpts.getLang()))
+}
@@ -0,0 +1,31 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestCommandFilterActive(t *testing.T) {
+	accept := model.CommandACL{Name: "allow-ls", Action: model.ActionAccept}
+	reject := model.CommandACL{Name: "deny-rm", Action: model.ActionReject, Priority: 10}
+	if commandFilterActive(nil) || commandFilterActive(model.CommandACLs{accept}) {
+		t.Error("commandFilterActive() = true for accept only rules")
+	}
+	if !commandFilterActive(model.CommandACLs{accept, reject}) {
+		t.Error("commandFilterActive() = false with reject rule")
+	}
+}
+
+func TestForbiddenCommandMsg(t *testing.T) {
+	p := &Parser{}
+	rule := CommandRule{Acl: &model.CommandACL{Name: "deny-rm", Action: model.ActionReject}}
+	msg := p.forbiddenCommandMsg("rm -rf /", rule)
+	if !strings.Contains(msg, "rm -rf /") || !strings.Contains(msg, "deny-rm") {
+		t.Errorf("forbiddenCommandMsg() = %q, want command and rule name", msg)
+	}
+	if msg = p.forbiddenCommandMsg("rm -rf /", CommandRule{}); strings.Contains(msg, "policy rule") {
+		t.Errorf("forbiddenCommandMsg() without rule = %q", msg)
+	}
+}
@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+const (
+	// 摘要中显示最近的命令数量
+	commandSummarySize = 10
+
+	// 摘要中每条命令最多显示的字符数
+	maxSummaryCommandLen = 100
+
+	// 会话结束后等待最后的命令结算的时间
+	commandSummaryWait = 2 * time.Second
+)
+
+type summaryCommand struct {
+	input     string
+	riskLevel int64
+	created   time.Time
+}
+
+// commandSummary 用户开启了命令摘要时，收集会话中执行的命令，会话结束后返回菜单前显示
+type commandSummary struct {
+	loc *time.Location
+
+	mu     sync.Mutex
+	count  int
+	recent []summaryCommand
+
+	done chan struct{}
+	once sync.Once
+}
+
+func newCommandSummary(loc *time.Location) *commandSummary {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return &commandSummary{loc: loc, done: make(chan struct{})}
+}
+
+func (c *commandSummary) add(cmd *model.Command) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count++
+	c.recent = append(c.recent, summaryCommand{input: cmd.Input, riskLevel: cmd.RiskLevel, created: cmd.DateCreated})
+	if len(c.recent) > commandSummarySize {
+		c.recent = c.recent[len(c.recent)-commandSummarySize:]
+	}
+}
+
+// finish 会话的命令已经全部记录
+func (c *commandSummary) finish() {
+	c.once.Do(func() { close(c.done) })
+}
+
+func (c *commandSummary) render(lang i18n.LanguageCode) string {
+	select {
+	case <-c.done:
+	case <-time.After(commandSummaryWait):
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var title string
+	switch {
+	case c.count == 0:
+		title = lang.T("Session command summary: no commands")
+	case c.count > len(c.recent):
+		title = fmt.Sprintf(lang.T("Session command summary: %d commands, the last %d are:"), c.count, len(c.recent))
+	default:
+		title = fmt.Sprintf(lang.T("Session command summary: %d commands"), c.count)
+	}
+	var buf strings.Builder
+	buf.WriteString(utils.CharNewLine + utils.WrapperTitle(title) + utils.CharNewLine)
+	for i := range c.recent {
+		input := visibleLine(c.recent[i].input)
+		if runes := []rune(input); len(runes) > maxSummaryCommandLen {
+			input = string(runes[:maxSummaryCommandLen]) + "..."
+		}
+		line := fmt.Sprintf("  %s  %s", c.recent[i].created.In(c.loc).Format("15:04:05"), input)
+		// 命中命令过滤规则的命令使用红色显示
+		if c.recent[i].riskLevel > model.NormalLevel {
+			line = utils.WrapperString(line, utils.Red)
+		}
+		buf.WriteString(line + utils.CharNewLine)
+	}
+	return buf.String()
+}
+
+// displayCommandSummary 会话结束后显示命令摘要
+func (s *Server) displayCommandSummary(summary *commandSummary) {
+	if summary == nil || s.UserConn.Context().Err() != nil {
+		// 用户已经断开时不再显示
+		return
+	}
+	utils.IgnoreErrWriteString(s.UserConn, summary.render(s.connOpts.getLang()))
+}
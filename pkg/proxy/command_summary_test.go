@@ -0,0 +1,33 @@
+package proxy
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestCommandSummaryRender(t *testing.T) {
+	summary := newCommandSummary(time.UTC)
+	start := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	for i := 0; i < commandSummarySize+2; i++ {
+		summary.add(&model.Command{Input: fmt.Sprintf("cmd-%d", i), DateCreated: start.Add(time.Duration(i) * time.Minute)})
+	}
+	summary.finish()
+	got := summary.render(i18n.EN)
+	if !strings.Contains(got, "12 commands, the last 10 are:") {
+		t.Errorf("render() = %q, want the command count", got)
+	}
+	if strings.Contains(got, "cmd-1\r") || !strings.Contains(got, "08:02:00  cmd-2\r\n") ||
+		!strings.Contains(got, "08:11:00  cmd-11\r\n") {
+		t.Errorf("render() = %q, want the last %d commands", got, commandSummarySize)
+	}
+	empty := newCommandSummary(nil)
+	empty.finish()
+	if got = empty.render(i18n.EN); !strings.Contains(got, "no commands") {
+		t.Errorf("render() without commands = %q", got)
+	}
+}
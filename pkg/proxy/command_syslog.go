@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"strconv"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/syslog"
+)
+
+// commandSyslog 配置了 SYSLOG_HOST 时实时转发会话的命令，与命令存储互不影响
+var commandSyslog *syslog.Forwarder
+
+// InitCommandSyslog 启动时创建 syslog 转发，连接失败时在后台重试
+func InitCommandSyslog(conf config.Config) {
+	if conf.SyslogHost == "" {
+		return
+	}
+	port := conf.SyslogPort
+	if port <= 0 {
+		port = 514
+		if conf.SyslogTLS {
+			port = 6514
+		}
+	}
+	forwarder, err := syslog.NewForwarder(syslog.Options{
+		Host:               conf.SyslogHost,
+		Port:               port,
+		TLS:                conf.SyslogTLS,
+		CAFile:             conf.SyslogTLSCAFile,
+		InsecureSkipVerify: conf.SyslogTLSInsecure,
+		Hostname:           conf.Name,
+	})
+	if err != nil {
+		logger.Errorf("Init command syslog failed: %s", err)
+		return
+	}
+	commandSyslog = forwarder
+}
+
+func forwardCommandSyslog(cmd *model.Command) {
+	if commandSyslog == nil {
+		return
+	}
+	commandSyslog.Send(commandSyslogMessage(cmd))
+}
+
+func commandSyslogMessage(cmd *model.Command) syslog.Message {
+	severity := syslog.SeverityInfo
+	switch cmd.RiskLevel {
+	case model.WarningLevel:
+		severity = syslog.SeverityWarning
+	case model.RejectLevel, model.ReviewReject:
+		severity = syslog.SeverityError
+	}
+	return syslog.Message{
+		Severity:  severity,
+		MsgID:     "command",
+		Timestamp: cmd.DateCreated,
+		SDID:      "command",
+		Params: []syslog.Param{
+			{Name: "session", Value: cmd.SessionID},
+			{Name: "org", Value: cmd.OrgID},
+			{Name: "user", Value: cmd.User},
+			{Name: "asset", Value: cmd.Server},
+			{Name: "account", Value: cmd.Account},
+			{Name: "risk_level", Value: strconv.FormatInt(cmd.RiskLevel, 10)},
+		},
+		Msg: cmd.Input,
+	}
+}
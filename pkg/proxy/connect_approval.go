@@ -0,0 +1,208 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+/*
+APPROVAL_ASSET_RULES 命中规则(格式同 CONFIRM_ASSET_RULES)的资产，如核心数据库，连接前需要审批。
+通过 core 创建资产登录工单后等待审批人处理，批准后继续连接；拒绝、等待超过 APPROVAL_WAIT_TIMEOUT 秒
+或者用户按 Ctrl-C 取消时返回菜单，超时和取消时关闭工单。审批结果记录到会话生命周期日志。
+*/
+
+const defaultApprovalWaitTimeout = 600
+
+var (
+	errApprovalRejected = errors.New("connect approval rejected")
+	errApprovalTimeout  = errors.New("wait connect approval timeout")
+	errApprovalCanceled = errors.New("wait connect approval canceled")
+
+	// 查询工单状态的间隔
+	approvalCheckInterval = 5 * time.Second
+)
+
+var approvalSpinner = []string{"|", "/", "-", "\\"}
+
+func approvalWaitTimeout() time.Duration {
+	seconds := config.GetConf().ApprovalWaitTimeout
+	if seconds <= 0 {
+		seconds = defaultApprovalWaitTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// InteractiveOnlyRule 返回资产命中的连接确认或者审批规则，命中时只能在交互会话中确认或者等待审批后连接
+func InteractiveOnlyRule(asset *model.Asset) (string, bool) {
+	conf := config.GetConf()
+	if rule, ok := matchAssetRules(asset, conf.ApprovalAssetRules); ok {
+		return "approval rule " + rule, true
+	}
+	if rule, ok := matchAssetRules(asset, conf.ConfirmAssetRules); ok {
+		return "confirmation rule " + rule, true
+	}
+	return "", false
+}
+
+// approveConnectIfNeed 返回 false 表示没有得到批准，取消连接
+func (s *Server) approveConnectIfNeed() bool {
+	asset := &s.connOpts.authInfo.Asset
+	rule, ok := matchAssetRules(asset, config.GetConf().ApprovalAssetRules)
+	if !ok {
+		return true
+	}
+	lang := s.connOpts.getLang()
+	user := s.connOpts.authInfo.User
+	reason := fmt.Sprintf("asset matches the koko approval rule %s", rule)
+	ticket, err := s.jmsService.SubmitAssetLoginReview(user.ID, asset.ID,
+		s.connOpts.authInfo.Account.Username, reason)
+	if err != nil || ticket.CheckReq.URL == "" {
+		// 无法创建工单时不允许连接
+		logger.Errorf("Conn[%s] submit approval of asset %s failed: %v", s.UserConn.ID(), asset.String(), err)
+		utils.IgnoreErrWriteString(s.UserConn, utils.WrapperWarn(lang.T("Core API failed")))
+		return false
+	}
+	logger.Infof("Conn[%s] user %s request approval %s to connect asset %s by rule %s",
+		s.UserConn.ID(), user.String(), ticket.TicketId, asset.String(), rule)
+	msg := fmt.Sprintf(lang.T("Asset %s requires approval by rule %s, approval request submitted"), asset.Name, rule)
+	utils.IgnoreErrWriteString(s.UserConn, utils.WrapperString(msg, utils.Yellow)+utils.CharNewLine)
+	if len(ticket.Reviewers) > 0 {
+		msg = fmt.Sprintf(lang.T("Ticket Reviewers: %s"), strings.Join(ticket.Reviewers, ", "))
+		utils.IgnoreErrWriteString(s.UserConn, msg+utils.CharNewLine)
+	}
+	if ticket.TicketDetailUrl != "" {
+		msg = fmt.Sprintf(lang.T("Could copy website URL to notify reviewers: %s"), ticket.TicketDetailUrl)
+		utils.IgnoreErrWriteString(s.UserConn, msg+utils.CharNewLine)
+	}
+	processor, err := s.waitConnectApproval(&ticket.TicketInfo)
+	switch {
+	case err == nil:
+		msg = utils.WrapperString(fmt.Sprintf(lang.T("%s approved"), processor), utils.Green)
+	case errors.Is(err, errApprovalRejected):
+		msg = utils.WrapperString(fmt.Sprintf(lang.T("%s rejected"), processor), utils.Red)
+	case errors.Is(err, errApprovalTimeout):
+		msg = utils.WrapperWarn(lang.T("Waiting for approval timed out, connection canceled"))
+	default:
+		msg = utils.WrapperWarn(lang.T("Waiting for approval canceled"))
+	}
+	utils.IgnoreErrWriteString(s.UserConn, msg+utils.CharNewLine)
+	logger.Infof("Conn[%s] user %s approval %s of asset %s: processor %s, err %v",
+		s.UserConn.ID(), user.String(), ticket.TicketId, asset.String(), processor, err)
+	if err != nil {
+		return false
+	}
+	s.approvedTicket = ticket.TicketId
+	s.approvedBy = processor
+	return true
+}
+
+/*
+waitConnectApproval 等待工单处理，返回处理人。等待期间显示等待的时间，
+读取用户的输入只处理 Ctrl-C，结束时关闭读取避免占用会话的输入。
+*/
+
+func (s *Server) waitConnectApproval(ticket *model.TicketInfo) (string, error) {
+	lang := s.connOpts.getLang()
+	canceled := make(chan struct{})
+	go func() {
+		defer close(canceled)
+		buf := make([]byte, 1024)
+		for {
+			n, err := s.UserConn.Read(buf)
+			if err != nil || bytes.IndexByte(buf[:n], CharCTRLC) >= 0 {
+				return
+			}
+		}
+	}()
+	defer func() { _ = s.UserConn.Close() }()
+
+	type checkResult struct {
+		state model.TicketState
+		err   error
+	}
+	results := make(chan checkResult, 1)
+	checkTicker := time.NewTicker(approvalCheckInterval)
+	defer checkTicker.Stop()
+	checking := false
+
+	start := time.Now()
+	timeout := time.NewTimer(approvalWaitTimeout())
+	defer timeout.Stop()
+	spinTicker := time.NewTicker(200 * time.Millisecond)
+	defer spinTicker.Stop()
+	frame := 0
+	for {
+		select {
+		case <-spinTicker.C:
+			frame++
+			elapsed := int(time.Since(start) / time.Second)
+			msg := fmt.Sprintf(lang.T("Awaiting approval %s %ds, press Ctrl-C to cancel"),
+				approvalSpinner[frame%len(approvalSpinner)], elapsed)
+			utils.IgnoreErrWriteString(s.UserConn, "\r\x1b[K"+utils.WrapperString(msg, utils.Yellow))
+			continue
+		case <-checkTicker.C:
+			if checking {
+				continue
+			}
+			checking = true
+			go func() {
+				state, err := s.jmsService.CheckConfirmStatusByRequestInfo(ticket.CheckReq)
+				results <- checkResult{state: state, err: err}
+			}()
+			continue
+		case ret := <-results:
+			checking = false
+			if ret.err != nil {
+				logger.Errorf("Conn[%s] check approval status err: %s", s.UserConn.ID(), ret.err)
+				continue
+			}
+			switch ret.state.State {
+			case model.TicketApproved:
+				utils.IgnoreErrWriteString(s.UserConn, utils.CharNewLine)
+				return ret.state.Processor, nil
+			case model.TicketRejected, model.TicketClosed:
+				utils.IgnoreErrWriteString(s.UserConn, utils.CharNewLine)
+				return ret.state.Processor, errApprovalRejected
+			}
+			continue
+		case <-timeout.C:
+			utils.IgnoreErrWriteString(s.UserConn, utils.CharNewLine)
+			s.closeApprovalTicket(ticket)
+			return "", errApprovalTimeout
+		case <-canceled:
+			utils.IgnoreErrWriteString(s.UserConn, utils.CharNewLine)
+			s.closeApprovalTicket(ticket)
+			return "", errApprovalCanceled
+		}
+	}
+}
+
+func (s *Server) closeApprovalTicket(ticket *model.TicketInfo) {
+	if err := s.jmsService.CancelConfirmByRequestInfo(ticket.CloseReq); err != nil {
+		logger.Errorf("Conn[%s] close approval ticket err: %s", s.UserConn.ID(), err)
+	}
+}
+
+// recordConnectApproval 会话创建后记录审批的工单和处理人
+func (s *Server) recordConnectApproval() {
+	if s.approvedTicket == "" {
+		return
+	}
+	logObj := model.SessionLifecycleLog{
+		Reason: fmt.Sprintf("connection approved by %s, ticket %s", s.approvedBy, s.approvedTicket),
+		User:   s.connOpts.authInfo.User.String(),
+	}
+	go func() {
+		if err := s.jmsService.RecordSessionLifecycleLog(s.ID, model.ConnectApproved, logObj); err != nil {
+			logger.Errorf("Session[%s] record connect approval log failed: %s", s.ID, err)
+		}
+	}()
+}
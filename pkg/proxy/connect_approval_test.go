@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/service"
+)
+
+func TestApproveConnectIfNeed(t *testing.T) {
+	conf := config.GetConf()
+	conf.ApprovalAssetRules = []string{"tier:core-db"}
+	setTestConfig(t, &conf)
+	oldInterval := approvalCheckInterval
+	approvalCheckInterval = 10 * time.Millisecond
+	defer func() { approvalCheckInterval = oldInterval }()
+
+	var state atomic.Value
+	var closed int32
+	jms := newTestJMService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case service.AssetLoginReviewURL:
+			_, _ = w.Write([]byte(`{"ticket_id": "ticket-1", "need_confirm": true,
+				"check_ticket_api": {"method": "GET", "url": "/api/v1/tickets/ticket-1/status/"},
+				"close_ticket_api": {"method": "DELETE", "url": "/api/v1/tickets/ticket-1/status/"},
+				"assignees": ["admin"]}`))
+		default:
+			if r.Method == http.MethodDelete {
+				atomic.AddInt32(&closed, 1)
+				_, _ = w.Write([]byte(`{}`))
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"processor": "admin", "state": {"value": %q}}`, state.Load())
+		}
+	}))
+	newServer := func(labels []model.Label) (*Server, *pipeUserConn) {
+		conn := newPipeUserConn("conn-1")
+		authInfo := &model.ConnectToken{Asset: model.Asset{Name: "db-core", Labels: labels}}
+		return &Server{UserConn: conn, jmsService: jms,
+			connOpts: &ConnectionOptions{authInfo: authInfo, i18nLang: "en"}}, conn
+	}
+	labels := []model.Label{{Name: "tier", Value: "core-db"}}
+
+	state.Store(model.TicketApproved)
+	s, conn := newServer(labels)
+	if !s.approveConnectIfNeed() {
+		t.Fatalf("approveConnectIfNeed() = false after approval, output %q", conn.output())
+	}
+	if s.approvedTicket != "ticket-1" || s.approvedBy != "admin" {
+		t.Errorf("approved ticket = %q by %q, want ticket-1 by admin", s.approvedTicket, s.approvedBy)
+	}
+	if !strings.Contains(conn.output(), "Ticket Reviewers: admin") {
+		t.Errorf("output %q missing reviewers", conn.output())
+	}
+
+	state.Store(model.TicketRejected)
+	s, conn = newServer(labels)
+	if s.approveConnectIfNeed() || s.approvedTicket != "" {
+		t.Error("approveConnectIfNeed() should deny the rejected connection")
+	}
+	if !strings.Contains(conn.output(), "admin rejected") {
+		t.Errorf("output %q missing rejection", conn.output())
+	}
+
+	// 等待时按 Ctrl-C 取消并关闭工单
+	state.Store(model.TicketOpen)
+	s, conn = newServer(labels)
+	go func() { _, _ = conn.writer.Write([]byte{CharCTRLC}) }()
+	if s.approveConnectIfNeed() {
+		t.Error("approveConnectIfNeed() should deny the canceled connection")
+	}
+	if atomic.LoadInt32(&closed) != 1 {
+		t.Errorf("ticket closed %d times, want 1", closed)
+	}
+
+	// 没有命中规则的资产不需要审批
+	s, _ = newServer([]model.Label{{Name: "tier", Value: "web"}})
+	if !s.approveConnectIfNeed() || s.approvedTicket != "" {
+		t.Error("asset without approval label should connect directly")
+	}
+}
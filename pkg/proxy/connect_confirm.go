@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+/*
+CONFIRM_ASSET_RULES 命中规则(格式同 REPLAY_FORCE_ASSET_RULES)的资产，如生产环境，
+连接前需要用户输入资产名称确认，输入不一致时取消连接返回菜单。确认结果记录到会话生命周期日志。
+*/
+
+// confirmConnectIfNeed 返回 false 表示用户没有确认，取消连接
+func (s *Server) confirmConnectIfNeed() bool {
+	asset := &s.connOpts.authInfo.Asset
+	rule, ok := matchAssetRules(asset, config.GetConf().ConfirmAssetRules)
+	if !ok {
+		return true
+	}
+	lang := s.connOpts.getLang()
+	msg := fmt.Sprintf(lang.T("Asset %s matches the confirmation rule %s"), asset.Name, rule)
+	utils.IgnoreErrWriteString(s.UserConn, utils.WrapperString(msg, utils.Yellow)+utils.CharNewLine)
+	vt := term.NewTerminal(s.UserConn, lang.T("Type the asset name to continue: "))
+	line, err := vt.ReadLine()
+	if err != nil {
+		logger.Errorf("Conn[%s] read connect confirmation err: %s", s.UserConn.ID(), err)
+		return false
+	}
+	if strings.TrimSpace(line) != asset.Name {
+		utils.IgnoreErrWriteString(s.UserConn, utils.WrapperWarn(lang.T("Asset name mismatch, connection canceled")))
+		logger.Infof("Conn[%s] user %s canceled connection to asset %s: confirmation mismatch",
+			s.UserConn.ID(), s.connOpts.authInfo.User.String(), asset.String())
+		return false
+	}
+	s.confirmedRule = rule
+	logger.Infof("Conn[%s] user %s confirmed connection to asset %s by rule %s",
+		s.UserConn.ID(), s.connOpts.authInfo.User.String(), asset.String(), rule)
+	return true
+}
+
+// recordConnectConfirm 会话创建后记录用户的确认
+func (s *Server) recordConnectConfirm() {
+	if s.confirmedRule == "" {
+		return
+	}
+	logObj := model.SessionLifecycleLog{
+		Reason: fmt.Sprintf("user confirmed the connection by asset name, rule %s", s.confirmedRule),
+		User:   s.connOpts.authInfo.User.String(),
+	}
+	go func() {
+		if err := s.jmsService.RecordSessionLifecycleLog(s.ID, model.ConnectConfirmed, logObj); err != nil {
+			logger.Errorf("Session[%s] record connect confirm log failed: %s", s.ID, err)
+		}
+	}()
+}
@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestConfirmConnectIfNeed(t *testing.T) {
+	conf := config.GetConf()
+	conf.ConfirmAssetRules = []string{"env:production"}
+	setTestConfig(t, &conf)
+
+	newServer := func(labels []model.Label) (*Server, *pipeUserConn) {
+		conn := newPipeUserConn("conn-1")
+		authInfo := &model.ConnectToken{Asset: model.Asset{Name: "web-prod", Labels: labels}}
+		return &Server{UserConn: conn, connOpts: &ConnectionOptions{authInfo: authInfo, i18nLang: "en"}}, conn
+	}
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{input: "web-prod\r", want: true},
+		{input: "web-pro\r", want: false},
+	}
+	for _, tt := range tests {
+		s, conn := newServer([]model.Label{{Name: "env", Value: "production"}})
+		go func() { _, _ = conn.writer.Write([]byte(tt.input)) }()
+		if got := s.confirmConnectIfNeed(); got != tt.want {
+			t.Errorf("confirmConnectIfNeed() with input %q = %v, want %v", tt.input, got, tt.want)
+		}
+		if tt.want && s.confirmedRule != "env:production" {
+			t.Errorf("confirmed rule = %q, want env:production", s.confirmedRule)
+		}
+	}
+	// 没有命中规则的资产不需要确认
+	s, _ := newServer([]model.Label{{Name: "env", Value: "staging"}})
+	if !s.confirmConnectIfNeed() || s.confirmedRule != "" {
+		t.Error("asset without confirmation label should connect directly")
+	}
+}
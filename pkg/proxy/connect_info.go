@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/common"
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+/*
+CONNECT_INFO 开启后，连接资产成功、开始转发数据之前显示本次连接的信息，用于排查问题：
+连接路径(koko 节点 → 网关 → 资产)、登录的用户和账号、使用的协议。默认关闭。
+*/
+
+type connectInfo struct {
+	Node     string
+	Gateways []string
+	Asset    string
+	User     string
+	Account  string
+	Protocol string
+}
+
+func renderConnectInfo(lang i18n.LanguageCode, info connectInfo, cm utils.ColorMeta) string {
+	hops := make([]string, 0, len(info.Gateways)+2)
+	hops = append(hops, info.Node)
+	hops = append(hops, info.Gateways...)
+	hops = append(hops, info.Asset)
+	rows := [][2]string{
+		{lang.T("Path"), strings.Join(hops, " → ")},
+		{lang.T("User"), info.User},
+		{lang.T("Account"), info.Account},
+		{lang.T("Protocol"), info.Protocol},
+	}
+	var b strings.Builder
+	b.WriteString(cm.TitleColor + lang.T("Connection info") + cm.ColorEnd + utils.CharNewLine)
+	for _, row := range rows {
+		b.WriteString(fmt.Sprintf("  %s%s:%s %s%s", cm.InstructionColor, row[0], cm.ColorEnd,
+			row[1], utils.CharNewLine))
+	}
+	return b.String()
+}
+
+// connectGateways 返回连接经过的网关，多级网关优先
+func (s *Server) connectGateways() []string {
+	var gateways []model.Gateway
+	switch {
+	case len(s.connOpts.authInfo.GatewayChain) > 0 && s.isSSHOrTelnet():
+		gateways = s.connOpts.authInfo.GatewayChain
+	case s.gateway != nil:
+		gateways = []model.Gateway{*s.gateway}
+	}
+	ret := make([]string, 0, len(gateways))
+	for i := range gateways {
+		gateway := &gateways[i]
+		addr := common.DisplayHost(gateway.Address)
+		if port := gateway.Protocols.GetProtocolPort(model.ProtocolSSH); port > 0 {
+			addr = net.JoinHostPort(common.TrimHostBrackets(gateway.Address), strconv.Itoa(port))
+		}
+		ret = append(ret, fmt.Sprintf("%s(%s)", gateway.Name, addr))
+	}
+	return ret
+}
+
+// displayConnectInfo CONNECT_INFO 开启时向用户显示本次连接的信息
+func (s *Server) displayConnectInfo() {
+	conf := config.GetConf()
+	if !conf.ConnectInfo {
+		return
+	}
+	asset := s.connOpts.authInfo.Asset
+	info := connectInfo{
+		Node:     conf.Name,
+		Gateways: s.connectGateways(),
+		Asset:    fmt.Sprintf("%s(%s)", asset.Name, common.DisplayHost(asset.Address)),
+		User:     s.connOpts.authInfo.User.String(),
+		Account:  s.account.String(),
+		Protocol: s.connOpts.authInfo.Protocol,
+	}
+	msg := renderConnectInfo(s.connOpts.getLang(), info, utils.NewColorMeta())
+	utils.IgnoreErrWriteString(s.UserConn, utils.CharNewLine+msg)
+}
@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+func TestRenderConnectInfo(t *testing.T) {
+	srv := &Server{connOpts: &ConnectionOptions{authInfo: &model.ConnectToken{
+		Protocol: model.ProtocolSSH,
+		GatewayChain: []model.Gateway{
+			{Name: "gw-1", Address: "10.0.0.1", Protocols: model.Protocols{{Name: "ssh", Port: 22}}},
+			{Name: "gw-2", Address: "fd00::2", Protocols: model.Protocols{{Name: "ssh", Port: 2222}}},
+		},
+	}}}
+	info := connectInfo{
+		Node:     "koko-1",
+		Gateways: srv.connectGateways(),
+		Asset:    "web01(10.0.1.5)",
+		User:     "Alice(alice)",
+		Account:  "root(root)",
+		Protocol: model.ProtocolSSH,
+	}
+	got := renderConnectInfo(i18n.EN, info, utils.ColorMeta{})
+	for _, want := range []string{
+		"Path: koko-1 → gw-1(10.0.0.1:22) → gw-2([fd00::2]:2222) → web01(10.0.1.5)\r\n",
+		"User: Alice(alice)\r\n", "Account: root(root)\r\n", "Protocol: ssh\r\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("renderConnectInfo() = %q, want contains %q", got, want)
+		}
+	}
+
+	srv.connOpts.authInfo.GatewayChain = nil
+	if gateways := srv.connectGateways(); len(gateways) != 0 {
+		t.Errorf("connectGateways() = %v, want direct connection", gateways)
+	}
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/common"
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/srvconn"
+)
+
+const (
+	dbTLSVerifyAsset = "asset"
+	dbTLSVerifyAll   = "verify"
+	dbTLSVerifySkip  = "skip"
+)
+
+func normalizeDBTLSVerify(mode string) string {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case dbTLSVerifyAll, dbTLSVerifySkip:
+		return mode
+	case "", dbTLSVerifyAsset:
+	default:
+		logger.Errorf("Invalid DB_TLS_VERIFY %s, use %s", mode, dbTLSVerifyAsset)
+	}
+	return dbTLSVerifyAsset
+}
+
+/*
+databaseTLSOptions 返回 MySQL/MariaDB、PostgreSQL 连接的 TLS 参数。
+DB_TLS_VERIFY 覆盖资产的证书校验设置，资产没有 CA 证书时使用 DB_TLS_CA_BUNDLE，
+通过网关隧道连接时使用资产地址校验证书。
+*/
+
+func (s *Server) databaseTLSOptions(localTunnelAddr *net.TCPAddr) ([]srvconn.SqlOption, error) {
+	asset := s.connOpts.authInfo.Asset
+	conf := config.GetConf()
+	allowInvalidCert := asset.SpecInfo.AllowInvalidCert
+	sslMode := strings.ToLower(strings.TrimSpace(asset.SpecInfo.SSLMode))
+	switch normalizeDBTLSVerify(conf.DBTLSVerify) {
+	case dbTLSVerifyAll:
+		allowInvalidCert = false
+		if sslMode == srvconn.PostgreSQLSSLRequire {
+			sslMode = srvconn.PostgreSQLSSLVerifyFull
+		}
+	case dbTLSVerifySkip:
+		allowInvalidCert = true
+		if sslMode == srvconn.PostgreSQLSSLVerifyFull {
+			sslMode = srvconn.PostgreSQLSSLRequire
+		}
+	}
+	useSSL := asset.SpecInfo.UseSSL || (sslMode != "" && sslMode != srvconn.PostgreSQLSSLDisable)
+	caCert := asset.SecretInfo.CaCert
+	if useSSL && caCert == "" && conf.DBTLSCABundle != "" {
+		data, err := os.ReadFile(conf.DBTLSCABundle)
+		if err != nil {
+			return nil, fmt.Errorf("%w: read DB_TLS_CA_BUNDLE failed: %s", srvconn.ErrDatabaseTLS, err)
+		}
+		caCert = string(data)
+	}
+	sslServerName := ""
+	if localTunnelAddr != nil {
+		sslServerName = common.TrimHostBrackets(asset.Address)
+	}
+	return []srvconn.SqlOption{
+		srvconn.SqlUseSSL(useSSL),
+		srvconn.SqlSSLMode(sslMode),
+		srvconn.SqlSSLServerName(sslServerName),
+		srvconn.SqlCaCert(caCert),
+		srvconn.SqlClientCert(asset.SecretInfo.ClientCert),
+		srvconn.SqlCertKey(asset.SecretInfo.ClientKey),
+		srvconn.SqlAllowInvalidCert(allowInvalidCert),
+	}, nil
+}
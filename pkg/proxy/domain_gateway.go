@@ -10,9 +10,11 @@ import (
 
 	gossh "golang.org/x/crypto/ssh"
 
+	"github.com/jumpserver/koko/pkg/common"
 	"github.com/jumpserver/koko/pkg/config"
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
 	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/srvconn"
 )
 
 type domainGateway struct {
@@ -142,8 +144,8 @@ func (d *domainGateway) createGatewaySSHClient(gateway *model.Gateway) (*gossh.C
 		Timeout:         configTimeout * time.Second,
 	}
 	port := gateway.Protocols.GetProtocolPort(model.ProtocolSSH)
-	addr := net.JoinHostPort(gateway.Address, strconv.Itoa(port))
-	return gossh.Dial("tcp", addr, &sshConfig)
+	addr := net.JoinHostPort(common.TrimHostBrackets(gateway.Address), strconv.Itoa(port))
+	return srvconn.DialSSH(addr, &sshConfig)
 }
 
 func (d *domainGateway) Stop() {
@@ -46,3 +46,52 @@ func TestReplaceURLHostPort(t *testing.T) {
 
 	}
 }
+
+func TestReplaceURLHostPortIPv6(t *testing.T) {
+	tests := []struct {
+		origin string
+		ip     string
+		port   int
+		want   string
+	}{
+		{"https://[2001:db8::1]:6443/api", "127.0.0.1", 9443, "https://127.0.0.1:9443/api"},
+		{"https://192.168.1.2:6443", "::1", 9443, "https://[::1]:9443"},
+		{"https://192.168.1.2:6443", "[::1]", 443, "https://[::1]"},
+		{"http://k8s.example.com", "::1", 80, "http://[::1]"},
+	}
+	for _, tt := range tests {
+		urlObj, err := url.Parse(tt.origin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ReplaceURLHostAndPort(urlObj, tt.ip, tt.port); got != tt.want {
+			t.Errorf("ReplaceURLHostAndPort(%s, %s, %d) = %s, want %s", tt.origin, tt.ip, tt.port, got, tt.want)
+		}
+	}
+}
+
+func TestParseUrlHostAndPort(t *testing.T) {
+	tests := []struct {
+		addr string
+		host string
+		port int
+	}{
+		{"https://192.168.1.2:6443", "192.168.1.2", 6443},
+		{"https://192.168.1.2", "192.168.1.2", 443},
+		{"https://[2001:db8::1]:6443", "2001:db8::1", 6443},
+		{"http://[::1]", "::1", 80},
+		{"https://k8s.example.com:8443/api", "k8s.example.com", 8443},
+	}
+	for _, tt := range tests {
+		host, port, err := ParseUrlHostAndPort(tt.addr)
+		if err != nil {
+			t.Fatalf("ParseUrlHostAndPort(%s) failed: %s", tt.addr, err)
+		}
+		if host != tt.host || port != tt.port {
+			t.Errorf("ParseUrlHostAndPort(%s) = %s, %d, want %s, %d", tt.addr, host, port, tt.host, tt.port)
+		}
+	}
+	if _, _, err := ParseUrlHostAndPort("https://[::1]:abc"); err == nil {
+		t.Error("invalid port accepted")
+	}
+}
@@ -0,0 +1,62 @@
+package proxy
+
+/*
+escapeDetector 识别用户在行首输入的退出序列(默认 ~.，与 OpenSSH 相同)，资产无响应时强制关闭与资产的连接，返回菜单。
+与 OpenSSH 一样只在行首(会话开始或者回车、换行之后)识别，行首输入的序列前缀先暂存，
+后面的输入不匹配时再一起发送给资产；行首连续输入两次序列的首字符时只发送一个首字符。
+只在读取用户输入的 goroutine 中使用，不需要加锁。
+*/
+
+type escapeDetector struct {
+	seq       []byte
+	pending   []byte
+	lineStart bool
+}
+
+// newEscapeDetector seq 为空时不启用，返回 nil
+func newEscapeDetector(seq string) *escapeDetector {
+	if seq == "" {
+		return nil
+	}
+	return &escapeDetector{seq: []byte(seq), lineStart: true}
+}
+
+// Feed 返回需要发送给资产的数据，以及是否输入了完整的退出序列
+func (e *escapeDetector) Feed(p []byte) ([]byte, bool) {
+	if e == nil {
+		return p, false
+	}
+	out := make([]byte, 0, len(p)+len(e.pending))
+	for _, b := range p {
+		if len(e.pending) > 0 {
+			switch {
+			case b == e.seq[len(e.pending)]:
+				e.pending = append(e.pending, b)
+				if len(e.pending) == len(e.seq) {
+					e.pending = e.pending[:0]
+					return out, true
+				}
+				continue
+			case len(e.pending) == 1 && b == e.seq[0]:
+				// 行首连续输入两次首字符，发送一个首字符
+				out = append(out, b)
+				e.pending = e.pending[:0]
+				e.lineStart = false
+				continue
+			}
+			out = append(out, e.pending...)
+			e.pending = e.pending[:0]
+			e.lineStart = false
+		}
+		if e.lineStart && b == e.seq[0] {
+			if len(e.seq) == 1 {
+				return out, true
+			}
+			e.pending = append(e.pending, b)
+			continue
+		}
+		out = append(out, b)
+		e.lineStart = b == '\r' || b == '\n'
+	}
+	return out, false
+}
@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"testing"
+)
+
+func TestEscapeDetector(t *testing.T) {
+	if newEscapeDetector("") != nil {
+		t.Fatal("newEscapeDetector(\"\") should disable the escape sequence")
+	}
+	tests := []struct {
+		name    string
+		inputs  []string
+		want    string
+		escaped bool
+	}{
+		{name: "session start", inputs: []string{"~."}, escaped: true},
+		{name: "after enter", inputs: []string{"ls\r", "~", "."}, want: "ls\r", escaped: true},
+		{name: "middle of line", inputs: []string{"echo ~.\r"}, want: "echo ~.\r"},
+		{name: "prefix not matched", inputs: []string{"~", "/tmp\r"}, want: "~/tmp\r"},
+		{name: "double escape char", inputs: []string{"~~."}, want: "~."},
+		{name: "after newline", inputs: []string{"a\n~.b"}, want: "a\n", escaped: true},
+	}
+	for _, tt := range tests {
+		d := newEscapeDetector("~.")
+		var (
+			got     []byte
+			escaped bool
+		)
+		for _, input := range tt.inputs {
+			var out []byte
+			out, escaped = d.Feed([]byte(input))
+			got = append(got, out...)
+			if escaped {
+				break
+			}
+		}
+		if string(got) != tt.want || escaped != tt.escaped {
+			t.Errorf("%s: Feed() = %q, %v, want %q, %v", tt.name, got, escaped, tt.want, tt.escaped)
+		}
+	}
+}
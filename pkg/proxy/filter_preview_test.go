@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestParserEnforcedActionPreview(t *testing.T) {
+	events := make(chan map[string]interface{}, 1)
+	jms := newTestJMService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var data map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&data)
+		if strings.HasSuffix(r.URL.Path, "/lifecycle_log/") {
+			events <- data
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("{}"))
+	}))
+
+	rejectRule := CommandRule{Acl: &model.CommandACL{Name: "deny-rm", Action: model.ActionReject}}
+	warningRule := CommandRule{Acl: &model.CommandACL{Name: "warn-ls", Action: model.ActionWarning}}
+
+	p := &Parser{id: "session-1", jmsService: jms}
+	if got := p.enforcedAction(rejectRule, "rm -rf /"); got != model.ActionReject {
+		t.Errorf("enforcedAction() = %s, want %s", got, model.ActionReject)
+	}
+
+	p.filterPreview = true
+	p.currentActiveUser.User = "admin(Administrator)"
+	if got := p.enforcedAction(warningRule, "ls"); got != model.ActionWarning {
+		t.Errorf("enforcedAction() = %s, want %s", got, model.ActionWarning)
+	}
+	if got := p.enforcedAction(rejectRule, "rm -rf /"); got != model.ActionWarning {
+		t.Errorf("enforcedAction() in preview = %s, want %s", got, model.ActionWarning)
+	}
+	select {
+	case data := <-events:
+		if data["event"] != string(model.CommandFilterPreview) {
+			t.Errorf("lifecycle event = %v, want %s", data["event"], model.CommandFilterPreview)
+		}
+		reason, _ := data["reason"].(string)
+		if !strings.Contains(reason, "rm -rf /") || !strings.Contains(reason, "deny-rm") {
+			t.Errorf("lifecycle reason = %q, want command and rule name", reason)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("command filter preview lifecycle log not recorded")
+	}
+	select {
+	case data := <-events:
+		t.Errorf("unexpected lifecycle log %v for warning rule", data)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,32 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/service"
+)
+
+// newTestJMService 使用 handler 模拟 core，测试结束时关闭
+func newTestJMService(t *testing.T, handler http.Handler) *service.JMService {
+	t.Helper()
+	core := httptest.NewServer(handler)
+	t.Cleanup(core.Close)
+	jms, err := service.NewAuthJMService(service.JMSCoreHost(core.URL),
+		service.JMSTimeOut(time.Second), service.JMSAccessKey("id", "secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jms
+}
+
+// setTestConfig 测试中使用 conf 作为全局配置，测试结束时恢复原来的配置
+func setTestConfig(t *testing.T, conf *config.Config) {
+	t.Helper()
+	prev := config.GlobalConfig
+	config.GlobalConfig = conf
+	t.Cleanup(func() { config.GlobalConfig = prev })
+}
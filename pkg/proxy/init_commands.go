@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/srvconn"
+)
+
+/*
+平台协议设置中的初始化命令(init_commands，每行一条，# 开头为注释)在会话建立后、连接宏之前依次执行，
+如网络设备的 terminal length 0，避免分页影响命令输出的审计。每条命令执行前等待资产输出静止(出现提示符)。
+初始化命令直接发送给资产，不经过 parser，不会出现在命令记录中，只记录 debug 日志；命令的输出仍然正常显示和录像。
+*/
+
+// platformInitCommands 返回当前协议的初始化命令，只支持 SSH 和 Telnet 的终端会话
+func (s *Server) platformInitCommands() []string {
+	if !s.isSSHOrTelnet() || s.connOpts.k8sContainer != nil {
+		return nil
+	}
+	protocol := s.connOpts.authInfo.Protocol
+	setting := s.connOpts.authInfo.Platform.GetProtocol(protocol).Setting
+	return parseInitCommands(setting.InitCommands)
+}
+
+func parseInitCommands(text string) []string {
+	var commands []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		commands = append(commands, line)
+	}
+	return commands
+}
+
+// newInitCommandRunner 初始化命令使用连接宏的方式执行，只包含命令步骤
+func newInitCommandRunner(commands []string) *macroRunner {
+	macro := model.ConnectMacro{Name: "init commands", Steps: make([]model.MacroStep, 0, len(commands))}
+	for _, command := range commands {
+		macro.Steps = append(macro.Steps, model.MacroStep{Type: model.MacroStepCommand, Value: command})
+	}
+	return newMacroRunner(&macro)
+}
+
+// runInitCommands 初始化命令直接写入资产连接，不经过 parser 记录命令，回车按 lineEndingMode 转换
+func (s *SwitchSession) runInitCommands(runner *macroRunner, lineEndingMode string, done <-chan struct{}, srvConn srvconn.ServerConnection) {
+	lineEnding := newLineEndingConverter(lineEndingMode)
+	send := func(p []byte) {
+		if p := strings.TrimSpace(string(p)); p != "" {
+			logger.Debugf("Session[%s] send init command: %s", s.ID, p)
+		}
+		if _, err := srvConn.Write(lineEnding.convert(p)); err != nil {
+			logger.Errorf("Session[%s] write init command err: %s", s.ID, err)
+		}
+	}
+	err := runner.Run(done, send)
+	switch {
+	case err == nil:
+		logger.Infof("Session[%s] %d init commands finished", s.ID, len(runner.macro.Steps))
+	case errors.Is(err, errMacroCanceled):
+		logger.Infof("Session[%s] init commands canceled", s.ID)
+	default:
+		logger.Errorf("Session[%s] init commands failed: %s", s.ID, err)
+	}
+}
@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"bytes"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/srvconn"
+)
+
+type recordServerConn struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (c *recordServerConn) Read([]byte) (int, error) { select {} }
+
+func (c *recordServerConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.Write(p)
+}
+
+func (c *recordServerConn) Close() error { return nil }
+
+func (c *recordServerConn) SetWinSize(int, int) error { return nil }
+
+func (c *recordServerConn) KeepAlive() error { return nil }
+
+func TestPlatformInitCommands(t *testing.T) {
+	newServer := func(protocol, commands string) *Server {
+		platform := model.Platform{Protocols: model.PlatformProtocols{{
+			Protocol: model.Protocol{Name: protocol},
+			Setting:  model.ProtocolSetting{InitCommands: commands},
+		}}}
+		return &Server{connOpts: &ConnectionOptions{authInfo: &model.ConnectToken{
+			Protocol: protocol, Platform: platform}}}
+	}
+	s := newServer(srvconn.ProtocolSSH, "# 关闭分页\r\nterminal length 0\n\n  terminal width 512  \n")
+	want := []string{"terminal length 0", "terminal width 512"}
+	if got := s.platformInitCommands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("platformInitCommands() = %q, want %q", got, want)
+	}
+	if got := newServer(srvconn.ProtocolSSH, "").platformInitCommands(); got != nil {
+		t.Errorf("platformInitCommands() without setting = %q, want nil", got)
+	}
+	if got := newServer(srvconn.ProtocolMySQL, "show tables").platformInitCommands(); got != nil {
+		t.Errorf("platformInitCommands() for mysql = %q, want nil", got)
+	}
+
+	conn := &recordServerConn{}
+	sw := &SwitchSession{ID: "test"}
+	sw.runInitCommands(newInitCommandRunner(want), "", make(chan struct{}), conn)
+	if got := conn.buf.String(); got != "terminal length 0\rterminal width 512\r" {
+		t.Errorf("init commands written = %q", got)
+	}
+}
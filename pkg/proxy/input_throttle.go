@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"time"
+)
+
+/*
+inputThrottle 限制用户输入到资产的速率(字节/秒)，防止客户端异常地大量输入导致 koko 空转。
+使用令牌桶，桶的容量为一秒的额度，正常的交互输入和粘贴不受影响；超出额度时暂停读取用户的输入，
+由 TCP 的流量控制让客户端等待。只在读取用户输入的 goroutine 中使用，不需要加锁。
+*/
+
+type inputThrottle struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	now func() time.Time
+}
+
+// newInputThrottle rate 小于等于 0 时不限制，返回 nil
+func newInputThrottle(rate int) *inputThrottle {
+	if rate <= 0 {
+		return nil
+	}
+	t := &inputThrottle{
+		rate:  float64(rate),
+		burst: float64(rate),
+		now:   time.Now,
+	}
+	t.tokens = t.burst
+	t.last = t.now()
+	return t
+}
+
+// reserve 消耗 n 字节的额度，返回需要等待的时间。额度不足时记为欠账，等待后再读取
+func (t *inputThrottle) reserve(n int) time.Duration {
+	if t == nil {
+		return 0
+	}
+	now := t.now()
+	t.tokens += now.Sub(t.last).Seconds() * t.rate
+	if t.tokens > t.burst {
+		t.tokens = t.burst
+	}
+	t.last = now
+	t.tokens -= float64(n)
+	if t.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-t.tokens / t.rate * float64(time.Second))
+}
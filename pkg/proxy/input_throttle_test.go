@@ -0,0 +1,40 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInputThrottle(t *testing.T) {
+	if newInputThrottle(0) != nil {
+		t.Fatal("newInputThrottle(0) should disable the throttle")
+	}
+	var disabled *inputThrottle
+	if wait := disabled.reserve(1 << 20); wait != 0 {
+		t.Errorf("nil throttle reserve() = %s, want 0", wait)
+	}
+
+	now := time.Now()
+	throttle := newInputThrottle(1000)
+	throttle.now = func() time.Time { return now }
+	throttle.last = now
+	// 一秒的额度内不等待
+	for i := 0; i < 10; i++ {
+		if wait := throttle.reserve(100); wait != 0 {
+			t.Fatalf("reserve() #%d = %s, want 0 within the burst", i, wait)
+		}
+	}
+	if wait := throttle.reserve(500); wait != 500*time.Millisecond {
+		t.Errorf("reserve() over the burst = %s, want 500ms", wait)
+	}
+	// 等待后恢复的额度先偿还欠账
+	now = now.Add(500 * time.Millisecond)
+	if wait := throttle.reserve(100); wait != 100*time.Millisecond {
+		t.Errorf("reserve() after wait = %s, want 100ms", wait)
+	}
+	// 空闲后额度不超过桶的容量
+	now = now.Add(time.Hour)
+	if wait := throttle.reserve(1500); wait != 500*time.Millisecond {
+		t.Errorf("reserve() after idle = %s, want 500ms", wait)
+	}
+}
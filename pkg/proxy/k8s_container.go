@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/srvconn"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+// chooseContainer 未指定容器时获取 pod 的容器，只有一个容器时自动选择，否则由用户选择
+func (s *Server) chooseContainer(info *ContainerInfo, opts []srvconn.ContainerOption) error {
+	if info.Container != "" {
+		return nil
+	}
+	containers, err := srvconn.ListPodContainers(opts...)
+	if err != nil {
+		return fmt.Errorf("get pod %s/%s containers failed: %w", info.Namespace, info.PodName, err)
+	}
+	switch len(containers) {
+	case 0:
+		return fmt.Errorf("pod %s/%s has no container", info.Namespace, info.PodName)
+	case 1:
+		info.Container = containers[0]
+		logger.Infof("Conn[%s] pod %s/%s auto select container %s", s.UserConn.ID(),
+			info.Namespace, info.PodName, info.Container)
+		return nil
+	}
+	lang := s.connOpts.getLang()
+	var b strings.Builder
+	b.WriteString(utils.CharNewLine)
+	b.WriteString(fmt.Sprintf(lang.T("Pod %s/%s has multiple containers:"), info.Namespace, info.PodName))
+	b.WriteString(utils.CharNewLine)
+	for i := range containers {
+		b.WriteString(fmt.Sprintf("  %d) %s%s", i+1, containers[i], utils.CharNewLine))
+	}
+	utils.IgnoreErrWriteString(s.UserConn, b.String())
+	vt := term.NewTerminal(s.UserConn, lang.T("Select container number: "))
+	for {
+		line, err2 := vt.ReadLine()
+		if err2 != nil {
+			return err2
+		}
+		line = strings.TrimSpace(line)
+		if num, err3 := strconv.Atoi(line); err3 == nil && num > 0 && num <= len(containers) {
+			info.Container = containers[num-1]
+			return nil
+		}
+		for i := range containers {
+			if containers[i] == line {
+				info.Container = line
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package proxy
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/asciinema"
+	"github.com/jumpserver/koko/pkg/common"
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+/*
+KEYSTROKE_AUDIT_ASSET_RULES 命中规则(格式同 REPLAY_FORCE_ASSET_RULES)的资产记录按键审计，默认不记录。
+按键审计与录像分开保存，记录用户输入的原始内容和时间，格式是 asciicast 的输入("i")事件，用于取证回放。
+
+用户的输入只放入队列，由单独的 goroutine 写入文件，不影响交互的延迟；队列满时丢弃并在会话结束时记录丢弃的数量。
+会话结束后与录像一样压缩上传到录像存储，上传成功后删除本地文件，失败时保留文件，下次启动时重新上传。
+录像存储为 server 或者 null 时只保存在本地：core 的录像接口只能保存会话录像，上传会覆盖会话的录像。
+*/
+
+const (
+	keystrokeFilenameSuffix   = ".keystroke"
+	keystrokeGzFilenameSuffix = keystrokeFilenameSuffix + replayGzFilenameSuffix
+
+	keystrokeQueueSize = 1024
+)
+
+type keystrokeEvent struct {
+	time time.Time
+	data []byte
+}
+
+type KeystrokeRecorder struct {
+	sessionID string
+	storage   ReplayStorage
+
+	absFilePath     string
+	absGzipFilePath string
+	target          string
+
+	file   *os.File
+	buf    *bufio.Writer
+	writer *asciinema.Writer
+
+	lock    sync.Mutex
+	closed  bool
+	dropped int
+	queue   chan keystrokeEvent
+	done    chan struct{}
+}
+
+// IsKeystrokeAuditFile 判断是否是按键审计的文件，返回会话 ID
+func IsKeystrokeAuditFile(filename string) (string, bool) {
+	for _, suffix := range []string{keystrokeFilenameSuffix, keystrokeGzFilenameSuffix} {
+		if sid := strings.TrimSuffix(filename, suffix); sid != filename && common.ValidUUIDString(sid) {
+			return sid, true
+		}
+	}
+	return "", false
+}
+
+// keystrokeAuditTarget 返回按键审计文件在录像存储中的路径，与录像一样按日期分目录
+func keystrokeAuditTarget(date, sid string) string {
+	return strings.Join([]string{date, sid + keystrokeGzFilenameSuffix}, "/")
+}
+
+// CanUploadKeystrokeAudit server 和 null 存储不上传按键审计的文件
+func CanUploadKeystrokeAudit(storage ReplayStorage) bool {
+	switch storage.TypeName() {
+	case "server", "null":
+		return false
+	}
+	return true
+}
+
+func NewKeystrokeRecorder(sid string, storage ReplayStorage, info *ReplyInfo) (*KeystrokeRecorder, error) {
+	today := info.TimeStamp.UTC().Format(dateTimeFormat)
+	dirPath := filepath.Join(config.GetConf().ReplayFolderPath, today)
+	if err := common.EnsureDirExist(dirPath); err != nil {
+		return nil, err
+	}
+	absFilePath := filepath.Join(dirPath, sid+keystrokeFilenameSuffix)
+	fd, err := os.Create(absFilePath)
+	if err != nil {
+		return nil, err
+	}
+	recorder := &KeystrokeRecorder{
+		sessionID:       sid,
+		storage:         storage,
+		absFilePath:     absFilePath,
+		absGzipFilePath: filepath.Join(dirPath, sid+keystrokeGzFilenameSuffix),
+		target:          keystrokeAuditTarget(today, sid),
+		file:            fd,
+		buf:             bufio.NewWriter(fd),
+		queue:           make(chan keystrokeEvent, keystrokeQueueSize),
+		done:            make(chan struct{}),
+	}
+	recorder.writer = asciinema.NewWriter(recorder.buf, asciinema.WithWidth(info.Width),
+		asciinema.WithHeight(info.Height), asciinema.WithTimestamp(info.TimeStamp),
+		asciinema.WithTitle("keystroke audit "+sid))
+	if err = recorder.writer.WriteHeader(); err != nil {
+		_ = fd.Close()
+		return nil, err
+	}
+	logger.Infof("Session %s: create keystroke audit file %s", sid, absFilePath)
+	go recorder.run()
+	return recorder, nil
+}
+
+// Record 记录用户的原始输入，不会阻塞
+func (r *KeystrokeRecorder) Record(p []byte) {
+	if r == nil || len(p) == 0 {
+		return
+	}
+	event := keystrokeEvent{time: time.Now(), data: append([]byte(nil), p...)}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closed {
+		return
+	}
+	select {
+	case r.queue <- event:
+	default:
+		r.dropped++
+	}
+}
+
+func (r *KeystrokeRecorder) run() {
+	defer close(r.done)
+	start := r.writer.TimestampNano
+	for event := range r.queue {
+		ts := float64(event.time.UnixNano()-start) / float64(time.Second)
+		if err := r.writer.WriteInput(ts, event.data); err != nil {
+			logger.Errorf("Session %s: write keystroke audit failed: %s", r.sessionID, err)
+		}
+	}
+}
+
+// End 写入剩余的输入后关闭文件并上传
+func (r *KeystrokeRecorder) End() {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	if r.closed {
+		r.lock.Unlock()
+		return
+	}
+	r.closed = true
+	close(r.queue)
+	dropped := r.dropped
+	r.lock.Unlock()
+	<-r.done
+	if dropped > 0 {
+		logger.Warnf("Session %s: keystroke audit dropped %d input events, queue is full", r.sessionID, dropped)
+	}
+	if err := r.buf.Flush(); err != nil {
+		logger.Errorf("Session %s: flush keystroke audit failed: %s", r.sessionID, err)
+	}
+	_ = r.file.Close()
+	go r.upload()
+}
+
+func (r *KeystrokeRecorder) upload() {
+	if err := CompressReplayFile(r.absFilePath, r.absGzipFilePath); err != nil {
+		// 保留原文件，下次启动时重新压缩上传
+		logger.Errorf("Session %s: %s", r.sessionID, err)
+		return
+	}
+	_ = os.Remove(r.absFilePath)
+	if !CanUploadKeystrokeAudit(r.storage) {
+		logger.Infof("Session %s: keystroke audit saved at %s, storage %s not supported",
+			r.sessionID, r.absGzipFilePath, r.storage.TypeName())
+		return
+	}
+	const maxRetry = 3
+	for i := 0; i <= maxRetry; i++ {
+		logger.Infof("Upload keystroke audit file: %s, type: %s", r.absGzipFilePath, r.storage.TypeName())
+		err := r.storage.Upload(r.absGzipFilePath, r.target)
+		if err == nil {
+			_ = os.Remove(r.absGzipFilePath)
+			return
+		}
+		logger.Errorf("Session %s: upload keystroke audit file err: %s", r.sessionID, err)
+	}
+}
+
+// GetKeystrokeRecorder 资产命中 KEYSTROKE_AUDIT_ASSET_RULES 时返回按键审计的记录，否则返回 nil
+func (s *Server) GetKeystrokeRecorder() *KeystrokeRecorder {
+	asset := &s.connOpts.authInfo.Asset
+	rule, ok := matchAssetRules(asset, config.GetConf().KeystrokeAuditAssetRules)
+	if !ok {
+		return nil
+	}
+	pty := s.UserConn.Pty()
+	info := &ReplyInfo{
+		Width:     pty.Window.Width,
+		Height:    pty.Window.Height,
+		TimeStamp: time.Now(),
+	}
+	recorder, err := NewKeystrokeRecorder(s.ID, NewReplayStorage(s.jmsService, s.terminalConf), info)
+	if err != nil {
+		logger.Errorf("Session %s: create keystroke audit failed: %s", s.ID, err)
+		return nil
+	}
+	logger.Infof("Session %s: asset %s keystroke audit enabled by rule %s", s.ID, asset.String(), rule)
+	return recorder
+}
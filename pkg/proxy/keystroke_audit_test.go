@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/config"
+	storage "github.com/jumpserver/koko/pkg/proxy/recorderstorage"
+)
+
+func TestKeystrokeRecorder(t *testing.T) {
+	conf := config.GetConf()
+	conf.ReplayFolderPath = t.TempDir()
+	setTestConfig(t, &conf)
+
+	sid := "6b1b5c0a-3c8e-4d6f-9a43-2f7c9a1f2d10"
+	info := &ReplyInfo{Width: 80, Height: 24, TimeStamp: time.Now()}
+	recorder, err := NewKeystrokeRecorder(sid, storage.NewNullStorage(), info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"l", "s", "\r", "\x1b[A"} {
+		recorder.Record([]byte(p))
+	}
+	recorder.End()
+	// End 之后的输入不记录
+	recorder.Record([]byte("x"))
+
+	gzPath := filepath.Join(conf.ReplayFolderPath, info.TimeStamp.UTC().Format(dateTimeFormat),
+		sid+keystrokeGzFilenameSuffix)
+	// 压缩完成后删除未压缩的文件
+	rawPath := strings.TrimSuffix(gzPath, replayGzFilenameSuffix)
+	for i := 0; i < 50; i++ {
+		if _, err = os.Stat(rawPath); os.IsNotExist(err) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	fd, err := os.Open(gzPath)
+	if err != nil {
+		t.Fatalf("keystroke audit file not saved: %s", err)
+	}
+	defer fd.Close()
+	reader, err := gzip.NewReader(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("keystroke audit has %d lines, want header and 4 events: %q", len(lines), data)
+	}
+	var inputs []string
+	for _, line := range lines[1:] {
+		var event []interface{}
+		if err = json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatal(err)
+		}
+		if len(event) != 3 || event[1] != "i" {
+			t.Fatalf("event %s, want input event", line)
+		}
+		inputs = append(inputs, event[2].(string))
+	}
+	if got := strings.Join(inputs, ""); got != "ls\r\x1b[A" {
+		t.Errorf("keystroke audit inputs = %q", got)
+	}
+
+	for name, want := range map[string]bool{
+		sid + keystrokeFilenameSuffix:   true,
+		sid + keystrokeGzFilenameSuffix: true,
+		sid + ".cast.gz":                false,
+		"abc" + keystrokeFilenameSuffix: false,
+	} {
+		if _, ok := IsKeystrokeAuditFile(name); ok != want {
+			t.Errorf("IsKeystrokeAuditFile(%s) = %v, want %v", name, ok, want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package proxy
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+/*
+last-output 命令返回用户会话中最近一条命令的输出，去除终端的控制符，方便复制原始的输出。
+每个会话保留最近一次回车之后资产的输出，最多 maxLastOutputSize 字节，超出时丢弃最早的部分。
+输出中最后一行没有换行的内容是提示符，不返回；回车之后还没有输出时返回上一条命令的输出。
+会话结束后只保留用户最近的一个会话，超过 lastOutputRetention 后删除，REPLAY_MASK_PATTERNS 同样生效。
+*/
+
+const (
+	maxLastOutputSize   = 64 * 1024
+	lastOutputRetention = time.Hour
+)
+
+var (
+	ErrLastOutputNotFound = errors.New("no recent session output found")
+
+	lastOutputLock sync.Mutex
+	lastOutputs    = make(map[string]*lastOutputBuffer)
+)
+
+type lastOutputBuffer struct {
+	sessionID string
+	userID    string
+
+	mu       sync.Mutex
+	current  []byte
+	previous []byte
+	updated  time.Time
+	ended    bool
+}
+
+func newLastOutputBuffer(sid, userID string) *lastOutputBuffer {
+	b := &lastOutputBuffer{sessionID: sid, userID: userID, updated: time.Now()}
+	lastOutputLock.Lock()
+	lastOutputs[sid] = b
+	lastOutputLock.Unlock()
+	return b
+}
+
+// Write 保存资产的输出，超过 maxLastOutputSize 时丢弃最早的输出
+func (b *lastOutputBuffer) Write(p []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.current = append(b.current, p...)
+	if over := len(b.current) - maxLastOutputSize; over > 0 {
+		b.current = append(b.current[:0], b.current[over:]...)
+	}
+	b.updated = time.Now()
+}
+
+// Commit 用户输入回车，开始新的命令
+func (b *lastOutputBuffer) Commit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if cleanCommandOutput(b.current) != "" {
+		b.previous = append(b.previous[:0], b.current...)
+	}
+	b.current = b.current[:0]
+}
+
+func (b *lastOutputBuffer) output() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if output := cleanCommandOutput(b.current); output != "" {
+		return output
+	}
+	return cleanCommandOutput(b.previous)
+}
+
+// End 会话结束，同一个用户只保留最近结束的会话，超过 lastOutputRetention 后删除
+func (b *lastOutputBuffer) End() {
+	lastOutputLock.Lock()
+	defer lastOutputLock.Unlock()
+	now := time.Now()
+	b.mu.Lock()
+	b.ended = true
+	b.updated = now
+	b.mu.Unlock()
+	for sid, item := range lastOutputs {
+		if item != b && ((item.userID == b.userID && item.isEnded()) || item.expired(now)) {
+			delete(lastOutputs, sid)
+		}
+	}
+	time.AfterFunc(lastOutputRetention, b.remove)
+}
+
+func (b *lastOutputBuffer) remove() {
+	lastOutputLock.Lock()
+	defer lastOutputLock.Unlock()
+	if lastOutputs[b.sessionID] == b {
+		delete(lastOutputs, b.sessionID)
+	}
+}
+
+func (b *lastOutputBuffer) isEnded() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.ended
+}
+
+func (b *lastOutputBuffer) expired(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.ended && now.Sub(b.updated) > lastOutputRetention
+}
+
+func (b *lastOutputBuffer) lastUpdated() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.updated
+}
+
+// LastCommandOutput 返回用户会话最近一条命令的输出，sid 为空时使用最近有输出的会话
+func LastCommandOutput(userID, sid string) (string, error) {
+	lastOutputLock.Lock()
+	defer lastOutputLock.Unlock()
+	now := time.Now()
+	var found *lastOutputBuffer
+	for id, item := range lastOutputs {
+		if item.expired(now) {
+			delete(lastOutputs, id)
+			continue
+		}
+		if item.userID != userID || (sid != "" && id != sid) {
+			continue
+		}
+		if found == nil || item.lastUpdated().After(found.lastUpdated()) {
+			found = item
+		}
+	}
+	if found == nil {
+		return "", ErrLastOutputNotFound
+	}
+	return MaskCommandOutput(found.output()), nil
+}
+
+/*
+cleanCommandOutput 按终端显示的方式去除控制符：CSI、OSC 等转义序列和其他控制字符，
+退格删除前一个字符，单独的 \r 回到行首(如进度条)只保留之后的内容。
+第一行是回车的回显，最后一行没有换行时是提示符，都不返回。
+*/
+
+func cleanCommandOutput(p []byte) string {
+	var (
+		lines []string
+		line  []rune
+	)
+	for i := 0; i < len(p); {
+		c := p[i]
+		switch {
+		case c == charESC:
+			i = skipEscapeSequence(p, i)
+			continue
+		case c == '\n':
+			lines = append(lines, strings.TrimRight(string(line), " "))
+			line = line[:0]
+		case c == '\r':
+			if i+1 < len(p) && p[i+1] == '\n' {
+				break
+			}
+			line = line[:0]
+		case c == '\b':
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+			}
+		case c == '\t':
+			line = append(line, '\t')
+		case c < 0x20 || c == 0x7f:
+		default:
+			r, size := utf8.DecodeRune(p[i:])
+			line = append(line, r)
+			i += size
+			continue
+		}
+		i++
+	}
+	// 去除开头回车的回显
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+// skipEscapeSequence 返回 p[i] 开始的转义序列之后的位置，序列不完整时跳过剩余的数据
+func skipEscapeSequence(p []byte, i int) int {
+	i++
+	if i >= len(p) {
+		return i
+	}
+	switch p[i] {
+	case '[':
+		// CSI: 参数和中间字节之后以 0x40-0x7e 结束
+		for i++; i < len(p); i++ {
+			if p[i] >= 0x40 && p[i] <= 0x7e {
+				return i + 1
+			}
+		}
+		return i
+	case ']', 'P', '_', '^':
+		// OSC、DCS 等字符串序列以 BEL 或者 ESC \ 结束
+		for i++; i < len(p); i++ {
+			if p[i] == charBEL {
+				return i + 1
+			}
+			if p[i] == charESC && i+1 < len(p) && p[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		return i
+	case '(', ')', '*', '+', '#':
+		// 字符集选择等序列多一个字节
+		return i + 2
+	}
+	return i + 1
+}
@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCleanCommandOutput(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"\r\nfile1  \x1b[01;34mdir\x1b[0m\r\nfile2\r\n[root@web ~]# ", "file1  dir\nfile2"},
+		{"\r\n\x1b]0;root@web:~\x07hello\r\n$ ", "hello"},
+		{"\r\n 10%\r 50%\r100%\r\ndone\r\n$ ", "100%\ndone"},
+		{"\r\nab\bc\x1b(Bd\r\n", "acd"},
+		{"\r\n$ ", ""},
+	}
+	for _, tt := range tests {
+		if got := cleanCommandOutput([]byte(tt.input)); got != tt.want {
+			t.Errorf("cleanCommandOutput(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestLastCommandOutput(t *testing.T) {
+	first := newLastOutputBuffer("sid-1", "user-1")
+	first.Write([]byte("\r\nuptime output\r\n$ "))
+	first.Commit()
+	// 回车之后还没有输出时返回上一条命令的输出
+	first.Write([]byte("\r\n"))
+	if got, err := LastCommandOutput("user-1", ""); err != nil || got != "uptime output" {
+		t.Errorf("LastCommandOutput() = %q, %v, want previous output", got, err)
+	}
+	first.Write([]byte("second\r\n$ "))
+	if got, _ := LastCommandOutput("user-1", "sid-1"); got != "second" {
+		t.Errorf("LastCommandOutput(sid-1) = %q, want second", got)
+	}
+	if _, err := LastCommandOutput("user-2", "sid-1"); err != ErrLastOutputNotFound {
+		t.Errorf("other user got the output, err %v", err)
+	}
+
+	first.Write([]byte(strings.Repeat("x", maxLastOutputSize)))
+	if len(first.current) != maxLastOutputSize {
+		t.Errorf("buffer size = %d, want capped at %d", len(first.current), maxLastOutputSize)
+	}
+
+	// 同一个用户只保留最近结束的会话
+	second := newLastOutputBuffer("sid-2", "user-1")
+	first.End()
+	second.End()
+	lastOutputLock.Lock()
+	_, kept := lastOutputs["sid-1"]
+	lastOutputLock.Unlock()
+	if kept {
+		t.Error("ended session sid-1 not removed")
+	}
+	// 超过保留时间后删除，不依赖 LastCommandOutput 的调用
+	second.remove()
+	lastOutputLock.Lock()
+	_, kept = lastOutputs["sid-2"]
+	lastOutputLock.Unlock()
+	if kept {
+		t.Error("ended session sid-2 not removed after retention")
+	}
+}
@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/srvconn"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+/*
+LATENCY_DISPLAY 连接 SSH 资产后显示到资产的延迟，使用 SSH keepalive 请求的往返时间，不需要资产开放 ICMP。
+延迟超过 LATENCY_WARN_THRESHOLD 毫秒时显示为警告。LATENCY_CHECK_INTERVAL 大于 0 时会话中按间隔检测延迟，
+只在延迟超过阈值和恢复时提示，避免干扰正在输出的内容。
+*/
+
+const defaultLatencyWarnThreshold = 300
+
+type latencyResult struct {
+	rtt time.Duration
+	err error
+}
+
+// measureLatency 发送一次需要回复的 keepalive 请求，返回往返时间
+func measureLatency(conn srvconn.ServerConnection) (time.Duration, error) {
+	start := time.Now()
+	if err := conn.KeepAlive(); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+func latencyWarnThreshold() time.Duration {
+	threshold := config.GetConf().LatencyWarnThreshold
+	if threshold <= 0 {
+		threshold = defaultLatencyWarnThreshold
+	}
+	return time.Duration(threshold) * time.Millisecond
+}
+
+func formatLatency(rtt time.Duration) string {
+	if rtt < time.Millisecond {
+		return "<1 ms"
+	}
+	return fmt.Sprintf("%d ms", rtt.Milliseconds())
+}
+
+// latencyMessage 延迟超过阈值时使用警告颜色
+func latencyMessage(lang i18n.LanguageCode, rtt, threshold time.Duration, cm utils.ColorMeta) string {
+	if rtt > threshold {
+		msg := fmt.Sprintf(lang.T("High latency to asset: %s (threshold %s)"), formatLatency(rtt), formatLatency(threshold))
+		return cm.YellowBoldColor + msg + cm.ColorEnd
+	}
+	msg := fmt.Sprintf(lang.T("Latency to asset: %s"), formatLatency(rtt))
+	return cm.GreenBoldColor + msg + cm.ColorEnd
+}
+
+// latencySupported 只有 SSH 的 keepalive 需要等待资产回复，其他协议的往返时间不准确
+func (s *Server) latencySupported() bool {
+	return s.connOpts.authInfo.Protocol == srvconn.ProtocolSSH
+}
+
+// displayAssetLatency 在会话开始转发数据之前显示到资产的延迟
+func (s *Server) displayAssetLatency(conn srvconn.ServerConnection) {
+	if !config.GetConf().LatencyDisplay || !s.latencySupported() {
+		return
+	}
+	rtt, err := measureLatency(conn)
+	if err != nil {
+		logger.Errorf("Session[%s] measure asset latency failed: %s", s.ID, err)
+		return
+	}
+	logger.Infof("Session[%s] latency to asset %s: %s", s.ID, s.connOpts.authInfo.Asset.String(), rtt)
+	msg := latencyMessage(s.connOpts.getLang(), rtt, latencyWarnThreshold(), utils.NewColorMeta())
+	utils.IgnoreErrWriteString(s.UserConn, utils.CharNewLine+msg+utils.CharNewLine)
+}
+
+// latencyTicker 会话中检测延迟的定时器，没有开启时返回的 channel 为 nil
+func (s *SwitchSession) latencyTicker() (<-chan time.Time, func()) {
+	if s.latencyInterval <= 0 {
+		return nil, func() {}
+	}
+	ticker := time.NewTicker(s.latencyInterval)
+	return ticker.C, ticker.Stop
+}
@@ -0,0 +1,36 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+func TestLatencyMessage(t *testing.T) {
+	cm := utils.NewColorMeta()
+	lang := i18n.LanguageCode("en")
+	threshold := 300 * time.Millisecond
+	tests := []struct {
+		rtt   time.Duration
+		want  string
+		color string
+	}{
+		{500 * time.Microsecond, "Latency to asset: <1 ms", cm.GreenBoldColor},
+		{42 * time.Millisecond, "Latency to asset: 42 ms", cm.GreenBoldColor},
+		{300 * time.Millisecond, "Latency to asset: 300 ms", cm.GreenBoldColor},
+		{1250 * time.Millisecond, "High latency to asset: 1250 ms (threshold 300 ms)", cm.YellowBoldColor},
+	}
+	for _, tt := range tests {
+		got := latencyMessage(lang, tt.rtt, threshold, cm)
+		if !strings.HasPrefix(got, tt.color) || !strings.Contains(got, tt.want) {
+			t.Errorf("latencyMessage(%s) = %q, want %q", tt.rtt, got, tt.want)
+		}
+	}
+	plain := latencyMessage(lang, time.Second, threshold, utils.ColorMeta{})
+	if plain != "High latency to asset: 1000 ms (threshold 300 ms)" {
+		t.Errorf("latencyMessage() without color = %q", plain)
+	}
+}
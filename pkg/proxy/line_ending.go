@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"strings"
+)
+
+/*
+平台协议设置中的 line_ending 指定资产期望的回车序列：lf、cr、crlf，为空时不转换。
+Windows 等网络设备需要 CRLF，用户的回车(CR、LF 或者 CRLF)转换为资产期望的序列后再发送给资产。
+转换在命令解析之后进行，命令记录和录像中保存的是用户实际的输入。
+*/
+
+const (
+	LineEndingLF   = "lf"
+	LineEndingCR   = "cr"
+	LineEndingCRLF = "crlf"
+)
+
+type lineEndingConverter struct {
+	ending []byte
+
+	// 上一次数据以 CR 结束，下一次数据开头的 LF 属于同一个回车
+	afterCR bool
+}
+
+func newLineEndingConverter(mode string) lineEndingConverter {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case LineEndingLF:
+		return lineEndingConverter{ending: []byte("\n")}
+	case LineEndingCR:
+		return lineEndingConverter{ending: []byte("\r")}
+	case LineEndingCRLF:
+		return lineEndingConverter{ending: []byte("\r\n")}
+	}
+	return lineEndingConverter{}
+}
+
+func (c *lineEndingConverter) enabled() bool {
+	return len(c.ending) > 0
+}
+
+// convert 将 b 中的回车转换为资产期望的序列
+func (c *lineEndingConverter) convert(b []byte) []byte {
+	if !c.enabled() || len(b) == 0 {
+		return b
+	}
+	out := make([]byte, 0, len(b)+len(c.ending))
+	for _, ch := range b {
+		afterCR := c.afterCR
+		c.afterCR = false
+		switch ch {
+		case '\r':
+			out = append(out, c.ending...)
+			c.afterCR = true
+		case '\n':
+			if afterCR {
+				continue
+			}
+			out = append(out, c.ending...)
+		default:
+			out = append(out, ch)
+		}
+	}
+	return out
+}
+
+// parseLineEnding 转换发送给资产的回车，文件传输的数据不处理
+func (p *Parser) parseLineEnding(b []byte) []byte {
+	if !p.lineEnding.enabled() || p.zmodemParser.IsStartSession() {
+		return b
+	}
+	return p.lineEnding.convert(b)
+}
+
+// lineEndingMode 只有 ssh 和 telnet 的终端会话使用平台设置的回车序列
+func (s *Server) lineEndingMode() string {
+	if !s.isSSHOrTelnet() || s.connOpts.k8sContainer != nil {
+		return ""
+	}
+	protocol := s.connOpts.authInfo.Protocol
+	return s.connOpts.authInfo.Platform.GetProtocol(protocol).Setting.LineEnding
+}
@@ -0,0 +1,35 @@
+package proxy
+
+import (
+	"testing"
+)
+
+func TestLineEndingConvert(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   string
+		chunks []string
+		want   string
+	}{
+		{"lf to crlf", LineEndingCRLF, []string{"dir\n"}, "dir\r\n"},
+		{"cr to crlf", LineEndingCRLF, []string{"dir\r"}, "dir\r\n"},
+		{"crlf not doubled", LineEndingCRLF, []string{"dir\r\nver\r"}, "dir\r\nver\r\n"},
+		{"split crlf", LineEndingCRLF, []string{"dir\r", "\nver\n"}, "dir\r\nver\r\n"},
+		{"empty lines", LineEndingCRLF, []string{"\r\r\n\n"}, "\r\n\r\n\r\n"},
+		{"cr to lf", LineEndingLF, []string{"show run\r"}, "show run\n"},
+		{"crlf to cr", "CR", []string{"a\r\nb\n"}, "a\rb\r"},
+		{"disabled", "", []string{"dir\n"}, "dir\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converter := newLineEndingConverter(tt.mode)
+			var got string
+			for _, chunk := range tt.chunks {
+				got += string(converter.convert([]byte(chunk)))
+			}
+			if got != tt.want {
+				t.Errorf("convert() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
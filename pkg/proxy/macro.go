@@ -0,0 +1,214 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+const (
+	// 执行命令前等待资产输出静止的时间，避免命令在 shell 准备好之前输入
+	macroSettleTime    = 300 * time.Millisecond
+	macroSettleTimeout = 5 * time.Second
+
+	macroDefaultWaitTimeout = 10 * time.Second
+
+	// wait 步骤只匹配最近的输出
+	macroOutputTailSize = 4 * 1024
+)
+
+var (
+	ErrMacroWaitTimeout = errors.New("macro wait for output timeout")
+	errMacroCanceled    = errors.New("macro canceled")
+)
+
+// ValidateMacroStep 检查步骤的参数是否合法
+func ValidateMacroStep(step model.MacroStep) error {
+	switch step.Type {
+	case model.MacroStepCommand:
+		if step.Value == "" {
+			return errors.New("empty command")
+		}
+	case model.MacroStepDelay:
+		if _, err := time.ParseDuration(step.Value); err != nil {
+			return err
+		}
+	case model.MacroStepWait:
+		if _, err := regexp.Compile(step.Value); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown macro step type %s", step.Type)
+	}
+	return nil
+}
+
+// macroUser 宏发送的命令记录的用户，用于审计区分宏执行的命令
+func macroUser(user, name string) string {
+	return fmt.Sprintf("%s [macro:%s]", user, name)
+}
+
+// recordMacroRun 记录执行的宏和全部步骤，以及是可信的宏还是用户确认后执行
+func (s *Server) recordMacroRun(macro *model.ConnectMacro) {
+	approval := "confirmed by user"
+	if macro.Trusted {
+		approval = "trusted"
+	}
+	steps := make([]string, 0, len(macro.Steps))
+	for i := range macro.Steps {
+		steps = append(steps, fmt.Sprintf("%s %s", macro.Steps[i].Type, macro.Steps[i].Value))
+	}
+	logObj := model.SessionLifecycleLog{
+		Reason: fmt.Sprintf("run macro %s (%s): %s", macro.Name, approval, strings.Join(steps, "; ")),
+		User:   s.connOpts.authInfo.User.String(),
+	}
+	go func() {
+		if err := s.jmsService.RecordSessionLifecycleLog(s.ID, model.MacroRun, logObj); err != nil {
+			logger.Errorf("Session[%s] record macro run log failed: %s", s.ID, err)
+		}
+	}()
+}
+
+/*
+macroRunner 在会话建立后依次执行连接宏的步骤。
+命令和用户的输入一样经过 parser 处理，会被命令过滤和审计，命令记录的用户标记为宏执行；
+资产的输出通过 Feed 传入，用于判断输出是否静止和 wait 步骤的匹配。
+*/
+
+type macroRunner struct {
+	macro *model.ConnectMacro
+
+	mu         sync.Mutex
+	tail       []byte
+	lastOutput time.Time
+
+	notify chan struct{}
+}
+
+func newMacroRunner(macro *model.ConnectMacro) *macroRunner {
+	return &macroRunner{
+		macro:      macro,
+		lastOutput: time.Now(),
+		notify:     make(chan struct{}, 1),
+	}
+}
+
+func (m *macroRunner) Feed(p []byte) {
+	m.mu.Lock()
+	m.tail = append(m.tail, p...)
+	if len(m.tail) > macroOutputTailSize {
+		m.tail = m.tail[len(m.tail)-macroOutputTailSize:]
+	}
+	m.lastOutput = time.Now()
+	m.mu.Unlock()
+	select {
+	case m.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (m *macroRunner) resetOutput() {
+	m.mu.Lock()
+	m.tail = m.tail[:0]
+	m.mu.Unlock()
+}
+
+func (m *macroRunner) matchOutput(pattern *regexp.Regexp) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return pattern.MatchString(utils.StripANSI(string(m.tail)))
+}
+
+func (m *macroRunner) quietFor() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return time.Since(m.lastOutput)
+}
+
+// Run 执行全部步骤，send 用于向资产输入数据，done 关闭时中止执行
+func (m *macroRunner) Run(done <-chan struct{}, send func([]byte)) error {
+	for i := range m.macro.Steps {
+		step := m.macro.Steps[i]
+		var err error
+		switch step.Type {
+		case model.MacroStepCommand:
+			err = m.runCommand(done, step.Value, send)
+		case model.MacroStepDelay:
+			var delay time.Duration
+			if delay, err = time.ParseDuration(step.Value); err == nil {
+				err = sleepOrDone(done, delay)
+			}
+		case model.MacroStepWait:
+			err = m.waitOutput(done, step)
+		default:
+			err = fmt.Errorf("unknown macro step type %s", step.Type)
+		}
+		if err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (m *macroRunner) runCommand(done <-chan struct{}, command string, send func([]byte)) error {
+	deadline := time.Now().Add(macroSettleTimeout)
+	for {
+		quiet := m.quietFor()
+		if quiet >= macroSettleTime || time.Now().After(deadline) {
+			break
+		}
+		if err := sleepOrDone(done, macroSettleTime-quiet); err != nil {
+			return err
+		}
+	}
+	m.resetOutput()
+	// 与用户输入一致，命令和回车分开发送，parser 才能正确记录命令
+	send([]byte(command))
+	if err := sleepOrDone(done, 100*time.Millisecond); err != nil {
+		return err
+	}
+	send([]byte("\r"))
+	return nil
+}
+
+func (m *macroRunner) waitOutput(done <-chan struct{}, step model.MacroStep) error {
+	pattern, err := regexp.Compile(step.Value)
+	if err != nil {
+		return err
+	}
+	timeout := macroDefaultWaitTimeout
+	if step.Timeout > 0 {
+		timeout = time.Duration(step.Timeout) * time.Second
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for !m.matchOutput(pattern) {
+		select {
+		case <-done:
+			return errMacroCanceled
+		case <-timer.C:
+			return fmt.Errorf("%w: %s", ErrMacroWaitTimeout, step.Value)
+		case <-m.notify:
+		}
+	}
+	m.resetOutput()
+	return nil
+}
+
+func sleepOrDone(done <-chan struct{}, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return errMacroCanceled
+	case <-timer.C:
+		return nil
+	}
+}
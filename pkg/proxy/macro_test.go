@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestMacroRunnerRun(t *testing.T) {
+	macro := &model.ConnectMacro{Name: "deploy", Steps: []model.MacroStep{
+		{Type: model.MacroStepCommand, Value: "sudo -iu app"},
+		{Type: model.MacroStepWait, Value: `app@\S+ \$ $`},
+		{Type: model.MacroStepDelay, Value: "10ms"},
+		{Type: model.MacroStepCommand, Value: "cd /srv/app"},
+	}}
+	runner := newMacroRunner(macro)
+	var (
+		mu   sync.Mutex
+		sent []string
+	)
+	send := func(p []byte) {
+		mu.Lock()
+		sent = append(sent, string(p))
+		mu.Unlock()
+		if string(p) == "\r" {
+			// 模拟 shell 切换用户后输出带颜色的提示符
+			go runner.Feed([]byte("\x1b[32mapp@web-01\x1b[0m $ "))
+		}
+	}
+	done := make(chan struct{})
+	if err := runner.Run(done, send); err != nil {
+		t.Fatalf("Run() error = %s", err)
+	}
+	want := []string{"sudo -iu app", "\r", "cd /srv/app", "\r"}
+	if len(sent) != len(want) {
+		t.Fatalf("Run() sent %q, want %q", sent, want)
+	}
+	for i := range want {
+		if sent[i] != want[i] {
+			t.Errorf("Run() sent[%d] = %q, want %q", i, sent[i], want[i])
+		}
+	}
+}
+
+func TestMacroRunnerWaitTimeout(t *testing.T) {
+	macro := &model.ConnectMacro{Name: "wait", Steps: []model.MacroStep{
+		{Type: model.MacroStepWait, Value: `\$ $`, Timeout: 1},
+	}}
+	runner := newMacroRunner(macro)
+	runner.Feed([]byte("Password: "))
+	start := time.Now()
+	err := runner.Run(make(chan struct{}), func([]byte) {})
+	if !errors.Is(err, ErrMacroWaitTimeout) {
+		t.Fatalf("Run() error = %v, want %v", err, ErrMacroWaitTimeout)
+	}
+	if time.Since(start) < time.Second {
+		t.Errorf("Run() returned before the wait timeout")
+	}
+
+	done := make(chan struct{})
+	close(done)
+	if err = runner.Run(done, func([]byte) {}); !errors.Is(err, errMacroCanceled) {
+		t.Errorf("Run() after done error = %v, want %v", err, errMacroCanceled)
+	}
+}
+
+func TestValidateMacroStep(t *testing.T) {
+	invalid := []model.MacroStep{
+		{Type: model.MacroStepCommand},
+		{Type: model.MacroStepDelay, Value: "2"},
+		{Type: model.MacroStepWait, Value: "(["},
+		{Type: "unknown", Value: "ls"},
+	}
+	for _, step := range invalid {
+		if err := ValidateMacroStep(step); err == nil {
+			t.Errorf("ValidateMacroStep(%+v) should return error", step)
+		}
+	}
+	if err := ValidateMacroStep(model.MacroStep{Type: model.MacroStepDelay, Value: "500ms"}); err != nil {
+		t.Errorf("ValidateMacroStep() error = %s", err)
+	}
+}
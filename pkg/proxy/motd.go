@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/jumpserver/koko/pkg/common"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+// MotdContext 资产 motd 模板可使用的变量
+type MotdContext struct {
+	utils.ColorMeta
+	User     string
+	Account  string
+	Asset    string
+	Address  string
+	Platform string
+	OrgName  string
+	Comment  string
+}
+
+// builtinMotds 内置的提示，motd 标签的值为这些 key 时按用户的语言显示
+var builtinMotds = map[string]string{
+	"maintenance": "{{.YellowBoldColor}}Asset {{.Asset}} is under maintenance, please save your work and operate with caution{{.ColorEnd}}",
+	"production":  "{{.RedBoldColor}}Asset {{.Asset}} is a production asset, please operate with caution{{.ColorEnd}}",
+	"deprecated":  "{{.YellowBoldColor}}Asset {{.Asset}} is about to go offline, please migrate your work in time{{.ColorEnd}}",
+}
+
+/*
+renderAssetMotd 渲染资产 motd 标签的内容。内容是内置的 key 时显示翻译后的提示，
+否则按原文作为 text/template 渲染，未配置 motd 时返回空字符。
+*/
+
+func renderAssetMotd(lang i18n.LanguageCode, asset *model.Asset, ctx MotdContext) (string, error) {
+	motd := asset.Motd()
+	if motd == "" {
+		return "", nil
+	}
+	if msg, ok := builtinMotds[strings.TrimSpace(motd)]; ok {
+		motd = lang.T(msg)
+	}
+	tmpl, err := template.New("motd").Parse(motd)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, ctx); err != nil {
+		return "", err
+	}
+	text := strings.ReplaceAll(buf.String(), "\r\n", "\n")
+	return strings.ReplaceAll(text, "\n", utils.CharNewLine), nil
+}
+
+// displayAssetMotd 在会话开始转发数据之前向用户显示资产的 motd
+func (s *Server) displayAssetMotd() {
+	asset := s.connOpts.authInfo.Asset
+	ctx := MotdContext{
+		ColorMeta: utils.NewColorMeta(),
+		User:      s.connOpts.authInfo.User.String(),
+		Account:   s.account.String(),
+		Asset:     asset.Name,
+		Address:   common.DisplayHost(asset.Address),
+		Platform:  asset.Platform.Name,
+		OrgName:   asset.OrgName,
+		Comment:   asset.Comment,
+	}
+	motd, err := renderAssetMotd(s.connOpts.getLang(), &asset, ctx)
+	if err != nil {
+		logger.Errorf("Session[%s] render asset %s motd failed: %s", s.ID, asset.Name, err)
+		return
+	}
+	if motd == "" {
+		return
+	}
+	utils.IgnoreErrWriteString(s.UserConn, utils.CharNewLine+motd+utils.CharNewLine)
+}
@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+func TestRenderAssetMotd(t *testing.T) {
+	ctx := MotdContext{ColorMeta: utils.NewColorMeta(), Asset: "web01", User: "admin"}
+	tests := []struct {
+		name   string
+		labels []model.Label
+		want   string
+	}{
+		{name: "no motd", labels: []model.Label{{Name: "env", Value: "prod"}}, want: ""},
+		{
+			name:   "custom template",
+			labels: []model.Label{{Name: model.AssetMotdLabel, Value: "Hi {{.User}}\n{{.GreenBoldColor}}{{.Asset}}{{.ColorEnd}}"}},
+			want:   "Hi admin\r\n\x1b[1;32mweb01\x1b[0m",
+		},
+		{
+			name:   "builtin key",
+			labels: []model.Label{{Name: model.AssetMotdLabel, Value: "production"}},
+			want:   "\x1b[1;31mAsset web01 is a production asset, please operate with caution\x1b[0m",
+		},
+	}
+	for _, tt := range tests {
+		asset := model.Asset{Name: "web01", Labels: tt.labels}
+		got, err := renderAssetMotd(i18n.EN, &asset, ctx)
+		if err != nil {
+			t.Fatalf("%s: renderAssetMotd() error = %s", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: renderAssetMotd() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	asset := model.Asset{Labels: []model.Label{{Name: model.AssetMotdLabel, Value: "{{.Unknown"}}}
+	if _, err := renderAssetMotd(i18n.EN, &asset, ctx); err == nil {
+		t.Error("renderAssetMotd() with invalid template should return error")
+	}
+}
@@ -0,0 +1,139 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+/*
+REPLAY_MASK_PATTERNS 录像和命令记录中需要隐藏的输出，如 API key、连接串中的密码，匹配的内容替换为 ****。
+正则包含分组时只替换第一个分组，如 "postgres://[^:]+:([^@]+)@" 只隐藏密码。实时会话的输出不受影响。
+
+录像按行处理：输出中最后一个换行之后不完整的行暂时保留，与下一次输出一起匹配，
+避免同一行内容分两次读取时无法识别；保留的内容超过 maxMaskHoldSize 时直接写入。
+没有换行的输出(如回显的输入)立即写入，不会延迟录像。
+*/
+
+const maskReplacement = "****"
+
+// maxMaskHoldSize 暂时保留的不完整行的最大长度
+const maxMaskHoldSize = 1024
+
+func compileMaskPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	regs := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		reg, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q in REPLAY_MASK_PATTERNS: %w", pattern, err)
+		}
+		if reg.MatchString("") {
+			return nil, fmt.Errorf("pattern %q in REPLAY_MASK_PATTERNS matches empty text", pattern)
+		}
+		regs = append(regs, reg)
+	}
+	return regs, nil
+}
+
+// ValidateReplayMaskPatterns 检查 REPLAY_MASK_PATTERNS 中的正则
+func ValidateReplayMaskPatterns(patterns []string) error {
+	_, err := compileMaskPatterns(patterns)
+	return err
+}
+
+// replayMaskPatterns 配置错误时不隐藏任何输出，启动时已经检查过配置
+func replayMaskPatterns() []*regexp.Regexp {
+	regs, err := compileMaskPatterns(config.GetConf().ReplayMaskPatterns)
+	if err != nil {
+		logger.Errorf("%s, output masking is disabled", err)
+		return nil
+	}
+	return regs
+}
+
+// maskOutput 替换匹配的内容，没有匹配时返回原数据
+func maskOutput(regs []*regexp.Regexp, p []byte) []byte {
+	for _, reg := range regs {
+		matches := reg.FindAllSubmatchIndex(p, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		var buf bytes.Buffer
+		buf.Grow(len(p))
+		last := 0
+		for _, match := range matches {
+			start, end := match[0], match[1]
+			// 有分组时只替换第一个分组，分组没有匹配时不替换
+			if len(match) >= 4 {
+				start, end = match[2], match[3]
+			}
+			if start < 0 || start < last {
+				continue
+			}
+			buf.Write(p[last:start])
+			buf.WriteString(maskReplacement)
+			last = end
+		}
+		buf.Write(p[last:])
+		p = buf.Bytes()
+	}
+	return p
+}
+
+// MaskCommandOutput 隐藏 exec 命令记录中的输出
+func MaskCommandOutput(output string) string {
+	regs := replayMaskPatterns()
+	if len(regs) == 0 {
+		return output
+	}
+	return string(maskOutput(regs, []byte(output)))
+}
+
+// outputMasker 录像输出的流式处理，只在录像的 goroutine 中使用
+type outputMasker struct {
+	regs    []*regexp.Regexp
+	pending []byte
+}
+
+func newOutputMasker(regs []*regexp.Regexp) *outputMasker {
+	if len(regs) == 0 {
+		return nil
+	}
+	return &outputMasker{regs: regs}
+}
+
+// Mask 返回可以写入录像的内容，可能为空
+func (m *outputMasker) Mask(p []byte) []byte {
+	data := p
+	if len(m.pending) > 0 {
+		data = append(m.pending, p...)
+		m.pending = nil
+	}
+	index := bytes.LastIndexByte(p, '\n')
+	if index < 0 {
+		return maskOutput(m.regs, data)
+	}
+	// index 是在 p 中的位置，换算到 data 中
+	index += len(data) - len(p)
+	if tail := data[index+1:]; len(tail) > 0 && len(tail) <= maxMaskHoldSize {
+		m.pending = append([]byte(nil), tail...)
+		data = data[:index+1]
+	}
+	return maskOutput(m.regs, data)
+}
+
+// Flush 返回暂时保留的内容，录像结束时调用
+func (m *outputMasker) Flush() []byte {
+	data := m.pending
+	m.pending = nil
+	if len(data) == 0 {
+		return nil
+	}
+	return maskOutput(m.regs, data)
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -72,8 +73,11 @@ type Parser struct {
 	zmodemParser        *zmodem.ZmodemParser
 	enableDownload      bool
 	enableUpload        bool
+	zmodemBlocked       bool // ZMODEM_POLICY 为 block 时禁止所有 zmodem 传输
 	abortedFileTransfer bool
 	currentActiveUser   CurrentActiveUser
+	// 输入当前命令的用户，命令记录在下一次输入时才发送，不能使用 currentActiveUser
+	cmdUser CurrentActiveUser
 
 	i18nLang string
 
@@ -86,6 +90,27 @@ type Parser struct {
 	currentCmdFilterRule CommandRule
 
 	userInputFilter func([]byte) []byte
+
+	// 按键审计，未开启时为 nil
+	keystrokes *KeystrokeRecorder
+
+	// 过滤预览模式，命中会拦截的规则时只记录不拦截
+	filterPreview bool
+
+	// 多行粘贴检测
+	paste pasteGuard
+
+	// 终端标题序列检测
+	title titleGuard
+
+	// 响铃字符的统计和去除
+	bell bellGuard
+
+	// 发送给资产的回车转换
+	lineEnding lineEndingConverter
+
+	// 命令记录中需要隐藏的输出
+	outputMask []*regexp.Regexp
 }
 
 func (p *Parser) setCurrentCmdStatusLevel(level int64) {
@@ -120,6 +145,11 @@ func (p *Parser) SetUserInputFilter(filter func([]byte) []byte) {
 	p.userInputFilter = filter
 }
 
+// SetKeystrokeRecorder 需要在 ParseStream 之前设置
+func (p *Parser) SetKeystrokeRecorder(recorder *KeystrokeRecorder) {
+	p.keystrokes = recorder
+}
+
 // ParseStream 解析数据流
 func (p *Parser) ParseStream(userInChan chan *exchange.RoomMessage, srvInChan <-chan []byte) (userOut, srvOut <-chan []byte) {
 	p.userOutputChan = make(chan []byte, 1)
@@ -129,6 +159,7 @@ func (p *Parser) ParseStream(userInChan chan *exchange.RoomMessage, srvInChan <-
 		defer func() {
 			// 会话结束，结算命令结果
 			p.sendCommandRecord()
+			p.logBellCount()
 			close(p.cmdRecordChan)
 			close(p.userOutputChan)
 			close(p.srvOutputChan)
@@ -155,6 +186,10 @@ func (p *Parser) ParseStream(userInChan chan *exchange.RoomMessage, srvInChan <-
 				if len(b) == 0 {
 					continue
 				}
+				if !p.zmodemParser.IsStartSession() {
+					// 文件传输的数据不是按键，不记录
+					p.keystrokes.Record(b)
+				}
 				b = p.ParseUserInput(b)
 				select {
 				case <-p.closed:
@@ -209,6 +244,9 @@ func (p *Parser) parseInputState(b []byte) []byte {
 
 				logger.Info("Zmodem abort upload file finished")
 				msg := lang.T("have no permission to upload file")
+				if p.zmodemBlocked {
+					msg = lang.T("Zmodem file transfer is disabled by the administrator")
+				}
 				p.abortedFileTransfer = false
 				p.srvOutputChan <- zmodem.CancelSequence
 				p.srvOutputChan <- []byte("\r\n")
@@ -274,7 +312,7 @@ func (p *Parser) parseInputState(b []byte) []byte {
 					statusMsg := utils.WrapperString(fmt.Sprintf(formatMsg, processor), utils.Red)
 					p.srvOutputChan <- []byte("\r\n")
 					p.srvOutputChan <- []byte(statusMsg)
-					p.forbiddenCommand(p.confirmStatus.Cmd)
+					p.forbiddenCommand(p.confirmStatus.Cmd, p.confirmStatus.Rule)
 				default:
 					// 默认是取消 不执行
 					p.setCurrentCmdStatusLevel(model.ReviewCancel)
@@ -302,11 +340,11 @@ func (p *Parser) parseInputState(b []byte) []byte {
 		// 用户输入了Enter，开始结算命令
 		p.parseCmdInput()
 		if rule, cmd, ok := p.IsMatchCommandRule(p.command); ok {
-			switch rule.Acl.Action {
+			switch p.enforcedAction(rule, cmd) {
 			case model.ActionReject:
 				p.setCurrentCmdStatusLevel(model.RejectLevel)
 				p.setCurrentCmdFilterRule(rule)
-				p.forbiddenCommand(cmd)
+				p.forbiddenCommand(cmd, rule)
 				return nil
 			case model.ActionReview:
 				p.setCurrentCmdFilterRule(rule)
@@ -331,14 +369,15 @@ func (p *Parser) parseInputState(b []byte) []byte {
 			p.isMultipleCmd = true
 			p.command = p.readInputBuffer()
 			p.cmdCreateDate = time.Now()
+			p.cmdUser = p.currentActiveUser
 			p.inputState = false
 			p.clearInputBuffer()
 			if rule, cmd, ok := p.IsMatchCommandRule(p.command); ok {
-				switch rule.Acl.Action {
+				switch p.enforcedAction(rule, cmd) {
 				case model.ActionReject:
 					p.setCurrentCmdFilterRule(rule)
 					p.setCurrentCmdStatusLevel(model.RejectLevel)
-					p.forbiddenCommand(cmd)
+					p.forbiddenCommand(cmd, rule)
 					return nil
 				case model.ActionReview:
 					p.setCurrentCmdFilterRule(rule)
@@ -401,15 +440,24 @@ func (p *Parser) clearInputBuffer() {
 	p.inputBuffer.Reset()
 }
 
-func (p *Parser) forbiddenCommand(cmd string) {
-	lang := i18n.NewLang(p.i18nLang)
-	fbdMsg := utils.WrapperWarn(fmt.Sprintf(lang.T("Command `%s` is forbidden"), cmd))
+// forbiddenCommand 拦截命令并提示命中的规则名称，不显示规则的内容
+func (p *Parser) forbiddenCommand(cmd string, rule CommandRule) {
+	fbdMsg := p.forbiddenCommandMsg(cmd, rule)
 	p.srvOutputChan <- []byte("\r\n" + fbdMsg)
 	p.output = fbdMsg
 	p.sendCommandToChan()
 	p.userOutputChan <- p.breakInputPacket()
 }
 
+func (p *Parser) forbiddenCommandMsg(cmd string, rule CommandRule) string {
+	lang := i18n.NewLang(p.i18nLang)
+	if rule.Acl == nil || rule.Acl.Name == "" {
+		return utils.WrapperWarn(fmt.Sprintf(lang.T("Command `%s` is forbidden"), cmd))
+	}
+	msg := fmt.Sprintf(lang.T("Command `%s` was blocked by policy rule `%s`"), cmd, rule.Acl.Name)
+	return utils.WrapperWarn("[!] " + msg)
+}
+
 // parseCmdInput 解析命令的输入
 func (p *Parser) parseCmdInput() {
 	commands := p.cmdInputParser.Parse()
@@ -424,11 +472,15 @@ func (p *Parser) parseCmdInput() {
 		}
 	}
 	p.cmdCreateDate = time.Now()
+	p.cmdUser = p.currentActiveUser
 }
 
 // parseCmdOutput 解析命令输出
 func (p *Parser) parseCmdOutput() {
 	p.output = strings.Join(p.cmdOutputParser.Parse(), "\r\n")
+	if len(p.outputMask) > 0 {
+		p.output = string(maskOutput(p.outputMask, []byte(p.output)))
+	}
 }
 
 // ParseUserInput 解析用户的输入
@@ -439,8 +491,11 @@ func (p *Parser) ParseUserInput(b []byte) []byte {
 	if p.userInputFilter != nil {
 		b = p.userInputFilter(b)
 	}
+	if b = p.parsePasteInput(b); b == nil {
+		return nil
+	}
 	nb := p.parseInputState(b)
-	return nb
+	return p.parseLineEnding(nb)
 }
 
 // parseZmodemState 解析数据，查看是不是处于zmodem状态
@@ -473,6 +528,9 @@ func (p *Parser) splitCmdStream(b []byte) []byte {
 			p.abortedFileTransfer = false
 			p.srvOutputChan <- b
 			msg := lang.T("have no permission to download file")
+			if p.zmodemBlocked {
+				msg = lang.T("Zmodem file transfer is disabled by the administrator")
+			}
 			p.srvOutputChan <- []byte("\r\n")
 			p.srvOutputChan <- []byte(msg)
 			p.srvOutputChan <- []byte("\r\n")
@@ -493,7 +551,7 @@ func (p *Parser) splitCmdStream(b []byte) []byte {
 		p.parseZmodemState(b)
 	}
 	if p.zmodemParser.IsStartSession() {
-		logger.Infof("Zmodem start session %s", p.zmodemParser.Status())
+		logger.Infof("Session %s: zmodem start session %s", p.id, p.zmodemParser.Status())
 		return b
 	}
 	if p.inputState {
@@ -507,7 +565,7 @@ func (p *Parser) splitCmdStream(b []byte) []byte {
 func (p *Parser) ParseServerOutput(b []byte) []byte {
 	p.lock.Lock()
 	defer p.lock.Unlock()
-	return p.splitCmdStream(b)
+	return p.splitCmdStream(p.parseBell(p.parseTitleSequence(b)))
 }
 
 // IsMatchCommandRule 判断命令是不是在过滤规则中
@@ -527,6 +585,36 @@ func (p *Parser) IsMatchCommandRule(command string) (CommandRule,
 	return CommandRule{}, "", false
 }
 
+/*
+enforcedAction 返回命中规则后实际执行的动作。
+过滤预览模式下拒绝、复核规则按告警处理，命令正常执行，
+命令记录中关联命中的规则，并记录一条会话生命周期日志，便于管理员调整规则。
+*/
+
+func (p *Parser) enforcedAction(rule CommandRule, cmd string) model.CommandAction {
+	action := rule.Acl.Action
+	if !p.filterPreview {
+		return action
+	}
+	switch action {
+	case model.ActionReject, model.ActionReview:
+	default:
+		return action
+	}
+	logger.Infof("Session %s: command filter preview, command %s would be %s by rule %s",
+		p.id, cmd, action, rule.Acl.Name)
+	logObj := model.SessionLifecycleLog{
+		Reason: fmt.Sprintf("command `%s` would be %s by rule %s", cmd, action, rule.Acl.Name),
+		User:   p.currentActiveUser.User,
+	}
+	go func() {
+		if err := p.jmsService.RecordSessionLifecycleLog(p.id, model.CommandFilterPreview, logObj); err != nil {
+			logger.Errorf("Session %s: record command filter preview log failed: %s", p.id, err)
+		}
+	}()
+	return model.ActionWarning
+}
+
 type CommandRule struct {
 	Acl  *model.CommandACL
 	Item *model.CommandFilterItem
@@ -666,7 +754,7 @@ func (p *Parser) sendCommandToChan() {
 		RiskLevel:      p.getCurrentCmdStatusLevel(),
 		CmdFilterACLId: cmdFilterId,
 		CmdGroupId:     cmdGroupId,
-		User:           p.currentActiveUser,
+		User:           p.cmdUser,
 	}
 	p.setCurrentCmdStatusLevel(model.NormalLevel)
 	p.resetCurrentCmdFilterRule()
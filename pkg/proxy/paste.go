@@ -0,0 +1,177 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+// PASTE_POLICY 多行粘贴的处理策略
+const (
+	pastePolicyOff     = "off"
+	pastePolicyWarn    = "warn"
+	pastePolicyConfirm = "confirm"
+)
+
+const (
+	// 等待粘贴结束标记时最多缓存的数据，超出后直接按粘贴内容处理
+	maxPasteBufferSize = 64 * 1024
+
+	// 确认提示中最多显示的粘贴行数
+	maxPastePreviewLines = 10
+
+	// 生命周期日志中最多记录的粘贴内容长度
+	maxPasteLogSize = 1024
+)
+
+var (
+	bracketedPasteStart = []byte("\x1b[200~")
+	bracketedPasteEnd   = []byte("\x1b[201~")
+)
+
+type pasteGuard struct {
+	policy string
+
+	// 收到了粘贴开始标记，等待结束标记
+	buffering bool
+	buf       bytes.Buffer
+
+	// 等待用户确认发送的粘贴内容
+	pending []byte
+}
+
+func normalizePastePolicy(policy string) string {
+	switch policy = strings.ToLower(strings.TrimSpace(policy)); policy {
+	case pastePolicyWarn, pastePolicyConfirm:
+		return policy
+	}
+	return pastePolicyOff
+}
+
+/*
+pasteLines 返回粘贴内容中的行，只有一行(或者只是结尾带换行)时返回 nil。
+终端开启了 bracketed paste 模式时粘贴内容包含在开始和结束标记之间；
+未开启时粘贴的内容通常在同一次读取中，包含多个换行的输入也认为是粘贴。
+*/
+
+func pasteLines(b []byte) []string {
+	content := bytes.ReplaceAll(b, bracketedPasteStart, nil)
+	content = bytes.ReplaceAll(content, bracketedPasteEnd, nil)
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	content = bytes.ReplaceAll(content, []byte("\r"), []byte("\n"))
+	content = bytes.Trim(content, "\n")
+	if !bytes.Contains(content, []byte("\n")) {
+		return nil
+	}
+	return strings.Split(string(content), "\n")
+}
+
+// visibleLine 显示控制字符等不可见字符，避免隐藏的内容骗过用户的确认
+func visibleLine(line string) string {
+	quoted := strconv.QuoteToGraphic(line)
+	return quoted[1 : len(quoted)-1]
+}
+
+func (p *Parser) pasteCheckSkipped() bool {
+	if p.zmodemParser.IsStartSession() || p.confirmStatus.InRunning() ||
+		p.confirmStatus.InQuery() {
+		return true
+	}
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.inVimState
+}
+
+// parsePasteInput 检测用户粘贴的多行内容，按照 PASTE_POLICY 记录日志或者等待用户确认
+func (p *Parser) parsePasteInput(b []byte) []byte {
+	guard := &p.paste
+	if guard.policy == "" || guard.policy == pastePolicyOff {
+		return b
+	}
+	if guard.pending != nil {
+		return p.parsePasteConfirm(b)
+	}
+	if !guard.buffering && p.pasteCheckSkipped() {
+		return b
+	}
+	if guard.buffering || bytes.Contains(b, bracketedPasteStart) {
+		guard.buf.Write(b)
+		if !bytes.Contains(guard.buf.Bytes(), bracketedPasteEnd) && guard.buf.Len() < maxPasteBufferSize {
+			guard.buffering = true
+			return nil
+		}
+		b = append([]byte(nil), guard.buf.Bytes()...)
+		guard.buf.Reset()
+		guard.buffering = false
+	}
+	lines := pasteLines(b)
+	if lines == nil {
+		return b
+	}
+	logger.Infof("Session %s: user %s pasted %d lines", p.id, p.currentActiveUser.User, len(lines))
+	if guard.policy == pastePolicyWarn {
+		p.recordPaste(lines, "sent")
+		return b
+	}
+	guard.pending = b
+	p.recordPaste(lines, "waiting for confirmation")
+	p.srvOutputChan <- []byte("\r\n" + p.pasteConfirmMsg(lines))
+	return nil
+}
+
+func (p *Parser) parsePasteConfirm(b []byte) []byte {
+	guard := &p.paste
+	switch strings.ToLower(string(b)) {
+	case "y":
+		data := guard.pending
+		guard.pending = nil
+		logger.Infof("Session %s: user %s confirm to send pasted content", p.id, p.currentActiveUser.User)
+		p.srvOutputChan <- []byte("\r\n")
+		return data
+	case "n", "\r", string([]byte{CtrlC}), string([]byte{CtrlD}):
+		lines := pasteLines(guard.pending)
+		guard.pending = nil
+		p.recordPaste(lines, "cancelled")
+		p.srvOutputChan <- []byte("\r\n")
+		return p.breakInputPacket()
+	default:
+		p.srvOutputChan <- []byte("\r\n" + p.pasteConfirmMsg(pasteLines(guard.pending)))
+	}
+	return nil
+}
+
+func (p *Parser) pasteConfirmMsg(lines []string) string {
+	lang := i18n.NewLang(p.i18nLang)
+	var buf strings.Builder
+	for i := 0; i < len(lines) && i < maxPastePreviewLines; i++ {
+		buf.WriteString(fmt.Sprintf("%3d| %s\r\n", i+1, visibleLine(lines[i])))
+	}
+	if len(lines) > maxPastePreviewLines {
+		buf.WriteString("   | ...\r\n")
+	}
+	msg := fmt.Sprintf(lang.T("Pasted content has %d lines, confirm to send? [y/N]"), len(lines))
+	return buf.String() + utils.WrapperString(msg, utils.Red)
+}
+
+// recordPaste 多行粘贴单独记录到会话生命周期日志，便于审计
+func (p *Parser) recordPaste(lines []string, result string) {
+	content := visibleLine(strings.Join(lines, "\n"))
+	if len(content) > maxPasteLogSize {
+		content = content[:maxPasteLogSize] + "..."
+	}
+	logObj := model.SessionLifecycleLog{
+		Reason: fmt.Sprintf("pasted %d lines (%s): %s", len(lines), result, content),
+		User:   p.currentActiveUser.User,
+	}
+	go func() {
+		if err := p.jmsService.RecordSessionLifecycleLog(p.id, model.MultiLinePaste, logObj); err != nil {
+			logger.Errorf("Session %s: record multi-line paste log failed: %s", p.id, err)
+		}
+	}()
+}
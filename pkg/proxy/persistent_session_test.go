@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+
+	"github.com/jumpserver/koko/pkg/exchange"
+)
+
+// pipeUserConn 模拟用户的 SSH 连接，cancel 模拟客户端断开
+type pipeUserConn struct {
+	id     string
+	reader *io.PipeReader
+	writer *io.PipeWriter
+	winCh  chan ssh.Window
+
+	mu  sync.Mutex
+	out bytes.Buffer
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newPipeUserConn(id string) *pipeUserConn {
+	ctx, cancel := context.WithCancel(context.Background())
+	r, w := io.Pipe()
+	return &pipeUserConn{id: id, reader: r, writer: w, winCh: make(chan ssh.Window, 1), ctx: ctx, cancel: cancel}
+}
+
+func (c *pipeUserConn) disconnect() {
+	c.cancel()
+	_ = c.writer.CloseWithError(io.EOF)
+}
+
+func (c *pipeUserConn) output() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.out.String()
+}
+
+func (c *pipeUserConn) Read(p []byte) (int, error) { return c.reader.Read(p) }
+
+func (c *pipeUserConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.out.Write(p)
+}
+
+func (c *pipeUserConn) Close() error { return c.reader.Close() }
+
+func (c *pipeUserConn) ID() string { return c.id }
+
+func (c *pipeUserConn) WinCh() <-chan ssh.Window { return c.winCh }
+
+func (c *pipeUserConn) LoginFrom() string { return "ST" }
+
+func (c *pipeUserConn) RemoteAddr() string { return "127.0.0.1" }
+
+func (c *pipeUserConn) Pty() ssh.Pty {
+	return ssh.Pty{Window: ssh.Window{Width: 120, Height: 40}}
+}
+
+func (c *pipeUserConn) Context() context.Context { return c.ctx }
+
+func (c *pipeUserConn) HandleRoomEvent(string, *exchange.RoomMessage) {}
+
+func TestDetachableConnReattach(t *testing.T) {
+	first := newPipeUserConn("conn-1")
+	d := newDetachableConn(first, "user-1", time.Minute, 16)
+	d.sessionID = "session-1"
+	registerPersistentSession(d)
+	defer d.Close()
+
+	reads := make(chan string, 4)
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			nr, err := d.Read(buf)
+			if err != nil {
+				close(reads)
+				return
+			}
+			reads <- string(buf[:nr])
+		}
+	}()
+	go func() { _, _ = first.writer.Write([]byte("ls\r")) }()
+	if got := <-reads; got != "ls\r" {
+		t.Fatalf("read = %q, want ls", got)
+	}
+	_, _ = d.Write([]byte("line1\r\nline2\r\n"))
+	first.disconnect()
+	waitFor(t, func() bool { return len(ListDetachedSessions("user-1")) == 1 })
+	// 分离期间资产的输出只保存在回滚缓冲区中
+	_, _ = d.Write([]byte("line3\r\n"))
+	if len(ListDetachedSessions("user-2")) != 0 {
+		t.Fatal("other users can see the detached session")
+	}
+
+	second := newPipeUserConn("conn-2")
+	done := make(chan error, 1)
+	go func() { done <- AttachSession("user-1", "session-1", second) }()
+	waitFor(t, func() bool { return second.output() != "" })
+	if got := second.output(); got != "line2\r\nline3\r\n" {
+		t.Errorf("scrollback replay = %q, want the last lines", got)
+	}
+	if win := <-d.WinCh(); win.Width != 120 || win.Height != 40 {
+		t.Errorf("window after attach = %+v, want size of the new client", win)
+	}
+	go func() { _, _ = second.writer.Write([]byte("pwd\r")) }()
+	if got := <-reads; got != "pwd\r" {
+		t.Errorf("read after attach = %q, want pwd", got)
+	}
+	if err := AttachSession("user-1", "session-1", newPipeUserConn("conn-3")); err != ErrSessionNotDetached {
+		t.Errorf("attach attached session err = %v, want %v", err, ErrSessionNotDetached)
+	}
+
+	_ = d.Close()
+	if err := <-done; err != nil {
+		t.Errorf("AttachSession() = %v", err)
+	}
+	if _, ok := <-reads; ok {
+		t.Error("read not ended after close")
+	}
+	if err := AttachSession("user-1", "session-1", newPipeUserConn("conn-4")); err != ErrSessionNotFound {
+		t.Errorf("attach closed session err = %v, want %v", err, ErrSessionNotFound)
+	}
+}
+
+func TestDetachableConnExpire(t *testing.T) {
+	conn := newPipeUserConn("conn-1")
+	d := newDetachableConn(conn, "user-1", 50*time.Millisecond, 0)
+	d.sessionID = "session-2"
+	registerPersistentSession(d)
+	defer d.Close()
+	conn.disconnect()
+	select {
+	case <-d.Context().Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("detached session not expired")
+	}
+	if !d.isExpired() {
+		t.Error("isExpired() = false after grace time")
+	}
+	if sessions := ListDetachedSessions("user-1"); len(sessions) != 0 {
+		t.Errorf("expired session still listed: %+v", sessions)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
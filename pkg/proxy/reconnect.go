@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/srvconn"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+const (
+	defaultReconnectMaxRetries = 3
+	reconnectRetryDelay        = time.Second
+	reconnectMaxRetryDelay     = 30 * time.Second
+)
+
+// droppedDetector 可以根据读取错误判断上游连接是否为意外断开
+type droppedDetector interface {
+	IsDropped(readErr error) bool
+}
+
+/*
+reconnectConnection 上游连接意外断开(非用户退出)时，按照退避策略重新建立连接，
+重连成功后继续转发数据，对 Bridge 透明；重试次数用完后返回原来的读取错误，结束会话。
+*/
+
+type reconnectConnection struct {
+	sync.RWMutex
+	conn srvconn.ServerConnection
+
+	s          *Server
+	dial       func() (srvconn.ServerConnection, error)
+	maxRetries int
+
+	closed  atomic.Bool
+	dropped atomic.Bool
+}
+
+func newReconnectConnection(s *Server, conn srvconn.ServerConnection,
+	dial func() (srvconn.ServerConnection, error)) *reconnectConnection {
+	maxRetries := s.terminalConf.ReconnectMaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultReconnectMaxRetries
+	}
+	return &reconnectConnection{conn: conn, s: s, dial: dial, maxRetries: maxRetries}
+}
+
+func (r *reconnectConnection) current() srvconn.ServerConnection {
+	r.RLock()
+	defer r.RUnlock()
+	return r.conn
+}
+
+func (r *reconnectConnection) Read(p []byte) (int, error) {
+	for {
+		conn := r.current()
+		nr, err := conn.Read(p)
+		if err == nil || nr > 0 {
+			// 先返回已读取的数据，下一次读取再处理错误
+			return nr, nil
+		}
+		if !r.isDropped(conn, err) {
+			return nr, err
+		}
+		logger.Errorf("Session[%s] connection to asset dropped: %s", r.s.ID, err)
+		if !r.reconnect() {
+			return nr, err
+		}
+	}
+}
+
+func (r *reconnectConnection) isDropped(conn srvconn.ServerConnection, err error) bool {
+	if r.closed.Load() {
+		return false
+	}
+	if r.dropped.Load() {
+		return true
+	}
+	if detector, ok := conn.(droppedDetector); ok {
+		return detector.IsDropped(err)
+	}
+	return !errors.Is(err, io.EOF)
+}
+
+func (r *reconnectConnection) reconnect() bool {
+	lang := r.s.connOpts.getLang()
+	userConn := r.s.UserConn
+	delay := reconnectRetryDelay
+	for i := 1; i <= r.maxRetries; i++ {
+		msg := fmt.Sprintf(lang.T("Connection to asset lost, reconnecting (%d/%d)..."), i, r.maxRetries)
+		utils.IgnoreErrWriteString(userConn, "\r\n"+utils.WrapperString(msg, utils.Red))
+		select {
+		case <-userConn.Context().Done():
+			return false
+		case <-time.After(delay):
+		}
+		if r.closed.Load() {
+			return false
+		}
+		newConn, err := r.dial()
+		if err != nil {
+			logger.Errorf("Session[%s] reconnect to asset %d times failed: %s", r.s.ID, i, err)
+			if delay *= 2; delay > reconnectMaxRetryDelay {
+				delay = reconnectMaxRetryDelay
+			}
+			continue
+		}
+		r.Lock()
+		oldConn := r.conn
+		r.conn = newConn
+		r.Unlock()
+		_ = oldConn.Close()
+		r.dropped.Store(false)
+		win := userConn.Pty().Window
+		_ = newConn.SetWinSize(win.Width, win.Height)
+		logger.Infof("Session[%s] reconnect to asset success", r.s.ID)
+		msg = lang.T("Reconnect to asset success")
+		utils.IgnoreErrWriteString(userConn, "\r\n"+utils.WrapperString(msg, utils.Green)+"\r\n")
+		return true
+	}
+	msg := fmt.Sprintf(lang.T("Reconnect to asset failed after %d attempts"), r.maxRetries)
+	utils.IgnoreErrWriteString(userConn, "\r\n"+utils.WrapperWarn(msg))
+	return false
+}
+
+// Drop 主动断开当前的上游连接(如 keepalive 无响应)，触发重连
+func (r *reconnectConnection) Drop() {
+	r.dropped.Store(true)
+	_ = r.current().Close()
+}
+
+func (r *reconnectConnection) Write(p []byte) (int, error) {
+	return r.current().Write(p)
+}
+
+func (r *reconnectConnection) SetWinSize(width, height int) error {
+	return r.current().SetWinSize(width, height)
+}
+
+func (r *reconnectConnection) KeepAlive() error {
+	return r.current().KeepAlive()
+}
+
+func (r *reconnectConnection) Close() error {
+	r.closed.Store(true)
+	return r.current().Close()
+}
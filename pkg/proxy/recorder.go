@@ -1,6 +1,8 @@
 package proxy
 
 import (
+	"compress/gzip"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -29,6 +31,7 @@ type CommandRecorder struct {
 }
 
 func (c *CommandRecorder) Record(command *model.Command) {
+	forwardCommandSyslog(command)
 	c.queue <- command
 }
 
@@ -113,6 +116,11 @@ const (
 	replayGzFilenameSuffix = ".gz"
 )
 
+const (
+	ReplayLimitPolicyStop     = "stop"
+	ReplayLimitPolicyTruncate = "truncate"
+)
+
 func NewReplayRecord(sid string, jmsService *service.JMService,
 	storage ReplayStorage, info *ReplyInfo) (*ReplyRecorder, error) {
 	recorder := &ReplyRecorder{
@@ -121,6 +129,8 @@ func NewReplayRecord(sid string, jmsService *service.JMService,
 		storage:    storage,
 		info:       info,
 	}
+	recorder.maxOutputSize, recorder.limitPolicy = getReplayOutputLimit()
+	recorder.masker = newOutputMasker(replayMaskPatterns())
 
 	if recorder.isNullStorage() {
 		return recorder, nil
@@ -173,6 +183,51 @@ type ReplyRecorder struct {
 
 	file *os.File
 	once sync.Once
+
+	// 录像输出大小限制，0 表示不限制
+	maxOutputSize int
+	limitPolicy   string
+	outputSize    int
+	limited       bool
+
+	// 隐藏录像中的敏感输出，没有配置时为 nil
+	masker *outputMasker
+}
+
+func getReplayOutputLimit() (int, string) {
+	conf := config.GetConf()
+	size := strings.TrimSpace(conf.ReplayMaxOutputSize)
+	if size == "" || size == "0" {
+		return 0, ""
+	}
+	policy := conf.ReplayOutputLimitPolicy
+	if policy != ReplayLimitPolicyTruncate {
+		policy = ReplayLimitPolicyStop
+	}
+	return common.ConvertSizeToBytes(size), policy
+}
+
+/*
+REPLAY_COMPRESS_LEVEL 录像上传前 gzip 压缩的级别，1(最快)到 9(最小)，-1 为 gzip 的默认级别。
+0 表示不压缩，文件仍然是 gzip 格式，回放时按 .gz 后缀解压的方式不变。
+*/
+
+func replayCompressLevel() int {
+	level := config.GetConf().ReplayCompressLevel
+	if level < gzip.DefaultCompression || level > gzip.BestCompression {
+		logger.Errorf("Invalid REPLAY_COMPRESS_LEVEL %d, use default level", level)
+		return gzip.DefaultCompression
+	}
+	return level
+}
+
+// CompressReplayFile 按 REPLAY_COMPRESS_LEVEL 压缩录像文件，压缩失败时删除不完整的压缩文件
+func CompressReplayFile(srcPath, dstPath string) error {
+	if err := common.GzipCompressFileLevel(srcPath, dstPath, replayCompressLevel()); err != nil {
+		_ = os.Remove(dstPath)
+		return fmt.Errorf("compress replay file %s: %w", srcPath, err)
+	}
+	return nil
 }
 
 func (r *ReplyRecorder) isNullStorage() bool {
@@ -183,22 +238,78 @@ func (r *ReplyRecorder) Record(p []byte) {
 	if r.isNullStorage() {
 		return
 	}
+	if r.masker != nil {
+		p = r.masker.Mask(p)
+	}
 	if len(p) > 0 {
-		r.once.Do(func() {
-			if err := r.Writer.WriteHeader(); err != nil {
-				logger.Errorf("Session %s write replay header failed: %s", r.SessionID, err)
-			}
-		})
-		if err := r.Writer.WriteRow(p); err != nil {
-			logger.Errorf("Session %s write replay row failed: %s", r.SessionID, err)
+		if !r.checkOutputLimit(len(p)) {
+			return
 		}
+		r.writeRow(p)
 	}
 }
 
+func (r *ReplyRecorder) writeRow(p []byte) {
+	r.once.Do(func() {
+		if err := r.Writer.WriteHeader(); err != nil {
+			logger.Errorf("Session %s write replay header failed: %s", r.SessionID, err)
+		}
+	})
+	if err := r.Writer.WriteRow(p); err != nil {
+		logger.Errorf("Session %s write replay row failed: %s", r.SessionID, err)
+	}
+}
+
+/*
+checkOutputLimit 判断本次输出是否还能写入录像。超出限制时写入一条提示后丢弃后续输出，
+录像按完整的行写入，截断后仍是合法的 asciicast 文件，不影响实时会话。
+stop 策略下整个会话不再录制输出；truncate 策略下只截断当前命令的输出，
+用户执行下一条命令时(ResetCommandOutput)恢复录制。
+*/
+
+func (r *ReplyRecorder) checkOutputLimit(size int) bool {
+	if r.maxOutputSize <= 0 {
+		return true
+	}
+	if r.limited {
+		return false
+	}
+	if r.outputSize+size <= r.maxOutputSize {
+		r.outputSize += size
+		return true
+	}
+	r.limited = true
+	logger.Infof("Session %s replay output exceeds %d bytes, policy: %s",
+		r.SessionID, r.maxOutputSize, r.limitPolicy)
+	var marker string
+	switch r.limitPolicy {
+	case ReplayLimitPolicyTruncate:
+		marker = "\r\n[koko: command output exceeds the replay size limit, truncated]\r\n"
+	default:
+		marker = "\r\n[koko: session output exceeds the replay size limit, recording stopped]\r\n"
+	}
+	r.writeRow([]byte(marker))
+	return false
+}
+
+// ResetCommandOutput 用户执行新命令时调用，truncate 策略下重新统计命令输出的大小
+func (r *ReplyRecorder) ResetCommandOutput() {
+	if r.limitPolicy != ReplayLimitPolicyTruncate {
+		return
+	}
+	r.outputSize = 0
+	r.limited = false
+}
+
 func (r *ReplyRecorder) End() {
 	if r.isNullStorage() {
 		return
 	}
+	if r.masker != nil {
+		if p := r.masker.Flush(); len(p) > 0 && r.checkOutputLimit(len(p)) {
+			r.writeRow(p)
+		}
+	}
 	_ = r.file.Close()
 	go r.uploadReplay()
 }
@@ -216,7 +327,11 @@ func (r *ReplyRecorder) uploadReplay() {
 	}
 	if !common.FileExists(r.absGzipFilePath) {
 		logger.Debug("Compress replay file: ", r.absFilePath)
-		_ = common.GzipCompressFile(r.absFilePath, r.absGzipFilePath)
+		if err := CompressReplayFile(r.absFilePath, r.absGzipFilePath); err != nil {
+			// 保留原文件，下次启动时作为遗留录像重新压缩上传
+			logger.Errorf("Session %s: %s", r.SessionID, err)
+			return
+		}
 		_ = os.Remove(r.absFilePath)
 	}
 	r.UploadGzipFile(3)
@@ -0,0 +1,150 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/asciinema"
+	"github.com/jumpserver/koko/pkg/config"
+)
+
+type fakeReplayStorage struct{}
+
+func (fakeReplayStorage) Upload(string, string) error { return nil }
+
+func (fakeReplayStorage) TypeName() string { return "fake" }
+
+func newTestReplayRecorder(buf *bytes.Buffer, maxSize int, policy string) *ReplyRecorder {
+	return &ReplyRecorder{
+		SessionID:     "test",
+		storage:       fakeReplayStorage{},
+		Writer:        asciinema.NewWriter(buf),
+		maxOutputSize: maxSize,
+		limitPolicy:   policy,
+	}
+}
+
+// replayOutputs 校验录像每一行都是合法的 JSON，返回所有输出内容
+func replayOutputs(t *testing.T, buf *bytes.Buffer) []string {
+	var outputs []string
+	scanner := bufio.NewScanner(buf)
+	for i := 0; scanner.Scan(); i++ {
+		if i == 0 {
+			var header map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+				t.Fatalf("invalid replay header %q: %s", scanner.Text(), err)
+			}
+			continue
+		}
+		var row []interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil || len(row) != 3 {
+			t.Fatalf("invalid replay row %q: %v", scanner.Text(), err)
+		}
+		outputs = append(outputs, row[2].(string))
+	}
+	return outputs
+}
+
+func TestReplyRecorderOutputLimitStop(t *testing.T) {
+	var buf bytes.Buffer
+	r := newTestReplayRecorder(&buf, 10, ReplayLimitPolicyStop)
+	r.Record([]byte("12345"))
+	r.Record([]byte("123456"))
+	r.ResetCommandOutput()
+	r.Record([]byte("1"))
+
+	outputs := replayOutputs(t, &buf)
+	if len(outputs) != 2 || outputs[0] != "12345" || !strings.Contains(outputs[1], "recording stopped") {
+		t.Errorf("replay outputs = %q, want output and stop marker", outputs)
+	}
+}
+
+func TestReplyRecorderOutputLimitTruncate(t *testing.T) {
+	var buf bytes.Buffer
+	r := newTestReplayRecorder(&buf, 10, ReplayLimitPolicyTruncate)
+	r.Record([]byte("1234567890a"))
+	r.Record([]byte("b"))
+	r.ResetCommandOutput()
+	r.Record([]byte("ls"))
+
+	outputs := replayOutputs(t, &buf)
+	if len(outputs) != 2 || !strings.Contains(outputs[0], "truncated") || outputs[1] != "ls" {
+		t.Errorf("replay outputs = %q, want truncate marker and next command output", outputs)
+	}
+}
+
+func TestReplyRecorderAsciicast(t *testing.T) {
+	var buf bytes.Buffer
+	r := newTestReplayRecorder(&buf, 0, "")
+	r.Writer = asciinema.NewWriter(&buf, asciinema.WithWidth(132), asciinema.WithHeight(50))
+	output := []byte("中文\r\n")
+	// 多字节字符被拆分到两次输出中
+	r.Record(output[:2])
+	r.Record(output[2:4])
+	r.Record(output[4:])
+
+	var header asciinema.Header
+	headerLine := strings.SplitN(buf.String(), "\n", 2)[0]
+	if err := json.Unmarshal([]byte(headerLine), &header); err != nil {
+		t.Fatalf("invalid replay header %q: %s", headerLine, err)
+	}
+	if header.Version != 2 || header.Width != 132 || header.Height != 50 {
+		t.Errorf("replay header = %+v, want version 2 and size 132x50", header)
+	}
+	outputs := replayOutputs(t, &buf)
+	if got := strings.Join(outputs, ""); got != string(output) {
+		t.Errorf("replay outputs = %q, want %q", got, output)
+	}
+}
+
+func TestCompressReplayFile(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	r := newTestReplayRecorder(&buf, 0, "")
+	for i := 0; i < 200; i++ {
+		r.Record([]byte("[root@web01 ~]# tail -f /var/log/messages\r\n"))
+	}
+	src := filepath.Join(dir, "test.cast")
+	if err := os.WriteFile(src, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf := config.GetConf()
+	setTestConfig(t, &conf)
+
+	sizes := make(map[int]int64)
+	for _, level := range []int{0, 1, 9, -1, 42} {
+		conf.ReplayCompressLevel = level
+		dst := filepath.Join(dir, "test.cast.gz")
+		if err := CompressReplayFile(src, dst); err != nil {
+			t.Fatalf("CompressReplayFile() level %d err: %s", level, err)
+		}
+		fd, err := os.Open(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader, err := gzip.NewReader(fd)
+		if err != nil {
+			t.Fatalf("level %d output is not gzip: %s", level, err)
+		}
+		data, err := io.ReadAll(reader)
+		_ = fd.Close()
+		if err != nil || !bytes.Equal(data, buf.Bytes()) {
+			t.Fatalf("level %d decompressed replay mismatch, err: %v", level, err)
+		}
+		stat, _ := os.Stat(dst)
+		sizes[level] = stat.Size()
+	}
+	if sizes[0] <= sizes[1] || sizes[1] < sizes[9] {
+		t.Errorf("compressed sizes = %v, want level 0 > 1 >= 9", sizes)
+	}
+	if sizes[42] != sizes[-1] {
+		t.Errorf("invalid level size = %d, want default level size %d", sizes[42], sizes[-1])
+	}
+}
@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+	storage "github.com/jumpserver/koko/pkg/proxy/recorderstorage"
+)
+
+/*
+按资产的标签和节点强制录像或者不录像，规则格式:
+  - "标签名:标签值" 匹配名称和值都相同的标签，如 "env:pci"
+  - "标签名" 匹配该名称的任意标签
+  - "node:节点名" 匹配资产所属的节点
+强制录像的资产即使全局未开启录像(录像存储为 null)也会录制并上传到 core，且不受 stop 截断策略的影响；
+豁免的资产不录像。同时命中两类规则时以强制录像为准，录像策略在会话开始时确定。
+*/
+
+type replayPolicy struct {
+	action string
+	rule   string // 命中的规则
+}
+
+const (
+	replayPolicyDefault = ""
+	replayPolicyForce   = "force"
+	replayPolicyExempt  = "exempt"
+)
+
+func decideReplayPolicy(asset *model.Asset, forceRules, exemptRules []string) replayPolicy {
+	if rule, ok := matchAssetRules(asset, forceRules); ok {
+		return replayPolicy{action: replayPolicyForce, rule: rule}
+	}
+	if rule, ok := matchAssetRules(asset, exemptRules); ok {
+		return replayPolicy{action: replayPolicyExempt, rule: rule}
+	}
+	return replayPolicy{action: replayPolicyDefault}
+}
+
+func matchAssetRules(asset *model.Asset, rules []string) (string, bool) {
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		name, value, hasValue := strings.Cut(rule, ":")
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+		if hasValue && strings.EqualFold(name, "node") {
+			for i := range asset.Nodes {
+				if asset.Nodes[i].Name == value {
+					return rule, true
+				}
+			}
+			continue
+		}
+		for i := range asset.Labels {
+			label := asset.Labels[i]
+			if label.Name == name && (!hasValue || label.Value == value) {
+				return rule, true
+			}
+		}
+	}
+	return "", false
+}
+
+// applyReplayPolicy 在会话开始时确定录像策略，强制录像和豁免录像都会记录到会话生命周期日志
+func (s *Server) applyReplayPolicy() {
+	conf := config.GetConf()
+	asset := &s.connOpts.authInfo.Asset
+	s.replayPolicy = decideReplayPolicy(asset, conf.ReplayForceAssetRules, conf.ReplayExemptAssetRules)
+	var event model.LifecycleEvent
+	switch s.replayPolicy.action {
+	case replayPolicyForce:
+		event = model.ReplayForced
+	case replayPolicyExempt:
+		event = model.ReplayExempted
+	default:
+		return
+	}
+	logger.Infof("Session[%s] asset %s replay policy %s by rule %s",
+		s.ID, asset.String(), s.replayPolicy.action, s.replayPolicy.rule)
+	logObj := model.SessionLifecycleLog{
+		Reason: fmt.Sprintf("replay %s by policy rule %s", s.replayPolicy.action, s.replayPolicy.rule),
+		User:   s.connOpts.authInfo.User.String(),
+	}
+	go func() {
+		if err := s.jmsService.RecordSessionLifecycleLog(s.ID, event, logObj); err != nil {
+			logger.Errorf("Session[%s] record replay policy log failed: %s", s.ID, err)
+		}
+	}()
+}
+
+func (s *Server) getReplayStorage() ReplayStorage {
+	replayStorage := NewReplayStorage(s.jmsService, s.terminalConf)
+	switch s.replayPolicy.action {
+	case replayPolicyForce:
+		if replayStorage.TypeName() == "null" {
+			replayStorage = storage.ServerStorage{StorageType: "server", JmsService: s.jmsService}
+		}
+	case replayPolicyExempt:
+		replayStorage = storage.NewNullStorage()
+	}
+	return replayStorage
+}
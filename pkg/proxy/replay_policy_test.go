@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestDecideReplayPolicy(t *testing.T) {
+	asset := &model.Asset{
+		Labels: []model.Label{{Name: "compliance", Value: "PCI"}, {Name: "env", Value: "sandbox"}},
+		Nodes:  []model.BaseNode{{ID: "1", Name: "Payments"}},
+	}
+	tests := []struct {
+		name   string
+		force  []string
+		exempt []string
+		want   replayPolicy
+	}{
+		{name: "label value", force: []string{"compliance:PCI"},
+			want: replayPolicy{action: replayPolicyForce, rule: "compliance:PCI"}},
+		{name: "label name", force: []string{"compliance"},
+			want: replayPolicy{action: replayPolicyForce, rule: "compliance"}},
+		{name: "node", force: []string{"compliance:SOX", "node:Payments"},
+			want: replayPolicy{action: replayPolicyForce, rule: "node:Payments"}},
+		{name: "force wins", force: []string{"compliance:PCI"}, exempt: []string{"env:sandbox"},
+			want: replayPolicy{action: replayPolicyForce, rule: "compliance:PCI"}},
+		{name: "exempt", force: []string{"node:Core"}, exempt: []string{"env:sandbox"},
+			want: replayPolicy{action: replayPolicyExempt, rule: "env:sandbox"}},
+		{name: "no match", force: []string{"compliance:SOX", "node:PCI"},
+			want: replayPolicy{action: replayPolicyDefault}},
+	}
+	for _, tt := range tests {
+		if got := decideReplayPolicy(asset, tt.force, tt.exempt); got != tt.want {
+			t.Errorf("%s: decideReplayPolicy() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGetReplayStorageByPolicy(t *testing.T) {
+	s := &Server{terminalConf: &model.TerminalConfig{
+		ReplayStorage: model.ReplayConfig{TypeName: "null"},
+	}}
+	if got := s.getReplayStorage().TypeName(); got != "null" {
+		t.Errorf("default policy storage = %s, want null", got)
+	}
+	s.replayPolicy = replayPolicy{action: replayPolicyForce}
+	if got := s.getReplayStorage().TypeName(); got != "server" {
+		t.Errorf("force policy storage = %s, want server", got)
+	}
+	s.terminalConf.ReplayStorage.TypeName = "server"
+	s.replayPolicy = replayPolicy{action: replayPolicyExempt}
+	if got := s.getReplayStorage().TypeName(); got != "null" {
+		t.Errorf("exempt policy storage = %s, want null", got)
+	}
+}
@@ -0,0 +1,338 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gliderlabs/ssh"
+	"github.com/mattn/go-runewidth"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+/*
+会话中按 SCROLLBACK_KEY(如 "^]s" 表示 Ctrl+] 后按 s)进入回滚模式，查看已经滚出屏幕的输出。
+每个会话在 scrollbackBuffer 中保留最近 SCROLLBACK_LINES 行去除控制符后的输出，
+资产使用备用屏幕(vim、top 等全屏程序)时的输出不保存。回滚模式在用户终端的备用屏幕中显示，
+期间用户的输入只在本地处理，不发送给资产，也暂停转发资产的输出，退出后恢复原来的屏幕并继续转发。
+*/
+
+const (
+	defaultScrollbackLines = 1000
+	// 没有换行的输出超过该长度时作为一行保存
+	maxScrollbackLineSize = 4096
+)
+
+var errScrollbackClosed = errors.New("session closed in scrollback mode")
+
+// altScreenPattern 进入(h)和退出(l)备用屏幕的序列
+var altScreenPattern = regexp.MustCompile(`\x1b\[\?(?:1049|1047|47)([hl])`)
+
+// ValidateScrollbackKey 检查 SCROLLBACK_KEY，单个可见字符会拦截正常的输入
+func ValidateScrollbackKey(value string) error {
+	if value == "" {
+		return nil
+	}
+	seq, err := utils.ParseKeySequence(value)
+	if err != nil {
+		return fmt.Errorf("SCROLLBACK_KEY: %w", err)
+	}
+	if len(seq) == 1 && seq[0] >= 0x20 && seq[0] < 0x7f {
+		return fmt.Errorf("SCROLLBACK_KEY %q is a printable character", value)
+	}
+	return nil
+}
+
+func scrollbackKey() []byte {
+	value := config.GetConf().ScrollbackKey
+	if ValidateScrollbackKey(value) != nil {
+		return nil
+	}
+	seq, _ := utils.ParseKeySequence(value)
+	return seq
+}
+
+// scrollbackBuffer 保存最近的输出行的环形缓冲
+type scrollbackBuffer struct {
+	mu        sync.Mutex
+	lines     []string
+	next      int
+	full      bool
+	pending   []byte
+	altScreen bool
+}
+
+func newScrollbackBuffer(size int) *scrollbackBuffer {
+	if size <= 0 {
+		size = defaultScrollbackLines
+	}
+	return &scrollbackBuffer{lines: make([]string, size)}
+}
+
+func (b *scrollbackBuffer) Write(p []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	start := 0
+	for _, loc := range altScreenPattern.FindAllSubmatchIndex(p, -1) {
+		if !b.altScreen {
+			b.feed(p[start:loc[0]])
+		}
+		b.altScreen = p[loc[2]] == 'h'
+		start = loc[1]
+	}
+	if !b.altScreen {
+		b.feed(p[start:])
+	}
+}
+
+func (b *scrollbackBuffer) feed(p []byte) {
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			b.pending = append(b.pending, p...)
+			if len(b.pending) >= maxScrollbackLineSize {
+				b.addLine()
+			}
+			return
+		}
+		b.pending = append(b.pending, p[:i+1]...)
+		b.addLine()
+		p = p[i+1:]
+	}
+}
+
+func (b *scrollbackBuffer) addLine() {
+	// cleanCommandOutput 不返回没有换行的最后一行
+	if !bytes.HasSuffix(b.pending, []byte("\n")) {
+		b.pending = append(b.pending, '\n')
+	}
+	b.lines[b.next] = cleanCommandOutput(b.pending)
+	b.pending = b.pending[:0]
+	b.next = (b.next + 1) % len(b.lines)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// Lines 按顺序返回保存的行，包括当前还没有换行的一行
+func (b *scrollbackBuffer) Lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var lines []string
+	if b.full {
+		lines = append(lines, b.lines[b.next:]...)
+	}
+	lines = append(lines, b.lines[:b.next]...)
+	pending := append(append([]byte(nil), b.pending...), '\n')
+	if line := cleanCommandOutput(pending); line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// scrollbackPager 回滚模式，offset 为屏幕第一行在 lines 中的位置
+type scrollbackPager struct {
+	lines  []string
+	offset int
+	width  int
+	height int
+	lang   i18n.LanguageCode
+}
+
+// newScrollbackPager 从最后一屏开始显示
+func newScrollbackPager(lines []string, width, height int, lang i18n.LanguageCode) *scrollbackPager {
+	p := &scrollbackPager{lines: lines, lang: lang}
+	p.resize(width, height)
+	p.offset = p.maxOffset()
+	return p
+}
+
+func (p *scrollbackPager) resize(width, height int) {
+	if width <= 0 {
+		width = 80
+	}
+	if height <= 1 {
+		height = 24
+	}
+	p.width, p.height = width, height
+	p.scroll(0)
+}
+
+// pageSize 最后一行显示状态
+func (p *scrollbackPager) pageSize() int {
+	return p.height - 1
+}
+
+func (p *scrollbackPager) maxOffset() int {
+	if n := len(p.lines) - p.pageSize(); n > 0 {
+		return n
+	}
+	return 0
+}
+
+func (p *scrollbackPager) scroll(n int) {
+	p.offset += n
+	if p.offset > p.maxOffset() {
+		p.offset = p.maxOffset()
+	}
+	if p.offset < 0 {
+		p.offset = 0
+	}
+}
+
+func (p *scrollbackPager) enter() []byte {
+	return append([]byte("\x1b[?1049h\x1b[?25l"), p.render()...)
+}
+
+func (p *scrollbackPager) exit() []byte {
+	return []byte("\x1b[?25h\x1b[?1049l")
+}
+
+func (p *scrollbackPager) render() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("\x1b[H\x1b[2J")
+	end := p.offset + p.pageSize()
+	for i := p.offset; i < end; i++ {
+		if i < len(p.lines) {
+			buf.WriteString(runewidth.Truncate(expandTabs(p.lines[i]), p.width, ""))
+		}
+		buf.WriteString("\r\n")
+	}
+	last := end
+	if last > len(p.lines) {
+		last = len(p.lines)
+	}
+	first := p.offset + 1
+	if first > last {
+		first = last
+	}
+	status := fmt.Sprintf(p.lang.T("Scrollback %d-%d/%d, arrows/PgUp/PgDn to scroll, q to return"),
+		first, last, len(p.lines))
+	buf.WriteString("\x1b[7m" + runewidth.Truncate(status, p.width, "") + "\x1b[0m")
+	return buf.Bytes()
+}
+
+/*
+handleInput 处理回滚模式中用户的输入，返回需要重新显示的内容和是否退出。
+支持方向键、PgUp/PgDn、Home/End 和 k、j、b、空格、g、G，q、Esc、Ctrl-C 或者再次按快捷键退出。
+*/
+
+func (p *scrollbackPager) handleInput(data []byte, hotkey []byte) ([]byte, bool) {
+	if len(hotkey) > 0 && bytes.Contains(data, hotkey) {
+		return nil, true
+	}
+	offset := p.offset
+	for len(data) > 0 {
+		key, size := scrollbackKeyAt(data)
+		data = data[size:]
+		switch key {
+		case "q", "\x1b", "\x03":
+			return nil, true
+		case "\x1b[A", "\x1bOA", "k":
+			p.scroll(-1)
+		case "\x1b[B", "\x1bOB", "j", "\r":
+			p.scroll(1)
+		case "\x1b[5~", "b":
+			p.scroll(-p.pageSize())
+		case "\x1b[6~", " ":
+			p.scroll(p.pageSize())
+		case "\x1b[H", "\x1b[1~", "g":
+			p.offset = 0
+		case "\x1b[F", "\x1b[4~", "G":
+			p.offset = p.maxOffset()
+		}
+	}
+	if p.offset == offset {
+		return nil, false
+	}
+	return p.render(), false
+}
+
+// scrollbackKeyAt 返回 data 开头的按键和长度，单独的 Esc 作为一个按键
+func scrollbackKeyAt(data []byte) (string, int) {
+	if data[0] != charESC || len(data) < 3 || (data[1] != '[' && data[1] != 'O') {
+		return string(data[:1]), 1
+	}
+	for i := 2; i < len(data); i++ {
+		if data[i] >= 0x40 && data[i] <= 0x7e {
+			return string(data[:i+1]), i + 1
+		}
+	}
+	return string(data), len(data)
+}
+
+func expandTabs(line string) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	var sb strings.Builder
+	col := 0
+	for _, r := range line {
+		if r == '\t' {
+			n := 8 - col%8
+			sb.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		sb.WriteRune(r)
+		col += runewidth.RuneWidth(r)
+	}
+	return sb.String()
+}
+
+/*
+runScrollback 在读取用户输入的 goroutine 中运行回滚模式，pause 通知 Bridge 暂停和恢复转发资产的输出。
+读取用户输入出错时返回错误，由调用方结束会话。
+*/
+
+func (s *SwitchSession) runScrollback(userConn UserConnection, buffer *scrollbackBuffer,
+	pause chan<- bool, done <-chan struct{}, window func() ssh.Window) error {
+	select {
+	case pause <- true:
+	case <-done:
+		return errScrollbackClosed
+	}
+	defer func() {
+		select {
+		case pause <- false:
+		case <-done:
+		}
+	}()
+	win := window()
+	pager := newScrollbackPager(buffer.Lines(), win.Width, win.Height, s.p.connOpts.getLang())
+	logger.Infof("Session[%s] enter scrollback mode, %d lines", s.ID, len(pager.lines))
+	defer logger.Infof("Session[%s] exit scrollback mode", s.ID)
+	if _, err := userConn.Write(pager.enter()); err != nil {
+		return err
+	}
+	defer func() { _, _ = userConn.Write(pager.exit()) }()
+	buf := make([]byte, 1024)
+	for {
+		nr, err := userConn.Read(buf)
+		if nr > 0 {
+			out, quit := pager.handleInput(buf[:nr], s.scrollbackKey)
+			if quit {
+				return nil
+			}
+			if w := window(); w.Width != pager.width || w.Height != pager.height {
+				pager.resize(w.Width, w.Height)
+				out = pager.render()
+			}
+			if len(out) > 0 {
+				if _, err2 := userConn.Write(out); err2 != nil {
+					return err2
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
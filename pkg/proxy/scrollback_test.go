@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScrollbackBuffer(t *testing.T) {
+	b := newScrollbackBuffer(3)
+	b.Write([]byte("line1\r\n\x1b[32mline2\x1b[0m\r\nli"))
+	b.Write([]byte("ne3\r\n$ "))
+	if got, want := b.Lines(), []string{"line1", "line2", "line3", "$"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Lines() = %q, want %q", got, want)
+	}
+	// 超出行数时丢弃最早的行
+	b.Write([]byte("ls\r\nline4\r\n"))
+	if got, want := b.Lines(), []string{"line3", "$ ls", "line4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Lines() after overflow = %q, want %q", got, want)
+	}
+	// 备用屏幕中的输出不保存
+	b.Write([]byte("vim\r\n\x1b[?1049h\x1b[Hfile content\r\n~\r\n\x1b[?1049lline5\r\n"))
+	if got, want := b.Lines(), []string{"line4", "vim", "line5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Lines() after alt screen = %q, want %q", got, want)
+	}
+}
+
+func TestScrollbackPager(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 10; i++ {
+		lines = append(lines, strings.Repeat("x", i))
+	}
+	p := newScrollbackPager(lines, 5, 4, "en")
+	if p.offset != 7 {
+		t.Fatalf("initial offset = %d, want last page at 7", p.offset)
+	}
+	steps := []struct {
+		input  string
+		offset int
+		quit   bool
+	}{
+		{input: "\x1b[A", offset: 6},
+		{input: "\x1b[5~", offset: 3},
+		{input: "kk", offset: 1},
+		{input: "g", offset: 0},
+		{input: "\x1b[A", offset: 0},
+		{input: " ", offset: 3},
+		{input: "G", offset: 7},
+		{input: "j", offset: 7},
+		{input: "q", offset: 7, quit: true},
+	}
+	for i, step := range steps {
+		out, quit := p.handleInput([]byte(step.input), []byte("\x1ds"))
+		if p.offset != step.offset || quit != step.quit {
+			t.Fatalf("step %d handleInput(%q) offset %d quit %v, want %d %v",
+				i, step.input, p.offset, quit, step.offset, step.quit)
+		}
+		if step.input == "g" && !strings.Contains(string(out), "\x1b[H\x1b[2Jx\r\nxx\r\nxxx\r\n") {
+			t.Errorf("render() = %q, want first page", out)
+		}
+	}
+	if _, quit := p.handleInput([]byte("\x1ds"), []byte("\x1ds")); !quit {
+		t.Error("handleInput() with hotkey should quit")
+	}
+	// 超出宽度的行截断
+	if out := string(newScrollbackPager(lines, 5, 4, "en").render()); strings.Contains(out, "xxxxxx") {
+		t.Errorf("render() = %q, want lines truncated to width 5", out)
+	}
+}
+
+func TestValidateScrollbackKey(t *testing.T) {
+	for value, ok := range map[string]bool{"": true, "^]s": true, "s": false, "^1": false} {
+		if err := ValidateScrollbackKey(value); (err == nil) != ok {
+			t.Errorf("ValidateScrollbackKey(%q) = %v", value, err)
+		}
+	}
+}
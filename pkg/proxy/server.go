@@ -7,7 +7,6 @@ import (
 	"net"
 	"net/url"
 	"regexp"
-	"sort"
 	"strconv"
 	"strings"
 	"sync/atomic"
@@ -18,11 +17,13 @@ import (
 
 	"github.com/jumpserver/koko/pkg/common"
 	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/credential"
 	"github.com/jumpserver/koko/pkg/exchange"
 	modelCommon "github.com/jumpserver/koko/pkg/jms-sdk-go/common"
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/service"
 	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/metrics"
 	"github.com/jumpserver/koko/pkg/session"
 	"github.com/jumpserver/koko/pkg/srvconn"
 	"github.com/jumpserver/koko/pkg/utils"
@@ -43,6 +44,19 @@ func NewServer(conn UserConnection, jmsService *service.JMService, opts ...Conne
 		setter(connOpts)
 	}
 	lang := connOpts.getLang()
+	if err := credential.ResolveConnectToken(connOpts.authInfo); err != nil {
+		logger.Errorf("Conn[%s] resolve account %s credential failed: %s", conn.ID(),
+			connOpts.authInfo.Account.String(), err)
+		utils.IgnoreErrWriteString(conn, utils.WrapperWarn(lang.T("Get account credential failed")))
+		return nil, fmt.Errorf("%w: %s", ErrAPIFailed, err)
+	}
+	if err := credential.ResolveLoginUser(connOpts.authInfo); err != nil {
+		logger.Errorf("Conn[%s] resolve login user of account %s failed: %s", conn.ID(),
+			connOpts.authInfo.Account.String(), err)
+		msg := fmt.Sprintf(lang.T("Resolve login user failed: %s"), err)
+		utils.IgnoreErrWriteString(conn, utils.WrapperWarn(msg))
+		return nil, err
+	}
 	protocol := connOpts.authInfo.Protocol
 	asset := connOpts.authInfo.Asset
 	account := connOpts.authInfo.Account
@@ -66,7 +80,7 @@ func NewServer(conn UserConnection, jmsService *service.JMService, opts ...Conne
 	}
 	if !asset.IsSupportProtocol(protocol) {
 		msg := lang.T("Account <%s> and asset <%s> protocol are inconsistent.")
-		msg = fmt.Sprintf(msg, account.Username, asset.Address)
+		msg = fmt.Sprintf(msg, account.Username, common.DisplayHost(asset.Address))
 		utils.IgnoreErrWriteString(conn, utils.WrapperWarn(msg))
 		return nil, fmt.Errorf("%w: %s", ErrUnMatchProtocol, msg)
 	}
@@ -93,6 +107,9 @@ func NewServer(conn UserConnection, jmsService *service.JMService, opts ...Conne
 		OrgID:      connOpts.authInfo.OrgId,
 		Type:       model.NORMALType,
 	}
+	if connOpts.dynamicHost {
+		apiSession.Type = model.DYNAMICType
+	}
 
 	if !connOpts.authInfo.Actions.EnableConnect() {
 		msg := lang.T("You don't have permission login %s")
@@ -114,6 +131,9 @@ func NewServer(conn UserConnection, jmsService *service.JMService, opts ...Conne
 		CreateSessionCallback: func() error {
 			apiSession.DateStart = modelCommon.NewNowUTCTime()
 			_, err2 := jmsService.CreateSession(*apiSession)
+			if err2 == nil {
+				notifySessionWebhook(WebhookSessionStart, apiSession, nil, "")
+			}
 			return err2
 		},
 		ConnectedSuccessCallback: func() error {
@@ -122,8 +142,10 @@ func NewServer(conn UserConnection, jmsService *service.JMService, opts ...Conne
 		ConnectedFailedCallback: func(err error) error {
 			return jmsService.SessionFailed(apiSession.ID, err)
 		},
-		DisConnectedCallback: func() error {
-			return jmsService.SessionDisconnect(apiSession.ID)
+		DisConnectedCallback: func(reason model.SessionExitReason) error {
+			dateEnd := modelCommon.NewNowUTCTime()
+			notifySessionWebhook(WebhookSessionEnd, apiSession, &dateEnd, reason)
+			return jmsService.SessionFinished(apiSession.ID, dateEnd, reason)
 		},
 	}, nil
 }
@@ -145,12 +167,19 @@ type Server struct {
 
 	sessionInfo *model.Session
 
+	replayPolicy replayPolicy
+	// 用户确认连接时命中的 CONFIRM_ASSET_RULES 规则
+	confirmedRule string
+	// 连接审批通过的工单和处理人
+	approvedTicket string
+	approvedBy     string
+
 	cacheSSHConnection *srvconn.SSHConnection
 
 	CreateSessionCallback    func() error
 	ConnectedSuccessCallback func() error
 	ConnectedFailedCallback  func(err error) error
-	DisConnectedCallback     func() error
+	DisConnectedCallback     func(reason model.SessionExitReason) error
 
 	keyboardMode int32
 
@@ -183,7 +212,11 @@ func (s *Server) CheckPermissionExpired(now time.Time) bool {
 	return s.connOpts.authInfo.ExpireAt.IsExpired(now)
 }
 
+const zmodemPolicyBlock = "block"
+
 func (s *Server) ZmodemFileTransferEvent(zinfo *zmodem.ZFileInfo, status bool) {
+	logger.Infof("Session[%s] zmodem %s file %s success: %v", s.ID, zinfo.Type(),
+		zinfo.Filename(), status)
 	protocol := s.connOpts.authInfo.Protocol
 	asset := s.connOpts.authInfo.Asset
 	user := s.connOpts.authInfo.User
@@ -226,13 +259,18 @@ func (s *Server) GetFilterParser() *Parser {
 	if actions.EnableUpload() {
 		enableUpload = true
 	}
+	// 禁止 zmodem 时与无上传下载权限的处理方式一致，中断传输并提示
+	zmodemBlocked := strings.EqualFold(config.GetConf().ZmodemPolicy, zmodemPolicyBlock)
+	if zmodemBlocked {
+		enableUpload = false
+		enableDownload = false
+	}
 	zParser := zmodem.New()
 	zParser.FileEventCallback = s.ZmodemFileTransferEvent
 	protocol := s.connOpts.authInfo.Protocol
-	filterRules := s.connOpts.authInfo.CommandFilterACLs
+	// 过滤规则排序，合并本地规则文件中的规则
+	filterRules := CommandFilterACLs(s.connOpts.authInfo.CommandFilterACLs)
 	platform := s.connOpts.authInfo.Platform
-	// 过滤规则排序
-	sort.Sort(model.CommandACLs(filterRules))
 	parser := Parser{
 		id:             s.ID,
 		protocolType:   protocol,
@@ -240,9 +278,19 @@ func (s *Server) GetFilterParser() *Parser {
 		cmdFilterACLs:  filterRules,
 		enableDownload: enableDownload,
 		enableUpload:   enableUpload,
+		zmodemBlocked:  zmodemBlocked,
 		zmodemParser:   zParser,
 		i18nLang:       s.connOpts.i18nLang,
 		platform:       &platform,
+		filterPreview:  config.GetConf().CommandFilterPreview,
+		paste:          pasteGuard{policy: normalizePastePolicy(config.GetConf().PastePolicy)},
+		title:          titleGuard{policy: normalizeTitlePolicy(config.GetConf().TitleSequencePolicy)},
+		bell:           bellGuard{policy: s.bellPolicy(), threshold: config.GetConf().BellAuditThreshold},
+		lineEnding:     newLineEndingConverter(s.lineEndingMode()),
+		outputMask:     replayMaskPatterns(),
+	}
+	if parser.filterPreview {
+		logger.Infof("Session %s: command filter preview mode enabled", s.ID)
 	}
 	parser.initial()
 	return &parser
@@ -255,12 +303,14 @@ func (s *Server) GetReplayRecorder() *ReplyRecorder {
 		Height:    pty.Window.Height,
 		TimeStamp: time.Now(),
 	}
-	recorder, err := NewReplayRecord(s.ID, s.jmsService,
-		NewReplayStorage(s.jmsService, s.terminalConf),
-		info)
+	recorder, err := NewReplayRecord(s.ID, s.jmsService, s.getReplayStorage(), info)
 	if err != nil {
 		logger.Error(err)
 	}
+	if s.replayPolicy.action == replayPolicyForce && recorder.maxOutputSize > 0 {
+		// 强制录像时超出大小限制只截断命令的输出，不停止录像
+		recorder.limitPolicy = ReplayLimitPolicyTruncate
+	}
 	return recorder
 }
 
@@ -394,7 +444,7 @@ func (s *Server) checkRequiredAuth() error {
 				s.UserConn.ID(), loginAccount.Name, asset.Name)
 		}
 
-		if s.account.Secret == "" {
+		if s.account.Secret == "" && !s.clientAgentForwarded() {
 			if err := s.getAuthPasswordIfNeed(); err != nil {
 				msg := utils.WrapperWarn(lang.T("Get auth password failed"))
 				utils.IgnoreErrWriteString(s.UserConn, msg)
@@ -418,7 +468,7 @@ func (s *Server) checkReuseSSHClient() bool {
 		platformMatched := strings.EqualFold(platform.Type.Value, linuxPlatform)
 		protocolMatched := protocol == model.ProtocolSSH
 		notSuSystemUser := s.suFromAccount == nil
-		return platformMatched && protocolMatched && notSuSystemUser
+		return platformMatched && protocolMatched && notSuSystemUser && !s.clientAgentRequired()
 	}
 	return false
 }
@@ -457,7 +507,7 @@ func (s *Server) getCacheSSHConn() (srvConn *srvconn.SSHConnection, ok bool) {
 		loginAccount.Name, asset.Address, sshClient.RefCount())
 	utils.IgnoreErrWriteString(s.UserConn, reuseMsg+"\r\n")
 	go func() {
-		_ = sess.Wait()
+		_ = cacheConn.Wait()
 		sshClient.ReleaseSession(sess)
 		logger.Infof("Reuse SSH client(%s) shell connection release", sshClient)
 		srvconn.ReleaseClientCacheKey(key, sshClient)
@@ -486,7 +536,7 @@ func (s *Server) createAvailableGateWay(domain *model.Domain) (*domainGateway, e
 		port := asset.ProtocolPort(protocol)
 		dGateway = &domainGateway{
 			domain:          domain,
-			dstIP:           asset.Address,
+			dstIP:           common.TrimHostBrackets(asset.Address),
 			dstPort:         port,
 			selectedGateway: s.gateway,
 		}
@@ -533,15 +583,24 @@ func (s *Server) getContainerConn(clusterServer string) (
 		Width:  pty.Window.Width,
 		Height: pty.Window.Height,
 	}
-	opts := make([]srvconn.ContainerOption, 0, 5)
+	opts := make([]srvconn.ContainerOption, 0, 7)
 	opts = append(opts, srvconn.ContainerHost(clusterServer))
 	opts = append(opts, srvconn.ContainerToken(token))
-	opts = append(opts, srvconn.ContainerName(info.Container))
 	opts = append(opts, srvconn.ContainerPodName(info.PodName))
 	opts = append(opts, srvconn.ContainerNamespace(info.Namespace))
 	opts = append(opts, srvconn.ContainerSkipTls(true))
+	if err = s.chooseContainer(info, opts); err != nil {
+		return nil, err
+	}
+	opts = append(opts, srvconn.ContainerName(info.Container))
+	opts = append(opts, srvconn.ContainerShell(info.Shell))
 	opts = append(opts, srvconn.ContainerPtyWin(win))
 	srvConn, err = srvconn.NewContainerConnection(opts...)
+	if err == nil && info.Shell != "" && srvConn.Shell() != info.Shell {
+		lang := s.connOpts.getLang()
+		msg := fmt.Sprintf(lang.T("Shell %s not found in the container, use %s instead"), info.Shell, srvConn.Shell())
+		utils.IgnoreErrWriteString(s.UserConn, utils.WrapperWarn(msg))
+	}
 	return
 }
 
@@ -549,15 +608,16 @@ func (s *Server) getRedisConn(localTunnelAddr *net.TCPAddr) (srvConn *srvconn.Re
 	asset := s.connOpts.authInfo.Asset
 	protocol := s.connOpts.authInfo.Protocol
 	platform := s.connOpts.authInfo.Platform
-	host := asset.Address
+	host := common.TrimHostBrackets(asset.Address)
 	port := asset.ProtocolPort(protocol)
 	if localTunnelAddr != nil {
 		host = "127.0.0.1"
 		port = localTunnelAddr.Port
 	}
+	// 平台没有要求用户名时也使用账号的用户名认证 Redis 6 ACL 用户，不支持时只使用密码
 	username := s.account.Username
 	protocolSetting := platform.GetProtocol("redis")
-	if s.account.IsNull() || !protocolSetting.Setting.AuthUsername {
+	if s.account.IsNull() {
 		username = ""
 	}
 	srvConn, err = srvconn.NewRedisConnection(
@@ -570,6 +630,9 @@ func (s *Server) getRedisConn(localTunnelAddr *net.TCPAddr) (srvConn *srvconn.Re
 		srvconn.SqlCaCert(asset.SecretInfo.CaCert),
 		srvconn.SqlClientCert(asset.SecretInfo.ClientCert),
 		srvconn.SqlCertKey(asset.SecretInfo.ClientKey),
+		srvconn.SqlRedisUserOptional(!protocolSetting.Setting.AuthUsername),
+		// 通过网关隧道连接时重定向的节点地址无法访问，只显示 MOVED 和 ASK 错误
+		srvconn.SqlRedisClusterRedirect(localTunnelAddr == nil),
 		srvconn.SqlPtyWin(srvconn.Windows{
 			Width:  s.UserConn.Pty().Window.Width,
 			Height: s.UserConn.Pty().Window.Height,
@@ -581,7 +644,7 @@ func (s *Server) getRedisConn(localTunnelAddr *net.TCPAddr) (srvConn *srvconn.Re
 func (s *Server) getMongoDBConn(localTunnelAddr *net.TCPAddr) (srvConn *srvconn.MongoDBConn, err error) {
 	asset := s.connOpts.authInfo.Asset
 	protocol := s.connOpts.authInfo.Protocol
-	host := asset.Address
+	host := common.TrimHostBrackets(asset.Address)
 	port := asset.ProtocolPort(protocol)
 	if localTunnelAddr != nil {
 		host = "127.0.0.1"
@@ -609,7 +672,7 @@ func (s *Server) getMongoDBConn(localTunnelAddr *net.TCPAddr) (srvConn *srvconn.
 func (s *Server) getClickHouseConn(localTunnelAddr *net.TCPAddr) (srvConn *srvconn.ClickHouseConn, err error) {
 	asset := s.connOpts.authInfo.Asset
 	protocol := s.connOpts.authInfo.Protocol
-	host := asset.Address
+	host := common.TrimHostBrackets(asset.Address)
 	port := asset.ProtocolPort(protocol)
 	if localTunnelAddr != nil {
 		host = "127.0.0.1"
@@ -629,6 +692,16 @@ func (s *Server) getClickHouseConn(localTunnelAddr *net.TCPAddr) (srvConn *srvco
 	return
 }
 
+// getAllowedEnv 客户端发送的环境变量中允许转发给资产的部分
+func (s *Server) getAllowedEnv() map[string]string {
+	allowlist := s.terminalConf.EnvAllowlist
+	conn, ok := s.UserConn.(environConnection)
+	if !ok || len(allowlist) == 0 {
+		return nil
+	}
+	return ForwardEnv(conn.Environ(), allowlist)
+}
+
 func (s *Server) getSSHConn() (srvConn *srvconn.SSHConnection, err error) {
 	loginAccount := s.account.GetBaseAccount()
 	if s.suFromAccount != nil {
@@ -639,16 +712,19 @@ func (s *Server) getSSHConn() (srvConn *srvconn.SSHConnection, err error) {
 	user := s.connOpts.authInfo.User
 	key := srvconn.MakeReuseSSHClientKey(user.ID, asset.ID,
 		loginAccount.ID, asset.Address, loginAccount.HashId())
-	timeout := config.GlobalConfig.SSHTimeout
+	timeout := config.GetConf().SSHTimeout
 	sshAuthOpts := make([]srvconn.SSHClientOption, 0, 6)
 	sshAuthOpts = append(sshAuthOpts, srvconn.SSHClientUsername(loginAccount.Username))
-	sshAuthOpts = append(sshAuthOpts, srvconn.SSHClientHost(asset.Address))
+	sshAuthOpts = append(sshAuthOpts, srvconn.SSHClientHost(common.TrimHostBrackets(asset.Address)))
 	sshAuthOpts = append(sshAuthOpts, srvconn.SSHClientPort(asset.ProtocolPort(protocol)))
 	sshAuthOpts = append(sshAuthOpts, srvconn.SSHClientTimeout(timeout))
 	if loginAccount.IsSSHKey() {
 		if signer, err1 := gossh.ParsePrivateKey([]byte(loginAccount.Secret)); err1 == nil {
 			sshAuthOpts = append(sshAuthOpts, srvconn.SSHClientPrivateAuth(signer))
 		}
+		if loginAccount.Certificate != "" {
+			sshAuthOpts = append(sshAuthOpts, srvconn.SSHClientCertificate(loginAccount.Certificate))
+		}
 	} else {
 		sshAuthOpts = append(sshAuthOpts, srvconn.SSHClientPassword(loginAccount.Secret))
 	}
@@ -680,16 +756,33 @@ func (s *Server) getSSHConn() (srvConn *srvconn.SSHConnection, err error) {
 		return ans, nil
 	})
 	sshAuthOpts = append(sshAuthOpts, kb)
-	// 获取网关配置
-	proxyArgs := s.getGatewayProxyOptions()
-	if proxyArgs != nil {
+	agentAuth, agentChannel := s.openClientAgent()
+	if agentAuth != nil {
+		sshAuthOpts = append(sshAuthOpts, srvconn.SSHClientAgentAuth(agentAuth))
+		key = s.clientAgentCacheKey(key)
+	}
+	// 获取网关配置，多级网关优先，然后是 SOCKS5 代理，其次是 HTTP CONNECT 代理
+	if hops := s.getGatewayChainOptions(); hops != nil {
+		sshAuthOpts = append(sshAuthOpts, srvconn.SSHClientGatewayChain(hops...))
+	} else if socks5Arg := s.getGatewaySocks5Proxy(); socks5Arg != nil {
+		sshAuthOpts = append(sshAuthOpts, srvconn.SSHClientSocks5Proxy(*socks5Arg))
+	} else if httpArg := s.getGatewayHTTPProxy(); httpArg != nil {
+		sshAuthOpts = append(sshAuthOpts, srvconn.SSHClientHTTPProxy(*httpArg))
+	} else if proxyArgs := s.getGatewayProxyOptions(); proxyArgs != nil {
 		sshAuthOpts = append(sshAuthOpts, srvconn.SSHClientProxyClient(proxyArgs...))
 	}
 	sshClient, err := srvconn.NewSSHClient(sshAuthOpts...)
+	if agentChannel != nil {
+		// 认证结束后不再需要 agent
+		_ = agentChannel.Close()
+	}
 	if err != nil {
 		logger.Errorf("Get new ssh client err: %s", err)
 		return nil, err
 	}
+	if agentAuth != nil && agentAuth.UsedKey() != nil {
+		s.recordClientAgentAuth(agentAuth.UsedKey())
+	}
 	srvconn.AddClientCache(key, sshClient)
 	sess, err := sshClient.AcquireSession()
 	if err != nil {
@@ -707,6 +800,10 @@ func (s *Server) getSSHConn() (srvConn *srvconn.SSHConnection, err error) {
 		Height: pty.Window.Height,
 	}))
 
+	if env := s.getAllowedEnv(); len(env) > 0 {
+		sshConnectOpts = append(sshConnectOpts, srvconn.SSHEnv(env))
+	}
+
 	if s.suFromAccount != nil {
 		/*
 			suFromAccount 是 switch user
@@ -742,7 +839,7 @@ func (s *Server) getSSHConn() (srvConn *srvconn.SSHConnection, err error) {
 			loginAccount, s.account)
 	}
 	go func() {
-		_ = sess.Wait()
+		_ = sshConn.Wait()
 		sshClient.ReleaseSession(sess)
 		logger.Infof("SSH client(%s) shell connection release", sshClient)
 		srvconn.ReleaseClientCacheKey(key, sshClient)
@@ -756,7 +853,7 @@ func (s *Server) getTelnetConn() (srvConn *srvconn.TelnetConnection, err error)
 		loginAccount = s.suFromAccount
 	}
 	telnetOpts := make([]srvconn.TelnetOption, 0, 8)
-	timeout := config.GlobalConfig.SSHTimeout
+	timeout := config.GetConf().SSHTimeout
 	pty := s.UserConn.Pty()
 	protocol := s.connOpts.authInfo.Protocol
 	asset := s.connOpts.authInfo.Asset
@@ -795,7 +892,7 @@ func (s *Server) getTelnetConn() (srvConn *srvconn.TelnetConnection, err error)
 		telnetOpts = append(telnetOpts, srvconn.TelnetCustomSuccessPattern(successPattern))
 	}
 
-	telnetOpts = append(telnetOpts, srvconn.TelnetHost(asset.Address))
+	telnetOpts = append(telnetOpts, srvconn.TelnetHost(common.TrimHostBrackets(asset.Address)))
 	telnetOpts = append(telnetOpts, srvconn.TelnetPort(asset.ProtocolPort(protocol)))
 	telnetOpts = append(telnetOpts, srvconn.TelnetUsername(loginAccount.Username))
 	telnetOpts = append(telnetOpts, srvconn.TelnetUPassword(loginAccount.Secret))
@@ -807,8 +904,9 @@ func (s *Server) getTelnetConn() (srvConn *srvconn.TelnetConnection, err error)
 	charset := s.getCharset()
 	telnetOpts = append(telnetOpts, srvconn.TelnetCharset(charset))
 	// 获取网关配置
-	proxyArgs := s.getGatewayProxyOptions()
-	if proxyArgs != nil {
+	if hops := s.getGatewayChainOptions(); hops != nil {
+		telnetOpts = append(telnetOpts, srvconn.TelnetGatewayChain(hops))
+	} else if proxyArgs := s.getGatewayProxyOptions(); proxyArgs != nil {
 		telnetOpts = append(telnetOpts, srvconn.TelnetProxyOptions(proxyArgs))
 	}
 	if s.suFromAccount != nil {
@@ -841,14 +939,63 @@ func (s *Server) getTelnetConn() (srvConn *srvconn.TelnetConnection, err error)
 	return tcon, nil
 }
 
+// getGatewaySocks5Proxy 获取 SOCKS5 类型的网关，未配置时返回 nil
+func (s *Server) getGatewaySocks5Proxy() *srvconn.Socks5ProxyOptions {
+	if s.gateway != nil {
+		if !s.gateway.IsSocks5() {
+			return nil
+		}
+		socks5Arg := srvconn.NewSocks5ProxyOptions(s.gateway)
+		return &socks5Arg
+	}
+	if s.domainGateways != nil {
+		for i := range s.domainGateways.Gateways {
+			if gateway := &s.domainGateways.Gateways[i]; gateway.IsSocks5() {
+				socks5Arg := srvconn.NewSocks5ProxyOptions(gateway)
+				return &socks5Arg
+			}
+		}
+	}
+	return nil
+}
+
+// getGatewayHTTPProxy 获取 HTTP CONNECT 类型的网关，未配置时返回 nil
+func (s *Server) getGatewayHTTPProxy() *srvconn.HTTPProxyOptions {
+	if s.gateway != nil {
+		if !s.gateway.IsHTTPProxy() {
+			return nil
+		}
+		httpArg := srvconn.NewHTTPProxyOptions(s.gateway)
+		return &httpArg
+	}
+	if s.domainGateways != nil {
+		for i := range s.domainGateways.Gateways {
+			if gateway := &s.domainGateways.Gateways[i]; gateway.IsHTTPProxy() {
+				httpArg := srvconn.NewHTTPProxyOptions(gateway)
+				return &httpArg
+			}
+		}
+	}
+	return nil
+}
+
+// getGatewayChainOptions 多级网关只支持 ssh 和 telnet 资产
+func (s *Server) getGatewayChainOptions() []srvconn.SSHClientOptions {
+	chain := s.connOpts.authInfo.GatewayChain
+	if len(chain) == 0 {
+		return nil
+	}
+	return srvconn.NewGatewayChainOptions(chain, config.GetConf().SSHTimeout)
+}
+
 func (s *Server) getGatewayProxyOptions() []srvconn.SSHClientOptions {
 	// 仅有一个网关的情况
 	if s.gateway != nil {
-		timeout := config.GlobalConfig.SSHTimeout
+		timeout := config.GetConf().SSHTimeout
 		port := s.gateway.Protocols.GetProtocolPort(model.ProtocolSSH)
 		loginAccount := s.gateway.Account
 		proxyArg := srvconn.SSHClientOptions{
-			Host:     s.gateway.Address,
+			Host:     common.TrimHostBrackets(s.gateway.Address),
 			Port:     strconv.Itoa(port),
 			Username: s.gateway.Account.Username,
 			Timeout:  timeout,
@@ -862,14 +1009,17 @@ func (s *Server) getGatewayProxyOptions() []srvconn.SSHClientOptions {
 	}
 	// 多个网关的情况
 	if s.domainGateways != nil && len(s.domainGateways.Gateways) != 0 {
-		timeout := config.GlobalConfig.SSHTimeout
+		timeout := config.GetConf().SSHTimeout
 		proxyArgs := make([]srvconn.SSHClientOptions, 0, len(s.domainGateways.Gateways))
 		for i := range s.domainGateways.Gateways {
 			gateway := s.domainGateways.Gateways[i]
+			if gateway.IsSocks5() || gateway.IsHTTPProxy() {
+				continue
+			}
 			loginAccount := gateway.Account
 			port := gateway.Protocols.GetProtocolPort(model.ProtocolSSH)
 			proxyArg := srvconn.SSHClientOptions{
-				Host:     gateway.Address,
+				Host:     common.TrimHostBrackets(gateway.Address),
 				Port:     strconv.Itoa(port),
 				Username: loginAccount.Username,
 				Timeout:  timeout,
@@ -976,6 +1126,14 @@ func (s *Server) Proxy() {
 		logger.Errorf("Conn[%s]: check basic auth failed: %s", s.UserConn.ID(), err)
 		return
 	}
+	if !s.confirmConnectIfNeed() {
+		return
+	}
+	if !s.approveConnectIfNeed() {
+		return
+	}
+	s.getSessionNoteIfNeed()
+	s.applySessionTags()
 	defer func() {
 		if s.cacheSSHConnection != nil {
 			_ = s.cacheSSHConnection.Close()
@@ -985,17 +1143,89 @@ func (s *Server) Proxy() {
 	ctx, cancel := context.WithCancel(context.Background())
 	maxIdleTime := s.terminalConf.MaxIdleTime
 	maxSessionTime := time.Now().Add(time.Duration(s.terminalConf.MaxSessionTime) * time.Hour)
+	keepAliveTime := 60
+	if s.terminalConf.KeepAliveInterval > 0 {
+		keepAliveTime = s.terminalConf.KeepAliveInterval
+	}
+	// 与用户一侧相同，core 没有下发时使用 RETRY_ALIVE_COUNT_MAX
+	keepAliveCountMax := config.GetConf().RetryAliveCountMax
+	if s.terminalConf.KeepAliveCountMax > 0 {
+		keepAliveCountMax = s.terminalConf.KeepAliveCountMax
+	}
 	sw := SwitchSession{
-		ID:            s.ID,
-		MaxIdleTime:   maxIdleTime,
-		keepAliveTime: 60,
-		ctx:           ctx,
-		cancel:        cancel,
-		p:             s,
-		notifyMsgChan: make(chan *exchange.RoomMessage, 1),
+		ID:                s.ID,
+		MaxIdleTime:       maxIdleTime,
+		IdleTimeout:       s.terminalConf.IdleTimeout,
+		keepAliveTime:     keepAliveTime,
+		keepAliveCountMax: keepAliveCountMax,
+		inputRateLimit:    config.GetConf().InputRateLimit,
+		escapeSequence:    config.GetConf().EscapeSequence,
+		scrollbackKey:     scrollbackKey(),
+		scrollbackLines:   config.GetConf().ScrollbackLines,
+		ctx:               ctx,
+		cancel:            cancel,
+		p:                 s,
+		notifyMsgChan:     make(chan *exchange.RoomMessage, 1),
 
 		MaxSessionTime: maxSessionTime,
+
+		startTime:   time.Now(),
+		maxDuration: s.sessionMaxDuration(),
+	}
+	if s.connOpts.commandSummary {
+		sw.summary = newCommandSummary(s.connOpts.summaryTimezone)
 	}
+	if sw.maxDuration > 0 {
+		sw.maxDurationGrace = maxDurationGrace(sw.maxDuration)
+		logger.Infof("Conn[%s] session %s max duration %s", s.UserConn.ID(), s.ID, sw.maxDuration)
+	}
+	if interval := config.GetConf().LatencyCheckInterval; interval > 0 && s.latencySupported() {
+		sw.latencyInterval = time.Duration(interval) * time.Second
+		sw.latencyThreshold = latencyWarnThreshold()
+	}
+	traceSession := session.NewSession(sw.p.sessionInfo, func(task *model.TerminalTask) error {
+		switch task.Name {
+		case model.TaskKillSession:
+			sw.Terminate(task.Kwargs.TerminatedBy)
+		case model.TaskLockSession:
+			sw.PauseOperation(task.Kwargs.CreatedByUser)
+		case model.TaskUnlockSession:
+			sw.ResumeOperation(task.Kwargs.CreatedByUser)
+		case model.TaskBroadcastMessage:
+			sw.BroadcastMessage(task.Kwargs.CreatedByUser, task.Args)
+		case model.TaskShutdownNotice:
+			seconds, _ := strconv.Atoi(task.Args)
+			sw.ShutdownNotice(seconds)
+		case model.TaskShutdownSession:
+			sw.Shutdown()
+		default:
+			return fmt.Errorf("ssh session unknown task %s", task.Name)
+		}
+		return nil
+	})
+	assetLimit, _ := s.connOpts.authInfo.Asset.MaxSessions()
+	err := addLimitedSession(traceSession, assetSessionLimit{limit: assetLimit})
+	var assetErr *AssetSessionLimitError
+	if errors.As(err, &assetErr) && config.GetConf().AssetSessionQueue {
+		err = s.waitAssetSessionSlot(traceSession, assetLimit)
+	}
+	if err != nil {
+		var limitErr *SessionLimitError
+		switch {
+		case errors.As(err, &limitErr):
+			msg := fmt.Sprintf(lang.T("You have reached the limit of %d concurrent %s sessions"),
+				limitErr.Limit, limitErr.Key)
+			utils.IgnoreErrWriteString(s.UserConn, utils.WrapperWarn(msg))
+		case errors.As(err, &assetErr):
+			msg := fmt.Sprintf(lang.T("Asset %s has reached the limit of %d concurrent sessions"),
+				s.connOpts.authInfo.Asset.Name, assetErr.Limit)
+			utils.IgnoreErrWriteString(s.UserConn, utils.WrapperWarn(msg))
+		}
+		logger.Errorf("Conn[%s] user %s refuse session %s: %s",
+			s.UserConn.ID(), s.connOpts.authInfo.User.String(), s.ID, err)
+		return
+	}
+	defer session.RemoveSession(traceSession)
 	if err := s.CreateSessionCallback(); err != nil {
 		msg := lang.T("Connect with api server failed")
 		msg = utils.WrapperWarn(msg)
@@ -1013,27 +1243,25 @@ func (s *Server) Proxy() {
 			logger.Errorf("%s err: %s", msg, err)
 		}
 	}
-	traceSession := session.NewSession(sw.p.sessionInfo, func(task *model.TerminalTask) error {
-		switch task.Name {
-		case model.TaskKillSession:
-			sw.Terminate(task.Kwargs.TerminatedBy)
-		case model.TaskLockSession:
-			sw.PauseOperation(task.Kwargs.CreatedByUser)
-		case model.TaskUnlockSession:
-			sw.ResumeOperation(task.Kwargs.CreatedByUser)
-		default:
-			return fmt.Errorf("ssh session unknown task %s", task.Name)
-		}
-		return nil
-	})
-	session.AddSession(traceSession)
-	defer session.RemoveSession(traceSession)
+	var exitReason model.SessionExitReason
+	s.applyReplayPolicy()
+	s.recordConnectConfirm()
+	s.recordConnectApproval()
 	defer func() {
-		if err := s.DisConnectedCallback(); err != nil {
+		logger.Infof("Conn[%s] session %s exit reason: %s", s.UserConn.ID(), s.ID, exitReason)
+		if err := s.DisConnectedCallback(exitReason); err != nil {
 			logger.Errorf("Conn[%s] update session %s err: %+v", s.UserConn.ID(), s.ID, err)
 		}
 	}()
 	var proxyAddr *net.TCPAddr
+	if len(s.connOpts.authInfo.GatewayChain) > 0 && !s.isSSHOrTelnet() {
+		msg := lang.T("Gateway chain only supports ssh and telnet assets")
+		utils.IgnoreErrWriteString(s.UserConn, utils.WrapperWarn(msg))
+		logger.Errorf("Conn[%s] %s gateway chain not support protocol %s",
+			s.UserConn.ID(), s.ID, s.connOpts.authInfo.Protocol)
+		exitReason = model.ExitConnectFailed
+		return
+	}
 	if (s.domainGateways != nil && len(s.domainGateways.Gateways) != 0) || s.gateway != nil {
 		protocol := s.connOpts.authInfo.Protocol
 		switch protocol {
@@ -1046,6 +1274,7 @@ func (s *Server) Proxy() {
 				msg = fmt.Sprintf(msg, err)
 				utils.IgnoreErrWriteString(s.UserConn, utils.WrapperWarn(msg))
 				logger.Error(msg)
+				exitReason = model.ExitConnectFailed
 				return
 			}
 			err = dGateway.Start()
@@ -1054,27 +1283,42 @@ func (s *Server) Proxy() {
 				msg = fmt.Sprintf(msg, err)
 				utils.IgnoreErrWriteString(s.UserConn, utils.WrapperWarn(msg))
 				logger.Error(msg)
+				exitReason = model.ExitConnectFailed
 				return
 			}
 			defer dGateway.Stop()
 			proxyAddr = dGateway.GetListenAddr()
 		}
 	}
+	protocol := s.connOpts.authInfo.Protocol
+	connectStart := time.Now()
 	srvCon, err := s.getServerConn(proxyAddr)
+	metrics.ObserveConnectLatency(protocol, time.Since(connectStart))
 	if err != nil {
 		logger.Error(err)
 		s.sendConnectErrorMsg(err)
 		if err2 := s.ConnectedFailedCallback(err); err2 != nil {
 			logger.Errorf("Conn[%s] update session err: %s", s.UserConn.ID(), err2)
 		}
+		exitReason = model.ExitConnectFailed
 		return
 	}
+	if s.isAutoReconnect() {
+		srvCon = newReconnectConnection(s, srvCon, func() (srvconn.ServerConnection, error) {
+			return s.getServerConn(proxyAddr)
+		})
+	}
 	defer srvCon.Close()
+	metrics.SessionStarted(protocol)
+	defer metrics.SessionEnded(protocol)
 
 	logger.Infof("Conn[%s] create session %s success", s.UserConn.ID(), s.ID)
 	if err2 := s.ConnectedSuccessCallback(); err2 != nil {
 		logger.Errorf("Conn[%s] update session %s err: %s", s.UserConn.ID(), s.ID, err2)
 	}
+	if s.connOpts.onSuccess != nil {
+		s.connOpts.onSuccess()
+	}
 	if s.OnSessionInfo != nil {
 		actions := s.connOpts.authInfo.Actions
 		tokenConnOpts := s.connOpts.authInfo.ConnectOptions
@@ -1094,10 +1338,38 @@ func (s *Server) Proxy() {
 		}
 		go s.OnSessionInfo(&info)
 	}
+	s.displayConnectInfo()
+	s.displayCommandFilterNotice()
+	s.displayAssetMotd()
+	s.displayAssetLatency(srvCon)
 	utils.IgnoreErrWriteWindowTitle(s.UserConn, s.connOpts.TerminalTitle())
+	if conf := config.GetConf(); conf.SessionDetachGrace > 0 && s.UserConn.LoginFrom() == "ST" {
+		grace := time.Duration(conf.SessionDetachGrace) * time.Second
+		dc := s.persistentUserConn(grace, conf.SessionScrollbackSize)
+		defer dc.Close()
+		s.UserConn = dc
+	}
 	if err = sw.Bridge(s.UserConn, srvCon); err != nil {
 		logger.Error(err)
 	}
+	exitReason = sw.exitReason
+	s.displayCommandSummary(sw.summary)
+}
+
+// isAutoReconnect 仅 ssh 和 telnet 这类终端会话支持断线自动重连
+func (s *Server) isAutoReconnect() bool {
+	if !s.terminalConf.AutoReconnect {
+		return false
+	}
+	return s.isSSHOrTelnet()
+}
+
+func (s *Server) isSSHOrTelnet() bool {
+	switch s.connOpts.authInfo.Protocol {
+	case srvconn.ProtocolSSH, srvconn.ProtocolTELNET:
+		return true
+	}
+	return false
 }
 
 func (s *Server) sendConnectErrorMsg(err error) {
@@ -1128,20 +1400,15 @@ func ParseUrlHostAndPort(clusterAddr string) (host string, port int, err error)
 	if err != nil {
 		return "", 0, err
 	}
-	// URL host 是包含port的结果
-	hostAndPort := strings.Split(clusterUrl.Host, ":")
-	var (
-		dstHost string
-		dstPort int
-	)
-	dstHost = hostAndPort[0]
-	switch len(hostAndPort) {
-	case 2:
-		dstPort, err = strconv.Atoi(hostAndPort[1])
+	// Hostname 和 Port 会处理 IPv6 地址的方括号，如 https://[::1]:6443
+	dstHost := clusterUrl.Hostname()
+	var dstPort int
+	if rawPort := clusterUrl.Port(); rawPort != "" {
+		dstPort, err = strconv.Atoi(rawPort)
 		if err != nil {
 			return "", 0, fmt.Errorf("%w: %s", ErrInvalidPort, err)
 		}
-	default:
+	} else {
 		switch clusterUrl.Scheme {
 		case "https":
 			dstPort = 443
@@ -3,6 +3,7 @@ package proxy
 import (
 	"net"
 
+	"github.com/jumpserver/koko/pkg/common"
 	"github.com/jumpserver/koko/pkg/logger"
 	"github.com/jumpserver/koko/pkg/srvconn"
 )
@@ -10,13 +11,18 @@ import (
 func (s *Server) getMySQLConn(localTunnelAddr *net.TCPAddr) (srvConn *srvconn.MySQLConn, err error) {
 	asset := s.connOpts.authInfo.Asset
 	protocol := s.connOpts.authInfo.Protocol
-	host := asset.Address
+	host := common.TrimHostBrackets(asset.Address)
 	port := asset.ProtocolPort(protocol)
 	if localTunnelAddr != nil {
 		host = "127.0.0.1"
 		port = localTunnelAddr.Port
 	}
-	mysqlOpts := make([]srvconn.SqlOption, 0, 7)
+	tlsOpts, err := s.databaseTLSOptions(localTunnelAddr)
+	if err != nil {
+		return nil, err
+	}
+	mysqlOpts := make([]srvconn.SqlOption, 0, 7+len(tlsOpts))
+	mysqlOpts = append(mysqlOpts, tlsOpts...)
 	mysqlOpts = append(mysqlOpts, srvconn.SqlHost(host))
 	mysqlOpts = append(mysqlOpts, srvconn.SqlPort(port))
 	mysqlOpts = append(mysqlOpts, srvconn.SqlUsername(s.account.Username))
@@ -47,7 +53,7 @@ func (s *Server) getMySQLConn(localTunnelAddr *net.TCPAddr) (srvConn *srvconn.My
 func (s *Server) getSQLServerConn(localTunnelAddr *net.TCPAddr) (srvConn *srvconn.SQLServerConn, err error) {
 	asset := s.connOpts.authInfo.Asset
 	protocol := s.connOpts.authInfo.Protocol
-	host := asset.Address
+	host := common.TrimHostBrackets(asset.Address)
 	port := asset.ProtocolPort(protocol)
 	if localTunnelAddr != nil {
 		host = "127.0.0.1"
@@ -70,13 +76,19 @@ func (s *Server) getSQLServerConn(localTunnelAddr *net.TCPAddr) (srvConn *srvcon
 func (s *Server) getPostgreSQLConn(localTunnelAddr *net.TCPAddr) (srvConn *srvconn.PostgreSQLConn, err error) {
 	asset := s.connOpts.authInfo.Asset
 	protocol := s.connOpts.authInfo.Protocol
-	host := asset.Address
+	host := common.TrimHostBrackets(asset.Address)
 	port := asset.ProtocolPort(protocol)
 	if localTunnelAddr != nil {
 		host = "127.0.0.1"
 		port = localTunnelAddr.Port
 	}
-	srvConn, err = srvconn.NewPostgreSQLConnection(
+	tlsOpts, err := s.databaseTLSOptions(localTunnelAddr)
+	if err != nil {
+		return nil, err
+	}
+	pgOpts := make([]srvconn.SqlOption, 0, 6+len(tlsOpts))
+	pgOpts = append(pgOpts, tlsOpts...)
+	pgOpts = append(pgOpts,
 		srvconn.SqlHost(host),
 		srvconn.SqlPort(port),
 		srvconn.SqlUsername(s.account.Username),
@@ -87,5 +99,6 @@ func (s *Server) getPostgreSQLConn(localTunnelAddr *net.TCPAddr) (srvConn *srvco
 			Height: s.UserConn.Pty().Window.Height,
 		}),
 	)
+	srvConn, err = srvconn.NewPostgreSQLConnection(pgOpts...)
 	return
 }
@@ -2,7 +2,9 @@ package proxy
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/jumpserver/koko/pkg/common"
 	"github.com/jumpserver/koko/pkg/i18n"
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
 	"github.com/jumpserver/koko/pkg/srvconn"
@@ -34,6 +36,41 @@ func ConnectTokenAuthInfo(authInfo *model.ConnectToken) ConnectionOption {
 	}
 }
 
+func ConnectMacro(macro *model.ConnectMacro) ConnectionOption {
+	return func(opts *ConnectionOptions) {
+		opts.macro = macro
+	}
+}
+
+// ConnectDynamicHost 连接不在资产列表中的动态主机，会话记录为单独的类型
+func ConnectDynamicHost() ConnectionOption {
+	return func(opts *ConnectionOptions) {
+		opts.dynamicHost = true
+	}
+}
+
+// ConnectCommandSummary 会话结束后显示执行的命令摘要，命令时间使用用户的时区 loc
+func ConnectCommandSummary(loc *time.Location) ConnectionOption {
+	return func(opts *ConnectionOptions) {
+		opts.commandSummary = true
+		opts.summaryTimezone = loc
+	}
+}
+
+// ConnectBellPolicy 用户设置的响铃处理策略，为空时使用 BELL_POLICY
+func ConnectBellPolicy(policy string) ConnectionOption {
+	return func(opts *ConnectionOptions) {
+		opts.bellPolicy = policy
+	}
+}
+
+// ConnectOnSuccess 连接资产成功后调用，连接失败时不调用
+func ConnectOnSuccess(fn func()) ConnectionOption {
+	return func(opts *ConnectionOptions) {
+		opts.onSuccess = fn
+	}
+}
+
 type ConnectionOptions struct {
 	authInfo *model.ConnectToken
 
@@ -42,6 +79,19 @@ type ConnectionOptions struct {
 	k8sContainer *ContainerInfo
 
 	params *ConnectionParams
+
+	// 登录后自动执行的连接宏
+	macro *model.ConnectMacro
+
+	dynamicHost bool
+
+	// 会话结束后显示命令摘要
+	commandSummary  bool
+	summaryTimezone *time.Location
+
+	bellPolicy string
+
+	onSuccess func()
 }
 
 type ConnectionParams struct {
@@ -52,6 +102,8 @@ type ContainerInfo struct {
 	Namespace string
 	PodName   string
 	Container string
+	// Shell 优先使用的 shell，为空时依次尝试 bash、sh 等
+	Shell string
 }
 
 func (c *ContainerInfo) String() string {
@@ -88,7 +140,7 @@ func (opts *ConnectionOptions) TerminalTitle() string {
 		title = fmt.Sprintf("%s://%s@%s",
 			protocol,
 			account.Username,
-			asset.Address)
+			common.DisplayHost(asset.Address))
 	}
 	return title
 }
@@ -110,7 +162,7 @@ func (opts *ConnectionOptions) ConnectMsg() string {
 			accountName = fmt.Sprintf("%s(%s)", lang.T("Dynamic"), account.Username)
 		default:
 		}
-		msg = fmt.Sprintf(lang.T("Connecting to %s@%s"), accountName, asset.Address)
+		msg = fmt.Sprintf(lang.T("Connecting to %s@%s"), accountName, common.DisplayHost(asset.Address))
 	case srvconn.ProtocolClickHouse,
 		srvconn.ProtocolRedis, srvconn.ProtocolMongoDB,
 		srvconn.ProtocolMySQL, srvconn.ProtocolSQLServer, srvconn.ProtocolPostgresql:
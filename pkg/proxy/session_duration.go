@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"time"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+/*
+SESSION_MAX_DURATION 会话从开始起的最长分钟数，与是否空闲无关，超过后断开会话，0 表示不限制。
+资产的 max_session_duration 标签(分钟，0 表示不限制)优先于全局配置。
+断开前 SESSION_MAX_DURATION_GRACE 秒提示用户保存工作，到达最长时间时断开。
+*/
+
+const defaultMaxDurationGrace = 60
+
+// sessionMaxDuration 返回会话的最长时间，0 表示不限制
+func (s *Server) sessionMaxDuration() time.Duration {
+	minutes := config.GetConf().SessionMaxDuration
+	asset := &s.connOpts.authInfo.Asset
+	if value, ok := asset.MaxSessionDuration(); ok {
+		logger.Debugf("Conn[%s] asset %s max session duration %d minutes by label", s.UserConn.ID(), asset.String(), value)
+		minutes = value
+	}
+	if minutes <= 0 {
+		return 0
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// maxDurationGrace 提示的时间不超过会话的最长时间
+func maxDurationGrace(maxDuration time.Duration) time.Duration {
+	seconds := config.GetConf().SessionMaxDurationGrace
+	if seconds <= 0 {
+		seconds = defaultMaxDurationGrace
+	}
+	grace := time.Duration(seconds) * time.Second
+	if grace > maxDuration {
+		grace = maxDuration
+	}
+	return grace
+}
+
+// maxDurationTimers 返回提示和断开的定时器，没有限制时返回的 channel 为 nil
+func (s *SwitchSession) maxDurationTimers() (warn, expire <-chan time.Time, stop func()) {
+	if s.maxDuration <= 0 {
+		return nil, nil, func() {}
+	}
+	deadline := s.startTime.Add(s.maxDuration)
+	warnTimer := time.NewTimer(time.Until(deadline.Add(-s.maxDurationGrace)))
+	expireTimer := time.NewTimer(time.Until(deadline))
+	return warnTimer.C, expireTimer.C, func() {
+		warnTimer.Stop()
+		expireTimer.Stop()
+	}
+}
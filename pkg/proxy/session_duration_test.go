@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestSessionMaxDuration(t *testing.T) {
+	conf := config.GetConf()
+	conf.SessionMaxDuration = 480
+	conf.SessionMaxDurationGrace = 120
+	setTestConfig(t, &conf)
+
+	tests := []struct {
+		labels []model.Label
+		want   time.Duration
+	}{
+		{want: 8 * time.Hour},
+		{labels: []model.Label{{Name: model.AssetMaxDurationLabel, Value: "60"}}, want: time.Hour},
+		{labels: []model.Label{{Name: model.AssetMaxDurationLabel, Value: "0"}}, want: 0},
+		{labels: []model.Label{{Name: model.AssetMaxDurationLabel, Value: "2h"}}, want: 8 * time.Hour},
+	}
+	for _, tt := range tests {
+		authInfo := &model.ConnectToken{Asset: model.Asset{Name: "web", Labels: tt.labels}}
+		s := &Server{UserConn: newPipeUserConn("conn-1"), connOpts: &ConnectionOptions{authInfo: authInfo}}
+		if got := s.sessionMaxDuration(); got != tt.want {
+			t.Errorf("sessionMaxDuration() with labels %v = %s, want %s", tt.labels, got, tt.want)
+		}
+	}
+	if got := maxDurationGrace(time.Hour); got != 2*time.Minute {
+		t.Errorf("maxDurationGrace(1h) = %s, want 2m", got)
+	}
+	if got := maxDurationGrace(time.Minute); got != time.Minute {
+		t.Errorf("maxDurationGrace(1m) = %s, want the max duration", got)
+	}
+}
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/session"
+	"github.com/jumpserver/koko/pkg/srvconn"
+)
+
+// sessionLimitDB 所有数据库协议共用的限制
+const sessionLimitDB = "db"
+
+type sessionLimit struct {
+	Key   string
+	Limit int
+}
+
+// SessionLimitError 用户的会话数量达到了限制
+type SessionLimitError struct {
+	Key   string
+	Limit int
+}
+
+func (e *SessionLimitError) Error() string {
+	return fmt.Sprintf("reach the %s session limit %d", e.Key, e.Limit)
+}
+
+// parseSessionLimits 解析 "协议=数量" 格式的配置，数量小于等于 0 时不限制
+func parseSessionLimits(entries []string) []sessionLimit {
+	limits := make([]sessionLimit, 0, len(entries))
+	for _, entry := range entries {
+		key, value, ok := strings.Cut(entry, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || key == "" || err != nil {
+			logger.Errorf("Invalid user session limit config: %s", entry)
+			continue
+		}
+		if limit > 0 {
+			limits = append(limits, sessionLimit{Key: key, Limit: limit})
+		}
+	}
+	return limits
+}
+
+func isDBProtocol(protocol string) bool {
+	for _, p := range srvconn.SupportedDBProtocols() {
+		if p == protocol {
+			return true
+		}
+	}
+	return false
+}
+
+func (l sessionLimit) match(protocol string) bool {
+	protocol = strings.ToLower(protocol)
+	if l.Key == sessionLimitDB {
+		return isDBProtocol(protocol)
+	}
+	return l.Key == protocol
+}
+
+// checkSessionLimits 统计该用户的活跃会话，超过任一适用的限制时返回 SessionLimitError
+func checkSessionLimits(limits []sessionLimit, userID, protocol string, alive []*session.Session) error {
+	for _, limit := range limits {
+		if !limit.match(protocol) {
+			continue
+		}
+		count := 0
+		for _, sess := range alive {
+			if sess.UserID == userID && limit.match(sess.Protocol) {
+				count++
+			}
+		}
+		if count >= limit.Limit {
+			return &SessionLimitError{Key: limit.Key, Limit: limit.Limit}
+		}
+	}
+	return nil
+}
+
+/*
+addLimitedSession 记录会话到活跃会话列表，同时检查用户和资产的会话数量限制。
+会话数量来自活跃会话列表，会话结束时(包括连接异常断开)由 Proxy 中的 defer 移除，不会一直占用名额
+*/
+
+func addLimitedSession(sess *session.Session, assetLimit assetSessionLimit) error {
+	limits := parseSessionLimits(config.GetConf().UserSessionLimits)
+	if len(limits) == 0 && assetLimit.limit <= 0 {
+		session.AddSession(sess)
+		return nil
+	}
+	return session.AddSessionIf(sess, func(alive []*session.Session) error {
+		if err := checkSessionLimits(limits, sess.UserID, sess.Protocol, alive); err != nil {
+			return err
+		}
+		return checkAssetSessionLimit(assetLimit, sess, alive)
+	})
+}
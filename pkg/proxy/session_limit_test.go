@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/session"
+)
+
+func TestAddLimitedSession(t *testing.T) {
+	conf := config.GetConf()
+	conf.UserSessionLimits = []string{"ssh=2", "db=1", "k8s=0", "invalid"}
+	setTestConfig(t, &conf)
+
+	newSession := func(id, userID, protocol string) *session.Session {
+		return session.NewSession(&model.Session{ID: id, UserID: userID, Protocol: protocol}, nil)
+	}
+	first := newSession("limit-ssh-1", "user-1", "ssh")
+	second := newSession("limit-ssh-2", "user-1", "ssh")
+	for _, sess := range []*session.Session{first, second} {
+		if err := addLimitedSession(sess, assetSessionLimit{}); err != nil {
+			t.Fatalf("addLimitedSession(%s) = %s, want nil", sess.ID, err)
+		}
+		defer session.RemoveSession(sess)
+	}
+	var limitErr *SessionLimitError
+	third := newSession("limit-ssh-3", "user-1", "ssh")
+	if err := addLimitedSession(third, assetSessionLimit{}); !errors.As(err, &limitErr) || limitErr.Limit != 2 {
+		t.Fatalf("addLimitedSession() over limit = %v, want ssh limit 2", err)
+	}
+	if _, ok := session.GetSessionById(third.ID); ok {
+		t.Error("refused session added to alive sessions")
+	}
+	other := newSession("limit-ssh-other", "user-2", "ssh")
+	if err := addLimitedSession(other, assetSessionLimit{}); err != nil {
+		t.Errorf("addLimitedSession() other user = %s, want nil", err)
+	}
+	defer session.RemoveSession(other)
+
+	// 会话结束后释放名额
+	session.RemoveSession(first)
+	if err := addLimitedSession(third, assetSessionLimit{}); err != nil {
+		t.Errorf("addLimitedSession() after session ended = %s, want nil", err)
+	}
+	defer session.RemoveSession(third)
+
+	mysql := newSession("limit-mysql", "user-1", "mysql")
+	if err := addLimitedSession(mysql, assetSessionLimit{}); err != nil {
+		t.Fatalf("addLimitedSession(mysql, assetSessionLimit{}) = %s, want nil", err)
+	}
+	defer session.RemoveSession(mysql)
+	redis := newSession("limit-redis", "user-1", "redis")
+	if err := addLimitedSession(redis, assetSessionLimit{}); !errors.As(err, &limitErr) || limitErr.Key != "db" {
+		t.Errorf("addLimitedSession(redis, assetSessionLimit{}) = %v, want db limit", err)
+	}
+	k8s := newSession("limit-k8s", "user-1", "k8s")
+	if err := addLimitedSession(k8s, assetSessionLimit{}); err != nil {
+		t.Errorf("addLimitedSession(k8s, assetSessionLimit{}) = %s, want unlimited", err)
+	}
+	defer session.RemoveSession(k8s)
+}
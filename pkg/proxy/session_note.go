@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+// 会话备注的最大字符数
+const maxSessionNoteLength = 128
+
+/*
+getSessionNoteIfNeed 开启 SESSION_NOTE_PROMPT 时，连接资产前提示用户填写会话备注(如连接原因、工单号)，
+直接回车跳过。备注在创建会话时作为 comment 提交到 core，可以在会话列表中查看和搜索。
+*/
+
+func (s *Server) getSessionNoteIfNeed() {
+	if !config.GetConf().SessionNotePrompt {
+		return
+	}
+	lang := s.connOpts.getLang()
+	vt := term.NewTerminal(s.UserConn, lang.T("Session note (reason or ticket number, press Enter to skip): "))
+	line, err := vt.ReadLine()
+	if err != nil {
+		logger.Errorf("Conn[%s] read session note err: %s", s.UserConn.ID(), err)
+		return
+	}
+	note := truncateSessionNote(strings.TrimSpace(line))
+	if note == "" {
+		logger.Infof("Conn[%s] user skip session note", s.UserConn.ID())
+		return
+	}
+	s.sessionInfo.Comment = note
+	logger.Infof("Conn[%s] session %s note: %s", s.UserConn.ID(), s.ID, note)
+}
+
+func truncateSessionNote(note string) string {
+	// 去掉控制字符，避免影响会话列表的显示
+	note = strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, note)
+	if utf8.RuneCountInString(note) <= maxSessionNoteLength {
+		return note
+	}
+	return string([]rune(note)[:maxSessionNoteLength])
+}
@@ -0,0 +1,18 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateSessionNote(t *testing.T) {
+	if got := truncateSessionNote("INC-1024 磁盘告警\x1b[31m"); got != "INC-1024 磁盘告警[31m" {
+		t.Errorf("truncateSessionNote() = %q, control characters should be removed", got)
+	}
+	long := strings.Repeat("工单", maxSessionNoteLength)
+	got := truncateSessionNote(long)
+	if n := utf8.RuneCountInString(got); n != maxSessionNoteLength || !utf8.ValidString(got) {
+		t.Errorf("truncateSessionNote() length = %d, want %d valid runes", n, maxSessionNoteLength)
+	}
+}
@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/srvconn"
+)
+
+/*
+SESSION_TAG_ENVS 中的客户端环境变量作为会话标签保存到会话的审计记录，如 CI 任务 ID，
+不在列表中的变量忽略。标签值只保留安全的字符并限制长度，这些变量不会转发给资产。
+*/
+
+const defaultSessionTagMaxLength = 64
+
+// SessionTags 从客户端的环境变量(KEY=VALUE 格式)中获取会话标签，没有标签时返回 nil
+func SessionTags(environ []string) map[string]string {
+	tagEnvs := config.GetConf().SessionTagEnvs
+	if len(tagEnvs) == 0 {
+		return nil
+	}
+	var tags map[string]string
+	for name, value := range srvconn.FilterAllowedEnv(environ, tagEnvs) {
+		if value = sanitizeSessionTag(value); value == "" {
+			continue
+		}
+		if tags == nil {
+			tags = make(map[string]string)
+		}
+		tags[name] = value
+	}
+	return tags
+}
+
+// ForwardEnv 客户端的环境变量中允许转发给资产的部分，不包括会话标签
+func ForwardEnv(environ []string, allowlist []string) map[string]string {
+	env := srvconn.FilterAllowedEnv(environ, allowlist)
+	tagEnvs := config.GetConf().SessionTagEnvs
+	for name := range env {
+		if srvconn.IsEnvAllowed(name, tagEnvs) {
+			delete(env, name)
+		}
+	}
+	return env
+}
+
+// FormatSessionTags 按名称排序，用于日志
+func FormatSessionTags(tags map[string]string) string {
+	items := make([]string, 0, len(tags))
+	for name := range tags {
+		items = append(items, name+"="+tags[name])
+	}
+	sort.Strings(items)
+	return strings.Join(items, ", ")
+}
+
+func (s *Server) applySessionTags() {
+	conn, ok := s.UserConn.(environConnection)
+	if !ok {
+		return
+	}
+	if tags := SessionTags(conn.Environ()); len(tags) > 0 {
+		s.sessionInfo.Tags = tags
+		logger.Infof("Conn[%s] session %s tags: %s", s.UserConn.ID(), s.ID, FormatSessionTags(tags))
+	}
+}
+
+// sanitizeSessionTag 只保留字母、数字和 -_.:/@#+=, 字符，超过 SESSION_TAG_MAX_LENGTH 时截断
+func sanitizeSessionTag(value string) string {
+	maxLength := config.GetConf().SessionTagMaxLength
+	if maxLength <= 0 {
+		maxLength = defaultSessionTagMaxLength
+	}
+	value = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case strings.ContainsRune("-_.:/@#+=,", r):
+			return r
+		}
+		return -1
+	}, strings.TrimSpace(value))
+	if len(value) > maxLength {
+		value = value[:maxLength]
+	}
+	return value
+}
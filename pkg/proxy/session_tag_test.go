@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/config"
+)
+
+func TestSessionTags(t *testing.T) {
+	conf := config.GetConf()
+	conf.SessionTagEnvs = []string{"KOKO_TAG", "KOKO_CI_*"}
+	conf.SessionTagMaxLength = 16
+	setTestConfig(t, &conf)
+
+	environ := []string{
+		"KOKO_TAG=ci-1234",
+		"KOKO_CI_JOB= job 42\x1b[31m;$(rm)",
+		"KOKO_CI_URL=" + strings.Repeat("a", 20),
+		"KOKO_CI_EMPTY=$;",
+		"KOKO_OTHER=ignored",
+		"LANG=en_US.UTF-8",
+	}
+	want := map[string]string{
+		"KOKO_TAG":    "ci-1234",
+		"KOKO_CI_JOB": "job4231mrm",
+		"KOKO_CI_URL": strings.Repeat("a", 16),
+	}
+	if got := SessionTags(environ); !reflect.DeepEqual(got, want) {
+		t.Errorf("SessionTags() = %v, want %v", got, want)
+	}
+	// 会话标签不转发给资产
+	env := ForwardEnv(environ, []string{"KOKO_*", "LANG"})
+	if want := map[string]string{"KOKO_OTHER": "ignored", "LANG": "en_US.UTF-8"}; !reflect.DeepEqual(env, want) {
+		t.Errorf("ForwardEnv() = %v, want %v", env, want)
+	}
+	conf.SessionTagEnvs = nil
+	if got := SessionTags(environ); got != nil {
+		t.Errorf("SessionTags() without SESSION_TAG_ENVS = %v", got)
+	}
+}
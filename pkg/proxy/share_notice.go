@@ -0,0 +1,44 @@
+package proxy
+
+import (
+	"fmt"
+
+	"github.com/jumpserver/koko/pkg/exchange"
+	"github.com/jumpserver/koko/pkg/i18n"
+	"github.com/jumpserver/koko/pkg/utils"
+)
+
+// shareNoticeConn 其他用户加入或离开分享的会话时，在 SSH 终端中提示会话的所有者。
+// web 终端由前端根据 Share_JOIN 等事件提示，不需要额外写入终端
+type shareNoticeConn struct {
+	UserConnection
+	lang i18n.LanguageCode
+}
+
+func newShareNoticeConn(conn UserConnection, lang i18n.LanguageCode) UserConnection {
+	if conn.LoginFrom() != "ST" {
+		return conn
+	}
+	return &shareNoticeConn{UserConnection: conn, lang: lang}
+}
+
+func (c *shareNoticeConn) HandleRoomEvent(event string, msg *exchange.RoomMessage) {
+	if !msg.Meta.Primary {
+		var notice string
+		switch event {
+		case exchange.ShareJoin:
+			if msg.Meta.Writable {
+				notice = fmt.Sprintf(c.lang.T("%s joined the session"), msg.Meta.User)
+			} else {
+				notice = fmt.Sprintf(c.lang.T("%s joined the session (read-only)"), msg.Meta.User)
+			}
+		case exchange.ShareLeave:
+			notice = fmt.Sprintf(c.lang.T("%s left the session"), msg.Meta.User)
+		}
+		if notice != "" {
+			utils.IgnoreErrWriteString(c.UserConnection, utils.CharNewLine+
+				utils.WrapperString(notice, utils.Yellow)+utils.CharNewLine)
+		}
+	}
+	c.UserConnection.HandleRoomEvent(event, msg)
+}
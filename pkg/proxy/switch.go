@@ -4,27 +4,45 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"strings"
 	"sync/atomic"
 	"time"
 	"unicode/utf8"
 
+	"github.com/gliderlabs/ssh"
+
 	"github.com/jumpserver/koko/pkg/exchange"
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/common"
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
 	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/metrics"
 	"github.com/jumpserver/koko/pkg/srvconn"
 	"github.com/jumpserver/koko/pkg/utils"
 	"github.com/jumpserver/koko/pkg/zmodem"
 )
 
+// idleWarningCountdown 空闲警告后的倒计时秒数
+const idleWarningCountdown = 30
+
 type SwitchSession struct {
 	ID string
 
 	MaxIdleTime   int
+	IdleTimeout   int // 空闲多少秒后开始倒计时警告，0 表示不启用
 	keepAliveTime int
 
+	keepAliveCountMax int // 连续多少次 keepalive 无回复后断开，0 表示不断开
+
+	inputRateLimit int // 用户输入的速率上限(字节/秒)，0 表示不限制
+
+	escapeSequence string // 行首输入后强制结束会话返回菜单的序列，空表示不启用
+
+	scrollbackKey   []byte // 进入回滚模式的快捷键，空表示不启用
+	scrollbackLines int
+
 	ctx    context.Context
 	cancel context.CancelFunc
 
@@ -34,9 +52,25 @@ type SwitchSession struct {
 
 	pausedStatus atomic.Bool // 暂停状态
 
+	shutdown atomic.Bool // koko 停止服务导致的会话结束
+
 	notifyMsgChan chan *exchange.RoomMessage
 
 	MaxSessionTime time.Time
+
+	// 会话开始后的最长时间，与空闲无关，0 表示不限制
+	startTime        time.Time
+	maxDuration      time.Duration
+	maxDurationGrace time.Duration
+
+	// 会话中检测资产延迟的间隔，0 表示不检测
+	latencyInterval  time.Duration
+	latencyThreshold time.Duration
+
+	// 用户开启了命令摘要时收集会话的命令
+	summary *commandSummary
+
+	exitReason model.SessionExitReason // Bridge 结束时设置会话结束的原因
 }
 
 func (s *SwitchSession) Terminate(username string) {
@@ -50,6 +84,27 @@ func (s *SwitchSession) Terminate(username string) {
 	logger.Infof("Session[%s] receive terminate task from %s", s.ID, username)
 }
 
+// ShutdownNotice 提示用户 koko 即将停止服务，会话将在 seconds 秒后关闭
+func (s *SwitchSession) ShutdownNotice(seconds int) {
+	lang := s.p.connOpts.getLang()
+	msg := fmt.Sprintf(lang.T("KoKo is going to restart, this session will be closed in %d seconds, please save your work"), seconds)
+	msg = "\n\r" + utils.WrapperString(msg, utils.Yellow, true) + "\n\r"
+	logger.Infof("Session[%s] receive shutdown notice, close in %d seconds", s.ID, seconds)
+	select {
+	case <-s.ctx.Done():
+	case s.notifyMsgChan <- &exchange.RoomMessage{Event: exchange.DataEvent, Body: []byte(msg)}:
+	case <-time.After(time.Second):
+		logger.Errorf("Session[%s] send shutdown notice timeout", s.ID)
+	}
+}
+
+// Shutdown koko 停止服务时关闭会话
+func (s *SwitchSession) Shutdown() {
+	s.shutdown.Store(true)
+	s.cancel()
+	logger.Infof("Session[%s] closed by koko shutdown", s.ID)
+}
+
 func (s *SwitchSession) PauseOperation(username string) {
 	s.pausedStatus.Store(true)
 	s.setOperator(username)
@@ -72,6 +127,22 @@ func (s *SwitchSession) ResumeOperation(username string) {
 	}
 }
 
+// BroadcastMessage 在用户的输出中插入一行醒目的广播消息，不会发送给资产。
+// 消息如果是已翻译的文本，则按照会话的语言显示，否则原样显示
+func (s *SwitchSession) BroadcastMessage(username, text string) {
+	lang := s.p.connOpts.getLang()
+	msg := fmt.Sprintf(lang.T("Broadcast message from admin %s: %s"), username, lang.T(text))
+	msg = "\n\r" + utils.WrapperString(msg, utils.Yellow, true) + "\n\r"
+	logger.Infof("Session[%s] receive broadcast message from %s", s.ID, username)
+	// 会话尚未开始转发或已结束时不阻塞广播方
+	select {
+	case <-s.ctx.Done():
+	case s.notifyMsgChan <- &exchange.RoomMessage{Event: exchange.DataEvent, Body: []byte(msg)}:
+	case <-time.After(time.Second):
+		logger.Errorf("Session[%s] send broadcast message timeout", s.ID)
+	}
+}
+
 func (s *SwitchSession) setOperator(username string) {
 	s.currentOperator.Store(username)
 }
@@ -90,12 +161,18 @@ func (s *SwitchSession) filterUserInput(p []byte) []byte {
 func (s *SwitchSession) recordCommand(cmdRecordChan chan *ExecutedCommand) {
 	// 命令记录
 	cmdRecorder := s.p.GetCommandRecorder()
+	if s.summary != nil {
+		defer s.summary.finish()
+	}
 	for item := range cmdRecordChan {
 		if item.Command == "" {
 			continue
 		}
 		cmd := s.generateCommandResult(item)
 		cmdRecorder.Record(cmd)
+		if s.summary != nil {
+			s.summary.add(cmd)
+		}
 	}
 	// 关闭命令记录
 	cmdRecorder.End()
@@ -126,17 +203,47 @@ func (s *SwitchSession) generateCommandResult(item *ExecutedCommand) *model.Comm
 	return s.p.GenerateCommandItem(user, input, output, item)
 }
 
+func (s *SwitchSession) isKeepAliveExceeded(missCount int) bool {
+	return s.keepAliveCountMax > 0 && missCount >= s.keepAliveCountMax
+}
+
 // Bridge 桥接两个链接
-func (s *SwitchSession) Bridge(userConn UserConnection, srvConn srvconn.ServerConnection) (err error) {
+func (s *SwitchSession) runMacro(macro *macroRunner, done <-chan struct{}, send func([]byte)) {
+	name := macro.macro.Name
+	logger.Infof("Session[%s] run macro %s", s.ID, name)
+	err := macro.Run(done, send)
+	switch {
+	case err == nil:
+		logger.Infof("Session[%s] macro %s finished", s.ID, name)
+	case errors.Is(err, errMacroCanceled):
+		logger.Infof("Session[%s] macro %s canceled", s.ID, name)
+	default:
+		logger.Errorf("Session[%s] macro %s failed: %s", s.ID, name, err)
+		lang := s.p.connOpts.getLang()
+		msg := fmt.Sprintf(lang.T("Macro %s stopped: %s"), name, err)
+		select {
+		case s.notifyMsgChan <- &exchange.RoomMessage{Event: exchange.DataEvent,
+			Body: []byte("\n\r" + utils.WrapperWarn(msg))}:
+		case <-done:
+		}
+	}
+}
 
+func (s *SwitchSession) Bridge(userConn UserConnection, srvConn srvconn.ServerConnection) (err error) {
+	sessLogger := logger.NewSessionLogger(s.ID, s.p.connOpts.authInfo.Asset.String())
+	protocol := s.p.connOpts.authInfo.Protocol
 	parser := s.p.GetFilterParser()
-	logger.Infof("Conn[%s] create ParseEngine success", userConn.ID())
+	sessLogger.Infof("Conn[%s] create ParseEngine success", userConn.ID())
 	replayRecorder := s.p.GetReplayRecorder()
-	logger.Infof("Conn[%s] create replay success", userConn.ID())
+	sessLogger.Infof("Conn[%s] create replay success", userConn.ID())
 	srvInChan := make(chan []byte, 1)
 	done := make(chan struct{})
 	userInputMessageChan := make(chan *exchange.RoomMessage, 1)
 	// 处理数据流
+	keystrokeRecorder := s.p.GetKeystrokeRecorder()
+	lastOutput := newLastOutputBuffer(s.ID, s.p.connOpts.authInfo.User.ID)
+	scrollback := newScrollbackBuffer(s.scrollbackLines)
+	parser.SetKeystrokeRecorder(keystrokeRecorder)
 	userOutChan, srvOutChan := parser.ParseStream(userInputMessageChan, srvInChan)
 	parser.SetUserInputFilter(s.filterUserInput)
 
@@ -147,6 +254,8 @@ func (s *SwitchSession) Bridge(userConn UserConnection, srvConn srvconn.ServerCo
 		parser.Close()
 		// 关闭录像
 		replayRecorder.End()
+		keystrokeRecorder.End()
+		lastOutput.End()
 	}()
 
 	// 记录命令
@@ -158,17 +267,26 @@ func (s *SwitchSession) Bridge(userConn UserConnection, srvConn srvconn.ServerCo
 	lastActiveTime := time.Now()
 	tick := time.NewTicker(30 * time.Second)
 	defer tick.Stop()
+	idleTimeout := time.Duration(s.IdleTimeout) * time.Second
+	idleTick := time.NewTicker(time.Second)
+	if s.IdleTimeout <= 0 {
+		idleTick.Stop()
+	}
+	defer idleTick.Stop()
+	idleWarned := false
 
 	room := exchange.CreateRoom(s.ID, userInputMessageChan)
 	exchange.Register(room)
 	defer exchange.UnRegister(room)
-	conn := exchange.WrapperUserCon(userConn)
+	conn := exchange.WrapperUserCon(newShareNoticeConn(userConn, s.p.connOpts.getLang()))
 	room.Subscribe(conn)
 	defer room.UnSubscribe(conn)
-	exitSignal := make(chan struct{}, 2)
+	// 读取结束时发送会话结束的原因
+	exitSignal := make(chan model.SessionExitReason, 2)
 	go func() {
 		var (
 			exitFlag bool
+			readErr  error
 		)
 		buffer := bytes.NewBuffer(make([]byte, 0, 1024*2))
 		/*
@@ -180,6 +298,7 @@ func (s *SwitchSession) Bridge(userConn UserConnection, srvConn srvconn.ServerCo
 			nr, err2 := srvConn.Read(buf)
 			validBytes := buf[:nr]
 			if nr > 0 {
+				metrics.AddOutputBytes(protocol, nr)
 				bufferLen := buffer.Len()
 				if bufferLen > 0 || nr == maxLen {
 					buffer.Write(buf[:nr])
@@ -205,19 +324,20 @@ func (s *SwitchSession) Bridge(userConn UserConnection, srvConn srvconn.ServerCo
 				case srvInChan <- validBytes:
 				case <-done:
 					exitFlag = true
-					logger.Infof("Session[%s] done", s.ID)
+					sessLogger.Infof("Session[%s] done", s.ID)
 				}
 				if exitFlag {
 					break
 				}
 			}
 			if err2 != nil {
-				logger.Errorf("Session[%s] srv read err: %s", s.ID, err2)
+				sessLogger.Errorf("Session[%s] srv read err: %s", s.ID, err2)
+				readErr = err2
 				break
 			}
 		}
-		logger.Infof("Session[%s] srv read end", s.ID)
-		exitSignal <- struct{}{}
+		sessLogger.Infof("Session[%s] srv read end", s.ID)
+		exitSignal <- srvReadExitReason(readErr)
 		close(srvInChan)
 	}()
 	user := s.p.connOpts.authInfo.User
@@ -248,10 +368,54 @@ func (s *SwitchSession) Bridge(userConn UserConnection, srvConn srvconn.ServerCo
 			room.Broadcast(&msg)
 		}
 	}
+	escapeChan := make(chan struct{}, 1)
+	// 回滚模式中暂停转发资产的输出，窗口大小在主循环中更新
+	scrollbackChan := make(chan bool)
+	var window atomic.Value
+	window.Store(userConn.Pty().Window)
+	currentWindow := func() ssh.Window { return window.Load().(ssh.Window) }
 	go func() {
+		throttle := newInputThrottle(s.inputRateLimit)
+		throttled := false
+		escape := newEscapeDetector(s.escapeSequence)
+		scrollKey := utils.NewKeySequenceFilter(s.scrollbackKey)
 		for {
 			buf := make([]byte, 1024)
 			nr, err := userConn.Read(buf)
+			zmodemActive := parser.zmodemParser != nil && parser.zmodemParser.IsStartSession()
+			// zmodem 传输的数据不识别退出序列
+			if nr > 0 && !zmodemActive {
+				var escaped bool
+				if buf, escaped = escape.Feed(buf[:nr]); escaped {
+					sessLogger.Infof("Session[%s] user input escape sequence, force quit", s.ID)
+					escapeChan <- struct{}{}
+					return
+				}
+				var hits int
+				if buf, hits = scrollKey.Filter(buf); hits > 0 && err == nil {
+					if err = s.runScrollback(userConn, scrollback, scrollbackChan, done, currentWindow); err != nil {
+						sessLogger.Errorf("Session[%s] scrollback mode err: %s", s.ID, err)
+						break
+					}
+				}
+				nr = len(buf)
+				if nr == 0 && err == nil {
+					continue
+				}
+			}
+			// zmodem 上传文件时不限制
+			if nr > 0 && !zmodemActive {
+				if wait := throttle.reserve(nr); wait > 0 {
+					if !throttled {
+						throttled = true
+						sessLogger.Infof("Session[%s] user input exceeds %d bytes/s, throttle input", s.ID, s.inputRateLimit)
+					}
+					// 会话结束时不再等待
+					if sleepOrDone(done, wait) != nil {
+						break
+					}
+				}
+			}
 			if nr > 0 {
 				index := bytes.IndexFunc(buf[:nr], func(r rune) bool {
 					return r == '\r'
@@ -271,64 +435,189 @@ func (s *SwitchSession) Bridge(userConn UserConnection, srvConn srvconn.ServerCo
 				}
 			}
 			if err != nil {
-				logger.Errorf("Session[%s] user read err: %s", s.ID, err)
+				sessLogger.Errorf("Session[%s] user read err: %s", s.ID, err)
 				break
 			}
 		}
-		logger.Infof("Session[%s] user read end", s.ID)
-		exitSignal <- struct{}{}
+		sessLogger.Infof("Session[%s] user read end", s.ID)
+		exitSignal <- model.ExitUserDisconnect
 	}()
+	var macro, initRunner *macroRunner
+	if commands := s.p.platformInitCommands(); len(commands) > 0 {
+		initRunner = newInitCommandRunner(commands)
+	}
+	if s.p.connOpts.macro != nil {
+		macro = newMacroRunner(s.p.connOpts.macro)
+	}
+	if initRunner != nil || macro != nil {
+		// 先执行平台的初始化命令，再执行用户的连接宏
+		go func() {
+			if initRunner != nil {
+				s.runInitCommands(initRunner, s.p.lineEndingMode(), done, srvConn)
+			}
+			if macro != nil {
+				s.p.recordMacroRun(macro.macro)
+				macroMeta := meta
+				macroMeta.User = macroUser(meta.User, macro.macro.Name)
+				s.runMacro(macro, done, func(p []byte) {
+					room.Receive(&exchange.RoomMessage{
+						Event: exchange.DataEvent, Body: p,
+						Meta: macroMeta})
+				})
+			}
+		}()
+	}
 	keepAliveTime := time.Duration(s.keepAliveTime) * time.Second
 	keepAliveTick := time.NewTicker(keepAliveTime)
 	defer keepAliveTick.Stop()
+	var (
+		keepAliveWaiting   bool
+		keepAliveMissCount int
+	)
+	keepAliveReplyChan := make(chan error, 1)
+	maxDurationWarn, maxDurationExpire, stopMaxDuration := s.maxDurationTimers()
+	defer stopMaxDuration()
+	maxDurationMinutes := int(s.maxDuration / time.Minute)
+	latencyTick, stopLatency := s.latencyTicker()
+	defer stopLatency()
+	var (
+		latencyWaiting bool
+		latencyHigh    bool
+	)
+	latencyReplyChan := make(chan latencyResult, 1)
 	lang := s.p.connOpts.getLang()
+	srvOut := srvOutChan
 	for {
 		select {
 		// 检测是否超过最大空闲时间
 		case now := <-tick.C:
 			if s.MaxSessionTime.Before(now) {
 				msg := lang.T("Session max time reached, disconnect")
-				logger.Infof("Session[%s] max session time reached, disconnect", s.ID)
+				sessLogger.Infof("Session[%s] max session time reached, disconnect", s.ID)
 				msg = utils.WrapperWarn(msg)
 				replayRecorder.Record([]byte(msg))
 				room.Broadcast(&exchange.RoomMessage{Event: exchange.DataEvent, Body: []byte("\n\r" + msg)})
+				s.exitReason = model.ExitMaxSessionTime
 				return
 			}
 
 			outTime := lastActiveTime.Add(maxIdleTime)
 			if now.After(outTime) {
 				msg := fmt.Sprintf(lang.T("Connect idle more than %d minutes, disconnect"), s.MaxIdleTime)
-				logger.Infof("Session[%s] idle more than %d minutes, disconnect", s.ID, s.MaxIdleTime)
+				sessLogger.Infof("Session[%s] idle more than %d minutes, disconnect", s.ID, s.MaxIdleTime)
 				msg = utils.WrapperWarn(msg)
 				replayRecorder.Record([]byte(msg))
 				room.Broadcast(&exchange.RoomMessage{Event: exchange.DataEvent, Body: []byte("\n\r" + msg)})
+				s.exitReason = model.ExitIdleTimeout
 				return
 			}
 			if s.p.CheckPermissionExpired(now) {
 				msg := lang.T("Permission has expired, disconnect")
-				logger.Infof("Session[%s] permission has expired, disconnect", s.ID)
+				sessLogger.Infof("Session[%s] permission has expired, disconnect", s.ID)
 				msg = utils.WrapperWarn(msg)
 				replayRecorder.Record([]byte(msg))
 				room.Broadcast(&exchange.RoomMessage{Event: exchange.DataEvent, Body: []byte("\n\r" + msg)})
+				s.exitReason = model.ExitPermissionExpired
 				return
 			}
 			continue
+			// 会话最长时间，断开前提示
+		case <-maxDurationWarn:
+			msg := fmt.Sprintf(lang.T("Session will reach the maximum duration of %d minutes, disconnect in %d seconds, please save your work"),
+				maxDurationMinutes, int(s.maxDurationGrace/time.Second))
+			sessLogger.Infof("Session[%s] max duration %d minutes will be reached in %s", s.ID, maxDurationMinutes, s.maxDurationGrace)
+			msg = "\n\r" + utils.WrapperString(msg, utils.Yellow, true) + "\n\r"
+			room.Broadcast(&exchange.RoomMessage{Event: exchange.DataEvent, Body: []byte(msg)})
+			continue
+		case <-maxDurationExpire:
+			msg := fmt.Sprintf(lang.T("Session exceeded the maximum duration of %d minutes, disconnect"), maxDurationMinutes)
+			sessLogger.Infof("Session[%s] max duration %d minutes exceeded, disconnect", s.ID, maxDurationMinutes)
+			msg = utils.WrapperWarn(msg)
+			replayRecorder.Record([]byte(msg))
+			room.Broadcast(&exchange.RoomMessage{Event: exchange.DataEvent, Body: []byte("\n\r" + msg)})
+			s.exitReason = model.ExitMaxDurationExceeded
+			return
+		case <-latencyTick:
+			if latencyWaiting {
+				continue
+			}
+			latencyWaiting = true
+			go func() {
+				rtt, err2 := measureLatency(srvConn)
+				latencyReplyChan <- latencyResult{rtt: rtt, err: err2}
+			}()
+			continue
+		case ret := <-latencyReplyChan:
+			latencyWaiting = false
+			if ret.err != nil {
+				// 连接异常由 keepalive 检测处理
+				continue
+			}
+			high := ret.rtt > s.latencyThreshold
+			if high == latencyHigh {
+				continue
+			}
+			latencyHigh = high
+			var msg string
+			if high {
+				msg = fmt.Sprintf(lang.T("High latency to asset: %s (threshold %s)"),
+					formatLatency(ret.rtt), formatLatency(s.latencyThreshold))
+				msg = utils.WrapperString(msg, utils.Yellow, true)
+			} else {
+				msg = fmt.Sprintf(lang.T("Latency to asset recovered: %s"), formatLatency(ret.rtt))
+				msg = utils.WrapperString(msg, utils.Green, true)
+			}
+			sessLogger.Infof("Session[%s] latency to asset %s", s.ID, ret.rtt)
+			room.Broadcast(&exchange.RoomMessage{Event: exchange.DataEvent, Body: []byte("\n\r" + msg + "\n\r")})
+			continue
+			// 空闲倒计时警告
+		case now := <-idleTick.C:
+			idleDuration := now.Sub(lastActiveTime)
+			if idleDuration < idleTimeout {
+				continue
+			}
+			remain := idleWarningCountdown - int((idleDuration-idleTimeout)/time.Second)
+			if remain <= 0 {
+				msg := fmt.Sprintf(lang.T("Connect idle more than %d seconds, disconnect"), s.IdleTimeout+idleWarningCountdown)
+				sessLogger.Infof("Session[%s] idle more than %d seconds, disconnect", s.ID, s.IdleTimeout+idleWarningCountdown)
+				msg = utils.WrapperWarn(msg)
+				replayRecorder.Record([]byte(msg))
+				room.Broadcast(&exchange.RoomMessage{Event: exchange.DataEvent, Body: []byte("\n\r" + msg)})
+				s.exitReason = model.ExitIdleTimeout
+				return
+			}
+			prefix := "\r"
+			if !idleWarned {
+				prefix = "\n\r"
+				idleWarned = true
+			}
+			msg := fmt.Sprintf(lang.T("Session idle, disconnect in %d seconds, press any key to continue"), remain)
+			msg = utils.WrapperString(msg, utils.Red)
+			room.Broadcast(&exchange.RoomMessage{Event: exchange.DataEvent, Body: []byte(prefix + msg)})
+			continue
 			// 手动结束
 		case <-s.ctx.Done():
 			adminUser := s.loadOperator()
 			msg := fmt.Sprintf(lang.T("Terminated by admin %s"), adminUser)
+			s.exitReason = model.ExitAdminTerminate
+			if s.shutdown.Load() {
+				msg = lang.T("KoKo is shutting down, session closed")
+				s.exitReason = model.ExitNodeShutdown
+			}
 			msg = utils.WrapperWarn(msg)
 			replayRecorder.Record([]byte(msg))
-			logger.Infof("Session[%s]: %s", s.ID, msg)
+			sessLogger.Infof("Session[%s]: %s", s.ID, msg)
 			room.Broadcast(&exchange.RoomMessage{Event: exchange.DataEvent, Body: []byte("\n\r" + msg)})
 			return
 			// 监控窗口大小变化
 		case win, ok := <-winCh:
 			if !ok {
+				s.exitReason = model.ExitUserDisconnect
 				return
 			}
+			window.Store(win)
 			_ = srvConn.SetWinSize(win.Width, win.Height)
-			logger.Infof("Session[%s] Window server change: %d*%d",
+			sessLogger.Infof("Session[%s] Window server change: %d*%d",
 				s.ID, win.Width, win.Height)
 			p, _ := json.Marshal(win)
 			msg := exchange.RoomMessage{
@@ -337,12 +626,22 @@ func (s *SwitchSession) Bridge(userConn UserConnection, srvConn srvconn.ServerCo
 			}
 			room.Broadcast(&msg)
 			// 经过parse处理的server数据，发给user
-		case p, ok := <-srvOutChan:
+		case p, ok := <-srvOut:
 			if !ok {
+				// 资产的读取结束后才会关闭，此时已经发送了结束原因
+				s.exitReason = <-exitSignal
 				return
 			}
 			if parser.NeedRecord() {
 				replayRecorder.Record(p)
+				lastOutput.Write(p)
+				scrollback.Write(p)
+			}
+			if initRunner != nil {
+				initRunner.Feed(p)
+			}
+			if macro != nil {
+				macro.Feed(p)
 			}
 			msg := exchange.RoomMessage{
 				Event: exchange.DataEvent,
@@ -352,30 +651,94 @@ func (s *SwitchSession) Bridge(userConn UserConnection, srvConn srvconn.ServerCo
 			// 经过parse处理的user数据，发给server
 		case p, ok := <-userOutChan:
 			if !ok {
+				s.exitReason = model.ExitUserDisconnect
 				return
 			}
-			if _, err1 := srvConn.Write(p); err1 != nil {
-				logger.Errorf("Session[%s] srvConn write err: %s", s.ID, err1)
+			if bytes.ContainsAny(p, "\r\n") {
+				replayRecorder.ResetCommandOutput()
+				lastOutput.Commit()
+			}
+			nw, err1 := srvConn.Write(p)
+			metrics.AddInputBytes(protocol, nw)
+			if err1 != nil {
+				sessLogger.Errorf("Session[%s] srvConn write err: %s", s.ID, err1)
 			}
 
 		case now := <-keepAliveTick.C:
-			if now.After(lastActiveTime.Add(keepAliveTime)) {
-				if err := srvConn.KeepAlive(); err != nil {
-					logger.Errorf("Session[%s] srvCon keep alive err: %s", s.ID, err)
+			if keepAliveWaiting {
+				// 上一次 keepalive 在一个周期内没有回复
+				keepAliveMissCount++
+				sessLogger.Errorf("Session[%s] srvCon keep alive no reply %d times", s.ID, keepAliveMissCount)
+			} else if now.After(lastActiveTime.Add(keepAliveTime)) {
+				keepAliveWaiting = true
+				go func() {
+					keepAliveReplyChan <- srvConn.KeepAlive()
+				}()
+			}
+			if s.isKeepAliveExceeded(keepAliveMissCount) {
+				if rc, ok := srvConn.(*reconnectConnection); ok {
+					// 开启了自动重连时，断开当前连接由读取端重连
+					sessLogger.Infof("Session[%s] srvCon keep alive miss %d times, reconnect", s.ID, keepAliveMissCount)
+					keepAliveMissCount = 0
+					keepAliveWaiting = false
+					rc.Drop()
+					continue
 				}
+				msg := lang.T("Connection to asset lost, no keepalive reply, disconnect")
+				sessLogger.Infof("Session[%s] srvCon keep alive miss %d times, disconnect", s.ID, keepAliveMissCount)
+				msg = utils.WrapperWarn(msg)
+				replayRecorder.Record([]byte(msg))
+				room.Broadcast(&exchange.RoomMessage{Event: exchange.DataEvent, Body: []byte("\n\r" + msg)})
+				s.exitReason = model.ExitAssetDisconnect
+				return
+			}
+			continue
+		case err := <-keepAliveReplyChan:
+			keepAliveWaiting = false
+			if err != nil {
+				keepAliveMissCount++
+				sessLogger.Errorf("Session[%s] srvCon keep alive err: %s", s.ID, err)
+			} else {
+				keepAliveMissCount = 0
 			}
 			continue
 		case <-userConn.Context().Done():
-			logger.Infof("Session[%s]: user conn context done", s.ID)
+			sessLogger.Infof("Session[%s]: user conn context done", s.ID)
+			s.exitReason = model.ExitUserDisconnect
+			if dc, ok := userConn.(*detachableConn); ok && dc.isExpired() {
+				s.exitReason = model.ExitDetachExpired
+			}
 			return nil
-		case <-exitSignal:
-			logger.Debugf("Session[%s] end by exit signal", s.ID)
+		case paused := <-scrollbackChan:
+			srvOut = srvOutChan
+			if paused {
+				srvOut = nil
+			}
+		case <-escapeChan:
+			msg := fmt.Sprintf(lang.T("Escape sequence %s received, close the connection to the asset"), s.escapeSequence)
+			msg = utils.WrapperWarn(msg)
+			replayRecorder.Record([]byte(msg))
+			room.Broadcast(&exchange.RoomMessage{Event: exchange.DataEvent, Body: []byte("\n\r" + msg)})
+			s.exitReason = model.ExitEscapeSequence
+			return
+		case reason := <-exitSignal:
+			sessLogger.Debugf("Session[%s] end by exit signal: %s", s.ID, reason)
+			s.exitReason = reason
 			return
 		case notifyMsg := <-s.notifyMsgChan:
-			logger.Infof("Session[%s] notify event: %s", s.ID, notifyMsg.Event)
+			sessLogger.Infof("Session[%s] notify event: %s", s.ID, notifyMsg.Event)
 			room.Broadcast(notifyMsg)
 			continue
 		}
 		lastActiveTime = time.Now()
+		idleWarned = false
+	}
+}
+
+// srvReadExitReason 资产端正常关闭连接时 Read 返回 io.EOF
+func srvReadExitReason(err error) model.SessionExitReason {
+	if err == nil || errors.Is(err, io.EOF) {
+		return model.ExitUserExit
 	}
+	return model.ExitAssetDisconnect
 }
@@ -0,0 +1,27 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+func TestSrvReadExitReason(t *testing.T) {
+	tests := []struct {
+		err  error
+		want model.SessionExitReason
+	}{
+		{err: nil, want: model.ExitUserExit},
+		{err: io.EOF, want: model.ExitUserExit},
+		{err: fmt.Errorf("telnet read: %w", io.EOF), want: model.ExitUserExit},
+		{err: errors.New("read tcp: connection reset by peer"), want: model.ExitAssetDisconnect},
+	}
+	for _, tt := range tests {
+		if got := srvReadExitReason(tt.err); got != tt.want {
+			t.Errorf("srvReadExitReason(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
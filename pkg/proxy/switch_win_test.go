@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+
+	"github.com/jumpserver/koko/pkg/exchange"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+// winServerConn 记录资产连接收到的窗口大小，读取阻塞到连接关闭
+type winServerConn struct {
+	recordServerConn
+	closed  chan struct{}
+	once    sync.Once
+	winSize chan [2]int
+}
+
+func (c *winServerConn) Read([]byte) (int, error) {
+	<-c.closed
+	return 0, context.Canceled
+}
+
+func (c *winServerConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+
+func (c *winServerConn) SetWinSize(w, h int) error {
+	c.winSize <- [2]int{w, h}
+	return nil
+}
+
+func TestBridgeWindowChange(t *testing.T) {
+	exchange.Initial()
+	conn := newPipeUserConn("conn-1")
+	authInfo := &model.ConnectToken{
+		Id:       "token-1",
+		User:     model.User{ID: "user-1", Username: "alice"},
+		Asset:    model.Asset{ID: "asset-1", Name: "web-1"},
+		Protocol: "ssh",
+	}
+	srv := &Server{ID: "sess-win", UserConn: conn,
+		connOpts:     &ConnectionOptions{authInfo: authInfo, i18nLang: "en"},
+		terminalConf: &model.TerminalConfig{ReplayStorage: model.ReplayConfig{TypeName: "null"}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	sw := &SwitchSession{ID: srv.ID, keepAliveTime: 60, ctx: ctx, cancel: cancel, p: srv,
+		notifyMsgChan: make(chan *exchange.RoomMessage, 1)}
+	srvConn := &winServerConn{closed: make(chan struct{}), winSize: make(chan [2]int, 1)}
+	done := make(chan error, 1)
+	go func() { done <- sw.Bridge(conn, srvConn) }()
+
+	conn.winCh <- ssh.Window{Width: 132, Height: 50}
+	select {
+	case size := <-srvConn.winSize:
+		if size != [2]int{132, 50} {
+			t.Errorf("SetWinSize(%d, %d), want 132*50", size[0], size[1])
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("window change did not reach the asset connection")
+	}
+	conn.disconnect()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Bridge did not return after user disconnect")
+	}
+}
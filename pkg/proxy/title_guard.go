@@ -0,0 +1,179 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+// TITLE_SEQUENCE_POLICY 资产输出中修改终端标题的 OSC 序列的处理策略
+const (
+	titlePolicyPass  = "pass"
+	titlePolicyStrip = "strip"
+	titlePolicyLog   = "log"
+)
+
+const (
+	// 等待序列结束时最多缓存的数据，超出后不再按标题序列处理
+	maxTitleSequenceSize = 4 * 1024
+
+	// 生命周期日志中最多记录的标题长度
+	maxTitleLogSize = 256
+)
+
+const (
+	charBEL = 0x07
+	charESC = 0x1b
+)
+
+type titleGuard struct {
+	policy string
+
+	// 上次输出结尾未完成的序列
+	pending []byte
+
+	// 上次记录的标题，标题不变时不重复记录
+	lastTitle string
+}
+
+func normalizeTitlePolicy(policy string) string {
+	switch policy = strings.ToLower(strings.TrimSpace(policy)); policy {
+	case titlePolicyStrip, titlePolicyLog:
+		return policy
+	}
+	return titlePolicyPass
+}
+
+/*
+scanTitleSequence 检查 data 开头的 "ESC ] Ps ; Pt BEL" 或 "ESC ] Ps ; Pt ESC \"，Ps 为 0、1、2 时修改窗口标题。
+返回序列的长度和标题，不是标题序列时返回 0；数据不完整时 incomplete 为 true。
+*/
+
+func scanTitleSequence(data []byte) (size int, title string, incomplete bool) {
+	if len(data) < 2 {
+		return 0, "", len(data) == 1
+	}
+	if data[1] != ']' {
+		return 0, "", false
+	}
+	i := 2
+	for i < len(data) && data[i] >= '0' && data[i] <= '9' {
+		i++
+	}
+	if i == len(data) {
+		return 0, "", true
+	}
+	if data[i] != ';' || i == 2 || i-2 > 1 || data[2] > '2' {
+		return 0, "", false
+	}
+	start := i + 1
+	for j := start; j < len(data); j++ {
+		switch data[j] {
+		case charBEL:
+			return j + 1, string(data[start:j]), false
+		case charESC:
+			if j+1 == len(data) {
+				return 0, "", true
+			}
+			if data[j+1] == '\\' {
+				return j + 2, string(data[start:j]), false
+			}
+			// 其他控制序列会中断当前序列，标题不完整也会被终端丢弃
+			return j, string(data[start:j]), false
+		}
+	}
+	return 0, "", true
+}
+
+// filter 按策略处理输出中的标题序列，不完整的序列缓存到下次输出
+func (g *titleGuard) filter(b []byte) ([]byte, []string) {
+	data := b
+	if len(g.pending) > 0 {
+		data = append(g.pending, b...)
+		g.pending = nil
+	}
+	if bytes.IndexByte(data, charESC) < 0 {
+		return data, nil
+	}
+	var (
+		out    = make([]byte, 0, len(data))
+		titles []string
+	)
+	for len(data) > 0 {
+		index := bytes.IndexByte(data, charESC)
+		if index < 0 {
+			out = append(out, data...)
+			break
+		}
+		out = append(out, data[:index]...)
+		data = data[index:]
+		size, title, incomplete := scanTitleSequence(data)
+		switch {
+		case incomplete && len(data) <= maxTitleSequenceSize:
+			g.pending = append([]byte(nil), data...)
+			return out, titles
+		case size == 0:
+			if !incomplete || g.policy != titlePolicyStrip {
+				// strip 时去除过长的未结束序列的 ESC，避免终端把后续内容都当作标题
+				out = append(out, data[0])
+			}
+			data = data[1:]
+			continue
+		}
+		titles = append(titles, title)
+		if g.policy != titlePolicyStrip {
+			out = append(out, data[:size]...)
+		}
+		data = data[size:]
+	}
+	return out, titles
+}
+
+// parseTitleSequence 检测资产输出中修改终端标题的序列，按照 TITLE_SEQUENCE_POLICY 去除或者记录日志
+func (p *Parser) parseTitleSequence(b []byte) []byte {
+	guard := &p.title
+	if guard.policy == "" || guard.policy == titlePolicyPass {
+		return b
+	}
+	if p.zmodemParser.IsStartSession() {
+		// 文件传输的数据不处理，缓存的数据直接输出
+		if len(guard.pending) > 0 {
+			b = append(guard.pending, b...)
+			guard.pending = nil
+		}
+		return b
+	}
+	out, titles := guard.filter(b)
+	for _, title := range titles {
+		if guard.policy == titlePolicyStrip {
+			logger.Debugf("Session %s: strip terminal title sequence %q", p.id, title)
+			continue
+		}
+		if title != guard.lastTitle {
+			guard.lastTitle = title
+			p.recordTitleChange(title)
+		}
+	}
+	return out
+}
+
+// recordTitleChange 资产修改终端标题记录到会话生命周期日志，便于审计伪造标题的行为
+func (p *Parser) recordTitleChange(title string) {
+	content := visibleLine(title)
+	if len(content) > maxTitleLogSize {
+		content = content[:maxTitleLogSize] + "..."
+	}
+	logger.Infof("Session %s: asset set terminal title %q", p.id, content)
+	logObj := model.SessionLifecycleLog{
+		Reason: fmt.Sprintf("terminal title changed: %s", content),
+		User:   p.currentActiveUser.User,
+	}
+	go func() {
+		if err := p.jmsService.RecordSessionLifecycleLog(p.id, model.TerminalTitleChange, logObj); err != nil {
+			logger.Errorf("Session %s: record terminal title change log failed: %s", p.id, err)
+		}
+	}()
+}
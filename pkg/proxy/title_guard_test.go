@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTitleGuardFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     string
+		chunks     []string
+		wantOutput string
+		wantTitles []string
+	}{
+		{"strip bel", titlePolicyStrip, []string{"a\x1b]0;fake\x07b"}, "ab", []string{"fake"}},
+		{"strip st", titlePolicyStrip, []string{"\x1b]2;prod-db\x1b\\$ "}, "$ ", []string{"prod-db"}},
+		{"log keeps sequence", titlePolicyLog, []string{"\x1b]1;icon\x07$ "}, "\x1b]1;icon\x07$ ", []string{"icon"}},
+		{"other osc", titlePolicyStrip, []string{"\x1b]52;c;aGk=\x07\x1b[1mx"}, "\x1b]52;c;aGk=\x07\x1b[1mx", nil},
+		{"split chunks", titlePolicyStrip, []string{"ls\r\n\x1b", "]0;ro", "ot@web\x1b", "\\$ "}, "ls\r\n$ ", []string{"root@web"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard := titleGuard{policy: tt.policy}
+			var (
+				output string
+				titles []string
+			)
+			for _, chunk := range tt.chunks {
+				out, got := guard.filter([]byte(chunk))
+				output += string(out)
+				titles = append(titles, got...)
+			}
+			if output != tt.wantOutput {
+				t.Errorf("output = %q, want %q", output, tt.wantOutput)
+			}
+			if !reflect.DeepEqual(titles, tt.wantTitles) {
+				t.Errorf("titles = %q, want %q", titles, tt.wantTitles)
+			}
+		})
+	}
+}
@@ -1,10 +1,15 @@
 package proxy
 
 import (
+	"errors"
+	"fmt"
 	"net"
 	"net/url"
 	"strconv"
 	"strings"
+
+	"github.com/jumpserver/koko/pkg/common"
+	"github.com/jumpserver/koko/pkg/srvconn"
 )
 
 const (
@@ -24,6 +29,30 @@ func (s *Server) ConvertErrorToReadableMsg(e error) string {
 	}
 	errMsg := e.Error()
 	lang := s.connOpts.getLang()
+	var hopErr *srvconn.GatewayHopError
+	if errors.As(e, &hopErr) {
+		msg := fmt.Sprintf(lang.T("Connect through gateway hop %d/%d (%s) failed"),
+			hopErr.Hop, hopErr.Total, hopErr.Addr)
+		return msg + ": " + s.ConvertErrorToReadableMsg(hopErr.Err)
+	}
+	if errors.Is(e, srvconn.ErrSocks5Dial) {
+		return lang.T("Connect through SOCKS5 proxy failed") + ": " + errMsg
+	}
+	if errors.Is(e, srvconn.ErrHTTPProxyDial) {
+		return lang.T("Connect through HTTP proxy failed") + ": " + errMsg
+	}
+	if errors.Is(e, srvconn.ErrSSHCertExpired) {
+		return lang.T("The SSH certificate of the account has expired") + ": " + errMsg
+	}
+	if errors.Is(e, srvconn.ErrSSHCertInvalid) {
+		return lang.T("The SSH certificate of the account is invalid") + ": " + errMsg
+	}
+	if errors.Is(e, srvconn.ErrDatabaseTLS) {
+		return lang.T("TLS handshake with the database failed, please check the certificates of the asset") + ": " + errMsg
+	}
+	if errors.Is(e, srvconn.ErrNotFoundShell) {
+		return lang.T("No available shell found in the container")
+	}
 	if strings.Contains(errMsg, UnAuth) || strings.Contains(errMsg, LoginFailed) {
 		return lang.T("Authentication failed")
 	}
@@ -43,15 +72,21 @@ func (s *Server) ConvertErrorToReadableMsg(e error) string {
 }
 
 func ReplaceURLHostAndPort(originUrl *url.URL, ip string, port int) string {
+	ip = common.TrimHostBrackets(ip)
 	newHost := net.JoinHostPort(ip, strconv.Itoa(port))
+	// 默认端口省略端口时，IPv6 地址仍然需要方括号
+	defaultHost := ip
+	if strings.Contains(ip, ":") {
+		defaultHost = "[" + ip + "]"
+	}
 	switch originUrl.Scheme {
 	case "https":
 		if port == 443 {
-			newHost = ip
+			newHost = defaultHost
 		}
 	default:
 		if port == 80 {
-			newHost = ip
+			newHost = defaultHost
 		}
 	}
 	newUrl := url.URL{
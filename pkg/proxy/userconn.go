@@ -18,4 +18,15 @@ type UserConnection interface {
 	Pty() ssh.Pty
 	Context() context.Context
 	HandleRoomEvent(event string, msg *exchange.RoomMessage)
+}
+
+// environConnection SSH 客户端的连接可以获取客户端发送的环境变量
+type environConnection interface {
+	Environ() []string
+}
+
+// agentConnection SSH 客户端的连接可以使用客户端转发的 SSH agent
+type agentConnection interface {
+	AgentForwarded() bool
+	OpenAgentChannel() (io.ReadWriteCloser, error)
 }
\ No newline at end of file
@@ -0,0 +1,101 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/config"
+	modelCommon "github.com/jumpserver/koko/pkg/jms-sdk-go/common"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+const (
+	WebhookSessionStart = "session_start"
+	WebhookSessionEnd   = "session_end"
+)
+
+const (
+	webhookTimeout    = 3 * time.Second
+	webhookMaxRetries = 3
+	webhookRetryDelay = time.Second
+)
+
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+type SessionWebhookEvent struct {
+	Event     string               `json:"event"`
+	SessionID string               `json:"session_id"`
+	User      string               `json:"user"`
+	Asset     string               `json:"asset"`
+	Protocol  string               `json:"protocol"`
+	DateStart modelCommon.UTCTime  `json:"date_start"`
+	DateEnd   *modelCommon.UTCTime `json:"date_end,omitempty"`
+
+	ExitReason model.SessionExitReason `json:"exit_reason,omitempty"`
+	Comment    string                  `json:"comment,omitempty"`
+	Tags       map[string]string       `json:"tags,omitempty"`
+}
+
+// notifySessionWebhook 异步发送会话事件通知，未配置 SESSION_WEBHOOK_URL 时不发送
+func notifySessionWebhook(event string, sess *model.Session, dateEnd *modelCommon.UTCTime,
+	exitReason model.SessionExitReason) {
+	webhookUrl := config.GetConf().SessionWebhookURL
+	if webhookUrl == "" {
+		return
+	}
+	data := SessionWebhookEvent{
+		Event:     event,
+		SessionID: sess.ID,
+		User:      sess.User,
+		Asset:     sess.Asset,
+		Protocol:  sess.Protocol,
+		DateStart: sess.DateStart,
+		DateEnd:   dateEnd,
+
+		ExitReason: exitReason,
+		Comment:    sess.Comment,
+		Tags:       sess.Tags,
+	}
+	go func() {
+		if err := postWebhookWithRetry(webhookUrl, &data); err != nil {
+			logger.Errorf("Session[%s] send %s webhook failed: %s", sess.ID, event, err)
+		}
+	}()
+}
+
+// postWebhookWithRetry 失败后按 1s、2s、4s 退避重试
+func postWebhookWithRetry(webhookUrl string, data *SessionWebhookEvent) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	delay := webhookRetryDelay
+	for i := 0; ; i++ {
+		if err = postWebhook(webhookUrl, body); err == nil {
+			return nil
+		}
+		if i >= webhookMaxRetries {
+			return err
+		}
+		logger.Debugf("Session[%s] %s webhook failed, retry in %s: %s",
+			data.SessionID, data.Event, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func postWebhook(webhookUrl string, body []byte) error {
+	resp, err := webhookClient.Post(webhookUrl, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/service"
+	"github.com/jumpserver/koko/pkg/logger"
+	"github.com/jumpserver/koko/pkg/rpc/pb"
+	"github.com/jumpserver/koko/pkg/session"
+)
+
+const (
+	authorizationKey = "authorization"
+	bearerPrefix     = "Bearer "
+
+	defaultTerminatedBy = "grpc"
+)
+
+/*
+Server 对外提供查询和管理当前节点活跃会话的 gRPC 服务，
+所有请求都需要在 metadata 中携带 authorization: Bearer <token>。
+*/
+
+type Server struct {
+	pb.UnimplementedSessionServiceServer
+
+	addr       string
+	token      string
+	srv        *grpc.Server
+	jmsService *service.JMService
+}
+
+func NewServer(addr, token string, jmsService *service.JMService) *Server {
+	s := &Server{addr: addr, token: token, jmsService: jmsService}
+	s.srv = grpc.NewServer(
+		grpc.UnaryInterceptor(s.unaryAuthInterceptor),
+		grpc.StreamInterceptor(s.streamAuthInterceptor),
+	)
+	pb.RegisterSessionServiceServer(s.srv, s)
+	return s
+}
+
+func (s *Server) Start() {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		logger.Errorf("gRPC server listen %s failed: %s", s.addr, err)
+		return
+	}
+	s.Serve(ln)
+}
+
+func (s *Server) Serve(ln net.Listener) {
+	logger.Infof("Start gRPC server at %s", ln.Addr())
+	if err := s.srv.Serve(ln); err != nil && err != grpc.ErrServerStopped {
+		logger.Errorf("gRPC server exit: %s", err)
+	}
+}
+
+func (s *Server) Stop() {
+	// WatchSessions 是长连接，GracefulStop 会一直等待，超时后强制关闭
+	done := make(chan struct{})
+	go func() {
+		s.srv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		s.srv.Stop()
+	}
+}
+
+func (s *Server) ListSessions(ctx context.Context, req *pb.ListSessionsRequest) (*pb.ListSessionsResponse, error) {
+	sessions := session.GetAliveSessionList()
+	resp := pb.ListSessionsResponse{Sessions: make([]*pb.Session, 0, len(sessions))}
+	for i := range sessions {
+		resp.Sessions = append(resp.Sessions, convertSession(sessions[i]))
+	}
+	return &resp, nil
+}
+
+func (s *Server) WatchSessions(req *pb.WatchSessionsRequest, stream pb.SessionService_WatchSessionsServer) error {
+	// 先订阅再读取已有会话，避免遗漏两者之间开始的会话
+	events, cancel := session.Subscribe()
+	defer cancel()
+	if req.IncludeExisting {
+		now := time.Now()
+		for _, sess := range session.GetAliveSessionList() {
+			event := session.Event{Type: session.EventStarted, Session: sess, Time: now}
+			if err := stream.Send(convertEvent(event)); err != nil {
+				return err
+			}
+		}
+	}
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			// 客户端断开或者服务关闭
+			return nil
+		case event := <-events:
+			if err := stream.Send(convertEvent(event)); err != nil {
+				logger.Debugf("gRPC watch sessions send event failed: %s", err)
+				return err
+			}
+		}
+	}
+}
+
+func (s *Server) TerminateSession(ctx context.Context, req *pb.TerminateSessionRequest) (*pb.TerminateSessionResponse, error) {
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "session id is required")
+	}
+	sess, ok := session.GetSessionById(req.Id)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "session %s not found", req.Id)
+	}
+	terminatedBy := req.TerminatedBy
+	if terminatedBy == "" {
+		terminatedBy = defaultTerminatedBy
+	}
+	task := model.TerminalTask{
+		Name:   model.TaskKillSession,
+		Kwargs: model.TaskKwargs{TerminatedBy: terminatedBy},
+	}
+	if err := sess.HandleTask(&task); err != nil {
+		logger.Errorf("gRPC terminate session %s failed: %s", req.Id, err)
+		return nil, status.Errorf(codes.Internal, "terminate session failed: %s", err)
+	}
+	logger.Infof("Session %s terminated by %s from gRPC", req.Id, terminatedBy)
+	if s.jmsService != nil {
+		logObj := model.SessionLifecycleLog{
+			Reason: "Terminated by admin from koko gRPC",
+			User:   terminatedBy,
+		}
+		if err := s.jmsService.RecordSessionLifecycleLog(req.Id, model.AdminTerminate, logObj); err != nil {
+			logger.Errorf("Record session %s lifecycle log failed: %s", req.Id, err)
+		}
+	}
+	return &pb.TerminateSessionResponse{}, nil
+}
+
+func (s *Server) unaryAuthInterceptor(ctx context.Context, req interface{},
+	info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := s.authenticate(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) streamAuthInterceptor(srv interface{}, ss grpc.ServerStream,
+	info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.authenticate(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+func (s *Server) authenticate(ctx context.Context) error {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "missing metadata")
+	}
+	values := md.Get(authorizationKey)
+	if len(values) == 0 || !strings.HasPrefix(values[0], bearerPrefix) {
+		return status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+	token := strings.TrimPrefix(values[0], bearerPrefix)
+	if s.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+		return status.Error(codes.Unauthenticated, "invalid token")
+	}
+	return nil
+}
+
+func convertSession(sess *session.Session) *pb.Session {
+	return &pb.Session{
+		Id:         sess.ID,
+		User:       sess.User,
+		UserId:     sess.UserID,
+		Asset:      sess.Asset,
+		AssetId:    sess.AssetID,
+		Account:    sess.Account,
+		Protocol:   sess.Protocol,
+		LoginFrom:  string(sess.LoginFrom),
+		RemoteAddr: sess.RemoteAddr,
+		OrgId:      sess.OrgID,
+		DateStart:  sess.DateStart.Unix(),
+	}
+}
+
+func convertEvent(event session.Event) *pb.SessionEvent {
+	eventType := pb.SessionEvent_TYPE_UNSPECIFIED
+	switch event.Type {
+	case session.EventStarted:
+		eventType = pb.SessionEvent_STARTED
+	case session.EventEnded:
+		eventType = pb.SessionEvent_ENDED
+	}
+	return &pb.SessionEvent{
+		Type:      eventType,
+		Session:   convertSession(event.Session),
+		Timestamp: event.Time.UnixMilli(),
+	}
+}
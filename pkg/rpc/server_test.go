@@ -0,0 +1,132 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/rpc/pb"
+	"github.com/jumpserver/koko/pkg/session"
+)
+
+const testToken = "test-token"
+
+func newTestClient(t *testing.T) pb.SessionServiceClient {
+	ln := bufconn.Listen(1024 * 1024)
+	srv := NewServer("", testToken, nil)
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+			return ln.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return pb.NewSessionServiceClient(conn)
+}
+
+func withToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, authorizationKey, bearerPrefix+token)
+}
+
+func TestServerAuthenticate(t *testing.T) {
+	client := newTestClient(t)
+	_, err := client.ListSessions(context.Background(), &pb.ListSessionsRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("ListSessions() without token error = %v, want Unauthenticated", err)
+	}
+	_, err = client.ListSessions(withToken(context.Background(), "wrong"), &pb.ListSessionsRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("ListSessions() with wrong token error = %v, want Unauthenticated", err)
+	}
+	stream, err := client.WatchSessions(context.Background(), &pb.WatchSessionsRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("WatchSessions() without token error = %v, want Unauthenticated", err)
+	}
+}
+
+func TestServerSessions(t *testing.T) {
+	client := newTestClient(t)
+	ctx := withToken(context.Background(), testToken)
+
+	existing := session.NewSession(&model.Session{ID: "existing", User: "admin"}, nil)
+	session.AddSession(existing)
+	defer session.RemoveSession(existing)
+
+	resp, err := client.ListSessions(ctx, &pb.ListSessionsRequest{})
+	if err != nil {
+		t.Fatalf("ListSessions() error = %s", err)
+	}
+	if len(resp.Sessions) != 1 || resp.Sessions[0].Id != "existing" {
+		t.Errorf("ListSessions() = %v, want session existing", resp.Sessions)
+	}
+
+	goroutines := runtime.NumGoroutine()
+	watchCtx, cancel := context.WithCancel(ctx)
+	stream, err := client.WatchSessions(watchCtx, &pb.WatchSessionsRequest{IncludeExisting: true})
+	if err != nil {
+		t.Fatalf("WatchSessions() error = %s", err)
+	}
+	expectEvent(t, stream, pb.SessionEvent_STARTED, "existing")
+
+	terminated := make(chan string, 1)
+	var sess *session.Session
+	sess = session.NewSession(&model.Session{ID: "new"}, func(task *model.TerminalTask) error {
+		terminated <- task.Kwargs.TerminatedBy
+		session.RemoveSession(sess)
+		return nil
+	})
+	session.AddSession(sess)
+	expectEvent(t, stream, pb.SessionEvent_STARTED, "new")
+
+	_, err = client.TerminateSession(ctx, &pb.TerminateSessionRequest{Id: "new", TerminatedBy: "dashboard"})
+	if err != nil {
+		t.Fatalf("TerminateSession() error = %s", err)
+	}
+	if by := <-terminated; by != "dashboard" {
+		t.Errorf("TerminateSession() terminated by = %s, want dashboard", by)
+	}
+	expectEvent(t, stream, pb.SessionEvent_ENDED, "new")
+
+	_, err = client.TerminateSession(ctx, &pb.TerminateSessionRequest{Id: "new"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("TerminateSession() of ended session error = %v, want NotFound", err)
+	}
+
+	// 客户端断开后服务端的 WatchSessions 需要退出，不能泄漏 goroutine
+	cancel()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > goroutines && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > goroutines {
+		t.Errorf("goroutines after client disconnect = %d, want <= %d", n, goroutines)
+	}
+}
+
+func expectEvent(t *testing.T, stream pb.SessionService_WatchSessionsClient, eventType pb.SessionEvent_Type, sid string) {
+	t.Helper()
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("WatchSessions() recv error = %s", err)
+	}
+	if event.Type != eventType || event.Session.GetId() != sid {
+		t.Fatalf("WatchSessions() event = %v %s, want %v %s", event.Type, event.Session.GetId(), eventType, sid)
+	}
+}
@@ -0,0 +1,65 @@
+package session
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+type EventType string
+
+const (
+	EventStarted EventType = "started"
+	EventEnded   EventType = "ended"
+)
+
+type Event struct {
+	Type    EventType
+	Session *Session
+	Time    time.Time
+}
+
+const subscriberBufferSize = 64
+
+var broker = &eventBroker{subscribers: make(map[chan Event]struct{})}
+
+/*
+Subscribe 订阅会话的开始和结束事件，返回的取消函数必须调用以释放订阅。
+发布事件不会阻塞会话，订阅者处理不及时、缓冲区已满时丢弃事件。
+*/
+
+func Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBufferSize)
+	broker.Lock()
+	broker.subscribers[ch] = struct{}{}
+	broker.Unlock()
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			broker.Lock()
+			delete(broker.subscribers, ch)
+			broker.Unlock()
+			close(ch)
+		})
+	}
+	return ch, cancel
+}
+
+type eventBroker struct {
+	sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+func (b *eventBroker) publish(event Event) {
+	b.Lock()
+	defer b.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			logger.Warnf("Session event subscriber is full, drop %s event of session %s",
+				event.Type, event.Session.ID)
+		}
+	}
+}
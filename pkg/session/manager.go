@@ -1,7 +1,9 @@
 package session
 
 import (
+	"sort"
 	"sync"
+	"time"
 )
 
 var (
@@ -17,12 +19,37 @@ func GetAliveSessions() []string {
 	return sessManager.Range()
 }
 
+// GetAliveSessionList 返回当前节点所有活跃会话，按开始时间排序
+func GetAliveSessionList() []*Session {
+	sessions := sessManager.List()
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].DateStart.Before(sessions[j].DateStart.Time)
+	})
+	return sessions
+}
+
 func AddSession(s *Session) {
 	sessManager.Add(s.ID, s)
+	broker.publish(Event{Type: EventStarted, Session: s, Time: time.Now()})
+}
+
+/*
+AddSessionIf 在检查和添加期间持有锁，check 返回错误时不添加会话，
+用于并发连接时准确地限制会话数量
+*/
+
+func AddSessionIf(s *Session, check func(alive []*Session) error) error {
+	if err := sessManager.AddIf(s.ID, s, check); err != nil {
+		return err
+	}
+	broker.publish(Event{Type: EventStarted, Session: s, Time: time.Now()})
+	return nil
 }
 
 func RemoveSession(s *Session) {
-	sessManager.Delete(s.ID)
+	if sessManager.Delete(s.ID) {
+		broker.publish(Event{Type: EventEnded, Session: s, Time: time.Now()})
+	}
 }
 
 func newSessionManager() *sessionManager {
@@ -41,6 +68,21 @@ func (s *sessionManager) Add(id string, sess *Session) {
 	defer s.Unlock()
 	s.data[id] = sess
 }
+
+func (s *sessionManager) AddIf(id string, sess *Session, check func(alive []*Session) error) error {
+	s.Lock()
+	defer s.Unlock()
+	alive := make([]*Session, 0, len(s.data))
+	for _, item := range s.data {
+		alive = append(alive, item)
+	}
+	if err := check(alive); err != nil {
+		return err
+	}
+	s.data[id] = sess
+	return nil
+}
+
 func (s *sessionManager) Get(id string) (sess *Session, ok bool) {
 	s.Lock()
 	defer s.Unlock()
@@ -48,10 +90,12 @@ func (s *sessionManager) Get(id string) (sess *Session, ok bool) {
 	return
 }
 
-func (s *sessionManager) Delete(id string) {
+func (s *sessionManager) Delete(id string) bool {
 	s.Lock()
 	defer s.Unlock()
+	_, ok := s.data[id]
 	delete(s.data, id)
+	return ok
 }
 
 func (s *sessionManager) Range() []string {
@@ -64,3 +108,13 @@ func (s *sessionManager) Range() []string {
 
 	return sids
 }
+
+func (s *sessionManager) List() []*Session {
+	s.Lock()
+	defer s.Unlock()
+	sessions := make([]*Session, 0, len(s.data))
+	for _, sess := range s.data {
+		sessions = append(sessions, sess)
+	}
+	return sessions
+}
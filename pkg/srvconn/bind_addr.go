@@ -0,0 +1,171 @@
+package srvconn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+/*
+OUTBOUND_BIND_ADDRESSES 按目标地址选择连接资产和网关时使用的本地地址，格式为 "目标=本地地址"，
+目标为 CIDR(如 10.1.0.0/16)或域名后缀(如 .prod.example.com)，按配置顺序匹配第一条，都不匹配时由系统选择本地地址。
+配置错误或本地地址不可用时连接失败，不会忽略绑定使用默认地址连接。
+*/
+
+var ErrBindAddress = errors.New("outbound bind address invalid")
+
+type bindRule struct {
+	network *net.IPNet
+	suffix  string
+	local   net.IP
+}
+
+func (r bindRule) match(host string, ips []net.IP) bool {
+	if r.network == nil {
+		host = strings.TrimSuffix(strings.ToLower(host), ".")
+		return host == strings.TrimPrefix(r.suffix, ".") || strings.HasSuffix(host, r.suffix)
+	}
+	for i := range ips {
+		if r.network.Contains(ips[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseBindRules(entries []string) ([]bindRule, error) {
+	rules := make([]bindRule, 0, len(entries))
+	for _, entry := range entries {
+		target, local, ok := strings.Cut(entry, "=")
+		target = strings.TrimSpace(target)
+		local = strings.TrimSpace(local)
+		if !ok || target == "" || local == "" {
+			return nil, fmt.Errorf("%w: invalid entry %q", ErrBindAddress, entry)
+		}
+		rule := bindRule{local: net.ParseIP(local)}
+		if rule.local == nil {
+			return nil, fmt.Errorf("%w: %q is not an ip address in entry %q", ErrBindAddress, local, entry)
+		}
+		if strings.Contains(target, "/") {
+			_, network, err := net.ParseCIDR(target)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid cidr in entry %q: %s", ErrBindAddress, entry, err)
+			}
+			if (network.IP.To4() == nil) != (rule.local.To4() == nil) {
+				return nil, fmt.Errorf("%w: address family of %s and %s mismatch", ErrBindAddress, local, target)
+			}
+			rule.network = network
+		} else {
+			rule.suffix = "." + strings.Trim(strings.ToLower(target), ".")
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// matchBindAddress 返回连接 host 使用的本地地址，没有匹配的配置时返回 nil
+func matchBindAddress(rules []bindRule, host string) net.IP {
+	var ips []net.IP
+	resolved := false
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+		resolved = true
+	}
+	for i := range rules {
+		if rules[i].network != nil && !resolved {
+			// 域名只在需要匹配 CIDR 时解析，解析失败则不匹配任何 CIDR，由连接时报错
+			ips, _ = net.LookupIP(host)
+			resolved = true
+		}
+		if rules[i].match(host, ips) {
+			return rules[i].local
+		}
+	}
+	return nil
+}
+
+// localAddrAvailable 本地地址需要已经配置在本机的网卡上
+func localAddrAvailable(ip net.IP) error {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return err
+	}
+	for i := range addrs {
+		if ipNet, ok := addrs[i].(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not assigned to any local interface", ip)
+}
+
+// outboundDialer 根据 OUTBOUND_BIND_ADDRESSES 创建连接 addr 的 dialer
+func outboundDialer(addr string, timeout time.Duration) (*net.Dialer, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	entries := config.GetConf().OutboundBindAddresses
+	if len(entries) == 0 {
+		return dialer, nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := parseBindRules(entries)
+	if err != nil {
+		return nil, err
+	}
+	local := matchBindAddress(rules, host)
+	if local == nil {
+		return dialer, nil
+	}
+	if err = localAddrAvailable(local); err != nil {
+		return nil, fmt.Errorf("%w: bind %s for %s: %s", ErrBindAddress, local, addr, err)
+	}
+	logger.Debugf("Dial %s from local address %s", addr, local)
+	dialer.LocalAddr = &net.TCPAddr{IP: local}
+	return dialer, nil
+}
+
+// DialTCP 连接资产或网关，按配置绑定本地地址
+func DialTCP(addr string, timeout time.Duration) (net.Conn, error) {
+	return DialTCPContext(context.Background(), addr, timeout)
+}
+
+// DialTCPContext 与 DialTCP 相同，ctx 取消时放弃连接
+func DialTCPContext(ctx context.Context, addr string, timeout time.Duration) (net.Conn, error) {
+	dialer, err := outboundDialer(addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil && dialer.LocalAddr != nil {
+		return nil, fmt.Errorf("dial %s from %s: %w", addr, dialer.LocalAddr, err)
+	}
+	return conn, err
+}
+
+// DialSSH 与 gossh.Dial 相同，连接时按配置绑定本地地址
+func DialSSH(addr string, cfg *gossh.ClientConfig) (*gossh.Client, error) {
+	return DialSSHContext(context.Background(), addr, cfg)
+}
+
+// DialSSHContext 与 DialSSH 相同，ctx 取消时中断连接和握手
+func DialSSHContext(ctx context.Context, addr string, cfg *gossh.ClientConfig) (*gossh.Client, error) {
+	conn, err := DialTCPContext(ctx, addr, cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	c, chans, reqs, err := newClientConn(ctx, conn, addr, cfg)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return gossh.NewClient(c, chans, reqs), nil
+}
@@ -0,0 +1,95 @@
+package srvconn
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/jumpserver/koko/pkg/config"
+)
+
+func TestMatchBindAddress(t *testing.T) {
+	rules, err := parseBindRules([]string{"10.1.0.0/16=192.168.1.10", ".prod.example.com=192.168.2.10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "10.1.2.3", want: "192.168.1.10"},
+		{host: "db.prod.example.com", want: "192.168.2.10"},
+		{host: "10.2.0.1", want: "<nil>"},
+	}
+	for _, tt := range tests {
+		if local := matchBindAddress(rules, tt.host); local.String() != tt.want {
+			t.Errorf("matchBindAddress(%s) = %v, want %s", tt.host, local, tt.want)
+		}
+	}
+	for _, entry := range []string{"10.1.0.0/16", "10.1.0.0/33=192.168.1.10", "10.1.0.0/16=eth0", "10.1.0.0/16=::1"} {
+		if _, err = parseBindRules([]string{entry}); !errors.Is(err, ErrBindAddress) {
+			t.Errorf("parseBindRules(%q) err = %v, want %v", entry, err, ErrBindAddress)
+		}
+	}
+}
+
+func TestDialTCPBindAddress(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	conf := config.GetConf()
+	conf.OutboundBindAddresses = []string{"127.0.0.0/8=127.0.0.1"}
+	prevConf := config.GlobalConfig
+	config.GlobalConfig = &conf
+	t.Cleanup(func() { config.GlobalConfig = prevConf })
+
+	conn, err := DialTCP(ln.Addr().String(), time.Second)
+	if err != nil {
+		t.Fatalf("DialTCP() err = %v", err)
+	}
+	_ = conn.Close()
+	if local := conn.LocalAddr().(*net.TCPAddr); !local.IP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("local addr = %s, want 127.0.0.1", local)
+	}
+
+	// 本地没有的地址不能忽略绑定直接连接
+	conf.OutboundBindAddresses = []string{"127.0.0.0/8=192.0.2.10"}
+	if _, err = DialTCP(ln.Addr().String(), time.Second); !errors.Is(err, ErrBindAddress) {
+		t.Errorf("DialTCP() with unavailable bind address err = %v, want %v", err, ErrBindAddress)
+	}
+}
+
+func TestDialSSHContextCancel(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// 只接受连接不握手，模拟无响应的资产
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = DialSSHContext(ctx, ln.Addr().String(), &gossh.ClientConfig{
+		User: "root", HostKeyCallback: gossh.InsecureIgnoreHostKey(), Timeout: 10 * time.Second})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DialSSHContext() err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("DialSSHContext() returned after %s, want canceled with ctx", elapsed)
+	}
+}
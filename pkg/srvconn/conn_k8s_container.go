@@ -2,6 +2,7 @@ package srvconn
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -9,6 +10,7 @@ import (
 	"sync"
 
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
@@ -90,6 +92,11 @@ type ContainerConnection struct {
 	once sync.Once
 }
 
+// Shell 实际执行的 shell，指定的 shell 不存在时为回退的 shell
+func (c *ContainerConnection) Shell() string {
+	return c.shell
+}
+
 func (c *ContainerConnection) Read(p []byte) (int, error) {
 	return c.stdoutReader.Read(p)
 }
@@ -136,6 +143,9 @@ type ContainerOptions struct {
 	ContainerName string
 	IsSkipTls     bool
 	win           *remotecommand.TerminalSize
+
+	// Shell 优先使用的 shell，不存在时依次回退到 bash、sh
+	Shell string
 }
 
 func (o ContainerOptions) K8sCfg() *rest.Config {
@@ -189,6 +199,12 @@ func ContainerSkipTls(isSkipTls bool) ContainerOption {
 	}
 }
 
+func ContainerShell(shell string) ContainerOption {
+	return func(args *ContainerOptions) {
+		args.Shell = shell
+	}
+}
+
 func ContainerPtyWin(win Windows) ContainerOption {
 	return func(args *ContainerOptions) {
 		args.win = &remotecommand.TerminalSize{
@@ -200,6 +216,14 @@ func ContainerPtyWin(win Windows) ContainerOption {
 
 func FindAvailableShell(opt *ContainerOptions) (shell string, err error) {
 	shells := []string{"bash", "sh", "powershell", "cmd"}
+	if opt.Shell != "" {
+		shells = []string{opt.Shell}
+		for _, shell := range []string{"bash", "sh"} {
+			if shell != opt.Shell {
+				shells = append(shells, shell)
+			}
+		}
+	}
 	for i := range shells {
 		if err = HasShellInContainer(opt, shells[i]); err == nil {
 			return shells[i], nil
@@ -271,6 +295,27 @@ func HasShellInContainer(opt *ContainerOptions, shell string) error {
 	return validateChecker(result)
 }
 
+// ListPodContainers 获取 pod 中的容器名称，按 pod 定义的顺序排列
+func ListPodContainers(opts ...ContainerOption) ([]string, error) {
+	var opt ContainerOptions
+	for _, setter := range opts {
+		setter(&opt)
+	}
+	client, err := kubernetes.NewForConfig(opt.K8sCfg())
+	if err != nil {
+		return nil, err
+	}
+	pod, err := client.CoreV1().Pods(opt.Namespace).Get(context.TODO(), opt.PodName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	containers := make([]string, 0, len(pod.Spec.Containers))
+	for i := range pod.Spec.Containers {
+		containers = append(containers, pod.Spec.Containers[i].Name)
+	}
+	return containers, nil
+}
+
 type slaveStream struct {
 	r           io.ReadCloser
 	w           io.WriteCloser
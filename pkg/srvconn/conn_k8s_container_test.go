@@ -0,0 +1,35 @@
+package srvconn
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestListPodContainers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/prod/pods/web-0" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"kind":"Pod","apiVersion":"v1","metadata":{"name":"web-0","namespace":"prod"},
+			"spec":{"containers":[{"name":"nginx"},{"name":"app"},{"name":"log-agent"}]}}`))
+	}))
+	defer server.Close()
+
+	containers, err := ListPodContainers(ContainerHost(server.URL), ContainerToken("token"),
+		ContainerNamespace("prod"), ContainerPodName("web-0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"nginx", "app", "log-agent"}; !reflect.DeepEqual(containers, want) {
+		t.Errorf("ListPodContainers() = %v, want %v", containers, want)
+	}
+	if _, err = ListPodContainers(ContainerHost(server.URL), ContainerNamespace("prod"),
+		ContainerPodName("missing")); err == nil {
+		t.Error("ListPodContainers() missing pod err = nil")
+	}
+}
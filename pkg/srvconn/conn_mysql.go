@@ -2,6 +2,7 @@ package srvconn
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"net"
 	"os"
@@ -12,9 +13,11 @@ import (
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 
+	"github.com/jumpserver/koko/pkg/common"
 	"github.com/jumpserver/koko/pkg/localcommand"
 	"github.com/jumpserver/koko/pkg/logger"
 )
@@ -23,8 +26,15 @@ const (
 	mysqlPrompt = "Enter password: "
 
 	mysqlShellFilename = "mysql"
+
+	// 连接前检查使用的网络，限制 TLS 握手的时间
+	mysqlCheckNetwork = "koko-tcp"
 )
 
+func init() {
+	mysql.RegisterDialContext(mysqlCheckNetwork, dialWithDeadline)
+}
+
 var (
 	mysqlShellPath = ""
 
@@ -60,7 +70,12 @@ func NewMySQLConnection(ops ...SqlOption) (*MySQLConn, error) {
 	for _, setter := range ops {
 		setter(args)
 	}
-	if err := checkMySQLAccount(args); err != nil {
+	tempFiles, err := storeMySQLCertFiles(args)
+	if err != nil {
+		return nil, err
+	}
+	defer ClearTempFileDelay(time.Minute, tempFiles...)
+	if err = checkMySQLAccount(args); err != nil {
 		return nil, err
 	}
 	lCmd, err := startMySQLCommand(args)
@@ -196,6 +211,7 @@ func (opt *sqlOption) CommandArgs() []string {
 		charset = strings.TrimSpace(charset)
 		args = append(args, fmt.Sprintf("--default-character-set=%s", charset))
 	}
+	args = append(args, opt.mysqlTLSArgs()...)
 	args = append(args, fmt.Sprintf("--user=%s", opt.Username))
 	args = append(args, fmt.Sprintf("--host=%s", opt.Host))
 	args = append(args, fmt.Sprintf("--port=%d", opt.Port))
@@ -213,6 +229,7 @@ func (opt *sqlOption) Envs() []string {
 		charset = strings.TrimSpace(charset)
 		extraArgs = append(extraArgs, fmt.Sprintf("--default-character-set=%s", charset))
 	}
+	extraArgs = append(extraArgs, opt.mysqlTLSArgs()...)
 
 	envs := make([]string, 0, 6)
 	envs = append(envs, fmt.Sprintf("USERNAME=%s", opt.Username))
@@ -226,14 +243,59 @@ func (opt *sqlOption) Envs() []string {
 func (opt *sqlOption) DataSourceName() string {
 	// "user:password@tcp(127.0.0.1:3306)/hello"
 	addr := net.JoinHostPort(opt.Host, strconv.Itoa(opt.Port))
-	return fmt.Sprintf("%s:%s@tcp(%s)/%s",
+	return fmt.Sprintf("%s:%s@%s(%s)/%s",
 		opt.Username,
 		opt.Password,
+		mysqlCheckNetwork,
 		addr,
 		opt.DBName,
 	)
 }
 
+/*
+mysqlTLSArgs 客户端(mariadb-client)的 TLS 参数。
+通过网关隧道连接时客户端连接的是隧道的本地地址，无法校验证书中的主机名，
+不使用 --ssl-verify-server-cert，由连接前的检查使用资产地址校验证书。
+*/
+
+func (opt *sqlOption) mysqlTLSArgs() []string {
+	args := []string{fmt.Sprintf("--connect-timeout=%d", int(databaseConnectTimeout.Seconds()))}
+	if !opt.UseSSL {
+		return args
+	}
+	args = append(args, "--ssl")
+	if opt.CaCertPath != "" {
+		args = append(args, fmt.Sprintf("--ssl-ca=%s", opt.CaCertPath))
+	}
+	if opt.ClientCertPath != "" {
+		args = append(args, fmt.Sprintf("--ssl-cert=%s", opt.ClientCertPath))
+	}
+	if opt.CertKeyPath != "" {
+		args = append(args, fmt.Sprintf("--ssl-key=%s", opt.CertKeyPath))
+	}
+	if !opt.AllowInvalidCert && opt.SSLServerName == "" {
+		args = append(args, "--ssl-verify-server-cert")
+	}
+	return args
+}
+
+// storeMySQLCertFiles 保存证书到临时文件，不校验服务端证书时不使用 CA 证书
+func storeMySQLCertFiles(args *sqlOption) ([]string, error) {
+	if !args.UseSSL {
+		return nil, nil
+	}
+	caCert := args.CaCert
+	if args.AllowInvalidCert {
+		caCert = ""
+	}
+	files, err := storeDatabaseCertFiles(caCert, args.ClientCert, args.CertKey)
+	if err != nil {
+		return nil, err
+	}
+	args.CaCertPath, args.ClientCertPath, args.CertKeyPath = files[0], files[1], files[2]
+	return files, nil
+}
+
 func MySQLDisableAutoReHash() SqlOption {
 	return func(args *sqlOption) {
 		args.disableMySQLAutoRehash = true
@@ -241,5 +303,27 @@ func MySQLDisableAutoReHash() SqlOption {
 }
 
 func checkMySQLAccount(args *sqlOption) error {
-	return checkDatabaseAccountValidate("mysql", args.DataSourceName())
+	dsn := args.DataSourceName()
+	if args.UseSSL {
+		serverName := args.Host
+		if args.SSLServerName != "" {
+			serverName = args.SSLServerName
+		}
+		tlsConf, err := databaseTLSConfig(args, serverName)
+		if err != nil {
+			return err
+		}
+		name := common.UUID()
+		if err = mysql.RegisterTLSConfig(name, tlsConf); err != nil {
+			return err
+		}
+		defer mysql.DeregisterTLSConfig(name)
+		dsn += "?tls=" + name
+	}
+	err := checkDatabaseAccountValidate("mysql", dsn)
+	if args.UseSSL && errors.Is(err, mysql.ErrInvalidConn) {
+		// TLS 1.3 服务端拒绝客户端证书时，握手后第一次读取才失败，驱动只返回 invalid connection
+		return fmt.Errorf("%w: %s", ErrDatabaseTLS, err)
+	}
+	return wrapDatabaseTLSError(args.UseSSL, err)
 }
@@ -0,0 +1,216 @@
+package srvconn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	mysqlClientProtocol41 = 0x200
+	mysqlClientSSL        = 0x800
+	mysqlSecureConn       = 0x8000
+	mysqlPluginAuth       = 0x80000
+)
+
+// newTestClientCert 返回自签名的客户端证书和私钥
+func newTestClientCert(t *testing.T) (certPEM, keyPEM string, cert *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "koko"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ = x509.ParseCertificate(der)
+	certPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return certPEM, keyPEM, cert
+}
+
+func mysqlPacket(seq byte, body []byte) []byte {
+	header := []byte{byte(len(body)), byte(len(body) >> 8), byte(len(body) >> 16), seq}
+	return append(header, body...)
+}
+
+func readMySQLPacket(r io.Reader) ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	body := make([]byte, int(header[0])|int(header[1])<<8|int(header[2])<<16)
+	_, err := io.ReadFull(r, body)
+	return body, err
+}
+
+func mysqlHandshakePacket() []byte {
+	flags := uint32(mysqlClientProtocol41 | mysqlClientSSL | mysqlSecureConn | mysqlPluginAuth)
+	body := []byte{10}
+	body = append(body, "8.0.0\x00"...)
+	body = append(body, 1, 0, 0, 0)
+	body = append(body, "abcdefgh"...)
+	body = append(body, 0)
+	body = binary.LittleEndian.AppendUint16(body, uint16(flags))
+	body = append(body, 33, 2, 0)
+	body = binary.LittleEndian.AppendUint16(body, uint16(flags>>16))
+	body = append(body, 21)
+	body = append(body, make([]byte, 10)...)
+	body = append(body, "ijklmnopqrst\x00"...)
+	body = append(body, "mysql_native_password\x00"...)
+	return mysqlPacket(0, body)
+}
+
+var mysqlOKPacket = []byte{0, 0, 0, 2, 0, 0, 0}
+
+/*
+startMTLSMySQL 模拟要求客户端证书的 MySQL，认证直接通过。
+stall 为 true 时收到 SSL 请求后不再响应，模拟握手没有响应的服务端。
+*/
+
+func startMTLSMySQL(t *testing.T, serverCert tls.Certificate, clientCA *x509.Certificate, stall bool) *net.TCPAddr {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCA)
+	tlsConf := &tls.Config{Certificates: []tls.Certificate{serverCert},
+		ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveMySQL(conn, tlsConf, stall)
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr)
+}
+
+func serveMySQL(conn net.Conn, tlsConf *tls.Config, stall bool) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write(mysqlHandshakePacket()); err != nil {
+		return
+	}
+	// SSL 请求
+	if _, err := readMySQLPacket(conn); err != nil {
+		return
+	}
+	if stall {
+		_, _ = io.Copy(io.Discard, conn)
+		return
+	}
+	tlsConn := tls.Server(conn, tlsConf)
+	if err := tlsConn.Handshake(); err != nil {
+		return
+	}
+	if _, err := readMySQLPacket(tlsConn); err != nil {
+		return
+	}
+	if _, err := tlsConn.Write(mysqlPacket(3, mysqlOKPacket)); err != nil {
+		return
+	}
+	for {
+		body, err := readMySQLPacket(tlsConn)
+		if err != nil || len(body) == 0 || body[0] == 0x01 {
+			return
+		}
+		// COM_PING 等命令直接返回 OK
+		_, _ = tlsConn.Write(mysqlPacket(1, mysqlOKPacket))
+	}
+}
+
+func TestCheckMySQLAccountMTLS(t *testing.T) {
+	defer func(timeout time.Duration) { databaseConnectTimeout = timeout }(databaseConnectTimeout)
+	databaseConnectTimeout = time.Second
+
+	serverCert, caCert := newTestServerCert(t, "db.example.com")
+	clientCert, clientKey, clientCA := newTestClientCert(t)
+	addr := startMTLSMySQL(t, serverCert, clientCA, false)
+	stallAddr := startMTLSMySQL(t, serverCert, clientCA, true)
+	tests := []struct {
+		name    string
+		addr    *net.TCPAddr
+		opts    []SqlOption
+		wantTLS bool
+	}{
+		{name: "client cert", addr: addr, opts: []SqlOption{SqlCaCert(caCert),
+			SqlClientCert(clientCert), SqlCertKey(clientKey), SqlSSLServerName("db.example.com")}},
+		{name: "missing client cert", addr: addr, wantTLS: true,
+			opts: []SqlOption{SqlCaCert(caCert), SqlSSLServerName("db.example.com")}},
+		{name: "unknown server ca", addr: addr, wantTLS: true,
+			opts: []SqlOption{SqlClientCert(clientCert), SqlCertKey(clientKey), SqlSSLServerName("db.example.com")}},
+		{name: "handshake no response", addr: stallAddr, wantTLS: true,
+			opts: []SqlOption{SqlAllowInvalidCert(true), SqlClientCert(clientCert), SqlCertKey(clientKey)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := &sqlOption{Host: tt.addr.IP.String(), Port: tt.addr.Port,
+				Username: "root", Password: "secret", UseSSL: true}
+			for _, setter := range tt.opts {
+				setter(args)
+			}
+			start := time.Now()
+			err := checkMySQLAccount(args)
+			if tt.wantTLS != errors.Is(err, ErrDatabaseTLS) || (!tt.wantTLS && err != nil) {
+				t.Errorf("checkMySQLAccount() err = %v, want tls error %v", err, tt.wantTLS)
+			}
+			if elapsed := time.Since(start); elapsed > 4*databaseConnectTimeout {
+				t.Errorf("checkMySQLAccount() took %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestMySQLTLSArgs(t *testing.T) {
+	opt := &sqlOption{UseSSL: true, CaCertPath: "/tmp/ca.pem", ClientCertPath: "/tmp/cert.pem",
+		CertKeyPath: "/tmp/key.pem"}
+	args := strings.Join(opt.mysqlTLSArgs(), " ")
+	for _, want := range []string{"--ssl ", "--ssl-ca=/tmp/ca.pem", "--ssl-cert=/tmp/cert.pem",
+		"--ssl-key=/tmp/key.pem", "--ssl-verify-server-cert"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("mysqlTLSArgs() = %s, want %s", args, want)
+		}
+	}
+	// 通过网关隧道连接时客户端不校验主机名
+	opt.SSLServerName = "db.example.com"
+	if args = strings.Join(opt.mysqlTLSArgs(), " "); strings.Contains(args, "--ssl-verify-server-cert") {
+		t.Errorf("mysqlTLSArgs() through tunnel = %s", args)
+	}
+	opt.UseSSL = false
+	if args = strings.Join(opt.mysqlTLSArgs(), " "); strings.Contains(args, "--ssl") {
+		t.Errorf("mysqlTLSArgs() without ssl = %s", args)
+	}
+}
@@ -1,19 +1,30 @@
 package srvconn
 
 import (
+	"database/sql"
 	"fmt"
+	"net"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 
 	"github.com/jumpserver/koko/pkg/localcommand"
+	"github.com/jumpserver/koko/pkg/logger"
 )
 
 const (
 	PostgreSQLPrompt = "Password for user %s:"
 )
 
+const (
+	PostgreSQLSSLDisable    = "disable"
+	PostgreSQLSSLRequire    = "require"
+	PostgreSQLSSLVerifyFull = "verify-full"
+)
+
 func NewPostgreSQLConnection(ops ...SqlOption) (*PostgreSQLConn, error) {
 	args := &sqlOption{
 		Username: os.Getenv("USER"),
@@ -29,6 +40,11 @@ func NewPostgreSQLConnection(ops ...SqlOption) (*PostgreSQLConn, error) {
 	for _, setter := range ops {
 		setter(args)
 	}
+	tempFiles, err := storePostgreSQLCertFiles(args)
+	if err != nil {
+		return nil, err
+	}
+	defer ClearTempFileDelay(time.Minute, tempFiles...)
 	if err := checkPostgreSQLAccount(args); err != nil {
 		return nil, err
 	}
@@ -61,7 +77,8 @@ func (conn *PostgreSQLConn) Close() error {
 func startPostgreSQLCommand(opt *sqlOption) (lcmd *localcommand.LocalCommand, err error) {
 	argv := opt.PostgreSQLCommandArgs()
 	//psql 是启动postgresql的客户端
-	lcmd, err = localcommand.New("psql", argv, localcommand.WithPtyWin(opt.win.Width, opt.win.Height))
+	lcmd, err = localcommand.New("psql", argv, localcommand.WithEnv(opt.PostgreSQLEnvs()),
+		localcommand.WithPtyWin(opt.win.Width, opt.win.Height))
 	if err != nil {
 		return nil, err
 	}
@@ -81,21 +98,135 @@ func startPostgreSQLCommand(opt *sqlOption) (lcmd *localcommand.LocalCommand, er
 func (opt *sqlOption) PostgreSQLCommandArgs() []string {
 	return []string{
 		"-U", opt.Username,
-		"-h", opt.Host,
+		"-h", opt.postgreSQLHost(),
 		"-p", strconv.Itoa(opt.Port),
 		"-d", opt.DBName,
 	}
 }
 
+// postgreSQLHost 通过网关隧道连接时使用资产地址校验证书，实际连接隧道地址
+func (opt *sqlOption) postgreSQLHost() string {
+	if opt.SSLServerName != "" {
+		return opt.SSLServerName
+	}
+	return opt.Host
+}
+
+// PostgreSQLSSLMode 资产未配置有效的 SSL 模式时，根据 UseSSL 和 AllowInvalidCert 判断
+func (opt *sqlOption) PostgreSQLSSLMode() string {
+	mode := strings.ToLower(strings.TrimSpace(opt.SSLMode))
+	switch mode {
+	case PostgreSQLSSLDisable, PostgreSQLSSLRequire, PostgreSQLSSLVerifyFull:
+		return mode
+	case "":
+	default:
+		logger.Errorf("Invalid PostgreSQL ssl mode %s, ignore it", opt.SSLMode)
+	}
+	switch {
+	case !opt.UseSSL:
+		return PostgreSQLSSLDisable
+	case opt.AllowInvalidCert:
+		return PostgreSQLSSLRequire
+	default:
+		return PostgreSQLSSLVerifyFull
+	}
+}
+
+// PostgreSQLEnvs psql 通过环境变量设置 SSL 参数，避免出现在进程参数中
+func (opt *sqlOption) PostgreSQLEnvs() []string {
+	envs := os.Environ()
+	envs = append(envs, fmt.Sprintf("PGSSLMODE=%s", opt.PostgreSQLSSLMode()))
+	envs = append(envs, fmt.Sprintf("PGCONNECT_TIMEOUT=%d", int(databaseConnectTimeout.Seconds())))
+	if opt.SSLServerName != "" {
+		envs = append(envs, fmt.Sprintf("PGHOSTADDR=%s", opt.Host))
+	}
+	if opt.CaCertPath != "" {
+		envs = append(envs, fmt.Sprintf("PGSSLROOTCERT=%s", opt.CaCertPath))
+	}
+	if opt.ClientCertPath != "" {
+		envs = append(envs, fmt.Sprintf("PGSSLCERT=%s", opt.ClientCertPath))
+	}
+	if opt.CertKeyPath != "" {
+		envs = append(envs, fmt.Sprintf("PGSSLKEY=%s", opt.CertKeyPath))
+	}
+	return envs
+}
+
 func (opt *sqlOption) PostgreSQLDataSourceName() string {
-	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		opt.Host,
+	// connect_timeout 包括 SSL 握手，服务端没有响应时不会一直等待
+	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s connect_timeout=%d",
+		opt.postgreSQLHost(),
 		opt.Port,
 		opt.Username,
 		opt.Password,
 		opt.DBName,
+		opt.PostgreSQLSSLMode(),
+		int(databaseConnectTimeout.Seconds()),
 	)
+	if opt.CaCertPath != "" {
+		dsn += fmt.Sprintf(" sslrootcert=%s", opt.CaCertPath)
+	}
+	if opt.ClientCertPath != "" {
+		dsn += fmt.Sprintf(" sslcert=%s", opt.ClientCertPath)
+	}
+	if opt.CertKeyPath != "" {
+		dsn += fmt.Sprintf(" sslkey=%s", opt.CertKeyPath)
+	}
+	return dsn
 }
+
+// storePostgreSQLCertFiles 保存证书到临时文件，返回的文件需要调用方清理
+func storePostgreSQLCertFiles(args *sqlOption) ([]string, error) {
+	args.SSLMode = args.PostgreSQLSSLMode()
+	if args.SSLMode == PostgreSQLSSLDisable {
+		return nil, nil
+	}
+	// require 模式不校验服务端证书，只有 verify-full 才使用 CA 证书
+	caCert := ""
+	if args.SSLMode == PostgreSQLSSLVerifyFull {
+		caCert = args.CaCert
+	}
+	caCertPath, err := StoreCAFileToLocal(caCert)
+	if err != nil {
+		return nil, err
+	}
+	certKeyPath, err := StoreCAFileToLocal(args.CertKey)
+	if err != nil {
+		return nil, err
+	}
+	clientCertPath, err := StoreCAFileToLocal(args.ClientCert)
+	if err != nil {
+		return nil, err
+	}
+	args.CaCertPath = caCertPath
+	args.CertKeyPath = certKeyPath
+	args.ClientCertPath = clientCertPath
+	return []string{caCertPath, certKeyPath, clientCertPath}, nil
+}
+
 func checkPostgreSQLAccount(args *sqlOption) error {
-	return checkDatabaseAccountValidate("postgres", args.PostgreSQLDataSourceName())
+	useSSL := args.PostgreSQLSSLMode() != PostgreSQLSSLDisable
+	if args.SSLServerName == "" {
+		err := checkDatabaseAccountValidate("postgres", args.PostgreSQLDataSourceName())
+		return wrapDatabaseTLSError(useSSL, err)
+	}
+	connector, err := pq.NewConnector(args.PostgreSQLDataSourceName())
+	if err != nil {
+		return err
+	}
+	connector.Dialer(tunnelDialer{addr: net.JoinHostPort(args.Host, strconv.Itoa(args.Port))})
+	return wrapDatabaseTLSError(useSSL, pingDatabase(sql.OpenDB(connector)))
+}
+
+// tunnelDialer 忽略 DSN 中的地址，连接网关隧道的本地地址
+type tunnelDialer struct {
+	addr string
+}
+
+func (d tunnelDialer) Dial(network, _ string) (net.Conn, error) {
+	return net.Dial(network, d.addr)
+}
+
+func (d tunnelDialer) DialTimeout(network, _ string, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout(network, d.addr, timeout)
 }
@@ -0,0 +1,181 @@
+package srvconn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+const pgSSLRequestCode = 80877103
+
+func newTestServerCert(t *testing.T, dnsName string) (tls.Certificate, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: dnsName},
+		DNSNames:              []string{dnsName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, string(certPEM)
+}
+
+// startSSLRequiredPostgreSQL 模拟只接受 SSL 连接的 PostgreSQL，认证直接通过
+func startSSLRequiredPostgreSQL(t *testing.T, cert tls.Certificate) *net.TCPAddr {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	tlsConf := &tls.Config{Certificates: []tls.Certificate{cert}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go servePostgreSQL(conn, tlsConf)
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr)
+}
+
+func servePostgreSQL(conn net.Conn, tlsConf *tls.Config) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	startup, err := readStartupMessage(conn)
+	if err != nil {
+		return
+	}
+	if len(startup) < 4 || binary.BigEndian.Uint32(startup) != pgSSLRequestCode {
+		msg := "SFATAL\x00C28000\x00Mno encryption\x00\x00"
+		_, _ = conn.Write(pgMessage('E', []byte(msg)))
+		return
+	}
+	if _, err = conn.Write([]byte("S")); err != nil {
+		return
+	}
+	tlsConn := tls.Server(conn, tlsConf)
+	if _, err = readStartupMessage(tlsConn); err != nil {
+		return
+	}
+	_, _ = tlsConn.Write(pgMessage('R', []byte{0, 0, 0, 0}))
+	_, _ = tlsConn.Write(pgMessage('Z', []byte("I")))
+	header := make([]byte, 5)
+	for {
+		if _, err = io.ReadFull(tlsConn, header); err != nil {
+			return
+		}
+		body := make([]byte, binary.BigEndian.Uint32(header[1:])-4)
+		if _, err = io.ReadFull(tlsConn, body); err != nil {
+			return
+		}
+		switch header[0] {
+		case 'Q':
+			_, _ = tlsConn.Write(pgMessage('I', nil))
+			_, _ = tlsConn.Write(pgMessage('Z', []byte("I")))
+		case 'X':
+			return
+		}
+	}
+}
+
+func readStartupMessage(r io.Reader) ([]byte, error) {
+	length := make([]byte, 4)
+	if _, err := io.ReadFull(r, length); err != nil {
+		return nil, err
+	}
+	body := make([]byte, binary.BigEndian.Uint32(length)-4)
+	_, err := io.ReadFull(r, body)
+	return body, err
+}
+
+func pgMessage(typ byte, body []byte) []byte {
+	msg := make([]byte, 5, 5+len(body))
+	msg[0] = typ
+	binary.BigEndian.PutUint32(msg[1:], uint32(4+len(body)))
+	return append(msg, body...)
+}
+
+func TestCheckPostgreSQLAccountSSL(t *testing.T) {
+	// 证书临时文件保存在当前目录下
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(wd) }()
+
+	cert, caCert := newTestServerCert(t, "db.example.com")
+	addr := startSSLRequiredPostgreSQL(t, cert)
+	tests := []struct {
+		name    string
+		opts    []SqlOption
+		mode    string
+		wantErr bool
+	}{
+		{name: "plaintext rejected", mode: PostgreSQLSSLDisable, wantErr: true},
+		{name: "require skip verify", mode: PostgreSQLSSLRequire,
+			opts: []SqlOption{SqlUseSSL(true), SqlAllowInvalidCert(true)}},
+		{name: "verify full through tunnel", mode: PostgreSQLSSLVerifyFull,
+			opts: []SqlOption{SqlUseSSL(true), SqlCaCert(caCert), SqlSSLServerName("db.example.com")}},
+		{name: "verify full host mismatch", mode: PostgreSQLSSLVerifyFull, wantErr: true,
+			opts: []SqlOption{SqlUseSSL(true), SqlCaCert(caCert)}},
+		{name: "verify full unknown ca", mode: PostgreSQLSSLVerifyFull, wantErr: true,
+			opts: []SqlOption{SqlSSLMode("verify-full"), SqlSSLServerName("db.example.com")}},
+		{name: "explicit mode overrides", mode: PostgreSQLSSLRequire,
+			opts: []SqlOption{SqlSSLMode("Require")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := &sqlOption{Host: addr.IP.String(), Port: addr.Port,
+				Username: "postgres", Password: "secret", DBName: "postgres"}
+			for _, setter := range tt.opts {
+				setter(args)
+			}
+			tempFiles, err := storePostgreSQLCertFiles(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				for _, file := range tempFiles {
+					_ = os.Remove(file)
+				}
+			}()
+			if args.SSLMode != tt.mode {
+				t.Errorf("ssl mode = %s, want %s", args.SSLMode, tt.mode)
+			}
+			err = checkPostgreSQLAccount(args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkPostgreSQLAccount() err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
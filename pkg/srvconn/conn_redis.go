@@ -3,17 +3,25 @@ package srvconn
 import (
 	"crypto/tls"
 	"crypto/x509"
-	"fmt"
+	"errors"
+	"net"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/jumpserver/koko/pkg/localcommand"
 	"github.com/mediocregopher/radix/v3"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+
+	"github.com/jumpserver/koko/pkg/localcommand"
+	"github.com/jumpserver/koko/pkg/logger"
 )
 
 const (
 	redisPrompt = "Please input password:"
+
+	// Redis 6 ACL 的默认用户，与不指定用户名相同
+	redisDefaultUser = "default"
 )
 
 var (
@@ -115,7 +123,14 @@ func startRedisCommand(opt *sqlOption) (lcmd *localcommand.LocalCommand, err err
 func (opt *sqlOption) RedisCommandArgs() []string {
 	params := []string{
 		"-h", opt.Host, "-p", strconv.Itoa(opt.Port),
-		"-n", opt.DBName,
+	}
+	if opt.redisCluster {
+		// 集群模式不支持 SELECT，只能使用 0 号库
+		if opt.redisClusterRedirect {
+			params = append(params, "-c")
+		}
+	} else {
+		params = append(params, "-n", opt.DBName)
 	}
 	if opt.UseSSL {
 		params = append(params, "--tls")
@@ -127,7 +142,7 @@ func (opt *sqlOption) RedisCommandArgs() []string {
 			params = append(params, "--key", opt.CertKeyPath)
 		}
 	}
-	if opt.Username != "" {
+	if opt.Username != "" && opt.Username != redisDefaultUser {
 		params = append(params, "--user", opt.Username)
 	}
 	if opt.Password != "" {
@@ -137,14 +152,63 @@ func (opt *sqlOption) RedisCommandArgs() []string {
 	return params
 }
 
+/*
+redisAuth 按账号的认证信息认证，与 redis-cli 一致：
+没有密码时不认证；用户名为空或者为 default 时使用 AUTH password，兼容 Redis 6 之前的版本；
+否则使用 Redis 6 ACL 的 AUTH username password。用户名不是平台要求的(SqlRedisUserOptional)时，
+Redis 6 之前的版本不支持用户名或者用户名认证失败，会再使用 AUTH password 认证，之后 redis-cli 也不使用用户名。
+*/
+
+func redisAuth(conn radix.Conn, args *sqlOption) error {
+	if args.Password == "" {
+		return nil
+	}
+	if args.Username == "" || args.Username == redisDefaultUser {
+		return conn.Do(radix.Cmd(nil, "AUTH", args.Password))
+	}
+	err := conn.Do(radix.Cmd(nil, "AUTH", args.Username, args.Password))
+	if err == nil || !isRedisAuthFallback(err, args.redisUserOptional) {
+		return err
+	}
+	logger.Infof("Redis %s:%d auth with user %s failed, retry with password only: %s",
+		args.Host, args.Port, args.Username, err)
+	if err = conn.Do(radix.Cmd(nil, "AUTH", args.Password)); err != nil {
+		return err
+	}
+	args.Username = ""
+	return nil
+}
+
+// isRedisAuthFallback Redis 6 之前的版本 AUTH 只有一个参数
+func isRedisAuthFallback(err error, userOptional bool) bool {
+	var respErr resp2.Error
+	if !errors.As(err, &respErr) {
+		return false
+	}
+	msg := respErr.Error()
+	if strings.Contains(msg, "wrong number of arguments") {
+		return true
+	}
+	return userOptional && strings.HasPrefix(msg, "WRONGPASS")
+}
+
+// isRedisCluster INFO cluster 中 cluster_enabled 为 1 时是集群模式，不支持 INFO 的服务按单节点处理
+func isRedisCluster(conn radix.Conn) bool {
+	var info string
+	if err := conn.Do(radix.Cmd(&info, "INFO", "cluster")); err != nil {
+		return false
+	}
+	for _, line := range strings.Split(info, "\n") {
+		if strings.TrimSpace(line) == "cluster_enabled:1" {
+			return true
+		}
+	}
+	return false
+}
+
 func checkRedisAccount(args *sqlOption) error {
 	var dialOptions []radix.DialOpt
-	addr := fmt.Sprintf("%s:%s", args.Host, strconv.Itoa(args.Port))
-	if args.Username != "" {
-		dialOptions = append(dialOptions, radix.DialAuthUser(args.Username, args.Password))
-	} else {
-		dialOptions = append(dialOptions, radix.DialAuthPass(args.Password))
-	}
+	addr := net.JoinHostPort(args.Host, strconv.Itoa(args.Port))
 
 	if args.UseSSL {
 		tlsConfig := tls.Config{}
@@ -170,6 +234,18 @@ func checkRedisAccount(args *sqlOption) error {
 		return err
 	}
 	defer conn.Close()
-	err = conn.Do(radix.Cmd(nil, "PING"))
-	return err
+	if err = redisAuth(conn, args); err != nil {
+		return err
+	}
+	if err = conn.Do(radix.Cmd(nil, "PING")); err != nil {
+		return err
+	}
+	if args.redisCluster = isRedisCluster(conn); args.redisCluster {
+		if args.redisClusterRedirect {
+			logger.Infof("Redis %s is a cluster node, follow MOVED and ASK redirects", addr)
+		} else {
+			logger.Infof("Redis %s is a cluster node, redirects are not followed through the gateway", addr)
+		}
+	}
+	return nil
 }
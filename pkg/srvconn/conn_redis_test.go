@@ -0,0 +1,190 @@
+package srvconn
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRedis 模拟 Redis 服务，记录收到的命令，reply 返回 RESP 格式的回复
+type fakeRedis struct {
+	mu       sync.Mutex
+	commands []string
+	reply    func(args []string) string
+}
+
+func (f *fakeRedis) serve(t *testing.T) *net.TCPAddr {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.handle(conn)
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr)
+}
+
+func (f *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readRESPCommand(reader)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, strings.Join(args, " "))
+		f.mu.Unlock()
+		if _, err = conn.Write([]byte(f.reply(args))); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeRedis) received() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.commands...)
+}
+
+func readRESPCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		if _, err = reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, strings.TrimSuffix(arg, "\r\n"))
+	}
+	return args, nil
+}
+
+func bulkString(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+// redisReplies 模拟的服务版本，legacy 为 Redis 6 之前的版本，users 为 ACL 用户和密码
+func redisReplies(legacy, cluster bool, users map[string]string) func(args []string) string {
+	return func(args []string) string {
+		switch strings.ToUpper(args[0]) {
+		case "AUTH":
+			if len(args) == 3 {
+				if legacy {
+					return "-ERR wrong number of arguments for 'auth' command\r\n"
+				}
+				if pass, ok := users[args[1]]; ok && pass == args[2] {
+					return "+OK\r\n"
+				}
+				return "-WRONGPASS invalid username-password pair or user is disabled.\r\n"
+			}
+			if users["default"] == args[1] {
+				return "+OK\r\n"
+			}
+			return "-ERR invalid password\r\n"
+		case "PING":
+			return "+PONG\r\n"
+		case "INFO":
+			if cluster {
+				return bulkString("# Cluster\r\ncluster_enabled:1\r\n")
+			}
+			return bulkString("# Cluster\r\ncluster_enabled:0\r\n")
+		}
+		return "-ERR unknown command\r\n"
+	}
+}
+
+func TestCheckRedisAccountAuth(t *testing.T) {
+	users := map[string]string{"default": "root-pass", "app": "app-pass"}
+	tests := []struct {
+		name         string
+		legacy       bool
+		username     string
+		password     string
+		userOptional bool
+		wantErr      bool
+		wantAuth     []string
+		wantUsername string
+	}{
+		{name: "no password", username: "app", wantAuth: nil, wantUsername: "app"},
+		{name: "password only", password: "root-pass", wantAuth: []string{"AUTH root-pass"}},
+		{name: "default user", username: "default", password: "root-pass",
+			wantAuth: []string{"AUTH root-pass"}, wantUsername: "default"},
+		{name: "acl user", username: "app", password: "app-pass",
+			wantAuth: []string{"AUTH app app-pass"}, wantUsername: "app"},
+		{name: "acl wrong password", username: "app", password: "root-pass", wantErr: true,
+			wantAuth: []string{"AUTH app root-pass"}, wantUsername: "app"},
+		{name: "optional user fallback", username: "root", password: "root-pass", userOptional: true,
+			wantAuth: []string{"AUTH root root-pass", "AUTH root-pass"}},
+		{name: "legacy redis", legacy: true, username: "app", password: "root-pass",
+			wantAuth: []string{"AUTH app root-pass", "AUTH root-pass"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &fakeRedis{reply: redisReplies(tt.legacy, false, users)}
+			addr := server.serve(t)
+			args := &sqlOption{Host: addr.IP.String(), Port: addr.Port, DBName: "0",
+				Username: tt.username, Password: tt.password, redisUserOptional: tt.userOptional}
+			err := checkRedisAccount(args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkRedisAccount() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			var auth []string
+			for _, cmd := range server.received() {
+				if strings.HasPrefix(cmd, "AUTH") {
+					auth = append(auth, cmd)
+				}
+			}
+			if !reflect.DeepEqual(auth, tt.wantAuth) {
+				t.Errorf("AUTH commands = %q, want %q", auth, tt.wantAuth)
+			}
+			if args.Username != tt.wantUsername {
+				t.Errorf("username = %q, want %q", args.Username, tt.wantUsername)
+			}
+		})
+	}
+}
+
+func TestRedisClusterCommandArgs(t *testing.T) {
+	server := &fakeRedis{reply: redisReplies(false, true, map[string]string{"default": "pass"})}
+	addr := server.serve(t)
+	args := &sqlOption{Host: addr.IP.String(), Port: addr.Port, DBName: "2",
+		Username: "default", Password: "pass", redisClusterRedirect: true}
+	if err := checkRedisAccount(args); err != nil {
+		t.Fatal(err)
+	}
+	if !args.redisCluster {
+		t.Fatal("cluster mode not detected")
+	}
+	params := strings.Join(args.RedisCommandArgs(), " ")
+	if !strings.Contains(params, " -c") || strings.Contains(params, "-n") || strings.Contains(params, "--user") {
+		t.Errorf("cluster redis-cli args = %q, want -c without -n and --user", params)
+	}
+	args.redisClusterRedirect = false
+	if params = strings.Join(args.RedisCommandArgs(), " "); strings.Contains(params, " -c") {
+		t.Errorf("redis-cli args through gateway = %q, want no -c", params)
+	}
+}
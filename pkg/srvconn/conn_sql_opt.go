@@ -19,10 +19,19 @@ type sqlOption struct {
 	CertKey          string
 	CertKeyPath      string
 	AllowInvalidCert bool
+	SSLMode          string
+	// 通过网关隧道连接时，校验证书使用的资产地址
+	SSLServerName string
 
 	win Windows
 
 	disableMySQLAutoRehash bool
+
+	// 用户名来自账号而不是平台要求的，Redis 不支持或者认证失败时只使用密码认证
+	redisUserOptional bool
+	// Redis 集群模式，redisClusterRedirect 为 true 时 redis-cli 跟随 MOVED 和 ASK 重定向
+	redisCluster         bool
+	redisClusterRedirect bool
 }
 
 type SqlOption func(*sqlOption)
@@ -87,12 +96,36 @@ func SqlAllowInvalidCert(allowInvalidCert bool) SqlOption {
 	}
 }
 
+func SqlSSLMode(sslMode string) SqlOption {
+	return func(args *sqlOption) {
+		args.SSLMode = sslMode
+	}
+}
+
+func SqlSSLServerName(serverName string) SqlOption {
+	return func(args *sqlOption) {
+		args.SSLServerName = serverName
+	}
+}
+
 func SqlPtyWin(win Windows) SqlOption {
 	return func(args *sqlOption) {
 		args.win = win
 	}
 }
 
+func SqlRedisUserOptional(optional bool) SqlOption {
+	return func(args *sqlOption) {
+		args.redisUserOptional = optional
+	}
+}
+
+func SqlRedisClusterRedirect(redirect bool) SqlOption {
+	return func(args *sqlOption) {
+		args.redisClusterRedirect = redirect
+	}
+}
+
 const (
 	maxSQLConnCount = 1
 	maxIdleTime     = time.Second * 15
@@ -103,12 +136,16 @@ func checkDatabaseAccountValidate(driveName, datasourceName string) error {
 	if err != nil {
 		return err
 	}
+	return pingDatabase(db)
+}
+
+func pingDatabase(db *sql.DB) error {
 	db.SetMaxOpenConns(maxSQLConnCount)
 	db.SetMaxIdleConns(maxSQLConnCount)
 	db.SetConnMaxLifetime(maxIdleTime)
 	db.SetConnMaxIdleTime(maxIdleTime)
 	defer db.Close()
-	err = db.Ping()
+	err := db.Ping()
 	if err != nil {
 		return err
 	}
@@ -3,6 +3,7 @@ package srvconn
 import (
 	"errors"
 	"io"
+	"sync"
 
 	gossh "golang.org/x/crypto/ssh"
 	"golang.org/x/text/transform"
@@ -30,6 +31,9 @@ func NewSSHConnection(sess *gossh.Session, opts ...SSHOption) (*SSHConnection, e
 		gossh.TTY_OP_ISPEED: 14400, // input speed = 14.4 kbaud
 		gossh.TTY_OP_OSPEED: 14400, // output speed = 14.4 kbaud
 	}
+	if len(options.env) > 0 {
+		SetSessionEnv(sess, options.env)
+	}
 	err := sess.RequestPty(options.term, options.win.Height, options.win.Width, modes)
 	if err != nil {
 		return nil, err
@@ -73,6 +77,9 @@ type SSHConnection struct {
 	stdin   io.Writer
 	stdout  io.Reader
 	options *SSHOptions
+
+	waitOnce sync.Once
+	waitErr  error
 }
 
 func (sc *SSHConnection) SetWinSize(w, h int) error {
@@ -91,8 +98,30 @@ func (sc *SSHConnection) Close() (err error) {
 	return sc.session.Close()
 }
 
+// IsDropped 根据读取错误判断连接是否意外断开。
+// 远端正常退出时会返回退出状态，网络中断导致的 EOF 则没有退出状态
+func (sc *SSHConnection) IsDropped(readErr error) bool {
+	if !errors.Is(readErr, io.EOF) {
+		return true
+	}
+	var exitErr *gossh.ExitError
+	if err := sc.Wait(); err != nil && !errors.As(err, &exitErr) {
+		return true
+	}
+	return false
+}
+
+// Wait 等待远端 shell 退出，可以多次调用
+func (sc *SSHConnection) Wait() error {
+	sc.waitOnce.Do(func() {
+		sc.waitErr = sc.session.Wait()
+	})
+	return sc.waitErr
+}
+
 func (sc *SSHConnection) KeepAlive() error {
-	_, err := sc.session.SendRequest("keepalive@openssh.com", false, nil)
+	// 需要等待回复才能确认连接可用，服务端回复失败也说明连接是正常的
+	_, err := sc.session.SendRequest("keepalive@openssh.com", true, nil)
 	return err
 }
 
@@ -104,6 +133,9 @@ type SSHOptions struct {
 	term    string
 
 	suConfig *SuConfig
+
+	// 转发给资产的环境变量
+	env map[string]string
 }
 
 func SSHCharset(charset string) SSHOption {
@@ -129,3 +161,9 @@ func SSHSudoConfig(cfg *SuConfig) SSHOption {
 		opt.suConfig = cfg
 	}
 }
+
+func SSHEnv(env map[string]string) SSHOption {
+	return func(opt *SSHOptions) {
+		opt.env = env
+	}
+}
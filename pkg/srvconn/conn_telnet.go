@@ -37,8 +37,13 @@ func NewTelnetConnection(opts ...TelnetOption) (*TelnetConnection, error) {
 		client      *tclientlib.Client
 	)
 	dstAddr := net.JoinHostPort(cfg.Host, cfg.Port)
-	if cfg.proxySSHClientOptions != nil {
-		if proxyClient, err = getAvailableProxyClient(cfg.proxySSHClientOptions...); err != nil {
+	if cfg.gatewayChain != nil || cfg.proxySSHClientOptions != nil {
+		if cfg.gatewayChain != nil {
+			proxyClient, err = newGatewayChainClient(cfg.gatewayChain)
+		} else {
+			proxyClient, err = getAvailableProxyClient(cfg.proxySSHClientOptions...)
+		}
+		if err != nil {
 			return nil, err
 		}
 		if conn, err = proxyClient.Dial("tcp", dstAddr); err != nil {
@@ -46,11 +51,12 @@ func NewTelnetConnection(opts ...TelnetOption) (*TelnetConnection, error) {
 			return nil, err
 		}
 	} else {
-		if conn, err = net.DialTimeout("tcp", dstAddr, cfg.Timeout); err != nil {
+		if conn, err = DialTCP(dstAddr, cfg.Timeout); err != nil {
 			return nil, err
 		}
 	}
-	client, err = newTelnetClient(conn, cfg)
+	negotiator := newTelnetNegotiator(conn, cfg.Term, cfg.win)
+	client, err = newTelnetClient(negotiator, cfg)
 	if err != nil {
 		if proxyClient != nil {
 			_ = proxyClient.Close()
@@ -62,12 +68,13 @@ func NewTelnetConnection(opts ...TelnetOption) (*TelnetConnection, error) {
 		transformWriter io.WriteCloser
 	)
 
-	transformReader = client
+	// 登录完成后直接从 negotiator 读取，tclientlib 无法处理数据中转义的 255 字节
+	transformReader = negotiator
 	transformWriter = client
 
 	if cfg.Charset != common.UTF8 {
 		if readDecode := common.LookupCharsetDecode(cfg.Charset); readDecode != nil {
-			transformReader = transform.NewReader(client, readDecode)
+			transformReader = transform.NewReader(negotiator, readDecode)
 		}
 		if writerEncode := common.LookupCharsetEncode(cfg.Charset); writerEncode != nil {
 			transformWriter = transform.NewWriter(client, writerEncode)
@@ -76,6 +83,7 @@ func NewTelnetConnection(opts ...TelnetOption) (*TelnetConnection, error) {
 	tCon := &TelnetConnection{
 		cfg:             cfg,
 		conn:            client,
+		negotiator:      negotiator,
 		proxyConn:       proxyClient,
 		transformReader: transformReader,
 		transformWriter: transformWriter,
@@ -131,9 +139,10 @@ func newTelnetClient(conn net.Conn, cfg *TelnetConfig) (*tclientlib.Client, erro
 }
 
 type TelnetConnection struct {
-	cfg       *TelnetConfig
-	conn      *tclientlib.Client
-	proxyConn *SSHClient
+	cfg        *TelnetConfig
+	conn       *tclientlib.Client
+	negotiator *telnetNegotiator
+	proxyConn  *SSHClient
 
 	transformReader io.Reader
 	transformWriter io.Writer
@@ -145,7 +154,7 @@ func (tc *TelnetConnection) KeepAlive() error {
 }
 
 func (tc *TelnetConnection) SetWinSize(w, h int) error {
-	return tc.conn.WindowChange(w, h)
+	return tc.negotiator.SetWindowSize(w, h)
 }
 
 func (tc *TelnetConnection) Read(p []byte) (n int, err error) {
@@ -187,6 +196,8 @@ type TelnetConfig struct {
 	CustomPasswordPattern *regexp.Regexp
 
 	proxySSHClientOptions []SSHClientOptions
+
+	gatewayChain []SSHClientOptions
 }
 
 func TelnetHost(host string) TelnetOption {
@@ -225,6 +236,12 @@ func TelnetProxyOptions(proxyOpts []SSHClientOptions) TelnetOption {
 	}
 }
 
+func TelnetGatewayChain(hops []SSHClientOptions) TelnetOption {
+	return func(opt *TelnetConfig) {
+		opt.gatewayChain = hops
+	}
+}
+
 func TelnetPtyWin(win Windows) TelnetOption {
 	return func(opt *TelnetConfig) {
 		opt.win = win
@@ -0,0 +1,153 @@
+package srvconn
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+数据库的 TLS 连接：服务端证书校验和客户端证书(双向 TLS)。
+客户端证书和私钥来自资产的 secret_info，连接前的账号检查和客户端命令使用同样的证书。
+连接前的检查限制整个连接过程(包括 TLS 握手)的时间，握手失败或者服务端没有响应时返回 ErrDatabaseTLS，
+不会一直等待。
+*/
+
+var (
+	ErrDatabaseTLS = errors.New("database tls handshake failed")
+
+	databaseConnectTimeout = 10 * time.Second
+)
+
+// wrapDatabaseTLSError 开启 TLS 时把证书校验、握手相关的错误转换为 ErrDatabaseTLS
+func wrapDatabaseTLSError(useSSL bool, err error) error {
+	if err == nil || !useSSL || !isTLSError(err) {
+		return err
+	}
+	return fmt.Errorf("%w: %s", ErrDatabaseTLS, err)
+}
+
+func isTLSError(err error) bool {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostnameErr      x509.HostnameError
+		invalidCert      x509.CertificateInvalidError
+		recordErr        tls.RecordHeaderError
+		netErr           net.Error
+		opErr            *net.OpError
+	)
+	switch {
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		// 无法连接数据库，与 TLS 无关
+		return false
+	case errors.As(err, &unknownAuthority), errors.As(err, &hostnameErr),
+		errors.As(err, &invalidCert), errors.As(err, &recordErr):
+		return true
+	case errors.As(err, &netErr) && netErr.Timeout():
+		// 开启 TLS 时连接成功后握手超时
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, keyword := range []string{"tls", "x509", "ssl", "certificate"} {
+		if strings.Contains(msg, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// databaseTLSConfig 返回连接前检查使用的 TLS 配置，serverName 为校验证书使用的地址
+func databaseTLSConfig(opt *sqlOption, serverName string) (*tls.Config, error) {
+	tlsConf := &tls.Config{ServerName: serverName, InsecureSkipVerify: opt.AllowInvalidCert}
+	if opt.CaCert != "" && !opt.AllowInvalidCert {
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM([]byte(opt.CaCert)) {
+			return nil, fmt.Errorf("%w: invalid ca certificate", ErrDatabaseTLS)
+		}
+		tlsConf.RootCAs = rootCAs
+	}
+	if opt.ClientCert != "" || opt.CertKey != "" {
+		cert, err := tls.X509KeyPair([]byte(opt.ClientCert), []byte(opt.CertKey))
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid client certificate: %s", ErrDatabaseTLS, err)
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConf, nil
+}
+
+// dialWithDeadline 连接后设置整个握手过程的超时时间，检查结束后连接即关闭
+func dialWithDeadline(ctx context.Context, addr string) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: databaseConnectTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.SetDeadline(time.Now().Add(databaseConnectTimeout)); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// storeDatabaseCertFiles 保存证书到临时文件，返回绝对路径，客户端命令以 nobody 运行时修改文件属主
+func storeDatabaseCertFiles(contents ...string) ([]string, error) {
+	files := make([]string, len(contents))
+	for i := range contents {
+		path, err := StoreCAFileToLocal(contents[i])
+		if err != nil {
+			removeFiles(files...)
+			return nil, err
+		}
+		if path == "" {
+			continue
+		}
+		if path, err = filepath.Abs(path); err != nil {
+			removeFiles(files...)
+			return nil, err
+		}
+		files[i] = path
+	}
+	if os.Geteuid() == 0 {
+		if err := chownToNobody(files...); err != nil {
+			removeFiles(files...)
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func chownToNobody(files ...string) error {
+	nobody, err := user.Lookup("nobody")
+	if err != nil {
+		return err
+	}
+	uid, _ := strconv.Atoi(nobody.Uid)
+	gid, _ := strconv.Atoi(nobody.Gid)
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		if err = os.Chown(file, uid, gid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeFiles(files ...string) {
+	for _, file := range files {
+		if file != "" {
+			_ = os.Remove(file)
+		}
+	}
+}
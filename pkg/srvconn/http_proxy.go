@@ -0,0 +1,87 @@
+package srvconn
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jumpserver/koko/pkg/common"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+// HTTPProxyOptions 通过 HTTP CONNECT 隧道连接资产，用户名为空时不认证
+type HTTPProxyOptions struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+}
+
+func (o *HTTPProxyOptions) String() string {
+	return net.JoinHostPort(o.Host, o.Port)
+}
+
+func NewHTTPProxyOptions(gateway *model.Gateway) HTTPProxyOptions {
+	port := gateway.Protocols.GetProtocolPort(model.ProtocolHTTPProxy)
+	return HTTPProxyOptions{
+		Host:     common.TrimHostBrackets(gateway.Address),
+		Port:     strconv.Itoa(port),
+		Username: gateway.Account.Username,
+		Password: gateway.Account.Secret,
+	}
+}
+
+func dialByHTTPProxy(httpArgs *HTTPProxyOptions, destAddr string, timeout time.Duration) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", httpArgs.String(), timeout)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrHTTPProxyDial, err)
+	}
+	if timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(timeout))
+	}
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Host: destAddr},
+		Host:   destAddr,
+		Header: make(http.Header),
+	}
+	if httpArgs.Username != "" {
+		auth := httpArgs.Username + ":" + httpArgs.Password
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(auth)))
+	}
+	if err = req.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("%w: %s", ErrHTTPProxyDial, err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("%w: %s", ErrHTTPProxyDial, err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_ = conn.Close()
+		return nil, fmt.Errorf("%w: proxy %s responded %s", ErrHTTPProxyDial, httpArgs, resp.Status)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	if reader.Buffered() > 0 {
+		// 资产在隧道建立后立即发送的数据(如 SSH 版本信息)可能已被读入缓冲区
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
@@ -0,0 +1,69 @@
+package srvconn
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startFakeHTTPProxy 模拟 HTTP CONNECT 代理，认证通过后回复 200 并发送 banner
+func startFakeHTTPProxy(t *testing.T, wantAuth string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				req, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil {
+					return
+				}
+				switch {
+				case req.Method != http.MethodConnect || req.Host != "10.0.0.1:22":
+					_, _ = io.WriteString(conn, "HTTP/1.1 400 Bad Request\r\n\r\n")
+				case req.Header.Get("Proxy-Authorization") != wantAuth:
+					_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+				default:
+					// 隧道建立后资产立即发送版本信息
+					_, _ = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\nSSH-2.0-Fake\r\n")
+				}
+			}(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestDialByHTTPProxy(t *testing.T) {
+	addr := startFakeHTTPProxy(t, "Basic dXNlcjpwYXNz")
+	host, port, _ := net.SplitHostPort(addr)
+	args := &HTTPProxyOptions{Host: host, Port: port, Username: "user", Password: "pass"}
+	conn, err := dialByHTTPProxy(args, "10.0.0.1:22", time.Second)
+	if err != nil {
+		t.Fatalf("dialByHTTPProxy() error = %s", err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	_ = conn.Close()
+	if err != nil || line != "SSH-2.0-Fake\r\n" {
+		t.Errorf("read through tunnel = %q, %v, want ssh banner", line, err)
+	}
+
+	args.Password = "wrong"
+	if _, err = dialByHTTPProxy(args, "10.0.0.1:22", time.Second); !errors.Is(err, ErrHTTPProxyDial) {
+		t.Fatalf("dialByHTTPProxy() with wrong password error = %v, want %v", err, ErrHTTPProxyDial)
+	}
+	if !strings.Contains(err.Error(), "407") {
+		t.Errorf("dialByHTTPProxy() error = %s, want the proxy response status", err)
+	}
+}
@@ -15,6 +15,7 @@ import (
 
 	com "github.com/jumpserver/koko/pkg/common"
 	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/credential"
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/common"
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
 	"github.com/jumpserver/koko/pkg/jms-sdk-go/service"
@@ -155,15 +156,12 @@ func (ad *AssetDir) Create(path string) (*SftpFile, error) {
 		logger.Infof("Change duplicate dir path %s to %s", oldPath, realPath)
 	}
 	sf, err := con.client.Create(realPath)
-	filename := realPath
-	isSuccess := false
-	operate := model.OperateUpload
-	if err == nil {
-		isSuccess = true
+	ftpLog := ad.CreateFTPLog(su, model.OperateUpload, realPath, err)
+	if err != nil {
+		return nil, err
 	}
-	ftpLog := ad.CreateFTPLog(su, operate, filename, isSuccess)
-	f := &SftpFile{File: sf, FTPLog: ftpLog}
-	return f, err
+	f := &SftpFile{File: sf, FTPLog: ftpLog, auditor: ad.auditor()}
+	return f, nil
 }
 
 func (ad *AssetDir) MkdirAll(path string) (err error) {
@@ -198,13 +196,7 @@ func (ad *AssetDir) MkdirAll(path string) (err error) {
 		logger.Infof("Change duplicate dir path %s to %s", oldPath, realPath)
 	}
 	err = con.client.MkdirAll(realPath)
-	filename := realPath
-	isSuccess := false
-	operate := model.OperateMkdir
-	if err == nil {
-		isSuccess = true
-	}
-	ad.CreateFTPLog(su, operate, filename, isSuccess)
+	ad.CreateFTPLog(su, model.OperateMkdir, realPath, err)
 	return
 }
 
@@ -230,15 +222,12 @@ func (ad *AssetDir) Open(path string) (*SftpFile, error) {
 		return nil, sftp.ErrSshFxConnectionLost
 	}
 	sf, err := con.client.Open(realPath)
-	filename := realPath
-	isSuccess := false
-	operate := model.OperateDownload
-	if err == nil {
-		isSuccess = true
+	ftpLog := ad.CreateFTPLog(su, model.OperateDownload, realPath, err)
+	if err != nil {
+		return nil, err
 	}
-	ftpLog := ad.CreateFTPLog(su, operate, filename, isSuccess)
-	f := &SftpFile{File: sf, FTPLog: ftpLog}
-	return f, err
+	f := &SftpFile{File: sf, FTPLog: ftpLog, auditor: ad.auditor()}
+	return f, nil
 }
 
 func (ad *AssetDir) ReadDir(path string) (res []os.FileInfo, err error) {
@@ -321,13 +310,7 @@ func (ad *AssetDir) RemoveDirectory(path string) (err error) {
 		return sftp.ErrSshFxConnectionLost
 	}
 	err = ad.removeDirectoryAll(con.client, realPath)
-	filename := realPath
-	isSuccess := false
-	operate := model.OperateRemoveDir
-	if err == nil {
-		isSuccess = true
-	}
-	ad.CreateFTPLog(su, operate, filename, isSuccess)
+	ad.CreateFTPLog(su, model.OperateRemoveDir, realPath, err)
 	return
 }
 
@@ -360,13 +343,13 @@ func (ad *AssetDir) Rename(oldNamePath, newNamePath string) (err error) {
 	operate := model.OperateRename
 	err = conn1.client.Rename(oldRealPath, newRealPath)
 	if err != nil {
-		ad.CreateFTPLog(su, operate, filename, false)
+		ad.CreateFTPLog(su, operate, filename, err)
 		return err
 	}
 	if fileInfo, err := conn2.client.Stat(newRealPath); err == nil && fileInfo.IsDir() {
 		operate = model.OperateRenameDir
 	}
-	ad.CreateFTPLog(su, operate, filename, true)
+	ad.CreateFTPLog(su, operate, filename, nil)
 	return
 }
 
@@ -392,14 +375,7 @@ func (ad *AssetDir) Remove(path string) (err error) {
 		return sftp.ErrSshFxConnectionLost
 	}
 	err = con.client.Remove(realPath)
-
-	filename := realPath
-	isSuccess := false
-	operate := model.OperateDelete
-	if err == nil {
-		isSuccess = true
-	}
-	ad.CreateFTPLog(su, operate, filename, isSuccess)
+	ad.CreateFTPLog(su, model.OperateDelete, realPath, err)
 	return
 }
 
@@ -453,12 +429,7 @@ func (ad *AssetDir) Symlink(oldNamePath, newNamePath string) (err error) {
 	}
 	err = conn1.client.Symlink(oldRealPath, newRealPath)
 	filename := fmt.Sprintf("%s=>%s", oldRealPath, newRealPath)
-	isSuccess := false
-	operate := model.OperateSymlink
-	if err == nil {
-		isSuccess = true
-	}
-	ad.CreateFTPLog(su, operate, filename, isSuccess)
+	ad.CreateFTPLog(su, model.OperateSymlink, filename, err)
 	return
 }
 
@@ -513,7 +484,7 @@ func (ad *AssetDir) GetSFTPAndRealPath(su *model.PermAccount, path string) (conn
 			case model.TaskKillSession:
 				ad.mu.Lock()
 				defer ad.mu.Unlock()
-				ad.finishSftpSession(su.String(), conn)
+				ad.finishSftpSession(su.String(), conn, model.ExitAdminTerminate)
 				return nil
 			}
 			return fmt.Errorf("sftp session not support task: %s", task.Name)
@@ -589,14 +560,22 @@ func (ad *AssetDir) createConnectToken(su *model.PermAccount) (model.ConnectToke
 		logger.Errorf("Create super connect token failed: %s", msg)
 		return model.ConnectToken{}, fmt.Errorf("create super connect token failed: %s", msg)
 	}
-	return ad.jmsService.GetConnectTokenInfo(tokenInfo.ID)
+	connectToken, err := ad.jmsService.GetConnectTokenInfo(tokenInfo.ID)
+	if err != nil {
+		return connectToken, err
+	}
+	if err = credential.ResolveConnectToken(&connectToken); err != nil {
+		return connectToken, err
+	}
+	err = credential.ResolveLoginUser(&connectToken)
+	return connectToken, err
 }
 
 func (ad *AssetDir) getNewSftpConn(connectToken *model.ConnectToken) (conn *SftpConn, err error) {
 	if ad.detailAsset == nil {
 		return nil, errNoSelectAsset
 	}
-	timeout := config.GlobalConfig.SSHTimeout
+	timeout := config.GetConf().SSHTimeout
 
 	user := connectToken.User
 	asset := connectToken.Asset
@@ -606,7 +585,7 @@ func (ad *AssetDir) getNewSftpConn(connectToken *model.ConnectToken) (conn *Sftp
 
 	sshAuthOpts := make([]SSHClientOption, 0, 6)
 	sshAuthOpts = append(sshAuthOpts, SSHClientUsername(username))
-	sshAuthOpts = append(sshAuthOpts, SSHClientHost(asset.Address))
+	sshAuthOpts = append(sshAuthOpts, SSHClientHost(com.TrimHostBrackets(asset.Address)))
 	sshAuthOpts = append(sshAuthOpts, SSHClientPort(asset.ProtocolPort(protocol)))
 
 	sshAuthOpts = append(sshAuthOpts, SSHClientTimeout(timeout))
@@ -616,17 +595,23 @@ func (ad *AssetDir) getNewSftpConn(connectToken *model.ConnectToken) (conn *Sftp
 		} else {
 			logger.Errorf("ssh private key parse failed: %s", err1)
 		}
+		if account.Certificate != "" {
+			sshAuthOpts = append(sshAuthOpts, SSHClientCertificate(account.Certificate))
+		}
 	} else {
 		sshAuthOpts = append(sshAuthOpts, SSHClientPassword(account.Secret))
 	}
 
-	if connectToken.Gateway != nil {
-		gateway := connectToken.Gateway
+	if gateway := connectToken.Gateway; gateway != nil && gateway.IsSocks5() {
+		sshAuthOpts = append(sshAuthOpts, SSHClientSocks5Proxy(NewSocks5ProxyOptions(gateway)))
+	} else if gateway != nil && gateway.IsHTTPProxy() {
+		sshAuthOpts = append(sshAuthOpts, SSHClientHTTPProxy(NewHTTPProxyOptions(gateway)))
+	} else if gateway != nil {
 		proxyArgs := make([]SSHClientOptions, 0, 1)
 		loginAccount := gateway.Account
 		port := gateway.Protocols.GetProtocolPort(model.ProtocolSSH)
 		proxyArg := SSHClientOptions{
-			Host:     gateway.Address,
+			Host:     com.TrimHostBrackets(gateway.Address),
 			Port:     strconv.Itoa(port),
 			Username: loginAccount.Username,
 			Timeout:  timeout,
@@ -685,19 +670,19 @@ func (ad *AssetDir) close() {
 	defer ad.mu.Unlock()
 	for key, conn := range ad.sftpClients {
 		if conn != nil {
-			ad.finishSftpSession(key, conn)
+			ad.finishSftpSession(key, conn, model.ExitUserDisconnect)
 		}
 	}
 }
 
-func (ad *AssetDir) finishSftpSession(key string, conn *SftpConn) {
+func (ad *AssetDir) finishSftpSession(key string, conn *SftpConn, reason model.SessionExitReason) {
 	if conn.isClosed {
 		return
 	}
 	sess := ad.sftpTraceSessions[key]
 	if sess != nil {
 		session.RemoveSession(sess)
-		if err := ad.jmsService.SessionFinished(sess.ID, common.NewNowUTCTime()); err != nil {
+		if err := ad.jmsService.SessionFinished(sess.ID, common.NewNowUTCTime(), reason); err != nil {
 			logger.Errorf("SFTP Session finished err: %s", err)
 		}
 		logger.Debugf("SFTP Session finished %s", sess.ID)
@@ -705,7 +690,12 @@ func (ad *AssetDir) finishSftpSession(key string, conn *SftpConn) {
 	conn.Close()
 }
 
-func (ad *AssetDir) CreateFTPLog(su *model.PermAccount, operate, filename string, isSuccess bool) *model.FTPLog {
+func (ad *AssetDir) auditor() *sftpAuditor {
+	return getSftpAuditor(ad.jmsService)
+}
+
+// CreateFTPLog 记录 sftp 操作日志，filename 为资产上的真实路径，日志异步上传
+func (ad *AssetDir) CreateFTPLog(su *model.PermAccount, operate, filename string, err error) *model.FTPLog {
 	data := model.FTPLog{
 		ID:         com.UUID(),
 		User:       ad.user.String(),
@@ -716,11 +706,14 @@ func (ad *AssetDir) CreateFTPLog(su *model.PermAccount, operate, filename string
 		Operate:    operate,
 		Path:       filename,
 		DateStart:  common.NewNowUTCTime(),
-		IsSuccess:  isSuccess,
+		IsSuccess:  err == nil,
 	}
-	if err := ad.jmsService.CreateFileOperationLog(data); err != nil {
-		logger.Errorf("Create ftp log err: %s", err)
+	if err != nil {
+		data.Error = err.Error()
 	}
+	ad.auditor().Record(data)
+	logger.Infof("User %s sftp %s %s on asset %s, success: %t", ad.user.String(), operate,
+		filename, ad.detailAsset.String(), data.IsSuccess)
 	return &data
 }
 
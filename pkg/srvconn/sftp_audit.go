@@ -0,0 +1,78 @@
+package srvconn
+
+import (
+	"sync"
+
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/service"
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+const sftpAuditQueueSize = 1024
+
+var (
+	sftpAuditorOnce sync.Once
+	sftpAuditorIns  *sftpAuditor
+)
+
+type sftpAuditEvent struct {
+	data   model.FTPLog
+	finish bool // 传输结束，更新已创建日志的大小和结果
+}
+
+/*
+sftpAuditor 按顺序异步上传 sftp 操作日志，避免文件传输阻塞在审计接口上；
+队列满时在当前 goroutine 直接上传，宁可阻塞也不丢失审计日志。
+*/
+
+type sftpAuditor struct {
+	jmsService *service.JMService
+	queue      chan sftpAuditEvent
+}
+
+func getSftpAuditor(jmsService *service.JMService) *sftpAuditor {
+	sftpAuditorOnce.Do(func() {
+		sftpAuditorIns = &sftpAuditor{
+			jmsService: jmsService,
+			queue:      make(chan sftpAuditEvent, sftpAuditQueueSize),
+		}
+		go sftpAuditorIns.run()
+	})
+	return sftpAuditorIns
+}
+
+func (a *sftpAuditor) Record(data model.FTPLog) {
+	a.push(sftpAuditEvent{data: data})
+}
+
+func (a *sftpAuditor) Finish(data model.FTPLog) {
+	a.push(sftpAuditEvent{data: data, finish: true})
+}
+
+func (a *sftpAuditor) push(event sftpAuditEvent) {
+	select {
+	case a.queue <- event:
+	default:
+		logger.Errorf("SFTP audit queue is full, upload ftp log %s directly", event.data.ID)
+		a.upload(event)
+	}
+}
+
+func (a *sftpAuditor) run() {
+	for event := range a.queue {
+		a.upload(event)
+	}
+}
+
+func (a *sftpAuditor) upload(event sftpAuditEvent) {
+	var err error
+	if event.finish {
+		err = a.jmsService.UpdateFileOperationLog(event.data)
+	} else {
+		err = a.jmsService.CreateFileOperationLog(event.data)
+	}
+	if err != nil {
+		logger.Errorf("Upload ftp log %s %s %s err: %s", event.data.ID,
+			event.data.Operate, event.data.Path, err)
+	}
+}
@@ -2,6 +2,7 @@ package srvconn
 
 import (
 	"errors"
+	"io"
 	"os"
 	"strings"
 	"sync"
@@ -183,6 +184,71 @@ func NewAssetDir(jmsService *service.JMService, user *model.User, opts ...Folder
 type SftpFile struct {
 	*sftp.File
 	FTPLog *model.FTPLog
+
+	auditor *sftpAuditor
+
+	mu          sync.Mutex
+	transferErr error // 传输过程中第一次出现的错误
+	closeOnce   sync.Once
+}
+
+func (f *SftpFile) trackErr(err error) {
+	if err == nil || errors.Is(err, io.EOF) {
+		return
+	}
+	f.mu.Lock()
+	if f.transferErr == nil {
+		f.transferErr = err
+	}
+	f.mu.Unlock()
+}
+
+func (f *SftpFile) Read(p []byte) (n int, err error) {
+	n, err = f.File.Read(p)
+	f.trackErr(err)
+	return
+}
+
+func (f *SftpFile) ReadAt(p []byte, off int64) (n int, err error) {
+	n, err = f.File.ReadAt(p, off)
+	f.trackErr(err)
+	return
+}
+
+func (f *SftpFile) Write(p []byte) (n int, err error) {
+	n, err = f.File.Write(p)
+	f.trackErr(err)
+	return
+}
+
+func (f *SftpFile) ReadFrom(r io.Reader) (n int64, err error) {
+	n, err = f.File.ReadFrom(r)
+	f.trackErr(err)
+	return
+}
+
+// Close 关闭远程文件，并记录传输完成后的文件大小和结果
+func (f *SftpFile) Close() (err error) {
+	f.closeOnce.Do(func() {
+		var size int64
+		if info, err1 := f.File.Stat(); err1 == nil {
+			size = info.Size()
+		}
+		err = f.File.Close()
+		if f.auditor == nil || f.FTPLog == nil {
+			return
+		}
+		f.trackErr(err)
+		data := *f.FTPLog
+		data.Size = size
+		data.IsSuccess = true
+		if f.transferErr != nil {
+			data.IsSuccess = false
+			data.Error = f.transferErr.Error()
+		}
+		f.auditor.Finish(data)
+	})
+	return
 }
 
 type SftpConn struct {
@@ -0,0 +1,51 @@
+package srvconn
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/proxy"
+
+	"github.com/jumpserver/koko/pkg/common"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+)
+
+// Socks5ProxyOptions 通过 SOCKS5 代理连接资产，用户名为空时不认证
+type Socks5ProxyOptions struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+}
+
+func (o *Socks5ProxyOptions) String() string {
+	return net.JoinHostPort(o.Host, o.Port)
+}
+
+func NewSocks5ProxyOptions(gateway *model.Gateway) Socks5ProxyOptions {
+	port := gateway.Protocols.GetProtocolPort(model.ProtocolSocks5)
+	return Socks5ProxyOptions{
+		Host:     common.TrimHostBrackets(gateway.Address),
+		Port:     strconv.Itoa(port),
+		Username: gateway.Account.Username,
+		Password: gateway.Account.Secret,
+	}
+}
+
+func dialBySocks5Proxy(socks5Args *Socks5ProxyOptions, destAddr string, timeout time.Duration) (net.Conn, error) {
+	var auth *proxy.Auth
+	if socks5Args.Username != "" {
+		auth = &proxy.Auth{User: socks5Args.Username, Password: socks5Args.Password}
+	}
+	dialer, err := proxy.SOCKS5("tcp", socks5Args.String(), auth, &net.Dialer{Timeout: timeout})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrSocks5Dial, err)
+	}
+	conn, err := dialer.Dial("tcp", destAddr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrSocks5Dial, err)
+	}
+	return conn, nil
+}
@@ -1,6 +1,7 @@
 package srvconn
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
@@ -26,13 +27,32 @@ type SSHClientOptions struct {
 	Timeout      int
 	keyboardAuth gossh.KeyboardInteractiveChallenge
 	PrivateAuth  gossh.Signer
+	// OpenSSH 证书，需要与 PrivateKey 或 PrivateAuth 配合使用
+	Certificate string
+	certSigner  gossh.Signer
+	// 用户转发的 SSH agent，优先于账号的密钥
+	agentAuth *ClientAgentAuth
 
 	proxySSHClientOptions []SSHClientOptions
+
+	// 多级网关，按顺序依次连接，与 proxySSHClientOptions 中任选一个可用网关不同
+	gatewayChain []SSHClientOptions
+
+	socks5Proxy *Socks5ProxyOptions
+
+	httpProxy *HTTPProxyOptions
+
+	// 取消时中断正在进行的连接和握手
+	ctx context.Context
 }
 
 func (cfg *SSHClientOptions) AuthMethods() []gossh.AuthMethod {
 	authMethods := make([]gossh.AuthMethod, 0, 3)
+	signers := make([]gossh.Signer, 0, 3)
 
+	if cfg.certSigner != nil {
+		signers = append(signers, cfg.certSigner)
+	}
 	if cfg.PrivateKey != "" {
 		var (
 			signer gossh.Signer
@@ -42,19 +62,33 @@ func (cfg *SSHClientOptions) AuthMethods() []gossh.AuthMethod {
 			// 先使用 passphrase 解析 PrivateKey
 			if signer, err = gossh.ParsePrivateKeyWithPassphrase([]byte(cfg.PrivateKey),
 				[]byte(cfg.Passphrase)); err == nil {
-				authMethods = append(authMethods, gossh.PublicKeys(signer))
+				signers = append(signers, signer)
 			}
 		}
 		if err != nil || cfg.Passphrase == "" {
 			// 1. 如果之前使用解析失败，则去掉 passphrase，则尝试直接解析 PrivateKey 防止错误的passphrase
 			// 2. 如果没有 Passphrase 则直接解析 PrivateKey
 			if signer, err = gossh.ParsePrivateKey([]byte(cfg.PrivateKey)); err == nil {
-				authMethods = append(authMethods, gossh.PublicKeys(signer))
+				signers = append(signers, signer)
 			}
 		}
 	}
 	if cfg.PrivateAuth != nil {
-		authMethods = append(authMethods, gossh.PublicKeys(cfg.PrivateAuth))
+		signers = append(signers, cfg.PrivateAuth)
+	}
+	if cfg.agentAuth != nil {
+		// 同一种认证方式只会尝试一次，agent 中的密钥和账号的密钥需要放在同一个 publickey 认证中
+		authMethods = append(authMethods, gossh.PublicKeysCallback(func() ([]gossh.Signer, error) {
+			agentSigners, err := cfg.agentAuth.Signers()
+			if err != nil {
+				logger.Errorf("Get signers from client ssh agent failed: %s", err)
+			}
+			return append(agentSigners, signers...), nil
+		}))
+	} else {
+		for i := range signers {
+			authMethods = append(authMethods, gossh.PublicKeys(signers[i]))
+		}
 	}
 	if cfg.Password != "" {
 		authMethods = append(authMethods, gossh.Password(cfg.Password))
@@ -117,18 +151,55 @@ func SSHClientTimeout(timeout int) SSHClientOption {
 	}
 }
 
+// SSHClientContext ctx 取消时中断资产的连接和认证，用于需要在超时后放弃的连接
+func SSHClientContext(ctx context.Context) SSHClientOption {
+	return func(args *SSHClientOptions) {
+		args.ctx = ctx
+	}
+}
+
 func SSHClientPrivateAuth(privateAuth gossh.Signer) SSHClientOption {
 	return func(args *SSHClientOptions) {
 		args.PrivateAuth = privateAuth
 	}
 }
 
+func SSHClientCertificate(certificate string) SSHClientOption {
+	return func(args *SSHClientOptions) {
+		args.Certificate = certificate
+	}
+}
+
+func SSHClientAgentAuth(agentAuth *ClientAgentAuth) SSHClientOption {
+	return func(args *SSHClientOptions) {
+		args.agentAuth = agentAuth
+	}
+}
+
 func SSHClientProxyClient(proxyArgs ...SSHClientOptions) SSHClientOption {
 	return func(args *SSHClientOptions) {
 		args.proxySSHClientOptions = proxyArgs
 	}
 }
 
+func SSHClientGatewayChain(hops ...SSHClientOptions) SSHClientOption {
+	return func(args *SSHClientOptions) {
+		args.gatewayChain = hops
+	}
+}
+
+func SSHClientSocks5Proxy(socks5Args Socks5ProxyOptions) SSHClientOption {
+	return func(args *SSHClientOptions) {
+		args.socks5Proxy = &socks5Args
+	}
+}
+
+func SSHClientHTTPProxy(httpArgs HTTPProxyOptions) SSHClientOption {
+	return func(args *SSHClientOptions) {
+		args.httpProxy = &httpArgs
+	}
+}
+
 func SSHClientKeyboardAuth(keyboardAuth gossh.KeyboardInteractiveChallenge) SSHClientOption {
 	return func(conf *SSHClientOptions) {
 		conf.keyboardAuth = keyboardAuth
@@ -150,6 +221,9 @@ var (
 	ErrNoAvailable = errors.New("no available gateway")
 	ErrGatewayDial = errors.New("gateway dial addr failed")
 	ErrSSHClient   = errors.New("new ssh client failed")
+	ErrSocks5Dial  = errors.New("socks5 proxy dial addr failed")
+
+	ErrHTTPProxyDial = errors.New("http proxy dial addr failed")
 )
 
 func getAvailableProxyClient(cfgs ...SSHClientOptions) (*SSHClient, error) {
@@ -162,14 +236,51 @@ func getAvailableProxyClient(cfgs ...SSHClientOptions) (*SSHClient, error) {
 }
 
 func NewSSHClientWithCfg(cfg *SSHClientOptions) (*SSHClient, error) {
-	gosshCfg := gossh.ClientConfig{
-		User:            cfg.Username,
-		Auth:            cfg.AuthMethods(),
-		Timeout:         time.Duration(cfg.Timeout) * time.Second,
-		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
-		Config:          createSSHConfig(),
+	// 证书无效或过期时不再尝试连接
+	if cfg.Certificate != "" && cfg.certSigner == nil {
+		if err := cfg.loadCertSigner(); err != nil {
+			return nil, err
+		}
 	}
+	gosshCfg := cfg.clientConfig()
 	destAddr := net.JoinHostPort(cfg.Host, cfg.Port)
+	if len(cfg.gatewayChain) > 0 {
+		proxyClient, err := newGatewayChainClient(cfg.gatewayChain)
+		if err != nil {
+			logger.Errorf("Connect gateway chain err: %s", err)
+			return nil, err
+		}
+		client, err := dialSSHClient(proxyClient, cfg)
+		if err != nil {
+			_ = proxyClient.Close()
+			return nil, err
+		}
+		return client, nil
+	}
+	if cfg.socks5Proxy != nil || cfg.httpProxy != nil {
+		var (
+			destConn net.Conn
+			err      error
+		)
+		if cfg.socks5Proxy != nil {
+			logger.Infof("Dial %s through socks5 proxy(%s)", destAddr, cfg.socks5Proxy)
+			destConn, err = dialBySocks5Proxy(cfg.socks5Proxy, destAddr, gosshCfg.Timeout)
+		} else {
+			logger.Infof("Dial %s through http proxy(%s)", destAddr, cfg.httpProxy)
+			destConn, err = dialByHTTPProxy(cfg.httpProxy, destAddr, gosshCfg.Timeout)
+		}
+		if err != nil {
+			return nil, err
+		}
+		sshConn, chans, reqs, err := newClientConn(cfg.context(), destConn, destAddr, &gosshCfg)
+		if err != nil {
+			_ = destConn.Close()
+			return nil, fmt.Errorf("%w: %s", ErrSSHClient, err)
+		}
+		gosshClient := gossh.NewClient(sshConn, chans, reqs)
+		return &SSHClient{Cfg: cfg, Client: gosshClient,
+			traceSessionMap: make(map[*gossh.Session]time.Time)}, nil
+	}
 	if len(cfg.proxySSHClientOptions) > 0 {
 		proxyClient, err := getAvailableProxyClient(cfg.proxySSHClientOptions...)
 		if err != nil {
@@ -182,7 +293,7 @@ func NewSSHClientWithCfg(cfg *SSHClientOptions) (*SSHClient, error) {
 			_ = proxyClient.Close()
 			return nil, fmt.Errorf("%w: %s", ErrGatewayDial, err)
 		}
-		proxyConn, chans, reqs, err := gossh.NewClientConn(destConn, destAddr, &gosshCfg)
+		proxyConn, chans, reqs, err := newClientConn(cfg.context(), destConn, destAddr, &gosshCfg)
 		if err != nil {
 			_ = proxyClient.Close()
 			_ = destConn.Close()
@@ -193,7 +304,7 @@ func NewSSHClientWithCfg(cfg *SSHClientOptions) (*SSHClient, error) {
 			traceSessionMap: make(map[*gossh.Session]time.Time),
 			ProxyClient:     proxyClient}, nil
 	}
-	gosshClient, err := gossh.Dial("tcp", destAddr, &gosshCfg)
+	gosshClient, err := DialSSHContext(cfg.context(), destAddr, &gosshCfg)
 	if err != nil {
 		return nil, err
 	}
@@ -266,6 +377,52 @@ func (s *SSHClient) ReleaseSession(sess *gossh.Session) {
 	logger.Infof("SSHClient(%s) release one session remain %d", s, len(s.traceSessionMap))
 }
 
+func (cfg *SSHClientOptions) context() context.Context {
+	if cfg.ctx == nil {
+		return context.Background()
+	}
+	return cfg.ctx
+}
+
+// newClientConn 与 gossh.NewClientConn 相同，ctx 取消时关闭 conn 以结束握手
+func newClientConn(ctx context.Context, conn net.Conn, addr string,
+	cfg *gossh.ClientConfig) (gossh.Conn, <-chan gossh.NewChannel, <-chan *gossh.Request, error) {
+	if ctx.Done() == nil {
+		return gossh.NewClientConn(conn, addr, cfg)
+	}
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-done:
+		}
+	}()
+	sshConn, chans, reqs, err := gossh.NewClientConn(conn, addr, cfg)
+	close(done)
+	<-exited
+	// ctx 取消后 conn 可能已经被关闭，即使握手成功也不再使用
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		if err == nil {
+			_ = sshConn.Close()
+		}
+		return nil, nil, nil, ctxErr
+	}
+	return sshConn, chans, reqs, err
+}
+
+func (cfg *SSHClientOptions) clientConfig() gossh.ClientConfig {
+	return gossh.ClientConfig{
+		User:            cfg.Username,
+		Auth:            cfg.AuthMethods(),
+		Timeout:         time.Duration(cfg.Timeout) * time.Second,
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		Config:          createSSHConfig(),
+	}
+}
+
 func createSSHConfig() gossh.Config {
 	var cfg gossh.Config
 	cfg.SetDefaults()
@@ -0,0 +1,73 @@
+package srvconn
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+var ErrAgentNotForwarded = errors.New("ssh agent forwarding not requested by client")
+
+// ClientAgentAuth 使用用户转发的 SSH agent 认证资产，记录资产接受的公钥用于审计
+type ClientAgentAuth struct {
+	agent agent.ExtendedAgent
+
+	mu      sync.Mutex
+	usedKey gossh.PublicKey
+}
+
+// NewClientAgentAuth channel 为到客户端 agent 的通道，由调用方在认证结束后关闭
+func NewClientAgentAuth(channel io.ReadWriter) *ClientAgentAuth {
+	return &ClientAgentAuth{agent: agent.NewClient(channel)}
+}
+
+func (a *ClientAgentAuth) Signers() ([]gossh.Signer, error) {
+	signers, err := a.agent.Signers()
+	if err != nil {
+		return nil, err
+	}
+	for i := range signers {
+		if algSigner, ok := signers[i].(gossh.AlgorithmSigner); ok {
+			signers[i] = &agentSigner{AlgorithmSigner: algSigner, auth: a}
+		}
+	}
+	return signers, nil
+}
+
+// UsedKey 资产接受并完成签名的公钥，没有使用 agent 中的密钥时返回 nil
+func (a *ClientAgentAuth) UsedKey() gossh.PublicKey {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.usedKey
+}
+
+func (a *ClientAgentAuth) setUsedKey(key gossh.PublicKey) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.usedKey = key
+}
+
+// agentSigner 只有资产接受公钥后才会请求签名，签名成功即表示使用了该密钥
+type agentSigner struct {
+	gossh.AlgorithmSigner
+	auth *ClientAgentAuth
+}
+
+func (s *agentSigner) Sign(rand io.Reader, data []byte) (*gossh.Signature, error) {
+	sig, err := s.AlgorithmSigner.Sign(rand, data)
+	if err == nil {
+		s.auth.setUsedKey(s.PublicKey())
+	}
+	return sig, err
+}
+
+func (s *agentSigner) SignWithAlgorithm(rand io.Reader, data []byte, algorithm string) (*gossh.Signature, error) {
+	sig, err := s.AlgorithmSigner.SignWithAlgorithm(rand, data, algorithm)
+	if err == nil {
+		s.auth.setUsedKey(s.PublicKey())
+	}
+	return sig, err
+}
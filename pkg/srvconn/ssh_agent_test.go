@@ -0,0 +1,44 @@
+package srvconn
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestClientAgentAuthUsedKey(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring := agent.NewKeyring()
+	if err = keyring.Add(agent.AddedKey{PrivateKey: priv}); err != nil {
+		t.Fatal(err)
+	}
+	clientConn, agentConn := net.Pipe()
+	defer clientConn.Close()
+	go func() { _ = agent.ServeAgent(keyring, agentConn) }()
+
+	auth := NewClientAgentAuth(clientConn)
+	signers, err := auth.Signers()
+	if err != nil || len(signers) != 1 {
+		t.Fatalf("Signers() = %d signers, %v, want 1", len(signers), err)
+	}
+	if auth.UsedKey() != nil {
+		t.Fatal("UsedKey() is set before signing")
+	}
+	if _, ok := signers[0].(gossh.AlgorithmSigner); !ok {
+		t.Error("agent signer does not support signature algorithms")
+	}
+	if _, err = signers[0].Sign(rand.Reader, []byte("session")); err != nil {
+		t.Fatalf("Sign() err = %v", err)
+	}
+	used := auth.UsedKey()
+	if used == nil || gossh.FingerprintSHA256(used) != gossh.FingerprintSHA256(signers[0].PublicKey()) {
+		t.Errorf("UsedKey() = %v, want the signed key", used)
+	}
+}
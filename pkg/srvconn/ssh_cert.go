@@ -0,0 +1,87 @@
+package srvconn
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+var (
+	ErrSSHCertInvalid = errors.New("invalid ssh certificate")
+	ErrSSHCertExpired = errors.New("ssh certificate expired")
+)
+
+// ParseSSHCertificate 解析 authorized_keys 格式的 OpenSSH 证书，如 ssh-ed25519-cert-v01@openssh.com AAAA...
+func ParseSSHCertificate(certificate string) (*gossh.Certificate, error) {
+	pubKey, _, _, _, err := gossh.ParseAuthorizedKey([]byte(certificate))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrSSHCertInvalid, err)
+	}
+	cert, ok := pubKey.(*gossh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not a certificate", ErrSSHCertInvalid, pubKey.Type())
+	}
+	return cert, nil
+}
+
+// CheckSSHCertificateValidity 检查证书在 now 时刻是否处于有效期内
+func CheckSSHCertificateValidity(cert *gossh.Certificate, now time.Time) error {
+	unix := now.Unix()
+	if unix < 0 {
+		return fmt.Errorf("%w: invalid time %s", ErrSSHCertInvalid, now)
+	}
+	current := uint64(unix)
+	if current < cert.ValidAfter {
+		validAfter := time.Unix(int64(cert.ValidAfter), 0)
+		return fmt.Errorf("%w: not valid before %s", ErrSSHCertInvalid, validAfter)
+	}
+	if cert.ValidBefore != gossh.CertTimeInfinity && current >= cert.ValidBefore {
+		validBefore := time.Unix(int64(cert.ValidBefore), 0)
+		return fmt.Errorf("%w: expired at %s", ErrSSHCertExpired, validBefore)
+	}
+	return nil
+}
+
+// NewSSHCertSigner 使用证书和对应的私钥构建 ssh.Signer，证书不在有效期内时返回错误
+func NewSSHCertSigner(certificate string, signer gossh.Signer) (gossh.Signer, error) {
+	cert, err := ParseSSHCertificate(certificate)
+	if err != nil {
+		return nil, err
+	}
+	if err = CheckSSHCertificateValidity(cert, time.Now()); err != nil {
+		return nil, err
+	}
+	certSigner, err := gossh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrSSHCertInvalid, err)
+	}
+	return certSigner, nil
+}
+
+// loadCertSigner 使用 PrivateAuth 或者 PrivateKey 作为证书对应的私钥
+func (cfg *SSHClientOptions) loadCertSigner() error {
+	signer := cfg.PrivateAuth
+	if signer == nil && cfg.PrivateKey != "" {
+		var err error
+		if cfg.Passphrase != "" {
+			signer, err = gossh.ParsePrivateKeyWithPassphrase([]byte(cfg.PrivateKey), []byte(cfg.Passphrase))
+		}
+		if signer == nil {
+			signer, err = gossh.ParsePrivateKey([]byte(cfg.PrivateKey))
+		}
+		if err != nil {
+			return fmt.Errorf("%w: parse private key failed: %s", ErrSSHCertInvalid, err)
+		}
+	}
+	if signer == nil {
+		return fmt.Errorf("%w: no private key for certificate", ErrSSHCertInvalid)
+	}
+	certSigner, err := NewSSHCertSigner(cfg.Certificate, signer)
+	if err != nil {
+		return err
+	}
+	cfg.certSigner = certSigner
+	return nil
+}
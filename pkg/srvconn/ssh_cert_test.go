@@ -0,0 +1,99 @@
+package srvconn
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func newTestSigner(t *testing.T) gossh.Signer {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := gossh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func newTestCertificate(t *testing.T, ca, user gossh.Signer, validAfter, validBefore uint64) string {
+	cert := &gossh.Certificate{
+		Key:             user.PublicKey(),
+		CertType:        gossh.UserCert,
+		KeyId:           "koko-test",
+		ValidPrincipals: []string{"root"},
+		ValidAfter:      validAfter,
+		ValidBefore:     validBefore,
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+	return string(gossh.MarshalAuthorizedKey(cert))
+}
+
+func TestNewSSHCertSigner(t *testing.T) {
+	ca := newTestSigner(t)
+	user := newTestSigner(t)
+	now := uint64(time.Now().Unix())
+	certificate := newTestCertificate(t, ca, user, now-60, now+3600)
+
+	cert, err := ParseSSHCertificate(certificate)
+	if err != nil {
+		t.Fatalf("ParseSSHCertificate() error = %s", err)
+	}
+	if cert.KeyId != "koko-test" {
+		t.Errorf("ParseSSHCertificate() KeyId = %s, want koko-test", cert.KeyId)
+	}
+	signer, err := NewSSHCertSigner(certificate, user)
+	if err != nil {
+		t.Fatalf("NewSSHCertSigner() error = %s", err)
+	}
+	if _, ok := signer.PublicKey().(*gossh.Certificate); !ok {
+		t.Errorf("NewSSHCertSigner() public key type = %s, want certificate", signer.PublicKey().Type())
+	}
+
+	cfg := SSHClientOptions{Certificate: certificate, PrivateAuth: user}
+	if err = cfg.loadCertSigner(); err != nil {
+		t.Fatalf("loadCertSigner() error = %s", err)
+	}
+	if len(cfg.AuthMethods()) == 0 {
+		t.Error("AuthMethods() should contain the certificate signer")
+	}
+}
+
+func TestNewSSHCertSignerInvalid(t *testing.T) {
+	ca := newTestSigner(t)
+	user := newTestSigner(t)
+	now := uint64(time.Now().Unix())
+
+	expired := newTestCertificate(t, ca, user, now-7200, now-3600)
+	if _, err := NewSSHCertSigner(expired, user); !errors.Is(err, ErrSSHCertExpired) {
+		t.Errorf("NewSSHCertSigner() with expired cert error = %v, want %v", err, ErrSSHCertExpired)
+	}
+
+	notYetValid := newTestCertificate(t, ca, user, now+3600, gossh.CertTimeInfinity)
+	if _, err := NewSSHCertSigner(notYetValid, user); !errors.Is(err, ErrSSHCertInvalid) {
+		t.Errorf("NewSSHCertSigner() with future cert error = %v, want %v", err, ErrSSHCertInvalid)
+	}
+
+	valid := newTestCertificate(t, ca, user, 0, gossh.CertTimeInfinity)
+	if _, err := NewSSHCertSigner(valid, newTestSigner(t)); !errors.Is(err, ErrSSHCertInvalid) {
+		t.Errorf("NewSSHCertSigner() with mismatched key error = %v, want %v", err, ErrSSHCertInvalid)
+	}
+
+	plainKey := string(gossh.MarshalAuthorizedKey(user.PublicKey()))
+	if _, err := ParseSSHCertificate(plainKey); !errors.Is(err, ErrSSHCertInvalid) {
+		t.Errorf("ParseSSHCertificate() with plain key error = %v, want %v", err, ErrSSHCertInvalid)
+	}
+
+	cfg := SSHClientOptions{Certificate: valid}
+	if err := cfg.loadCertSigner(); !errors.Is(err, ErrSSHCertInvalid) {
+		t.Errorf("loadCertSigner() without private key error = %v, want %v", err, ErrSSHCertInvalid)
+	}
+}
@@ -0,0 +1,119 @@
+package srvconn
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/jumpserver/koko/pkg/common"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+/*
+多级网关：资产位于多层堡垒机之后时，按顺序依次通过每一跳网关建立 SSH 连接，最后一跳网关再连接资产。
+上一跳的 SSHClient 作为下一跳的 ProxyClient，关闭资产的连接时会依次关闭所有网关的连接。
+每一跳的连接和握手都使用该跳的超时时间；资产会话的 keepalive 经过所有网关转发，任意一跳断开都会被检测到。
+*/
+
+var ErrHandshakeTimeout = errors.New("ssh handshake through gateway timeout")
+
+// GatewayHopError 多级网关中某一跳连接失败，Hop 从 1 开始
+type GatewayHopError struct {
+	Hop   int
+	Total int
+	Addr  string
+	Err   error
+}
+
+func (e *GatewayHopError) Error() string {
+	return fmt.Sprintf("gateway hop %d/%d (%s) failed: %s", e.Hop, e.Total, e.Addr, e.Err)
+}
+
+func (e *GatewayHopError) Unwrap() error {
+	return e.Err
+}
+
+// NewGatewayChainOptions 多级网关每一跳都使用 SSH 连接
+func NewGatewayChainOptions(gateways []model.Gateway, timeout int) []SSHClientOptions {
+	hops := make([]SSHClientOptions, 0, len(gateways))
+	for i := range gateways {
+		gateway := gateways[i]
+		hop := SSHClientOptions{
+			Host:     common.TrimHostBrackets(gateway.Address),
+			Port:     strconv.Itoa(gateway.Protocols.GetProtocolPort(model.ProtocolSSH)),
+			Username: gateway.Account.Username,
+			Timeout:  timeout,
+		}
+		if gateway.Account.IsSSHKey() {
+			hop.PrivateKey = gateway.Account.Secret
+		} else {
+			hop.Password = gateway.Account.Secret
+		}
+		hops = append(hops, hop)
+	}
+	return hops
+}
+
+// newGatewayChainClient 依次连接每一跳网关，返回最后一跳网关的 client
+func newGatewayChainClient(hops []SSHClientOptions) (*SSHClient, error) {
+	var client *SSHClient
+	for i := range hops {
+		hop := hops[i]
+		next, err := dialSSHClient(client, &hop)
+		if err != nil {
+			if client != nil {
+				_ = client.Close()
+			}
+			return nil, &GatewayHopError{Hop: i + 1, Total: len(hops),
+				Addr: net.JoinHostPort(hop.Host, hop.Port), Err: err}
+		}
+		logger.Infof("Gateway hop %d/%d (%s) connected", i+1, len(hops), next)
+		client = next
+	}
+	return client, nil
+}
+
+// dialSSHClient 通过 proxyClient 建立到 cfg 的 SSH 连接，proxyClient 为空时直接连接。
+// 连接成功后 proxyClient 由返回的 client 关闭
+func dialSSHClient(proxyClient *SSHClient, cfg *SSHClientOptions) (*SSHClient, error) {
+	gosshCfg := cfg.clientConfig()
+	addr := net.JoinHostPort(cfg.Host, cfg.Port)
+	if proxyClient == nil {
+		gosshClient, err := DialSSH(addr, &gosshCfg)
+		if err != nil {
+			return nil, err
+		}
+		return &SSHClient{Cfg: cfg, Client: gosshClient,
+			traceSessionMap: make(map[*gossh.Session]time.Time)}, nil
+	}
+	conn, err := proxyClient.Dial("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrGatewayDial, err)
+	}
+	// 网关转发的连接不支持设置 deadline，超时后关闭连接以结束握手
+	var timer *time.Timer
+	if gosshCfg.Timeout > 0 {
+		timer = time.AfterFunc(gosshCfg.Timeout, func() {
+			_ = conn.Close()
+		})
+	}
+	sshConn, chans, reqs, err := gossh.NewClientConn(conn, addr, &gosshCfg)
+	if timer != nil && !timer.Stop() {
+		if err == nil {
+			_ = sshConn.Close()
+		}
+		return nil, ErrHandshakeTimeout
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("%w: %s", ErrSSHClient, err)
+	}
+	return &SSHClient{Cfg: cfg, Client: gossh.NewClient(sshConn, chans, reqs),
+		traceSessionMap: make(map[*gossh.Session]time.Time),
+		ProxyClient:     proxyClient}, nil
+}
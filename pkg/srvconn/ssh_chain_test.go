@@ -0,0 +1,84 @@
+package srvconn
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/gliderlabs/ssh"
+)
+
+// startFakeSSHServer 模拟支持端口转发的网关，用户名为 name
+func startFakeSSHServer(t *testing.T, name string) SSHClientOptions {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &ssh.Server{
+		Handler: func(s ssh.Session) {
+			_, _ = s.Write([]byte(name))
+		},
+		PasswordHandler: func(ctx ssh.Context, password string) ssh.AuthResult {
+			if ctx.User() == name && password == "secret" {
+				return ssh.AuthSuccessful
+			}
+			return ssh.AuthFailed
+		},
+		LocalPortForwardingCallback: func(ctx ssh.Context, host string, port uint32) bool {
+			return true
+		},
+		ChannelHandlers: map[string]ssh.ChannelHandler{
+			"session":      ssh.DefaultSessionHandler,
+			"direct-tcpip": ssh.DirectTCPIPHandler,
+		},
+	}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { _ = srv.Close() })
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	return SSHClientOptions{Host: host, Port: port, Username: name, Password: "secret", Timeout: 3}
+}
+
+func TestNewSSHClientThroughGatewayChain(t *testing.T) {
+	hop1 := startFakeSSHServer(t, "hop1")
+	hop2 := startFakeSSHServer(t, "hop2")
+	asset := startFakeSSHServer(t, "asset")
+
+	client, err := NewSSHClient(SSHClientHost(asset.Host), SSHClientUsername("asset"),
+		SSHClientPassword("secret"), SSHClientTimeout(3),
+		func(conf *SSHClientOptions) { conf.Port = asset.Port },
+		SSHClientGatewayChain(hop1, hop2))
+	if err != nil {
+		t.Fatalf("NewSSHClient() error = %s", err)
+	}
+	if client.ProxyClient == nil || client.ProxyClient.Cfg.Username != "hop2" ||
+		client.ProxyClient.ProxyClient == nil || client.ProxyClient.ProxyClient.Cfg.Username != "hop1" {
+		t.Fatal("client should be connected through hop1 and hop2 in order")
+	}
+	sess, err := client.AcquireSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := sess.Output("")
+	client.ReleaseSession(sess)
+	if err != nil || string(out) != "asset" {
+		t.Errorf("session output = %q, %v, want asset", out, err)
+	}
+	_ = client.Close()
+	// 关闭后所有网关的连接都已断开
+	if _, err = client.ProxyClient.ProxyClient.NewSession(); err == nil {
+		t.Error("first hop connection is still alive after Close()")
+	}
+
+	badHop := hop2
+	badHop.Password = "wrong"
+	_, err = NewSSHClient(SSHClientHost(asset.Host), SSHClientUsername("asset"),
+		SSHClientPassword("secret"), SSHClientGatewayChain(hop1, badHop))
+	var hopErr *GatewayHopError
+	if !errors.As(err, &hopErr) || hopErr.Hop != 2 || hopErr.Total != 2 {
+		t.Fatalf("NewSSHClient() error = %v, want hop 2/2 failed", err)
+	}
+	if !strings.Contains(err.Error(), "hop 2/2") {
+		t.Errorf("error message %q should contain the failed hop", err)
+	}
+}
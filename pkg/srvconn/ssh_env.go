@@ -0,0 +1,65 @@
+package srvconn
+
+import (
+	"sort"
+	"strings"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+/*
+FilterAllowedEnv 从客户端 SSH 会话的环境变量(KEY=VALUE 格式)中筛选出允许转发给资产的变量。
+allowlist 中的名称区分大小写，支持以 * 结尾的前缀匹配，如 LC_*；不在列表中的变量被忽略。
+*/
+
+func FilterAllowedEnv(environ []string, allowlist []string) map[string]string {
+	if len(environ) == 0 {
+		return nil
+	}
+	env := make(map[string]string, len(environ))
+	for _, item := range environ {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok || name == "" {
+			continue
+		}
+		if !IsEnvAllowed(name, allowlist) {
+			logger.Debugf("Ignore client env %s not in allowlist", name)
+			continue
+		}
+		env[name] = value
+	}
+	return env
+}
+
+// IsEnvAllowed 变量名是否匹配 allowlist 中的名称或前缀
+func IsEnvAllowed(name string, allowlist []string) bool {
+	for _, pattern := range allowlist {
+		pattern = strings.TrimSpace(pattern)
+		if strings.HasSuffix(pattern, "*") {
+			if prefix := strings.TrimSuffix(pattern, "*"); prefix != "" && strings.HasPrefix(name, prefix) {
+				return true
+			}
+			continue
+		}
+		if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// SetSessionEnv 需要在请求 pty 和执行命令之前调用，资产 sshd 未允许(AcceptEnv)的变量会被拒绝
+func SetSessionEnv(sess *gossh.Session, env map[string]string) {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := sess.Setenv(name, env[name]); err != nil {
+			logger.Debugf("SSH session set env %s rejected by asset: %s", name, err)
+		}
+	}
+}
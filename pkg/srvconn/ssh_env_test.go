@@ -0,0 +1,33 @@
+package srvconn
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterAllowedEnv(t *testing.T) {
+	environ := []string{
+		"LANG=en_US.UTF-8",
+		"TZ=Asia/Shanghai",
+		"LC_ALL=C",
+		"LC_CTYPE=UTF-8",
+		"APP_ENV=prod=blue",
+		"LD_PRELOAD=/tmp/evil.so",
+		"PATH=/tmp",
+		"invalid",
+	}
+	allowlist := []string{"LANG", "TZ", " LC_* ", "APP_ENV", "*"}
+	want := map[string]string{
+		"LANG":     "en_US.UTF-8",
+		"TZ":       "Asia/Shanghai",
+		"LC_ALL":   "C",
+		"LC_CTYPE": "UTF-8",
+		"APP_ENV":  "prod=blue",
+	}
+	if got := FilterAllowedEnv(environ, allowlist); !reflect.DeepEqual(got, want) {
+		t.Errorf("FilterAllowedEnv() = %v, want %v", got, want)
+	}
+	if got := FilterAllowedEnv(environ, nil); len(got) != 0 {
+		t.Errorf("FilterAllowedEnv() with empty allowlist = %v, want empty", got)
+	}
+}
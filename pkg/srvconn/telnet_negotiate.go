@@ -0,0 +1,245 @@
+package srvconn
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sync"
+
+	"github.com/LeeEirc/tclientlib"
+
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+const (
+	telnetSubOptionIS   = 0
+	telnetSubOptionSEND = 1
+)
+
+const (
+	telnetStateData = iota
+	telnetStateIAC
+	telnetStateOption
+	telnetStateSB
+	telnetStateSBIAC
+)
+
+/*
+telnetNegotiator 包装 telnet 的底层连接，在读取时处理服务端发起的 IAC 选项协商，
+只把终端数据交给 tclientlib。部分网络设备(如 Cisco IOS)依赖 TTYPE、NAWS、ECHO
+的协商结果，协商按 RFC 1143 的方式记录选项状态，已处于请求的状态时不再回复，避免协商死循环。
+*/
+
+type telnetNegotiator struct {
+	net.Conn
+
+	termType string
+
+	writeMu sync.Mutex
+
+	mu            sync.Mutex
+	width, height int
+	localOpts     map[byte]bool // 客户端已启用(WILL)的选项
+	remoteOpts    map[byte]bool // 服务端已启用(DO)的选项
+
+	// 跨 Read 的解析状态，IAC 序列可能被拆分在多个 TCP 包中
+	state   int
+	verb    byte
+	sbBytes []byte
+}
+
+func newTelnetNegotiator(conn net.Conn, termType string, win Windows) *telnetNegotiator {
+	return &telnetNegotiator{
+		Conn:       conn,
+		termType:   termType,
+		width:      win.Width,
+		height:     win.Height,
+		localOpts:  make(map[byte]bool),
+		remoteOpts: make(map[byte]bool),
+	}
+}
+
+func (t *telnetNegotiator) Read(p []byte) (int, error) {
+	for {
+		nr, err := t.Conn.Read(p)
+		// 过滤后的数据不会比原数据长，直接在 p 上原地写入
+		n := t.filter(p[:nr], p)
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+func (t *telnetNegotiator) Write(p []byte) (int, error) {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	return t.Conn.Write(p)
+}
+
+func (t *telnetNegotiator) filter(src, dst []byte) int {
+	n := 0
+	for _, b := range src {
+		switch t.state {
+		case telnetStateData:
+			if b == tclientlib.IAC {
+				t.state = telnetStateIAC
+				continue
+			}
+			dst[n] = b
+			n++
+		case telnetStateIAC:
+			switch b {
+			case tclientlib.IAC:
+				// IAC IAC 是转义的 255 数据
+				dst[n] = b
+				n++
+				t.state = telnetStateData
+			case tclientlib.DO, tclientlib.DONT, tclientlib.WILL, tclientlib.WONT:
+				t.verb = b
+				t.state = telnetStateOption
+			case tclientlib.SB:
+				t.sbBytes = t.sbBytes[:0]
+				t.state = telnetStateSB
+			default:
+				// NOP、GA 等命令无需处理
+				t.state = telnetStateData
+			}
+		case telnetStateOption:
+			t.handleOption(t.verb, b)
+			t.state = telnetStateData
+		case telnetStateSB:
+			if b == tclientlib.IAC {
+				t.state = telnetStateSBIAC
+				continue
+			}
+			t.sbBytes = append(t.sbBytes, b)
+		case telnetStateSBIAC:
+			switch b {
+			case tclientlib.SE:
+				t.handleSubOption(t.sbBytes)
+				t.state = telnetStateData
+			case tclientlib.IAC:
+				t.sbBytes = append(t.sbBytes, b)
+				t.state = telnetStateSB
+			default:
+				t.state = telnetStateSB
+			}
+		}
+	}
+	return n
+}
+
+func (t *telnetNegotiator) handleOption(verb, opt byte) {
+	t.mu.Lock()
+	var replies [][]byte
+	switch verb {
+	case tclientlib.DO:
+		switch opt {
+		case tclientlib.TTYPE, tclientlib.NAWS, tclientlib.SGA, tclientlib.BINARY:
+			if !t.localOpts[opt] {
+				t.localOpts[opt] = true
+				replies = append(replies, telnetCommand(tclientlib.WILL, opt))
+			}
+			if opt == tclientlib.NAWS {
+				// 服务端每次请求都发送当前的窗口大小
+				replies = append(replies, t.nawsPacket())
+			}
+		default:
+			// 包括 ECHO，由服务端回显，客户端不回显
+			replies = append(replies, telnetCommand(tclientlib.WONT, opt))
+		}
+	case tclientlib.DONT:
+		if t.localOpts[opt] {
+			t.localOpts[opt] = false
+			replies = append(replies, telnetCommand(tclientlib.WONT, opt))
+		}
+	case tclientlib.WILL:
+		switch opt {
+		case tclientlib.ECHO, tclientlib.SGA, tclientlib.BINARY:
+			if !t.remoteOpts[opt] {
+				t.remoteOpts[opt] = true
+				replies = append(replies, telnetCommand(tclientlib.DO, opt))
+			}
+		default:
+			replies = append(replies, telnetCommand(tclientlib.DONT, opt))
+		}
+	case tclientlib.WONT:
+		if t.remoteOpts[opt] {
+			t.remoteOpts[opt] = false
+			replies = append(replies, telnetCommand(tclientlib.DONT, opt))
+		}
+	}
+	t.mu.Unlock()
+	t.reply(replies...)
+}
+
+func (t *telnetNegotiator) handleSubOption(params []byte) {
+	if len(params) < 2 {
+		return
+	}
+	switch params[0] {
+	case tclientlib.TTYPE:
+		if params[1] != telnetSubOptionSEND {
+			return
+		}
+		data := append([]byte{telnetSubOptionIS}, t.termType...)
+		t.reply(telnetSubNegotiation(tclientlib.TTYPE, data))
+	default:
+		logger.Debugf("Telnet ignore sub negotiation option %d", params[0])
+	}
+}
+
+// SetWindowSize 记录窗口大小，NAWS 已协商时通知服务端
+func (t *telnetNegotiator) SetWindowSize(width, height int) error {
+	t.mu.Lock()
+	t.width, t.height = width, height
+	enabled := t.localOpts[tclientlib.NAWS]
+	packet := t.nawsPacket()
+	t.mu.Unlock()
+	if !enabled {
+		return nil
+	}
+	return t.reply(packet)
+}
+
+func (t *telnetNegotiator) nawsPacket() []byte {
+	width, height := t.width, t.height
+	if width > tclientlib.MAX_WINDOW_WIDTH {
+		width = tclientlib.MAX_WINDOW_WIDTH
+	}
+	if height > tclientlib.MAX_WINDOW_HEIGHT {
+		height = tclientlib.MAX_WINDOW_HEIGHT
+	}
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint16(data[:2], uint16(width))
+	binary.BigEndian.PutUint16(data[2:], uint16(height))
+	return telnetSubNegotiation(tclientlib.NAWS, data)
+}
+
+func (t *telnetNegotiator) reply(packets ...[]byte) error {
+	if len(packets) == 0 {
+		return nil
+	}
+	_, err := t.Write(bytes.Join(packets, nil))
+	if err != nil {
+		logger.Errorf("Telnet reply option negotiation failed: %s", err)
+	}
+	return err
+}
+
+func telnetCommand(verb, opt byte) []byte {
+	return []byte{tclientlib.IAC, verb, opt}
+}
+
+func telnetSubNegotiation(opt byte, data []byte) []byte {
+	buf := make([]byte, 0, len(data)+6)
+	buf = append(buf, tclientlib.IAC, tclientlib.SB, opt)
+	for _, b := range data {
+		// 参数中的 255 需要转义
+		if b == tclientlib.IAC {
+			buf = append(buf, tclientlib.IAC)
+		}
+		buf = append(buf, b)
+	}
+	return append(buf, tclientlib.IAC, tclientlib.SE)
+}
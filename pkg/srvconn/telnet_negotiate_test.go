@@ -0,0 +1,141 @@
+package srvconn
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/LeeEirc/tclientlib"
+)
+
+// startMockTelnetServer 启动一个模拟 telnet 服务器，handler 处理客户端连接
+func startMockTelnetServer(t *testing.T, handler func(conn net.Conn)) (string, int) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+		handler(conn)
+	}()
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	return host, portNum
+}
+
+func expectBytes(t *testing.T, conn net.Conn, want []byte) bool {
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Errorf("read from client err: %s", err)
+		return false
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("client sent %v, want %v", got, want)
+		return false
+	}
+	return true
+}
+
+func TestTelnetNegotiationHandshake(t *testing.T) {
+	serverDone := make(chan struct{})
+	host, port := startMockTelnetServer(t, func(conn net.Conn) {
+		defer close(serverDone)
+		// 建立连接后客户端会先发送一个换行
+		if !expectBytes(t, conn, []byte("\r\n")) {
+			return
+		}
+		_, _ = conn.Write([]byte{
+			tclientlib.IAC, tclientlib.DO, tclientlib.TTYPE,
+			tclientlib.IAC, tclientlib.DO, tclientlib.NAWS,
+			tclientlib.IAC, tclientlib.WILL, tclientlib.ECHO,
+			tclientlib.IAC, tclientlib.WILL, tclientlib.SGA,
+			tclientlib.IAC, tclientlib.DO, tclientlib.LINEMODE,
+		})
+		want := []byte{
+			tclientlib.IAC, tclientlib.WILL, tclientlib.TTYPE,
+			tclientlib.IAC, tclientlib.WILL, tclientlib.NAWS,
+			tclientlib.IAC, tclientlib.SB, tclientlib.NAWS, 0, 100, 0, 40, tclientlib.IAC, tclientlib.SE,
+			tclientlib.IAC, tclientlib.DO, tclientlib.ECHO,
+			tclientlib.IAC, tclientlib.DO, tclientlib.SGA,
+			tclientlib.IAC, tclientlib.WONT, tclientlib.LINEMODE,
+		}
+		if !expectBytes(t, conn, want) {
+			return
+		}
+		// 重复的请求不再回复，终端类型子协商拆分在两个包中发送
+		_, _ = conn.Write([]byte{
+			tclientlib.IAC, tclientlib.WILL, tclientlib.ECHO,
+			tclientlib.IAC, tclientlib.SB, tclientlib.TTYPE,
+		})
+		time.Sleep(50 * time.Millisecond)
+		_, _ = conn.Write([]byte{telnetSubOptionSEND, tclientlib.IAC, tclientlib.SE})
+		want = append([]byte{tclientlib.IAC, tclientlib.SB, tclientlib.TTYPE, telnetSubOptionIS}, "vt100"...)
+		want = append(want, tclientlib.IAC, tclientlib.SE)
+		if !expectBytes(t, conn, want) {
+			return
+		}
+		_, _ = conn.Write(append([]byte("Router"), tclientlib.IAC, tclientlib.IAC, '>'))
+		// 窗口大小变化通过 NAWS 通知
+		expectBytes(t, conn, []byte{
+			tclientlib.IAC, tclientlib.SB, tclientlib.NAWS, 0, 120, 0, 255, 255, tclientlib.IAC, tclientlib.SE,
+		})
+	})
+
+	tc, err := NewTelnetConnection(TelnetHost(host), TelnetPort(port), TelnetUTimeout(5),
+		TelnetPtyWin(Windows{Width: 100, Height: 40}), func(cfg *TelnetConfig) { cfg.Term = "vt100" })
+	if err != nil {
+		t.Fatalf("NewTelnetConnection() error = %s", err)
+	}
+	defer tc.Close()
+
+	want := append([]byte("Router"), tclientlib.IAC, '>')
+	var received []byte
+	buf := make([]byte, 1024)
+	for !bytes.Equal(received, want) {
+		nr, err := tc.Read(buf)
+		if err != nil {
+			t.Fatalf("Read() error = %s, received %q", err, received)
+		}
+		received = append(received, buf[:nr]...)
+	}
+	if err = tc.SetWinSize(120, 255); err != nil {
+		t.Errorf("SetWinSize() error = %s", err)
+	}
+	<-serverDone
+}
+
+func TestTelnetNegotiatorRefuseUnsupported(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	negotiator := newTelnetNegotiator(client, "xterm", Windows{Width: 80, Height: 24})
+
+	go func() {
+		_, _ = server.Write([]byte{tclientlib.IAC, tclientlib.WILL, tclientlib.XDISPLOC, 'o', 'k'})
+	}()
+	go func() {
+		buf := make([]byte, 16)
+		_, _ = negotiator.Read(buf)
+	}()
+	got := make([]byte, 3)
+	_ = server.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := io.ReadFull(server, got); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{tclientlib.IAC, tclientlib.DONT, tclientlib.XDISPLOC}; !bytes.Equal(got, want) {
+		t.Errorf("negotiator reply %v, want %v", got, want)
+	}
+	// NAWS 未协商时不发送窗口大小
+	if err := negotiator.SetWindowSize(100, 30); err != nil {
+		t.Errorf("SetWindowSize() error = %s", err)
+	}
+}
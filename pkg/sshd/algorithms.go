@@ -0,0 +1,134 @@
+package sshd
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+/*
+SSH_ALGORITHM_PROFILE 选择 SSH 服务允许的加密、密钥交换和 MAC 算法：
+secure(默认) 只允许 AEAD、CTR 加密，ECDH、curve25519 和 group14-sha256 密钥交换以及 SHA-2 MAC；
+compat 额外允许 CBC 加密、SHA-1 的密钥交换和 MAC，用于无法升级的旧客户端。
+SSH_CIPHERS、SSH_KEY_EXCHANGES、SSH_MACS 不为空时替换对应的列表，如 FIPS 环境去除 chacha20 和 curve25519。
+客户端不支持允许的算法时握手失败，记录客户端提供的算法。
+*/
+
+const (
+	SSHAlgorithmProfileSecure = "secure"
+	SSHAlgorithmProfileCompat = "compat"
+)
+
+type sshAlgorithms struct {
+	Ciphers      []string
+	KeyExchanges []string
+	MACs         []string
+}
+
+var (
+	secureSSHAlgorithms = sshAlgorithms{
+		Ciphers: []string{
+			"aes128-gcm@openssh.com", "aes256-gcm@openssh.com", "chacha20-poly1305@openssh.com",
+			"aes128-ctr", "aes192-ctr", "aes256-ctr",
+		},
+		KeyExchanges: []string{
+			"curve25519-sha256", "curve25519-sha256@libssh.org",
+			"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+			"diffie-hellman-group14-sha256",
+		},
+		MACs: []string{"hmac-sha2-256-etm@openssh.com", "hmac-sha2-256"},
+	}
+
+	compatSSHAlgorithms = sshAlgorithms{
+		Ciphers: append(append([]string(nil), secureSSHAlgorithms.Ciphers...),
+			"aes128-cbc", "3des-cbc"),
+		KeyExchanges: append(append([]string(nil), secureSSHAlgorithms.KeyExchanges...),
+			"diffie-hellman-group14-sha1", "diffie-hellman-group1-sha1"),
+		MACs: append(append([]string(nil), secureSSHAlgorithms.MACs...),
+			"hmac-sha1", "hmac-sha1-96"),
+	}
+
+	// SSH 库服务端支持的算法
+	availableSSHAlgorithms = sshAlgorithms{
+		Ciphers: append(append([]string(nil), compatSSHAlgorithms.Ciphers...),
+			"arcfour256", "arcfour128", "arcfour"),
+		KeyExchanges: compatSSHAlgorithms.KeyExchanges,
+		MACs:         compatSSHAlgorithms.MACs,
+	}
+)
+
+// ParseSSHAlgorithms 按 SSH_ALGORITHM_PROFILE 和 SSH_CIPHERS 等配置返回 SSH 服务允许的算法
+func ParseSSHAlgorithms(conf *config.Config) (*sshAlgorithms, error) {
+	var profile sshAlgorithms
+	switch strings.ToLower(strings.TrimSpace(conf.SSHAlgorithmProfile)) {
+	case "", SSHAlgorithmProfileSecure:
+		profile = secureSSHAlgorithms
+	case SSHAlgorithmProfileCompat:
+		profile = compatSSHAlgorithms
+	default:
+		return nil, fmt.Errorf("unknown SSH_ALGORITHM_PROFILE %q, available: %s, %s",
+			conf.SSHAlgorithmProfile, SSHAlgorithmProfileSecure, SSHAlgorithmProfileCompat)
+	}
+	ciphers, err := parseAlgorithmList("SSH_CIPHERS", conf.SSHCiphers,
+		profile.Ciphers, availableSSHAlgorithms.Ciphers)
+	if err != nil {
+		return nil, err
+	}
+	kexAlgos, err := parseAlgorithmList("SSH_KEY_EXCHANGES", conf.SSHKeyExchanges,
+		profile.KeyExchanges, availableSSHAlgorithms.KeyExchanges)
+	if err != nil {
+		return nil, err
+	}
+	macs, err := parseAlgorithmList("SSH_MACS", conf.SSHMACs,
+		profile.MACs, availableSSHAlgorithms.MACs)
+	if err != nil {
+		return nil, err
+	}
+	return &sshAlgorithms{Ciphers: ciphers, KeyExchanges: kexAlgos, MACs: macs}, nil
+}
+
+// parseAlgorithmList values 为空时使用 defaults，否则校验后去重，保持配置的顺序
+func parseAlgorithmList(key string, values, defaults, available []string) ([]string, error) {
+	ret := make([]string, 0, len(values))
+	var unknown []string
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		switch {
+		case value == "":
+		case !containsMethod(available, value):
+			unknown = append(unknown, value)
+		case !containsMethod(ret, value):
+			ret = append(ret, value)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unsupported algorithms %s in %s, available: %s",
+			strings.Join(unknown, ", "), key, strings.Join(available, ", "))
+	}
+	if len(ret) == 0 {
+		return defaults, nil
+	}
+	return ret, nil
+}
+
+func (a *sshAlgorithms) gosshConfig() gossh.Config {
+	return gossh.Config{Ciphers: a.Ciphers, KeyExchanges: a.KeyExchanges, MACs: a.MACs}
+}
+
+func (a *sshAlgorithms) String() string {
+	return fmt.Sprintf("ciphers: %s; key exchanges: %s; MACs: %s", strings.Join(a.Ciphers, ", "),
+		strings.Join(a.KeyExchanges, ", "), strings.Join(a.MACs, ", "))
+}
+
+// sshConnFailedCallback 记录因为没有共同的算法而被拒绝的客户端，错误信息包含客户端提供的算法
+func sshConnFailedCallback(conn net.Conn, err error) {
+	if err == nil || !strings.Contains(err.Error(), "no common algorithm") {
+		return
+	}
+	logger.Warnf("SSH conn from %s rejected, no acceptable algorithms: %s", conn.RemoteAddr(), err)
+}
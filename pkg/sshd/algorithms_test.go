@@ -0,0 +1,119 @@
+package sshd
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/jumpserver/koko/pkg/config"
+)
+
+func TestParseSSHAlgorithms(t *testing.T) {
+	conf := config.Config{}
+	algorithms, err := ParseSSHAlgorithms(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*algorithms, secureSSHAlgorithms) {
+		t.Errorf("ParseSSHAlgorithms() = %s, want the secure profile", algorithms)
+	}
+	conf = config.Config{SSHAlgorithmProfile: "Compat", SSHMACs: []string{" hmac-sha2-256", "hmac-sha2-256"}}
+	if algorithms, err = ParseSSHAlgorithms(&conf); err != nil {
+		t.Fatal(err)
+	}
+	if !containsMethod(algorithms.Ciphers, "aes128-cbc") || !reflect.DeepEqual(algorithms.MACs, []string{"hmac-sha2-256"}) {
+		t.Errorf("ParseSSHAlgorithms() = %s, want compat ciphers and the configured MACs", algorithms)
+	}
+	invalid := []config.Config{
+		{SSHAlgorithmProfile: "legacy"},
+		{SSHCiphers: []string{"aes128-ctr", "blowfish-cbc"}},
+		{SSHKeyExchanges: []string{"diffie-hellman-group-exchange-sha256"}},
+		{SSHMACs: []string{"hmac-md5"}},
+	}
+	for i := range invalid {
+		if _, err = ParseSSHAlgorithms(&invalid[i]); err == nil {
+			t.Errorf("ParseSSHAlgorithms(%+v) want error", invalid[i])
+		}
+	}
+}
+
+// startAlgorithmTestServer 启动只允许 algorithms 的 SSH 服务，返回地址和握手失败的错误
+func startAlgorithmTestServer(t *testing.T, algorithms *sshAlgorithms) (string, <-chan error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := gossh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := make(chan error, 1)
+	srv := &ssh.Server{
+		HostSigners: []ssh.Signer{signer},
+		ServerConfigCallback: func(ctx ssh.Context) *gossh.ServerConfig {
+			return &gossh.ServerConfig{Config: algorithms.gosshConfig()}
+		},
+		PasswordHandler: func(ctx ssh.Context, password string) ssh.AuthResult { return ssh.AuthFailed },
+		ConnectionFailedCallback: func(conn net.Conn, err error) {
+			sshConnFailedCallback(conn, err)
+			failed <- err
+		},
+	}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { _ = srv.Close() })
+	return ln.Addr().String(), failed
+}
+
+func TestWeakAlgorithmClientRejected(t *testing.T) {
+	algorithms, err := ParseSSHAlgorithms(&config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, failed := startAlgorithmTestServer(t, algorithms)
+	dial := func(cfg gossh.Config) error {
+		conn, err := gossh.Dial("tcp", addr, &gossh.ClientConfig{
+			Config:          cfg,
+			User:            "test",
+			Auth:            []gossh.AuthMethod{gossh.Password("test")},
+			HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+			Timeout:         5 * time.Second,
+		})
+		if conn != nil {
+			_ = conn.Close()
+		}
+		return err
+	}
+	weak := []gossh.Config{
+		{Ciphers: []string{"aes128-cbc"}},
+		{KeyExchanges: []string{"diffie-hellman-group1-sha1"}},
+		{MACs: []string{"hmac-sha1"}, Ciphers: []string{"aes128-ctr"}},
+	}
+	for _, cfg := range weak {
+		if err = dial(cfg); err == nil || !strings.Contains(err.Error(), "no common algorithm") {
+			t.Errorf("client with %+v: err = %v, want handshake rejected", cfg, err)
+		}
+		select {
+		case serverErr := <-failed:
+			if !strings.Contains(serverErr.Error(), "client offered") {
+				t.Errorf("server error %q should contain the client offered algorithms", serverErr)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("server did not report the rejected handshake")
+		}
+	}
+	// 握手成功后因为密码错误认证失败
+	if err = dial(gossh.Config{}); err == nil || strings.Contains(err.Error(), "no common algorithm") {
+		t.Errorf("client with default algorithms: err = %v, want auth failure", err)
+	}
+}
@@ -0,0 +1,57 @@
+package sshd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/*
+SSH_AUTH_METHODS 列出 SSH 服务开启的认证方式：publickey、password、keyboard-interactive，
+没有列出的认证方式不会通知客户端，客户端使用时直接拒绝。
+列表的顺序不影响认证，客户端按照自己的配置决定尝试认证方式的顺序。
+keyboard-interactive 用于 MFA 和登录复核，关闭后需要 MFA 或者登录复核的用户不能通过 SSH 登录。
+全部关闭(或者只开启 keyboard-interactive)时没有可用的认证方式，启动失败。
+*/
+
+const (
+	authMethodPublicKey           = "publickey"
+	authMethodPassword            = "password"
+	authMethodKeyboardInteractive = "keyboard-interactive"
+)
+
+var supportedAuthMethods = []string{authMethodPublicKey, authMethodPassword, authMethodKeyboardInteractive}
+
+// ParseAuthMethods 校验 SSH_AUTH_METHODS，返回去重后开启的认证方式
+func ParseAuthMethods(methods []string) ([]string, error) {
+	ret := make([]string, 0, len(methods))
+	var unknown []string
+	for _, method := range methods {
+		method = strings.ToLower(strings.TrimSpace(method))
+		switch {
+		case method == "":
+		case !containsMethod(supportedAuthMethods, method):
+			unknown = append(unknown, method)
+		case !containsMethod(ret, method):
+			ret = append(ret, method)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown auth methods %s in SSH_AUTH_METHODS, available: %s",
+			strings.Join(unknown, ", "), strings.Join(supportedAuthMethods, ", "))
+	}
+	if !containsMethod(ret, authMethodPublicKey) && !containsMethod(ret, authMethodPassword) {
+		return nil, errors.New("SSH_AUTH_METHODS must enable publickey or password, " +
+			"keyboard-interactive is only used for MFA and login confirmation")
+	}
+	return ret, nil
+}
+
+func containsMethod(methods []string, method string) bool {
+	for i := range methods {
+		if methods[i] == method {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package sshd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gliderlabs/ssh"
+)
+
+func TestParseAuthMethods(t *testing.T) {
+	methods, err := ParseAuthMethods([]string{" PublicKey", "keyboard-interactive", "publickey", "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(methods, ","); got != "publickey,keyboard-interactive,password" {
+		t.Errorf("ParseAuthMethods() = %s, want the enabled methods without duplicates", got)
+	}
+	invalid := [][]string{nil, {}, {"keyboard-interactive"}, {"password", "gssapi-with-mic"}}
+	for _, methods := range invalid {
+		if _, err = ParseAuthMethods(methods); err == nil {
+			t.Errorf("ParseAuthMethods(%v) want error", methods)
+		}
+	}
+}
+
+func TestSetAuthHandlers(t *testing.T) {
+	srv := &ssh.Server{}
+	setAuthHandlers(srv, nil, []string{authMethodPublicKey})
+	if srv.PublicKeyHandler == nil || srv.PasswordHandler != nil || srv.KeyboardInteractiveHandler != nil ||
+		srv.NextAuthMethodsHandler != nil {
+		t.Error("only publickey auth should be enabled")
+	}
+	srv = &ssh.Server{}
+	setAuthHandlers(srv, nil, []string{authMethodPassword, authMethodKeyboardInteractive})
+	if srv.PublicKeyHandler != nil || srv.PasswordHandler == nil || srv.KeyboardInteractiveHandler == nil {
+		t.Error("password and keyboard-interactive auth should be enabled")
+	}
+	if next := srv.NextAuthMethodsHandler(nil); len(next) != 1 || next[0] != authMethodKeyboardInteractive {
+		t.Errorf("next auth methods = %v, want keyboard-interactive", next)
+	}
+}
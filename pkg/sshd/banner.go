@@ -0,0 +1,49 @@
+package sshd
+
+import (
+	"os"
+	"strings"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+/*
+SSH_AUTH_BANNER 在 SSH 认证之前发送给客户端的提示(SSH_MSG_USERAUTH_BANNER)，如合规要求的法律声明。
+SSH_AUTH_BANNER_FILE 不为空时从文件读取，每次连接时读取以便修改后立即生效，读取失败时使用 SSH_AUTH_BANNER。
+认证前不知道用户的语言，banner 不做本地化。与登录后显示的菜单 banner 无关。
+*/
+
+// maxAuthBannerSize banner 文件的最大长度，避免误配置的大文件在每次连接时发送
+const maxAuthBannerSize = 8 * 1024
+
+func loadAuthBanner(conf *config.Config) string {
+	banner := conf.SSHAuthBanner
+	if conf.SSHAuthBannerFile != "" {
+		content, err := os.ReadFile(conf.SSHAuthBannerFile)
+		switch {
+		case err != nil:
+			logger.Errorf("Read SSH auth banner file %s failed: %s", conf.SSHAuthBannerFile, err)
+		case len(content) > maxAuthBannerSize:
+			logger.Errorf("SSH auth banner file %s is larger than %d bytes, ignored",
+				conf.SSHAuthBannerFile, maxAuthBannerSize)
+		default:
+			banner = string(content)
+		}
+	}
+	if strings.TrimSpace(banner) == "" {
+		return ""
+	}
+	// 统一使用 CRLF 换行，部分客户端只按 \n 换行时不会回到行首
+	banner = strings.ReplaceAll(banner, "\r\n", "\n")
+	banner = strings.TrimRight(banner, "\n")
+	return strings.ReplaceAll(banner, "\n", "\r\n") + "\r\n"
+}
+
+// authBannerCallback 返回空字符串时不发送 banner
+func authBannerCallback(conn gossh.ConnMetadata) string {
+	conf := config.GetConf()
+	return loadAuthBanner(&conf)
+}
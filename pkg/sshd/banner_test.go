@@ -0,0 +1,43 @@
+package sshd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jumpserver/koko/pkg/config"
+)
+
+func TestLoadAuthBanner(t *testing.T) {
+	dir := t.TempDir()
+	bannerFile := filepath.Join(dir, "issue.net")
+	if err := os.WriteFile(bannerFile, []byte("Authorized access only.\nActivity is recorded.\n\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	largeFile := filepath.Join(dir, "large")
+	if err := os.WriteFile(largeFile, []byte(strings.Repeat("a", maxAuthBannerSize+1)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		conf config.Config
+		want string
+	}{
+		{"empty", config.Config{}, ""},
+		{"blank", config.Config{SSHAuthBanner: " \n"}, ""},
+		{"text", config.Config{SSHAuthBanner: "No trespassing"}, "No trespassing\r\n"},
+		{"file", config.Config{SSHAuthBanner: "text", SSHAuthBannerFile: bannerFile},
+			"Authorized access only.\r\nActivity is recorded.\r\n"},
+		{"missing file", config.Config{SSHAuthBanner: "text", SSHAuthBannerFile: filepath.Join(dir, "missing")},
+			"text\r\n"},
+		{"large file", config.Config{SSHAuthBanner: "text", SSHAuthBannerFile: largeFile}, "text\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := loadAuthBanner(&tt.conf); got != tt.want {
+				t.Errorf("loadAuthBanner() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
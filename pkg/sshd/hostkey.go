@@ -1,9 +1,23 @@
 package sshd
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
 	"golang.org/x/crypto/ssh"
+
+	"github.com/jumpserver/koko/pkg/logger"
 )
 
+const defaultEd25519HostKeyFilename = "ssh_host_ed25519_key"
+
 func ParsePrivateKeyFromString(content string) (signer ssh.Signer, err error) {
 	return ssh.ParsePrivateKey([]byte(content))
 }
@@ -11,3 +25,90 @@ func ParsePrivateKeyFromString(content string) (signer ssh.Signer, err error) {
 func ParsePrivateKeyWithPassphrase(privateKey, Passphrase string) (signer ssh.Signer, err error) {
 	return ssh.ParsePrivateKeyWithPassphrase([]byte(privateKey), []byte(Passphrase))
 }
+
+/*
+LoadHostSigners 加载 SSH 服务的所有 host key，客户端可以协商自己偏好的算法。
+依次为 core 下发的 host key 和配置的 key 文件，同一类型的 key 只使用第一个。
+没有 Ed25519 类型的 key 时，在 keyDir 下自动生成一个并持久化，以后启动复用。
+*/
+
+func LoadHostSigners(coreHostKey string, keyFiles []string, keyDir string) ([]ssh.Signer, error) {
+	signers := make([]ssh.Signer, 0, len(keyFiles)+2)
+	keyTypes := make(map[string]string)
+	addSigner := func(signer ssh.Signer, source string) {
+		keyType := signer.PublicKey().Type()
+		if exist, ok := keyTypes[keyType]; ok {
+			logger.Warnf("SSH host key %s from %s ignored, already loaded from %s", keyType, source, exist)
+			return
+		}
+		keyTypes[keyType] = source
+		signers = append(signers, signer)
+	}
+	if coreHostKey != "" {
+		signer, err := ParsePrivateKeyFromString(coreHostKey)
+		if err != nil {
+			return nil, fmt.Errorf("parse terminal host key failed: %w", err)
+		}
+		addSigner(signer, "core")
+	}
+	for _, keyFile := range keyFiles {
+		keyFile = strings.TrimSpace(keyFile)
+		if keyFile == "" {
+			continue
+		}
+		content, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read host key %s failed: %w", keyFile, err)
+		}
+		signer, err := ParsePrivateKeyFromString(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("parse host key %s failed: %w", keyFile, err)
+		}
+		addSigner(signer, keyFile)
+	}
+	if _, ok := keyTypes[ssh.KeyAlgoED25519]; !ok {
+		keyFile := filepath.Join(keyDir, defaultEd25519HostKeyFilename)
+		signer, err := loadOrGenerateEd25519Key(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		addSigner(signer, keyFile)
+	}
+	return signers, nil
+}
+
+func loadOrGenerateEd25519Key(keyFile string) (ssh.Signer, error) {
+	content, err := os.ReadFile(keyFile)
+	if err == nil {
+		signer, err := ParsePrivateKeyFromString(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("parse host key %s failed: %w", keyFile, err)
+		}
+		return signer, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read host key %s failed: %w", keyFile, err)
+	}
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate ed25519 host key failed: %w", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("marshal ed25519 host key failed: %w", err)
+	}
+	content = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err = os.WriteFile(keyFile, content, 0600); err != nil {
+		return nil, fmt.Errorf("save host key %s failed: %w", keyFile, err)
+	}
+	logger.Infof("Generate ed25519 host key %s", keyFile)
+	return ssh.NewSignerFromKey(privateKey)
+}
+
+func signerAlgorithms(signers []ssh.Signer) []string {
+	algos := make([]string, 0, len(signers))
+	for i := range signers {
+		algos = append(algos, signers[i].PublicKey().Type())
+	}
+	return algos
+}
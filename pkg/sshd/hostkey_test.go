@@ -0,0 +1,54 @@
+package sshd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestLoadHostSigners(t *testing.T) {
+	dir := t.TempDir()
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(ecdsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaFile := filepath.Join(dir, "ssh_host_ecdsa_key")
+	content := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	if err = os.WriteFile(ecdsaFile, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	signers, err := LoadHostSigners("", []string{ecdsaFile, ecdsaFile}, dir)
+	if err != nil {
+		t.Fatalf("LoadHostSigners() error = %s", err)
+	}
+	want := []string{ssh.KeyAlgoECDSA256, ssh.KeyAlgoED25519}
+	if got := signerAlgorithms(signers); !reflect.DeepEqual(got, want) {
+		t.Fatalf("LoadHostSigners() algorithms = %v, want %v", got, want)
+	}
+
+	// 自动生成的 Ed25519 key 持久化后，再次加载使用同一个 key
+	again, err := LoadHostSigners("", nil, dir)
+	if err != nil {
+		t.Fatalf("LoadHostSigners() reload error = %s", err)
+	}
+	if len(again) != 1 || string(again[0].PublicKey().Marshal()) != string(signers[1].PublicKey().Marshal()) {
+		t.Error("LoadHostSigners() should reuse the generated ed25519 host key")
+	}
+
+	if _, err = LoadHostSigners("", []string{filepath.Join(dir, "not_exist")}, dir); err == nil {
+		t.Error("LoadHostSigners() with missing key file should return error")
+	}
+}
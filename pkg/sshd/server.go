@@ -2,8 +2,11 @@ package sshd
 
 import (
 	"context"
+	"errors"
 	"net"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gliderlabs/ssh"
@@ -23,19 +26,11 @@ const (
 	sshSubSystemSFTP      = "sftp"
 )
 
-var (
-	supportedMACs = []string{"hmac-sha2-256-etm@openssh.com",
-		"hmac-sha2-256", "hmac-sha1"}
-
-	supportedKexAlgos = []string{
-		"curve25519-sha256", "curve25519-sha256@libssh.org",
-		"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
-	}
-)
-
 type Server struct {
 	Srv     *ssh.Server
 	Handler *handler.Server
+
+	listening atomic.Bool
 }
 
 func (s *Server) Start() {
@@ -45,16 +40,40 @@ func (s *Server) Start() {
 		logger.Fatal(err)
 	}
 	proxyListener := &proxyproto.Listener{Listener: ln}
-	logger.Fatal(s.Srv.Serve(proxyListener))
+	s.listening.Store(true)
+	defer s.listening.Store(false)
+	if err = s.Srv.Serve(proxyListener); !errors.Is(err, ssh.ErrServerClosed) {
+		logger.Fatal(err)
+	}
+	logger.Info("SSH server stop accepting new connections")
+}
+
+// CheckListening 用于健康检查，SSH 服务正在监听时返回 nil
+func (s *Server) CheckListening() error {
+	if !s.listening.Load() {
+		return errors.New("ssh server is not listening")
+	}
+	return nil
+}
+
+// StopAccept 关闭监听，不再接受新的连接，已建立的连接不受影响
+func (s *Server) StopAccept() {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	cancelFunc()
+	// Shutdown 先关闭监听再等待连接结束，ctx 已取消所以不会等待
+	_ = s.Srv.Shutdown(ctx)
 }
 
 func (s *Server) Stop() {
 	ctx, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancelFunc()
-	logger.Fatal(s.Srv.Shutdown(ctx))
+	if err := s.Srv.Shutdown(ctx); err != nil {
+		logger.Errorf("SSH server shutdown err: %s, close all connections", err)
+		_ = s.Srv.Close()
+	}
 }
 
-const nextAuthMethod = "keyboard-interactive"
+const nextAuthMethod = authMethodKeyboardInteractive
 
 func NewSSHServer(jmsService *service.JMService) *Server {
 	cf := config.GlobalConfig
@@ -63,25 +82,40 @@ func NewSSHServer(jmsService *service.JMService) *Server {
 	if err != nil {
 		logger.Fatal(err)
 	}
-	singer, err := ParsePrivateKeyFromString(termCfg.HostKey)
+	signers, err := LoadHostSigners(termCfg.HostKey, cf.SSHHostKeyFiles, cf.KeyFolderPath)
+	if err != nil {
+		logger.Fatalf("Load SSH host keys failed: %s\n", err)
+	}
+	logger.Infof("SSH server host key algorithms: %s", strings.Join(signerAlgorithms(signers), ", "))
+	hostSigners := make([]ssh.Signer, 0, len(signers))
+	for i := range signers {
+		hostSigners = append(hostSigners, signers[i])
+	}
+	auth.InitSSHAuthLimiter(cf.SSHAuthFailureLimit, time.Duration(cf.SSHAuthFailureWindow)*time.Second,
+		time.Duration(cf.SSHAuthLockoutTime)*time.Second, cf.SSHAuthLimiterMaxIPs)
+	authMethods, err := ParseAuthMethods(cf.SSHAuthMethods)
 	if err != nil {
-		logger.Fatalf("Parse Terminal private key failed: %s\n", err)
+		logger.Fatal(err)
 	}
+	logger.Infof("SSH server auth methods: %s", strings.Join(authMethods, ", "))
+	algorithms, err := ParseSSHAlgorithms(cf)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	logger.Infof("SSH server algorithms: %s", algorithms)
 	sshHandler := handler.NewServer(termCfg, jmsService)
 	srv := &ssh.Server{
-		Addr:                       addr,
-		KeyboardInteractiveHandler: auth.SSHKeyboardInteractiveAuth,
-		PasswordHandler:            sshHandler.PasswordAuth,
-		PublicKeyHandler:           sshHandler.PublicKeyAuth,
-		NextAuthMethodsHandler:     func(ctx ssh.Context) []string { return []string{nextAuthMethod} },
-		HostSigners:                []ssh.Signer{singer},
+		Addr:         addr,
+		ConnCallback: auth.SSHConnCallback,
+		HostSigners:  hostSigners,
 		ServerConfigCallback: func(ctx ssh.Context) *gossh.ServerConfig {
-			cfg := gossh.Config{MACs: supportedMACs, KeyExchanges: supportedKexAlgos}
-			return &gossh.ServerConfig{Config: cfg}
+			return &gossh.ServerConfig{Config: algorithms.gosshConfig(), BannerCallback: authBannerCallback}
 		},
+		ConnectionFailedCallback:    sshConnFailedCallback,
 		Handler:                     sshHandler.SessionHandler,
 		LocalPortForwardingCallback: sshHandler.LocalPortForwardingPermission,
 		SubsystemHandlers:           map[string]ssh.SubsystemHandler{sshSubSystemSFTP: sshHandler.SFTPHandler},
+		SessionRequestCallback:      subsystemRequestCallback,
 		ChannelHandlers: map[string]ssh.ChannelHandler{
 			sshChannelSession: ssh.DefaultSessionHandler,
 			sshChannelDirectTCPIP: func(srv *ssh.Server, conn *gossh.ServerConn, newChan gossh.NewChannel, ctx ssh.Context) {
@@ -100,7 +134,24 @@ func NewSSHServer(jmsService *service.JMService) *Server {
 			},
 		},
 	}
-	return &Server{srv, sshHandler}
+	setAuthHandlers(srv, sshHandler, authMethods)
+	return &Server{Srv: srv, Handler: sshHandler}
+}
+
+// setAuthHandlers 只设置开启的认证方式的回调
+func setAuthHandlers(srv *ssh.Server, sshHandler *handler.Server, methods []string) {
+	for _, method := range methods {
+		switch method {
+		case authMethodPublicKey:
+			srv.PublicKeyHandler = sshHandler.PublicKeyAuth
+		case authMethodPassword:
+			srv.PasswordHandler = sshHandler.PasswordAuth
+		case authMethodKeyboardInteractive:
+			srv.KeyboardInteractiveHandler = auth.SSHKeyboardInteractiveAuth
+			srv.NextAuthMethodsHandler = func(ctx ssh.Context) []string { return []string{nextAuthMethod} }
+		}
+	}
+	auth.InitSSHAuthMethods(srv.KeyboardInteractiveHandler != nil)
 }
 
 type localForwardChannelData struct {
@@ -0,0 +1,74 @@
+package sshd
+
+import (
+	"strings"
+
+	"github.com/gliderlabs/ssh"
+
+	"github.com/jumpserver/koko/pkg/auth"
+	"github.com/jumpserver/koko/pkg/config"
+	"github.com/jumpserver/koko/pkg/jms-sdk-go/model"
+	"github.com/jumpserver/koko/pkg/logger"
+)
+
+/*
+SSH_ALLOWED_SUBSYSTEMS 允许客户端请求的 subsystem。只有 koko 实现了的 subsystem(目前只有 sftp)才能启用，
+未知或者没有允许的 subsystem 请求在回复前拒绝，并记录包含 subsystem 名称、用户和来源地址的审计日志。
+*/
+
+const sshSessionRequestSubsystem = "subsystem"
+
+const (
+	subsystemRejectUnknown  = "unknown"
+	subsystemRejectDisabled = "disabled"
+)
+
+// knownSubsystems koko 实现了的 subsystem
+var knownSubsystems = map[string]bool{
+	sshSubSystemSFTP: true,
+}
+
+// allowedSubsystems 配置中允许的 subsystem，环境变量中的多个值可以使用逗号分隔
+func allowedSubsystems() map[string]bool {
+	allowed := make(map[string]bool)
+	for _, item := range config.GetConf().SSHAllowedSubsystems {
+		for _, name := range strings.Split(item, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				allowed[name] = true
+			}
+		}
+	}
+	return allowed
+}
+
+// checkSubsystem 返回是否允许该 subsystem，不允许时同时返回原因
+func checkSubsystem(name string) (bool, string) {
+	if !knownSubsystems[name] {
+		return false, subsystemRejectUnknown
+	}
+	if !allowedSubsystems()[name] {
+		return false, subsystemRejectDisabled
+	}
+	return true, ""
+}
+
+// subsystemRequestCallback 作为 SessionRequestCallback，只检查 subsystem 请求，shell 和 exec 不受影响
+func subsystemRequestCallback(sess ssh.Session, requestType string) bool {
+	if requestType != sshSessionRequestSubsystem {
+		return true
+	}
+	name := sess.Subsystem()
+	connID, _ := sess.Context().Value(ssh.ContextKeySessionID).(string)
+	ok, reason := checkSubsystem(name)
+	if ok {
+		logger.Debugf("SSH conn[%s] subsystem %s requested", connID, name)
+		return true
+	}
+	username := "-"
+	if user, ok2 := sess.Context().Value(auth.ContextKeyUser).(*model.User); ok2 && user != nil {
+		username = user.String()
+	}
+	logger.Warnf("SSH subsystem request rejected: conn=%s user=%s remote_addr=%s subsystem=%q reason=%s",
+		connID, username, sess.RemoteAddr(), name, reason)
+	return false
+}
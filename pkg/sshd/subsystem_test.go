@@ -0,0 +1,120 @@
+package sshd
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"testing"
+
+	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/jumpserver/koko/pkg/config"
+)
+
+func startSubsystemTestServer(t *testing.T, handled chan<- string) string {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := gossh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subsystemHandler := func(sess ssh.Session) { handled <- sess.Subsystem() }
+	srv := &ssh.Server{
+		PasswordHandler: func(ctx ssh.Context, password string) ssh.AuthResult { return ssh.AuthSuccessful },
+		HostSigners:     []ssh.Signer{signer},
+		Handler:         func(sess ssh.Session) {},
+		SubsystemHandlers: map[string]ssh.SubsystemHandler{
+			sshSubSystemSFTP: subsystemHandler,
+			// 即使注册了处理函数，未知的 subsystem 也会被拒绝
+			"foo": subsystemHandler,
+		},
+		SessionRequestCallback: subsystemRequestCallback,
+	}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { _ = srv.Close() })
+	return ln.Addr().String()
+}
+
+func requestSubsystem(t *testing.T, addr, name string) error {
+	client, err := gossh.Dial("tcp", addr, &gossh.ClientConfig{
+		User:            "test",
+		Auth:            []gossh.AuthMethod{gossh.Password("test")},
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	sess, err := client.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	return sess.RequestSubsystem(name)
+}
+
+func TestSubsystemRequestCallback(t *testing.T) {
+	conf := config.GetConf()
+	conf.SSHAllowedSubsystems = []string{"sftp"}
+	prevConf := config.GlobalConfig
+	config.GlobalConfig = &conf
+	t.Cleanup(func() { config.GlobalConfig = prevConf })
+
+	handled := make(chan string, 2)
+	addr := startSubsystemTestServer(t, handled)
+
+	if err := requestSubsystem(t, addr, "foo"); err == nil {
+		t.Fatal("unknown subsystem foo accepted")
+	}
+	if err := requestSubsystem(t, addr, "sftp"); err != nil {
+		t.Fatalf("subsystem sftp rejected: %s", err)
+	}
+	if got := <-handled; got != "sftp" {
+		t.Fatalf("handled subsystem = %s, want sftp", got)
+	}
+
+	conf.SSHAllowedSubsystems = nil
+	if err := requestSubsystem(t, addr, "sftp"); err == nil {
+		t.Fatal("disabled subsystem sftp accepted")
+	}
+	select {
+	case name := <-handled:
+		t.Fatalf("rejected subsystem %s handled", name)
+	default:
+	}
+}
+
+func TestCheckSubsystem(t *testing.T) {
+	conf := config.GetConf()
+	conf.SSHAllowedSubsystems = []string{" sftp, foo "}
+	prevConf := config.GlobalConfig
+	config.GlobalConfig = &conf
+	t.Cleanup(func() { config.GlobalConfig = prevConf })
+
+	tests := []struct {
+		name   string
+		ok     bool
+		reason string
+	}{
+		{"sftp", true, ""},
+		{"foo", false, subsystemRejectUnknown},
+		{"", false, subsystemRejectUnknown},
+	}
+	for _, tt := range tests {
+		ok, reason := checkSubsystem(tt.name)
+		if ok != tt.ok || reason != tt.reason {
+			t.Errorf("checkSubsystem(%q) = %v, %q, want %v, %q", tt.name, ok, reason, tt.ok, tt.reason)
+		}
+	}
+	conf.SSHAllowedSubsystems = []string{}
+	if ok, reason := checkSubsystem("sftp"); ok || reason != subsystemRejectDisabled {
+		t.Errorf("checkSubsystem(sftp) = %v, %q, want false, %q", ok, reason, subsystemRejectDisabled)
+	}
+}
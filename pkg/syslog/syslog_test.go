@@ -0,0 +1,79 @@
+package syslog
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestForwarderSend(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+	f, err := NewForwarder(Options{Host: "127.0.0.1", Port: port, Hostname: "koko 1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.Send(Message{
+		Severity:  SeverityWarning,
+		MsgID:     "command",
+		Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		SDID:      "command",
+		Params:    []Param{{Name: "user", Value: "alice"}, {Name: "asset", Value: `web "01"]`}},
+		Msg:       "rm -rf /tmp/x",
+	})
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	reader := bufio.NewReader(conn)
+	length, err := reader.ReadString(' ')
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, _ := strconv.Atoi(strings.TrimSpace(length))
+	buf := make([]byte, size)
+	if _, err = reader.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	prefix := "<108>1 2024-01-02T03:04:05Z koko1 koko "
+	suffix := ` command [command@32473 user="alice" asset="web \"01\"\]"] rm -rf /tmp/x`
+	if got := string(buf); !strings.HasPrefix(got, prefix) || !strings.HasSuffix(got, suffix) {
+		t.Errorf("syslog message = %q, want %q...%q", got, prefix, suffix)
+	}
+}
+
+func TestForwarderSendNotBlock(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	_ = ln.Close()
+	f, err := NewForwarder(Options{Host: "127.0.0.1", Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < queueSize*2; i++ {
+			f.Send(Message{Severity: SeverityInfo, Msg: "ls"})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Send blocked while syslog server is unavailable")
+	}
+}
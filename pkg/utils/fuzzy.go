@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"unicode"
+)
+
+const (
+	fuzzyScoreMatch       = 16
+	fuzzyBonusConsecutive = 8
+	fuzzyBonusBoundary    = 8
+	fuzzyBonusFirstChar   = 4
+	fuzzyPenaltyGap       = 1
+)
+
+/*
+FuzzyMatch 参考 fzf 的打分方式做模糊匹配:
+pattern 中的字符按顺序(忽略大小写)出现在 text 中即匹配成功，
+连续匹配、单词边界（- _ . / 空格之后）匹配以及首字符匹配有额外加分，
+匹配字符之间的间隔会扣分。
+*/
+
+func FuzzyMatch(pattern, text string) (int, bool) {
+	patternRunes := []rune(pattern)
+	if len(patternRunes) == 0 {
+		return 0, true
+	}
+	textRunes := []rune(text)
+	var (
+		score     int
+		pIndex    int
+		lastMatch = -1
+	)
+	for i := 0; i < len(textRunes) && pIndex < len(patternRunes); i++ {
+		if unicode.ToLower(textRunes[i]) != unicode.ToLower(patternRunes[pIndex]) {
+			continue
+		}
+		score += fuzzyScoreMatch
+		switch {
+		case i == 0:
+			score += fuzzyBonusFirstChar + fuzzyBonusBoundary
+		case isFuzzyBoundary(textRunes[i-1]):
+			score += fuzzyBonusBoundary
+		}
+		if lastMatch >= 0 {
+			if i == lastMatch+1 {
+				score += fuzzyBonusConsecutive
+			} else {
+				score -= (i - lastMatch - 1) * fuzzyPenaltyGap
+			}
+		}
+		lastMatch = i
+		pIndex++
+	}
+	if pIndex < len(patternRunes) {
+		return 0, false
+	}
+	return score, true
+}
+
+func isFuzzyBoundary(r rune) bool {
+	switch r {
+	case '-', '_', '.', '/', ' ', ':', '@':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		text    string
+		matched bool
+	}{
+		{"webprd01", "web-prod-01.example.com", true},
+		{"WEB", "web-prod-01.example.com", true},
+		{"", "anything", true},
+		{"prdweb", "web-prod-01.example.com", false},
+		{"192.168", "192.168.1.1", true},
+	}
+	for _, tt := range tests {
+		if _, ok := FuzzyMatch(tt.pattern, tt.text); ok != tt.matched {
+			t.Errorf("FuzzyMatch(%q, %q) matched = %v, want %v", tt.pattern, tt.text, ok, tt.matched)
+		}
+	}
+}
+
+func TestFuzzyMatchScore(t *testing.T) {
+	consecutive, _ := FuzzyMatch("web", "web-prod-01")
+	scattered, _ := FuzzyMatch("web", "w-e-b-prod")
+	if consecutive <= scattered {
+		t.Errorf("consecutive score %d should be greater than scattered score %d", consecutive, scattered)
+	}
+	boundary, _ := FuzzyMatch("p", "web-prod")
+	inner, _ := FuzzyMatch("p", "webprod")
+	if boundary <= inner {
+		t.Errorf("boundary score %d should be greater than inner score %d", boundary, inner)
+	}
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// ParseKeySequence 解析快捷键，^ 加字符表示 Ctrl 组合键，^^ 表示字符 ^
+func ParseKeySequence(value string) ([]byte, error) {
+	var seq []byte
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c != '^' {
+			seq = append(seq, c)
+			continue
+		}
+		if i+1 == len(value) {
+			return nil, fmt.Errorf("incomplete control key in %q", value)
+		}
+		i++
+		next := value[i]
+		switch {
+		case next == '^':
+			seq = append(seq, '^')
+		case next == '?':
+			seq = append(seq, 0x7f)
+		case next >= '@' && next <= '_', next >= 'a' && next <= 'z':
+			seq = append(seq, next&0x1f)
+		default:
+			return nil, fmt.Errorf("invalid control key ^%c in %q", next, value)
+		}
+	}
+	return seq, nil
+}
+
+/*
+KeySequenceFilter 从用户输入中去除快捷键，快捷键可以跨多次读取。
+部分匹配的输入暂时保留，后续输入不匹配时原样输出。
+*/
+
+type KeySequenceFilter struct {
+	seq     []byte
+	matched int
+}
+
+func NewKeySequenceFilter(seq []byte) *KeySequenceFilter {
+	return &KeySequenceFilter{seq: seq}
+}
+
+func (f *KeySequenceFilter) Filter(p []byte) ([]byte, int) {
+	if len(f.seq) == 0 {
+		return p, 0
+	}
+	if f.matched == 0 && bytes.IndexByte(p, f.seq[0]) < 0 {
+		return p, 0
+	}
+	out := make([]byte, 0, len(p)+f.matched)
+	hits := 0
+	for _, c := range p {
+		if c == f.seq[f.matched] {
+			f.matched++
+			if f.matched == len(f.seq) {
+				hits++
+				f.matched = 0
+			}
+			continue
+		}
+		if f.matched > 0 {
+			out = append(out, f.seq[:f.matched]...)
+			f.matched = 0
+			if c == f.seq[0] {
+				f.matched = 1
+				if len(f.seq) == 1 {
+					hits++
+					f.matched = 0
+				}
+				continue
+			}
+		}
+		out = append(out, c)
+	}
+	return out, hits
+}
@@ -0,0 +1,48 @@
+package utils
+
+import "testing"
+
+func TestParseKeySequence(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "^]l", want: "\x1dl"},
+		{value: "^a^B", want: "\x01\x02"},
+		{value: "^^x", want: "^x"},
+		{value: "^", wantErr: true},
+		{value: "^1", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseKeySequence(tt.value)
+		if (err != nil) != tt.wantErr || string(got) != tt.want {
+			t.Errorf("ParseKeySequence(%q) = %q, %v, want %q", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestKeySequenceFilter(t *testing.T) {
+	f := NewKeySequenceFilter([]byte("\x1dl"))
+	steps := []struct {
+		input string
+		want  string
+		hits  int
+	}{
+		{"ls\r", "ls\r", 0},
+		{"a\x1dlb", "ab", 1},
+		// 快捷键被拆分到两次读取中
+		{"x\x1d", "x", 0},
+		{"l", "", 1},
+		// 部分匹配后不匹配时原样输出
+		{"\x1d", "", 0},
+		{"q", "\x1dq", 0},
+		{"\x1d\x1dl\x1dl", "\x1d", 2},
+	}
+	for i, step := range steps {
+		got, hits := f.Filter([]byte(step.input))
+		if string(got) != step.want || hits != step.hits {
+			t.Errorf("step %d filter(%q) = %q, %d, want %q, %d", i, step.input, got, hits, step.want, step.hits)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"strings"
+)
+
+// 内置的颜色主题
+const (
+	ThemeDefault = "default"
+	ThemeDark    = "dark"
+	ThemeLight   = "light"
+	ThemeNoColor = "no-color"
+)
+
+/*
+Theme 横幅和菜单使用的颜色主题，按语义角色配置 ANSI SGR 参数(如 "1;32" 表示绿色粗体)，
+参数为空时该角色不使用颜色
+*/
+
+type Theme struct {
+	Name        string
+	Title       string
+	Instruction string
+	Help        string
+	Warning     string
+	Error       string
+}
+
+var builtinThemes = map[string]Theme{
+	// 与之前的默认颜色一致
+	ThemeDefault: {Title: Bold + ";32", Instruction: Bold + ";32", Warning: "31", Error: "31"},
+	// 深色背景使用高亮颜色
+	ThemeDark: {Title: Bold + ";92", Instruction: Bold + ";96", Help: "37", Warning: Bold + ";93", Error: Bold + ";91"},
+	// 浅色背景避免使用黄色等对比度低的颜色
+	ThemeLight:   {Title: Bold + ";34", Instruction: Bold + ";34", Warning: Bold + ";35", Error: Bold + ";31"},
+	ThemeNoColor: {},
+}
+
+// GetTheme 获取内置主题，不存在时返回默认主题
+func GetTheme(name string) (Theme, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	theme, ok := builtinThemes[name]
+	if !ok {
+		name = ThemeDefault
+		theme = builtinThemes[ThemeDefault]
+	}
+	theme.Name = name
+	return theme, ok
+}
+
+// WithRoles 使用 "角色=SGR 参数" 格式的配置覆盖主题中的颜色，如 title=1;36
+func (t Theme) WithRoles(roles []string) Theme {
+	for _, item := range roles {
+		role, code, ok := strings.Cut(item, "=")
+		if !ok || !isValidSGR(strings.TrimSpace(code)) {
+			continue
+		}
+		code = strings.TrimSpace(code)
+		switch strings.ToLower(strings.TrimSpace(role)) {
+		case "title":
+			t.Title = code
+		case "instruction":
+			t.Instruction = code
+		case "help":
+			t.Help = code
+		case "warning":
+			t.Warning = code
+		case "error":
+			t.Error = code
+		}
+	}
+	return t
+}
+
+func isValidSGR(code string) bool {
+	for _, c := range code {
+		if (c < '0' || c > '9') && c != ';' {
+			return false
+		}
+	}
+	return true
+}
+
+func (t Theme) IsNoColor() bool {
+	return t.Name == ThemeNoColor
+}
+
+func wrapSGR(code, text string) string {
+	if code == "" {
+		return text
+	}
+	return ColorEscape + code + "m" + text + ColorEnd
+}
+
+func (t Theme) WrapTitle(text string) string {
+	return wrapSGR(t.Title, text)
+}
+
+func (t Theme) WrapInstruction(text string) string {
+	return wrapSGR(t.Instruction, text)
+}
+
+func (t Theme) WrapHelp(text string) string {
+	return wrapSGR(t.Help, text)
+}
+
+func (t Theme) WrapWarning(text string) string {
+	return wrapSGR(t.Warning, text)
+}
+
+func (t Theme) WrapError(text string) string {
+	return wrapSGR(t.Error, text)
+}
+
+// ColorMeta 模板中使用的颜色变量，no-color 主题时所有颜色变量为空
+func (t Theme) ColorMeta() ColorMeta {
+	meta := ColorMeta{
+		TitleColor:       sgrStart(t.Title),
+		InstructionColor: sgrStart(t.Instruction),
+		HelpColor:        sgrStart(t.Help),
+		WarningColor:     sgrStart(t.Warning),
+		ErrorColor:       sgrStart(t.Error),
+	}
+	if t.IsNoColor() {
+		return meta
+	}
+	meta.GreenBoldColor = ColorEscape + Bold + ";" + Green
+	meta.YellowBoldColor = ColorEscape + Bold + ";" + Yellow
+	meta.RedBoldColor = ColorEscape + Bold + ";" + Red
+	meta.ColorEnd = ColorEnd
+	return meta
+}
+
+func sgrStart(code string) string {
+	if code == "" {
+		return ""
+	}
+	return ColorEscape + code + "m"
+}
+
+/*
+ResolveTheme 根据配置和客户端终端选择主题。客户端设置了 NO_COLOR 环境变量或者
+TERM 为 dumb 时使用 no-color 主题
+*/
+
+func ResolveTheme(name string, roles []string, environ []string, term string) Theme {
+	if strings.EqualFold(term, "dumb") {
+		theme, _ := GetTheme(ThemeNoColor)
+		return theme
+	}
+	for _, item := range environ {
+		if key, value, ok := strings.Cut(item, "="); ok && key == "NO_COLOR" && value != "" {
+			theme, _ := GetTheme(ThemeNoColor)
+			return theme
+		}
+	}
+	theme, _ := GetTheme(name)
+	if theme.IsNoColor() {
+		return theme
+	}
+	return theme.WithRoles(roles)
+}
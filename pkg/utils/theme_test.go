@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestDefaultThemeColorMeta(t *testing.T) {
+	meta := NewColorMeta()
+	if meta.GreenBoldColor != "\033[1;32m" || meta.ColorEnd != "\033[0m" {
+		t.Errorf("NewColorMeta() legacy colors = %q %q", meta.GreenBoldColor, meta.ColorEnd)
+	}
+	if meta.InstructionColor != meta.GreenBoldColor {
+		t.Errorf("InstructionColor = %q, want %q", meta.InstructionColor, meta.GreenBoldColor)
+	}
+}
+
+func TestResolveTheme(t *testing.T) {
+	theme := ResolveTheme("dark", []string{"title=1;36", "help=bad", "unknown=1"}, nil, "xterm")
+	if theme.Name != ThemeDark || theme.Title != "1;36" || theme.Help != "37" {
+		t.Errorf("ResolveTheme() = %+v, want dark theme with title 1;36", theme)
+	}
+	if got := theme.WrapTitle("koko"); got != "\033[1;36mkoko\033[0m" {
+		t.Errorf("WrapTitle() = %q", got)
+	}
+	if theme, _ = GetTheme("not-exist"); theme.Name != ThemeDefault {
+		t.Errorf("GetTheme() unknown name = %s, want %s", theme.Name, ThemeDefault)
+	}
+
+	cases := []struct {
+		environ []string
+		term    string
+	}{
+		{environ: []string{"NO_COLOR=1"}, term: "xterm"},
+		{term: "dumb"},
+	}
+	for _, c := range cases {
+		theme = ResolveTheme("dark", []string{"title=1;36"}, c.environ, c.term)
+		if !theme.IsNoColor() || theme.WrapTitle("koko") != "koko" {
+			t.Errorf("ResolveTheme(%v, %s) = %+v, want no-color", c.environ, c.term, theme)
+		}
+		if meta := theme.ColorMeta(); meta.InstructionColor != "" || meta.ColorEnd != "" {
+			t.Errorf("no-color ColorMeta() = %+v, want empty", meta)
+		}
+	}
+}
@@ -14,10 +14,30 @@ const (
 	ColorEscape = "\033["
 	Green       = "32m"
 	Red         = "31m"
+	Yellow      = "33m"
 	ColorEnd    = ColorEscape + "0m"
 	Bold        = "1"
 )
 
+// ColorMeta 模板中可使用的颜色变量，如 {{.GreenBoldColor}}text{{.ColorEnd}}，
+// 横幅和菜单使用按主题设置的语义颜色，如 {{.InstructionColor}}text{{.ColorEnd}}
+type ColorMeta struct {
+	GreenBoldColor  string
+	YellowBoldColor string
+	RedBoldColor    string
+	ColorEnd        string
+
+	TitleColor       string
+	InstructionColor string
+	HelpColor        string
+	WarningColor     string
+	ErrorColor       string
+}
+
+func NewColorMeta() ColorMeta {
+	return builtinThemes[ThemeDefault].ColorMeta()
+}
+
 const (
 	CharClear     = "\x1b[H\x1b[2J"
 	CharTab       = "\t"
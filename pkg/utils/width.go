@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"regexp"
+
+	"github.com/mattn/go-runewidth"
+)
+
+var ansiEscapeRegexp = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// DisplayWidth 计算字符串在终端上的显示宽度，忽略 ANSI 颜色控制符，宽字符按两列计算
+func DisplayWidth(s string) int {
+	return runewidth.StringWidth(StripANSI(s))
+}
+
+// StripANSI 去除字符串中的 ANSI 控制符
+func StripANSI(s string) string {
+	return ansiEscapeRegexp.ReplaceAllString(s, "")
+}